@@ -9,7 +9,7 @@ import (
 // Grayscale produces a grayscale version of the image.
 func Grayscale(img image.Image) *image.NRGBA {
 	src := newScanner(img)
-	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
+	dst := newNRGBA(image.Rect(0, 0, src.w, src.h))
 	parallel(0, src.h, func(ys <-chan int) {
 		for y := range ys {
 			i := y * dst.Stride
@@ -31,10 +31,17 @@ func Grayscale(img image.Image) *image.NRGBA {
 	return dst
 }
 
+// grayscalePixel is the pixel function of Grayscale.
+func grayscalePixel(c color.NRGBA) color.NRGBA {
+	f := 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+	y := uint8(f + 0.5)
+	return color.NRGBA{y, y, y, c.A}
+}
+
 // Invert produces an inverted (negated) version of the image.
 func Invert(img image.Image) *image.NRGBA {
 	src := newScanner(img)
-	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
+	dst := newNRGBA(image.Rect(0, 0, src.w, src.h))
 	parallel(0, src.h, func(ys <-chan int) {
 		for y := range ys {
 			i := y * dst.Stride
@@ -51,6 +58,93 @@ func Invert(img image.Image) *image.NRGBA {
 	return dst
 }
 
+// Sepia produces a sepia-toned version of the image.
+// The percentage parameter sets the strength of the effect and must be in range (0, 100).
+// The percentage = 0 gives the original image, the percentage = 100 gives the full sepia tone.
+//
+// Example:
+//
+//	dstImage = imaging.Sepia(srcImage, 80)
+func Sepia(img image.Image, percentage float64) *image.NRGBA {
+	if percentage <= 0 {
+		return Clone(img)
+	}
+
+	return AdjustFunc(img, sepiaFunc(percentage))
+}
+
+// sepiaFunc returns the pixel function of Sepia.
+func sepiaFunc(percentage float64) func(c color.NRGBA) color.NRGBA {
+	k := math.Min(percentage, 100) / 100
+	return func(c color.NRGBA) color.NRGBA {
+		r := float64(c.R)
+		g := float64(c.G)
+		b := float64(c.B)
+		sr := 0.393*r + 0.769*g + 0.189*b
+		sg := 0.349*r + 0.686*g + 0.168*b
+		sb := 0.272*r + 0.534*g + 0.131*b
+		return color.NRGBA{
+			clamp(r + (sr-r)*k),
+			clamp(g + (sg-g)*k),
+			clamp(b + (sb-b)*k),
+			c.A,
+		}
+	}
+}
+
+// Duotone maps the luminance of the image onto a gradient between the dark and light colors.
+// Black pixels become the dark color and white pixels become the light color.
+// The alpha channel of the image is preserved.
+//
+// Example:
+//
+//	dstImage = imaging.Duotone(srcImage, color.NRGBA{20, 30, 90, 255}, color.NRGBA{250, 200, 120, 255})
+func Duotone(img image.Image, dark, light color.Color) *image.NRGBA {
+	c1 := color.NRGBAModel.Convert(dark).(color.NRGBA)
+	c2 := color.NRGBAModel.Convert(light).(color.NRGBA)
+
+	var lut [256][3]uint8
+	for i := 0; i < 256; i++ {
+		t := float64(i) / 255
+		lut[i][0] = clamp(float64(c1.R) + (float64(c2.R)-float64(c1.R))*t)
+		lut[i][1] = clamp(float64(c1.G) + (float64(c2.G)-float64(c1.G))*t)
+		lut[i][2] = clamp(float64(c1.B) + (float64(c2.B)-float64(c1.B))*t)
+	}
+
+	return AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		y := clamp(0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B))
+		v := lut[y]
+		return color.NRGBA{v[0], v[1], v[2], c.A}
+	})
+}
+
+// Tint blends the colors of the image with the given color. Opacity parameter
+// is the strength of the tint, it must be from 0.0 to 1.0.
+// The alpha channel of the image is preserved.
+//
+// Example:
+//
+//	dstImage = imaging.Tint(srcImage, color.NRGBA{255, 120, 0, 255}, 0.25)
+func Tint(img image.Image, tint color.Color, opacity float64) *image.NRGBA {
+	opacity = math.Min(math.Max(opacity, 0.0), 1.0)
+	if opacity == 0 {
+		return Clone(img)
+	}
+
+	c := color.NRGBAModel.Convert(tint).(color.NRGBA)
+	var lut [3][256]uint8
+	for i := 0; i < 256; i++ {
+		v := float64(i)
+		lut[0][i] = clamp(v + (float64(c.R)-v)*opacity)
+		lut[1][i] = clamp(v + (float64(c.G)-v)*opacity)
+		lut[2][i] = clamp(v + (float64(c.B)-v)*opacity)
+	}
+
+	return AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		return color.NRGBA{lut[0][c.R], lut[1][c.G], lut[2][c.B], c.A}
+	})
+}
+
 // AdjustSaturation changes the saturation of the image using the percentage parameter and returns the adjusted image.
 // The percentage must be in the range (-100, 100).
 // The percentage = 0 gives the original image.
@@ -61,15 +155,21 @@ func Invert(img image.Image) *image.NRGBA {
 //
 //	dstImage = imaging.AdjustSaturation(srcImage, 25) // Increase image saturation by 25%.
 //	dstImage = imaging.AdjustSaturation(srcImage, -10) // Decrease image saturation by 10%.
-func AdjustSaturation(img image.Image, percentage float64) *image.NRGBA {
+//	dstImage = imaging.AdjustSaturation(srcImage, 25, imaging.InColorSpace(imaging.ColorSpaceOkLab))
+func AdjustSaturation(img image.Image, percentage float64, opts ...AdjustOption) *image.NRGBA {
 	if percentage == 0 {
 		return Clone(img)
 	}
 
+	return AdjustColors(img, SaturationAdjustment(percentage, opts...))
+}
+
+// saturationFunc returns the pixel function of AdjustSaturation.
+func saturationFunc(percentage float64) func(c color.NRGBA) color.NRGBA {
 	percentage = math.Min(math.Max(percentage, -100), 100)
 	multiplier := 1 + percentage/100
 
-	return AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+	return func(c color.NRGBA) color.NRGBA {
 		h, s, l := rgbToHSL(c.R, c.G, c.B)
 		s *= multiplier
 		if s > 1 {
@@ -77,7 +177,7 @@ func AdjustSaturation(img image.Image, percentage float64) *image.NRGBA {
 		}
 		r, g, b := hslToRGB(h, s, l)
 		return color.NRGBA{r, g, b, c.A}
-	})
+	}
 }
 
 // AdjustHue changes the hue of the image using the shift parameter (measured in degrees) and returns the adjusted image.
@@ -89,14 +189,20 @@ func AdjustSaturation(img image.Image, percentage float64) *image.NRGBA {
 //
 //	dstImage = imaging.AdjustHue(srcImage, 90) // Shift Hue by 90°.
 //	dstImage = imaging.AdjustHue(srcImage, -30) // Shift Hue by -30°.
-func AdjustHue(img image.Image, shift float64) *image.NRGBA {
+//	dstImage = imaging.AdjustHue(srcImage, 90, imaging.InColorSpace(imaging.ColorSpaceOkLab))
+func AdjustHue(img image.Image, shift float64, opts ...AdjustOption) *image.NRGBA {
 	if math.Mod(shift, 360) == 0 {
 		return Clone(img)
 	}
 
+	return AdjustColors(img, HueAdjustment(shift, opts...))
+}
+
+// hueFunc returns the pixel function of AdjustHue.
+func hueFunc(shift float64) func(c color.NRGBA) color.NRGBA {
 	summand := shift / 360
 
-	return AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+	return func(c color.NRGBA) color.NRGBA {
 		h, s, l := rgbToHSL(c.R, c.G, c.B)
 		h += summand
 		h = math.Mod(h, 1)
@@ -106,7 +212,7 @@ func AdjustHue(img image.Image, shift float64) *image.NRGBA {
 		}
 		r, g, b := hslToRGB(h, s, l)
 		return color.NRGBA{r, g, b, c.A}
-	})
+	}
 }
 
 // AdjustContrast changes the contrast of the image using the percentage parameter and returns the adjusted image.
@@ -117,11 +223,17 @@ func AdjustHue(img image.Image, shift float64) *image.NRGBA {
 //
 //	dstImage = imaging.AdjustContrast(srcImage, -10) // Decrease image contrast by 10%.
 //	dstImage = imaging.AdjustContrast(srcImage, 20) // Increase image contrast by 20%.
-func AdjustContrast(img image.Image, percentage float64) *image.NRGBA {
+//	dstImage = imaging.AdjustContrast(srcImage, 20, imaging.InColorSpace(imaging.ColorSpaceOkLab))
+func AdjustContrast(img image.Image, percentage float64, opts ...AdjustOption) *image.NRGBA {
 	if percentage == 0 {
 		return Clone(img)
 	}
 
+	return AdjustColors(img, ContrastAdjustment(percentage, opts...))
+}
+
+// contrastLUT returns the lookup table of AdjustContrast.
+func contrastLUT(percentage float64) []uint8 {
 	percentage = math.Min(math.Max(percentage, -100.0), 100.0)
 	lut := make([]uint8, 256)
 
@@ -136,8 +248,7 @@ func AdjustContrast(img image.Image, percentage float64) *image.NRGBA {
 			lut[i] = uint8(float64(i)/255.0+0.5) * 255
 		}
 	}
-
-	return adjustLUT(img, lut)
+	return lut
 }
 
 // AdjustBrightness changes the brightness of the image using the percentage parameter and returns the adjusted image.
@@ -148,11 +259,17 @@ func AdjustContrast(img image.Image, percentage float64) *image.NRGBA {
 //
 //	dstImage = imaging.AdjustBrightness(srcImage, -15) // Decrease image brightness by 15%.
 //	dstImage = imaging.AdjustBrightness(srcImage, 10) // Increase image brightness by 10%.
-func AdjustBrightness(img image.Image, percentage float64) *image.NRGBA {
+//	dstImage = imaging.AdjustBrightness(srcImage, 10, imaging.InColorSpace(imaging.ColorSpaceOkLab))
+func AdjustBrightness(img image.Image, percentage float64, opts ...AdjustOption) *image.NRGBA {
 	if percentage == 0 {
 		return Clone(img)
 	}
 
+	return AdjustColors(img, BrightnessAdjustment(percentage, opts...))
+}
+
+// brightnessLUT returns the lookup table of AdjustBrightness.
+func brightnessLUT(percentage float64) []uint8 {
 	percentage = math.Min(math.Max(percentage, -100.0), 100.0)
 	lut := make([]uint8, 256)
 
@@ -160,8 +277,7 @@ func AdjustBrightness(img image.Image, percentage float64) *image.NRGBA {
 	for i := 0; i < 256; i++ {
 		lut[i] = clamp(float64(i) + shift)
 	}
-
-	return adjustLUT(img, lut)
+	return lut
 }
 
 // AdjustGamma performs a gamma correction on the image and returns the adjusted image.
@@ -176,14 +292,18 @@ func AdjustGamma(img image.Image, gamma float64) *image.NRGBA {
 		return Clone(img)
 	}
 
+	return adjustLUT(img, gammaLUT(gamma))
+}
+
+// gammaLUT returns the lookup table of AdjustGamma.
+func gammaLUT(gamma float64) []uint8 {
 	e := 1.0 / math.Max(gamma, 0.0001)
 	lut := make([]uint8, 256)
 
 	for i := 0; i < 256; i++ {
 		lut[i] = clamp(math.Pow(float64(i)/255.0, e) * 255.0)
 	}
-
-	return adjustLUT(img, lut)
+	return lut
 }
 
 // AdjustSigmoid changes the contrast of the image using a sigmoidal function and returns the adjusted image.
@@ -201,6 +321,11 @@ func AdjustSigmoid(img image.Image, midpoint, factor float64) *image.NRGBA {
 		return Clone(img)
 	}
 
+	return adjustLUT(img, sigmoidLUT(midpoint, factor))
+}
+
+// sigmoidLUT returns the lookup table of AdjustSigmoid.
+func sigmoidLUT(midpoint, factor float64) []uint8 {
 	lut := make([]uint8, 256)
 	a := math.Min(math.Max(midpoint, 0.0), 1.0)
 	b := math.Abs(factor)
@@ -223,8 +348,58 @@ func AdjustSigmoid(img image.Image, midpoint, factor float64) *image.NRGBA {
 			lut[i] = clamp(f * 255.0)
 		}
 	}
+	return lut
+}
+
+// Posterize reduces the number of tonal levels in each color channel of the image
+// and returns the adjusted image. The levels parameter must be in range [2, 256].
+// The levels = 256 gives the original image.
+//
+// Example:
+//
+//	dstImage = imaging.Posterize(srcImage, 4) // Four levels per channel.
+func Posterize(img image.Image, levels int) *image.NRGBA {
+	if levels >= 256 {
+		return Clone(img)
+	}
+	return adjustLUT(img, posterizeLUT(levels))
+}
+
+// posterizeLUT returns the lookup table of Posterize.
+func posterizeLUT(levels int) []uint8 {
+	if levels < 2 {
+		levels = 2
+	}
+
+	lut := make([]uint8, 256)
+	n := float64(levels - 1)
+	for i := 0; i < 256; i++ {
+		lut[i] = clamp(math.Floor(float64(i)*n/255+0.5) * 255 / n)
+	}
+	return lut
+}
+
+// Solarize inverts the color channel values of the image that are greater than or equal
+// to the threshold and returns the adjusted image. The threshold = 0 gives the inverted image.
+//
+// Example:
+//
+//	dstImage = imaging.Solarize(srcImage, 128)
+func Solarize(img image.Image, threshold uint8) *image.NRGBA {
+	return adjustLUT(img, solarizeLUT(threshold))
+}
 
-	return adjustLUT(img, lut)
+// solarizeLUT returns the lookup table of Solarize.
+func solarizeLUT(threshold uint8) []uint8 {
+	lut := make([]uint8, 256)
+	for i := 0; i < 256; i++ {
+		if i >= int(threshold) {
+			lut[i] = uint8(255 - i)
+		} else {
+			lut[i] = uint8(i)
+		}
+	}
+	return lut
 }
 
 func sigmoid(a, b, x float64) float64 {
@@ -234,7 +409,7 @@ func sigmoid(a, b, x float64) float64 {
 // adjustLUT applies the given lookup table to the colors of the image.
 func adjustLUT(img image.Image, lut []uint8) *image.NRGBA {
 	src := newScanner(img)
-	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
+	dst := newNRGBA(image.Rect(0, 0, src.w, src.h))
 	lut = lut[0:256]
 	parallel(0, src.h, func(ys <-chan int) {
 		for y := range ys {
@@ -269,7 +444,7 @@ func adjustLUT(img image.Image, lut []uint8) *image.NRGBA {
 //	)
 func AdjustFunc(img image.Image, fn func(c color.NRGBA) color.NRGBA) *image.NRGBA {
 	src := newScanner(img)
-	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
+	dst := newNRGBA(image.Rect(0, 0, src.w, src.h))
 	parallel(0, src.h, func(ys <-chan int) {
 		for y := range ys {
 			i := y * dst.Stride
@@ -291,3 +466,318 @@ func AdjustFunc(img image.Image, fn func(c color.NRGBA) color.NRGBA) *image.NRGB
 	})
 	return dst
 }
+
+// ColorSpace specifies the color space the color adjustments operate in.
+type ColorSpace int
+
+// Color spaces of the color adjustments.
+const (
+	// ColorSpaceRGB adjusts the sRGB channel values, and the saturation and hue of
+	// the HSL model derived from them. It's the default.
+	ColorSpaceRGB ColorSpace = iota
+
+	// ColorSpaceOkLab adjusts the lightness, chroma and hue of the perceptually uniform
+	// OkLab color space, so e.g. changing the saturation or hue keeps the perceived
+	// lightness, and changing the brightness or contrast keeps the saturation and hue.
+	// The colors are brought into the sRGB gamut with GamutClipChroma.
+	ColorSpaceOkLab
+)
+
+// AdjustOption sets an optional parameter for the color adjustments.
+type AdjustOption func(*adjustConfig)
+
+type adjustConfig struct {
+	space ColorSpace
+}
+
+func newAdjustConfig(opts []AdjustOption) adjustConfig {
+	var cfg adjustConfig
+	for _, option := range opts {
+		option(&cfg)
+	}
+	return cfg
+}
+
+// InColorSpace returns an AdjustOption that sets the color space of AdjustBrightness,
+// AdjustContrast, AdjustSaturation and AdjustHue and their variants.
+// Default is ColorSpaceRGB.
+func InColorSpace(space ColorSpace) AdjustOption {
+	return func(c *adjustConfig) {
+		c.space = space
+	}
+}
+
+// ColorAdjustment is a color adjustment applied pixel by pixel, such as the ones of
+// AdjustBrightness or AdjustSaturation. AdjustColors applies several adjustments in
+// a single pass over the pixels. The zero value leaves the colors unchanged.
+type ColorAdjustment struct {
+	lut   []uint8
+	pixel func(c color.NRGBA) color.NRGBA
+}
+
+// BrightnessAdjustment returns the color adjustment of AdjustBrightness.
+func BrightnessAdjustment(percentage float64, opts ...AdjustOption) ColorAdjustment {
+	if percentage == 0 {
+		return ColorAdjustment{}
+	}
+	if newAdjustConfig(opts).space == ColorSpaceOkLab {
+		return ColorAdjustment{pixel: oklabBrightnessFunc(percentage)}
+	}
+	return ColorAdjustment{lut: brightnessLUT(percentage)}
+}
+
+// ContrastAdjustment returns the color adjustment of AdjustContrast.
+func ContrastAdjustment(percentage float64, opts ...AdjustOption) ColorAdjustment {
+	if percentage == 0 {
+		return ColorAdjustment{}
+	}
+	if newAdjustConfig(opts).space == ColorSpaceOkLab {
+		return ColorAdjustment{pixel: oklabContrastFunc(percentage)}
+	}
+	return ColorAdjustment{lut: contrastLUT(percentage)}
+}
+
+// GammaAdjustment returns the color adjustment of AdjustGamma.
+func GammaAdjustment(gamma float64) ColorAdjustment {
+	if gamma == 1 {
+		return ColorAdjustment{}
+	}
+	return ColorAdjustment{lut: gammaLUT(gamma)}
+}
+
+// SigmoidAdjustment returns the color adjustment of AdjustSigmoid.
+func SigmoidAdjustment(midpoint, factor float64) ColorAdjustment {
+	if factor == 0 {
+		return ColorAdjustment{}
+	}
+	return ColorAdjustment{lut: sigmoidLUT(midpoint, factor)}
+}
+
+// PosterizeAdjustment returns the color adjustment of Posterize.
+func PosterizeAdjustment(levels int) ColorAdjustment {
+	if levels >= 256 {
+		return ColorAdjustment{}
+	}
+	return ColorAdjustment{lut: posterizeLUT(levels)}
+}
+
+// SolarizeAdjustment returns the color adjustment of Solarize.
+func SolarizeAdjustment(threshold uint8) ColorAdjustment {
+	return ColorAdjustment{lut: solarizeLUT(threshold)}
+}
+
+// InvertAdjustment returns the color adjustment of Invert.
+func InvertAdjustment() ColorAdjustment {
+	return ColorAdjustment{lut: solarizeLUT(0)}
+}
+
+// GrayscaleAdjustment returns the color adjustment of Grayscale.
+func GrayscaleAdjustment() ColorAdjustment {
+	return ColorAdjustment{pixel: grayscalePixel}
+}
+
+// SaturationAdjustment returns the color adjustment of AdjustSaturation.
+func SaturationAdjustment(percentage float64, opts ...AdjustOption) ColorAdjustment {
+	if percentage == 0 {
+		return ColorAdjustment{}
+	}
+	if newAdjustConfig(opts).space == ColorSpaceOkLab {
+		return ColorAdjustment{pixel: oklabSaturationFunc(percentage)}
+	}
+	return ColorAdjustment{pixel: saturationFunc(percentage)}
+}
+
+// HueAdjustment returns the color adjustment of AdjustHue.
+func HueAdjustment(shift float64, opts ...AdjustOption) ColorAdjustment {
+	if math.Mod(shift, 360) == 0 {
+		return ColorAdjustment{}
+	}
+	if newAdjustConfig(opts).space == ColorSpaceOkLab {
+		return ColorAdjustment{pixel: oklabHueFunc(shift)}
+	}
+	return ColorAdjustment{pixel: hueFunc(shift)}
+}
+
+// SepiaAdjustment returns the color adjustment of Sepia.
+func SepiaAdjustment(percentage float64) ColorAdjustment {
+	if percentage <= 0 {
+		return ColorAdjustment{}
+	}
+	return ColorAdjustment{pixel: sepiaFunc(percentage)}
+}
+
+// FuncAdjustment returns the color adjustment of AdjustFunc.
+func FuncAdjustment(fn func(c color.NRGBA) color.NRGBA) ColorAdjustment {
+	return ColorAdjustment{pixel: fn}
+}
+
+// AdjustColors applies the color adjustments to the image in order in a single pass over
+// the pixels and returns the adjusted image. The result is the same as applying the
+// adjustments one by one, but only the resulting image is allocated. The consecutive
+// adjustments based on lookup tables, i.e. all the ones of the channel values such as
+// brightness, contrast and gamma, are composed into one table.
+//
+// Example:
+//
+//	dstImage := imaging.AdjustColors(srcImage,
+//		imaging.GammaAdjustment(1.2),
+//		imaging.BrightnessAdjustment(5),
+//		imaging.ContrastAdjustment(10),
+//		imaging.SaturationAdjustment(20),
+//	)
+func AdjustColors(img image.Image, adjustments ...ColorAdjustment) *image.NRGBA {
+	stages := fuseColorAdjustments(adjustments)
+	src := newScanner(img)
+	dst := newNRGBA(image.Rect(0, 0, src.w, src.h))
+	parallel(0, src.h, func(ys <-chan int) {
+		for y := range ys {
+			i := y * dst.Stride
+			row := dst.Pix[i : i+src.w*4]
+			src.scan(0, y, src.w, y+1, row)
+			adjustPixels(row, stages)
+		}
+	})
+	return dst
+}
+
+// AdjustColorsInPlace applies the color adjustments to the image like AdjustColors,
+// but it changes the pixels of the image instead of allocating a new one.
+//
+// Example:
+//
+//	imaging.AdjustColorsInPlace(img,
+//		imaging.BrightnessAdjustment(5),
+//		imaging.SaturationAdjustment(20),
+//	)
+func AdjustColorsInPlace(img *image.NRGBA, adjustments ...ColorAdjustment) {
+	stages := fuseColorAdjustments(adjustments)
+	if len(stages) == 0 {
+		return
+	}
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			i := y * img.Stride
+			adjustPixels(img.Pix[i:i+w*4], stages)
+		}
+	})
+}
+
+// fuseColorAdjustments drops the no-op adjustments and composes the consecutive
+// lookup tables into one.
+func fuseColorAdjustments(adjustments []ColorAdjustment) []ColorAdjustment {
+	var stages []ColorAdjustment
+	for _, adj := range adjustments {
+		n := len(stages)
+		switch {
+		case adj.lut == nil && adj.pixel == nil:
+		case adj.lut != nil && n > 0 && stages[n-1].lut != nil:
+			lut := make([]uint8, 256)
+			for i, v := range stages[n-1].lut[0:256] {
+				lut[i] = adj.lut[v]
+			}
+			stages[n-1].lut = lut
+		default:
+			stages = append(stages, adj)
+		}
+	}
+	return stages
+}
+
+// adjustPixels applies the fused color adjustments to the NRGBA pixels.
+func adjustPixels(pix []uint8, stages []ColorAdjustment) {
+	switch {
+	case len(stages) == 0:
+		return
+	case len(stages) == 1 && stages[0].lut != nil:
+		lut := stages[0].lut[0:256]
+		for i := 0; i+4 <= len(pix); i += 4 {
+			d := pix[i : i+3 : i+3]
+			d[0] = lut[d[0]]
+			d[1] = lut[d[1]]
+			d[2] = lut[d[2]]
+		}
+		return
+	}
+	for i := 0; i+4 <= len(pix); i += 4 {
+		d := pix[i : i+4 : i+4]
+		c := color.NRGBA{d[0], d[1], d[2], d[3]}
+		for _, s := range stages {
+			if s.lut != nil {
+				c.R, c.G, c.B = s.lut[c.R], s.lut[c.G], s.lut[c.B]
+			} else {
+				c = s.pixel(c)
+			}
+		}
+		d[0] = c.R
+		d[1] = c.G
+		d[2] = c.B
+		d[3] = c.A
+	}
+}
+
+// GrayscaleInPlace converts the image to grayscale like Grayscale, changing its pixels.
+func GrayscaleInPlace(img *image.NRGBA) {
+	AdjustColorsInPlace(img, GrayscaleAdjustment())
+}
+
+// InvertInPlace inverts the image like Invert, changing its pixels.
+func InvertInPlace(img *image.NRGBA) {
+	AdjustColorsInPlace(img, InvertAdjustment())
+}
+
+// SepiaInPlace tones the image like Sepia, changing its pixels.
+func SepiaInPlace(img *image.NRGBA, percentage float64) {
+	AdjustColorsInPlace(img, SepiaAdjustment(percentage))
+}
+
+// AdjustSaturationInPlace changes the saturation of the image like AdjustSaturation,
+// changing its pixels.
+func AdjustSaturationInPlace(img *image.NRGBA, percentage float64, opts ...AdjustOption) {
+	AdjustColorsInPlace(img, SaturationAdjustment(percentage, opts...))
+}
+
+// AdjustHueInPlace shifts the hue of the image like AdjustHue, changing its pixels.
+func AdjustHueInPlace(img *image.NRGBA, shift float64, opts ...AdjustOption) {
+	AdjustColorsInPlace(img, HueAdjustment(shift, opts...))
+}
+
+// AdjustContrastInPlace changes the contrast of the image like AdjustContrast,
+// changing its pixels.
+func AdjustContrastInPlace(img *image.NRGBA, percentage float64, opts ...AdjustOption) {
+	AdjustColorsInPlace(img, ContrastAdjustment(percentage, opts...))
+}
+
+// AdjustBrightnessInPlace changes the brightness of the image like AdjustBrightness,
+// changing its pixels.
+func AdjustBrightnessInPlace(img *image.NRGBA, percentage float64, opts ...AdjustOption) {
+	AdjustColorsInPlace(img, BrightnessAdjustment(percentage, opts...))
+}
+
+// AdjustGammaInPlace performs a gamma correction on the image like AdjustGamma,
+// changing its pixels.
+func AdjustGammaInPlace(img *image.NRGBA, gamma float64) {
+	AdjustColorsInPlace(img, GammaAdjustment(gamma))
+}
+
+// AdjustSigmoidInPlace changes the contrast of the image like AdjustSigmoid,
+// changing its pixels.
+func AdjustSigmoidInPlace(img *image.NRGBA, midpoint, factor float64) {
+	AdjustColorsInPlace(img, SigmoidAdjustment(midpoint, factor))
+}
+
+// PosterizeInPlace reduces the color levels of the image like Posterize, changing its pixels.
+func PosterizeInPlace(img *image.NRGBA, levels int) {
+	AdjustColorsInPlace(img, PosterizeAdjustment(levels))
+}
+
+// SolarizeInPlace solarizes the image like Solarize, changing its pixels.
+func SolarizeInPlace(img *image.NRGBA, threshold uint8) {
+	AdjustColorsInPlace(img, SolarizeAdjustment(threshold))
+}
+
+// AdjustFuncInPlace applies the fn function to each pixel of the image like AdjustFunc,
+// changing its pixels.
+func AdjustFuncInPlace(img *image.NRGBA, fn func(c color.NRGBA) color.NRGBA) {
+	AdjustColorsInPlace(img, FuncAdjustment(fn))
+}
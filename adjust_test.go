@@ -3,6 +3,8 @@ package imaging
 import (
 	"image"
 	"image/color"
+	"image/draw"
+	"math"
 	"testing"
 )
 
@@ -1116,3 +1118,567 @@ func BenchmarkAdjustFunc(b *testing.B) {
 		})
 	}
 }
+
+func TestSepia(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 1),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x00, 0x00, 0x00, 0x01, 0x80, 0x80, 0x80, 0x02,
+			0x11, 0x22, 0x33, 0xff, 0xff, 0xff, 0xff, 0xff,
+		},
+	}
+	testCases := []struct {
+		name       string
+		percentage float64
+		want       *image.NRGBA
+	}{
+		{
+			"Sepia 2x2 0",
+			0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x01, 0x80, 0x80, 0x80, 0x02,
+					0x11, 0x22, 0x33, 0xff, 0xff, 0xff, 0xff, 0xff,
+				},
+			},
+		},
+		{
+			"Sepia 2x2 50",
+			50,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x01, 0x96, 0x8d, 0x7c, 0x02,
+					0x1e, 0x24, 0x28, 0xff, 0xff, 0xff, 0xf7, 0xff,
+				},
+			},
+		},
+		{
+			"Sepia 2x2 100",
+			100,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x01, 0xad, 0x9a, 0x78, 0x02,
+					0x2a, 0x26, 0x1d, 0xff, 0xff, 0xff, 0xef, 0xff,
+				},
+			},
+		},
+		{
+			"Sepia 2x2 200",
+			200,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x01, 0xad, 0x9a, 0x78, 0x02,
+					0x2a, 0x26, 0x1d, 0xff, 0xff, 0xff, 0xef, 0xff,
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Sepia(src, tc.percentage)
+			if !compareNRGBA(got, tc.want, 0) {
+				t.Fatalf("got result %#v want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func BenchmarkSepia(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Sepia(testdataBranchesJPG, 80)
+	}
+}
+
+func TestDuotone(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 1),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x00, 0x00, 0x00, 0x01, 0x80, 0x80, 0x80, 0x02,
+			0x11, 0x22, 0x33, 0xff, 0xff, 0xff, 0xff, 0xff,
+		},
+	}
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 2),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x20, 0x10, 0x60, 0x01, 0x88, 0x78, 0x50, 0x02,
+			0x39, 0x29, 0x5c, 0xff, 0xf0, 0xe0, 0x40, 0xff,
+		},
+	}
+	got := Duotone(src, color.NRGBA{0x20, 0x10, 0x60, 0xff}, color.NRGBA{0xf0, 0xe0, 0x40, 0xff})
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("got result %#v want %#v", got, want)
+	}
+}
+
+func BenchmarkDuotone(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Duotone(testdataBranchesJPG, color.Black, color.White)
+	}
+}
+
+func TestTint(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 1),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x00, 0x00, 0x00, 0x01, 0x80, 0x80, 0x80, 0x02,
+			0x11, 0x22, 0x33, 0xff, 0xff, 0xff, 0xff, 0xff,
+		},
+	}
+	testCases := []struct {
+		name    string
+		opacity float64
+		want    *image.NRGBA
+	}{
+		{
+			"Tint 2x2 0",
+			0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x01, 0x80, 0x80, 0x80, 0x02,
+					0x11, 0x22, 0x33, 0xff, 0xff, 0xff, 0xff, 0xff,
+				},
+			},
+		},
+		{
+			"Tint 2x2 0.25",
+			0.25,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x40, 0x20, 0x00, 0x01, 0xa0, 0x80, 0x60, 0x02,
+					0x4d, 0x3a, 0x26, 0xff, 0xff, 0xdf, 0xbf, 0xff,
+				},
+			},
+		},
+		{
+			"Tint 2x2 1",
+			1,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0xff, 0x80, 0x00, 0x01, 0xff, 0x80, 0x00, 0x02,
+					0xff, 0x80, 0x00, 0xff, 0xff, 0x80, 0x00, 0xff,
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Tint(src, color.NRGBA{0xff, 0x80, 0x00, 0xff}, tc.opacity)
+			if !compareNRGBA(got, tc.want, 0) {
+				t.Fatalf("got result %#v want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func BenchmarkTint(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Tint(testdataBranchesJPG, color.NRGBA{0xff, 0x80, 0x00, 0xff}, 0.3)
+	}
+}
+
+func TestPosterize(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x00, 0x40, 0x80, 0x01, 0xc0, 0x11, 0xff, 0xff,
+		},
+	}
+	testCases := []struct {
+		name   string
+		levels int
+		want   *image.NRGBA
+	}{
+		{
+			"Posterize 2x1 256",
+			256,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x40, 0x80, 0x01, 0xc0, 0x11, 0xff, 0xff,
+				},
+			},
+		},
+		{
+			"Posterize 2x1 4",
+			4,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x55, 0xaa, 0x01, 0xaa, 0x00, 0xff, 0xff,
+				},
+			},
+		},
+		{
+			"Posterize 2x1 2",
+			2,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0xff, 0x01, 0xff, 0x00, 0xff, 0xff,
+				},
+			},
+		},
+		{
+			"Posterize 2x1 0",
+			0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0xff, 0x01, 0xff, 0x00, 0xff, 0xff,
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Posterize(src, tc.levels)
+			if !compareNRGBA(got, tc.want, 0) {
+				t.Fatalf("got result %#v want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func BenchmarkPosterize(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Posterize(testdataBranchesJPG, 4)
+	}
+}
+
+func TestSolarize(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x00, 0x40, 0x80, 0x01, 0xc0, 0x11, 0xff, 0xff,
+		},
+	}
+	testCases := []struct {
+		name      string
+		threshold uint8
+		want      *image.NRGBA
+	}{
+		{
+			"Solarize 2x1 0",
+			0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0xff, 0xbf, 0x7f, 0x01, 0x3f, 0xee, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"Solarize 2x1 128",
+			128,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x40, 0x7f, 0x01, 0x3f, 0x11, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"Solarize 2x1 255",
+			255,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x40, 0x80, 0x01, 0xc0, 0x11, 0x00, 0xff,
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Solarize(src, tc.threshold)
+			if !compareNRGBA(got, tc.want, 0) {
+				t.Fatalf("got result %#v want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func BenchmarkSolarize(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Solarize(testdataBranchesJPG, 128)
+	}
+}
+
+func TestAdjustColors(t *testing.T) {
+	img := testdataFlowersSmallPNG
+	swap := func(c color.NRGBA) color.NRGBA { return color.NRGBA{c.B, c.R, c.G, c.A} }
+	testCases := []struct {
+		name        string
+		adjustments []ColorAdjustment
+		want        *image.NRGBA
+	}{
+		{
+			"none",
+			nil,
+			Clone(img),
+		},
+		{
+			"no-op",
+			[]ColorAdjustment{
+				{}, BrightnessAdjustment(0), ContrastAdjustment(0), GammaAdjustment(1), SigmoidAdjustment(0.5, 0),
+				PosterizeAdjustment(256), SaturationAdjustment(0), HueAdjustment(-360), SepiaAdjustment(0),
+			},
+			Clone(img),
+		},
+		{
+			"lookup tables",
+			[]ColorAdjustment{GammaAdjustment(1.2), BrightnessAdjustment(5), ContrastAdjustment(10), SigmoidAdjustment(0.4, 5)},
+			AdjustSigmoid(AdjustContrast(AdjustBrightness(AdjustGamma(img, 1.2), 5), 10), 0.4, 5),
+		},
+		{
+			"single function",
+			[]ColorAdjustment{SaturationAdjustment(20)},
+			AdjustSaturation(img, 20),
+		},
+		{
+			"mixed",
+			[]ColorAdjustment{
+				GammaAdjustment(0.8), SaturationAdjustment(-30), InvertAdjustment(), SolarizeAdjustment(200),
+				HueAdjustment(90), FuncAdjustment(swap), PosterizeAdjustment(4), SepiaAdjustment(40), GrayscaleAdjustment(),
+			},
+			Grayscale(Sepia(Posterize(AdjustFunc(AdjustHue(Solarize(Invert(AdjustSaturation(AdjustGamma(img, 0.8), -30)), 200), 90), swap), 4), 40)),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := AdjustColors(img, tc.adjustments...)
+			if !compareNRGBA(got, tc.want, 0) {
+				t.Fatal("the result differs from the adjustments applied one by one")
+			}
+		})
+	}
+}
+
+func BenchmarkAdjustColors(b *testing.B) {
+	adjustments := []ColorAdjustment{
+		GammaAdjustment(1.2),
+		BrightnessAdjustment(5),
+		ContrastAdjustment(10),
+		SigmoidAdjustment(0.5, 3),
+		SaturationAdjustment(20),
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		AdjustColors(testdataBranchesJPG, adjustments...)
+	}
+}
+
+func TestAdjustInPlace(t *testing.T) {
+	swap := func(c color.NRGBA) color.NRGBA { return color.NRGBA{c.B, c.R, c.G, c.A} }
+	testCases := []struct {
+		name    string
+		inPlace func(img *image.NRGBA)
+		want    func(img image.Image) *image.NRGBA
+	}{
+		{"Grayscale", GrayscaleInPlace, Grayscale},
+		{"Invert", InvertInPlace, Invert},
+		{
+			"Sepia",
+			func(img *image.NRGBA) { SepiaInPlace(img, 60) },
+			func(img image.Image) *image.NRGBA { return Sepia(img, 60) },
+		},
+		{
+			"AdjustSaturation",
+			func(img *image.NRGBA) { AdjustSaturationInPlace(img, -40) },
+			func(img image.Image) *image.NRGBA { return AdjustSaturation(img, -40) },
+		},
+		{
+			"AdjustHue",
+			func(img *image.NRGBA) { AdjustHueInPlace(img, 120) },
+			func(img image.Image) *image.NRGBA { return AdjustHue(img, 120) },
+		},
+		{
+			"AdjustContrast",
+			func(img *image.NRGBA) { AdjustContrastInPlace(img, 30) },
+			func(img image.Image) *image.NRGBA { return AdjustContrast(img, 30) },
+		},
+		{
+			"AdjustBrightness",
+			func(img *image.NRGBA) { AdjustBrightnessInPlace(img, -20) },
+			func(img image.Image) *image.NRGBA { return AdjustBrightness(img, -20) },
+		},
+		{
+			"AdjustGamma",
+			func(img *image.NRGBA) { AdjustGammaInPlace(img, 1.6) },
+			func(img image.Image) *image.NRGBA { return AdjustGamma(img, 1.6) },
+		},
+		{
+			"AdjustSigmoid",
+			func(img *image.NRGBA) { AdjustSigmoidInPlace(img, 0.5, -4) },
+			func(img image.Image) *image.NRGBA { return AdjustSigmoid(img, 0.5, -4) },
+		},
+		{
+			"Posterize",
+			func(img *image.NRGBA) { PosterizeInPlace(img, 3) },
+			func(img image.Image) *image.NRGBA { return Posterize(img, 3) },
+		},
+		{
+			"Solarize",
+			func(img *image.NRGBA) { SolarizeInPlace(img, 90) },
+			func(img image.Image) *image.NRGBA { return Solarize(img, 90) },
+		},
+		{
+			"AdjustFunc",
+			func(img *image.NRGBA) { AdjustFuncInPlace(img, swap) },
+			func(img image.Image) *image.NRGBA { return AdjustFunc(img, swap) },
+		},
+		{
+			"AdjustColors",
+			func(img *image.NRGBA) {
+				AdjustColorsInPlace(img, GammaAdjustment(1.2), ContrastAdjustment(15), SaturationAdjustment(25))
+			},
+			func(img image.Image) *image.NRGBA {
+				return AdjustColors(img, GammaAdjustment(1.2), ContrastAdjustment(15), SaturationAdjustment(25))
+			},
+		},
+		{
+			"no-op",
+			func(img *image.NRGBA) { AdjustBrightnessInPlace(img, 0) },
+			Clone,
+		},
+	}
+	r := image.Rect(30, 20, 170, 120)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			img := Clone(testdataFlowersSmallPNG)
+			tc.inPlace(img)
+			if !compareNRGBA(img, tc.want(testdataFlowersSmallPNG), 0) {
+				t.Fatal("the image differs from the adjusted copy")
+			}
+
+			// Only the pixels of a sub-image are changed.
+			img = Clone(testdataFlowersSmallPNG)
+			tc.inPlace(img.SubImage(r).(*image.NRGBA))
+			want := Clone(testdataFlowersSmallPNG)
+			draw.Draw(want, r, tc.want(want.SubImage(r)), image.Point{}, draw.Src)
+			if !compareNRGBA(img, want, 0) {
+				t.Fatal("the sub-image isn't adjusted in place")
+			}
+		})
+	}
+}
+
+func BenchmarkAdjustColorsInPlace(b *testing.B) {
+	img := Clone(testdataBranchesJPG)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		AdjustColorsInPlace(img, GammaAdjustment(1.2), ContrastAdjustment(10), SaturationAdjustment(20))
+	}
+}
+
+func TestAdjustOkLab(t *testing.T) {
+	img := testdataFlowersSmallPNG
+	oklab := InColorSpace(ColorSpaceOkLab)
+	lab := func(p []uint8) oklabColor {
+		return newOklabColor(float64(p[0])/255, float64(p[1])/255, float64(p[2])/255)
+	}
+	src := Clone(img)
+
+	testCases := []struct {
+		name  string
+		dst   *image.NRGBA
+		check func(s, d oklabColor) bool
+	}{
+		{
+			"AdjustSaturation -100",
+			AdjustSaturation(img, -100, oklab),
+			func(s, d oklabColor) bool {
+				return math.Abs(d.l-s.l) < 0.01 && math.Hypot(d.a, d.b) < 0.01
+			},
+		},
+		{
+			"AdjustSaturation 50",
+			AdjustSaturation(img, 50, oklab),
+			func(s, d oklabColor) bool {
+				return math.Abs(d.l-s.l) < 0.01 && math.Hypot(d.a, d.b) >= math.Hypot(s.a, s.b)-0.01
+			},
+		},
+		{
+			"AdjustHue 120",
+			AdjustHue(img, 120, oklab),
+			func(s, d oklabColor) bool {
+				if math.Hypot(s.a, s.b) < 0.05 || math.Hypot(d.a, d.b) < 0.05 {
+					return math.Abs(d.l-s.l) < 0.01
+				}
+				h := math.Atan2(d.b, d.a) - math.Atan2(s.b, s.a)
+				h = math.Mod(h+4*math.Pi, 2*math.Pi)
+				return math.Abs(d.l-s.l) < 0.01 && math.Abs(h-2*math.Pi/3) < 0.15
+			},
+		},
+		{
+			"AdjustBrightness 10",
+			AdjustBrightness(img, 10, oklab),
+			func(s, d oklabColor) bool {
+				return s.l > 0.85 || math.Abs(d.l-s.l-0.1) < 0.01
+			},
+		},
+		{
+			"AdjustContrast -100",
+			AdjustContrast(img, -100, oklab),
+			func(s, d oklabColor) bool {
+				return math.Abs(d.l-0.5) < 0.01
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for i := 0; i < len(src.Pix); i += 4 {
+				s, d := src.Pix[i:i+4], tc.dst.Pix[i:i+4]
+				if !tc.check(lab(s), lab(d)) {
+					t.Fatalf("got %v from %v: %+v from %+v", d, s, lab(d), lab(s))
+				}
+				if d[3] != s[3] {
+					t.Fatal("the alpha is changed")
+				}
+			}
+		})
+	}
+
+	if !compareNRGBA(AdjustSaturation(img, 30, InColorSpace(ColorSpaceRGB)), AdjustSaturation(img, 30), 0) {
+		t.Fatal("ColorSpaceRGB isn't the default")
+	}
+	want := AdjustHue(AdjustContrast(img, 20, oklab), 45, oklab)
+	if !compareNRGBA(AdjustColors(img, ContrastAdjustment(20, oklab), HueAdjustment(45, oklab)), want, 0) {
+		t.Fatal("AdjustColors differs")
+	}
+	if !compareNRGBA(NewPipeline().AdjustContrast(20, oklab).AdjustHue(45, oklab).Apply(img), want, 0) {
+		t.Fatal("Pipeline differs")
+	}
+}
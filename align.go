@@ -0,0 +1,454 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/cmplx"
+	"math/rand"
+)
+
+// AlignMode specifies the motion model used by Align.
+type AlignMode int
+
+// Image alignment modes.
+const (
+	// AlignTranslation estimates a shift between the frames using phase correlation.
+	// It's fast and robust for frames that differ by a translation only, e.g. a burst
+	// of shots from a steady hand.
+	AlignTranslation AlignMode = iota
+
+	// AlignSimilarity estimates a rotation, a uniform scale and a shift between the frames
+	// by matching corners. It handles slightly rotated handheld shots.
+	AlignSimilarity
+)
+
+// Align registers the images to the first one and returns them warped into its coordinates.
+// All the resulting images have the size of the first image, the areas not covered by a frame
+// after warping are transparent. The first image is returned unchanged (cloned).
+// The aligned frames can be stacked or merged.
+//
+// Example:
+//
+//	frames := imaging.Align([]image.Image{shot1, shot2, shot3}, imaging.AlignSimilarity)
+func Align(images []image.Image, mode AlignMode) []*image.NRGBA {
+	if len(images) == 0 {
+		return nil
+	}
+	ref := images[0]
+	b := ref.Bounds()
+	result := make([]*image.NRGBA, len(images))
+	result[0] = Clone(ref)
+	for i, img := range images[1:] {
+		t := estimateMotion(ref, img, mode)
+		result[i+1] = warpSimilarity(img, t, b.Dx(), b.Dy())
+	}
+	return result
+}
+
+// similarity is a similarity transform that maps the point (x, y)
+// to (a*x - b*y + tx, b*x + a*y + ty).
+type similarity struct {
+	a, b, tx, ty float64
+}
+
+var identitySimilarity = similarity{a: 1}
+
+func (s similarity) apply(x, y float64) (float64, float64) {
+	return s.a*x - s.b*y + s.tx, s.b*x + s.a*y + s.ty
+}
+
+func (s similarity) invert() similarity {
+	d := s.a*s.a + s.b*s.b
+	a, b := s.a/d, -s.b/d
+	return similarity{a: a, b: b, tx: -(a*s.tx - b*s.ty), ty: -(b*s.tx + a*s.ty)}
+}
+
+// estimateMotion returns the transform mapping the coordinates of ref to the coordinates of img.
+func estimateMotion(ref, img image.Image, mode AlignMode) similarity {
+	if mode == AlignSimilarity {
+		if t, ok := estimateSimilarity(ref, img); ok {
+			return t
+		}
+	}
+	dx, dy := phaseCorrelation(ref, img)
+	return similarity{a: 1, tx: dx, ty: dy}
+}
+
+// warpSimilarity returns a width x height image whose pixel (x, y) is the pixel t(x, y) of img.
+func warpSimilarity(img image.Image, t similarity, width, height int) *image.NRGBA {
+	src := toNRGBA(img)
+	dst := newNRGBA(image.Rect(0, 0, width, height))
+	parallel(0, height, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < width; x++ {
+				xf, yf := t.apply(float64(x), float64(y))
+				interpolatePoint(dst, x, y, src, xf, yf, color.NRGBA{})
+			}
+		}
+	})
+	return dst
+}
+
+// alignScale returns the factor to downscale an image to fit the size limit.
+func alignScale(img image.Image, maxSize int) float64 {
+	b := img.Bounds()
+	m := b.Dx()
+	if b.Dy() > m {
+		m = b.Dy()
+	}
+	if m <= maxSize {
+		return 1
+	}
+	return float64(maxSize) / float64(m)
+}
+
+// scaledLuminance returns the luminance of the image downscaled by the given factor.
+func scaledLuminance(img image.Image, scale float64) ([]float64, int, int) {
+	if scale < 1 {
+		b := img.Bounds()
+		w := int(math.Max(1, math.Round(float64(b.Dx())*scale)))
+		h := int(math.Max(1, math.Round(float64(b.Dy())*scale)))
+		img = Resize(img, w, h, Box)
+	}
+	return luminancePlane(img)
+}
+
+// phaseCorrelation estimates the shift (dx, dy) such that img(x+dx, y+dy) matches ref(x, y).
+func phaseCorrelation(ref, img image.Image) (float64, float64) {
+	const maxSize = 512
+
+	scale := alignScale(ref, maxSize)
+	if s := alignScale(img, maxSize); s < scale {
+		scale = s
+	}
+	lum1, w1, h1 := scaledLuminance(ref, scale)
+	lum2, w2, h2 := scaledLuminance(img, scale)
+	if w1 == 0 || h1 == 0 || w2 == 0 || h2 == 0 {
+		return 0, 0
+	}
+
+	n := 1
+	for n < w1 || n < h1 || n < w2 || n < h2 {
+		n *= 2
+	}
+	f1 := windowedSpectrum(lum1, w1, h1, n)
+	f2 := windowedSpectrum(lum2, w2, h2, n)
+
+	// The normalized cross-power spectrum has a peak at the shift.
+	for i := range f1 {
+		c := cmplx.Conj(f1[i]) * f2[i]
+		if a := cmplx.Abs(c); a > 1e-12 {
+			f1[i] = c / complex(a, 0)
+		} else {
+			f1[i] = 0
+		}
+	}
+	fft2D(f1, n, true)
+
+	best := 0
+	for i := range f1 {
+		if real(f1[i]) > real(f1[best]) {
+			best = i
+		}
+	}
+	px, py := best%n, best/n
+
+	// Refine the peak position to subpixel precision with a parabola fit.
+	at := func(x, y int) float64 { return real(f1[((y+n)%n)*n+(x+n)%n]) }
+	sub := func(l, c, r float64) float64 {
+		d := l - 2*c + r
+		if d >= 0 {
+			return 0
+		}
+		return 0.5 * (l - r) / d
+	}
+	dx := float64(px) + sub(at(px-1, py), at(px, py), at(px+1, py))
+	dy := float64(py) + sub(at(px, py-1), at(px, py), at(px, py+1))
+	if dx > float64(n)/2 {
+		dx -= float64(n)
+	}
+	if dy > float64(n)/2 {
+		dy -= float64(n)
+	}
+	return dx / scale, dy / scale
+}
+
+// windowedSpectrum returns the 2D Fourier transform of the luminance, with the mean removed
+// and a Hann window applied to suppress the edges, zero-padded to n x n.
+func windowedSpectrum(lum []float64, w, h, n int) []complex128 {
+	var mean float64
+	for _, v := range lum {
+		mean += v
+	}
+	mean /= float64(len(lum))
+
+	data := make([]complex128, n*n)
+	for y := 0; y < h; y++ {
+		wy := 0.5 - 0.5*math.Cos(2*math.Pi*(float64(y)+0.5)/float64(h))
+		for x := 0; x < w; x++ {
+			wx := 0.5 - 0.5*math.Cos(2*math.Pi*(float64(x)+0.5)/float64(w))
+			data[y*n+x] = complex((lum[y*w+x]-mean)*wx*wy, 0)
+		}
+	}
+	fft2D(data, n, false)
+	return data
+}
+
+// fft2D computes the 2D discrete Fourier transform of the n x n data in place.
+// n must be a power of two.
+func fft2D(data []complex128, n int, inverse bool) {
+	parallel(0, n, func(ys <-chan int) {
+		for y := range ys {
+			fft(data[y*n:(y+1)*n], inverse)
+		}
+	})
+	parallel(0, n, func(xs <-chan int) {
+		col := make([]complex128, n)
+		for x := range xs {
+			for y := 0; y < n; y++ {
+				col[y] = data[y*n+x]
+			}
+			fft(col, inverse)
+			for y := 0; y < n; y++ {
+				data[y*n+x] = col[y]
+			}
+		}
+	})
+}
+
+// fft computes the discrete Fourier transform of the data in place using the iterative
+// radix-2 algorithm. The length of the data must be a power of two.
+// The inverse transform isn't normalized.
+func fft(data []complex128, inverse bool) {
+	n := len(data)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			data[i], data[j] = data[j], data[i]
+		}
+	}
+	sign := -1.0
+	if inverse {
+		sign = 1
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Rect(1, sign*2*math.Pi/float64(size))
+		for start := 0; start < n; start += size {
+			wk := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				u := data[start+k]
+				v := data[start+k+size/2] * wk
+				data[start+k] = u + v
+				data[start+k+size/2] = u - v
+				wk *= step
+			}
+		}
+	}
+}
+
+// featureMatch is a pair of corresponding points in two images.
+type featureMatch struct {
+	x1, y1, x2, y2 float64
+}
+
+// estimateSimilarity estimates the similarity transform mapping the coordinates of ref
+// to the coordinates of img by matching corners. It reports false if there are too few matches.
+func estimateSimilarity(ref, img image.Image) (similarity, bool) {
+	const maxSize = 1024
+
+	scale := alignScale(ref, maxSize)
+	if s := alignScale(img, maxSize); s < scale {
+		scale = s
+	}
+	matches := matchFeatures(ref, img, scale)
+	t, inliers := ransacSimilarity(matches, 3)
+	if inliers < 6 {
+		return similarity{}, false
+	}
+	// Undo the downscaling of the coordinates.
+	t.tx /= scale
+	t.ty /= scale
+	return t, true
+}
+
+// matchFeatures detects corners in both images downscaled by the given factor
+// and returns the mutually best matching pairs of them (in the downscaled coordinates).
+func matchFeatures(img1, img2 image.Image, scale float64) []featureMatch {
+	const (
+		maxCorners = 500
+		radius     = 4
+	)
+	desc1, kps1 := describeFeatures(img1, scale, maxCorners, radius)
+	desc2, kps2 := describeFeatures(img2, scale, maxCorners, radius)
+	if len(kps1) == 0 || len(kps2) == 0 {
+		return nil
+	}
+
+	best := func(d []float64, others [][]float64) (int, float64, float64) {
+		idx, s1, s2 := -1, -2.0, -2.0
+		for j, o := range others {
+			var s float64
+			for k := range d {
+				s += d[k] * o[k]
+			}
+			if s > s1 {
+				idx, s1, s2 = j, s, s1
+			} else if s > s2 {
+				s2 = s
+			}
+		}
+		return idx, s1, s2
+	}
+
+	var matches []featureMatch
+	for i, d := range desc1 {
+		j, s1, s2 := best(d, desc2)
+		// Require a good and unambiguous match: the distance of the descriptors
+		// (sqrt(2 - 2s) for unit vectors) must be clearly smaller than the second best.
+		if j < 0 || s1 < 0.7 || 2-2*s1 > 0.64*(2-2*s2) {
+			continue
+		}
+		if back, _, _ := best(desc2[j], desc1); back != i {
+			continue
+		}
+		matches = append(matches, featureMatch{
+			x1: float64(kps1[i].X), y1: float64(kps1[i].Y),
+			x2: float64(kps2[j].X), y2: float64(kps2[j].Y),
+		})
+	}
+	return matches
+}
+
+// describeFeatures detects corners and describes each of them with the normalized luminance
+// of the surrounding (2*radius+1) x (2*radius+1) patch.
+func describeFeatures(img image.Image, scale float64, maxCorners, radius int) ([][]float64, []Keypoint) {
+	if scale < 1 {
+		b := img.Bounds()
+		img = Resize(img, int(math.Max(1, math.Round(float64(b.Dx())*scale))), int(math.Max(1, math.Round(float64(b.Dy())*scale))), Box)
+	}
+	smooth := Blur(img, 1)
+	lum, w, h := luminancePlane(smooth)
+
+	var descs [][]float64
+	var kps []Keypoint
+	for _, kp := range HarrisCorners(smooth, maxCorners) {
+		if kp.X < radius || kp.Y < radius || kp.X >= w-radius || kp.Y >= h-radius {
+			continue
+		}
+		d := make([]float64, 0, (2*radius+1)*(2*radius+1))
+		var mean float64
+		for y := kp.Y - radius; y <= kp.Y+radius; y++ {
+			for x := kp.X - radius; x <= kp.X+radius; x++ {
+				d = append(d, lum[y*w+x])
+				mean += lum[y*w+x]
+			}
+		}
+		mean /= float64(len(d))
+		var norm float64
+		for k := range d {
+			d[k] -= mean
+			norm += d[k] * d[k]
+		}
+		if norm < 1e-6 {
+			continue
+		}
+		norm = math.Sqrt(norm)
+		for k := range d {
+			d[k] /= norm
+		}
+		descs = append(descs, d)
+		kps = append(kps, kp)
+	}
+	return descs, kps
+}
+
+// ransacSimilarity robustly fits a similarity transform to the matches using RANSAC.
+// It returns the transform refined on the inliers (matches with an error of at most
+// tolerance pixels) and the number of inliers.
+func ransacSimilarity(matches []featureMatch, tolerance float64) (similarity, int) {
+	const iterations = 1000
+	if len(matches) < 2 {
+		return identitySimilarity, 0
+	}
+
+	// A fixed seed makes the results reproducible.
+	rnd := rand.New(rand.NewSource(1))
+	countInliers := func(t similarity, keep []bool) int {
+		n := 0
+		for i, m := range matches {
+			x, y := t.apply(m.x1, m.y1)
+			ok := math.Hypot(x-m.x2, y-m.y2) <= tolerance
+			if keep != nil {
+				keep[i] = ok
+			}
+			if ok {
+				n++
+			}
+		}
+		return n
+	}
+
+	best, bestCount := identitySimilarity, -1
+	for it := 0; it < iterations; it++ {
+		i, j := rnd.Intn(len(matches)), rnd.Intn(len(matches))
+		if i == j {
+			continue
+		}
+		t, ok := fitSimilarity([]featureMatch{matches[i], matches[j]})
+		if !ok {
+			continue
+		}
+		if n := countInliers(t, nil); n > bestCount {
+			best, bestCount = t, n
+		}
+	}
+	if bestCount < 2 {
+		return identitySimilarity, 0
+	}
+
+	keep := make([]bool, len(matches))
+	countInliers(best, keep)
+	var inliers []featureMatch
+	for i, m := range matches {
+		if keep[i] {
+			inliers = append(inliers, m)
+		}
+	}
+	if t, ok := fitSimilarity(inliers); ok {
+		best = t
+	}
+	return best, countInliers(best, nil)
+}
+
+// fitSimilarity returns the least squares similarity transform mapping
+// the first points of the matches to the second ones.
+func fitSimilarity(matches []featureMatch) (similarity, bool) {
+	// With the points as complex numbers the transform is z2 = m*z1 + t.
+	var c1, c2 complex128
+	for _, m := range matches {
+		c1 += complex(m.x1, m.y1)
+		c2 += complex(m.x2, m.y2)
+	}
+	n := complex(float64(len(matches)), 0)
+	c1 /= n
+	c2 /= n
+	var num complex128
+	var den float64
+	for _, m := range matches {
+		z1 := complex(m.x1, m.y1) - c1
+		z2 := complex(m.x2, m.y2) - c2
+		num += cmplx.Conj(z1) * z2
+		den += real(z1)*real(z1) + imag(z1)*imag(z1)
+	}
+	if den < 1e-9 {
+		return similarity{}, false
+	}
+	mul := num / complex(den, 0)
+	t := c2 - mul*c1
+	return similarity{a: real(mul), b: imag(mul), tx: real(t), ty: imag(t)}, true
+}
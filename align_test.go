@@ -0,0 +1,141 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/cmplx"
+	"testing"
+)
+
+// meanDiffInside returns the mean absolute difference of the color channels of the pixels
+// in the rectangle r that are opaque in both images.
+func meanDiffInside(img1, img2 *image.NRGBA, r image.Rectangle) float64 {
+	var sum float64
+	var n int
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c1, c2 := img1.NRGBAAt(x, y), img2.NRGBAAt(x, y)
+			if c1.A != 0xff || c2.A != 0xff {
+				continue
+			}
+			sum += math.Abs(float64(c1.R)-float64(c2.R)) + math.Abs(float64(c1.G)-float64(c2.G)) + math.Abs(float64(c1.B)-float64(c2.B))
+			n += 3
+		}
+	}
+	if n == 0 {
+		return math.Inf(1)
+	}
+	return sum / float64(n)
+}
+
+func TestAlignTranslation(t *testing.T) {
+	base := testdataBranchesPNG
+	ref := Crop(base, image.Rect(20, 20, 420, 320))
+	moved := Crop(base, image.Rect(27, 14, 427, 314))
+
+	dx, dy := phaseCorrelation(ref, moved)
+	if math.Abs(dx+7) > 0.5 || math.Abs(dy-6) > 0.5 {
+		t.Fatalf("got shift (%.2f, %.2f) want (-7, 6)", dx, dy)
+	}
+
+	for _, mode := range []AlignMode{AlignTranslation, AlignSimilarity} {
+		got := Align([]image.Image{ref, moved}, mode)
+		if len(got) != 2 {
+			t.Fatalf("mode %d: got %d images want 2", mode, len(got))
+		}
+		if !compareNRGBA(got[0], ref, 0) {
+			t.Fatalf("mode %d: the reference image is changed", mode)
+		}
+		if got[1].Bounds() != ref.Bounds() {
+			t.Fatalf("mode %d: got bounds %v want %v", mode, got[1].Bounds(), ref.Bounds())
+		}
+		if d := meanDiffInside(got[1], ref, image.Rect(10, 10, 390, 290)); d > 2 {
+			t.Fatalf("mode %d: mean difference %.2f after alignment", mode, d)
+		}
+		// The uncovered area is transparent.
+		if c := got[1].NRGBAAt(2, 150); c.A != 0 {
+			t.Fatalf("mode %d: got uncovered pixel %v want transparent", mode, c)
+		}
+	}
+}
+
+func TestAlignSimilarity(t *testing.T) {
+	base := testdataBranchesPNG
+	ref := Crop(base, image.Rect(100, 50, 500, 350))
+	rotated := Rotate(base, 4, color.Transparent)
+	b := rotated.Bounds()
+	cx, cy := b.Dx()/2, b.Dy()/2
+	moved := Crop(rotated, image.Rect(cx-195, cy-155, cx+205, cy+145))
+
+	// Both rotations interpolate the fine details, the difference of a half pixel shift is 14.
+	got := Align([]image.Image{ref, moved}, AlignSimilarity)
+	if d := meanDiffInside(got[1], ref, image.Rect(30, 30, 370, 270)); d > 10 {
+		t.Fatalf("mean difference %.2f after alignment", d)
+	}
+	before := meanDiffInside(Clone(moved), ref, image.Rect(30, 30, 370, 270))
+	after := meanDiffInside(Align([]image.Image{ref, moved}, AlignTranslation)[1], ref, image.Rect(30, 30, 370, 270))
+	if d := meanDiffInside(got[1], ref, image.Rect(30, 30, 370, 270)); d >= after || d >= before {
+		t.Fatalf("similarity alignment error %.2f, translation %.2f, unaligned %.2f", d, after, before)
+	}
+}
+
+func TestAlignEmpty(t *testing.T) {
+	if got := Align(nil, AlignTranslation); got != nil {
+		t.Fatalf("got %v want nil", got)
+	}
+	flat := New(50, 40, color.Gray{0x80})
+	got := Align([]image.Image{flat, flat}, AlignSimilarity)
+	if !compareNRGBA(got[1], flat, 0) {
+		t.Fatal("flat images are misaligned")
+	}
+}
+
+func TestFFT(t *testing.T) {
+	data := []complex128{1, 2, 3, 4, 0, -1, 2, 5}
+	want := make([]complex128, len(data))
+	for k := range want {
+		for n, v := range data {
+			want[k] += v * cmplx.Rect(1, -2*math.Pi*float64(k*n)/float64(len(data)))
+		}
+	}
+	got := append([]complex128(nil), data...)
+	fft(got, false)
+	for k := range want {
+		if cmplx.Abs(got[k]-want[k]) > 1e-9 {
+			t.Fatalf("got %v want %v", got, want)
+		}
+	}
+	fft(got, true)
+	for k := range data {
+		if cmplx.Abs(got[k]/complex(float64(len(data)), 0)-data[k]) > 1e-9 {
+			t.Fatalf("inverse: got %v want %v", got, data)
+		}
+	}
+}
+
+func TestFitSimilarity(t *testing.T) {
+	want := similarity{a: 0.9, b: 0.2, tx: 5, ty: -3}
+	var matches []featureMatch
+	for _, p := range [][2]float64{{0, 0}, {10, 3}, {-4, 8}, {20, 20}} {
+		x, y := want.apply(p[0], p[1])
+		matches = append(matches, featureMatch{p[0], p[1], x, y})
+	}
+	// An outlier.
+	matches = append(matches, featureMatch{5, 5, 100, 100})
+
+	got, inliers := ransacSimilarity(matches, 1)
+	if inliers != 4 {
+		t.Fatalf("got %d inliers want 4", inliers)
+	}
+	if math.Abs(got.a-want.a) > 1e-6 || math.Abs(got.b-want.b) > 1e-6 || math.Abs(got.tx-want.tx) > 1e-6 || math.Abs(got.ty-want.ty) > 1e-6 {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+	x, y := want.invert().apply(want.apply(7, -2))
+	if math.Abs(x-7) > 1e-9 || math.Abs(y+2) > 1e-9 {
+		t.Fatalf("got (%v, %v) want (7, -2) after the inverse transform", x, y)
+	}
+	if _, ok := fitSimilarity([]featureMatch{{1, 1, 2, 2}, {1, 1, 3, 3}}); ok {
+		t.Fatal("fit of coincident points succeeded")
+	}
+}
@@ -0,0 +1,114 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// ExtractAlpha returns the alpha channel of the image as a grayscale image, opaque pixels
+// are white and transparent ones black. It's the mask of SetAlpha and ApplyMask.
+//
+// Example:
+//
+//	// Feather the edges of a cutout.
+//	alpha := imaging.ExtractAlpha(cutout)
+//	dstImage := imaging.SetAlpha(cutout, imaging.Blur(alpha, 2))
+func ExtractAlpha(img image.Image) *image.Gray {
+	src := newScanner(img)
+	dst := image.NewGray(image.Rect(0, 0, src.w, src.h))
+	parallel(0, src.h, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			d := dst.Pix[y*dst.Stride : y*dst.Stride+src.w]
+			for x := range d {
+				d[x] = scanLine[x*4+3]
+			}
+		}
+	})
+	return dst
+}
+
+// SetAlpha replaces the alpha channel of the image with the luminance of the grayscale mask,
+// e.g. an alpha channel returned by ExtractAlpha, and returns the resulting image. Unlike
+// ApplyMask, the transparent pixels of the image become visible where the mask is white.
+// The mask is aligned with the top-left corner of the image, the pixels outside of it
+// become transparent. The colors of the image are kept.
+//
+// Example:
+//
+//	dstImage := imaging.SetAlpha(srcImage, imaging.ExtractAlpha(maskImage))
+func SetAlpha(img, mask image.Image) *image.NRGBA {
+	return applyAlphaMask(img, mask, func(a, m float64) uint8 {
+		return clamp(m)
+	})
+}
+
+// AdjustOpacity multiplies the alpha channel of the image by the factor and returns
+// the adjusted image. The factor of 0.5 makes the image half as opaque, the factors above 1
+// make the translucent pixels more opaque. The negative factor is treated as 0.
+//
+// Example:
+//
+//	faded := imaging.AdjustOpacity(logo, 0.4)
+//	dstImage := imaging.Overlay(srcImage, faded, image.Pt(20, 20), 1)
+func AdjustOpacity(img image.Image, factor float64) *image.NRGBA {
+	factor = math.Max(factor, 0)
+	dst := Clone(img)
+	if factor == 1 {
+		return dst
+	}
+	w, h := dst.Rect.Dx(), dst.Rect.Dy()
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				d := &dst.Pix[y*dst.Stride+x*4+3]
+				*d = clamp(float64(*d) * factor)
+			}
+		}
+	})
+	return dst
+}
+
+// PremultiplyAlpha returns the image with the color channels multiplied by the alpha,
+// the representation of the image.RGBA used by the image/draw package and most
+// compositing and blending formulas. The conversion is the one of color.RGBAModel.
+//
+// Example:
+//
+//	premul := imaging.PremultiplyAlpha(srcImage)
+//	draw.Draw(premul, rect, overlay, image.Point{}, draw.Over)
+//	dstImage := imaging.UnpremultiplyAlpha(premul)
+func PremultiplyAlpha(img image.Image) *image.RGBA {
+	src := newScanner(img)
+	dst := image.NewRGBA(image.Rect(0, 0, src.w, src.h))
+	parallel(0, src.h, func(ys <-chan int) {
+		for y := range ys {
+			i := y * dst.Stride
+			row := dst.Pix[i : i+src.w*4]
+			src.scan(0, y, src.w, y+1, row)
+			for x := 0; x < src.w; x++ {
+				d := row[x*4 : x*4+4 : x*4+4]
+				if a := uint32(d[3]); a != 0xff {
+					// The 16-bit arithmetic of color.NRGBA.RGBA.
+					a |= a << 8
+					d[0] = uint8((uint32(d[0]) * 0x101 * a / 0xffff) >> 8)
+					d[1] = uint8((uint32(d[1]) * 0x101 * a / 0xffff) >> 8)
+					d[2] = uint8((uint32(d[2]) * 0x101 * a / 0xffff) >> 8)
+				}
+			}
+		}
+	})
+	return dst
+}
+
+// UnpremultiplyAlpha returns the image with the premultiplied color channels divided by
+// the alpha, the inverse of PremultiplyAlpha. The colors of the transparent pixels are lost
+// by the premultiplication, they become black.
+//
+// Example:
+//
+//	dstImage := imaging.UnpremultiplyAlpha(premul)
+func UnpremultiplyAlpha(img *image.RGBA) *image.NRGBA {
+	return Clone(img)
+}
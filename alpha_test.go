@@ -0,0 +1,87 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestExtractSetAlpha(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 0),
+		Stride: 3 * 4,
+		Pix:    []uint8{0x10, 0x20, 0x30, 0xff, 0x40, 0x50, 0x60, 0x80, 0x70, 0x80, 0x90, 0x00},
+	}
+	alpha := ExtractAlpha(src)
+	if want := []uint8{0xff, 0x80, 0x00}; alpha.Rect != image.Rect(0, 0, 3, 1) || string(alpha.Pix) != string(want) {
+		t.Fatalf("got alpha %v %v want %v", alpha.Rect, alpha.Pix, want)
+	}
+	if got := SetAlpha(src, alpha); !compareNRGBA(got, Clone(src), 0) {
+		t.Fatalf("SetAlpha with the extracted alpha changed the image: %#v", got)
+	}
+
+	// The transparent pixels become visible, the pixels outside the mask transparent.
+	mask := image.NewGray(image.Rect(4, 4, 6, 5))
+	mask.Pix = []uint8{0x40, 0xff}
+	got := SetAlpha(src, mask)
+	want := []uint8{0x10, 0x20, 0x30, 0x40, 0x40, 0x50, 0x60, 0xff, 0x70, 0x80, 0x90, 0x00}
+	if string(got.Pix) != string(want) {
+		t.Fatalf("got %v want %v", got.Pix, want)
+	}
+}
+
+func TestAdjustOpacity(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 3, 1),
+		Stride: 3 * 4,
+		Pix:    []uint8{0x10, 0x20, 0x30, 0xff, 0x40, 0x50, 0x60, 0x80, 0x70, 0x80, 0x90, 0x00},
+	}
+	testCases := []struct {
+		factor float64
+		want   []uint8
+	}{
+		{1, []uint8{0xff, 0x80, 0x00}},
+		{0.5, []uint8{0x80, 0x40, 0x00}},
+		{1.5, []uint8{0xff, 0xc0, 0x00}},
+		{0, []uint8{0x00, 0x00, 0x00}},
+		{-1, []uint8{0x00, 0x00, 0x00}},
+	}
+	for _, tc := range testCases {
+		got := AdjustOpacity(src, tc.factor)
+		for i, a := range tc.want {
+			if got.Pix[i*4+3] != a || got.Pix[i*4] != src.Pix[i*4] {
+				t.Fatalf("AdjustOpacity(%v): got %v want alpha %v", tc.factor, got.Pix, tc.want)
+			}
+		}
+	}
+}
+
+func TestPremultiplyAlpha(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(2, 3, 258, 4))
+	for x := 0; x < 256; x++ {
+		src.SetNRGBA(2+x, 3, color.NRGBA{200, uint8(x), 0xff, uint8(x)})
+	}
+	got := PremultiplyAlpha(src)
+	if got.Rect != image.Rect(0, 0, 256, 1) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	for x := 0; x < 256; x++ {
+		c := src.NRGBAAt(2+x, 3)
+		if want := color.RGBAModel.Convert(c).(color.RGBA); got.RGBAAt(x, 0) != want {
+			t.Fatalf("got %v for %v want %v", got.RGBAAt(x, 0), c, want)
+		}
+	}
+
+	// The colors of the opaque pixels survive the round trip, the translucent ones lose
+	// the precision of their alpha.
+	back := UnpremultiplyAlpha(got)
+	if back.NRGBAAt(255, 0) != src.NRGBAAt(257, 3) {
+		t.Fatalf("got opaque %v", back.NRGBAAt(255, 0))
+	}
+	for x := 64; x < 256; x++ {
+		c, want := back.NRGBAAt(x, 0), src.NRGBAAt(2+x, 3)
+		if c.A != want.A || absint(int(c.R)-int(want.R)) > 4 || absint(int(c.B)-int(want.B)) > 4 {
+			t.Fatalf("got %v want %v", c, want)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package imaging
+
+import (
+	"bufio"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"time"
+)
+
+var (
+	errNoFrames          = errors.New("imaging: animation has no frames")
+	errAnimationTooLarge = errors.New("imaging: animation has too many frames to decode")
+)
+
+// animationMaxPixels is the maximum number of the pixels of all the frames DecodeAnimation
+// composites, 1 GiB of NRGBA frames. A small GIF file can hold thousands of frames of
+// the full size that only change a few pixels each.
+var animationMaxPixels int64 = 1 << 28
+
+// Animation is a sequence of frames displayed one after another, e.g. an animated GIF.
+type Animation struct {
+	// Frames are the images of the animation. Each frame replaces the previous one.
+	// The first frame sets the size of the animation, the other frames are aligned
+	// with its top-left corner.
+	Frames []image.Image
+
+	// Delays are the display durations of the frames.
+	Delays []time.Duration
+
+	// LoopCount controls the number of times the animation is played: 0 loops forever,
+	// -1 plays it once and n plays it n+1 times.
+	LoopCount int
+}
+
+// DecodeAnimation reads an animation from r. The frames of animated GIF images are composited
+// into full frames, other images are returned as animations of a single frame. The decode
+// options are applied to every frame, MaxPixels and MaxDimensions are checked on the header
+// before decoding the frames. The animations of more than 2^28 pixels in all the frames
+// are not decoded.
+//
+// Example:
+//
+//	anim, err := imaging.DecodeAnimation(file, imaging.MaxPixels(4096*4096))
+func DecodeAnimation(r io.Reader, opts ...DecodeOption) (*Animation, error) {
+	cfg := defaultDecodeConfig
+	for _, option := range opts {
+		option(&cfg)
+	}
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(4); err != nil || string(magic) != "GIF8" {
+		img, err := Decode(br, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &Animation{Frames: []image.Image{img}, Delays: []time.Duration{0}}, nil
+	}
+
+	br, err := checkDecodeLimits(br, cfg)
+	if err != nil {
+		return nil, err
+	}
+	g, err := gif.DecodeAll(br)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(g.Image))*int64(g.Config.Width)*int64(g.Config.Height) > animationMaxPixels {
+		return nil, errAnimationTooLarge
+	}
+	anim := &Animation{LoopCount: g.LoopCount}
+	canvas := newNRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	for i, pm := range g.Image {
+		var previous *image.NRGBA
+		if g.Disposal[i] == gif.DisposalPrevious {
+			previous = Clone(canvas)
+		}
+		draw.Draw(canvas, pm.Rect, pm, pm.Rect.Min, draw.Over)
+		anim.Frames = append(anim.Frames, Clone(canvas))
+		anim.Delays = append(anim.Delays, time.Duration(g.Delay[i])*10*time.Millisecond)
+
+		switch g.Disposal[i] {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, pm.Rect, image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return anim, nil
+}
+
+// OpenAnimation loads an animation from file, see DecodeAnimation.
+//
+// Example:
+//
+//	anim, err := imaging.OpenAnimation("in.gif")
+func OpenAnimation(filename string, opts ...DecodeOption) (*Animation, error) {
+	file, err := fs.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return DecodeAnimation(file, opts...)
+}
+
+// ConvertAnimation reads an animation from r and writes it to w in the specified format,
+// see DecodeAnimation and EncodeAnimation, e.g. to convert an animated GIF to a WebP
+// animation, which keeps the full colors of the frames and is usually smaller. AVIF output
+// is not supported and returns ErrUnsupportedFormat.
+//
+// Example:
+//
+//	err := imaging.ConvertAnimation(dst, src, imaging.WEBP, imaging.AnimationDeduplicate(0))
+func ConvertAnimation(w io.Writer, r io.Reader, format Format, opts ...EncodeOption) error {
+	anim, err := DecodeAnimation(r)
+	if err != nil {
+		return err
+	}
+	return EncodeAnimation(w, anim, format, opts...)
+}
+
+// AnimationPaletteMode specifies how the palettes of the animation frames are built.
+type AnimationPaletteMode int
+
+// Animation palette modes.
+const (
+	// AnimationPalettePerFrame builds an optimized palette for every frame. This is the default.
+	AnimationPalettePerFrame AnimationPaletteMode = iota
+
+	// AnimationPaletteGlobal builds one palette from the colors of all the frames. The frames
+	// don't store their own palettes, which makes mostly static animations like screen
+	// recordings much smaller, but animations with changing colors look worse.
+	AnimationPaletteGlobal
+)
+
+// AnimationPalette returns an EncodeOption that sets how the palettes of the GIF animation
+// frames are built. Default is AnimationPalettePerFrame.
+func AnimationPalette(mode AnimationPaletteMode) EncodeOption {
+	return func(c *encodeConfig) {
+		c.animationPalette = mode
+	}
+}
+
+// AnimationQuality returns an EncodeOption that sets the quality of the animation frames,
+// from 1 to 100 inclusive, higher is better. The last quality applies to the rest of the frames,
+// e.g. AnimationQuality(100, 80) keeps the first frame lossless and sets 80 for the others.
+// GIF frames only store the pixels that changed since the previous frame, the quality sets
+// how much a pixel may differ from the displayed one to be kept instead: 100 keeps only the
+// identical pixels, lower qualities give smaller files. Default is 100.
+func AnimationQuality(qualities ...int) EncodeOption {
+	return func(c *encodeConfig) {
+		c.animationQuality = qualities
+	}
+}
+
+// AnimationDeduplicate returns an EncodeOption that merges consecutive frames that differ from
+// the last kept frame by at most tolerance in every color channel (from 0 to 255): such frames
+// are dropped and their delays are added to the kept frame. It shrinks screen recordings with
+// long static periods. Tolerance 0 merges identical frames only, a negative tolerance disables
+// merging, which is the default.
+func AnimationDeduplicate(tolerance int) EncodeOption {
+	return func(c *encodeConfig) {
+		c.animationDedup = tolerance
+	}
+}
+
+// EncodeAnimation writes the animation to w in the specified format, GIF or WEBP. The GIF
+// animations support the GIFNumColors, GIFQuantizer, GIFDrawer, AnimationPalette,
+// AnimationQuality and AnimationDeduplicate encode options. The WebP animations are lossless,
+// in full color with the alpha channel, and support the AnimationDeduplicate option.
+//
+// Example:
+//
+//	anim := &imaging.Animation{Frames: frames, Delays: delays}
+//	err := imaging.EncodeAnimation(file, anim, imaging.GIF, imaging.AnimationPalette(imaging.AnimationPaletteGlobal))
+func EncodeAnimation(w io.Writer, anim *Animation, format Format, opts ...EncodeOption) error {
+	cfg := defaultEncodeConfig
+	for _, option := range opts {
+		option(&cfg)
+	}
+	if len(anim.Frames) == 0 {
+		return errNoFrames
+	}
+	switch format {
+	case GIF:
+		return encodeAnimatedGIF(cfg.writer(w), anim, cfg)
+	case WEBP:
+		return encodeAnimatedWebP(cfg.writer(w), anim, cfg)
+	}
+	return ErrUnsupportedFormat
+}
+
+// SaveAnimation saves the animation to file with the specified filename.
+// The format is determined from the filename extension, see EncodeAnimation.
+//
+// Example:
+//
+//	err := imaging.SaveAnimation(anim, "out.gif", imaging.AnimationQuality(90))
+func SaveAnimation(anim *Animation, filename string, opts ...EncodeOption) (err error) {
+	f, err := FormatFromFilename(filename)
+	if err != nil {
+		return err
+	}
+	file, err := fs.Create(filename)
+	if err != nil {
+		return err
+	}
+	err = EncodeAnimation(file, anim, f, opts...)
+	errc := file.Close()
+	if err == nil {
+		err = errc
+	}
+	return err
+}
+
+// frameQuality returns the quality of the i-th frame.
+func (c *encodeConfig) frameQuality(i int) int {
+	if len(c.animationQuality) == 0 {
+		return 100
+	}
+	q := c.animationQuality[minint(i, len(c.animationQuality)-1)]
+	return minint(maxint(q, 1), 100)
+}
+
+// animationFrames returns the frames of the animation with the size of the first frame
+// and their delays.
+func animationFrames(anim *Animation) ([]*image.NRGBA, []time.Duration) {
+	size := anim.Frames[0].Bounds().Size()
+	frames := make([]*image.NRGBA, len(anim.Frames))
+	delays := make([]time.Duration, len(anim.Frames))
+	copy(delays, anim.Delays)
+	for i, f := range anim.Frames {
+		if f.Bounds().Size() == size {
+			frames[i] = Clone(f)
+			continue
+		}
+		frames[i] = Paste(newNRGBA(image.Rect(0, 0, size.X, size.Y)), f, image.Pt(0, 0))
+	}
+	return frames, delays
+}
+
+// deduplicateFrames drops the frames that differ from the last kept frame by at most
+// the tolerance and adds their delays to it.
+func deduplicateFrames(frames []*image.NRGBA, delays []time.Duration, tolerance int) ([]*image.NRGBA, []time.Duration) {
+	keptFrames := frames[:1]
+	keptDelays := delays[:1]
+	for i := 1; i < len(frames); i++ {
+		last := len(keptFrames) - 1
+		if framesEqual(frames[i], keptFrames[last], tolerance) {
+			keptDelays[last] += delays[i]
+			continue
+		}
+		keptFrames = append(keptFrames, frames[i])
+		keptDelays = append(keptDelays, delays[i])
+	}
+	return keptFrames, keptDelays
+}
+
+// framesEqual reports whether the frames of the same size differ by at most the tolerance
+// in every channel.
+func framesEqual(a, b *image.NRGBA, tolerance int) bool {
+	for i := range a.Pix {
+		if absint(int(a.Pix[i])-int(b.Pix[i])) > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeAnimatedGIF writes the animation to w as an animated GIF. If all the frames are opaque,
+// every frame but the first one only stores the bounding box of the pixels that changed since
+// the previous frame, the other pixels are transparent and keep the previous frame colors.
+// Otherwise the frames are stored whole and the canvas is cleared between them.
+func encodeAnimatedGIF(w io.Writer, anim *Animation, cfg encodeConfig) error {
+	frames, delays := animationFrames(anim)
+	if cfg.animationDedup >= 0 {
+		frames, delays = deduplicateFrames(frames, delays, cfg.animationDedup)
+	}
+	opaque := true
+	for _, f := range frames {
+		opaque = opaque && f.Opaque()
+	}
+
+	numColors := cfg.gifNumColors
+	if numColors < 1 || numColors > 256 {
+		numColors = 256
+	}
+	method := QuantizeMedianCut
+	var quantizer draw.Quantizer = method
+	if cfg.gifQuantizer != nil {
+		quantizer = cfg.gifQuantizer
+		if m, ok := quantizer.(QuantizeMethod); ok {
+			method = m
+		}
+	}
+	var drawer draw.Drawer = draw.FloydSteinberg
+	if cfg.gifDrawer != nil {
+		drawer = cfg.gifDrawer
+	}
+
+	var global color.Palette
+	if cfg.animationPalette == AnimationPaletteGlobal {
+		var hist []colorCount
+		transparent := opaque && len(frames) > 1
+		for _, f := range frames {
+			h, t := colorHistogram(f)
+			hist = mergeHistograms(hist, h)
+			transparent = transparent || t
+		}
+		global = method.quantizeHistogram(make(color.Palette, 0, numColors), hist, transparent)
+	}
+
+	size := frames[0].Rect.Size()
+	out := &gif.GIF{
+		LoopCount: anim.LoopCount,
+		Config:    image.Config{Width: size.X, Height: size.Y},
+	}
+	if global != nil {
+		out.Config.ColorModel = global
+	}
+
+	// shown holds the source colors of the displayed pixels.
+	shown := newNRGBA(frames[0].Rect)
+	for i, f := range frames {
+		src := f
+		if opaque && i > 0 {
+			src = frameDifference(f, shown, (100-cfg.frameQuality(i))*64/100)
+		}
+		pal := global
+		if pal == nil {
+			pal = quantizer.Quantize(make(color.Palette, 0, numColors), src)
+		}
+		pm := image.NewPaletted(src.Rect, pal)
+		drawer.Draw(pm, src.Rect, src, src.Rect.Min)
+
+		transparent := -1
+		for j, c := range pal {
+			if _, _, _, a := c.RGBA(); a == 0 {
+				transparent = j
+				break
+			}
+		}
+		for y := src.Rect.Min.Y; y < src.Rect.Max.Y; y++ {
+			for x := src.Rect.Min.X; x < src.Rect.Max.X; x++ {
+				s := src.Pix[src.PixOffset(x, y):][:4]
+				if s[3] == 0 && transparent >= 0 {
+					pm.Pix[pm.PixOffset(x, y)] = uint8(transparent)
+					continue
+				}
+				copy(shown.Pix[shown.PixOffset(x, y):], s)
+			}
+		}
+
+		disposal := byte(gif.DisposalNone)
+		if !opaque {
+			disposal = gif.DisposalBackground
+		}
+		out.Image = append(out.Image, pm)
+		out.Delay = append(out.Delay, int((delays[i]+5*time.Millisecond)/(10*time.Millisecond)))
+		out.Disposal = append(out.Disposal, disposal)
+	}
+	return gif.EncodeAll(w, out)
+}
+
+// frameDifference returns the part of the frame that differs from the shown image
+// by more than the tolerance in any channel. The unchanged pixels are transparent.
+// If no pixels changed, a transparent 1x1 image is returned.
+func frameDifference(frame, shown *image.NRGBA, tolerance int) *image.NRGBA {
+	w, h := frame.Rect.Dx(), frame.Rect.Dy()
+	changed := make([]bool, w*h)
+	rowMin := make([]int, h)
+	rowMax := make([]int, h)
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			rowMin[y], rowMax[y] = w, -1
+			for x := 0; x < w; x++ {
+				i := y*frame.Stride + x*4
+				for c := 0; c < 4; c++ {
+					if absint(int(frame.Pix[i+c])-int(shown.Pix[i+c])) > tolerance {
+						changed[y*w+x] = true
+						rowMin[y] = minint(rowMin[y], x)
+						rowMax[y] = x
+						break
+					}
+				}
+			}
+		}
+	})
+
+	r := image.Rectangle{Min: image.Pt(w, h)}
+	for y := 0; y < h; y++ {
+		if rowMax[y] < 0 {
+			continue
+		}
+		r.Min.X = minint(r.Min.X, rowMin[y])
+		r.Max.X = maxint(r.Max.X, rowMax[y]+1)
+		r.Min.Y = minint(r.Min.Y, y)
+		r.Max.Y = y + 1
+	}
+	if r.Empty() {
+		return newNRGBA(image.Rect(0, 0, 1, 1))
+	}
+	dst := newNRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if changed[y*w+x] {
+				copy(dst.Pix[dst.PixOffset(x, y):][:4], frame.Pix[y*frame.Stride+x*4:])
+			}
+		}
+	}
+	return dst
+}
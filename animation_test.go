@@ -0,0 +1,435 @@
+package imaging
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// renderGIF returns the displayed frames of the animated GIF.
+func renderGIF(t *testing.T, data []byte) (*gif.GIF, []*image.NRGBA) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("gif.DecodeAll: %v", err)
+	}
+	canvas := image.NewNRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	var frames []*image.NRGBA
+	for i, pm := range g.Image {
+		draw.Draw(canvas, pm.Rect, pm, pm.Rect.Min, draw.Over)
+		frames = append(frames, Clone(canvas))
+		if g.Disposal[i] == gif.DisposalBackground {
+			draw.Draw(canvas, pm.Rect, image.Transparent, image.Point{}, draw.Src)
+		}
+	}
+	return g, frames
+}
+
+// movingSquareAnimation returns frames of a red square moving over a gray background.
+func movingSquareAnimation(n int) *Animation {
+	anim := &Animation{}
+	for i := 0; i < n; i++ {
+		frame := New(40, 30, color.NRGBA{128, 128, 128, 255})
+		frame = Paste(frame, New(5, 5, color.NRGBA{255, 0, 0, 255}), image.Pt(5+i*3, 10))
+		anim.Frames = append(anim.Frames, frame)
+		anim.Delays = append(anim.Delays, 100*time.Millisecond)
+	}
+	return anim
+}
+
+func TestEncodeAnimation(t *testing.T) {
+	anim := movingSquareAnimation(3)
+	anim.LoopCount = 2
+	anim.Delays[1] = 44 * time.Millisecond
+	for _, mode := range []AnimationPaletteMode{AnimationPalettePerFrame, AnimationPaletteGlobal} {
+		var buf bytes.Buffer
+		if err := EncodeAnimation(&buf, anim, GIF, AnimationPalette(mode)); err != nil {
+			t.Fatalf("EncodeAnimation: %v", err)
+		}
+		g, frames := renderGIF(t, buf.Bytes())
+		if len(frames) != 3 || g.LoopCount != 2 {
+			t.Fatalf("got %d frames and loop count %d", len(frames), g.LoopCount)
+		}
+		if want := []int{10, 4, 10}; g.Delay[0] != want[0] || g.Delay[1] != want[1] || g.Delay[2] != want[2] {
+			t.Fatalf("got delays %v want %v", g.Delay, want)
+		}
+		for i, f := range frames {
+			if !compareNRGBA(f, anim.Frames[i].(*image.NRGBA), 0) {
+				t.Fatalf("mode %v: frame %d differs", mode, i)
+			}
+		}
+		// Only the changed pixels are stored.
+		if want := image.Rect(5, 10, 13, 15); g.Image[1].Rect != want {
+			t.Fatalf("got frame bounds %v want %v", g.Image[1].Rect, want)
+		}
+		if mode == AnimationPaletteGlobal {
+			for i, pm := range g.Image {
+				if !reflect.DeepEqual(pm.Palette, g.Image[0].Palette) {
+					t.Fatalf("frame %d doesn't use the global palette", i)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeAnimationHash(t *testing.T) {
+	h := sha256.New()
+	var buf bytes.Buffer
+	if err := EncodeAnimation(&buf, movingSquareAnimation(3), GIF, EncodeHash(h)); err != nil {
+		t.Fatalf("EncodeAnimation: %v", err)
+	}
+	if want := sha256.Sum256(buf.Bytes()); !bytes.Equal(h.Sum(nil), want[:]) {
+		t.Fatal("the hash differs from the hash of the data")
+	}
+}
+
+func TestEncodeAnimationPaletteSize(t *testing.T) {
+	// A mostly static animation, e.g. a screen recording with a moving cursor.
+	anim := &Animation{}
+	for i := 0; i < 10; i++ {
+		anim.Frames = append(anim.Frames, Paste(testdataFlowersSmallPNG, New(4, 4, color.White), image.Pt(i*10, i*5)))
+	}
+	var perFrame, global bytes.Buffer
+	if err := EncodeAnimation(&perFrame, anim, GIF); err != nil {
+		t.Fatalf("EncodeAnimation: %v", err)
+	}
+	if err := EncodeAnimation(&global, anim, GIF, AnimationPalette(AnimationPaletteGlobal)); err != nil {
+		t.Fatalf("EncodeAnimation: %v", err)
+	}
+	if global.Len() >= perFrame.Len() {
+		t.Fatalf("got %d bytes with the global palette and %d bytes with per-frame palettes", global.Len(), perFrame.Len())
+	}
+}
+
+func TestEncodeAnimationQuality(t *testing.T) {
+	// The second frame is slightly brighter, the third one has a new square.
+	frame := New(20, 20, color.NRGBA{100, 100, 100, 255})
+	brighter := New(20, 20, color.NRGBA{105, 105, 105, 255})
+	square := Paste(brighter, New(2, 2, color.Black), image.Pt(3, 4))
+	anim := &Animation{Frames: []image.Image{frame, brighter, square}}
+
+	testCases := []struct {
+		name      string
+		qualities []int
+		want      []image.Rectangle
+	}{
+		{"lossless", nil, []image.Rectangle{image.Rect(0, 0, 20, 20), image.Rect(0, 0, 20, 20), image.Rect(3, 4, 5, 6)}},
+		{"lossy", []int{90}, []image.Rectangle{image.Rect(0, 0, 20, 20), image.Rect(0, 0, 1, 1), image.Rect(3, 4, 5, 6)}},
+		{"per frame", []int{100, 100, 90}, []image.Rectangle{image.Rect(0, 0, 20, 20), image.Rect(0, 0, 20, 20), image.Rect(3, 4, 5, 6)}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeAnimation(&buf, anim, GIF, AnimationQuality(tc.qualities...)); err != nil {
+				t.Fatalf("EncodeAnimation: %v", err)
+			}
+			g, frames := renderGIF(t, buf.Bytes())
+			for i, pm := range g.Image {
+				if pm.Rect != tc.want[i] {
+					t.Fatalf("frame %d: got bounds %v want %v", i, pm.Rect, tc.want[i])
+				}
+			}
+			// The pixels differ from the source by the tolerance at most.
+			for i, f := range frames {
+				if !compareNRGBA(f, anim.Frames[i].(*image.NRGBA), 6) {
+					t.Fatalf("frame %d differs", i)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeAnimationTransparent(t *testing.T) {
+	anim := &Animation{Frames: []image.Image{
+		New(10, 10, color.NRGBA{255, 0, 0, 255}),
+		New(10, 10, color.NRGBA{}),
+		// Smaller frames are aligned with the top-left corner.
+		New(5, 5, color.NRGBA{0, 0, 255, 255}),
+	}}
+	var buf bytes.Buffer
+	if err := EncodeAnimation(&buf, anim, GIF); err != nil {
+		t.Fatalf("EncodeAnimation: %v", err)
+	}
+	g, frames := renderGIF(t, buf.Bytes())
+	want := []*image.NRGBA{
+		New(10, 10, color.NRGBA{255, 0, 0, 255}),
+		New(10, 10, color.NRGBA{}),
+		Paste(New(10, 10, color.NRGBA{}), New(5, 5, color.NRGBA{0, 0, 255, 255}), image.Pt(0, 0)),
+	}
+	for i, f := range frames {
+		if g.Disposal[i] != gif.DisposalBackground || g.Image[i].Rect != image.Rect(0, 0, 10, 10) {
+			t.Fatalf("frame %d: got disposal %d and bounds %v", i, g.Disposal[i], g.Image[i].Rect)
+		}
+		if !compareNRGBA(f, want[i], 0) {
+			t.Fatalf("frame %d differs", i)
+		}
+	}
+}
+
+func TestEncodeAnimationDeduplicate(t *testing.T) {
+	gray := New(10, 10, color.NRGBA{100, 100, 100, 255})
+	grayNoise := Paste(gray, New(1, 1, color.NRGBA{103, 100, 100, 255}), image.Pt(4, 4))
+	red := New(10, 10, color.NRGBA{255, 0, 0, 255})
+	anim := &Animation{
+		Frames: []image.Image{gray, gray, grayNoise, red, red},
+		Delays: []time.Duration{100 * time.Millisecond, 50 * time.Millisecond, 30 * time.Millisecond, 20 * time.Millisecond, 10 * time.Millisecond},
+	}
+	testCases := []struct {
+		name      string
+		tolerance int
+		delays    []int
+	}{
+		{"disabled", -1, []int{10, 5, 3, 2, 1}},
+		{"identical", 0, []int{15, 3, 3}},
+		{"near-identical", 5, []int{18, 3}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeAnimation(&buf, anim, GIF, AnimationDeduplicate(tc.tolerance)); err != nil {
+				t.Fatalf("EncodeAnimation: %v", err)
+			}
+			g, err := gif.DecodeAll(&buf)
+			if err != nil {
+				t.Fatalf("gif.DecodeAll: %v", err)
+			}
+			if !reflect.DeepEqual(g.Delay, tc.delays) {
+				t.Fatalf("got delays %v want %v", g.Delay, tc.delays)
+			}
+		})
+	}
+}
+
+func TestDeduplicateFramesDrift(t *testing.T) {
+	// A slow fade is kept although every frame is close to the previous one.
+	var frames []*image.NRGBA
+	var delays []time.Duration
+	for i := 0; i < 10; i++ {
+		frames = append(frames, New(2, 2, color.NRGBA{uint8(i * 2), 0, 0, 255}))
+		delays = append(delays, 10*time.Millisecond)
+	}
+	got, gotDelays := deduplicateFrames(frames, delays, 3)
+	if len(got) != 5 {
+		t.Fatalf("got %d frames want 5", len(got))
+	}
+	for i, d := range gotDelays {
+		if d != 20*time.Millisecond {
+			t.Fatalf("frame %d: got delay %v want 20ms", i, d)
+		}
+	}
+}
+
+func TestEncodeAnimationFails(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeAnimation(&buf, &Animation{}, GIF); err != errNoFrames {
+		t.Fatalf("got error %v want %v", err, errNoFrames)
+	}
+	if err := EncodeAnimation(&buf, movingSquareAnimation(2), PNG); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want %v", err, ErrUnsupportedFormat)
+	}
+}
+
+func TestSaveAnimation(t *testing.T) {
+	dir := t.TempDir()
+	anim := movingSquareAnimation(4)
+	if err := SaveAnimation(anim, filepath.Join(dir, "out.gif")); err != nil {
+		t.Fatalf("SaveAnimation: %v", err)
+	}
+	img, err := Open(filepath.Join(dir, "out.gif"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if !compareNRGBA(Clone(img), anim.Frames[0].(*image.NRGBA), 0) {
+		t.Fatal("the first frame differs")
+	}
+	if err := SaveAnimation(anim, filepath.Join(dir, "out.xyz")); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want %v", err, ErrUnsupportedFormat)
+	}
+}
+
+func TestDecodeAnimation(t *testing.T) {
+	anim := movingSquareAnimation(4)
+	anim.LoopCount = 3
+	var buf bytes.Buffer
+	if err := EncodeAnimation(&buf, anim, GIF); err != nil {
+		t.Fatalf("EncodeAnimation: %v", err)
+	}
+	got, err := DecodeAnimation(&buf)
+	if err != nil {
+		t.Fatalf("DecodeAnimation: %v", err)
+	}
+	if len(got.Frames) != 4 || got.LoopCount != 3 || !reflect.DeepEqual(got.Delays, anim.Delays) {
+		t.Fatalf("got %d frames, loop count %d and delays %v", len(got.Frames), got.LoopCount, got.Delays)
+	}
+	for i, f := range got.Frames {
+		if !compareNRGBA(f.(*image.NRGBA), anim.Frames[i].(*image.NRGBA), 0) {
+			t.Fatalf("frame %d differs", i)
+		}
+	}
+}
+
+func TestDecodeAnimationLimits(t *testing.T) {
+	anim := movingSquareAnimation(4)
+	var buf bytes.Buffer
+	if err := EncodeAnimation(&buf, anim, GIF); err != nil {
+		t.Fatalf("EncodeAnimation: %v", err)
+	}
+	data := buf.Bytes()
+	size := anim.Frames[0].Bounds().Size()
+
+	if _, err := DecodeAnimation(bytes.NewReader(data), MaxPixels(size.X*size.Y)); err != nil {
+		t.Fatalf("DecodeAnimation: %v", err)
+	}
+	var tooLarge *ImageTooLargeError
+	if _, err := DecodeAnimation(bytes.NewReader(data), MaxDimensions(size.X-1, 0)); !errors.As(err, &tooLarge) {
+		t.Fatalf("got error %v want an *ImageTooLargeError", err)
+	}
+
+	defer func(n int64) { animationMaxPixels = n }(animationMaxPixels)
+	animationMaxPixels = int64(4*size.X*size.Y - 1)
+	if _, err := DecodeAnimation(bytes.NewReader(data)); err != errAnimationTooLarge {
+		t.Fatalf("got error %v want %v", err, errAnimationTooLarge)
+	}
+
+	// The options apply to the still images.
+	buf.Reset()
+	if err := Encode(&buf, New(8, 8, color.White), PNG); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if _, err := DecodeAnimation(&buf, MaxPixels(63)); !errors.As(err, &tooLarge) {
+		t.Fatalf("still image: got error %v want an *ImageTooLargeError", err)
+	}
+}
+
+func TestDecodeAnimationDisposal(t *testing.T) {
+	pal := color.Palette{color.NRGBA{}, color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}}
+	fill := func(r image.Rectangle, index uint8) *image.Paletted {
+		pm := image.NewPaletted(r, pal)
+		for i := range pm.Pix {
+			pm.Pix[i] = index
+		}
+		return pm
+	}
+	g := &gif.GIF{
+		Image: []*image.Paletted{
+			fill(image.Rect(0, 0, 4, 4), 1),
+			fill(image.Rect(0, 0, 2, 2), 2),
+			fill(image.Rect(2, 2, 4, 4), 2),
+			fill(image.Rect(0, 0, 1, 1), 0),
+		},
+		Delay:    []int{1, 2, 3, 4},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalPrevious, gif.DisposalBackground, gif.DisposalNone},
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatalf("gif.EncodeAll: %v", err)
+	}
+	anim, err := DecodeAnimation(&buf)
+	if err != nil {
+		t.Fatalf("DecodeAnimation: %v", err)
+	}
+
+	red := New(4, 4, color.NRGBA{255, 0, 0, 255})
+	blue := New(2, 2, color.NRGBA{0, 0, 255, 255})
+	want := []*image.NRGBA{
+		red,
+		Paste(red, blue, image.Pt(0, 0)),
+		// The previous frame is restored before the third frame.
+		Paste(red, blue, image.Pt(2, 2)),
+		// The third frame is cleared to transparent.
+		Paste(red, image.NewNRGBA(image.Rect(0, 0, 2, 2)), image.Pt(2, 2)),
+	}
+	for i, f := range anim.Frames {
+		if !compareNRGBA(f.(*image.NRGBA), want[i], 0) {
+			t.Fatalf("frame %d differs", i)
+		}
+		if want := time.Duration(i+1) * 10 * time.Millisecond; anim.Delays[i] != want {
+			t.Fatalf("frame %d: got delay %v want %v", i, anim.Delays[i], want)
+		}
+	}
+}
+
+func TestDecodeAnimationStill(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testdataFlowersSmallPNG, PNG); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	anim, err := DecodeAnimation(&buf)
+	if err != nil {
+		t.Fatalf("DecodeAnimation: %v", err)
+	}
+	if len(anim.Frames) != 1 || len(anim.Delays) != 1 || !compareNRGBA(Clone(anim.Frames[0]), Clone(testdataFlowersSmallPNG), 0) {
+		t.Fatalf("got %d frames", len(anim.Frames))
+	}
+	if _, err := DecodeAnimation(strings.NewReader("GIF89a")); err == nil {
+		t.Fatal("expected an error for a truncated GIF")
+	}
+	if _, err := OpenAnimation("testdata/missing.gif"); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
+
+func TestConvertAnimation(t *testing.T) {
+	anim := movingSquareAnimation(2)
+	anim.Frames = append(anim.Frames, anim.Frames[1], anim.Frames[1])
+	anim.Delays = append(anim.Delays, 100*time.Millisecond, 100*time.Millisecond)
+	filename := filepath.Join(t.TempDir(), "in.gif")
+	if err := SaveAnimation(anim, filename); err != nil {
+		t.Fatalf("SaveAnimation: %v", err)
+	}
+	src, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("os.Open: %v", err)
+	}
+	defer src.Close()
+
+	var buf bytes.Buffer
+	if err := ConvertAnimation(&buf, src, GIF, AnimationDeduplicate(0)); err != nil {
+		t.Fatalf("ConvertAnimation: %v", err)
+	}
+	got, err := DecodeAnimation(&buf)
+	if err != nil {
+		t.Fatalf("DecodeAnimation: %v", err)
+	}
+	if want := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond}; !reflect.DeepEqual(got.Delays, want) {
+		t.Fatalf("got delays %v want %v", got.Delays, want)
+	}
+
+	if err := ConvertAnimation(&buf, strings.NewReader("GIF89a"), GIF); err == nil {
+		t.Fatal("expected an error for a truncated GIF")
+	}
+	if _, err := src.Seek(0, 0); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	if err := ConvertAnimation(&buf, src, PNG); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want %v", err, ErrUnsupportedFormat)
+	}
+}
+
+func TestMergeHistograms(t *testing.T) {
+	a := []colorCount{{rgb: [3]uint8{0, 0, 1}, count: 1, alpha: 255}, {rgb: [3]uint8{0, 1, 0}, count: 2, alpha: 510}}
+	b := []colorCount{{rgb: [3]uint8{0, 0, 1}, count: 3, alpha: 300}, {rgb: [3]uint8{1, 0, 0}, count: 1, alpha: 255}}
+	want := []colorCount{
+		{rgb: [3]uint8{0, 0, 1}, count: 4, alpha: 555},
+		{rgb: [3]uint8{0, 1, 0}, count: 2, alpha: 510},
+		{rgb: [3]uint8{1, 0, 0}, count: 1, alpha: 255},
+	}
+	got := mergeHistograms(a, b)
+	if len(got) != len(want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %v want %v", got, want)
+		}
+	}
+}
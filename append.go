@@ -0,0 +1,128 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// AppendOption sets an optional parameter for the AppendHorizontal and AppendVertical functions.
+type AppendOption func(*appendConfig)
+
+type appendConfig struct {
+	align      Anchor
+	background color.Color
+	spacing    int
+}
+
+func newAppendConfig(opts []AppendOption) appendConfig {
+	cfg := appendConfig{
+		align:      Center,
+		background: color.Transparent,
+	}
+	for _, option := range opts {
+		option(&cfg)
+	}
+	return cfg
+}
+
+// AppendAlign returns an AppendOption that sets the alignment of the images smaller than
+// the result across the direction of the appending: Top, Center or Bottom for AppendHorizontal
+// and Left, Center or Right for AppendVertical. The corner anchors combine the two,
+// e.g. TopLeft aligns to the top and to the left. Default is Center.
+func AppendAlign(align Anchor) AppendOption {
+	return func(c *appendConfig) {
+		c.align = align
+	}
+}
+
+// AppendBackground returns an AppendOption that sets the color of the space around the
+// smaller images and between the images. Default is transparent.
+func AppendBackground(background color.Color) AppendOption {
+	return func(c *appendConfig) {
+		c.background = background
+	}
+}
+
+// AppendSpacing returns an AppendOption that sets the space in pixels between the images.
+// The spacing < 0 is 0. Default is 0.
+func AppendSpacing(spacing int) AppendOption {
+	return func(c *appendConfig) {
+		c.spacing = maxint(spacing, 0)
+	}
+}
+
+// AppendHorizontal places the images side by side from left to right and returns the combined
+// image, as tall as the tallest image. The shorter images are aligned to the center, see
+// AppendAlign, over the background color, see AppendBackground. The images are drawn over
+// the background, so their transparent areas show it. The nil and empty images are skipped.
+//
+// Example:
+//
+//	// Compare the images side by side.
+//	dstImage := imaging.AppendHorizontal([]image.Image{before, after},
+//		imaging.AppendSpacing(10),
+//		imaging.AppendBackground(color.White),
+//	)
+func AppendHorizontal(images []image.Image, opts ...AppendOption) *image.NRGBA {
+	return appendImages(images, true, newAppendConfig(opts))
+}
+
+// AppendVertical places the images one below another from top to bottom and returns the
+// combined image, as wide as the widest image. The narrower images are aligned like in
+// AppendHorizontal.
+//
+// Example:
+//
+//	dstImage := imaging.AppendVertical([]image.Image{header, body, footer}, imaging.AppendAlign(imaging.Left))
+func AppendVertical(images []image.Image, opts ...AppendOption) *image.NRGBA {
+	return appendImages(images, false, newAppendConfig(opts))
+}
+
+func appendImages(images []image.Image, horizontal bool, cfg appendConfig) *image.NRGBA {
+	// The length along the direction of the appending and the size across it.
+	var length, size, n int
+	for _, img := range images {
+		if img == nil || img.Bounds().Empty() {
+			continue
+		}
+		b := img.Bounds()
+		l, s := b.Dx(), b.Dy()
+		if !horizontal {
+			l, s = s, l
+		}
+		length += l
+		size = maxint(size, s)
+		n++
+	}
+	if n == 0 {
+		return &image.NRGBA{}
+	}
+	length += (n - 1) * cfg.spacing
+
+	var dst *image.NRGBA
+	if horizontal {
+		dst = New(length, size, cfg.background)
+	} else {
+		dst = New(size, length, cfg.background)
+	}
+	pos := 0
+	for _, img := range images {
+		if img == nil || img.Bounds().Empty() {
+			continue
+		}
+		b := img.Bounds()
+		// The slot of the image spans the result across the direction of the appending.
+		var slot image.Rectangle
+		if horizontal {
+			slot = image.Rect(pos, 0, pos+b.Dx(), size)
+			pos += b.Dx() + cfg.spacing
+		} else {
+			slot = image.Rect(0, pos, size, pos+b.Dy())
+			pos += b.Dy() + cfg.spacing
+		}
+		pt := anchorPt(slot, b.Dx(), b.Dy(), cfg.align)
+		draw.Draw(dst, image.Rectangle{Min: pt, Max: pt.Add(b.Size())}, img, b.Min, draw.Over)
+	}
+	return dst
+}
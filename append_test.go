@@ -0,0 +1,88 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestAppendHorizontal(t *testing.T) {
+	red, blue := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}
+	bg := color.NRGBA{0, 255, 0, 255}
+	// The blue image is offset, it's drawn from its bounds.
+	offset := image.NewNRGBA(image.Rect(10, 10, 12, 12))
+	for i := 0; i < len(offset.Pix); i += 4 {
+		copy(offset.Pix[i:], []uint8{blue.R, blue.G, blue.B, blue.A})
+	}
+	images := []image.Image{New(3, 4, red), nil, offset, &image.NRGBA{}}
+
+	for _, tc := range []struct {
+		align Anchor
+		rows  [4]string
+	}{
+		{Top, [4]string{"rrr.bb", "rrr.bb", "rrr...", "rrr..."}},
+		{Center, [4]string{"rrr...", "rrr.bb", "rrr.bb", "rrr..."}},
+		{BottomRight, [4]string{"rrr...", "rrr...", "rrr.bb", "rrr.bb"}},
+	} {
+		got := AppendHorizontal(images, AppendAlign(tc.align), AppendBackground(bg), AppendSpacing(1))
+		if got.Bounds() != image.Rect(0, 0, 6, 4) {
+			t.Fatalf("align %d: got bounds %v want 6x4", tc.align, got.Bounds())
+		}
+		for y, row := range tc.rows {
+			for x, ch := range row {
+				want := map[rune]color.NRGBA{'r': red, 'b': blue, '.': bg}[ch]
+				if c := got.NRGBAAt(x, y); c != want {
+					t.Errorf("align %d: pixel (%d, %d): got %v want %v", tc.align, x, y, c, want)
+				}
+			}
+		}
+	}
+
+	// The transparent background and the images drawn over it.
+	half := New(2, 2, color.NRGBA{255, 0, 0, 128})
+	got := AppendHorizontal([]image.Image{half, New(1, 3, blue)}, AppendAlign(Top), AppendBackground(color.White))
+	if c := got.NRGBAAt(0, 0); c != (color.NRGBA{255, 127, 127, 255}) {
+		t.Errorf("got %v want the half transparent red over white", c)
+	}
+	got = AppendHorizontal([]image.Image{half, New(1, 3, blue)}, AppendAlign(Top))
+	if c := got.NRGBAAt(0, 2); c != (color.NRGBA{}) {
+		t.Errorf("got %v want transparent", c)
+	}
+
+	for _, images := range [][]image.Image{nil, {nil, &image.NRGBA{}}} {
+		if got := AppendHorizontal(images); got.Bounds() != (image.Rectangle{}) {
+			t.Errorf("got bounds %v want empty", got.Bounds())
+		}
+	}
+}
+
+func TestAppendVertical(t *testing.T) {
+	got := AppendVertical([]image.Image{testdataFlowersSmallPNG, testdataBranchesJPG}, AppendAlign(Left), AppendSpacing(5))
+	fw, fh := testdataFlowersSmallPNG.Bounds().Dx(), testdataFlowersSmallPNG.Bounds().Dy()
+	bw, bh := testdataBranchesJPG.Bounds().Dx(), testdataBranchesJPG.Bounds().Dy()
+	if want := image.Rect(0, 0, maxint(fw, bw), fh+5+bh); got.Bounds() != want {
+		t.Fatalf("got bounds %v want %v", got.Bounds(), want)
+	}
+	if !compareNRGBA(Crop(got, image.Rect(0, 0, fw, fh)), Clone(testdataFlowersSmallPNG), 0) {
+		t.Error("the first image differs")
+	}
+	if !compareNRGBA(Crop(got, image.Rect(0, fh+5, bw, fh+5+bh)), Clone(testdataBranchesJPG), 0) {
+		t.Error("the second image differs")
+	}
+
+	// AppendVertical is AppendHorizontal of the transposed images.
+	a, b := Transpose(testdataFlowersSmallPNG), Transpose(Resize(testdataBranchesJPG, 100, 0, Box))
+	want := Transpose(AppendHorizontal([]image.Image{a, b}, AppendAlign(Bottom), AppendSpacing(3), AppendBackground(color.Black)))
+	got = AppendVertical([]image.Image{Transpose(a), Transpose(b)}, AppendAlign(Right), AppendSpacing(3), AppendBackground(color.Black))
+	if !compareNRGBA(got, want, 0) {
+		t.Error("AppendVertical differs from AppendHorizontal of the transposed images")
+	}
+}
+
+func BenchmarkAppendHorizontal(b *testing.B) {
+	images := []image.Image{testdataBranchesJPG, testdataFlowersSmallPNG, testdataBranchesJPG}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		AppendHorizontal(images, AppendBackground(color.White))
+	}
+}
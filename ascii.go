@@ -0,0 +1,124 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Character sets for the ToASCII function. Characters are ordered from the darkest
+// to the brightest pixel, as seen on a terminal with a dark background.
+const (
+	// ASCIICharset is a short ramp of ASCII characters.
+	ASCIICharset = " .:-=+*#%@"
+
+	// ASCIICharsetDetailed is a long ramp of ASCII characters giving finer tonal steps.
+	ASCIICharsetDetailed = " .'`^\",:;Il!i><~+_-?][}{1)(|\\/tfjrxnuvczXYUJCLQ0OZmwqpdbkhao*#MW&8%B@$"
+
+	// BlockCharset is a ramp of Unicode block shade characters.
+	BlockCharset = " ░▒▓█"
+)
+
+// blockShades maps the Unicode shade characters to the fraction of the cell they cover.
+var blockShades = map[rune]float64{
+	'░': 0.25,
+	'▒': 0.5,
+	'▓': 0.75,
+	'█': 1,
+}
+
+// ToASCII converts the image to text art that is cols characters wide.
+// Each character is chosen from the charset according to the luminance of the
+// corresponding image area; the charset must be ordered from the darkest to the brightest.
+// Transparent areas are treated as dark. The number of rows is chosen to preserve
+// the aspect ratio of the image, assuming terminal cells are twice as tall as they are wide.
+// Rows are separated by newline characters.
+//
+// Example:
+//
+//	fmt.Println(imaging.ToASCII(srcImage, 80, imaging.ASCIICharset))
+func ToASCII(img image.Image, cols int, charset string) string {
+	chars := []rune(charset)
+	if cols <= 0 || len(chars) == 0 {
+		return ""
+	}
+	w := img.Bounds().Dx()
+	h := img.Bounds().Dy()
+	if w <= 0 || h <= 0 {
+		return ""
+	}
+
+	rows := int(math.Max(1, math.Floor(float64(h)*float64(cols)/float64(w)/2+0.5)))
+	small := Resize(img, cols, rows, Box)
+
+	var sb strings.Builder
+	n := float64(len(chars) - 1)
+	for y := 0; y < rows; y++ {
+		i := y * small.Stride
+		for x := 0; x < cols; x++ {
+			s := small.Pix[i : i+4 : i+4]
+			lum := (0.299*float64(s[0]) + 0.587*float64(s[1]) + 0.114*float64(s[2])) * float64(s[3]) / 255
+			sb.WriteRune(chars[int(lum/255*n+0.5)])
+			i += 4
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// RenderASCII draws the text art produced by ToASCII (or any other multi-line text)
+// back to an image using a monospace font face. Each character is drawn in its own cell
+// with the fg color over the bg color. The Unicode shade characters of BlockCharset are
+// drawn as blended solid cells. If face is nil, a built-in 7x13 pixel face is used.
+//
+// Example:
+//
+//	text := imaging.ToASCII(srcImage, 100, imaging.ASCIICharset)
+//	dstImage := imaging.RenderASCII(text, nil, color.White, color.Black)
+func RenderASCII(text string, face font.Face, fg, bg color.Color) *image.NRGBA {
+	if face == nil {
+		face = basicfont.Face7x13
+	}
+
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	cols := 0
+	for _, line := range lines {
+		if n := len([]rune(line)); n > cols {
+			cols = n
+		}
+	}
+
+	metrics := face.Metrics()
+	adv, ok := face.GlyphAdvance('M')
+	if !ok {
+		adv = metrics.Height / 2
+	}
+	cellW := adv.Ceil()
+	cellH := metrics.Height.Ceil()
+	dst := New(cols*cellW, len(lines)*cellH, bg)
+	if cols == 0 || cellW <= 0 || cellH <= 0 {
+		return dst
+	}
+
+	fgSrc := image.NewUniform(fg)
+	d := &font.Drawer{Dst: dst, Src: fgSrc, Face: face}
+	for row, line := range lines {
+		for col, c := range []rune(line) {
+			cell := image.Rect(col*cellW, row*cellH, (col+1)*cellW, (row+1)*cellH)
+			if cover, ok := blockShades[c]; ok {
+				mask := image.NewUniform(color.Alpha{clamp(cover * 255)})
+				draw.DrawMask(dst, cell, fgSrc, image.Point{}, mask, image.Point{}, draw.Over)
+				continue
+			}
+			d.Dot = fixed.P(cell.Min.X, cell.Min.Y+metrics.Ascent.Ceil())
+			d.DrawString(string(c))
+		}
+	}
+	return dst
+}
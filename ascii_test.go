@@ -0,0 +1,77 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestToASCII(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 4, 2),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x00, 0x00, 0x00, 0xff, 0x55, 0x55, 0x55, 0xff, 0xaa, 0xaa, 0xaa, 0xff, 0xff, 0xff, 0xff, 0xff,
+			0x00, 0x00, 0x00, 0xff, 0x55, 0x55, 0x55, 0xff, 0xaa, 0xaa, 0xaa, 0xff, 0xff, 0xff, 0xff, 0x00,
+		},
+	}
+	testCases := []struct {
+		name    string
+		cols    int
+		charset string
+		want    string
+	}{
+		{"ToASCII 4 cols", 4, " .:#", " .::\n"},
+		{"ToASCII 8 cols", 8, " .:#", "  ..::##\n  ..::  \n"},
+		{"ToASCII blocks", 4, BlockCharset, " ░▓▒\n"},
+		{"ToASCII 0 cols", 0, ASCIICharset, ""},
+		{"ToASCII empty charset", 4, "", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ToASCII(src, tc.cols, tc.charset)
+			if got != tc.want {
+				t.Fatalf("got %q want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRenderASCII(t *testing.T) {
+	fg := color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	bg := color.NRGBA{0x00, 0x00, 0x00, 0xff}
+
+	got := RenderASCII("█ \n@@\n", nil, fg, bg)
+	if want := image.Rect(0, 0, 2*7, 2*13); !got.Rect.Eq(want) {
+		t.Fatalf("got bounds %v want %v", got.Rect, want)
+	}
+	if c := got.NRGBAAt(3, 6); c != fg {
+		t.Fatalf("full block cell: got %v want %v", c, fg)
+	}
+	if c := got.NRGBAAt(10, 6); c != bg {
+		t.Fatalf("space cell: got %v want %v", c, bg)
+	}
+
+	drawn := false
+	for y := 13; y < 26; y++ {
+		for x := 0; x < 7; x++ {
+			if got.NRGBAAt(x, y) != bg {
+				drawn = true
+			}
+		}
+	}
+	if !drawn {
+		t.Fatalf("glyph cell is empty")
+	}
+
+	if got := RenderASCII("", nil, fg, bg); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v want empty", got.Rect)
+	}
+}
+
+func BenchmarkToASCII(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ToASCII(testdataBranchesJPG, 120, ASCIICharsetDetailed)
+	}
+}
@@ -0,0 +1,136 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// BackgroundMethod specifies how SubtractBackground models the background.
+type BackgroundMethod int
+
+// Background models.
+const (
+	// BackgroundMedian takes the median of each color channel over the frames. It ignores
+	// objects that cover a pixel in less than half of the frames, so it works even with
+	// a few frames and slow-moving objects.
+	BackgroundMedian BackgroundMethod = iota
+
+	// BackgroundMean takes the average of each color channel over the frames. It's faster
+	// than the median but moving objects blend into the background, so it needs many frames.
+	BackgroundMean
+)
+
+// backgroundMinThreshold is the minimum color difference from the background for a pixel
+// to be a part of the foreground. It suppresses the sensor noise and JPEG artifacts.
+const backgroundMinThreshold = 25
+
+// SubtractBackground estimates the static background of a sequence of frames, e.g. timelapse
+// shots or stills of a fixed camera, and returns a foreground mask for each frame. Foreground
+// pixels are white (255) and background pixels are black (0). A pixel is foreground if its
+// color differs from the background by more than the usual variation of that pixel over
+// the frames, so flickering areas like leaves or water need larger changes to be detected.
+//
+// The masks have the size of the first frame, the other frames are placed at its top-left
+// corner like in Stack. Handheld frames should be registered with Align first.
+//
+// Example:
+//
+//	masks := imaging.SubtractBackground(frames, imaging.BackgroundMedian)
+func SubtractBackground(frames []image.Image, method BackgroundMethod) []*image.Gray {
+	if len(frames) == 0 {
+		return nil
+	}
+	scanners := make([]*scanner, len(frames))
+	for i, f := range frames {
+		scanners[i] = newScanner(f)
+	}
+	w, h := scanners[0].w, scanners[0].h
+	masks := make([]*image.Gray, len(frames))
+	for i := range masks {
+		masks[i] = image.NewGray(image.Rect(0, 0, w, h))
+	}
+
+	parallel(0, h, func(ys <-chan int) {
+		n := len(frames)
+		lines := make([][]uint8, n)
+		for i := range lines {
+			lines[i] = make([]uint8, w*4)
+		}
+		covers := make([]int, n) // The number of pixels of the row covered by each frame.
+		values := make([]float64, 0, n)
+		dists := make([]float64, n)
+		for y := range ys {
+			for i, src := range scanners {
+				covers[i] = 0
+				if y < src.h {
+					covers[i] = minint(w, src.w)
+					src.scan(0, y, covers[i], y+1, lines[i])
+				}
+			}
+			for x := 0; x < w; x++ {
+				var bg [3]float64
+				for c := 0; c < 3; c++ {
+					values = values[:0]
+					for i := range lines {
+						if x < covers[i] {
+							values = append(values, float64(lines[i][x*4+c]))
+						}
+					}
+					bg[c] = backgroundValue(values, method)
+				}
+
+				// The distances of the frames from the background and their usual spread.
+				values = values[:0]
+				for i, line := range lines {
+					if x >= covers[i] {
+						continue
+					}
+					s := line[x*4 : x*4+3 : x*4+3]
+					d := math.Max(math.Abs(float64(s[0])-bg[0]), math.Max(math.Abs(float64(s[1])-bg[1]), math.Abs(float64(s[2])-bg[2])))
+					dists[i] = d
+					values = append(values, d)
+				}
+				var spread float64
+				if method == BackgroundMean {
+					// The root mean square of the distances approximates the standard deviation.
+					for _, d := range values {
+						spread += d * d
+					}
+					spread = math.Sqrt(spread / float64(len(values)))
+				} else {
+					// The median absolute deviation scaled to the standard deviation.
+					spread = 1.4826 * backgroundValue(values, BackgroundMedian)
+				}
+				threshold := math.Max(backgroundMinThreshold, 3*spread)
+
+				for i := range lines {
+					if x < covers[i] && dists[i] > threshold {
+						masks[i].Pix[y*masks[i].Stride+x] = 0xff
+					}
+				}
+			}
+		}
+	})
+	return masks
+}
+
+// backgroundValue returns the median or the mean of the values. It may reorder the values.
+func backgroundValue(values []float64, method BackgroundMethod) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	if method == BackgroundMean {
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	}
+	sort.Float64s(values)
+	m := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[m-1] + values[m]) / 2
+	}
+	return values[m]
+}
@@ -0,0 +1,118 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// backgroundTestFrames returns n frames of a gray background with a little noise and a white
+// square moving to the right by 4 pixels per frame.
+func backgroundTestFrames(n int) []image.Image {
+	frames := make([]image.Image, n)
+	for i := range frames {
+		img := New(80, 20, color.NRGBA{100, 100, 100, 255})
+		for y := 0; y < 20; y++ {
+			for x := 0; x < 80; x++ {
+				if (x*7+y*3+i*5)%11 == 0 {
+					img.SetNRGBA(x, y, color.NRGBA{110, 95, 100, 255})
+				}
+			}
+		}
+		frames[i] = Paste(img, New(4, 4, color.White), image.Pt(i*4, 8))
+	}
+	return frames
+}
+
+func TestSubtractBackground(t *testing.T) {
+	testCases := []struct {
+		name   string
+		frames int
+		method BackgroundMethod
+	}{
+		{"median", 5, BackgroundMedian},
+		{"mean", 16, BackgroundMean},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			frames := backgroundTestFrames(tc.frames)
+			masks := SubtractBackground(frames, tc.method)
+			if len(masks) != len(frames) {
+				t.Fatalf("got %d masks want %d", len(masks), len(frames))
+			}
+			for i, mask := range masks {
+				if got, want := mask.Bounds(), image.Rect(0, 0, 80, 20); got != want {
+					t.Fatalf("got mask bounds %v want %v", got, want)
+				}
+				square := image.Rect(i*4, 8, i*4+4, 12)
+				for y := 0; y < 20; y++ {
+					for x := 0; x < 80; x++ {
+						want := uint8(0)
+						if image.Pt(x, y).In(square) {
+							want = 0xff
+						}
+						if got := mask.GrayAt(x, y).Y; got != want {
+							t.Fatalf("mask %d: got %#x at %d,%d want %#x", i, got, x, y, want)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSubtractBackgroundSizes(t *testing.T) {
+	frames := []image.Image{
+		New(3, 2, color.Black),
+		New(3, 2, color.Black),
+		New(2, 1, color.White),
+		New(4, 3, color.Black),
+	}
+	masks := SubtractBackground(frames, BackgroundMedian)
+	want := []*image.Gray{
+		{Rect: image.Rect(0, 0, 3, 2), Stride: 3, Pix: []uint8{0, 0, 0, 0, 0, 0}},
+		{Rect: image.Rect(0, 0, 3, 2), Stride: 3, Pix: []uint8{0, 0, 0, 0, 0, 0}},
+		{Rect: image.Rect(0, 0, 3, 2), Stride: 3, Pix: []uint8{0xff, 0xff, 0, 0, 0, 0}},
+		{Rect: image.Rect(0, 0, 3, 2), Stride: 3, Pix: []uint8{0, 0, 0, 0, 0, 0}},
+	}
+	for i := range want {
+		if masks[i].Rect != want[i].Rect || string(masks[i].Pix) != string(want[i].Pix) {
+			t.Fatalf("mask %d: got %#v want %#v", i, masks[i], want[i])
+		}
+	}
+
+	if masks := SubtractBackground(nil, BackgroundMedian); masks != nil {
+		t.Fatalf("got %#v want nil", masks)
+	}
+}
+
+func TestBackgroundValue(t *testing.T) {
+	testCases := []struct {
+		values []float64
+		method BackgroundMethod
+		want   float64
+	}{
+		{[]float64{3, 1, 2}, BackgroundMedian, 2},
+		{[]float64{4, 1, 3, 2}, BackgroundMedian, 2.5},
+		{[]float64{4, 1, 3, 2}, BackgroundMean, 2.5},
+		{[]float64{5}, BackgroundMean, 5},
+		{nil, BackgroundMedian, 0},
+	}
+	for _, tc := range testCases {
+		if got := backgroundValue(tc.values, tc.method); got != tc.want {
+			t.Fatalf("got %v want %v", got, tc.want)
+		}
+	}
+}
+
+func BenchmarkSubtractBackground(b *testing.B) {
+	frames := make([]image.Image, 5)
+	for i := range frames {
+		frames[i] = testdataBranchesPNG
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		SubtractBackground(frames, BackgroundMedian)
+	}
+}
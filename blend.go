@@ -0,0 +1,238 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// BlendMode is the blend mode of Blend, it specifies how the colors of the source layer
+// are mixed with the colors of the destination below it, like the layer blend modes
+// of the image editors.
+type BlendMode int
+
+// Blend modes.
+const (
+	// BlendNormal draws the source over the destination, like Overlay.
+	BlendNormal BlendMode = iota
+
+	// BlendMultiply multiplies the colors, the result is darker, e.g. for shadows and textures.
+	BlendMultiply
+
+	// BlendScreen multiplies the inverted colors, the result is lighter, e.g. for glows.
+	BlendScreen
+
+	// BlendOverlay multiplies the dark colors and screens the light colors of the destination.
+	BlendOverlay
+
+	// BlendDarken keeps the darker of the colors.
+	BlendDarken
+
+	// BlendLighten keeps the lighter of the colors.
+	BlendLighten
+
+	// BlendColorDodge brightens the destination by the source.
+	BlendColorDodge
+
+	// BlendColorBurn darkens the destination by the source.
+	BlendColorBurn
+
+	// BlendHardLight multiplies the dark colors and screens the light colors of the source.
+	BlendHardLight
+
+	// BlendSoftLight darkens or lightens the destination by the source, a softer BlendHardLight.
+	BlendSoftLight
+
+	// BlendDifference subtracts the darker of the colors from the lighter.
+	BlendDifference
+
+	// BlendExclusion is a BlendDifference of lower contrast.
+	BlendExclusion
+
+	// BlendHue keeps the hue of the source and the saturation and luminosity of the destination.
+	BlendHue
+
+	// BlendSaturation keeps the saturation of the source and the hue and luminosity of the destination.
+	BlendSaturation
+
+	// BlendColor keeps the hue and saturation of the source and the luminosity of the destination,
+	// e.g. to colorize a grayscale image.
+	BlendColor
+
+	// BlendLuminosity keeps the luminosity of the source and the hue and saturation of the destination.
+	BlendLuminosity
+)
+
+// Blend draws the src image over the dst image at the given position mixing their colors
+// with the blend mode and returns the combined image of the size of dst. Opacity is the
+// opacity of the src image, from 0.0 to 1.0. The blend modes follow the W3C Compositing and
+// Blending specification: the colors are mixed where both images are opaque and the source
+// is drawn over the destination otherwise. An invalid mode returns a copy of dst.
+//
+// Examples:
+//
+//	// Darken the photo by the paper texture.
+//	dstImage := imaging.Blend(photo, texture, image.Pt(0, 0), imaging.BlendMultiply, 1.0)
+//
+//	// Tint the product shot with the brand color.
+//	tint := imaging.New(shot.Bounds().Dx(), shot.Bounds().Dy(), brandColor)
+//	dstImage = imaging.Blend(shot, tint, image.Pt(0, 0), imaging.BlendColor, 0.6)
+func Blend(dst, src image.Image, pos image.Point, mode BlendMode, opacity float64) *image.NRGBA {
+	res := Clone(dst)
+	if mode < BlendNormal || mode > BlendLuminosity {
+		return res
+	}
+	opacity = math.Min(math.Max(opacity, 0.0), 1.0)
+	pos = pos.Sub(dst.Bounds().Min)
+	srcRect := image.Rectangle{Min: pos, Max: pos.Add(src.Bounds().Size())}
+	interRect := srcRect.Intersect(res.Bounds())
+	if interRect.Empty() {
+		return res
+	}
+
+	s := newScanner(src)
+	parallel(interRect.Min.Y, interRect.Max.Y, func(ys <-chan int) {
+		scanLine := make([]uint8, interRect.Dx()*4)
+		for y := range ys {
+			s.scan(interRect.Min.X-srcRect.Min.X, y-srcRect.Min.Y, interRect.Max.X-srcRect.Min.X, y-srcRect.Min.Y+1, scanLine)
+			i := y*res.Stride + interRect.Min.X*4
+			for x := 0; x < interRect.Dx(); x++ {
+				d := res.Pix[i+x*4 : i+x*4+4 : i+x*4+4]
+				p := scanLine[x*4 : x*4+4 : x*4+4]
+				as := float64(p[3]) / 255 * opacity
+				if as == 0 {
+					continue
+				}
+				ab := float64(d[3]) / 255
+				cs := [3]float64{float64(p[0]) / 255, float64(p[1]) / 255, float64(p[2]) / 255}
+				cb := [3]float64{float64(d[0]) / 255, float64(d[1]) / 255, float64(d[2]) / 255}
+				mixed := blendColors(mode, cb, cs)
+				ao := as + ab*(1-as)
+				for c := 0; c < 3; c++ {
+					// The source mixed with the backdrop where it is opaque, drawn over it.
+					v := (1-ab)*cs[c] + ab*mixed[c]
+					d[c] = clamp((v*as + cb[c]*ab*(1-as)) / ao * 255)
+				}
+				d[3] = clamp(ao * 255)
+			}
+		}
+	})
+	return res
+}
+
+// blendColors returns the mix of the backdrop and the source colors from 0 to 1.
+func blendColors(mode BlendMode, cb, cs [3]float64) [3]float64 {
+	switch mode {
+	case BlendHue:
+		return setLum(setSat(cs, sat(cb)), lum(cb))
+	case BlendSaturation:
+		return setLum(setSat(cb, sat(cs)), lum(cb))
+	case BlendColor:
+		return setLum(cs, lum(cb))
+	case BlendLuminosity:
+		return setLum(cb, lum(cs))
+	}
+	var res [3]float64
+	for c := range res {
+		res[c] = blendChannel(mode, cb[c], cs[c])
+	}
+	return res
+}
+
+// blendChannel returns the mix of the backdrop and the source channel values of the
+// separable blend modes.
+func blendChannel(mode BlendMode, b, s float64) float64 {
+	switch mode {
+	case BlendMultiply:
+		return b * s
+	case BlendScreen:
+		return b + s - b*s
+	case BlendOverlay:
+		return blendChannel(BlendHardLight, s, b)
+	case BlendDarken:
+		return math.Min(b, s)
+	case BlendLighten:
+		return math.Max(b, s)
+	case BlendColorDodge:
+		switch {
+		case b == 0:
+			return 0
+		case s == 1:
+			return 1
+		}
+		return math.Min(1, b/(1-s))
+	case BlendColorBurn:
+		switch {
+		case b == 1:
+			return 1
+		case s == 0:
+			return 0
+		}
+		return 1 - math.Min(1, (1-b)/s)
+	case BlendHardLight:
+		if s <= 0.5 {
+			return b * 2 * s
+		}
+		return blendChannel(BlendScreen, b, 2*s-1)
+	case BlendSoftLight:
+		if s <= 0.5 {
+			return b - (1-2*s)*b*(1-b)
+		}
+		d := math.Sqrt(b)
+		if b <= 0.25 {
+			d = ((16*b-12)*b + 4) * b
+		}
+		return b + (2*s-1)*(d-b)
+	case BlendDifference:
+		return math.Abs(b - s)
+	case BlendExclusion:
+		return b + s - 2*b*s
+	}
+	return s
+}
+
+func lum(c [3]float64) float64 {
+	return 0.3*c[0] + 0.59*c[1] + 0.11*c[2]
+}
+
+// setLum sets the luminosity of the color keeping its hue and clipping it into the gamut.
+func setLum(c [3]float64, l float64) [3]float64 {
+	d := l - lum(c)
+	c = [3]float64{c[0] + d, c[1] + d, c[2] + d}
+	l = lum(c)
+	n := math.Min(c[0], math.Min(c[1], c[2]))
+	x := math.Max(c[0], math.Max(c[1], c[2]))
+	for i := range c {
+		if n < 0 {
+			c[i] = l + (c[i]-l)*l/(l-n)
+		}
+		if x > 1 {
+			c[i] = l + (c[i]-l)*(1-l)/(x-l)
+		}
+	}
+	return c
+}
+
+func sat(c [3]float64) float64 {
+	return math.Max(c[0], math.Max(c[1], c[2])) - math.Min(c[0], math.Min(c[1], c[2]))
+}
+
+// setSat sets the saturation of the color keeping its hue.
+func setSat(c [3]float64, s float64) [3]float64 {
+	// The indices of the smallest, middle and largest channels.
+	lo, mid, hi := 0, 1, 2
+	if c[lo] > c[mid] {
+		lo, mid = mid, lo
+	}
+	if c[mid] > c[hi] {
+		mid, hi = hi, mid
+	}
+	if c[lo] > c[mid] {
+		lo, mid = mid, lo
+	}
+	var res [3]float64
+	if c[hi] > c[lo] {
+		res[mid] = (c[mid] - c[lo]) * s / (c[hi] - c[lo])
+		res[hi] = s
+	}
+	return res
+}
@@ -0,0 +1,104 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBlend(t *testing.T) {
+	dst := New(1, 1, color.NRGBA{100, 200, 255, 255})
+	src := New(1, 1, color.NRGBA{200, 100, 50, 255})
+	testCases := []struct {
+		name string
+		mode BlendMode
+		want color.NRGBA
+	}{
+		{"Normal", BlendNormal, color.NRGBA{200, 100, 50, 255}},
+		{"Multiply", BlendMultiply, color.NRGBA{78, 78, 50, 255}},
+		{"Screen", BlendScreen, color.NRGBA{222, 222, 255, 255}},
+		{"Overlay", BlendOverlay, color.NRGBA{157, 188, 255, 255}},
+		{"Darken", BlendDarken, color.NRGBA{100, 100, 50, 255}},
+		{"Lighten", BlendLighten, color.NRGBA{200, 200, 255, 255}},
+		{"ColorDodge", BlendColorDodge, color.NRGBA{255, 255, 255, 255}},
+		{"ColorBurn", BlendColorBurn, color.NRGBA{57, 115, 255, 255}},
+		{"HardLight", BlendHardLight, color.NRGBA{188, 157, 100, 255}},
+		{"SoftLight", BlendSoftLight, color.NRGBA{134, 191, 255, 255}},
+		{"Difference", BlendDifference, color.NRGBA{100, 100, 205, 255}},
+		{"Exclusion", BlendExclusion, color.NRGBA{143, 143, 205, 255}},
+		{"invalid", BlendMode(100), color.NRGBA{100, 200, 255, 255}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Blend(dst, src, image.Pt(0, 0), tc.mode, 1.0)
+			if !compareNRGBA(got, New(1, 1, tc.want), 1) {
+				t.Fatalf("got %v want %v", got.NRGBAAt(0, 0), tc.want)
+			}
+		})
+	}
+}
+
+func TestBlendNonSeparable(t *testing.T) {
+	gray := New(1, 1, color.NRGBA{128, 128, 128, 255})
+	red := New(1, 1, color.NRGBA{200, 40, 40, 255})
+	testCases := []struct {
+		name     string
+		dst, src *image.NRGBA
+		mode     BlendMode
+		want     color.NRGBA
+	}{
+		// The gray keeps the luminosity of the red.
+		{"Saturation", red, gray, BlendSaturation, color.NRGBA{88, 88, 88, 255}},
+		{"Color", red, gray, BlendColor, color.NRGBA{88, 88, 88, 255}},
+		{"Luminosity", gray, red, BlendLuminosity, color.NRGBA{88, 88, 88, 255}},
+		// The red keeps the luminosity of the gray.
+		{"Hue of gray", red, gray, BlendHue, color.NRGBA{88, 88, 88, 255}},
+		{"Colorize", gray, red, BlendColor, color.NRGBA{240, 80, 80, 255}},
+		{"Luminosity of gray", red, gray, BlendLuminosity, color.NRGBA{240, 80, 80, 255}},
+		{"Hue", gray, red, BlendHue, color.NRGBA{128, 128, 128, 255}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Blend(tc.dst, tc.src, image.Pt(0, 0), tc.mode, 1.0)
+			if !compareNRGBA(got, New(1, 1, tc.want), 1) {
+				t.Fatalf("got %v want %v", got.NRGBAAt(0, 0), tc.want)
+			}
+		})
+	}
+}
+
+func TestBlendAlpha(t *testing.T) {
+	pos := image.Pt(10, 20)
+	for _, opacity := range []float64{0, 0.3, 1} {
+		got := Blend(testdataBranchesJPG, testdataFlowersSmallPNG, pos, BlendNormal, opacity)
+		want := Overlay(testdataBranchesJPG, testdataFlowersSmallPNG, pos, opacity)
+		if !compareNRGBA(got, want, 1) {
+			t.Fatalf("opacity %v: the result differs from Overlay", opacity)
+		}
+	}
+
+	// The source is drawn as is over the transparent pixels, and the pixels outside of
+	// it are kept.
+	dst := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 1),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x00, 0x00, 0x00, 0x00, 0x10, 0x20, 0x30, 0xff},
+	}
+	src := New(1, 1, color.NRGBA{200, 100, 50, 128})
+	got := Blend(dst, src, image.Pt(0, 0), BlendMultiply, 1.0)
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 1),
+		Stride: 2 * 4,
+		Pix:    []uint8{200, 100, 50, 128, 0x10, 0x20, 0x30, 0xff},
+	}
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("got %v want %v", got.Pix, want.Pix)
+	}
+}
+
+func BenchmarkBlend(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Blend(testdataBranchesJPG, testdataFlowersSmallPNG, image.Pt(20, 20), BlendSoftLight, 0.8)
+	}
+}
@@ -0,0 +1,345 @@
+package imaging
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+
+	"golang.org/x/image/bmp"
+)
+
+// This file implements a BMP decoder and encoder. Unlike the golang.org/x/image/bmp package
+// they support 1, 4 and 16-bit images, bit field masks and the alpha channel.
+
+var errInvalidBMP = errors.New("imaging: invalid BMP data")
+
+// BMP compression types.
+const (
+	bmpRGB            = 0
+	bmpBitFields      = 3
+	bmpAlphaBitFields = 6
+)
+
+// bmpHeader is the header of a BMP image including its palette.
+type bmpHeader struct {
+	width, height int
+	topDown       bool
+	bpp           int
+	masks         [4]uint32 // Channel masks of the 16 and 32-bit images.
+	pal           color.Palette
+	offset        int // Offset of the pixel data.
+	read          int // Number of bytes of the header.
+}
+
+// colorModel returns the color model of the decoded image.
+func (h *bmpHeader) colorModel() color.Model {
+	switch {
+	case h.pal != nil:
+		return h.pal
+	case h.masks[3] != 0:
+		return color.NRGBAModel
+	}
+	return color.RGBAModel
+}
+
+// readBMPHeader reads the header and the palette of an uncompressed BMP image from r.
+func readBMPHeader(r io.Reader) (*bmpHeader, error) {
+	var b [14 + 124]byte
+	if _, err := io.ReadFull(r, b[:18]); err != nil {
+		return nil, errInvalidBMP
+	}
+	if string(b[:2]) != "BM" {
+		return nil, errInvalidBMP
+	}
+	offset := int(binary.LittleEndian.Uint32(b[10:]))
+	infoLen := int(binary.LittleEndian.Uint32(b[14:]))
+	switch infoLen {
+	case 40, 52, 56, 108, 124:
+	default:
+		return nil, bmp.ErrUnsupported
+	}
+	if _, err := io.ReadFull(r, b[18:14+infoLen]); err != nil {
+		return nil, errInvalidBMP
+	}
+	read := 14 + infoLen
+
+	width := int(int32(binary.LittleEndian.Uint32(b[18:])))
+	height := int(int32(binary.LittleEndian.Uint32(b[22:])))
+	topDown := height < 0
+	if topDown {
+		height = -height
+	}
+	planes := binary.LittleEndian.Uint16(b[26:])
+	bpp := int(binary.LittleEndian.Uint16(b[28:]))
+	compression := binary.LittleEndian.Uint32(b[30:])
+	colorsUsed := int(binary.LittleEndian.Uint32(b[46:]))
+	if width < 0 || planes != 1 || width*height > 1<<28 {
+		return nil, bmp.ErrUnsupported
+	}
+
+	var masks [4]uint32
+	switch compression {
+	case bmpRGB:
+		switch bpp {
+		case 16:
+			masks = [4]uint32{0x7c00, 0x03e0, 0x001f, 0}
+		case 32:
+			masks = [4]uint32{0xff0000, 0xff00, 0xff, 0}
+			// The alpha channel of 32-bit images is used with the newer headers only.
+			if infoLen > 40 {
+				masks[3] = 0xff000000
+			}
+		}
+	case bmpBitFields, bmpAlphaBitFields:
+		if bpp != 16 && bpp != 32 {
+			return nil, bmp.ErrUnsupported
+		}
+		n := 3
+		if compression == bmpAlphaBitFields {
+			n = 4
+		}
+		if infoLen == 40 {
+			// The masks follow the header.
+			if _, err := io.ReadFull(r, b[54:54+n*4]); err != nil {
+				return nil, errInvalidBMP
+			}
+			read += n * 4
+		} else if infoLen >= 56 {
+			n = 4
+		}
+		for i := 0; i < n; i++ {
+			masks[i] = binary.LittleEndian.Uint32(b[54+i*4:])
+		}
+	default:
+		return nil, bmp.ErrUnsupported
+	}
+
+	var pal color.Palette
+	switch bpp {
+	case 1, 4, 8:
+		if colorsUsed == 0 || colorsUsed > 1<<bpp {
+			colorsUsed = 1 << bpp
+		}
+		p := make([]byte, colorsUsed*4)
+		if _, err := io.ReadFull(r, p); err != nil {
+			return nil, errInvalidBMP
+		}
+		read += len(p)
+		// Indices beyond the palette are black.
+		pal = make(color.Palette, 1<<bpp)
+		for i := range pal {
+			pal[i] = color.RGBA{0, 0, 0, 0xff}
+			if i < colorsUsed {
+				pal[i] = color.RGBA{p[i*4+2], p[i*4+1], p[i*4], 0xff}
+			}
+		}
+	case 16, 24, 32:
+	default:
+		return nil, bmp.ErrUnsupported
+	}
+
+	return &bmpHeader{
+		width:   width,
+		height:  height,
+		topDown: topDown,
+		bpp:     bpp,
+		masks:   masks,
+		pal:     pal,
+		offset:  offset,
+		read:    read,
+	}, nil
+}
+
+// decodeBMP reads an uncompressed BMP image from r. Paletted images (1, 4 and 8 bits per pixel)
+// are returned as *image.Paletted, images with an alpha channel as *image.NRGBA and
+// other images as *image.RGBA.
+func decodeBMP(r io.Reader) (image.Image, error) {
+	h, err := readBMPHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	width, height, topDown, bpp, masks, pal := h.width, h.height, h.topDown, h.bpp, h.masks, h.pal
+	offset, read := h.offset, h.read
+
+	if offset < read {
+		return nil, errInvalidBMP
+	}
+	if _, err := io.CopyN(io.Discard, r, int64(offset-read)); err != nil {
+		return nil, errInvalidBMP
+	}
+
+	rect := image.Rect(0, 0, width, height)
+	var dst image.Image
+	var pix []uint8
+	var stride int
+	switch {
+	case pal != nil:
+		m := image.NewPaletted(rect, pal)
+		dst, pix, stride = m, m.Pix, m.Stride
+	case masks[3] != 0:
+		m := newNRGBA(rect)
+		dst, pix, stride = m, m.Pix, m.Stride
+	default:
+		m := image.NewRGBA(rect)
+		dst, pix, stride = m, m.Pix, m.Stride
+	}
+
+	rowLen := (bpp*width + 31) / 32 * 4
+	row := make([]byte, rowLen)
+	alphaSeen := false
+	for i := 0; i < height; i++ {
+		if _, err := io.ReadFull(r, row); err != nil {
+			return nil, errInvalidBMP
+		}
+		y := height - 1 - i
+		if topDown {
+			y = i
+		}
+		d := pix[y*stride:]
+		switch bpp {
+		case 1, 4, 8:
+			perByte := 8 / bpp
+			for x := 0; x < width; x++ {
+				shift := uint(8 - bpp - x%perByte*bpp)
+				d[x] = row[x/perByte] >> shift & (1<<bpp - 1)
+			}
+		case 24:
+			for x := 0; x < width; x++ {
+				s := row[x*3 : x*3+3 : x*3+3]
+				d[x*4+0] = s[2]
+				d[x*4+1] = s[1]
+				d[x*4+2] = s[0]
+				d[x*4+3] = 0xff
+			}
+		default:
+			for x := 0; x < width; x++ {
+				var v uint32
+				if bpp == 16 {
+					v = uint32(binary.LittleEndian.Uint16(row[x*2:]))
+				} else {
+					v = binary.LittleEndian.Uint32(row[x*4:])
+				}
+				for c := 0; c < 4; c++ {
+					d[x*4+c] = bmpChannel(v, masks[c])
+				}
+				if masks[3] == 0 {
+					d[x*4+3] = 0xff
+				} else if d[x*4+3] != 0 {
+					alphaSeen = true
+				}
+			}
+		}
+	}
+
+	// Many encoders leave the alpha channel zeroed, such images are opaque.
+	if m, ok := dst.(*image.NRGBA); ok && !alphaSeen {
+		for i := 3; i < len(m.Pix); i += 4 {
+			m.Pix[i] = 0xff
+		}
+	}
+	return dst, nil
+}
+
+// bmpChannel extracts the channel with the given bit mask from the pixel value
+// and scales it to 8 bits.
+func bmpChannel(v, mask uint32) uint8 {
+	if mask == 0 {
+		return 0
+	}
+	shift := bits.TrailingZeros32(mask)
+	max := uint64(mask >> shift)
+	return uint8((uint64(v&mask>>shift)*255 + max/2) / max)
+}
+
+// encodeBMP writes the image to w as a BMP with the given number of bits per pixel:
+// 8 (paletted), 16 (5 bits per channel), 24 or 32 (with alpha).
+func encodeBMP(w io.Writer, img image.Image, depth int) error {
+	var pal color.Palette
+	var indexed *image.Paletted
+	switch depth {
+	case 8:
+		indexed = convertPNG(img, PNGColorPaletted, 8).(*image.Paletted)
+		pal = indexed.Palette
+	case 16, 24, 32:
+	default:
+		return errors.New("imaging: unsupported BMP bit depth")
+	}
+
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	infoLen := 40
+	compression := uint32(bmpRGB)
+	if depth == 32 {
+		// The BITMAPV4HEADER with an alpha mask makes the decoders use the alpha channel.
+		infoLen = 108
+		compression = bmpBitFields
+	}
+	rowLen := (depth*width + 31) / 32 * 4
+	pixOffset := 14 + infoLen + len(pal)*4
+	fileSize := pixOffset + rowLen*height
+
+	bw := bufio.NewWriter(w)
+	le := binary.LittleEndian
+	header := make([]byte, 14+infoLen)
+	copy(header, "BM")
+	le.PutUint32(header[2:], uint32(fileSize))
+	le.PutUint32(header[10:], uint32(pixOffset))
+	le.PutUint32(header[14:], uint32(infoLen))
+	le.PutUint32(header[18:], uint32(width))
+	le.PutUint32(header[22:], uint32(height))
+	le.PutUint16(header[26:], 1)
+	le.PutUint16(header[28:], uint16(depth))
+	le.PutUint32(header[30:], compression)
+	le.PutUint32(header[34:], uint32(rowLen*height))
+	le.PutUint32(header[38:], 2835) // 72 DPI.
+	le.PutUint32(header[42:], 2835)
+	le.PutUint32(header[46:], uint32(len(pal)))
+	if depth == 32 {
+		le.PutUint32(header[54:], 0x00ff0000)
+		le.PutUint32(header[58:], 0x0000ff00)
+		le.PutUint32(header[62:], 0x000000ff)
+		le.PutUint32(header[66:], 0xff000000)
+		le.PutUint32(header[70:], 0x73524742) // The sRGB color space.
+	}
+	bw.Write(header)
+	for _, c := range pal {
+		n := color.NRGBAModel.Convert(c).(color.NRGBA)
+		bw.Write([]byte{n.B, n.G, n.R, 0})
+	}
+
+	src := newScanner(img)
+	scanLine := make([]uint8, width*4)
+	row := make([]byte, rowLen)
+	for y := height - 1; y >= 0; y-- {
+		switch depth {
+		case 8:
+			copy(row, indexed.Pix[y*indexed.Stride:y*indexed.Stride+width])
+		default:
+			src.scan(0, y, width, y+1, scanLine)
+			for x := 0; x < width; x++ {
+				s := scanLine[x*4 : x*4+4 : x*4+4]
+				switch depth {
+				case 16:
+					le.PutUint16(row[x*2:], uint16(s[0]>>3)<<10|uint16(s[1]>>3)<<5|uint16(s[2]>>3))
+				case 24:
+					row[x*3+0] = s[2]
+					row[x*3+1] = s[1]
+					row[x*3+2] = s[0]
+				case 32:
+					row[x*4+0] = s[2]
+					row[x*4+1] = s[1]
+					row[x*4+2] = s[0]
+					row[x*4+3] = s[3]
+				}
+			}
+		}
+		if _, err := bw.Write(row); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,228 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"testing"
+
+	"golang.org/x/image/bmp"
+)
+
+// bmpFile builds a BMP file with a BITMAPINFOHEADER, the extra data (masks or palette)
+// following the header and the pixel rows.
+func bmpFile(width, height, bpp int, compression uint32, extra []byte, rows ...[]byte) []byte {
+	le := binary.LittleEndian
+	h := make([]byte, 54)
+	copy(h, "BM")
+	pixOffset := 54 + len(extra)
+	le.PutUint32(h[10:], uint32(pixOffset))
+	le.PutUint32(h[14:], 40)
+	le.PutUint32(h[18:], uint32(width))
+	le.PutUint32(h[22:], uint32(height))
+	le.PutUint16(h[26:], 1)
+	le.PutUint16(h[28:], uint16(bpp))
+	le.PutUint32(h[30:], compression)
+	if bpp <= 8 {
+		le.PutUint32(h[46:], uint32(len(extra)/4))
+	}
+	data := append(h, extra...)
+	for _, row := range rows {
+		data = append(data, row...)
+	}
+	le.PutUint32(data[2:], uint32(len(data)))
+	return data
+}
+
+func TestDecodeBMP(t *testing.T) {
+	palette := []byte{0x00, 0x00, 0xff, 0x00, 0xff, 0x00, 0x00, 0x00}
+	testCases := []struct {
+		name string
+		data []byte
+		want *image.NRGBA
+	}{
+		{
+			"1-bit",
+			bmpFile(3, 2, 1, bmpRGB, palette, []byte{0xa0, 0, 0, 0}, []byte{0x40, 0, 0, 0}),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0xff, 0x00, 0x00, 0xff, 0x00, 0x00, 0xff, 0xff, 0xff, 0x00, 0x00, 0xff,
+					0x00, 0x00, 0xff, 0xff, 0xff, 0x00, 0x00, 0xff, 0x00, 0x00, 0xff, 0xff,
+				},
+			},
+		},
+		{
+			"4-bit",
+			bmpFile(3, 1, 4, bmpRGB, palette, []byte{0x10, 0x20, 0, 0}),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0xff, 0xff, 0xff, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"16-bit 555",
+			bmpFile(2, 1, 16, bmpRGB, nil, []byte{0x00, 0x7c, 0x1f, 0x00}),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0xff, 0x00, 0x00, 0xff, 0x00, 0x00, 0xff, 0xff,
+				},
+			},
+		},
+		{
+			"16-bit 565",
+			bmpFile(2, 1, 16, bmpBitFields, []byte{0x00, 0xf8, 0, 0, 0xe0, 0x07, 0, 0, 0x1f, 0, 0, 0}, []byte{0xe0, 0x07, 0x10, 0x84}),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0xff, 0x00, 0xff, 0x84, 0x82, 0x84, 0xff,
+				},
+			},
+		},
+		{
+			"32-bit alpha bitfields",
+			bmpFile(1, 1, 32, bmpAlphaBitFields, []byte{0, 0, 0xff, 0, 0, 0xff, 0, 0, 0xff, 0, 0, 0, 0, 0, 0, 0xff}, []byte{0x30, 0x20, 0x10, 0x80}),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix:    []uint8{0x10, 0x20, 0x30, 0x80},
+			},
+		},
+		{
+			"32-bit zero alpha",
+			bmpFile(1, 1, 32, bmpAlphaBitFields, []byte{0, 0, 0xff, 0, 0, 0xff, 0, 0, 0xff, 0, 0, 0, 0, 0, 0, 0xff}, []byte{0x30, 0x20, 0x10, 0x00}),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 1 * 4,
+				Pix:    []uint8{0x10, 0x20, 0x30, 0xff},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := Decode(bytes.NewReader(tc.data))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if got := Clone(img); !compareNRGBA(got, tc.want, 0) {
+				t.Fatalf("got result %#v want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeBMPTopDown(t *testing.T) {
+	data := bmpFile(1, 2, 24, bmpRGB, nil, []byte{0, 0, 0xff, 0}, []byte{0xff, 0, 0, 0})
+	binary.LittleEndian.PutUint32(data[22:], uint32(0xffffffff-1)) // Height -2.
+	img, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	want := []uint8{0xff, 0x00, 0x00, 0xff, 0x00, 0x00, 0xff, 0xff}
+	if got := Clone(img); !compareBytes(got.Pix, want, 0) {
+		t.Fatalf("got pixels %v want %v", got.Pix, want)
+	}
+}
+
+func TestDecodeBMPFails(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated header", []byte("BM\x00\x00")},
+		{"truncated pixels", bmpFile(4, 4, 24, bmpRGB, nil, []byte{1, 2, 3})},
+		{"rle", bmpFile(1, 1, 8, 1, make([]byte, 1024), []byte{0, 0, 0, 0})},
+		{"bad depth", bmpFile(1, 1, 7, bmpRGB, nil, []byte{0, 0, 0, 0})},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Decode(bytes.NewReader(tc.data)); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestEncodeBMPBitDepth(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 3, 2),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0xff, 0x00, 0x00, 0xff, 0x00, 0xff, 0x00, 0x80, 0x40, 0x40, 0x40, 0xff,
+			0x10, 0x20, 0x30, 0x20, 0xf8, 0xf8, 0xf8, 0xff, 0x00, 0x00, 0xff, 0xff,
+		},
+	}
+	opaque := Clone(src)
+	for i := 3; i < len(opaque.Pix); i += 4 {
+		opaque.Pix[i] = 0xff
+	}
+
+	testCases := []struct {
+		depth int
+		delta int
+		want  *image.NRGBA
+	}{
+		{8, 0, opaque},
+		{16, 7, opaque},
+		{24, 0, opaque},
+		{32, 0, src},
+	}
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		if err := Encode(&buf, src, BMP, BMPBitDepth(tc.depth)); err != nil {
+			t.Fatalf("Encode %d: %v", tc.depth, err)
+		}
+		if got := binary.LittleEndian.Uint16(buf.Bytes()[28:]); int(got) != tc.depth {
+			t.Fatalf("got bit depth %d want %d", got, tc.depth)
+		}
+		data := buf.Bytes()
+		img, err := Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Decode %d: %v", tc.depth, err)
+		}
+		if got := Clone(img); !compareNRGBA(got, tc.want, tc.delta) {
+			t.Fatalf("depth %d: got result %#v want %#v", tc.depth, got, tc.want)
+		}
+		if tc.depth == 16 {
+			continue
+		}
+		// The golang.org/x/image/bmp decoder must read the files too.
+		img, err = bmp.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("bmp.Decode %d: %v", tc.depth, err)
+		}
+		if got := Clone(img); !compareNRGBA(got, tc.want, tc.delta) {
+			t.Fatalf("depth %d: got bmp.Decode result %#v want %#v", tc.depth, got, tc.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, src, BMP, BMPBitDepth(12)); err == nil {
+		t.Fatalf("expected error for unsupported bit depth")
+	}
+}
+
+func TestEncodeBMPGray(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 5, 3))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i * 17)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, src, BMP, BMPBitDepth(8)); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	img, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got, want := Clone(img), Clone(src); !compareNRGBA(got, want, 0) {
+		t.Fatalf("got result %#v want %#v", got, want)
+	}
+}
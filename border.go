@@ -0,0 +1,178 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// BorderStyle specifies the style of the border added by AddBorder.
+type BorderStyle int
+
+// Border styles.
+const (
+	// BorderSquare is a plain border with square corners.
+	BorderSquare BorderStyle = iota
+
+	// BorderRounded is a border with the outer corners rounded with a radius of twice
+	// the border width, and the corners of the image rounded with a radius of the border
+	// width, so the border is as wide along the corners. The outside of the rounded corners
+	// is transparent.
+	BorderRounded
+
+	// BorderShadow is a soft drop shadow of the color, cast down and to the right by a third
+	// of the border width and blurred over the border width. The shadow follows the alpha
+	// channel of the image, so the images with the rounded corners or transparent backgrounds
+	// cast the shadow of their shapes. The rest of the border is transparent.
+	BorderShadow
+)
+
+// AddBorder adds a border of the given width and color around the image and returns
+// the framed image, which is 2*width pixels wider and higher than the image.
+// The styles can be combined by adding the borders one after another, e.g. the card-style
+// thumbnails are framed with a rounded border and then with a shadow.
+// The width <= 0 returns a copy of the image.
+//
+// Example:
+//
+//	// A thin black frame.
+//	dstImage := imaging.AddBorder(srcImage, 2, color.Black, imaging.BorderSquare)
+//
+//	// A card with a white rounded border and a drop shadow.
+//	card := imaging.AddBorder(thumbnail, 8, color.White, imaging.BorderRounded)
+//	card = imaging.AddBorder(card, 12, color.NRGBA{0, 0, 0, 96}, imaging.BorderShadow)
+func AddBorder(img image.Image, width int, c color.Color, style BorderStyle) *image.NRGBA {
+	if width <= 0 {
+		return Clone(img)
+	}
+	if style == BorderShadow {
+		return addShadow(img, width, c)
+	}
+
+	radius := 0.0
+	if style == BorderRounded {
+		radius = float64(width)
+	}
+	cc := color.NRGBAModel.Convert(c).(color.NRGBA)
+	ca := float64(cc.A) / 255
+	border := [4]float64{float64(cc.R) * ca, float64(cc.G) * ca, float64(cc.B) * ca, float64(cc.A)}
+
+	src := newScanner(img)
+	outer := image.Rect(0, 0, src.w+2*width, src.h+2*width)
+	inner := image.Rect(width, width, width+src.w, width+src.h)
+	dst := newNRGBA(outer)
+	parallel(0, outer.Max.Y, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			if y >= inner.Min.Y && y < inner.Max.Y {
+				src.scan(0, y-width, src.w, y-width+1, scanLine)
+			}
+			for x := 0; x < outer.Max.X; x++ {
+				px, py := float64(x)+0.5, float64(y)+0.5
+				// The image pixel is blended with the border along the rounded corners
+				// of the image, and the result is cut along the rounded outer corners.
+				var p [4]float64
+				if ci := roundedRectCoverage(inner, radius, px, py); ci > 0 {
+					s := scanLine[(x-width)*4 : (x-width)*4+4]
+					sa := float64(s[3]) / 255
+					for k := 0; k < 3; k++ {
+						p[k] = float64(s[k]) * sa * ci
+					}
+					p[3] = float64(s[3]) * ci
+					for k := range p {
+						p[k] += border[k] * (1 - ci)
+					}
+				} else {
+					p = border
+				}
+				co := roundedRectCoverage(outer, 2*radius, px, py)
+				if a := p[3] * co; a > 0 {
+					d := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4 : y*dst.Stride+x*4+4]
+					d[0] = clamp(p[0] * 255 / p[3])
+					d[1] = clamp(p[1] * 255 / p[3])
+					d[2] = clamp(p[2] * 255 / p[3])
+					d[3] = clamp(a)
+				}
+			}
+		}
+	})
+	return dst
+}
+
+// roundedRectCoverage returns the part of the pixel centered at (px, py) covered by
+// the rectangle with the corners rounded with the radius, antialiased over a pixel.
+func roundedRectCoverage(r image.Rectangle, radius, px, py float64) float64 {
+	x0, y0 := float64(r.Min.X), float64(r.Min.Y)
+	x1, y1 := float64(r.Max.X), float64(r.Max.Y)
+	if px < x0 || px > x1 || py < y0 || py > y1 {
+		return 0
+	}
+	radius = math.Min(radius, math.Min(x1-x0, y1-y0)/2)
+	if radius <= 0 {
+		return 1
+	}
+	// The distance from the nearest center of a corner arc.
+	cx := math.Min(math.Max(px, x0+radius), x1-radius)
+	cy := math.Min(math.Max(py, y0+radius), y1-radius)
+	return math.Min(math.Max(radius-math.Hypot(px-cx, py-cy)+0.5, 0), 1)
+}
+
+// addShadow returns the image centered on a transparent canvas enlarged by the width
+// on each side, over its blurred shadow of the color.
+func addShadow(img image.Image, width int, c color.Color) *image.NRGBA {
+	cc := color.NRGBAModel.Convert(c).(color.NRGBA)
+	src := newScanner(img)
+	offset := width / 3
+	canvas := image.Rect(0, 0, src.w+2*width, src.h+2*width)
+
+	// The shadow is the alpha of the image in the color, moved by the offset from
+	// the position of the image.
+	shadow := newNRGBA(canvas)
+	parallel(0, canvas.Max.Y, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			for x := 0; x < canvas.Max.X; x++ {
+				d := shadow.Pix[y*shadow.Stride+x*4 : y*shadow.Stride+x*4+3 : y*shadow.Stride+x*4+3]
+				d[0], d[1], d[2] = cc.R, cc.G, cc.B
+			}
+			sy := y - width - offset
+			if sy < 0 || sy >= src.h {
+				continue
+			}
+			src.scan(0, sy, src.w, sy+1, scanLine)
+			for x := 0; x < src.w; x++ {
+				a := float64(scanLine[x*4+3]) * float64(cc.A) / 255
+				shadow.Pix[y*shadow.Stride+(x+width+offset)*4+3] = clamp(a)
+			}
+		}
+	})
+	dst := shadow
+	if sigma := float64(width) / 3; sigma >= 0.5 {
+		dst = Blur(shadow, sigma)
+		Release(shadow)
+	}
+
+	// The image is composited over the shadow.
+	parallel(0, src.h, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			for x := 0; x < src.w; x++ {
+				s := scanLine[x*4 : x*4+4 : x*4+4]
+				i := (y+width)*dst.Stride + (x+width)*4
+				d := dst.Pix[i : i+4 : i+4]
+				sa := float64(s[3]) / 255
+				da := float64(d[3]) / 255 * (1 - sa)
+				a := sa + da
+				if a == 0 {
+					continue
+				}
+				d[0] = clamp((float64(s[0])*sa + float64(d[0])*da) / a)
+				d[1] = clamp((float64(s[1])*sa + float64(d[1])*da) / a)
+				d[2] = clamp((float64(s[2])*sa + float64(d[2])*da) / a)
+				d[3] = clamp(a * 255)
+			}
+		}
+	})
+	return dst
+}
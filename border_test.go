@@ -0,0 +1,97 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestAddBorderSquare(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x10, 0x20, 0x30, 0xff, 0x40, 0x50, 0x60, 0x80},
+	}
+	got := AddBorder(src, 1, color.NRGBA{0xff, 0, 0, 0xff}, BorderSquare)
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 4, 3),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0xff,
+			0xff, 0x00, 0x00, 0xff, 0x10, 0x20, 0x30, 0xff, 0x40, 0x50, 0x60, 0x80, 0xff, 0x00, 0x00, 0xff,
+			0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0xff,
+		},
+	}
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("got result %#v want %#v", got, want)
+	}
+
+	for _, width := range []int{0, -1} {
+		if got := AddBorder(src, width, color.White, BorderRounded); !compareNRGBA(got, Clone(src), 0) {
+			t.Fatalf("got %#v for the width %d", got, width)
+		}
+	}
+}
+
+func TestAddBorderRounded(t *testing.T) {
+	red := color.NRGBA{0xff, 0, 0, 0xff}
+	white := color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	got := AddBorder(New(20, 20, red), 4, white, BorderRounded)
+	if got.Rect != image.Rect(0, 0, 28, 28) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	testCases := []struct {
+		x, y int
+		want color.NRGBA
+	}{
+		{0, 0, color.NRGBA{}},
+		{27, 27, color.NRGBA{}},
+		{14, 0, white},
+		{0, 14, white},
+		{4, 4, white},
+		{23, 4, white},
+		{6, 6, red},
+		{14, 14, red},
+		{4, 14, red},
+	}
+	for _, tc := range testCases {
+		if c := got.NRGBAAt(tc.x, tc.y); c != tc.want {
+			t.Errorf("got %v at (%d, %d) want %v", c, tc.x, tc.y, tc.want)
+		}
+	}
+	// The antialiased corners are partially transparent.
+	if a := got.NRGBAAt(2, 2).A; a == 0 || a == 0xff {
+		t.Errorf("got alpha %d at the corner", a)
+	}
+}
+
+func TestAddBorderShadow(t *testing.T) {
+	src := New(10, 10, color.NRGBA{0xff, 0, 0, 0xff})
+	src.SetNRGBA(0, 0, color.NRGBA{})
+	got := AddBorder(src, 6, color.Black, BorderShadow)
+	if got.Rect != image.Rect(0, 0, 22, 22) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	if c := got.NRGBAAt(11, 11); c != (color.NRGBA{0xff, 0, 0, 0xff}) {
+		t.Fatalf("got image pixel %v", c)
+	}
+	if c := got.NRGBAAt(0, 0); c.A != 0 {
+		t.Fatalf("got corner %v", c)
+	}
+	// The shadow is cast down and to the right.
+	below, above := got.NRGBAAt(11, 17), got.NRGBAAt(11, 4)
+	if below.R != 0 || below.A <= above.A || below.A < 0x40 {
+		t.Fatalf("got shadow %v below and %v above", below, above)
+	}
+	// The transparent pixel of the image shows the shadow.
+	if c := got.NRGBAAt(6, 6); c.R != 0 || c.A == 0 || c.A == 0xff {
+		t.Fatalf("got %v at the transparent pixel", c)
+	}
+}
+
+func BenchmarkAddBorder(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		AddBorder(testdataBranchesJPG, 12, color.White, BorderRounded)
+	}
+}
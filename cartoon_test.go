@@ -0,0 +1,87 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestCartoon(t *testing.T) {
+	step := New(40, 20, color.NRGBA{0x40, 0x70, 0x90, 0xff})
+	for y := 0; y < 20; y++ {
+		for x := 20; x < 40; x++ {
+			step.SetNRGBA(x, y, color.NRGBA{0xd0, 0xb0, 0x30, 0xff})
+		}
+	}
+
+	// The flat areas are quantized, the edge is outlined.
+	got := Cartoon(step)
+	if got.Rect != step.Rect {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	levels := posterizeLUT(6)
+	for _, x := range []int{2, 37} {
+		c := got.NRGBAAt(x, 10)
+		for _, v := range []uint8{c.R, c.G, c.B} {
+			if levels[v] != v {
+				t.Fatalf("got %v at (%d, 10) want the quantized color", c, x)
+			}
+		}
+	}
+	outline := 0
+	for x := 0; x < 40; x++ {
+		if c := got.NRGBAAt(x, 10); c == (color.NRGBA{0, 0, 0, 0xff}) {
+			if x < 17 || x > 22 {
+				t.Fatalf("got an outline at (%d, 10) far from the edge", x)
+			}
+			outline++
+		}
+	}
+	if outline != 2 {
+		t.Fatalf("got the outline %d pixels wide want 2", outline)
+	}
+
+	// The options disable the steps.
+	if got := Cartoon(step, CartoonSmoothing(0, 0), CartoonLevels(256), CartoonEdges(0, 0, 0)); !compareNRGBA(got, step, 0) {
+		t.Fatal("the disabled steps change the image")
+	}
+	want := Posterize(BilateralFilter(BilateralFilter(step, 2, 30), 2, 30), 6)
+	if got := Cartoon(step, CartoonEdges(15, 40, 0)); !compareNRGBA(got, want, 0) {
+		t.Fatal("got the image differing from the smoothed and quantized one")
+	}
+
+	// The color and width of the outlines.
+	got = Cartoon(step, CartoonEdges(15, 40, 3), CartoonEdgeColor(color.NRGBA{0xff, 0, 0, 0xff}))
+	outline = 0
+	for x := 0; x < 40; x++ {
+		if c := got.NRGBAAt(x, 10); c == (color.NRGBA{0xff, 0, 0, 0xff}) {
+			outline++
+		}
+	}
+	if outline != 3 {
+		t.Fatalf("got the outline %d pixels wide want 3", outline)
+	}
+
+	// The alpha channel is kept.
+	src := Clone(step)
+	for i := 3; i < len(src.Pix); i += 4 {
+		src.Pix[i] = 0x80
+	}
+	got = Cartoon(src)
+	for i := 3; i < len(got.Pix); i += 4 {
+		if got.Pix[i] != 0x80 {
+			t.Fatalf("got alpha %d want 128", got.Pix[i])
+		}
+	}
+
+	if got := Cartoon(image.NewNRGBA(image.Rect(0, 0, 0, 0))); got.Rect.Dx() != 0 {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
+
+func BenchmarkCartoon(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Cartoon(testdataBranchesJPG)
+	}
+}
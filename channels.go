@@ -0,0 +1,128 @@
+package imaging
+
+import (
+	"image"
+)
+
+// Channel is a channel of the NRGBA pixels, see SwapChannels.
+type Channel int
+
+// Channels of the NRGBA pixels.
+const (
+	ChannelR Channel = iota
+	ChannelG
+	ChannelB
+	ChannelA
+)
+
+// SplitChannels returns the red, green, blue and alpha channels of the image as grayscale
+// images. The color channels aren't premultiplied by the alpha, like the ones of image.NRGBA.
+//
+// Example:
+//
+//	channels := imaging.SplitChannels(srcImage)
+//	red := channels[imaging.ChannelR]
+func SplitChannels(img image.Image) [4]*image.Gray {
+	src := newScanner(img)
+	var channels [4]*image.Gray
+	for c := range channels {
+		channels[c] = image.NewGray(image.Rect(0, 0, src.w, src.h))
+	}
+	parallel(0, src.h, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			for c, ch := range channels {
+				d := ch.Pix[y*ch.Stride : y*ch.Stride+src.w]
+				for x := range d {
+					d[x] = scanLine[x*4+c]
+				}
+			}
+		}
+	})
+	return channels
+}
+
+// MergeChannels combines the red, green, blue and alpha channels, e.g. the ones of
+// SplitChannels, into an image. The nil color channels are black and the nil alpha channel
+// is opaque. The channels are aligned with their top-left corners and the image has the size
+// of their intersection, or of the empty image if all the channels are nil.
+//
+// Example:
+//
+//	// Make the translucent pixels either opaque or transparent.
+//	channels := imaging.SplitChannels(srcImage)
+//	dstImage := imaging.MergeChannels(channels[0], channels[1], channels[2], imaging.Threshold(channels[3], 128))
+func MergeChannels(r, g, b, a *image.Gray) *image.NRGBA {
+	channels := [4]*image.Gray{r, g, b, a}
+	w, h, found := 0, 0, false
+	for _, ch := range channels {
+		if ch == nil {
+			continue
+		}
+		if !found {
+			w, h, found = ch.Rect.Dx(), ch.Rect.Dy(), true
+		}
+		w, h = minint(w, ch.Rect.Dx()), minint(h, ch.Rect.Dy())
+	}
+
+	dst := newNRGBA(image.Rect(0, 0, w, h))
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			d := dst.Pix[y*dst.Stride : y*dst.Stride+w*4]
+			for c, ch := range channels {
+				if ch == nil {
+					v := uint8(0)
+					if c == int(ChannelA) {
+						v = 0xff
+					}
+					for x := 0; x < w; x++ {
+						d[x*4+c] = v
+					}
+					continue
+				}
+				s := ch.Pix[y*ch.Stride : y*ch.Stride+w]
+				for x, v := range s {
+					d[x*4+c] = v
+				}
+			}
+		}
+	})
+	return dst
+}
+
+// SwapChannels rearranges the channels of the image: the channel i of the resulting image
+// is the channel order[i] of the image. It fixes e.g. the BGR data of the camera SDKs
+// decoded as RGB, or copies a channel to the others. The channels aren't premultiplied.
+//
+// Example:
+//
+//	// BGRA to RGBA.
+//	dstImage := imaging.SwapChannels(srcImage, [4]imaging.Channel{imaging.ChannelB, imaging.ChannelG, imaging.ChannelR, imaging.ChannelA})
+//
+//	// The green channel as grayscale.
+//	dstImage = imaging.SwapChannels(srcImage, [4]imaging.Channel{imaging.ChannelG, imaging.ChannelG, imaging.ChannelG, imaging.ChannelA})
+func SwapChannels(img image.Image, order [4]Channel) *image.NRGBA {
+	for _, c := range order {
+		if c < ChannelR || c > ChannelA {
+			return Clone(img)
+		}
+	}
+	src := newScanner(img)
+	dst := newNRGBA(image.Rect(0, 0, src.w, src.h))
+	parallel(0, src.h, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			d := dst.Pix[y*dst.Stride : y*dst.Stride+src.w*4]
+			for x := 0; x < src.w; x++ {
+				s := scanLine[x*4 : x*4+4 : x*4+4]
+				d[x*4+0] = s[order[0]]
+				d[x*4+1] = s[order[1]]
+				d[x*4+2] = s[order[2]]
+				d[x*4+3] = s[order[3]]
+			}
+		}
+	})
+	return dst
+}
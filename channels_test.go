@@ -0,0 +1,72 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSplitMergeChannels(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x10, 0x20, 0x30, 0xff, 0x40, 0x50, 0x60, 0x80},
+	}
+	channels := SplitChannels(src)
+	want := [4]string{"\x10\x40", "\x20\x50", "\x30\x60", "\xff\x80"}
+	for c, ch := range channels {
+		if ch.Rect != image.Rect(0, 0, 2, 1) || string(ch.Pix) != want[c] {
+			t.Fatalf("got channel %d %v %v want %v", c, ch.Rect, ch.Pix, []byte(want[c]))
+		}
+	}
+	if got := MergeChannels(channels[0], channels[1], channels[2], channels[3]); !compareNRGBA(got, Clone(src), 0) {
+		t.Fatalf("got merged %#v", got)
+	}
+
+	// The nil color channels are black, the nil alpha opaque, the size is the intersection.
+	wide := image.NewGray(image.Rect(3, 3, 6, 5))
+	for i := range wide.Pix {
+		wide.Pix[i] = uint8(i)
+	}
+	got := MergeChannels(nil, wide, channels[2], nil)
+	wantPix := []uint8{0, 0, 0x30, 0xff, 0, 1, 0x60, 0xff}
+	if got.Rect != image.Rect(0, 0, 2, 1) || string(got.Pix) != string(wantPix) {
+		t.Fatalf("got %v %v want %v", got.Rect, got.Pix, wantPix)
+	}
+	if got := MergeChannels(nil, nil, nil, nil); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
+
+func TestSwapChannels(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 1),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x10, 0x20, 0x30, 0xff, 0x40, 0x50, 0x60, 0x80},
+	}
+	testCases := []struct {
+		name  string
+		order [4]Channel
+		want  []uint8
+	}{
+		{"identity", [4]Channel{ChannelR, ChannelG, ChannelB, ChannelA}, src.Pix},
+		{"BGR", [4]Channel{ChannelB, ChannelG, ChannelR, ChannelA}, []uint8{0x30, 0x20, 0x10, 0xff, 0x60, 0x50, 0x40, 0x80}},
+		{"green", [4]Channel{ChannelG, ChannelG, ChannelG, ChannelA}, []uint8{0x20, 0x20, 0x20, 0xff, 0x50, 0x50, 0x50, 0x80}},
+		{"alpha to red", [4]Channel{ChannelA, ChannelG, ChannelB, ChannelR}, []uint8{0xff, 0x20, 0x30, 0x10, 0x80, 0x50, 0x60, 0x40}},
+		{"invalid", [4]Channel{ChannelR, ChannelG, ChannelB, 4}, src.Pix},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := SwapChannels(src, tc.order)
+			if string(got.Pix) != string(tc.want) {
+				t.Fatalf("got %v want %v", got.Pix, tc.want)
+			}
+		})
+	}
+}
+
+func BenchmarkSplitChannels(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		SplitChannels(testdataBranchesJPG)
+	}
+}
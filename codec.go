@@ -0,0 +1,321 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strconv"
+)
+
+// This file implements the JPEG 2000 and JPEG XL formats. There are no Go implementations
+// of these codecs, so the images are converted by the reference command line codecs:
+// opj_decompress and opj_compress of OpenJPEG, djxl and cjxl of libjxl. The codecs are only
+// run by the programs built with the imaging_exec build tag, see runExternalCodec.
+
+// ErrCodecUnsupported means the external program needed to decode or encode
+// a JPEG 2000 or JPEG XL image is not installed, or the program was built without
+// the imaging_exec build tag, which enables running the external programs.
+var ErrCodecUnsupported = errors.New("imaging: JPEG 2000 requires opj_decompress and opj_compress (OpenJPEG), JPEG XL requires djxl and cjxl (libjxl) in PATH and the imaging_exec build tag")
+
+var (
+	errInvalidJP2 = errors.New("imaging: invalid JPEG 2000 data")
+	errInvalidJXL = errors.New("imaging: invalid JPEG XL data")
+)
+
+// Signatures of the JPEG 2000 and JPEG XL files and codestreams.
+const (
+	jp2Signature  = "\x00\x00\x00\x0cjP  \r\n\x87\n"
+	j2kSignature  = "\xff\x4f\xff\x51"
+	jxlSignature  = "\x00\x00\x00\x0cJXL \r\n\x87\n"
+	jxlCodestream = "\xff\x0a"
+)
+
+// externalCodecs are the programs converting the formats to and from PNG. The args functions
+// return the command line arguments of the programs given the input and the output file.
+var externalCodecs = map[Format]struct {
+	decoder    string
+	encoder    string
+	ext        string
+	decodeArgs func(in, out string) []string
+	encodeArgs func(in, out string, cfg encodeConfig) []string
+}{
+	JP2: {
+		decoder: "opj_decompress",
+		encoder: "opj_compress",
+		ext:     ".jp2",
+		decodeArgs: func(in, out string) []string {
+			return []string{"-quiet", "-i", in, "-o", out}
+		},
+		encodeArgs: func(in, out string, cfg encodeConfig) []string {
+			args := []string{"-i", in, "-o", out}
+			if cfg.jp2CompressionRatio > 1 {
+				args = append(args, "-r", strconv.FormatFloat(cfg.jp2CompressionRatio, 'f', -1, 64))
+			}
+			return args
+		},
+	},
+	JXL: {
+		decoder: "djxl",
+		encoder: "cjxl",
+		ext:     ".jxl",
+		decodeArgs: func(in, out string) []string {
+			return []string{in, out, "--quiet"}
+		},
+		encodeArgs: func(in, out string, cfg encodeConfig) []string {
+			return []string{in, out, "--quiet", "-q", strconv.Itoa(cfg.jxlQuality)}
+		},
+	},
+}
+
+// externalFormat returns the format of the JPEG 2000 or JPEG XL data in r
+// and the file extension the decoder expects.
+func externalFormat(r *bufio.Reader) (Format, string, bool) {
+	magic, _ := r.Peek(12)
+	switch {
+	case bytes.HasPrefix(magic, []byte(jp2Signature)):
+		return JP2, ".jp2", true
+	case bytes.HasPrefix(magic, []byte(j2kSignature)):
+		return JP2, ".j2k", true
+	case bytes.HasPrefix(magic, []byte(jxlSignature)), bytes.HasPrefix(magic, []byte(jxlCodestream)):
+		return JXL, ".jxl", true
+	}
+	return 0, "", false
+}
+
+// decodeExternal decodes the image data with the external decoder of the format.
+func decodeExternal(data []byte, format Format, ext string) (image.Image, error) {
+	codec := externalCodecs[format]
+	out, err := runExternalCodec(codec.decoder, codec.decodeArgs, data, ext, ".png")
+	if err != nil {
+		return nil, err
+	}
+	return png.Decode(bytes.NewReader(out))
+}
+
+// decodeExternalConfig reads the size of the JPEG 2000 or JPEG XL image in r from its header.
+// The external decoders aren't needed.
+func decodeExternalConfig(r io.Reader, format Format) (image.Config, error) {
+	if format == JP2 {
+		return decodeJP2Config(r)
+	}
+	return decodeJXLConfig(r)
+}
+
+// readBox reads the header of a JPEG 2000 or JPEG XL box and returns its type
+// and the length of its content, or -1 if the box extends to the end of the file.
+func readBox(r io.Reader) (string, int64, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(r, b[:8]); err != nil {
+		return "", 0, err
+	}
+	size := int64(binary.BigEndian.Uint32(b[:]))
+	switch size {
+	case 0:
+		return string(b[4:8]), -1, nil
+	case 1:
+		if _, err := io.ReadFull(r, b[8:16]); err != nil {
+			return "", 0, err
+		}
+		size = int64(binary.BigEndian.Uint64(b[8:])) - 8
+	}
+	if size < 8 {
+		return "", 0, errors.New("imaging: invalid box size")
+	}
+	return string(b[4:8]), size - 8, nil
+}
+
+// decodeJP2Config reads the image header box of a JP2 file
+// or the SIZ marker segment of a JPEG 2000 codestream.
+func decodeJP2Config(r io.Reader) (image.Config, error) {
+	var sig [12]byte
+	if _, err := io.ReadFull(r, sig[:4]); err != nil {
+		return image.Config{}, errInvalidJP2
+	}
+	if string(sig[:4]) == j2kSignature {
+		return decodeJ2KConfig(r)
+	}
+	if _, err := io.ReadFull(r, sig[4:]); err != nil || string(sig[:]) != jp2Signature {
+		return image.Config{}, errInvalidJP2
+	}
+	for {
+		typ, size, err := readBox(r)
+		if err != nil {
+			return image.Config{}, errInvalidJP2
+		}
+		switch typ {
+		case "jp2h":
+			// The header superbox, the image header box is its first box.
+			if typ, size, err = readBox(r); err != nil || typ != "ihdr" || size < 11 {
+				return image.Config{}, errInvalidJP2
+			}
+			var b [11]byte
+			if _, err := io.ReadFull(r, b[:]); err != nil {
+				return image.Config{}, errInvalidJP2
+			}
+			height := int(binary.BigEndian.Uint32(b[0:]))
+			width := int(binary.BigEndian.Uint32(b[4:]))
+			components := int(binary.BigEndian.Uint16(b[8:]))
+			return jp2Config(width, height, components, int(b[10]&0x7f)+1)
+		case "jp2c":
+			var soc [4]byte
+			if _, err := io.ReadFull(r, soc[:]); err != nil || string(soc[:]) != j2kSignature {
+				return image.Config{}, errInvalidJP2
+			}
+			return decodeJ2KConfig(r)
+		}
+		if size < 0 {
+			return image.Config{}, errInvalidJP2
+		}
+		if _, err := io.CopyN(io.Discard, r, size); err != nil {
+			return image.Config{}, errInvalidJP2
+		}
+	}
+}
+
+// decodeJ2KConfig reads the SIZ marker segment following the SOC and SIZ markers
+// of a JPEG 2000 codestream.
+func decodeJ2KConfig(r io.Reader) (image.Config, error) {
+	var b [39]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return image.Config{}, errInvalidJP2
+	}
+	be := binary.BigEndian
+	// Lsiz and Rsiz are followed by the reference grid size and the image offset on it.
+	width := int(be.Uint32(b[4:])) - int(be.Uint32(b[12:]))
+	height := int(be.Uint32(b[8:])) - int(be.Uint32(b[16:]))
+	components := int(be.Uint16(b[36:]))
+	return jp2Config(width, height, components, int(b[38]&0x7f)+1)
+}
+
+// jp2Config returns the config of the PNG image opj_decompress writes
+// for a JPEG 2000 image with the given number of components and bits per component.
+func jp2Config(width, height, components, bits int) (image.Config, error) {
+	if width <= 0 || height <= 0 || components == 0 {
+		return image.Config{}, errInvalidJP2
+	}
+	wide := bits > 8
+	var m color.Model
+	switch {
+	case components == 1 && wide:
+		m = color.Gray16Model
+	case components == 1:
+		m = color.GrayModel
+	case components == 3 && wide:
+		m = color.RGBA64Model
+	case components == 3:
+		m = color.RGBAModel
+	case wide:
+		m = color.NRGBA64Model
+	default:
+		m = color.NRGBAModel
+	}
+	return image.Config{ColorModel: m, Width: width, Height: height}, nil
+}
+
+// jxlRatios are the aspect ratios of the JPEG XL size header as the numerator
+// and the denominator of the width to height ratio.
+var jxlRatios = [8][2]uint64{{}, {1, 1}, {12, 10}, {4, 3}, {3, 2}, {16, 9}, {5, 4}, {2, 1}}
+
+// decodeJXLConfig reads the size header of a JPEG XL codestream, which may be in a container.
+// The color model isn't stored in the size header and is reported as color.NRGBAModel.
+func decodeJXLConfig(r io.Reader) (image.Config, error) {
+	var sig [12]byte
+	if _, err := io.ReadFull(r, sig[:2]); err != nil {
+		return image.Config{}, errInvalidJXL
+	}
+	if string(sig[:2]) != jxlCodestream {
+		if _, err := io.ReadFull(r, sig[2:]); err != nil || string(sig[:]) != jxlSignature {
+			return image.Config{}, errInvalidJXL
+		}
+		// Find the first box of the codestream.
+		for {
+			typ, size, err := readBox(r)
+			if err != nil {
+				return image.Config{}, errInvalidJXL
+			}
+			if typ == "jxlc" {
+				break
+			}
+			if typ == "jxlp" {
+				// The partial codestream boxes start with their index.
+				if _, err := io.CopyN(io.Discard, r, 4); err != nil {
+					return image.Config{}, errInvalidJXL
+				}
+				break
+			}
+			if size < 0 {
+				return image.Config{}, errInvalidJXL
+			}
+			if _, err := io.CopyN(io.Discard, r, size); err != nil {
+				return image.Config{}, errInvalidJXL
+			}
+		}
+		if _, err := io.ReadFull(r, sig[:2]); err != nil || string(sig[:2]) != jxlCodestream {
+			return image.Config{}, errInvalidJXL
+		}
+	}
+
+	// The size header takes at most 71 bits.
+	var b [9]byte
+	n, _ := io.ReadFull(r, b[:])
+	br := &jxlBitReader{data: b[:n]}
+	size := func(small bool) uint64 {
+		if small {
+			return (br.bits(5) + 1) * 8
+		}
+		return br.bits([4]int{9, 13, 18, 30}[br.bits(2)]) + 1
+	}
+	small := br.bits(1) == 1
+	height := size(small)
+	var width uint64
+	if ratio := br.bits(3); ratio == 0 {
+		width = size(small)
+	} else {
+		width = height * jxlRatios[ratio][0] / jxlRatios[ratio][1]
+	}
+	if br.err {
+		return image.Config{}, errInvalidJXL
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: int(width), Height: int(height)}, nil
+}
+
+// jxlBitReader reads the least significant bits of the bytes first.
+type jxlBitReader struct {
+	data []byte
+	pos  int  // Position in bits.
+	err  bool // The reader was past the end of the data.
+}
+
+func (br *jxlBitReader) bits(n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		if br.pos/8 >= len(br.data) {
+			br.err = true
+			return 0
+		}
+		v |= uint64(br.data[br.pos/8]>>uint(br.pos%8)&1) << uint(i)
+		br.pos++
+	}
+	return v
+}
+
+// encodeExternal encodes the image with the external encoder of the format.
+func encodeExternal(w io.Writer, img image.Image, format Format, cfg encodeConfig) error {
+	codec := externalCodecs[format]
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	args := func(in, out string) []string { return codec.encodeArgs(in, out, cfg) }
+	out, err := runExternalCodec(codec.encoder, args, buf.Bytes(), ".png", codec.ext)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
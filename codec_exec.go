@@ -0,0 +1,53 @@
+//go:build imaging_exec
+
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// codecTimeout is the time the external codecs are let run.
+var codecTimeout = time.Minute
+
+// runExternalCodec writes the data to a temporary file with the input extension, runs
+// the codec program found in PATH and returns the content of the output file. The codecs
+// choose the formats by the file extensions. The codec is killed after codecTimeout.
+func runExternalCodec(name string, args func(in, out string) []string, data []byte, inExt, outExt string) ([]byte, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, ErrCodecUnsupported
+	}
+	dir, err := os.MkdirTemp("", "imaging-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	in := filepath.Join(dir, "in"+inExt)
+	out := filepath.Join(dir, "out"+outExt)
+	if err := os.WriteFile(in, data, 0600); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), codecTimeout)
+	defer cancel()
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args(in, out)...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// The children of a killed codec could keep the output open.
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("imaging: %s failed: %v", name, ctx.Err())
+		}
+		return nil, fmt.Errorf("imaging: %s failed: %v: %s", name, err, strings.TrimSpace(output.String()))
+	}
+	return os.ReadFile(out)
+}
@@ -0,0 +1,170 @@
+//go:build imaging_exec
+
+package imaging
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeCodec installs a script with the given name as the only program in PATH. The script
+// writes its arguments to the args file and copies the source file to the output file, which
+// is the argument with the given index. If source is empty, the input file is copied.
+func fakeCodec(t *testing.T, name, source string, in, out int) (argsFile string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported")
+	}
+	cp, err := exec.LookPath("cp")
+	if err != nil {
+		t.Skip("cp is not found")
+	}
+	dir := t.TempDir()
+	argsFile = filepath.Join(dir, name+".args")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\n"
+	if source == "" {
+		script += "eval in=\\${" + strconv.Itoa(in) + "}\n"
+		source = "\"$in\""
+	}
+	script += "eval out=\\${" + strconv.Itoa(out) + "}\n" + cp + " " + source + " \"$out\"\n"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	t.Setenv("PATH", dir)
+	return argsFile
+}
+
+func TestDecodeExternal(t *testing.T) {
+	pngFile, err := filepath.Abs("testdata/flowers_small.png")
+	if err != nil {
+		t.Fatalf("Abs: %v", err)
+	}
+	testCases := []struct {
+		name    string
+		data    string
+		decoder string
+		out     int
+		args    string
+	}{
+		{"jp2", jp2Signature + "ftypjp2 ", "opj_decompress", 5, "-quiet -i in.jp2 -o out.png"},
+		{"j2k", j2kSignature + "\x00\x29", "opj_decompress", 5, "-quiet -i in.j2k -o out.png"},
+		{"jxl", jxlSignature, "djxl", 2, "in.jxl out.png --quiet"},
+		{"jxl codestream", jxlCodestream + "\xff\x00", "djxl", 2, "in.jxl out.png --quiet"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			argsFile := fakeCodec(t, tc.decoder, pngFile, 0, tc.out)
+			img, err := Decode(strings.NewReader(tc.data))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !compareNRGBA(Clone(img), Clone(testdataFlowersSmallPNG), 0) {
+				t.Fatal("the decoded image differs")
+			}
+			args, err := os.ReadFile(argsFile)
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+			fields := strings.Fields(string(args))
+			for i, f := range fields {
+				fields[i] = filepath.Base(f)
+			}
+			if got := strings.Join(fields, " "); got != tc.args {
+				t.Fatalf("got arguments %q want %q", got, tc.args)
+			}
+		})
+	}
+}
+
+func TestEncodeExternal(t *testing.T) {
+	testCases := []struct {
+		name    string
+		format  Format
+		opts    []EncodeOption
+		encoder string
+		in, out int
+		args    string
+	}{
+		{"jp2", JP2, nil, "opj_compress", 2, 4, "-i in.png -o out.jp2"},
+		{"jp2 lossy", JP2, []EncodeOption{JP2CompressionRatio(20)}, "opj_compress", 2, 4, "-i in.png -o out.jp2 -r 20"},
+		{"jxl", JXL, nil, "cjxl", 1, 2, "in.png out.jxl --quiet -q 90"},
+		{"jxl lossless", JXL, []EncodeOption{JXLQuality(100)}, "cjxl", 1, 2, "in.png out.jxl --quiet -q 100"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			argsFile := fakeCodec(t, tc.encoder, "", tc.in, tc.out)
+			var buf bytes.Buffer
+			if err := Encode(&buf, testdataFlowersSmallPNG, tc.format, tc.opts...); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			// The fake encoder copies the PNG input.
+			img, err := png.Decode(&buf)
+			if err != nil {
+				t.Fatalf("png.Decode: %v", err)
+			}
+			if !compareNRGBA(Clone(img), Clone(testdataFlowersSmallPNG), 0) {
+				t.Fatal("the encoded image differs")
+			}
+			args, err := os.ReadFile(argsFile)
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+			fields := strings.Fields(string(args))
+			for i, f := range fields {
+				fields[i] = filepath.Base(f)
+			}
+			if got := strings.Join(fields, " "); got != tc.args {
+				t.Fatalf("got arguments %q want %q", got, tc.args)
+			}
+		})
+	}
+}
+
+func TestExternalCodecFails(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho 'ERROR -> failed to decode image!' >&2\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(dir, "opj_decompress"), []byte(script), 0755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	t.Setenv("PATH", dir)
+	_, err := Decode(strings.NewReader(jp2Signature))
+	if err == nil || !strings.Contains(err.Error(), "opj_decompress failed") || !strings.Contains(err.Error(), "failed to decode image") {
+		t.Fatalf("got error %v want the decoder error", err)
+	}
+}
+
+func TestExternalCodecTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported")
+	}
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep is not found")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "djxl"), []byte("#!/bin/sh\nexec "+sleep+" 10\n"), 0755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	t.Setenv("PATH", dir)
+	defer func(d time.Duration) { codecTimeout = d }(codecTimeout)
+	codecTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	_, err = Decode(strings.NewReader(jxlSignature))
+	if err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Fatalf("got error %v want the deadline error", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("the decoder was not killed, took %v", d)
+	}
+}
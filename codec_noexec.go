@@ -0,0 +1,8 @@
+//go:build !imaging_exec
+
+package imaging
+
+// runExternalCodec doesn't run the external codecs without the imaging_exec build tag.
+func runExternalCodec(name string, args func(in, out string) []string, data []byte, inExt, outExt string) ([]byte, error) {
+	return nil, ErrCodecUnsupported
+}
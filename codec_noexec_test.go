@@ -0,0 +1,22 @@
+//go:build !imaging_exec
+
+package imaging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeExternalNoExec(t *testing.T) {
+	// The codecs in PATH are not run without the build tag.
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "djxl"), []byte("#!/bin/sh\nexit 1\n"), 0755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	t.Setenv("PATH", dir)
+	if _, err := Decode(strings.NewReader(jxlSignature)); err != ErrCodecUnsupported {
+		t.Fatalf("got error %v want %v", err, ErrCodecUnsupported)
+	}
+}
@@ -0,0 +1,17 @@
+package imaging
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExternalCodecUnsupported(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if _, err := Decode(strings.NewReader(jxlSignature)); err != ErrCodecUnsupported {
+		t.Fatalf("got error %v want %v", err, ErrCodecUnsupported)
+	}
+	if err := Encode(&bytes.Buffer{}, testdataFlowersSmallPNG, JP2); err != ErrCodecUnsupported {
+		t.Fatalf("got error %v want %v", err, ErrCodecUnsupported)
+	}
+}
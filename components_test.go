@@ -0,0 +1,98 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestLabelComponents(t *testing.T) {
+	// The U shape joins two labels of the first pass, the diagonal pixels are separate.
+	src := grayMask(
+		"#.#...",
+		"#.#..#",
+		"###.#.",
+		"......",
+	)
+	labels, components := LabelComponents(src)
+	wantLabels := []int{
+		1, 0, 1, 0, 0, 0,
+		1, 0, 1, 0, 0, 2,
+		1, 1, 1, 0, 3, 0,
+		0, 0, 0, 0, 0, 0,
+	}
+	for i, l := range wantLabels {
+		if labels[i] != l {
+			t.Fatalf("got labels %v want %v", labels, wantLabels)
+		}
+	}
+	want := []Component{
+		{Area: 7, Bounds: image.Rect(0, 0, 3, 3), CentroidX: 1.5, CentroidY: 8.0/7 + 0.5},
+		{Area: 1, Bounds: image.Rect(5, 1, 6, 2), CentroidX: 5.5, CentroidY: 1.5},
+		{Area: 1, Bounds: image.Rect(4, 2, 5, 3), CentroidX: 4.5, CentroidY: 2.5},
+	}
+	if len(components) != len(want) {
+		t.Fatalf("got %d components want %d", len(components), len(want))
+	}
+	for i, c := range components {
+		w := want[i]
+		if c.Area != w.Area || c.Bounds != w.Bounds || math.Abs(c.CentroidX-w.CentroidX) > 1e-9 || math.Abs(c.CentroidY-w.CentroidY) > 1e-9 {
+			t.Fatalf("component %d: got %+v want %+v", i, c, w)
+		}
+	}
+
+	// The transparent pixels are the background, the coordinates start at zero.
+	img := image.NewNRGBA(image.Rect(3, 4, 6, 5))
+	img.SetNRGBA(3, 4, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	img.SetNRGBA(4, 4, color.NRGBA{0xff, 0xff, 0xff, 0x00})
+	img.SetNRGBA(5, 4, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	labels, components = LabelComponents(img)
+	if len(components) != 2 || components[1].Bounds != image.Rect(2, 0, 3, 1) || labels[1] != 0 {
+		t.Fatalf("got %v %+v", labels, components)
+	}
+
+	if labels, components := LabelComponents(&image.Gray{}); len(labels) != 0 || len(components) != 0 {
+		t.Fatalf("got %v %v", labels, components)
+	}
+}
+
+func TestLabelComponentsContours(t *testing.T) {
+	// Each component has a single outer contour.
+	mask := Otsu(testdataFlowersSmallPNG)
+	labels, components := LabelComponents(mask)
+	outer := 0
+	for _, c := range FindContours(mask) {
+		if !c.Hole {
+			outer++
+		}
+	}
+	if len(components) != outer {
+		t.Fatalf("got %d components want %d", len(components), outer)
+	}
+
+	area := 0
+	for _, c := range components {
+		area += c.Area
+	}
+	fg := 0
+	for i, v := range mask.Pix {
+		if (v != 0) != (labels[i] != 0) {
+			t.Fatalf("got label %d of the pixel %d of the value %d", labels[i], i, v)
+		}
+		if v != 0 {
+			fg++
+		}
+	}
+	if area != fg {
+		t.Fatalf("got total area %d want %d", area, fg)
+	}
+}
+
+func BenchmarkLabelComponents(b *testing.B) {
+	mask := Otsu(testdataBranchesJPG)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		LabelComponents(mask)
+	}
+}
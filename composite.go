@@ -0,0 +1,136 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// CompositeOp is the Porter–Duff compositing operator of Composite, it specifies which
+// parts of the source and destination images are kept where they overlap and where only
+// one of them is opaque.
+type CompositeOp int
+
+// Porter–Duff compositing operators.
+const (
+	// CompositeSrcOver draws the source over the destination, like Overlay.
+	CompositeSrcOver CompositeOp = iota
+
+	// CompositeDstOver draws the source behind the destination.
+	CompositeDstOver
+
+	// CompositeSrc replaces the destination with the source.
+	CompositeSrc
+
+	// CompositeDst keeps the destination.
+	CompositeDst
+
+	// CompositeSrcIn keeps the source inside of the destination.
+	CompositeSrcIn
+
+	// CompositeDstIn keeps the destination inside of the source, e.g. to mask it.
+	CompositeDstIn
+
+	// CompositeSrcOut keeps the source outside of the destination.
+	CompositeSrcOut
+
+	// CompositeDstOut keeps the destination outside of the source, e.g. to cut holes in it.
+	CompositeDstOut
+
+	// CompositeSrcAtop draws the source over the destination inside of the destination only.
+	CompositeSrcAtop
+
+	// CompositeDstAtop draws the destination over the source inside of the source only.
+	CompositeDstAtop
+
+	// CompositeXor keeps the source and the destination where they don't overlap.
+	CompositeXor
+
+	// CompositeClear makes the destination transparent.
+	CompositeClear
+)
+
+// compositeCoefs holds the fractions of the source and the destination of each operator:
+// Fa = c[0] + c[1]*αd and Fb = c[2] + c[3]*αs.
+var compositeCoefs = [...][4]float64{
+	CompositeSrcOver: {1, 0, 1, -1},
+	CompositeDstOver: {1, -1, 1, 0},
+	CompositeSrc:     {1, 0, 0, 0},
+	CompositeDst:     {0, 0, 1, 0},
+	CompositeSrcIn:   {0, 1, 0, 0},
+	CompositeDstIn:   {0, 0, 0, 1},
+	CompositeSrcOut:  {1, -1, 0, 0},
+	CompositeDstOut:  {0, 0, 1, -1},
+	CompositeSrcAtop: {0, 1, 1, -1},
+	CompositeDstAtop: {1, -1, 0, 1},
+	CompositeXor:     {1, -1, 1, -1},
+	CompositeClear:   {0, 0, 0, 0},
+}
+
+// Composite composes the src image with the dst image at the given position using
+// the Porter–Duff operator and returns the combined image of the size of dst.
+// Opacity is the opacity of the src image, from 0.0 to 1.0. The dst image is treated as
+// composed with a transparent source outside of the src image, so the operators that
+// drop the destination where the source is transparent, e.g. CompositeDstIn, also clear
+// the rest of it. An invalid operator returns a copy of dst.
+//
+// Examples:
+//
+//	// Keep the photo inside of the shape only.
+//	dstImage := imaging.Composite(photo, shape, image.Pt(0, 0), imaging.CompositeDstIn, 1.0)
+//
+//	// Cut the shape out of the photo.
+//	dstImage := imaging.Composite(photo, shape, image.Pt(50, 50), imaging.CompositeDstOut, 1.0)
+//
+//	// Draw the texture over the opaque parts of the logo only.
+//	dstImage := imaging.Composite(logo, texture, image.Pt(0, 0), imaging.CompositeSrcAtop, 0.8)
+func Composite(dst, src image.Image, pos image.Point, op CompositeOp, opacity float64) *image.NRGBA {
+	res := Clone(dst)
+	if op < 0 || int(op) >= len(compositeCoefs) {
+		return res
+	}
+	c := compositeCoefs[op]
+	opacity = math.Min(math.Max(opacity, 0.0), 1.0)
+	pos = pos.Sub(dst.Bounds().Min)
+	srcRect := image.Rectangle{Min: pos, Max: pos.Add(src.Bounds().Size())}
+	interRect := srcRect.Intersect(res.Bounds())
+	// The destination is cleared where the source is transparent if Fb(0) = 0.
+	clearOutside := c[2] == 0
+
+	s := newScanner(src)
+	w, h := res.Rect.Dx(), res.Rect.Dy()
+	parallel(0, h, func(ys <-chan int) {
+		scanLine := make([]uint8, interRect.Dx()*4)
+		for y := range ys {
+			row := res.Pix[y*res.Stride : y*res.Stride+w*4]
+			if y < interRect.Min.Y || y >= interRect.Max.Y {
+				if clearOutside {
+					clear(row)
+				}
+				continue
+			}
+			if clearOutside {
+				clear(row[:interRect.Min.X*4])
+				clear(row[interRect.Max.X*4:])
+			}
+			s.scan(interRect.Min.X-srcRect.Min.X, y-srcRect.Min.Y, interRect.Max.X-srcRect.Min.X, y-srcRect.Min.Y+1, scanLine)
+			for x := 0; x < interRect.Dx(); x++ {
+				d := row[(interRect.Min.X+x)*4 : (interRect.Min.X+x)*4+4 : (interRect.Min.X+x)*4+4]
+				p := scanLine[x*4 : x*4+4 : x*4+4]
+				as := float64(p[3]) / 255 * opacity
+				ad := float64(d[3]) / 255
+				fa := (c[0] + c[1]*ad) * as
+				fb := (c[2] + c[3]*as) * ad
+				ao := fa + fb
+				if ao <= 0 {
+					d[0], d[1], d[2], d[3] = 0, 0, 0, 0
+					continue
+				}
+				d[0] = clamp((float64(p[0])*fa + float64(d[0])*fb) / ao)
+				d[1] = clamp((float64(p[1])*fa + float64(d[1])*fb) / ao)
+				d[2] = clamp((float64(p[2])*fa + float64(d[2])*fb) / ao)
+				d[3] = clamp(ao * 255)
+			}
+		}
+	})
+	return res
+}
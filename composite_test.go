@@ -0,0 +1,118 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestComposite(t *testing.T) {
+	red := color.NRGBA{0xff, 0, 0, 0xff}
+	blue := color.NRGBA{0, 0, 0xff, 0xff}
+	none := color.NRGBA{}
+	// The pixels are covered by both images, the destination only, the source only and none.
+	dst := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 2),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0xff,
+			0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		},
+	}
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 2),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00,
+		},
+	}
+	testCases := []struct {
+		name string
+		op   CompositeOp
+		want [4]color.NRGBA
+	}{
+		{"SrcOver", CompositeSrcOver, [4]color.NRGBA{blue, red, blue, none}},
+		{"DstOver", CompositeDstOver, [4]color.NRGBA{red, red, blue, none}},
+		{"Src", CompositeSrc, [4]color.NRGBA{blue, none, blue, none}},
+		{"Dst", CompositeDst, [4]color.NRGBA{red, red, none, none}},
+		{"SrcIn", CompositeSrcIn, [4]color.NRGBA{blue, none, none, none}},
+		{"DstIn", CompositeDstIn, [4]color.NRGBA{red, none, none, none}},
+		{"SrcOut", CompositeSrcOut, [4]color.NRGBA{none, none, blue, none}},
+		{"DstOut", CompositeDstOut, [4]color.NRGBA{none, red, none, none}},
+		{"SrcAtop", CompositeSrcAtop, [4]color.NRGBA{blue, red, none, none}},
+		{"DstAtop", CompositeDstAtop, [4]color.NRGBA{red, none, blue, none}},
+		{"Xor", CompositeXor, [4]color.NRGBA{none, red, blue, none}},
+		{"Clear", CompositeClear, [4]color.NRGBA{none, none, none, none}},
+		{"invalid", CompositeOp(-1), [4]color.NRGBA{red, red, none, none}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Composite(dst, src, image.Pt(0, 0), tc.op, 1.0)
+			for i, want := range tc.want {
+				if c := got.NRGBAAt(i%2, i/2); c != want {
+					t.Fatalf("got pixel %d %v want %v", i, c, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompositeOutside(t *testing.T) {
+	red := color.NRGBA{0xff, 0, 0, 0xff}
+	dst := &image.NRGBA{
+		Rect:   image.Rect(-1, 0, 2, 1),
+		Stride: 3 * 4,
+		Pix:    []uint8{0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0xff},
+	}
+	src := New(1, 1, color.NRGBA{0, 0, 0xff, 0xff})
+
+	testCases := []struct {
+		op   CompositeOp
+		want [3]color.NRGBA
+	}{
+		{CompositeDstIn, [3]color.NRGBA{{}, red, {}}},
+		{CompositeDstOut, [3]color.NRGBA{red, {}, red}},
+		{CompositeSrcAtop, [3]color.NRGBA{red, {0, 0, 0xff, 0xff}, red}},
+	}
+	for _, tc := range testCases {
+		got := Composite(dst, src, image.Pt(0, 0), tc.op, 1.0)
+		for x, want := range tc.want {
+			if c := got.NRGBAAt(x, 0); c != want {
+				t.Fatalf("op %d: got pixel %d %v want %v", tc.op, x, c, want)
+			}
+		}
+	}
+
+	// The source outside of the destination.
+	got := Composite(dst, src, image.Pt(5, 5), CompositeDstIn, 1.0)
+	if !compareNRGBA(got, New(3, 1, color.NRGBA{}), 0) {
+		t.Fatalf("got %v want transparent image", got.Pix)
+	}
+}
+
+func TestCompositeOpacity(t *testing.T) {
+	pos := image.Pt(10, 20)
+	for _, opacity := range []float64{0, 0.3, 0.7, 1} {
+		got := Composite(testdataBranchesJPG, testdataFlowersSmallPNG, pos, CompositeSrcOver, opacity)
+		want := Overlay(testdataBranchesJPG, testdataFlowersSmallPNG, pos, opacity)
+		if !compareNRGBA(got, want, 1) {
+			t.Fatalf("opacity %v: the result differs from Overlay", opacity)
+		}
+	}
+
+	// Half of the source is kept where the destination is opaque.
+	dst := New(1, 1, color.NRGBA{0xff, 0, 0, 0xff})
+	src := New(1, 1, color.NRGBA{0, 0, 0xff, 0xff})
+	got := Composite(dst, src, image.Pt(0, 0), CompositeSrcIn, 0.5)
+	if want := (color.NRGBA{0, 0, 0xff, 0x80}); got.NRGBAAt(0, 0) != want {
+		t.Fatalf("got %v want %v", got.NRGBAAt(0, 0), want)
+	}
+}
+
+func BenchmarkComposite(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Composite(testdataBranchesJPG, testdataFlowersSmallPNG, image.Pt(20, 20), CompositeSrcAtop, 0.5)
+	}
+}
@@ -0,0 +1,266 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// Contour is a closed polygonal boundary of a region found by FindContours.
+type Contour struct {
+	// Points are the polygon vertices. They are pixel corners, so a contour around
+	// the single pixel (x, y) has the vertices (x, y), (x+1, y), (x+1, y+1) and (x, y+1).
+	Points []image.Point
+
+	// Hole reports whether the contour is the inner boundary of a hole in a region.
+	// Outer boundaries are clockwise, hole boundaries are counter-clockwise.
+	Hole bool
+
+	// Parent is the index of the directly enclosing contour, or -1 for a top-level contour.
+	// The parent of a hole is the outer boundary of its region, the parent of a region
+	// lying inside a hole is that hole.
+	Parent int
+}
+
+// Area returns the area enclosed by the contour in pixels.
+func (c Contour) Area() float64 {
+	return math.Abs(polygonArea(c.Points))
+}
+
+// Bounds returns the bounding rectangle of the contour.
+func (c Contour) Bounds() image.Rectangle {
+	if len(c.Points) == 0 {
+		return image.Rectangle{}
+	}
+	r := image.Rectangle{Min: c.Points[0], Max: c.Points[0]}
+	for _, p := range c.Points[1:] {
+		r.Min.X = minint(r.Min.X, p.X)
+		r.Min.Y = minint(r.Min.Y, p.Y)
+		r.Max.X = maxint(r.Max.X, p.X)
+		r.Max.Y = maxint(r.Max.Y, p.Y)
+	}
+	return r
+}
+
+// FindContours finds the boundaries of the foreground regions of a binary image and
+// returns them with their nesting hierarchy. Pixels with a luminance of at least 128 are
+// the foreground, other pixels (including transparent ones) are the background. Foreground
+// pixels are connected to their horizontal and vertical neighbours only, so diagonally
+// touching pixels form separate regions. The contour coordinates are relative to the
+// top-left corner of the image bounds.
+//
+// Example:
+//
+//	contours := imaging.FindContours(imaging.Grayscale(srcImage))
+//	for _, c := range contours {
+//		if !c.Hole && c.Parent == -1 {
+//			fmt.Println("top-level region with area", c.Area())
+//		}
+//	}
+func FindContours(img image.Image) []Contour {
+	fg, w, h := foregroundPlane(img)
+	loops := traceBoundaries(w, h, func(x, y int) bool { return fg[y*w+x] })
+	contours := make([]Contour, len(loops))
+	areas := make([]float64, len(loops))
+	bounds := make([]image.Rectangle, len(loops))
+	for i, loop := range loops {
+		a := polygonArea(loop)
+		contours[i] = Contour{Points: loop, Hole: a < 0, Parent: -1}
+		areas[i] = math.Abs(a)
+		bounds[i] = contours[i].Bounds()
+	}
+
+	for i, c := range contours {
+		// The foreground pixel to the right of the first edge. It lies inside all the
+		// outer boundaries enclosing the contour and inside all the enclosing holes.
+		d := dirDeltas[dirOf(c.Points[0], c.Points[1])]
+		px := c.Points[0].X + minint(d.X, 0) + minint(-d.Y, 0)
+		py := c.Points[0].Y + minint(d.Y, 0) + minint(d.X, 0)
+		for j := range contours {
+			if j == i || areas[j] <= areas[i] || !image.Pt(px, py).In(bounds[j]) {
+				continue
+			}
+			if c.Parent >= 0 && areas[j] >= areas[c.Parent] {
+				continue
+			}
+			if pixelInPolygon(px, py, contours[j].Points) {
+				c.Parent = j
+			}
+		}
+		contours[i].Parent = c.Parent
+	}
+	return contours
+}
+
+// foregroundPlane reports for each pixel of the image in row-major order whether it's
+// a foreground pixel of FindContours, and returns the width and height of the image.
+func foregroundPlane(img image.Image) ([]bool, int, int) {
+	src := newScanner(img)
+	fg := make([]bool, src.w*src.h)
+	parallel(0, src.h, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			for x := 0; x < src.w; x++ {
+				s := scanLine[x*4 : x*4+4 : x*4+4]
+				lum := (0.299*float64(s[0]) + 0.587*float64(s[1]) + 0.114*float64(s[2])) * float64(s[3]) / 255
+				fg[y*src.w+x] = lum >= 127.5
+			}
+		}
+	})
+	return fg, src.w, src.h
+}
+
+// pixelInPolygon reports whether the center of the pixel (x, y) is inside the polygon
+// with axis-aligned edges.
+func pixelInPolygon(x, y int, poly []image.Point) bool {
+	in := false
+	for i := range poly {
+		a := poly[i]
+		b := poly[(i+1)%len(poly)]
+		if a.X != b.X || a.X <= x {
+			continue
+		}
+		if minint(a.Y, b.Y) <= y && y < maxint(a.Y, b.Y) {
+			in = !in
+		}
+	}
+	return in
+}
+
+func minint(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxint(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Boundary edge directions in image coordinates (the y axis points down).
+const (
+	dirRight = iota
+	dirDown
+	dirLeft
+	dirUp
+)
+
+var dirDeltas = [4]image.Point{
+	dirRight: {1, 0},
+	dirDown:  {0, 1},
+	dirLeft:  {-1, 0},
+	dirUp:    {0, -1},
+}
+
+// traceBoundaries returns the closed boundaries between the pixels where inside reports true
+// and the rest of the w x h grid. The boundaries run along the pixel edges, their vertices are
+// pixel corners and only the corners where the direction changes are kept.
+// Outer boundaries are clockwise (positive polygonArea) and the boundaries of holes
+// are counter-clockwise. Pixels touching only diagonally belong to separate boundaries.
+func traceBoundaries(w, h int, inside func(x, y int) bool) [][]image.Point {
+	in := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < w && y < h && inside(x, y)
+	}
+
+	// out holds a bit mask of the outgoing edge directions for each pixel corner.
+	stride := w + 1
+	out := make([]uint8, stride*(h+1))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !in(x, y) {
+				continue
+			}
+			if !in(x, y-1) {
+				out[y*stride+x] |= 1 << dirRight
+			}
+			if !in(x+1, y) {
+				out[y*stride+x+1] |= 1 << dirDown
+			}
+			if !in(x, y+1) {
+				out[(y+1)*stride+x+1] |= 1 << dirLeft
+			}
+			if !in(x-1, y) {
+				out[(y+1)*stride+x] |= 1 << dirUp
+			}
+		}
+	}
+
+	var loops [][]image.Point
+	for v := range out {
+		if out[v] == 0 {
+			continue
+		}
+		start := image.Pt(v%stride, v/stride)
+		p := start
+		dir := -1
+		var loop []image.Point
+		for {
+			i := p.Y*stride + p.X
+			next := -1
+			if dir < 0 {
+				for d := 0; d < 4; d++ {
+					if out[i]&(1<<d) != 0 {
+						next = d
+						break
+					}
+				}
+			} else {
+				// Prefer turning right, then going straight, then turning left.
+				for _, d := range [3]int{(dir + 1) % 4, dir, (dir + 3) % 4} {
+					if out[i]&(1<<d) != 0 {
+						next = d
+						break
+					}
+				}
+			}
+			if next < 0 {
+				break
+			}
+			out[i] &^= 1 << next
+			if next != dir {
+				loop = append(loop, p)
+			}
+			dir = next
+			p = p.Add(dirDeltas[dir])
+			if p == start {
+				break
+			}
+		}
+		// Drop the start point if the boundary goes straight through it.
+		if len(loop) > 2 && dir == dirOf(loop[0], loop[1]) {
+			loop = loop[1:]
+		}
+		loops = append(loops, loop)
+	}
+	return loops
+}
+
+// dirOf returns the direction of the axis-aligned step from a to b.
+func dirOf(a, b image.Point) int {
+	switch {
+	case b.X > a.X:
+		return dirRight
+	case b.Y > a.Y:
+		return dirDown
+	case b.X < a.X:
+		return dirLeft
+	default:
+		return dirUp
+	}
+}
+
+// polygonArea returns the signed area of the polygon. It is positive if the polygon
+// is clockwise in image coordinates.
+func polygonArea(poly []image.Point) float64 {
+	var sum int
+	for i := range poly {
+		a := poly[i]
+		b := poly[(i+1)%len(poly)]
+		sum += a.X*b.Y - b.X*a.Y
+	}
+	return float64(sum) / 2
+}
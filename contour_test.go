@@ -0,0 +1,145 @@
+package imaging
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestTraceBoundaries(t *testing.T) {
+	testCases := []struct {
+		name string
+		mask []string
+		want [][]image.Point
+	}{
+		{
+			"empty",
+			[]string{
+				"...",
+				"...",
+			},
+			nil,
+		},
+		{
+			"rectangle",
+			[]string{
+				"....",
+				".##.",
+				".##.",
+			},
+			[][]image.Point{
+				{{1, 1}, {3, 1}, {3, 3}, {1, 3}},
+			},
+		},
+		{
+			"hole",
+			[]string{
+				"###",
+				"#.#",
+				"###",
+			},
+			[][]image.Point{
+				{{0, 0}, {3, 0}, {3, 3}, {0, 3}},
+				{{1, 1}, {1, 2}, {2, 2}, {2, 1}},
+			},
+		},
+		{
+			"diagonal",
+			[]string{
+				"#.",
+				".#",
+			},
+			[][]image.Point{
+				{{0, 0}, {1, 0}, {1, 1}, {0, 1}},
+				{{1, 1}, {2, 1}, {2, 2}, {1, 2}},
+			},
+		},
+		{
+			"L shape",
+			[]string{
+				"#.",
+				"##",
+			},
+			[][]image.Point{
+				{{0, 0}, {1, 0}, {1, 1}, {2, 1}, {2, 2}, {0, 2}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := traceBoundaries(len(tc.mask[0]), len(tc.mask), func(x, y int) bool {
+				return tc.mask[y][x] == '#'
+			})
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPolygonArea(t *testing.T) {
+	cw := []image.Point{{0, 0}, {3, 0}, {3, 2}, {0, 2}}
+	if got := polygonArea(cw); got != 6 {
+		t.Fatalf("got area %v want 6", got)
+	}
+	ccw := []image.Point{{0, 0}, {0, 2}, {3, 2}, {3, 0}}
+	if got := polygonArea(ccw); got != -6 {
+		t.Fatalf("got area %v want -6", got)
+	}
+}
+
+func TestFindContours(t *testing.T) {
+	mask := []string{
+		"#######.#",
+		"#.....#..",
+		"#.###.#..",
+		"#.#.#.#..",
+		"#.###.#.#",
+		"#.....#.#",
+		"#######..",
+	}
+	src := image.NewGray(image.Rect(10, 10, 19, 17))
+	for y, row := range mask {
+		for x := range row {
+			if row[x] == '#' {
+				src.Pix[y*src.Stride+x] = 0xff
+			}
+		}
+	}
+
+	got := FindContours(src)
+	want := []Contour{
+		{Points: []image.Point{{0, 0}, {7, 0}, {7, 7}, {0, 7}}, Hole: false, Parent: -1},
+		{Points: []image.Point{{8, 0}, {9, 0}, {9, 1}, {8, 1}}, Hole: false, Parent: -1},
+		{Points: []image.Point{{1, 1}, {1, 6}, {6, 6}, {6, 1}}, Hole: true, Parent: 0},
+		{Points: []image.Point{{2, 2}, {5, 2}, {5, 5}, {2, 5}}, Hole: false, Parent: 2},
+		{Points: []image.Point{{3, 3}, {3, 4}, {4, 4}, {4, 3}}, Hole: true, Parent: 3},
+		{Points: []image.Point{{8, 4}, {9, 4}, {9, 6}, {8, 6}}, Hole: false, Parent: -1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v want %+v", got, want)
+	}
+
+	if a := got[3].Area(); a != 9 {
+		t.Fatalf("got area %v want 9", a)
+	}
+	if b := got[5].Bounds(); !b.Eq(image.Rect(8, 4, 9, 6)) {
+		t.Fatalf("got bounds %v want (8,4)-(9,6)", b)
+	}
+}
+
+func TestFindContoursEmpty(t *testing.T) {
+	if got := FindContours(image.NewGray(image.Rect(0, 0, 4, 4))); len(got) != 0 {
+		t.Fatalf("got %d contours want 0", len(got))
+	}
+	if got := FindContours(&image.NRGBA{}); len(got) != 0 {
+		t.Fatalf("got %d contours want 0", len(got))
+	}
+}
+
+func BenchmarkFindContours(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		FindContours(testdataBranchesPNG)
+	}
+}
@@ -4,6 +4,21 @@ import (
 	"image"
 )
 
+// EdgeMode specifies how the pixels outside of the image are sampled.
+type EdgeMode int
+
+// Edge modes.
+const (
+	// EdgeClamp repeats the edge pixels.
+	EdgeClamp EdgeMode = iota
+
+	// EdgeWrap wraps around to the opposite edge, as if the image was tiled.
+	EdgeWrap
+
+	// EdgeMirror mirrors the image at the edges, without repeating the edge pixels.
+	EdgeMirror
+)
+
 // ConvolveOptions are convolution parameters.
 type ConvolveOptions struct {
 	// If Normalize is true the kernel is normalized before convolution.
@@ -14,25 +29,66 @@ type ConvolveOptions struct {
 
 	// Bias is added to each color channel value after convolution.
 	Bias int
+
+	// Edge specifies how the pixels outside of the image are sampled. Default is EdgeClamp.
+	Edge EdgeMode
 }
 
 // Convolve3x3 convolves the image with the specified 3x3 convolution kernel.
 // Default parameters are used if a nil *ConvolveOptions is passed.
 func Convolve3x3(img image.Image, kernel [9]float64, options *ConvolveOptions) *image.NRGBA {
-	return convolve(img, kernel[:], options)
+	return convolve(img, kernel[:], 3, 3, options)
 }
 
 // Convolve5x5 convolves the image with the specified 5x5 convolution kernel.
 // Default parameters are used if a nil *ConvolveOptions is passed.
 func Convolve5x5(img image.Image, kernel *[25]float64, options *ConvolveOptions) *image.NRGBA {
-	return convolve(img, kernel[:], options)
+	return convolve(img, kernel[:], 5, 5, options)
+}
+
+// ConvolveKernel convolves the image with the specified convolution kernel of any size,
+// given as rows of coefficients. The number of rows and the length of the rows must be odd
+// and the rows must be of the same length, otherwise a copy of the image is returned.
+// Default parameters are used if a nil *ConvolveOptions is passed.
+//
+// Example:
+//
+//	// Emboss.
+//	dstImage := imaging.ConvolveKernel(srcImage, [][]float64{
+//		{-2, -1, 0},
+//		{-1, 1, 1},
+//		{0, 1, 2},
+//	}, nil)
+//
+//	// Horizontal motion blur wrapping around the edges.
+//	dstImage = imaging.ConvolveKernel(srcImage, [][]float64{
+//		{1, 1, 1, 1, 1, 1, 1},
+//	}, &imaging.ConvolveOptions{Normalize: true, Edge: imaging.EdgeWrap})
+func ConvolveKernel(img image.Image, kernel [][]float64, options *ConvolveOptions) *image.NRGBA {
+	kh := len(kernel)
+	if kh%2 == 0 {
+		return Clone(img)
+	}
+	kw := len(kernel[0])
+	if kw%2 == 0 {
+		return Clone(img)
+	}
+	flat := make([]float64, 0, kw*kh)
+	for _, row := range kernel {
+		if len(row) != kw {
+			return Clone(img)
+		}
+		flat = append(flat, row...)
+	}
+	return convolve(img, flat, kw, kh, options)
 }
 
-func convolve(img image.Image, kernel []float64, options *ConvolveOptions) *image.NRGBA {
+// convolve convolves the image with the kw x kh kernel given row by row.
+func convolve(img image.Image, kernel []float64, kw, kh int, options *ConvolveOptions) *image.NRGBA {
 	src := toNRGBA(img)
 	w := src.Bounds().Max.X
 	h := src.Bounds().Max.Y
-	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	dst := newNRGBA(image.Rect(0, 0, w, h))
 
 	if w < 1 || h < 1 {
 		return dst
@@ -43,6 +99,7 @@ func convolve(img image.Image, kernel []float64, options *ConvolveOptions) *imag
 	}
 
 	if options.Normalize {
+		kernel = append([]float64(nil), kernel...)
 		normalizeKernel(kernel)
 	}
 
@@ -51,18 +108,11 @@ func convolve(img image.Image, kernel []float64, options *ConvolveOptions) *imag
 		k    float64
 	}
 	var coefs []coef
-	var m int
-
-	switch len(kernel) {
-	case 9:
-		m = 1
-	case 25:
-		m = 2
-	}
+	mx, my := kw/2, kh/2
 
 	i := 0
-	for y := -m; y <= m; y++ {
-		for x := -m; x <= m; x++ {
+	for y := -my; y <= my; y++ {
+		for x := -mx; x <= mx; x++ {
 			if kernel[i] != 0 {
 				coefs = append(coefs, coef{x: x, y: y, k: kernel[i]})
 			}
@@ -75,20 +125,8 @@ func convolve(img image.Image, kernel []float64, options *ConvolveOptions) *imag
 			for x := 0; x < w; x++ {
 				var r, g, b float64
 				for _, c := range coefs {
-					ix := x + c.x
-					if ix < 0 {
-						ix = 0
-					} else if ix >= w {
-						ix = w - 1
-					}
-
-					iy := y + c.y
-					if iy < 0 {
-						iy = 0
-					} else if iy >= h {
-						iy = h - 1
-					}
-
+					ix := edgeIndex(x+c.x, w, options.Edge)
+					iy := edgeIndex(y+c.y, h, options.Edge)
 					off := iy*src.Stride + ix*4
 					s := src.Pix[off : off+3 : off+3]
 					r += float64(s[0]) * c.k
@@ -128,6 +166,39 @@ func convolve(img image.Image, kernel []float64, options *ConvolveOptions) *imag
 	return dst
 }
 
+// edgeIndex returns the index of the pixel sampled at the index i of the n pixels
+// with the given edge mode.
+func edgeIndex(i, n int, mode EdgeMode) int {
+	if i >= 0 && i < n {
+		return i
+	}
+	switch mode {
+	case EdgeWrap:
+		i %= n
+		if i < 0 {
+			i += n
+		}
+		return i
+	case EdgeMirror:
+		if n == 1 {
+			return 0
+		}
+		period := 2*n - 2
+		i %= period
+		if i < 0 {
+			i += period
+		}
+		if i >= n {
+			i = period - i
+		}
+		return i
+	}
+	if i < 0 {
+		return 0
+	}
+	return n - 1
+}
+
 func normalizeKernel(kernel []float64) {
 	var sum, sumpos float64
 	for i := range kernel {
@@ -226,7 +226,7 @@ func TestConvolve5x5(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			got := Convolve5x5(tc.src, tc.kernel, tc.options)
+			got := Convolve5x5(tc.src, &tc.kernel, tc.options)
 			if !compareNRGBA(got, tc.want, 0) {
 				t.Fatalf("got result %#v want %#v", got, tc.want)
 			}
@@ -325,7 +325,7 @@ func BenchmarkConvolve5x5(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		Convolve5x5(
 			testdataBranchesJPG,
-			[25]float64{
+			&[25]float64{
 				-1, -1, -1, -1, 0,
 				-1, -1, -1, 0, 1,
 				-1, -1, 0, 1, 1,
@@ -336,3 +336,96 @@ func BenchmarkConvolve5x5(b *testing.B) {
 		)
 	}
 }
+
+func TestConvolveKernel(t *testing.T) {
+	img := testdataFlowersSmallPNG
+	emboss := [9]float64{-2, -1, 0, -1, 1, 1, 0, 1, 2}
+	got := ConvolveKernel(img, [][]float64{{-2, -1, 0}, {-1, 1, 1}, {0, 1, 2}}, nil)
+	if !compareNRGBA(got, Convolve3x3(img, emboss, nil), 0) {
+		t.Fatal("the 3x3 kernel differs from Convolve3x3")
+	}
+
+	var k5 [25]float64
+	rows := make([][]float64, 5)
+	for i := range k5 {
+		k5[i] = float64(i%7) - 2
+	}
+	for y := range rows {
+		rows[y] = k5[y*5 : y*5+5]
+	}
+	opts := &ConvolveOptions{Normalize: true, Abs: true, Bias: 10, Edge: EdgeMirror}
+	want := Convolve5x5(img, &k5, opts)
+	if k5[0] != -2 || k5[24] != 1 {
+		t.Fatal("the kernel is changed by the normalization")
+	}
+	if !compareNRGBA(ConvolveKernel(img, rows, opts), want, 0) {
+		t.Fatal("the 5x5 kernel differs from Convolve5x5")
+	}
+
+	// A 1x3 kernel is the same as a 3x3 kernel with one row.
+	got = ConvolveKernel(img, [][]float64{{1, 2, 1}}, &ConvolveOptions{Normalize: true})
+	want = Convolve3x3(img, [9]float64{0, 0, 0, 1, 2, 1, 0, 0, 0}, &ConvolveOptions{Normalize: true})
+	if !compareNRGBA(got, want, 0) {
+		t.Fatal("the 1x3 kernel differs from the 3x3 kernel")
+	}
+
+	for _, kernel := range [][][]float64{
+		nil,
+		{{1, 1}},
+		{{1}, {1}},
+		{{1, 1, 1}, {1, 1}, {1, 1, 1}},
+		{{}},
+	} {
+		if !compareNRGBA(ConvolveKernel(img, kernel, nil), Clone(img), 0) {
+			t.Fatalf("the invalid kernel %v changes the image", kernel)
+		}
+	}
+}
+
+func TestConvolveEdge(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 4, 1),
+		Stride: 16,
+		Pix: []uint8{
+			0x10, 0x00, 0x00, 0xff, 0x20, 0x00, 0x00, 0xff, 0x30, 0x00, 0x00, 0xff, 0x40, 0x00, 0x00, 0xff,
+		},
+	}
+	// Sample the pixel two to the right.
+	kernel := [][]float64{{0, 0, 0, 0, 1}}
+	testCases := []struct {
+		edge EdgeMode
+		want []uint8
+	}{
+		{EdgeClamp, []uint8{0x30, 0x40, 0x40, 0x40}},
+		{EdgeWrap, []uint8{0x30, 0x40, 0x10, 0x20}},
+		{EdgeMirror, []uint8{0x30, 0x40, 0x30, 0x20}},
+	}
+	for _, tc := range testCases {
+		got := ConvolveKernel(src, kernel, &ConvolveOptions{Edge: tc.edge})
+		for x, want := range tc.want {
+			if r := got.Pix[x*4]; r != want {
+				t.Fatalf("edge mode %v: got %#x at %d want %#x", tc.edge, r, x, want)
+			}
+		}
+	}
+}
+
+func TestEdgeIndex(t *testing.T) {
+	testCases := []struct {
+		mode EdgeMode
+		n    int
+		want []int // for i from -5 to 8
+	}{
+		{EdgeClamp, 4, []int{0, 0, 0, 0, 0, 0, 1, 2, 3, 3, 3, 3, 3, 3}},
+		{EdgeWrap, 4, []int{3, 0, 1, 2, 3, 0, 1, 2, 3, 0, 1, 2, 3, 0}},
+		{EdgeMirror, 4, []int{1, 2, 3, 2, 1, 0, 1, 2, 3, 2, 1, 0, 1, 2}},
+		{EdgeMirror, 1, []int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+	}
+	for _, tc := range testCases {
+		for i := -5; i <= 8; i++ {
+			if got := edgeIndex(i, tc.n, tc.mode); got != tc.want[i+5] {
+				t.Fatalf("edgeIndex(%d, %d, %v): got %d want %d", i, tc.n, tc.mode, got, tc.want[i+5])
+			}
+		}
+	}
+}
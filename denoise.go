@@ -0,0 +1,180 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// MedianFilter reduces the noise of the image replacing each pixel with the median of the
+// pixels in the square window of the given radius around it, channel by channel. It removes
+// the impulse noise (e.g. dust and scratches in scans) while keeping the edges sharp.
+// The color channels are weighted by alpha, so the transparent pixels don't change the colors.
+// The radius must be positive, the window is 2*radius+1 pixels wide.
+//
+// Example:
+//
+//	dstImage := imaging.MedianFilter(srcImage, 2)
+func MedianFilter(img image.Image, radius int) *image.NRGBA {
+	if radius <= 0 {
+		return Clone(img)
+	}
+
+	src := toNRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dst := newNRGBA(image.Rect(0, 0, w, h))
+
+	parallel(0, h, func(ys <-chan int) {
+		var hist medianHistogram
+		for y := range ys {
+			y0, y1 := maxint(y-radius, 0), minint(y+radius, h-1)
+			column := func(x int, sign int32) {
+				for yy := y0; yy <= y1; yy++ {
+					i := yy*src.Stride + x*4
+					hist.add(src.Pix[i:i+4:i+4], sign)
+				}
+			}
+
+			// The window is slid along the row, adding and removing a column at each step.
+			hist = medianHistogram{}
+			for x := 0; x <= minint(radius, w-1); x++ {
+				column(x, 1)
+			}
+			for x := 0; x < w; x++ {
+				if hist.total > 0 {
+					j := y*dst.Stride + x*4
+					d := dst.Pix[j : j+4 : j+4]
+					d[0] = hist.median(0, hist.total)
+					d[1] = hist.median(1, hist.total)
+					d[2] = hist.median(2, hist.total)
+					d[3] = hist.median(3, hist.count)
+				}
+				if x-radius >= 0 {
+					column(x-radius, -1)
+				}
+				if x+radius+1 < w {
+					column(x+radius+1, 1)
+				}
+			}
+		}
+	})
+
+	return dst
+}
+
+// medianHistogram is the histogram of the channel values of the pixels in the window of
+// MedianFilter. The fine bins count the values, the coarse bins the groups of 16 values
+// to find the median quickly. The color values are weighted by alpha.
+type medianHistogram struct {
+	coarse [4][16]int32
+	fine   [4][256]int32
+	total  int32 // The sum of the alpha of the pixels.
+	count  int32 // The number of the pixels.
+}
+
+// add adds the pixel to the histogram if sign is 1 or removes it if sign is -1.
+func (hist *medianHistogram) add(p []uint8, sign int32) {
+	a := int32(p[3]) * sign
+	for c := 0; c < 3; c++ {
+		hist.fine[c][p[c]] += a
+		hist.coarse[c][p[c]>>4] += a
+	}
+	hist.fine[3][p[3]] += sign
+	hist.coarse[3][p[3]>>4] += sign
+	hist.total += a
+	hist.count += sign
+}
+
+// median returns the median value of the channel, whose total weight is given.
+func (hist *medianHistogram) median(c int, total int32) uint8 {
+	half := (total + 1) / 2
+	var sum int32
+	for i, n := range hist.coarse[c] {
+		if sum+n < half {
+			sum += n
+			continue
+		}
+		for v := i * 16; v < i*16+15; v++ {
+			sum += hist.fine[c][v]
+			if sum >= half {
+				return uint8(v)
+			}
+		}
+		return uint8(i*16 + 15)
+	}
+	return 0xff
+}
+
+// BilateralFilter reduces the noise of the image with the bilateral filter, a Gaussian blur
+// that averages only the pixels of similar colors, so it smooths the flat areas and keeps
+// the edges. The sigmaSpace parameter is the standard deviation of the Gaussian function
+// of the distance in pixels, as in Blur. The sigmaColor parameter is the standard deviation
+// of the Gaussian function of the distance between the colors, where the channel values
+// range from 0 to 255: the color differences much larger than sigmaColor are kept.
+// Both parameters must be positive, typical values of sigmaColor are 10 to 50.
+//
+// Example:
+//
+//	dstImage := imaging.BilateralFilter(srcImage, 3, 25)
+func BilateralFilter(img image.Image, sigmaSpace, sigmaColor float64) *image.NRGBA {
+	if sigmaSpace <= 0 || sigmaColor <= 0 {
+		return Clone(img)
+	}
+
+	radius := int(math.Ceil(sigmaSpace * 3.0))
+	size := 2*radius + 1
+	spatial := make([]float64, size*size)
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			spatial[(dy+radius)*size+dx+radius] = math.Exp(-float64(dx*dx+dy*dy) / (2 * sigmaSpace * sigmaSpace))
+		}
+	}
+	// The weight of the color distance is the product of the weights of the channel differences.
+	var rangeLUT [256]float64
+	for d := range rangeLUT {
+		rangeLUT[d] = math.Exp(-float64(d*d) / (2 * sigmaColor * sigmaColor))
+	}
+
+	src := toNRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dst := newNRGBA(image.Rect(0, 0, w, h))
+
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			y0, y1 := maxint(y-radius, 0), minint(y+radius, h-1)
+			for x := 0; x < w; x++ {
+				x0, x1 := maxint(x-radius, 0), minint(x+radius, w-1)
+				i := y*src.Stride + x*4
+				c := src.Pix[i : i+4 : i+4]
+				var r, g, b, a, wsum float64
+				for yy := y0; yy <= y1; yy++ {
+					k := (yy-y+radius)*size + radius - x
+					row := src.Pix[yy*src.Stride:]
+					for xx := x0; xx <= x1; xx++ {
+						s := row[xx*4 : xx*4+4 : xx*4+4]
+						weight := spatial[k+xx] *
+							rangeLUT[absint(int(s[0])-int(c[0]))] *
+							rangeLUT[absint(int(s[1])-int(c[1]))] *
+							rangeLUT[absint(int(s[2])-int(c[2]))]
+						wsum += weight
+						wa := float64(s[3]) * weight
+						r += float64(s[0]) * wa
+						g += float64(s[1]) * wa
+						b += float64(s[2]) * wa
+						a += wa
+					}
+				}
+				if a != 0 {
+					aInv := 1 / a
+					j := y*dst.Stride + x*4
+					d := dst.Pix[j : j+4 : j+4]
+					d[0] = clamp(r * aInv)
+					d[1] = clamp(g * aInv)
+					d[2] = clamp(b * aInv)
+					d[3] = clamp(a / wsum)
+				}
+			}
+		}
+	})
+
+	return dst
+}
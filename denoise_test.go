@@ -0,0 +1,135 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// stepImage returns an opaque image with the left half black and the right half white.
+func stepImage(w, h int) *image.NRGBA {
+	img := New(w, h, color.Black)
+	for y := 0; y < h; y++ {
+		for x := w / 2; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+		}
+	}
+	return img
+}
+
+func TestMedianFilter(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 4, 0),
+		Stride: 5 * 4,
+		Pix: []uint8{
+			10, 0, 0, 0xff, 50, 0, 0, 0xff, 20, 0, 0, 0xff, 40, 0, 0, 0xff, 30, 0, 0, 0xff,
+		},
+	}
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 5, 1),
+		Stride: 5 * 4,
+		Pix: []uint8{
+			10, 0, 0, 0xff, 20, 0, 0, 0xff, 40, 0, 0, 0xff, 30, 0, 0, 0xff, 30, 0, 0, 0xff,
+		},
+	}
+	if got := MedianFilter(src, 1); !compareNRGBA(got, want, 0) {
+		t.Fatalf("got %v want %v", got.Pix, want.Pix)
+	}
+	if got := MedianFilter(src, 0); !compareNRGBA(got, toNRGBA(src), 0) {
+		t.Fatal("the radius 0 changes the image")
+	}
+
+	// The impulse noise is removed.
+	gray := color.NRGBA{0x80, 0x80, 0x80, 0xff}
+	noisy := New(20, 20, gray)
+	for _, p := range []image.Point{{3, 3}, {10, 4}, {15, 12}, {0, 0}, {19, 19}, {7, 16}} {
+		noisy.SetNRGBA(p.X, p.Y, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+		noisy.SetNRGBA(p.X, (p.Y+9)%20, color.NRGBA{0, 0, 0, 0xff})
+	}
+	if !compareNRGBA(MedianFilter(noisy, 1), New(20, 20, gray), 0) {
+		t.Fatal("the impulse noise isn't removed")
+	}
+
+	// The edges are kept.
+	step := stepImage(20, 10)
+	for _, r := range []int{1, 2, 5} {
+		if !compareNRGBA(MedianFilter(step, r), step, 0) {
+			t.Fatalf("the radius %d changes the edge", r)
+		}
+	}
+
+	// The colors of the transparent pixels are ignored.
+	green := color.NRGBA{0, 0xff, 0, 0xff}
+	img := New(3, 3, green)
+	img.SetNRGBA(1, 1, color.NRGBA{0xff, 0, 0, 0})
+	img.SetNRGBA(2, 1, color.NRGBA{0xff, 0, 0, 0})
+	if got := MedianFilter(img, 1).NRGBAAt(1, 1); got != green {
+		t.Fatalf("got %v want %v", got, green)
+	}
+
+	if got := MedianFilter(&image.NRGBA{}, 2); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v for an empty image", got.Rect)
+	}
+}
+
+func TestBilateralFilter(t *testing.T) {
+	src := testdataFlowersSmallPNG
+	for _, sigmas := range [][2]float64{{0, 20}, {2, 0}, {-1, -1}} {
+		if !compareNRGBA(BilateralFilter(src, sigmas[0], sigmas[1]), toNRGBA(src), 0) {
+			t.Fatalf("the sigmas %v change the image", sigmas)
+		}
+	}
+
+	gray := New(20, 20, color.NRGBA{0x80, 0x80, 0x80, 0xff})
+	if !compareNRGBA(BilateralFilter(gray, 2, 20), gray, 0) {
+		t.Fatal("the flat image is changed")
+	}
+
+	// The edges are kept.
+	step := stepImage(20, 10)
+	if !compareNRGBA(BilateralFilter(step, 3, 20), step, 0) {
+		t.Fatal("the edge is changed")
+	}
+
+	// The noise is reduced.
+	rnd := rand.New(rand.NewSource(1))
+	noisy := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for i := 0; i < len(noisy.Pix); i += 4 {
+		v := uint8(0x80 + rnd.Intn(21) - 10)
+		copy(noisy.Pix[i:i+4], []uint8{v, v, v, 0xff})
+	}
+	deviation := func(img *image.NRGBA) int {
+		sum := 0
+		for i := 0; i < len(img.Pix); i += 4 {
+			sum += absint(int(img.Pix[i]) - 0x80)
+		}
+		return sum
+	}
+	if got, before := deviation(BilateralFilter(noisy, 2, 30)), deviation(noisy); got*3 > before {
+		t.Fatalf("got deviation %d want at most a third of %d", got, before)
+	}
+
+	// With a large sigmaColor it's a Gaussian blur.
+	if !compareNRGBA(BilateralFilter(src, 1.5, 1e6), Blur(src, 1.5), 2) {
+		t.Fatal("the result differs from Blur")
+	}
+
+	if got := BilateralFilter(&image.NRGBA{}, 2, 20); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v for an empty image", got.Rect)
+	}
+}
+
+func BenchmarkMedianFilter(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		MedianFilter(testdataBranchesJPG, 3)
+	}
+}
+
+func BenchmarkBilateralFilter(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		BilateralFilter(testdataBranchesJPG, 2, 25)
+	}
+}
@@ -0,0 +1,252 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// DitherMethod specifies the dithering algorithm used to map an image to a palette.
+// It implements the draw.Drawer interface, so it can be passed to the GIFDrawer encode option.
+type DitherMethod int
+
+// Dithering methods.
+const (
+	// DitherNone maps each pixel to the nearest palette color.
+	DitherNone DitherMethod = iota
+
+	// DitherFloydSteinberg is the Floyd-Steinberg error diffusion.
+	DitherFloydSteinberg
+
+	// DitherAtkinson is the Atkinson error diffusion. It diffuses only 3/4 of the error
+	// and gives a higher-contrast result than Floyd-Steinberg.
+	DitherAtkinson
+
+	// DitherBayer is the ordered dithering with an 8x8 Bayer threshold matrix.
+	// Unlike error diffusion it is stable between frames of an animation.
+	DitherBayer
+)
+
+type diffusionWeight struct {
+	dx, dy int
+	w      float64
+}
+
+var diffusionKernels = map[DitherMethod][]diffusionWeight{
+	DitherFloydSteinberg: {
+		{1, 0, 7.0 / 16},
+		{-1, 1, 3.0 / 16},
+		{0, 1, 5.0 / 16},
+		{1, 1, 1.0 / 16},
+	},
+	DitherAtkinson: {
+		{1, 0, 1.0 / 8},
+		{2, 0, 1.0 / 8},
+		{-1, 1, 1.0 / 8},
+		{0, 1, 1.0 / 8},
+		{1, 1, 1.0 / 8},
+		{0, 2, 1.0 / 8},
+	},
+}
+
+var bayer8x8 = [8][8]float64{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+// Dither maps the image to the given palette using the specified dithering method
+// and returns the resulting paletted image. The palette must not be empty,
+// only its first 256 colors are used.
+//
+// Example:
+//
+//	dstImage := imaging.Dither(srcImage, palette.WebSafe, imaging.DitherFloydSteinberg)
+func Dither(img image.Image, p color.Palette, method DitherMethod) *image.Paletted {
+	if len(p) == 0 {
+		return &image.Paletted{}
+	}
+	if len(p) > 256 {
+		p = p[:256]
+	}
+
+	src := newScanner(img)
+	dst := image.NewPaletted(image.Rect(0, 0, src.w, src.h), p)
+	if src.w <= 0 || src.h <= 0 {
+		return dst
+	}
+	ditherTo(dst, src, method)
+	return dst
+}
+
+// Draw implements the draw.Drawer interface. If dst is a paletted image, the r rectangle
+// of dst is filled with the src image colors aligned at sp mapped to the dst palette using
+// the dithering method. Otherwise the src image is copied to dst.
+func (m DitherMethod) Draw(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point) {
+	pm, ok := dst.(*image.Paletted)
+	if !ok || len(pm.Palette) == 0 {
+		draw.Draw(dst, r, src, sp, draw.Src)
+		return
+	}
+
+	r = r.Intersect(pm.Rect)
+	sr := image.Rectangle{Min: sp, Max: sp.Add(r.Size())}
+	clipped := sr.Intersect(src.Bounds())
+	r.Min = r.Min.Add(clipped.Min.Sub(sr.Min))
+	r.Max = r.Min.Add(clipped.Size())
+	sr = clipped
+	if r.Empty() {
+		return
+	}
+
+	sub := pm.SubImage(r).(*image.Paletted)
+	ditherTo(sub, newScanner(Crop(src, sr)), m)
+}
+
+// ditherTo fills dst (which must have the same size as src) with palette indexes.
+func ditherTo(dst *image.Paletted, src *scanner, method DitherMethod) {
+	pal := newPaletteMatcher(dst.Palette)
+
+	switch method {
+	case DitherFloydSteinberg, DitherAtkinson:
+		ditherDiffusion(dst, src, pal, diffusionKernels[method])
+
+	case DitherBayer:
+		spread := 255 / cbrtPaletteSize(len(dst.Palette))
+		parallel(0, src.h, func(ys <-chan int) {
+			scanLine := make([]uint8, src.w*4)
+			for y := range ys {
+				src.scan(0, y, src.w, y+1, scanLine)
+				row := dst.Pix[y*dst.Stride : y*dst.Stride+src.w]
+				for x := range row {
+					s := scanLine[x*4 : x*4+4 : x*4+4]
+					t := ((bayer8x8[y%8][x%8]+0.5)/64 - 0.5) * spread
+					row[x] = pal.nearest(
+						float64(s[0])+t,
+						float64(s[1])+t,
+						float64(s[2])+t,
+						float64(s[3]),
+					)
+				}
+			}
+		})
+
+	default:
+		parallel(0, src.h, func(ys <-chan int) {
+			scanLine := make([]uint8, src.w*4)
+			for y := range ys {
+				src.scan(0, y, src.w, y+1, scanLine)
+				row := dst.Pix[y*dst.Stride : y*dst.Stride+src.w]
+				for x := range row {
+					s := scanLine[x*4 : x*4+4 : x*4+4]
+					row[x] = pal.nearest(float64(s[0]), float64(s[1]), float64(s[2]), float64(s[3]))
+				}
+			}
+		})
+	}
+}
+
+// ditherDiffusion performs error diffusion dithering. The error of the red, green
+// and blue channels is carried over to the neighbouring pixels using the kernel weights.
+func ditherDiffusion(dst *image.Paletted, src *scanner, pal *paletteMatcher, kernel []diffusionWeight) {
+	maxDY := 0
+	for _, k := range kernel {
+		if k.dy > maxDY {
+			maxDY = k.dy
+		}
+	}
+
+	// errs holds the accumulated error for the current row and the rows below it.
+	errs := make([][]float64, maxDY+1)
+	for i := range errs {
+		errs[i] = make([]float64, src.w*3)
+	}
+
+	scanLine := make([]uint8, src.w*4)
+	for y := 0; y < src.h; y++ {
+		src.scan(0, y, src.w, y+1, scanLine)
+		cur := errs[0]
+		row := dst.Pix[y*dst.Stride : y*dst.Stride+src.w]
+		for x := 0; x < src.w; x++ {
+			s := scanLine[x*4 : x*4+4 : x*4+4]
+			r := float64(s[0]) + cur[x*3+0]
+			g := float64(s[1]) + cur[x*3+1]
+			b := float64(s[2]) + cur[x*3+2]
+			idx := pal.nearest(r, g, b, float64(s[3]))
+			row[x] = idx
+
+			c := pal.colors[idx]
+			er := r - float64(c.R)
+			eg := g - float64(c.G)
+			eb := b - float64(c.B)
+			for _, k := range kernel {
+				nx := x + k.dx
+				if nx < 0 || nx >= src.w || y+k.dy >= src.h {
+					continue
+				}
+				e := errs[k.dy][nx*3 : nx*3+3 : nx*3+3]
+				e[0] += er * k.w
+				e[1] += eg * k.w
+				e[2] += eb * k.w
+			}
+		}
+
+		// Rotate the error rows and clear the new last row.
+		copy(errs, errs[1:])
+		errs[maxDY] = cur
+		for i := range cur {
+			cur[i] = 0
+		}
+	}
+}
+
+func cbrtPaletteSize(n int) float64 {
+	c := 1.0
+	for (c+1)*(c+1)*(c+1) <= float64(n) {
+		c++
+	}
+	return c
+}
+
+// paletteMatcher finds the nearest palette colors.
+type paletteMatcher struct {
+	colors []color.NRGBA
+}
+
+func newPaletteMatcher(p color.Palette) *paletteMatcher {
+	m := &paletteMatcher{colors: make([]color.NRGBA, len(p))}
+	for i, c := range p {
+		m.colors[i] = color.NRGBAModel.Convert(c).(color.NRGBA)
+	}
+	return m
+}
+
+// nearest returns the index of the palette color nearest to the given
+// non-premultiplied color. The color components may be out of the [0, 255] range.
+func (m *paletteMatcher) nearest(r, g, b, a float64) uint8 {
+	best := 0
+	bestDist := -1.0
+	for i, c := range m.colors {
+		// The color difference matters less for translucent colors.
+		ca := float64(c.A)
+		wa := (a + ca) / 510
+		dr := (r - float64(c.R)) * wa
+		dg := (g - float64(c.G)) * wa
+		db := (b - float64(c.B)) * wa
+		da := a - ca
+		dist := dr*dr + dg*dg + db*db + da*da
+		if bestDist < 0 || dist < bestDist {
+			best = i
+			bestDist = dist
+			if dist == 0 {
+				break
+			}
+		}
+	}
+	return uint8(best)
+}
@@ -0,0 +1,104 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDither(t *testing.T) {
+	bw := color.Palette{color.Black, color.White}
+	gray := New(16, 16, color.NRGBA{0x80, 0x80, 0x80, 0xff})
+
+	testCases := []struct {
+		name     string
+		method   DitherMethod
+		min, max int
+	}{
+		{"Dither none", DitherNone, 256, 256},
+		{"Dither Floyd-Steinberg", DitherFloydSteinberg, 124, 132},
+		{"Dither Atkinson", DitherAtkinson, 100, 156},
+		{"Dither Bayer", DitherBayer, 124, 132},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Dither(gray, bw, tc.method)
+			if !got.Rect.Eq(gray.Rect) {
+				t.Fatalf("got bounds %v want %v", got.Rect, gray.Rect)
+			}
+			white := 0
+			for _, idx := range got.Pix {
+				if idx == 1 {
+					white++
+				}
+			}
+			if white < tc.min || white > tc.max {
+				t.Fatalf("got %d white pixels want [%d, %d]", white, tc.min, tc.max)
+			}
+		})
+	}
+}
+
+func TestDitherExact(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 0),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0xff, 0x00, 0x00, 0xff, 0x00, 0xff, 0x00, 0xff, 0x00, 0x00, 0x00, 0x00,
+		},
+	}
+	p := color.Palette{
+		color.NRGBA{0x00, 0x00, 0x00, 0x00},
+		color.NRGBA{0x00, 0xff, 0x00, 0xff},
+		color.NRGBA{0xff, 0x00, 0x00, 0xff},
+	}
+	for _, method := range []DitherMethod{DitherNone, DitherFloydSteinberg, DitherAtkinson, DitherBayer} {
+		got := Dither(src, p, method)
+		want := []uint8{2, 1, 0}
+		if !compareBytes(got.Pix, want, 0) {
+			t.Fatalf("method %d: got %v want %v", method, got.Pix, want)
+		}
+	}
+}
+
+func TestDitherEmptyPalette(t *testing.T) {
+	got := Dither(testdataFlowersSmallPNG, nil, DitherFloydSteinberg)
+	if !got.Rect.Empty() {
+		t.Fatalf("got bounds %v want empty", got.Rect)
+	}
+}
+
+func BenchmarkDither(b *testing.B) {
+	p := color.Palette{color.Black, color.White, color.NRGBA{0xff, 0, 0, 0xff}, color.NRGBA{0, 0, 0xff, 0xff}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Dither(testdataBranchesJPG, p, DitherFloydSteinberg)
+	}
+}
+
+func TestDitherMethodDraw(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(10, 10, 13, 11),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0xff, 0x00, 0x00, 0xff, 0x00, 0xff, 0x00, 0xff, 0x00, 0x00, 0x00, 0x00,
+		},
+	}
+	p := color.Palette{
+		color.NRGBA{0x00, 0x00, 0x00, 0x00},
+		color.NRGBA{0x00, 0xff, 0x00, 0xff},
+		color.NRGBA{0xff, 0x00, 0x00, 0xff},
+	}
+
+	dst := image.NewPaletted(image.Rect(0, 0, 4, 1), p)
+	DitherFloydSteinberg.Draw(dst, image.Rect(1, 0, 4, 1), src, image.Pt(10, 10))
+	if want := []uint8{0, 2, 1, 0}; !compareBytes(dst.Pix, want, 0) {
+		t.Fatalf("got %v want %v", dst.Pix, want)
+	}
+
+	rgba := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	DitherFloydSteinberg.Draw(rgba, rgba.Rect, src, src.Rect.Min)
+	if !compareNRGBA(rgba, Clone(src), 0) {
+		t.Fatalf("got %#v want %#v", rgba, src)
+	}
+}
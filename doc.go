@@ -3,5 +3,11 @@ Package imaging provides basic image processing functions (resize, rotate, crop,
 
 All the image processing functions provided by the package accept any image type that implements image.Image interface
 as an input, and return a new image of *image.NRGBA type (32bit RGBA colors, non-premultiplied alpha).
+
+A few formats are handled by external programs: PDF documents (DecodePDF) and JPEG 2000 and
+JPEG XL images (Decode and Encode). Starting processes is not expected from an image library,
+so these programs are only run by the programs built with the imaging_exec build tag:
+
+	go build -tags imaging_exec
 */
 package imaging
@@ -0,0 +1,207 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// DetectDocument finds the quadrilateral outline of a document (e.g. a sheet of paper)
+// photographed against a darker background. It returns the corners in the order top-left,
+// top-right, bottom-right, bottom-left, relative to the top-left corner of the image bounds,
+// ready to be passed to WarpPerspective. The ok result is false if no region large enough
+// to be a document was found.
+//
+// Example:
+//
+//	corners, ok := imaging.DetectDocument(photo)
+//	if ok {
+//		page := imaging.WarpPerspective(photo, corners, 0, 0)
+//	}
+func DetectDocument(img image.Image) (corners [4]image.Point, ok bool) {
+	const maxSize = 400
+
+	b := img.Bounds()
+	if b.Dx() <= 0 || b.Dy() <= 0 {
+		return corners, false
+	}
+
+	// Work on a downscaled, smoothed copy to ignore the document contents and noise.
+	small := Blur(Grayscale(Fit(img, maxSize, maxSize, Box)), 1.5)
+	w, h := small.Rect.Dx(), small.Rect.Dy()
+	threshold := otsuThreshold(Histogram(small))
+
+	loops := traceBoundaries(w, h, func(x, y int) bool {
+		return small.Pix[y*small.Stride+x*4] > threshold
+	})
+	var best []image.Point
+	var bestArea float64
+	for _, loop := range loops {
+		if a := polygonArea(loop); a > bestArea {
+			best, bestArea = loop, a
+		}
+	}
+	if bestArea < float64(w*h)/10 {
+		return corners, false
+	}
+
+	quad := reducePolygon(convexHull(best), 4)
+	if len(quad) != 4 {
+		return corners, false
+	}
+
+	// The blur rounds the corners, so the hull vertices are only close to them.
+	// Find the exact corners as the intersections of the lines fitted to the sides.
+	refined := refineQuad(quad, best)
+
+	// Start from the top-left corner, the hull is clockwise.
+	first := 0
+	for i, p := range refined {
+		if p[0]+p[1] < refined[first][0]+refined[first][1] {
+			first = i
+		}
+	}
+	sx := float64(b.Dx()) / float64(w)
+	sy := float64(b.Dy()) / float64(h)
+	for i := range corners {
+		p := refined[(first+i)%4]
+		corners[i] = image.Pt(int(math.Round(p[0]*sx)), int(math.Round(p[1]*sy)))
+	}
+	return corners, true
+}
+
+// refineQuad fits a line to the boundary points lying along each side of the quadrilateral
+// (ignoring the parts near its corners) and returns the intersections of the adjacent lines.
+// The corners that can't be refined are kept as is.
+func refineQuad(quad []image.Point, boundary []image.Point) [4][2]float64 {
+	// All the pixel corners along the boundary.
+	var pts []image.Point
+	for i := range boundary {
+		a, b := boundary[i], boundary[(i+1)%len(boundary)]
+		d := dirDeltas[dirOf(a, b)]
+		for p := a; p != b; p = p.Add(d) {
+			pts = append(pts, p)
+		}
+	}
+
+	// Fit the lines in the form nx*x + ny*y = c with a unit normal (nx, ny).
+	var lines [4][3]float64
+	var fitted [4]bool
+	for i := range lines {
+		a, b := quad[i], quad[(i+1)%4]
+		dx, dy := float64(b.X-a.X), float64(b.Y-a.Y)
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			continue
+		}
+		var n, mx, my float64
+		var sel [][2]float64
+		for _, p := range pts {
+			px, py := float64(p.X-a.X), float64(p.Y-a.Y)
+			t := (px*dx + py*dy) / (length * length)
+			dist := math.Abs(px*dy-py*dx) / length
+			if t < 0.15 || t > 0.85 || dist > 3+length/50 {
+				continue
+			}
+			sel = append(sel, [2]float64{float64(p.X), float64(p.Y)})
+			mx += float64(p.X)
+			my += float64(p.Y)
+			n++
+		}
+		if n < 2 {
+			continue
+		}
+		mx /= n
+		my /= n
+		var sxx, sxy, syy float64
+		for _, p := range sel {
+			x, y := p[0]-mx, p[1]-my
+			sxx += x * x
+			sxy += x * y
+			syy += y * y
+		}
+		// The direction of the line is the principal axis of the points.
+		angle := math.Atan2(2*sxy, sxx-syy) / 2
+		nx, ny := -math.Sin(angle), math.Cos(angle)
+		lines[i] = [3]float64{nx, ny, nx*mx + ny*my}
+		fitted[i] = true
+	}
+
+	var refined [4][2]float64
+	for i := range refined {
+		// Corner i is the intersection of the sides i-1 and i.
+		refined[i] = [2]float64{float64(quad[i].X), float64(quad[i].Y)}
+		j := (i + 3) % 4
+		if !fitted[i] || !fitted[j] {
+			continue
+		}
+		l1, l2 := lines[j], lines[i]
+		det := l1[0]*l2[1] - l1[1]*l2[0]
+		if math.Abs(det) < 1e-6 {
+			continue
+		}
+		x := (l1[2]*l2[1] - l1[1]*l2[2]) / det
+		y := (l1[0]*l2[2] - l1[2]*l2[0]) / det
+		// Reject intersections far away from the approximate corner.
+		if math.Hypot(x-refined[i][0], y-refined[i][1]) > 20 {
+			continue
+		}
+		refined[i] = [2]float64{x, y}
+	}
+	return refined
+}
+
+// convexHull returns the convex hull of the points, clockwise in image coordinates
+// (the y axis points down), using the monotone chain algorithm.
+func convexHull(pts []image.Point) []image.Point {
+	sorted := make([]image.Point, len(pts))
+	copy(sorted, pts)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		return a.X < b.X || (a.X == b.X && a.Y < b.Y)
+	})
+	if len(sorted) < 3 {
+		return sorted
+	}
+
+	cross := func(o, a, b image.Point) int {
+		return (a.X-o.X)*(b.Y-o.Y) - (a.Y-o.Y)*(b.X-o.X)
+	}
+	hull := make([]image.Point, 0, 2*len(sorted))
+	for _, p := range sorted {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	lower := len(hull) + 1
+	for i := len(sorted) - 2; i >= 0; i-- {
+		p := sorted[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	return hull[:len(hull)-1]
+}
+
+// reducePolygon simplifies the polygon to n vertices by repeatedly removing
+// the vertex forming the smallest triangle with its neighbours.
+func reducePolygon(poly []image.Point, n int) []image.Point {
+	poly = append([]image.Point(nil), poly...)
+	for len(poly) > n {
+		minIdx := 0
+		minArea := math.Inf(1)
+		for i := range poly {
+			a := poly[(i+len(poly)-1)%len(poly)]
+			b := poly[i]
+			c := poly[(i+1)%len(poly)]
+			area := math.Abs(float64((b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)))
+			if area < minArea {
+				minIdx, minArea = i, area
+			}
+		}
+		poly = append(poly[:minIdx], poly[minIdx+1:]...)
+	}
+	return poly
+}
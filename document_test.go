@@ -0,0 +1,126 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// drawDocument draws a light quadrilateral page with a few dark text lines on a dark background.
+func drawDocument(w, h int, corners [4]image.Point) *image.NRGBA {
+	img := New(w, h, color.NRGBA{0x30, 0x38, 0x40, 0xff})
+	inside := func(x, y int) bool {
+		for i := range corners {
+			a, b := corners[i], corners[(i+1)%4]
+			if (b.X-a.X)*(2*y+1-2*a.Y)-(b.Y-a.Y)*(2*x+1-2*a.X) < 0 {
+				return false
+			}
+		}
+		return true
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !inside(x, y) {
+				continue
+			}
+			c := color.NRGBA{0xf0, 0xee, 0xe8, 0xff}
+			if y%(h/10) < 3 && x%17 < 12 {
+				c = color.NRGBA{0x20, 0x20, 0x20, 0xff}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestDetectDocument(t *testing.T) {
+	testCases := []struct {
+		name    string
+		w, h    int
+		corners [4]image.Point
+	}{
+		{
+			"rotated",
+			300, 200,
+			[4]image.Point{{60, 30}, {250, 50}, {230, 180}, {40, 160}},
+		},
+		{
+			"perspective",
+			300, 200,
+			[4]image.Point{{100, 20}, {200, 20}, {280, 190}, {20, 190}},
+		},
+		{
+			"downscaled",
+			1200, 900,
+			[4]image.Point{{200, 100}, {1000, 160}, {1100, 850}, {80, 800}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			img := drawDocument(tc.w, tc.h, tc.corners)
+			got, ok := DetectDocument(img)
+			if !ok {
+				t.Fatalf("document not found")
+			}
+			tol := 3 * (tc.w + 399) / 400
+			for i := range got {
+				d := got[i].Sub(tc.corners[i])
+				if absint(d.X) > tol || absint(d.Y) > tol {
+					t.Fatalf("got corners %v want %v", got, tc.corners)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectDocumentNotFound(t *testing.T) {
+	testCases := []struct {
+		name string
+		img  image.Image
+	}{
+		{"empty", &image.NRGBA{}},
+		{"small", drawDocument(200, 200, [4]image.Point{{10, 10}, {40, 10}, {40, 40}, {10, 40}})},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, ok := DetectDocument(tc.img); ok {
+				t.Fatalf("got corners %v want none", got)
+			}
+		})
+	}
+}
+
+func TestConvexHull(t *testing.T) {
+	pts := []image.Point{{0, 0}, {2, 1}, {4, 0}, {3, 2}, {4, 4}, {1, 3}, {0, 4}, {2, 2}}
+	want := []image.Point{{0, 0}, {4, 0}, {4, 4}, {0, 4}}
+	got := convexHull(pts)
+	if len(got) != len(want) {
+		t.Fatalf("got hull %v want %v", got, want)
+	}
+	// The hull may start at any vertex but must be clockwise.
+	start := 0
+	for i, p := range got {
+		if p == want[0] {
+			start = i
+		}
+	}
+	for i := range want {
+		if got[(start+i)%len(got)] != want[i] {
+			t.Fatalf("got hull %v want %v", got, want)
+		}
+	}
+}
+
+func TestReducePolygon(t *testing.T) {
+	poly := []image.Point{{0, 0}, {5, 1}, {10, 0}, {10, 10}, {5, 11}, {0, 10}}
+	want := []image.Point{{0, 0}, {10, 0}, {10, 10}, {0, 10}}
+	got := reducePolygon(poly, 4)
+	if len(got) != len(want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v want %v", got, want)
+		}
+	}
+}
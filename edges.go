@@ -0,0 +1,209 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// Sobel returns the gradient magnitude of the image luminance computed with the 3x3 Sobel
+// operator as a grayscale image. The gradients are normalized so that a sharp step from
+// black to white gives the magnitude 255, larger magnitudes are clipped. The edge pixels
+// are repeated beyond the image bounds.
+//
+// Example:
+//
+//	edges := imaging.Sobel(srcImage)
+func Sobel(img image.Image) *image.NRGBA {
+	lum, w, h := luminancePlane(img)
+	gx, gy := gradients(lum, w, h, sobelWeights)
+	return magnitudeImage(gx, gy, w, h)
+}
+
+// Scharr returns the gradient magnitude of the image luminance computed with the 3x3 Scharr
+// operator as a grayscale image, normalized like Sobel. The Scharr operator is more accurate
+// for the diagonal edges than the Sobel operator.
+//
+// Example:
+//
+//	edges := imaging.Scharr(srcImage)
+func Scharr(img image.Image) *image.NRGBA {
+	lum, w, h := luminancePlane(img)
+	gx, gy := gradients(lum, w, h, scharrWeights)
+	return magnitudeImage(gx, gy, w, h)
+}
+
+// Canny detects the edges of the image using the Canny edge detector and returns a binary
+// image with the white edges one pixel wide on the black background, which can be passed
+// to HoughLines or FindContours. The luminance is smoothed with a 5x5 Gaussian filter,
+// the Sobel gradients are thinned to their local maxima across the edges and the edges are
+// traced by hysteresis: the pixels with a gradient magnitude of at least high start the
+// edges, which continue through the pixels with a magnitude of at least low.
+// The thresholds are in the range of the Sobel magnitudes, 0 to 255, typically
+// with high two or three times low.
+//
+// Example:
+//
+//	edges := imaging.Canny(srcImage, 20, 50)
+func Canny(img image.Image, low, high float64) *image.NRGBA {
+	if low > high {
+		low, high = high, low
+	}
+	lum, w, h := luminancePlane(img)
+	dst := newNRGBA(image.Rect(0, 0, w, h))
+	for i := 3; i < len(dst.Pix); i += 4 {
+		dst.Pix[i] = 0xff
+	}
+	if w == 0 || h == 0 {
+		return dst
+	}
+
+	gx, gy := gradients(gaussianSmooth(lum, w, h), w, h, sobelWeights)
+	mag := make([]float64, w*h)
+	for i := range mag {
+		mag[i] = math.Hypot(gx[i], gy[i])
+	}
+
+	// Non-maximum suppression: keep the pixels whose magnitude is the largest among the
+	// neighbours along the gradient direction, rounded to one of four directions.
+	const tan22, tan67 = 0.41421356237, 2.41421356237
+	at := func(x, y int) float64 {
+		if x < 0 || y < 0 || x >= w || y >= h {
+			return 0
+		}
+		return mag[y*w+x]
+	}
+	thin := make([]float64, w*h)
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				i := y*w + x
+				m := mag[i]
+				if m < low || m == 0 {
+					continue
+				}
+				ax, ay := math.Abs(gx[i]), math.Abs(gy[i])
+				var dx, dy int
+				switch {
+				case ay <= ax*tan22:
+					dx = 1
+				case ay >= ax*tan67:
+					dy = 1
+				case (gx[i] > 0) == (gy[i] > 0):
+					dx, dy = 1, 1
+				default:
+					dx, dy = 1, -1
+				}
+				// Break the ties of the plateaus in favour of one side.
+				if m > at(x-dx, y-dy) && m >= at(x+dx, y+dy) {
+					thin[i] = m
+				}
+			}
+		}
+	})
+
+	// Hysteresis: trace the edges from the strong pixels through the weak ones.
+	var stack []int
+	for i, m := range thin {
+		if m >= high {
+			dst.Pix[i*4] = 0xff
+			stack = append(stack, i)
+		}
+	}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		x, y := i%w, i/w
+		for ny := maxint(y-1, 0); ny <= minint(y+1, h-1); ny++ {
+			for nx := maxint(x-1, 0); nx <= minint(x+1, w-1); nx++ {
+				j := ny*w + nx
+				if thin[j] >= low && dst.Pix[j*4] == 0 {
+					dst.Pix[j*4] = 0xff
+					stack = append(stack, j)
+				}
+			}
+		}
+	}
+
+	for i := 0; i < len(dst.Pix); i += 4 {
+		dst.Pix[i+1] = dst.Pix[i]
+		dst.Pix[i+2] = dst.Pix[i]
+	}
+	return dst
+}
+
+// Smoothing weights of the derivative operators across the derivative direction.
+var (
+	sobelWeights  = [3]float64{1, 2, 1}
+	scharrWeights = [3]float64{3, 10, 3}
+)
+
+// gradients returns the horizontal and vertical gradients of the plane computed with
+// the 3x3 derivative operator smoothing with the given weights. The gradients are divided
+// by the sum of the weights, so they are the differences of the values per two pixels.
+func gradients(p []float64, w, h int, weights [3]float64) (gx, gy []float64) {
+	gx = make([]float64, w*h)
+	gy = make([]float64, w*h)
+	norm := weights[0] + weights[1] + weights[2]
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			rows := [3]int{maxint(y-1, 0) * w, y * w, minint(y+1, h-1) * w}
+			for x := 0; x < w; x++ {
+				cols := [3]int{maxint(x-1, 0), x, minint(x+1, w-1)}
+				var sx, sy float64
+				for k := 0; k < 3; k++ {
+					sx += weights[k] * (p[rows[k]+cols[2]] - p[rows[k]+cols[0]])
+					sy += weights[k] * (p[rows[2]+cols[k]] - p[rows[0]+cols[k]])
+				}
+				gx[y*w+x] = sx / norm
+				gy[y*w+x] = sy / norm
+			}
+		}
+	})
+	return gx, gy
+}
+
+// magnitudeImage returns the grayscale image of the gradient magnitudes.
+func magnitudeImage(gx, gy []float64, w, h int) *image.NRGBA {
+	dst := newNRGBA(image.Rect(0, 0, w, h))
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				v := clamp(math.Hypot(gx[y*w+x], gy[y*w+x]))
+				d := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4 : y*dst.Stride+x*4+4]
+				d[0], d[1], d[2], d[3] = v, v, v, 0xff
+			}
+		}
+	})
+	return dst
+}
+
+// gaussianSmooth returns the plane smoothed with the separable 5x5 binomial filter,
+// approximating the Gaussian filter with sigma = 1. The edge values are repeated.
+func gaussianSmooth(p []float64, w, h int) []float64 {
+	weights := [5]float64{1, 4, 6, 4, 1}
+	tmp := make([]float64, w*h)
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				var sum float64
+				for k := -2; k <= 2; k++ {
+					sum += weights[k+2] * p[y*w+minint(maxint(x+k, 0), w-1)]
+				}
+				tmp[y*w+x] = sum / 16
+			}
+		}
+	})
+	dst := make([]float64, w*h)
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				var sum float64
+				for k := -2; k <= 2; k++ {
+					sum += weights[k+2] * tmp[minint(maxint(y+k, 0), h-1)*w+x]
+				}
+				dst[y*w+x] = sum / 16
+			}
+		}
+	})
+	return dst
+}
@@ -0,0 +1,155 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestSobelScharr(t *testing.T) {
+	for name, fn := range map[string]func(image.Image) *image.NRGBA{"Sobel": Sobel, "Scharr": Scharr} {
+		// A vertical step from black to white between x = 9 and x = 10.
+		src := New(20, 10, color.Black)
+		for y := 0; y < 10; y++ {
+			for x := 10; x < 20; x++ {
+				src.SetNRGBA(x, y, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+			}
+		}
+		got := fn(src)
+		if got.Rect != image.Rect(0, 0, 20, 10) {
+			t.Fatalf("%s: got bounds %v", name, got.Rect)
+		}
+		for y := 0; y < 10; y++ {
+			for x := 0; x < 20; x++ {
+				want := uint8(0)
+				if x == 9 || x == 10 {
+					want = 0xff
+				}
+				if c := got.NRGBAAt(x, y); c != (color.NRGBA{want, want, want, 0xff}) {
+					t.Fatalf("%s: got %v at (%d, %d) want %d", name, c, x, y, want)
+				}
+			}
+		}
+
+		if !compareNRGBA(fn(New(8, 8, color.White)), New(8, 8, color.Black), 0) {
+			t.Fatalf("%s: got edges in a flat image", name)
+		}
+		if got := fn(&image.NRGBA{}); !got.Rect.Empty() {
+			t.Fatalf("%s: got bounds %v for an empty image", name, got.Rect)
+		}
+	}
+
+	// The gradients of a linear ramp are the differences of the values per two pixels
+	// along each axis.
+	ramp := func(dx, dy int) *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, 9, 9))
+		for y := 0; y < 9; y++ {
+			for x := 0; x < 9; x++ {
+				v := uint8(100 + 10*(dx*x+dy*y))
+				img.SetNRGBA(x, y, color.NRGBA{v, v, v, 0xff})
+			}
+		}
+		return img
+	}
+	if c := Scharr(ramp(1, 0)).NRGBAAt(4, 4); c.R != 20 {
+		t.Fatalf("got magnitude %d want 20", c.R)
+	}
+	if c := Sobel(ramp(1, 1)).NRGBAAt(4, 4); c.R != 28 {
+		t.Fatalf("got magnitude %d want 28", c.R)
+	}
+}
+
+func TestCanny(t *testing.T) {
+	src := squareImage()
+	got := Canny(src, 20, 50)
+	edges := 0
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			c := got.NRGBAAt(x, y)
+			if c.A != 0xff || c.R != c.G || c.G != c.B || (c.R != 0 && c.R != 0xff) {
+				t.Fatalf("got %v at (%d, %d) want black or white", c, x, y)
+			}
+			if c.R == 0 {
+				continue
+			}
+			edges++
+			// The edges lie on the boundary of the square between (10, 10) and (29, 19).
+			if x < 8 || x > 31 || y < 8 || y > 21 || (x > 11 && x < 28 && y > 11 && y < 18) {
+				t.Fatalf("got an edge at (%d, %d) away from the square", x, y)
+			}
+		}
+	}
+	// The edges are one pixel wide.
+	for y := 12; y < 18; y++ {
+		n := 0
+		for x := 0; x < 40; x++ {
+			if got.NRGBAAt(x, y).R != 0 {
+				n++
+			}
+		}
+		if n != 2 {
+			t.Fatalf("got %d edge pixels in row %d want 2", n, y)
+		}
+	}
+	if edges < 50 {
+		t.Fatalf("got %d edge pixels want the outline of the square", edges)
+	}
+
+	// The swapped thresholds are the same.
+	if !compareNRGBA(Canny(src, 50, 20), got, 0) {
+		t.Fatal("the swapped thresholds give a different result")
+	}
+
+	black := New(40, 30, color.Black)
+	if !compareNRGBA(Canny(src, 300, 400), black, 0) {
+		t.Fatal("got edges above the maximum magnitude")
+	}
+	if !compareNRGBA(Canny(New(40, 30, color.White), 1, 2), black, 0) {
+		t.Fatal("got edges in a flat image")
+	}
+	if got := Canny(&image.NRGBA{}, 20, 50); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v for an empty image", got.Rect)
+	}
+}
+
+func TestCannyHysteresis(t *testing.T) {
+	// An edge whose contrast fades: the weak part is kept only if it's connected to
+	// the strong part.
+	src := New(40, 20, color.Black)
+	for x := 0; x < 40; x++ {
+		v := uint8(255 - 215*x/39)
+		for y := 10; y < 20; y++ {
+			src.SetNRGBA(x, y, color.NRGBA{v, v, v, 0xff})
+		}
+	}
+	hasEdge := func(img *image.NRGBA, x int) bool {
+		for y := 0; y < 20; y++ {
+			if img.NRGBAAt(x, y).R != 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	got := Canny(src, 20, 100)
+	if !hasEdge(got, 5) || !hasEdge(got, 35) {
+		t.Fatal("the weak edge connected to the strong one is missing")
+	}
+	got = Canny(src, 60, 100)
+	if !hasEdge(got, 5) || hasEdge(got, 35) {
+		t.Fatal("got the weak edge below the low threshold")
+	}
+
+	// The weak edge alone isn't detected.
+	weak := Crop(src, image.Rect(25, 0, 40, 20))
+	if !compareNRGBA(Canny(weak, 20, 100), New(15, 20, color.Black), 0) {
+		t.Fatal("got a weak edge without a strong one")
+	}
+}
+
+func BenchmarkCanny(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Canny(testdataBranchesJPG, 20, 50)
+	}
+}
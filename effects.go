@@ -2,6 +2,7 @@ package imaging
 
 import (
 	"image"
+	"image/color"
 	"math"
 )
 
@@ -28,12 +29,14 @@ func Blur(img image.Image, sigma float64) *image.NRGBA {
 		kernel[i] = gaussianBlurKernel(float64(i), sigma)
 	}
 
-	return blurVertical(blurHorizontal(img, kernel), kernel)
+	tmp := blurHorizontal(img, kernel)
+	defer Release(tmp)
+	return blurVertical(tmp, kernel)
 }
 
 func blurHorizontal(img image.Image, kernel []float64) *image.NRGBA {
 	src := newScanner(img)
-	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
+	dst := newNRGBA(image.Rect(0, 0, src.w, src.h))
 	radius := len(kernel) - 1
 
 	parallel(0, src.h, func(ys <-chan int) {
@@ -83,7 +86,7 @@ func blurHorizontal(img image.Image, kernel []float64) *image.NRGBA {
 
 func blurVertical(img image.Image, kernel []float64) *image.NRGBA {
 	src := newScanner(img)
-	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
+	dst := newNRGBA(image.Rect(0, 0, src.w, src.h))
 	radius := len(kernel) - 1
 
 	parallel(0, src.w, func(xs <-chan int) {
@@ -144,8 +147,9 @@ func Sharpen(img image.Image, sigma float64) *image.NRGBA {
 	}
 
 	src := newScanner(img)
-	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
+	dst := newNRGBA(image.Rect(0, 0, src.w, src.h))
 	blurred := Blur(img, sigma)
+	defer Release(blurred)
 
 	parallel(0, src.h, func(ys <-chan int) {
 		scanLine := make([]uint8, src.w*4)
@@ -167,3 +171,395 @@ func Sharpen(img image.Image, sigma float64) *image.NRGBA {
 
 	return dst
 }
+
+// UnsharpMask sharpens the image with the unsharp mask of the given radius, amount and
+// threshold, the three parameters of the unsharp mask filters of the image editors.
+// The radius is the sigma of the Gaussian blur, as in Blur, and sets the size of the
+// sharpened detail. The amount is the percentage of the difference between the image
+// and the blurred image added to the image: Sharpen is the amount = 100 with no threshold.
+// The channel values that differ from the blurred ones by less than the threshold are kept,
+// so the low-contrast noise and textures aren't sharpened. The alpha channel is kept.
+// The radius and amount must be positive.
+//
+// Example:
+//
+//	dstImage := imaging.UnsharpMask(srcImage, 1.5, 120, 4)
+func UnsharpMask(img image.Image, radius, amount float64, threshold uint8) *image.NRGBA {
+	if radius <= 0 || amount <= 0 {
+		return Clone(img)
+	}
+
+	k := amount / 100
+	src := newScanner(img)
+	dst := newNRGBA(image.Rect(0, 0, src.w, src.h))
+	blurred := Blur(img, radius)
+	defer Release(blurred)
+
+	parallel(0, src.h, func(ys <-chan int) {
+		for y := range ys {
+			i := y * dst.Stride
+			src.scan(0, y, src.w, y+1, dst.Pix[i:i+src.w*4])
+			for x := 0; x < src.w; x++ {
+				d := dst.Pix[i : i+3 : i+3]
+				b := blurred.Pix[i : i+3 : i+3]
+				for j := range d {
+					diff := int(d[j]) - int(b[j])
+					if absint(diff) >= int(threshold) {
+						d[j] = clamp(float64(d[j]) + k*float64(diff))
+					}
+				}
+				i += 4
+			}
+		}
+	})
+
+	return dst
+}
+
+// Texture enhances or smooths the fine detail of the image (surface texture, fabric, skin pores)
+// while leaving strong edges and the noise floor mostly untouched.
+// The amount parameter must be in range (-100, 100). The amount = 0 gives the original image.
+// Positive values amplify the fine detail, negative values soften it.
+//
+// Example:
+//
+//	dstImage := imaging.Texture(srcImage, 40)
+func Texture(img image.Image, amount float64) *image.NRGBA {
+	if amount == 0 {
+		return Clone(img)
+	}
+
+	const (
+		noiseFloor = 2.0  // Detail below this level is treated as noise and left alone.
+		edgeLevel  = 24.0 // Large-scale contrast at which the detail gain is halved.
+	)
+
+	amount = math.Min(math.Max(amount, -100), 100)
+	gain := 2 * amount / 100
+
+	src := newScanner(img)
+	dst := newNRGBA(image.Rect(0, 0, src.w, src.h))
+	fine := Blur(img, 1)
+	coarse := Blur(img, 4)
+
+	parallel(0, src.h, func(ys <-chan int) {
+		for y := range ys {
+			i := y * dst.Stride
+			src.scan(0, y, src.w, y+1, dst.Pix[i:i+src.w*4])
+			for x := 0; x < src.w; x++ {
+				d := dst.Pix[i : i+3 : i+3]
+				f := fine.Pix[i : i+3 : i+3]
+				c := coarse.Pix[i : i+3 : i+3]
+
+				edge := math.Abs(0.299*(float64(f[0])-float64(c[0])) +
+					0.587*(float64(f[1])-float64(c[1])) +
+					0.114*(float64(f[2])-float64(c[2])))
+				e := edge / edgeLevel
+				k := gain / (1 + e*e)
+
+				for j := 0; j < 3; j++ {
+					detail := float64(d[j]) - float64(f[j])
+					switch {
+					case detail > noiseFloor:
+						detail -= noiseFloor
+					case detail < -noiseFloor:
+						detail += noiseFloor
+					default:
+						detail = 0
+					}
+					d[j] = clamp(float64(d[j]) + k*detail)
+				}
+				i += 4
+			}
+		}
+	})
+
+	return dst
+}
+
+// Orton applies the Orton effect, a dreamy soft-focus glow popular for landscapes: a copy of
+// the image blurred with the given sigma is screen-blended over the image and the contrast
+// of the result is boosted with a sigmoidal curve to restore the depth lost to the glow.
+// The intensity parameter must be from 0.0 (no effect) to 1.0 (the full screen blend).
+// Sigma must be positive, larger values give a wider glow, e.g. 1% of the image size.
+//
+// Example:
+//
+//	dstImage := imaging.Orton(srcImage, 8, 0.6)
+func Orton(img image.Image, sigma, intensity float64) *image.NRGBA {
+	if sigma <= 0 || intensity <= 0 {
+		return Clone(img)
+	}
+
+	intensity = math.Min(intensity, 1)
+	contrast := sigmoidLUT(0.5, 4*intensity)
+
+	src := newScanner(img)
+	dst := newNRGBA(image.Rect(0, 0, src.w, src.h))
+	blurred := Blur(img, sigma)
+	defer Release(blurred)
+
+	parallel(0, src.h, func(ys <-chan int) {
+		for y := range ys {
+			i := y * dst.Stride
+			src.scan(0, y, src.w, y+1, dst.Pix[i:i+src.w*4])
+			for x := 0; x < src.w; x++ {
+				d := dst.Pix[i : i+3 : i+3]
+				b := blurred.Pix[i : i+3 : i+3]
+				for j := range d {
+					s := float64(d[j]) / 255
+					screen := 1 - (1-s)*(1-float64(b[j])/255)
+					d[j] = contrast[clamp((s+intensity*(screen-s))*255)]
+				}
+				i += 4
+			}
+		}
+	})
+
+	return dst
+}
+
+// Scanlines darkens every other row of the image to simulate the scanlines of a CRT display.
+// The intensity parameter must be from 0.0 (no effect) to 1.0 (the odd rows become black).
+//
+// Example:
+//
+//	dstImage := imaging.Scanlines(srcImage, 0.4)
+func Scanlines(img image.Image, intensity float64) *image.NRGBA {
+	intensity = math.Min(math.Max(intensity, 0.0), 1.0)
+	if intensity == 0 {
+		return Clone(img)
+	}
+
+	src := newScanner(img)
+	dst := newNRGBA(image.Rect(0, 0, src.w, src.h))
+	k := 1 - intensity
+	parallel(0, src.h, func(ys <-chan int) {
+		for y := range ys {
+			i := y * dst.Stride
+			src.scan(0, y, src.w, y+1, dst.Pix[i:i+src.w*4])
+			if y%2 == 0 {
+				continue
+			}
+			for x := 0; x < src.w; x++ {
+				d := dst.Pix[i : i+3 : i+3]
+				d[0] = clamp(float64(d[0]) * k)
+				d[1] = clamp(float64(d[1]) * k)
+				d[2] = clamp(float64(d[2]) * k)
+				i += 4
+			}
+		}
+	})
+	return dst
+}
+
+// RGBMask simulates the aperture grille of a CRT display: every pixel column
+// keeps only one of the red, green or blue channels at full strength and
+// attenuates the other two. The intensity parameter must be from 0.0 (no effect)
+// to 1.0 (the other two channels are removed).
+//
+// Example:
+//
+//	dstImage := imaging.RGBMask(srcImage, 0.3)
+func RGBMask(img image.Image, intensity float64) *image.NRGBA {
+	intensity = math.Min(math.Max(intensity, 0.0), 1.0)
+	if intensity == 0 {
+		return Clone(img)
+	}
+
+	src := newScanner(img)
+	dst := newNRGBA(image.Rect(0, 0, src.w, src.h))
+	k := 1 - intensity
+	parallel(0, src.h, func(ys <-chan int) {
+		for y := range ys {
+			i := y * dst.Stride
+			src.scan(0, y, src.w, y+1, dst.Pix[i:i+src.w*4])
+			for x := 0; x < src.w; x++ {
+				d := dst.Pix[i : i+3 : i+3]
+				keep := x % 3
+				for c := 0; c < 3; c++ {
+					if c != keep {
+						d[c] = clamp(float64(d[c]) * k)
+					}
+				}
+				i += 4
+			}
+		}
+	})
+	return dst
+}
+
+// BarrelDistortion bulges the image outwards from its center like the curved glass of a CRT display.
+// The strength parameter is typically in range (0, 0.5); negative values produce a pincushion distortion.
+// The area uncovered by the distortion is filled with the bgColor.
+//
+// Example:
+//
+//	dstImage := imaging.BarrelDistortion(srcImage, 0.1, color.Black)
+func BarrelDistortion(img image.Image, strength float64, bgColor color.Color) *image.NRGBA {
+	if strength == 0 {
+		return Clone(img)
+	}
+
+	src := toNRGBA(img)
+	w := src.Bounds().Dx()
+	h := src.Bounds().Dy()
+	dst := newNRGBA(image.Rect(0, 0, w, h))
+	if w <= 0 || h <= 0 {
+		return dst
+	}
+
+	bg := color.NRGBAModel.Convert(bgColor).(color.NRGBA)
+	cx := float64(w)/2 - 0.5
+	cy := float64(h)/2 - 0.5
+	norm := math.Hypot(float64(w)/2, float64(h)/2)
+
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				dx := (float64(x) - cx) / norm
+				dy := (float64(y) - cy) / norm
+				f := 1 + strength*(dx*dx+dy*dy)
+				interpolatePoint(dst, x, y, src, cx+dx*f*norm, cy+dy*f*norm, bg)
+			}
+		}
+	})
+	return dst
+}
+
+// CRT applies a retro display look to the image: a slight barrel distortion,
+// an RGB aperture grille and scanlines.
+//
+// Example:
+//
+//	dstImage := imaging.CRT(srcImage)
+func CRT(img image.Image) *image.NRGBA {
+	dst := BarrelDistortion(img, 0.08, color.Black)
+	dst = RGBMask(dst, 0.25)
+	return Scanlines(dst, 0.35)
+}
+
+// PencilSketch turns the image into a grayscale pencil drawing: the grayscale image is
+// color-dodged with its inverted copy blurred with the given sigma, so the flat areas become
+// white and the edges and textures remain as pencil strokes. Sigma must be positive, larger
+// values give broader and darker strokes, typically 2 to 10. The alpha channel is kept.
+//
+// Example:
+//
+//	dstImage := imaging.PencilSketch(srcImage, 5)
+func PencilSketch(img image.Image, sigma float64) *image.NRGBA {
+	if sigma <= 0 {
+		return Clone(img)
+	}
+
+	dst := Grayscale(img)
+	inverted := Invert(dst)
+	blurred := Blur(inverted, sigma)
+	Release(inverted)
+	defer Release(blurred)
+
+	parallel(0, dst.Rect.Dy(), func(ys <-chan int) {
+		for y := range ys {
+			i := y * dst.Stride
+			for x := 0; x < dst.Rect.Dx(); x++ {
+				d := dst.Pix[i : i+3 : i+3]
+				v := uint8(0xff)
+				if b := blurred.Pix[i]; b < 0xff {
+					v = clamp(math.Min(float64(d[0])*255/float64(0xff-b), 255))
+				}
+				d[0], d[1], d[2] = v, v, v
+				i += 4
+			}
+		}
+	})
+	return dst
+}
+
+// crossHatchLevels are the luminance levels below which the layers of the hatch lines
+// of CrossHatch are drawn.
+var crossHatchLevels = [4]float64{0.8, 0.6, 0.4, 0.2}
+
+// CrossHatch renders the image as a cross-hatched ink drawing with black lines on white:
+// the darker the area, the more layers of parallel lines cross it. The areas lighter than
+// 80% of white stay white, then diagonal, anti-diagonal, horizontal and vertical lines are
+// added at each fifth of the luminance. The spacing is the distance between the parallel
+// lines in pixels and must be at least 2. The alpha channel is kept.
+//
+// Example:
+//
+//	dstImage := imaging.CrossHatch(srcImage, 6)
+func CrossHatch(img image.Image, spacing int) *image.NRGBA {
+	if spacing < 2 {
+		return Clone(img)
+	}
+
+	src := newScanner(img)
+	dst := newNRGBA(image.Rect(0, 0, src.w, src.h))
+	parallel(0, src.h, func(ys <-chan int) {
+		for y := range ys {
+			i := y * dst.Stride
+			src.scan(0, y, src.w, y+1, dst.Pix[i:i+src.w*4])
+			for x := 0; x < src.w; x++ {
+				d := dst.Pix[i : i+3 : i+3]
+				lum := (0.299*float64(d[0]) + 0.587*float64(d[1]) + 0.114*float64(d[2])) / 255
+				lines := [4]bool{
+					(x+y)%spacing == 0,
+					(x-y)%spacing == 0,
+					y%spacing == spacing/2,
+					x%spacing == spacing/2,
+				}
+				v := uint8(0xff)
+				for k, level := range crossHatchLevels {
+					if lum < level && lines[k] {
+						v = 0
+						break
+					}
+				}
+				d[0], d[1], d[2] = v, v, v
+				i += 4
+			}
+		}
+	})
+	return dst
+}
+
+// Vignette fades the edges and corners of the image to the color, e.g. black for
+// the darkening of a lens or white for a faded print, drawing the eye to the center.
+// The fade follows the shape of the image: it starts at 30% of the distance from
+// the center to the corners and grows smoothly to the corners. The strength parameter
+// must be from 0.0 (no effect) to 1.0 (the corners take the color). The alpha channel is kept.
+//
+// Example:
+//
+//	dstImage := imaging.Vignette(srcImage, 0.6, color.Black)
+func Vignette(img image.Image, strength float64, c color.Color) *image.NRGBA {
+	strength = math.Min(math.Max(strength, 0.0), 1.0)
+	cc := color.NRGBAModel.Convert(c).(color.NRGBA)
+	strength *= float64(cc.A) / 255
+	if strength == 0 {
+		return Clone(img)
+	}
+
+	src := newScanner(img)
+	dst := newNRGBA(image.Rect(0, 0, src.w, src.h))
+	cx, cy := float64(src.w)/2, float64(src.h)/2
+	parallel(0, src.h, func(ys <-chan int) {
+		for y := range ys {
+			i := y * dst.Stride
+			src.scan(0, y, src.w, y+1, dst.Pix[i:i+src.w*4])
+			dy := (float64(y) + 0.5 - cy) / cy
+			for x := 0; x < src.w; x++ {
+				dx := (float64(x) + 0.5 - cx) / cx
+				// The distance is 1 at the corners.
+				t := math.Min(math.Max((math.Sqrt((dx*dx+dy*dy)/2)-0.3)/0.7, 0), 1)
+				k := strength * t * t * (3 - 2*t)
+				d := dst.Pix[i : i+3 : i+3]
+				d[0] = clamp(float64(d[0])*(1-k) + float64(cc.R)*k)
+				d[1] = clamp(float64(d[1])*(1-k) + float64(cc.G)*k)
+				d[2] = clamp(float64(d[2])*(1-k) + float64(cc.B)*k)
+				i += 4
+			}
+		}
+	})
+	return dst
+}
@@ -2,6 +2,8 @@ package imaging
 
 import (
 	"image"
+	"image/color"
+	"math"
 	"testing"
 )
 
@@ -236,3 +238,400 @@ func BenchmarkSharpen(b *testing.B) {
 		Sharpen(testdataBranchesJPG, 3)
 	}
 }
+
+func TestUnsharpMask(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 2),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66,
+			0x66, 0x66, 0x66, 0x66, 0x77, 0x77, 0x77, 0x77, 0x66, 0x66, 0x66, 0x66,
+			0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66,
+		},
+	}
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 3, 3),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x66, 0x66, 0x66, 0x66, 0x64, 0x64, 0x64, 0x66, 0x66, 0x66, 0x66, 0x66,
+			0x64, 0x64, 0x64, 0x66, 0x7d, 0x7d, 0x7d, 0x77, 0x64, 0x64, 0x64, 0x66,
+			0x66, 0x66, 0x66, 0x66, 0x64, 0x64, 0x64, 0x66, 0x66, 0x66, 0x66, 0x66,
+		},
+	}
+	if got := UnsharpMask(src, 0.5, 100, 0); !compareNRGBA(got, want, 0) {
+		t.Fatalf("got result %#v want %#v", got, want)
+	}
+	for _, params := range [][2]float64{{0, 100}, {1, 0}, {-1, -50}} {
+		if got := UnsharpMask(src, params[0], params[1], 0); !compareNRGBA(got, toNRGBA(src), 0) {
+			t.Fatalf("the radius and amount %v change the image", params)
+		}
+	}
+
+	// The amount = 100 is Sharpen for the opaque images.
+	img := testdataFlowersSmallPNG
+	if !compareNRGBA(UnsharpMask(img, 1.5, 100, 0), Sharpen(img, 1.5), 0) {
+		t.Fatal("the amount = 100 differs from Sharpen")
+	}
+
+	// The threshold keeps the low-contrast noise and the larger amount sharpens more.
+	noisy := New(20, 10, color.NRGBA{0x80, 0x80, 0x80, 0xff})
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 20; x++ {
+			v := uint8(0x80 + (x*7+y*3)%5 - 2)
+			if x >= 10 {
+				v += 0x40
+			}
+			noisy.SetNRGBA(x, y, color.NRGBA{v, v, v, 0xff})
+		}
+	}
+	got := UnsharpMask(noisy, 1, 150, 8)
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 20; x++ {
+			if (x < 7 || x > 12) && got.NRGBAAt(x, y) != noisy.NRGBAAt(x, y) {
+				t.Fatalf("the noise at (%d, %d) is sharpened", x, y)
+			}
+		}
+	}
+	overshoot := func(img *image.NRGBA) int {
+		return int(img.NRGBAAt(10, 5).R) - int(noisy.NRGBAAt(10, 5).R)
+	}
+	if overshoot(got) <= 0 || overshoot(UnsharpMask(noisy, 1, 300, 8)) <= overshoot(got) {
+		t.Fatal("the edge isn't sharpened more by the larger amount")
+	}
+	if !compareNRGBA(UnsharpMask(noisy, 1, 150, 0xff), noisy, 0) {
+		t.Fatal("the maximum threshold changes the image")
+	}
+}
+
+func BenchmarkUnsharpMask(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		UnsharpMask(testdataBranchesJPG, 3, 150, 4)
+	}
+}
+
+func TestTexture(t *testing.T) {
+	flat := New(8, 8, color.NRGBA{0x40, 0x80, 0xc0, 0xff})
+	if got := Texture(flat, 80); !compareNRGBA(got, flat, 0) {
+		t.Fatalf("flat image changed: got %#v want %#v", got, flat)
+	}
+
+	src := Clone(testdataFlowersSmallPNG)
+	if got := Texture(src, 0); !compareNRGBA(got, src, 0) {
+		t.Fatalf("zero amount changed the image")
+	}
+
+	base := detailEnergy(src)
+	if e := detailEnergy(Texture(src, 80)); e <= base {
+		t.Fatalf("positive amount: got detail energy %v want > %v", e, base)
+	}
+	if e := detailEnergy(Texture(src, -80)); e >= base {
+		t.Fatalf("negative amount: got detail energy %v want < %v", e, base)
+	}
+}
+
+// detailEnergy returns the sum of absolute differences between horizontally adjacent pixels.
+func detailEnergy(img *image.NRGBA) float64 {
+	var sum float64
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 1; x < img.Rect.Dx(); x++ {
+			i := y*img.Stride + x*4
+			for c := 0; c < 3; c++ {
+				sum += math.Abs(float64(img.Pix[i+c]) - float64(img.Pix[i+c-4]))
+			}
+		}
+	}
+	return sum
+}
+
+func BenchmarkTexture(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Texture(testdataBranchesJPG, 50)
+	}
+}
+
+func TestOrton(t *testing.T) {
+	src := testdataFlowersSmallPNG
+	for _, params := range [][2]float64{{0, 0.5}, {5, 0}, {-1, -1}} {
+		if got := Orton(src, params[0], params[1]); !compareNRGBA(got, toNRGBA(src), 0) {
+			t.Fatalf("the sigma and intensity %v change the image", params)
+		}
+	}
+	if !compareNRGBA(Orton(src, 5, 3), Orton(src, 5, 1), 0) {
+		t.Fatal("the intensity > 1 differs from the intensity = 1")
+	}
+
+	// Black and white are kept, the midtones are brightened.
+	for _, c := range []color.NRGBA{{0, 0, 0, 0xff}, {0xff, 0xff, 0xff, 0xff}} {
+		if got := Orton(New(8, 8, c), 2, 1); !compareNRGBA(got, New(8, 8, c), 0) {
+			t.Fatalf("got %v from %v", got.NRGBAAt(4, 4), c)
+		}
+	}
+	gray := Orton(New(8, 8, color.NRGBA{0x80, 0x80, 0x80, 0x80}), 2, 0.5)
+	want := gray.NRGBAAt(0, 0)
+	if want.R <= 0x80 || want.R != want.G || want.G != want.B || want.A != 0x80 {
+		t.Fatalf("got %v want a lighter gray with the same alpha", want)
+	}
+	if !compareNRGBA(gray, New(8, 8, want), 0) {
+		t.Fatal("the flat image isn't flat")
+	}
+
+	// The bright areas glow into their surroundings.
+	spot := New(30, 30, color.NRGBA{0x40, 0x40, 0x40, 0xff})
+	for y := 13; y < 17; y++ {
+		for x := 13; x < 17; x++ {
+			spot.SetNRGBA(x, y, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+		}
+	}
+	got := Orton(spot, 3, 1)
+	if near, far := got.NRGBAAt(19, 15).R, got.NRGBAAt(2, 2).R; near <= far {
+		t.Fatalf("got %d near the spot and %d far from it want a glow", near, far)
+	}
+}
+
+func BenchmarkOrton(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Orton(testdataBranchesJPG, 8, 0.6)
+	}
+}
+
+func TestScanlines(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 1),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x80, 0x40, 0xff, 0x01, 0x10, 0x20, 0x30, 0xff,
+			0x80, 0x40, 0xff, 0x01, 0x10, 0x20, 0x30, 0xff,
+		},
+	}
+	testCases := []struct {
+		name      string
+		intensity float64
+		want      *image.NRGBA
+	}{
+		{
+			"Scanlines 2x2 0",
+			0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x80, 0x40, 0xff, 0x01, 0x10, 0x20, 0x30, 0xff,
+					0x80, 0x40, 0xff, 0x01, 0x10, 0x20, 0x30, 0xff,
+				},
+			},
+		},
+		{
+			"Scanlines 2x2 0.5",
+			0.5,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x80, 0x40, 0xff, 0x01, 0x10, 0x20, 0x30, 0xff,
+					0x40, 0x20, 0x80, 0x01, 0x08, 0x10, 0x18, 0xff,
+				},
+			},
+		},
+		{
+			"Scanlines 2x2 2",
+			2,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x80, 0x40, 0xff, 0x01, 0x10, 0x20, 0x30, 0xff,
+					0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0xff,
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Scanlines(src, tc.intensity)
+			if !compareNRGBA(got, tc.want, 0) {
+				t.Fatalf("got result %#v want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRGBMask(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 4, 1),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x80, 0x80, 0x80, 0xff, 0x80, 0x80, 0x80, 0xff, 0x80, 0x80, 0x80, 0xff, 0x80, 0x80, 0x80, 0x02,
+		},
+	}
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 4, 1),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x80, 0x00, 0x00, 0xff, 0x00, 0x80, 0x00, 0xff, 0x00, 0x00, 0x80, 0xff, 0x80, 0x00, 0x00, 0x02,
+		},
+	}
+	got := RGBMask(src, 1)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("got result %#v want %#v", got, want)
+	}
+	if got := RGBMask(src, 0); !compareNRGBA(got, src, 0) {
+		t.Fatalf("zero intensity changed the image")
+	}
+}
+
+func TestBarrelDistortion(t *testing.T) {
+	src := New(9, 9, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+	src.SetNRGBA(4, 4, color.NRGBA{0xff, 0x00, 0x00, 0xff})
+
+	if got := BarrelDistortion(src, 0, color.Black); !compareNRGBA(got, src, 0) {
+		t.Fatalf("zero strength changed the image")
+	}
+
+	got := BarrelDistortion(src, 0.5, color.NRGBA{0, 0, 0, 0})
+	if !got.Rect.Eq(src.Rect) {
+		t.Fatalf("got bounds %v want %v", got.Rect, src.Rect)
+	}
+	if c := got.NRGBAAt(4, 4); c != (color.NRGBA{0xff, 0x00, 0x00, 0xff}) {
+		t.Fatalf("center pixel moved: got %v", c)
+	}
+	if c := got.NRGBAAt(0, 0); c.A != 0 {
+		t.Fatalf("corner pixel not uncovered: got %v", c)
+	}
+
+	got = BarrelDistortion(src, -0.5, color.NRGBA{0, 0, 0, 0})
+	if c := got.NRGBAAt(0, 0); c.A != 0xff {
+		t.Fatalf("pincushion corner pixel uncovered: got %v", c)
+	}
+}
+
+func BenchmarkCRT(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		CRT(testdataBranchesJPG)
+	}
+}
+
+func TestPencilSketch(t *testing.T) {
+	src := testdataFlowersSmallPNG
+	if got := PencilSketch(src, 0); !compareNRGBA(got, toNRGBA(src), 0) {
+		t.Fatal("the sigma = 0 changes the image")
+	}
+
+	// The flat areas become white.
+	flat := New(10, 10, color.NRGBA{0x30, 0x80, 0x50, 0x90})
+	if got := PencilSketch(flat, 3); !compareNRGBA(got, New(10, 10, color.NRGBA{0xff, 0xff, 0xff, 0x90}), 0) {
+		t.Fatalf("got %v want white", got.NRGBAAt(5, 5))
+	}
+
+	// The edges become gray strokes.
+	step := New(20, 10, color.NRGBA{0x40, 0x40, 0x40, 0xff})
+	for y := 0; y < 10; y++ {
+		for x := 10; x < 20; x++ {
+			step.SetNRGBA(x, y, color.NRGBA{0xc0, 0xc0, 0xc0, 0xff})
+		}
+	}
+	got := PencilSketch(step, 2)
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 20; x++ {
+			c := got.NRGBAAt(x, y)
+			if c.R != c.G || c.G != c.B || c.A != 0xff {
+				t.Fatalf("got %v at (%d, %d) want opaque gray", c, x, y)
+			}
+			if (x < 3 || x > 16) && c.R != 0xff {
+				t.Fatalf("got %v at (%d, %d) far from the edge want white", c, x, y)
+			}
+		}
+	}
+	if c := got.NRGBAAt(9, 5); c.R > 0xc0 {
+		t.Fatalf("got %v at the edge want a stroke", c)
+	}
+}
+
+func TestCrossHatch(t *testing.T) {
+	src := testdataFlowersSmallPNG
+	for _, spacing := range []int{-1, 0, 1} {
+		if got := CrossHatch(src, spacing); !compareNRGBA(got, toNRGBA(src), 0) {
+			t.Fatalf("the spacing %d changes the image", spacing)
+		}
+	}
+
+	testCases := []struct {
+		gray  uint8
+		lines func(x, y int) bool
+	}{
+		{0xf0, func(x, y int) bool { return false }},
+		{0xb0, func(x, y int) bool { return (x+y)%4 == 0 }},
+		{0x80, func(x, y int) bool { return (x+y)%4 == 0 || (x-y)%4 == 0 }},
+		{0x10, func(x, y int) bool { return (x+y)%4 == 0 || (x-y)%4 == 0 || y%4 == 2 || x%4 == 2 }},
+	}
+	for _, tc := range testCases {
+		img := New(12, 12, color.NRGBA{tc.gray, tc.gray, tc.gray, 0x80})
+		got := CrossHatch(img, 4)
+		for y := 0; y < 12; y++ {
+			for x := 0; x < 12; x++ {
+				want := color.NRGBA{0xff, 0xff, 0xff, 0x80}
+				if tc.lines(x, y) {
+					want = color.NRGBA{0, 0, 0, 0x80}
+				}
+				if c := got.NRGBAAt(x, y); c != want {
+					t.Fatalf("gray %#x: got %v at (%d, %d) want %v", tc.gray, c, x, y, want)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkPencilSketch(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		PencilSketch(testdataBranchesJPG, 5)
+	}
+}
+
+func TestVignette(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(-5, -5, 15, 15))
+	for i := 0; i < len(src.Pix); i += 4 {
+		copy(src.Pix[i:i+4], []uint8{100, 120, 140, 0xc0})
+	}
+
+	dst := Vignette(src, 1, color.Black)
+	if got := dst.NRGBAAt(10, 10); got != (color.NRGBA{100, 120, 140, 0xc0}) {
+		t.Fatalf("got center %v", got)
+	}
+	corner, edge := dst.NRGBAAt(0, 0), dst.NRGBAAt(0, 10)
+	if corner.R >= edge.R || edge.R >= 100 || corner.R > 10 || corner.A != 0xc0 {
+		t.Fatalf("got corner %v edge %v", corner, edge)
+	}
+	// The fade grows along the diagonal.
+	for i := 1; i < 10; i++ {
+		if dst.NRGBAAt(i, i).R < dst.NRGBAAt(i-1, i-1).R {
+			t.Fatalf("got %v at %d after %v", dst.NRGBAAt(i, i), i, dst.NRGBAAt(i-1, i-1))
+		}
+	}
+
+	if got := Vignette(src, 1, color.White).NRGBAAt(0, 0); got.B <= 240 {
+		t.Fatalf("got white corner %v", got)
+	}
+	for _, tc := range []struct {
+		strength float64
+		c        color.Color
+	}{
+		{0, color.Black},
+		{-1, color.Black},
+		{1, color.Transparent},
+	} {
+		if got := Vignette(src, tc.strength, tc.c); !compareNRGBA(got, Clone(src), 0) {
+			t.Fatalf("Vignette(%v, %v) changed the image", tc.strength, tc.c)
+		}
+	}
+}
+
+func BenchmarkVignette(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Vignette(testdataBranchesJPG, 0.6, color.Black)
+	}
+}
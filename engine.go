@@ -0,0 +1,190 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"sync/atomic"
+)
+
+// EngineConfig is the configuration of an Engine. The zero value is the configuration
+// of the package functions.
+type EngineConfig struct {
+	// MaxProcs limits the number of concurrent processing goroutines, see SetMaxProcs.
+	MaxProcs int
+
+	// MaxPixels, MaxWidth and MaxHeight are the decode limits, see MaxPixels and
+	// MaxDimensions. The options passed to the engine methods override them.
+	MaxPixels int
+	MaxWidth  int
+	MaxHeight int
+
+	// Filter is the resample filter of the resizing methods, Lanczos if nil.
+	Filter *ResampleFilter
+
+	// FS is the file system of Open and Save, the local file system if nil.
+	FS FileSystem
+}
+
+// Engine processes images with its own settings, so a library using the package can
+// isolate its settings from the host application and from other libraries. The package
+// functions use a default engine, which SetMaxProcs configures. Pooling and the formats
+// added by RegisterFormat are shared by all the engines.
+//
+// An Engine is safe for concurrent use.
+//
+// Example:
+//
+//	engine := imaging.NewEngine(imaging.EngineConfig{
+//		MaxProcs:  2,
+//		MaxPixels: 50e6,
+//	})
+//	img, err := engine.Open("upload.jpg", imaging.AutoOrientation(true))
+//	if err != nil {
+//		return err
+//	}
+//	err = engine.Save(engine.Thumbnail(img, 200, 200), "thumb.jpg")
+type Engine struct {
+	maxProcs  atomic.Int64
+	maxPixels int
+	maxWidth  int
+	maxHeight int
+	filter    *ResampleFilter
+	fs        FileSystem
+}
+
+// defaultEngine is the engine of the package functions.
+var defaultEngine = &Engine{}
+
+// NewEngine returns a new engine with the given configuration.
+func NewEngine(cfg EngineConfig) *Engine {
+	e := &Engine{
+		maxPixels: cfg.MaxPixels,
+		maxWidth:  cfg.MaxWidth,
+		maxHeight: cfg.MaxHeight,
+		fs:        cfg.FS,
+	}
+	e.maxProcs.Store(int64(cfg.MaxProcs))
+	if cfg.Filter != nil {
+		filter := *cfg.Filter
+		e.filter = &filter
+	}
+	return e
+}
+
+// SetMaxProcs limits the number of concurrent processing goroutines of the engine
+// to the given value. A value <= 0 clears the limit.
+func (e *Engine) SetMaxProcs(value int) {
+	e.maxProcs.Store(int64(value))
+}
+
+// resampleFilter returns the resample filter of the engine.
+func (e *Engine) resampleFilter() ResampleFilter {
+	if e.filter == nil {
+		return Lanczos
+	}
+	return *e.filter
+}
+
+// fileSystem returns the file system of the engine.
+func (e *Engine) fileSystem() FileSystem {
+	if e.fs == nil {
+		return fs
+	}
+	return e.fs
+}
+
+// decodeConfig returns the decode configuration of the engine with the options applied.
+func (e *Engine) decodeConfig(opts []DecodeOption) decodeConfig {
+	cfg := defaultDecodeConfig
+	cfg.maxPixels = e.maxPixels
+	cfg.maxWidth = e.maxWidth
+	cfg.maxHeight = e.maxHeight
+	for _, option := range opts {
+		option(&cfg)
+	}
+	return cfg
+}
+
+// Decode reads an image from r like Decode with the decode limits of the engine.
+func (e *Engine) Decode(r io.Reader, opts ...DecodeOption) (image.Image, error) {
+	img, _, err := decodeWithFormat(r, nil, e.decodeConfig(opts))
+	return img, err
+}
+
+// DecodeBytes reads an image from the byte slice like DecodeBytes with the decode limits
+// of the engine.
+func (e *Engine) DecodeBytes(b []byte, opts ...DecodeOption) (image.Image, error) {
+	img, _, err := decodeWithFormat(bytes.NewReader(b), b, e.decodeConfig(opts))
+	return img, err
+}
+
+// DecodeReaderAt reads an image of the given size from r like DecodeReaderAt with the decode
+// limits of the engine.
+func (e *Engine) DecodeReaderAt(r io.ReaderAt, size int64, opts ...DecodeOption) (image.Image, error) {
+	return decodeReaderAt(r, size, e.decodeConfig(opts))
+}
+
+// Open loads an image from file of the engine file system with the decode limits of the engine.
+func (e *Engine) Open(filename string, opts ...DecodeOption) (image.Image, error) {
+	file, err := e.fileSystem().Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return e.Decode(file, opts...)
+}
+
+// Save saves the image to file of the engine file system like Save.
+func (e *Engine) Save(img image.Image, filename string, opts ...EncodeOption) (err error) {
+	f, err := FormatFromFilename(filename)
+	if err != nil {
+		return err
+	}
+	file, err := e.fileSystem().Create(filename)
+	if err != nil {
+		return err
+	}
+	err = Encode(file, img, f, opts...)
+	errc := file.Close()
+	if err == nil {
+		err = errc
+	}
+	return err
+}
+
+// Resize resizes the image like Resize with the resample filter of the engine.
+func (e *Engine) Resize(img image.Image, width, height int) *image.NRGBA {
+	return e.resize(img, width, height, e.resampleFilter())
+}
+
+// ResizeFast resizes the image like ResizeFast.
+func (e *Engine) ResizeFast(img image.Image, width, height int) *image.NRGBA {
+	return e.resizeFast(img, width, height)
+}
+
+// Fit scales down the image like Fit with the resample filter of the engine.
+func (e *Engine) Fit(img image.Image, width, height int) *image.NRGBA {
+	return e.fit(img, width, height, e.resampleFilter())
+}
+
+// Fill fills an image of the specified size with the scaled source image like Fill
+// with the resample filter of the engine.
+func (e *Engine) Fill(img image.Image, width, height int, anchor Anchor) *image.NRGBA {
+	return e.fill(img, width, height, anchor, e.resampleFilter())
+}
+
+// Thumbnail scales and crops the image like Thumbnail with the resample filter of the engine.
+func (e *Engine) Thumbnail(img image.Image, width, height int) *image.NRGBA {
+	return e.fill(img, width, height, Center, e.resampleFilter())
+}
+
+// Crop cuts out a rectangular region of the image like Crop.
+func (e *Engine) Crop(img image.Image, rect image.Rectangle) *image.NRGBA {
+	return e.crop(img, rect)
+}
+
+// Clone returns a copy of the image like Clone.
+func (e *Engine) Clone(img image.Image) *image.NRGBA {
+	return e.clone(img)
+}
@@ -0,0 +1,171 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"os"
+	"sync/atomic"
+	"testing"
+)
+
+// memFS is an in-memory file system.
+type memFS map[string][]byte
+
+type memFile struct {
+	bytes.Buffer
+	fs   memFS
+	name string
+}
+
+func (f *memFile) Close() error {
+	f.fs[f.name] = f.Bytes()
+	return nil
+}
+
+func (m memFS) Create(name string) (io.WriteCloser, error) {
+	return &memFile{fs: m, name: name}, nil
+}
+
+func (m memFS) Open(name string) (io.ReadCloser, error) {
+	data, ok := m[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func TestEngineDefaults(t *testing.T) {
+	e := NewEngine(EngineConfig{})
+	img := testdataBranchesPNG
+	if !compareNRGBA(e.Resize(img, 150, 0), Resize(img, 150, 0, Lanczos), 0) {
+		t.Fatal("Resize differs from the package function")
+	}
+	if !compareNRGBA(e.Fit(img, 100, 100), Fit(img, 100, 100, Lanczos), 0) {
+		t.Fatal("Fit differs from the package function")
+	}
+	if !compareNRGBA(e.Fill(img, 100, 100, Top), Fill(img, 100, 100, Top, Lanczos), 0) {
+		t.Fatal("Fill differs from the package function")
+	}
+	if !compareNRGBA(e.Thumbnail(img, 50, 80), Thumbnail(img, 50, 80, Lanczos), 0) {
+		t.Fatal("Thumbnail differs from the package function")
+	}
+	r := image.Rect(10, 20, 110, 70)
+	if !compareNRGBA(e.Crop(img, r), Crop(img, r), 0) {
+		t.Fatal("Crop differs from the package function")
+	}
+	if !compareNRGBA(e.Clone(img), Clone(img), 0) {
+		t.Fatal("Clone differs from the package function")
+	}
+}
+
+func TestEngineFilter(t *testing.T) {
+	filter := Box
+	e := NewEngine(EngineConfig{Filter: &filter})
+	filter = NearestNeighbor
+	img := testdataBranchesPNG
+	if !compareNRGBA(e.Resize(img, 150, 0), Resize(img, 150, 0, Box), 0) {
+		t.Fatal("the filter isn't used")
+	}
+	if !compareNRGBA(e.Thumbnail(img, 50, 50), Thumbnail(img, 50, 50, Box), 0) {
+		t.Fatal("the filter isn't used")
+	}
+}
+
+func TestEngineMaxProcs(t *testing.T) {
+	e := NewEngine(EngineConfig{MaxProcs: 1})
+	var procs, maxProcs int32
+	e.parallel(0, 100, func(is <-chan int) {
+		n := atomic.AddInt32(&procs, 1)
+		for {
+			m := atomic.LoadInt32(&maxProcs)
+			if n <= m || atomic.CompareAndSwapInt32(&maxProcs, m, n) {
+				break
+			}
+		}
+		for range is {
+		}
+		atomic.AddInt32(&procs, -1)
+	})
+	if maxProcs != 1 {
+		t.Fatalf("got %d goroutines want 1", maxProcs)
+	}
+
+	e.SetMaxProcs(3)
+	if e.maxProcs.Load() != 3 {
+		t.Fatal("the limit isn't set")
+	}
+	if defaultEngine.maxProcs.Load() != 0 {
+		t.Fatal("the limit of the default engine is changed")
+	}
+	SetMaxProcs(5)
+	defer SetMaxProcs(0)
+	if e.maxProcs.Load() != 3 {
+		t.Fatal("SetMaxProcs changed the limit of the engine")
+	}
+}
+
+func TestEngineDecodeLimits(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testdataFlowersSmallPNG, PNG); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	e := NewEngine(EngineConfig{MaxPixels: 1000})
+	var tooLarge *ImageTooLargeError
+	if _, err := e.Decode(bytes.NewReader(buf.Bytes())); !errors.As(err, &tooLarge) {
+		t.Fatalf("got error %v want *ImageTooLargeError", err)
+	}
+	if _, err := e.DecodeBytes(buf.Bytes()); !errors.As(err, &tooLarge) {
+		t.Fatalf("got error %v want *ImageTooLargeError", err)
+	}
+	if _, err := e.Decode(bytes.NewReader(buf.Bytes()), MaxPixels(0)); err != nil {
+		t.Fatalf("the option doesn't override the limit: %v", err)
+	}
+	if _, err := Decode(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("the package function is limited: %v", err)
+	}
+
+	e = NewEngine(EngineConfig{MaxWidth: 200, MaxHeight: 200})
+	if _, err := e.DecodeBytes(buf.Bytes()); !errors.As(err, &tooLarge) {
+		t.Fatalf("got error %v want *ImageTooLargeError", err)
+	}
+}
+
+func TestEngineFS(t *testing.T) {
+	mem := memFS{}
+	e := NewEngine(EngineConfig{FS: mem})
+	if err := e.Save(testdataFlowersSmallPNG, "flowers.png"); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, ok := mem["flowers.png"]; !ok {
+		t.Fatal("the file isn't saved to the engine file system")
+	}
+	img, err := e.Open("flowers.png")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if !compareNRGBA(Clone(img), Clone(testdataFlowersSmallPNG), 0) {
+		t.Fatal("the opened image differs")
+	}
+	if _, err := Open("flowers.png"); err == nil {
+		t.Fatal("the package function uses the engine file system")
+	}
+	if _, err := e.Open("missing.png"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("got error %v want %v", err, os.ErrNotExist)
+	}
+	if err := e.Save(testdataFlowersSmallPNG, "flowers.xyz"); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want %v", err, ErrUnsupportedFormat)
+	}
+
+	e = NewEngine(EngineConfig{FS: badFS{}})
+	if err := e.Save(testdataFlowersSmallPNG, "badFile.jpg"); err != errClose {
+		t.Fatalf("got error %v want %v", err, errClose)
+	}
+	if err := e.Save(testdataFlowersSmallPNG, "other.jpg"); err != errCreate {
+		t.Fatalf("got error %v want %v", err, errCreate)
+	}
+	if _, err := e.Open("file.jpg"); err != errOpen {
+		t.Fatalf("got error %v want %v", err, errOpen)
+	}
+}
@@ -0,0 +1,157 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// Enhancement holds the parameters of the automatic photo enhancement chosen by
+// SelectEnhancement and applied by ApplyEnhancement. The zero value leaves the image unchanged.
+type Enhancement struct {
+	// Denoise is the color sigma of the BilateralFilter smoothing the noise, 0 disables it.
+	Denoise float64
+
+	// BlackPoint and WhitePoint are the luminance levels stretched to black and white,
+	// the zero WhitePoint means 255.
+	BlackPoint, WhitePoint uint8
+
+	// ShadowLift brightens the shadows and keeps the highlights, from 0 (none) to 0.8.
+	// The value of 0.5 raises the dark tones of a third of the white by a half of the white.
+	ShadowLift float64
+
+	// Sharpen is the amount of the UnsharpMask with a radius of 1, 0 disables it.
+	Sharpen float64
+}
+
+// SelectEnhancement chooses the enhancement of the photo from its metadata and its
+// luminance histogram, the presets are:
+//
+//   - High ISO (800 and above): the noise is smoothed the more the higher the ISO speed,
+//     and the sharpening is weaker, or disabled from ISO 3200, not to amplify the noise.
+//   - Backlit (at least 30% of the pixels in the shadows and a tenth in the highlights,
+//     with fewer midtones than shadows, e.g. a subject against a bright sky): the shadows
+//     are lifted strongly.
+//   - Underexposed (the mean luminance is below 30%): the shadows are lifted moderately.
+//   - Low contrast (the darkest or lightest 0.5% of the pixels are gray): the levels are
+//     stretched, by at most 40 levels at each end.
+//   - The other photos are only sharpened slightly.
+//
+// Example:
+//
+//	info, _ := imaging.InspectFile("photo.jpg")
+//	img, _ := imaging.Open("photo.jpg")
+//	e := imaging.SelectEnhancement(img, info)
+//	e.Sharpen = 0 // The photos are sharpened later.
+//	dstImage := imaging.ApplyEnhancement(img, e)
+func SelectEnhancement(img image.Image, info ImageInfo) Enhancement {
+	e := Enhancement{Sharpen: 40}
+	if info.ISO >= 800 {
+		e.Denoise = math.Min(10*math.Log2(float64(info.ISO)/400), 30)
+		e.Sharpen = 20
+		if info.ISO >= 3200 {
+			e.Sharpen = 0
+		}
+	}
+
+	hist := Histogram(img)
+	var dark, mid, bright, mean, cum float64
+	low, high := -1, 255
+	for v, p := range hist {
+		switch {
+		case v < 64:
+			dark += p
+		case v < 200:
+			mid += p
+		default:
+			bright += p
+		}
+		mean += float64(v) * p / 255
+		cum += p
+		if low < 0 && cum > 0.005 {
+			low = v
+		}
+		if cum < 0.995 {
+			high = v + 1
+		}
+	}
+	if cum == 0 {
+		return e
+	}
+
+	switch {
+	case dark >= 0.3 && bright >= 0.1 && mid < dark:
+		e.ShadowLift = 0.5
+	case mean < 0.3:
+		e.ShadowLift = 0.3
+	}
+	e.BlackPoint = uint8(minint(maxint(low, 0), 40))
+	e.WhitePoint = uint8(maxint(minint(high, 255), 215))
+	return e
+}
+
+// ApplyEnhancement applies the enhancement to the image and returns the enhanced image:
+// the noise is smoothed first, then the levels are stretched and the shadows lifted, and
+// the image is sharpened last.
+//
+// Example:
+//
+//	dstImage := imaging.ApplyEnhancement(srcImage, imaging.Enhancement{ShadowLift: 0.3, Sharpen: 40})
+func ApplyEnhancement(img image.Image, e Enhancement) *image.NRGBA {
+	dst := Clone(img)
+	if e.Denoise > 0 {
+		denoised := BilateralFilter(dst, 1.5, e.Denoise)
+		Release(dst)
+		dst = denoised
+	}
+	AdjustColorsInPlace(dst, enhanceAdjustment(e))
+	if e.Sharpen > 0 {
+		// The threshold keeps the flat areas and the remaining noise.
+		sharpened := UnsharpMask(dst, 1, e.Sharpen, 3)
+		Release(dst)
+		dst = sharpened
+	}
+	return dst
+}
+
+// AutoEnhance improves the photo in one call with the enhancement chosen by
+// SelectEnhancement from its metadata, e.g. returned by Inspect, and its histogram.
+// The zero ImageInfo selects the enhancement from the histogram only.
+//
+// Example:
+//
+//	for _, filename := range filenames {
+//		info, err := imaging.InspectFile(filename, imaging.AutoOrientation(true))
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		img, err := imaging.Open(filename, imaging.AutoOrientation(true))
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		err = imaging.Save(imaging.AutoEnhance(img, info), filepath.Join("enhanced", filepath.Base(filename)))
+//	}
+func AutoEnhance(img image.Image, info ImageInfo) *image.NRGBA {
+	return ApplyEnhancement(img, SelectEnhancement(img, info))
+}
+
+// enhanceAdjustment returns the lookup table adjustment of the levels and the shadow lift.
+func enhanceAdjustment(e Enhancement) ColorAdjustment {
+	white := e.WhitePoint
+	if white == 0 {
+		white = 255
+	}
+	lift := math.Min(math.Max(e.ShadowLift, 0), 0.8)
+	if e.BlackPoint == 0 && white == 255 && lift == 0 || e.BlackPoint >= white {
+		return ColorAdjustment{}
+	}
+
+	lut := make([]uint8, 256)
+	for i := range lut {
+		x := math.Min(math.Max((float64(i)-float64(e.BlackPoint))/float64(white-e.BlackPoint), 0), 1)
+		// The curve rises the most at a third and keeps black and white, it stays
+		// monotonic for the lifts up to 8/9.
+		x += lift * 27 / 8 * x * (1 - x) * (1 - x)
+		lut[i] = clamp(x * 255)
+	}
+	return ColorAdjustment{lut: lut}
+}
@@ -0,0 +1,127 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// enhanceTestImage returns a gray image with the columns from the given levels.
+func enhanceTestImage(levels ...uint8) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, len(levels), 4))
+	for y := 0; y < 4; y++ {
+		for x, v := range levels {
+			img.SetNRGBA(x, y, color.NRGBA{v, v, v, 0xff})
+		}
+	}
+	return img
+}
+
+// enhanceRamp returns n levels evenly spaced from lo to hi.
+func enhanceRamp(lo, hi float64, n int) []uint8 {
+	levels := make([]uint8, n)
+	for i := range levels {
+		levels[i] = uint8(math.Round(lo + (hi-lo)*float64(i)/float64(n-1)))
+	}
+	return levels
+}
+
+func TestSelectEnhancement(t *testing.T) {
+	full := enhanceTestImage(enhanceRamp(0, 255, 256)...)
+	backlit := enhanceTestImage(append(enhanceRamp(20, 40, 50), enhanceRamp(220, 240, 50)...)...)
+	testCases := []struct {
+		name string
+		img  image.Image
+		iso  int
+		want Enhancement
+	}{
+		{"full range", full, 0, Enhancement{Sharpen: 40, BlackPoint: 1, WhitePoint: 254}},
+		{"low ISO", full, 400, Enhancement{Sharpen: 40, BlackPoint: 1, WhitePoint: 254}},
+		{"ISO 800", full, 800, Enhancement{Denoise: 10, Sharpen: 20, BlackPoint: 1, WhitePoint: 254}},
+		{"ISO 1600", full, 1600, Enhancement{Denoise: 20, Sharpen: 20, BlackPoint: 1, WhitePoint: 254}},
+		{"ISO 6400", full, 6400, Enhancement{Denoise: 30, BlackPoint: 1, WhitePoint: 254}},
+		{"backlit", backlit, 0, Enhancement{Sharpen: 40, BlackPoint: 20, WhitePoint: 240, ShadowLift: 0.5}},
+		{"underexposed", enhanceTestImage(enhanceRamp(0, 140, 141)...), 0, Enhancement{Sharpen: 40, WhitePoint: 215, ShadowLift: 0.3}},
+		{"low contrast", enhanceTestImage(enhanceRamp(60, 180, 121)...), 0, Enhancement{Sharpen: 40, BlackPoint: 40, WhitePoint: 215}},
+		{"empty", &image.NRGBA{}, 3200, Enhancement{Denoise: 30}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := SelectEnhancement(tc.img, ImageInfo{ISO: tc.iso})
+			if math.Abs(got.Denoise-tc.want.Denoise) > 1e-9 {
+				t.Fatalf("got %+v want %+v", got, tc.want)
+			}
+			got.Denoise = tc.want.Denoise
+			if got != tc.want {
+				t.Fatalf("got %+v want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestApplyEnhancement(t *testing.T) {
+	src := enhanceTestImage(enhanceRamp(0, 255, 256)...)
+	src.Pix[3] = 0x80
+	if got := ApplyEnhancement(src, Enhancement{}); !compareNRGBA(got, src, 0) {
+		t.Fatalf("the zero enhancement changed the image")
+	}
+
+	// The levels and the lifted shadows keep the order of the tones, and black and white.
+	for _, e := range []Enhancement{
+		{BlackPoint: 30, WhitePoint: 200},
+		{ShadowLift: 0.5},
+		{ShadowLift: 2},
+		{BlackPoint: 20, ShadowLift: 0.8},
+	} {
+		got := ApplyEnhancement(src, e)
+		if got.Pix[0] != 0 || got.Pix[3] != 0x80 || got.Pix[255*4] != 255 {
+			t.Fatalf("%+v: got black %v and white %v", e, got.Pix[0:4], got.Pix[255*4:256*4])
+		}
+		for x := 1; x < 256; x++ {
+			if got.Pix[x*4] < got.Pix[(x-1)*4] {
+				t.Fatalf("%+v: got %d at %d after %d", e, got.Pix[x*4], x, got.Pix[(x-1)*4])
+			}
+		}
+	}
+	got := ApplyEnhancement(src, Enhancement{ShadowLift: 0.5})
+	if v := got.Pix[85*4]; v < 145 || v > 150 {
+		t.Fatalf("got the lifted third %d", v)
+	}
+
+	// The denoising smooths the noise of a flat area.
+	noisy := New(20, 20, color.Gray{128})
+	for i := 0; i < len(noisy.Pix); i += 4 * 3 {
+		noisy.Pix[i] = 148
+	}
+	got = ApplyEnhancement(noisy, Enhancement{Denoise: 30})
+	for i := 0; i < len(got.Pix); i += 4 {
+		if got.Pix[i] > 140 {
+			t.Fatalf("got noise %d at %d", got.Pix[i], i/4)
+		}
+	}
+}
+
+func TestAutoEnhance(t *testing.T) {
+	backlit := enhanceTestImage(append(enhanceRamp(20, 40, 50), enhanceRamp(220, 240, 50)...)...)
+	got := AutoEnhance(backlit, ImageInfo{})
+	want := ApplyEnhancement(backlit, SelectEnhancement(backlit, ImageInfo{}))
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("AutoEnhance differs from the selected enhancement")
+	}
+	// The shadows are lifted and the highlights kept.
+	if v, orig := got.NRGBAAt(40, 1).R, backlit.NRGBAAt(40, 1).R; v < orig+8 {
+		t.Fatalf("got the shadow %d from %d", v, orig)
+	}
+	if v, orig := got.NRGBAAt(75, 1).R, backlit.NRGBAAt(75, 1).R; v < orig {
+		t.Fatalf("got the highlight %d from %d", v, orig)
+	}
+}
+
+func BenchmarkAutoEnhance(b *testing.B) {
+	b.ReportAllocs()
+	info := ImageInfo{ISO: 1600}
+	for i := 0; i < b.N; i++ {
+		AutoEnhance(testdataBranchesJPG, info)
+	}
+}
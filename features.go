@@ -0,0 +1,224 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// Keypoint is a distinctive image point (e.g. a corner) found by a feature detector.
+// The coordinates are relative to the top-left corner of the image bounds.
+type Keypoint struct {
+	X, Y  int
+	Score float64 // Detector response, higher is stronger.
+}
+
+// luminancePlane returns the luminance of the image pixels (from 0 to 255) in row-major order.
+func luminancePlane(img image.Image) ([]float64, int, int) {
+	src := newScanner(img)
+	lum := make([]float64, src.w*src.h)
+	parallel(0, src.h, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			for x := 0; x < src.w; x++ {
+				s := scanLine[x*4 : x*4+4 : x*4+4]
+				lum[y*src.w+x] = 0.299*float64(s[0]) + 0.587*float64(s[1]) + 0.114*float64(s[2])
+			}
+		}
+	})
+	return lum, src.w, src.h
+}
+
+// HarrisCorners detects corners using the Harris corner detector. The corner response is
+// computed from the image gradients summed over a Gaussian window. Only local maxima with
+// a response of at least 1% of the strongest one are returned, sorted by the response in
+// descending order. If maxCorners is positive, at most maxCorners strongest corners are returned.
+//
+// Example:
+//
+//	corners := imaging.HarrisCorners(srcImage, 500)
+func HarrisCorners(img image.Image, maxCorners int) []Keypoint {
+	const k = 0.04
+
+	lum, w, h := luminancePlane(img)
+	if w < 3 || h < 3 {
+		return nil
+	}
+
+	// Products of the Sobel gradients.
+	ixx := make([]float64, w*h)
+	iyy := make([]float64, w*h)
+	ixy := make([]float64, w*h)
+	parallel(1, h-1, func(ys <-chan int) {
+		for y := range ys {
+			for x := 1; x < w-1; x++ {
+				i := y*w + x
+				gx := (lum[i-w+1] + 2*lum[i+1] + lum[i+w+1]) - (lum[i-w-1] + 2*lum[i-1] + lum[i+w-1])
+				gy := (lum[i+w-1] + 2*lum[i+w] + lum[i+w+1]) - (lum[i-w-1] + 2*lum[i-w] + lum[i-w+1])
+				gx /= 8
+				gy /= 8
+				ixx[i] = gx * gx
+				iyy[i] = gy * gy
+				ixy[i] = gx * gy
+			}
+		}
+	})
+
+	// Sum the products over the Gaussian window.
+	weights := [5]float64{1, 4, 6, 4, 1}
+	window := func(p []float64, x, y int) float64 {
+		var sum float64
+		for dy := -2; dy <= 2; dy++ {
+			yy := y + dy
+			if yy < 0 || yy >= h {
+				continue
+			}
+			for dx := -2; dx <= 2; dx++ {
+				xx := x + dx
+				if xx < 0 || xx >= w {
+					continue
+				}
+				sum += weights[dy+2] * weights[dx+2] * p[yy*w+xx]
+			}
+		}
+		return sum / 256
+	}
+	response := make([]float64, w*h)
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				a := window(ixx, x, y)
+				b := window(iyy, x, y)
+				c := window(ixy, x, y)
+				response[y*w+x] = a*b - c*c - k*(a+b)*(a+b)
+			}
+		}
+	})
+
+	var maxResponse float64
+	for _, r := range response {
+		maxResponse = math.Max(maxResponse, r)
+	}
+	if maxResponse <= 0 {
+		return nil
+	}
+	return localMaxima(response, w, h, 0.01*maxResponse, 1, maxCorners)
+}
+
+// fastCircle is the Bresenham circle of radius 3 used by the FAST detector.
+var fastCircle = [16]image.Point{
+	{0, -3}, {1, -3}, {2, -2}, {3, -1}, {3, 0}, {3, 1}, {2, 2}, {1, 3},
+	{0, 3}, {-1, 3}, {-2, 2}, {-3, 1}, {-3, 0}, {-3, -1}, {-2, -2}, {-1, -3},
+}
+
+// FASTCorners detects corners using the FAST-9 detector (Features from Accelerated Segment Test).
+// A pixel is a corner if at least 9 contiguous pixels of the circle of radius 3 around it are
+// all brighter or all darker than the pixel by more than threshold (typically 20).
+// The corners are sorted by their score in descending order. FAST is much faster than
+// the Harris detector but more sensitive to noise.
+//
+// Example:
+//
+//	corners := imaging.FASTCorners(srcImage, 20)
+func FASTCorners(img image.Image, threshold float64) []Keypoint {
+	lum, w, h := luminancePlane(img)
+	if w < 7 || h < 7 {
+		return nil
+	}
+
+	var offsets [16]int
+	for i, p := range fastCircle {
+		offsets[i] = p.Y*w + p.X
+	}
+
+	score := make([]float64, w*h)
+	parallel(3, h-3, func(ys <-chan int) {
+		for y := range ys {
+			for x := 3; x < w-3; x++ {
+				i := y*w + x
+				p := lum[i]
+				var brighter, darker uint32
+				for j, o := range offsets {
+					v := lum[i+o]
+					if v > p+threshold {
+						brighter |= 1 << j
+					} else if v < p-threshold {
+						darker |= 1 << j
+					}
+				}
+				if !hasArc(brighter, 9) && !hasArc(darker, 9) {
+					continue
+				}
+				// The score is the sum of the differences exceeding the threshold.
+				var s float64
+				for _, o := range offsets {
+					d := math.Abs(lum[i+o]-p) - threshold
+					if d > 0 {
+						s += d
+					}
+				}
+				score[i] = s
+			}
+		}
+	})
+	return localMaxima(score, w, h, math.SmallestNonzeroFloat64, 1, 0)
+}
+
+// hasArc reports whether the 16-bit circular mask has at least n contiguous set bits.
+func hasArc(mask uint32, n int) bool {
+	if mask == 0 {
+		return false
+	}
+	mask |= mask << 16 // Unroll the circle.
+	run := 0
+	for i := 0; i < 32; i++ {
+		if mask&(1<<i) != 0 {
+			run++
+			if run >= n {
+				return true
+			}
+		} else {
+			run = 0
+		}
+	}
+	return false
+}
+
+// localMaxima returns the points with a value of at least minValue that are the maximum of
+// their (2*radius+1) x (2*radius+1) neighbourhood, sorted by the value in descending order.
+// If limit is positive, at most limit points are returned.
+func localMaxima(values []float64, w, h int, minValue float64, radius, limit int) []Keypoint {
+	var points []Keypoint
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := values[y*w+x]
+			if v < minValue {
+				continue
+			}
+			peak := true
+			for dy := -radius; dy <= radius && peak; dy++ {
+				for dx := -radius; dx <= radius; dx++ {
+					nx, ny := x+dx, y+dy
+					if (dx == 0 && dy == 0) || nx < 0 || ny < 0 || nx >= w || ny >= h {
+						continue
+					}
+					nv := values[ny*w+nx]
+					// Break ties in favour of the first point in scan order.
+					if nv > v || (nv == v && ny*w+nx < y*w+x) {
+						peak = false
+						break
+					}
+				}
+			}
+			if peak {
+				points = append(points, Keypoint{X: x, Y: y, Score: v})
+			}
+		}
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Score > points[j].Score })
+	if limit > 0 && len(points) > limit {
+		points = points[:limit]
+	}
+	return points
+}
@@ -0,0 +1,102 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// squareImage returns a black image with a white square.
+func squareImage() *image.NRGBA {
+	img := New(40, 30, color.Black)
+	for y := 10; y < 20; y++ {
+		for x := 10; x < 30; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+		}
+	}
+	return img
+}
+
+// nearCorners reports whether every expected corner has a keypoint within tol pixels.
+func nearCorners(kps []Keypoint, corners []image.Point, tol int) bool {
+	for _, c := range corners {
+		found := false
+		for _, k := range kps {
+			if absint(k.X-c.X) <= tol && absint(k.Y-c.Y) <= tol {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHarrisCorners(t *testing.T) {
+	corners := []image.Point{{10, 10}, {29, 10}, {10, 19}, {29, 19}}
+	got := HarrisCorners(squareImage(), 0)
+	if len(got) != 4 {
+		t.Fatalf("got %d corners %v want 4", len(got), got)
+	}
+	if !nearCorners(got, corners, 1) {
+		t.Fatalf("got corners %v want near %v", got, corners)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Score > got[i-1].Score {
+			t.Fatalf("corners are not sorted by score: %v", got)
+		}
+	}
+
+	if got := HarrisCorners(squareImage(), 2); len(got) != 2 {
+		t.Fatalf("got %d corners want 2", len(got))
+	}
+	if got := HarrisCorners(New(20, 20, color.White), 0); len(got) != 0 {
+		t.Fatalf("got corners %v in a flat image", got)
+	}
+	if got := HarrisCorners(&image.NRGBA{}, 0); len(got) != 0 {
+		t.Fatalf("got corners %v in an empty image", got)
+	}
+}
+
+func TestFASTCorners(t *testing.T) {
+	corners := []image.Point{{10, 10}, {29, 10}, {10, 19}, {29, 19}}
+	got := FASTCorners(squareImage(), 20)
+	if len(got) != 4 {
+		t.Fatalf("got %d corners %v want 4", len(got), got)
+	}
+	if !nearCorners(got, corners, 1) {
+		t.Fatalf("got corners %v want near %v", got, corners)
+	}
+
+	if got := FASTCorners(squareImage(), 255); len(got) != 0 {
+		t.Fatalf("got corners %v above the maximum contrast", got)
+	}
+	if got := FASTCorners(New(20, 20, color.White), 20); len(got) != 0 {
+		t.Fatalf("got corners %v in a flat image", got)
+	}
+	if got := FASTCorners(&image.NRGBA{}, 20); len(got) != 0 {
+		t.Fatalf("got corners %v in an empty image", got)
+	}
+}
+
+func TestHasArc(t *testing.T) {
+	testCases := []struct {
+		mask uint32
+		n    int
+		want bool
+	}{
+		{0x0000, 9, false},
+		{0x01ff, 9, true},
+		{0x00ff, 9, false},
+		{0xf01f, 9, true},
+		{0xf00f, 9, false},
+		{0xffff, 16, true},
+	}
+	for _, tc := range testCases {
+		if got := hasArc(tc.mask, tc.n); got != tc.want {
+			t.Fatalf("hasArc(%#x, %d) = %v want %v", tc.mask, tc.n, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownFilmPreset means the name is not one of the film presets, see FilmPresets.
+var ErrUnknownFilmPreset = errors.New("imaging: unknown film preset")
+
+// filmPreset is the recipe of the look of a film stock. The colors are mixed in this
+// order: desaturated or converted to gray, passed through the tone curves of the
+// channels and tinted by the split toning.
+type filmPreset struct {
+	// saturation scales the deviations of the channels from the luminance.
+	saturation float64
+	// mono, if set, converts the image to gray with these channel weights.
+	mono *[3]float64
+	// curves are the control points {input, output} of the tone curves of the red, green
+	// and blue channels, with the inputs increasing from 0 to 1.
+	curves [3][][2]float64
+	// shadows and highlights are added to the dark and the light tones.
+	shadows, highlights [3]float64
+}
+
+// filmPresets is the bundled data of the presets.
+var filmPresets = map[string]filmPreset{
+	// Kodak Portra: the warm, soft and low contrast look of the portrait film with
+	// the gentle skin tones.
+	"portra": {
+		saturation: 0.85,
+		curves: [3][][2]float64{
+			{{0, 0.04}, {0.25, 0.27}, {0.5, 0.54}, {0.75, 0.79}, {1, 0.98}},
+			{{0, 0.03}, {0.25, 0.26}, {0.5, 0.51}, {0.75, 0.76}, {1, 0.97}},
+			{{0, 0.05}, {0.25, 0.25}, {0.5, 0.48}, {0.75, 0.72}, {1, 0.93}},
+		},
+		highlights: [3]float64{0.02, 0.01, -0.01},
+	},
+	// Fuji Velvia: the vivid colors and the deep contrast of the landscape slide film.
+	"velvia": {
+		saturation: 1.4,
+		curves: [3][][2]float64{
+			{{0, 0}, {0.25, 0.19}, {0.5, 0.5}, {0.75, 0.81}, {1, 1}},
+			{{0, 0}, {0.25, 0.2}, {0.5, 0.51}, {0.75, 0.82}, {1, 1}},
+			{{0, 0.01}, {0.25, 0.21}, {0.5, 0.5}, {0.75, 0.79}, {1, 0.98}},
+		},
+	},
+	// Kodak Kodachrome: the warm reds, the dense shadows and the punchy contrast of the
+	// classic slide film.
+	"kodachrome": {
+		saturation: 1.15,
+		curves: [3][][2]float64{
+			{{0, 0}, {0.25, 0.23}, {0.5, 0.54}, {0.75, 0.82}, {1, 1}},
+			{{0, 0}, {0.25, 0.2}, {0.5, 0.49}, {0.75, 0.78}, {1, 0.97}},
+			{{0, 0.02}, {0.25, 0.2}, {0.5, 0.45}, {0.75, 0.72}, {1, 0.92}},
+		},
+		shadows: [3]float64{0.01, 0, -0.02},
+	},
+	// CineStill 800T: the tungsten balanced cinema film, with the cool shadows and
+	// the warm highlights of the night scenes.
+	"cinestill": {
+		saturation: 1.05,
+		curves: [3][][2]float64{
+			{{0, 0.02}, {0.25, 0.22}, {0.5, 0.5}, {0.75, 0.8}, {1, 1}},
+			{{0, 0.03}, {0.25, 0.25}, {0.5, 0.51}, {0.75, 0.77}, {1, 0.97}},
+			{{0, 0.06}, {0.25, 0.29}, {0.5, 0.53}, {0.75, 0.76}, {1, 0.94}},
+		},
+		shadows:    [3]float64{-0.03, 0.01, 0.04},
+		highlights: [3]float64{0.04, 0.01, -0.03},
+	},
+	// Polaroid: the faded blacks, the soft highlights and the muted colors with the
+	// greenish shadows of the instant film.
+	"polaroid": {
+		saturation: 0.75,
+		curves: [3][][2]float64{
+			{{0, 0.1}, {0.25, 0.3}, {0.5, 0.53}, {0.75, 0.76}, {1, 0.93}},
+			{{0, 0.11}, {0.25, 0.32}, {0.5, 0.54}, {0.75, 0.76}, {1, 0.92}},
+			{{0, 0.12}, {0.25, 0.3}, {0.5, 0.5}, {0.75, 0.71}, {1, 0.87}},
+		},
+		shadows: [3]float64{-0.02, 0.02, 0.01},
+	},
+	// Kodak Tri-X: the grainy black and white film with the strong contrast, shot
+	// through a yellow filter.
+	"trix": {
+		mono: &[3]float64{0.45, 0.5, 0.05},
+		curves: [3][][2]float64{
+			{{0, 0}, {0.2, 0.12}, {0.5, 0.5}, {0.8, 0.88}, {1, 1}},
+			{{0, 0}, {0.2, 0.12}, {0.5, 0.5}, {0.8, 0.88}, {1, 1}},
+			{{0, 0}, {0.2, 0.12}, {0.5, 0.5}, {0.8, 0.88}, {1, 1}},
+		},
+	},
+}
+
+var (
+	filmLUTsOnce sync.Once
+	filmLUTs     map[string]*LUT
+)
+
+// FilmPresets returns the sorted names of the film presets of ApplyFilmPreset.
+func FilmPresets() []string {
+	names := make([]string, 0, len(filmPresets))
+	for name := range filmPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FilmPresetLUT returns the 3D color lookup table of the film preset, e.g. to apply it
+// with ApplyLUTGamut. The tables are built on the first use and shared, so the returned
+// table must not be modified.
+func FilmPresetLUT(name string) (*LUT, error) {
+	filmLUTsOnce.Do(func() {
+		filmLUTs = make(map[string]*LUT, len(filmPresets))
+		for name, preset := range filmPresets {
+			filmLUTs[name] = preset.lut()
+		}
+	})
+	lut, ok := filmLUTs[name]
+	if !ok {
+		return nil, ErrUnknownFilmPreset
+	}
+	return lut, nil
+}
+
+// ApplyFilmPreset applies the look of a classic film stock to the image and returns
+// the adjusted image. The name is one of FilmPresets:
+//
+//	cinestill   CineStill 800T, the cool shadows and the warm highlights
+//	kodachrome  Kodak Kodachrome, the warm reds and the punchy contrast
+//	polaroid    Polaroid, the faded blacks and the muted colors
+//	portra      Kodak Portra, the warm, soft and low contrast look
+//	trix        Kodak Tri-X, the contrasty black and white
+//	velvia      Fuji Velvia, the vivid colors and the deep contrast
+//
+// The presets are 3D color lookup tables, see ApplyLUT. The alpha channel is kept.
+// An unknown name returns ErrUnknownFilmPreset.
+//
+// Example:
+//
+//	dstImage, err := imaging.ApplyFilmPreset(srcImage, "portra")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+func ApplyFilmPreset(img image.Image, name string) (*image.NRGBA, error) {
+	lut, err := FilmPresetLUT(name)
+	if err != nil {
+		return nil, err
+	}
+	return ApplyLUT(img, lut), nil
+}
+
+// lut builds the lookup table of the preset.
+func (p filmPreset) lut() *LUT {
+	var curves [3]func(float64) float64
+	for i, points := range p.curves {
+		curves[i] = monotoneCurve(points)
+	}
+	return NewLUT(33, func(r, g, b float64) (float64, float64, float64) {
+		c := [3]float64{r, g, b}
+		y := 0.299*r + 0.587*g + 0.114*b
+		if p.mono != nil {
+			m := p.mono[0]*r + p.mono[1]*g + p.mono[2]*b
+			c = [3]float64{m, m, m}
+		} else {
+			for i := range c {
+				c[i] = y + (c[i]-y)*p.saturation
+			}
+		}
+		for i := range c {
+			c[i] = curves[i](c[i])
+			c[i] += p.shadows[i]*(1-y)*(1-y) + p.highlights[i]*y*y
+		}
+		return c[0], c[1], c[2]
+	})
+}
+
+// monotoneCurve returns the smooth curve through the control points, which doesn't
+// overshoot them: the monotone piecewise cubic Hermite spline. The curve is flat outside
+// the points.
+func monotoneCurve(points [][2]float64) func(float64) float64 {
+	n := len(points)
+	slopes := make([]float64, n-1)
+	for i := range slopes {
+		slopes[i] = (points[i+1][1] - points[i][1]) / (points[i+1][0] - points[i][0])
+	}
+	// The tangents at the points are the weighted harmonic means of the slopes of the
+	// segments around them, 0 at the local extrema.
+	m := make([]float64, n)
+	m[0], m[n-1] = slopes[0], slopes[n-2]
+	for i := 1; i < n-1; i++ {
+		if slopes[i-1]*slopes[i] > 0 {
+			h0, h1 := points[i][0]-points[i-1][0], points[i+1][0]-points[i][0]
+			m[i] = 3 * (h0 + h1) / ((2*h1+h0)/slopes[i-1] + (h1+2*h0)/slopes[i])
+		}
+	}
+	return func(x float64) float64 {
+		if x <= points[0][0] {
+			return points[0][1]
+		}
+		if x >= points[n-1][0] {
+			return points[n-1][1]
+		}
+		i := sort.Search(n-1, func(i int) bool { return points[i+1][0] > x })
+		h := points[i+1][0] - points[i][0]
+		t := (x - points[i][0]) / h
+		t2, t3 := t*t, t*t*t
+		return (2*t3-3*t2+1)*points[i][1] + (t3-2*t2+t)*h*m[i] +
+			(-2*t3+3*t2)*points[i+1][1] + (t3-t2)*h*m[i+1]
+	}
+}
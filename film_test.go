@@ -0,0 +1,120 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestFilmPresets(t *testing.T) {
+	want := []string{"cinestill", "kodachrome", "polaroid", "portra", "trix", "velvia"}
+	if got := FilmPresets(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	for _, name := range want {
+		lut, err := FilmPresetLUT(name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if lut.Size() != 33 {
+			t.Errorf("%s: got size %d want 33", name, lut.Size())
+		}
+	}
+	if _, err := FilmPresetLUT("Portra"); !errors.Is(err, ErrUnknownFilmPreset) {
+		t.Fatalf("got error %v want ErrUnknownFilmPreset", err)
+	}
+}
+
+func TestApplyFilmPreset(t *testing.T) {
+	black, white := color.NRGBA{0, 0, 0, 255}, color.NRGBA{255, 255, 255, 128}
+	red, sky := color.NRGBA{200, 60, 50, 255}, color.NRGBA{90, 140, 220, 255}
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 4, 1),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			black.R, black.G, black.B, black.A,
+			white.R, white.G, white.B, white.A,
+			red.R, red.G, red.B, red.A,
+			sky.R, sky.G, sky.B, sky.A,
+		},
+	}
+	apply := func(name string) *image.NRGBA {
+		t.Helper()
+		dst, err := ApplyFilmPreset(src, name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for x := 0; x < 4; x++ {
+			if a, want := dst.NRGBAAt(x, 0).A, src.NRGBAAt(x, 0).A; a != want {
+				t.Fatalf("%s: pixel %d: got alpha %d want %d", name, x, a, want)
+			}
+		}
+		return dst
+	}
+	chroma := func(c color.NRGBA) int {
+		return maxint(maxint(int(c.R), int(c.G)), int(c.B)) - minint(minint(int(c.R), int(c.G)), int(c.B))
+	}
+
+	trix := apply("trix")
+	for x := 0; x < 4; x++ {
+		if c := trix.NRGBAAt(x, 0); c.R != c.G || c.R != c.B {
+			t.Errorf("trix: pixel %d: got %v want gray", x, c)
+		}
+	}
+	// The yellow filter darkens the sky below the red.
+	if trix.NRGBAAt(3, 0).R >= trix.NRGBAAt(2, 0).R {
+		t.Errorf("trix: got sky %v and red %v want the sky darker", trix.NRGBAAt(3, 0), trix.NRGBAAt(2, 0))
+	}
+
+	if c := apply("polaroid").NRGBAAt(0, 0); c.R < 20 || c.G < 20 || c.B < 20 {
+		t.Errorf("polaroid: got black %v want faded", c)
+	}
+	if c := apply("velvia").NRGBAAt(2, 0); chroma(c) <= chroma(red) {
+		t.Errorf("velvia: got red %v want more saturated than %v", c, red)
+	}
+	if c := apply("portra").NRGBAAt(2, 0); chroma(c) >= chroma(red) {
+		t.Errorf("portra: got red %v want less saturated than %v", c, red)
+	}
+	cine := apply("cinestill")
+	if c := cine.NRGBAAt(0, 0); c.B <= c.R {
+		t.Errorf("cinestill: got black %v want cool shadows", c)
+	}
+	if c := cine.NRGBAAt(1, 0); c.R <= c.B {
+		t.Errorf("cinestill: got white %v want warm highlights", c)
+	}
+
+	if dst, err := ApplyFilmPreset(src, "unknown"); !errors.Is(err, ErrUnknownFilmPreset) || dst != nil {
+		t.Fatalf("got %v, %v want nil, ErrUnknownFilmPreset", dst, err)
+	}
+}
+
+func TestMonotoneCurve(t *testing.T) {
+	points := [][2]float64{{0.1, 0.2}, {0.3, 0.25}, {0.5, 0.8}, {0.9, 0.85}}
+	curve := monotoneCurve(points)
+	for _, p := range points {
+		if got := curve(p[0]); math.Abs(got-p[1]) > 1e-9 {
+			t.Errorf("curve(%v): got %v want %v", p[0], got, p[1])
+		}
+	}
+	if curve(0) != 0.2 || curve(1) != 0.85 {
+		t.Errorf("got ends %v and %v want 0.2 and 0.85", curve(0), curve(1))
+	}
+	prev := curve(0)
+	for x := 0.0; x <= 1; x += 0.001 {
+		y := curve(x)
+		if y < prev-1e-12 || y > 0.85+1e-12 {
+			t.Fatalf("curve(%v) = %v: not monotone", x, y)
+		}
+		prev = y
+	}
+}
+
+func BenchmarkApplyFilmPreset(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ApplyFilmPreset(testdataBranchesJPG, "portra")
+	}
+}
@@ -0,0 +1,278 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+var errInvalidFingerprint = errors.New("imaging: invalid fingerprint data")
+
+// fingerprintMagic starts the binary encoding of a Fingerprint, the last byte is the version.
+const fingerprintMagic = "IFP\x01"
+
+// Fingerprint is a compact summary of an image for indexing it in an asset database.
+// It's computed by NewFingerprint in a single pass over the pixels and can be stored
+// as JSON or in the binary form of MarshalBinary (up to 133 bytes).
+type Fingerprint struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// Histogram is the luminance distribution in 16 bins of equal width. The values sum to 1.
+	Histogram [16]float32 `json:"histogram"`
+
+	// Brightness is the mean luminance and Contrast is its standard deviation, both from 0 to 1.
+	Brightness float32 `json:"brightness"`
+	Contrast   float32 `json:"contrast"`
+
+	// PHash is the perceptual hash of the image. It changes little when the image is resized,
+	// compressed or slightly edited, see Distance.
+	PHash uint64 `json:"phash"`
+
+	// Colors are the dominant colors of the image sorted by their weight in descending order.
+	Colors []DominantColor `json:"colors"`
+}
+
+// DominantColor is a color of an image with its share of the image pixels.
+type DominantColor struct {
+	Color  color.NRGBA `json:"color"`
+	Weight float32     `json:"weight"`
+}
+
+// fingerprintColors is the maximum number of dominant colors of a fingerprint.
+const fingerprintColors = 5
+
+// NewFingerprint computes the fingerprint of the image. The histogram and the hash are computed
+// from the pixel colors ignoring the alpha channel, the dominant colors ignore the fully
+// transparent pixels.
+//
+// Example:
+//
+//	fp := imaging.NewFingerprint(srcImage)
+//	data, err := fp.MarshalBinary()
+func NewFingerprint(img image.Image) *Fingerprint {
+	src := newScanner(img)
+	fp := &Fingerprint{Width: src.w, Height: src.h}
+	if src.w == 0 || src.h == 0 {
+		return fp
+	}
+
+	// The luminance histogram, a 32x32 grid of the luminance for the hash
+	// and a histogram of the colors with 5 bits per channel.
+	const gridSize = 32
+	var mu sync.Mutex
+	var lumHist [256]float64
+	var grid, gridCounts [gridSize * gridSize]float64
+	colors := make([]colorCount, 1<<15)
+	colorSums := make([][3]float64, 1<<15)
+
+	parallel(0, src.h, func(ys <-chan int) {
+		var tmpHist [256]float64
+		var tmpGrid, tmpCounts [gridSize * gridSize]float64
+		tmpColors := make([]colorCount, 1<<15)
+		tmpSums := make([][3]float64, 1<<15)
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			// The grid cells covered by the pixel.
+			gy0, gy1 := y*gridSize/src.h, ((y+1)*gridSize-1)/src.h
+			for x := 0; x < src.w; x++ {
+				s := scanLine[x*4 : x*4+4 : x*4+4]
+				lum := 0.299*float64(s[0]) + 0.587*float64(s[1]) + 0.114*float64(s[2])
+				tmpHist[int(lum+0.5)]++
+
+				gx0, gx1 := x*gridSize/src.w, ((x+1)*gridSize-1)/src.w
+				for gy := gy0; gy <= gy1; gy++ {
+					for gx := gx0; gx <= gx1; gx++ {
+						tmpGrid[gy*gridSize+gx] += lum
+						tmpCounts[gy*gridSize+gx]++
+					}
+				}
+
+				if s[3] == 0 {
+					continue
+				}
+				key := int(s[0]>>3)<<10 | int(s[1]>>3)<<5 | int(s[2]>>3)
+				tmpColors[key].count++
+				tmpColors[key].alpha += float64(s[3])
+				tmpSums[key][0] += float64(s[0])
+				tmpSums[key][1] += float64(s[1])
+				tmpSums[key][2] += float64(s[2])
+			}
+		}
+		mu.Lock()
+		for i := range lumHist {
+			lumHist[i] += tmpHist[i]
+		}
+		for i := range grid {
+			grid[i] += tmpGrid[i]
+			gridCounts[i] += tmpCounts[i]
+		}
+		for i := range colors {
+			colors[i].count += tmpColors[i].count
+			colors[i].alpha += tmpColors[i].alpha
+			for c := 0; c < 3; c++ {
+				colorSums[i][c] += tmpSums[i][c]
+			}
+		}
+		mu.Unlock()
+	})
+
+	total := float64(src.w * src.h)
+	var mean, variance float64
+	for i, n := range lumHist {
+		fp.Histogram[i/16] += float32(n / total)
+		mean += float64(i) * n / total
+	}
+	for i, n := range lumHist {
+		variance += (float64(i) - mean) * (float64(i) - mean) * n / total
+	}
+	fp.Brightness = float32(mean / 255)
+	fp.Contrast = float32(math.Sqrt(variance) / 255)
+
+	for i := range grid {
+		grid[i] /= gridCounts[i]
+	}
+	fp.PHash = perceptualHash(grid[:], gridSize)
+
+	// Split the colors into the dominant ones with median cut.
+	var hist []colorCount
+	for i, e := range colors {
+		if e.count == 0 {
+			continue
+		}
+		for c := 0; c < 3; c++ {
+			e.rgb[c] = clamp(colorSums[i][c] / e.count)
+		}
+		hist = append(hist, e)
+	}
+	if len(hist) > 0 {
+		var opaque float64
+		for _, e := range hist {
+			opaque += e.count
+		}
+		for _, box := range medianCut(hist, fingerprintColors) {
+			var n float64
+			for _, e := range box.entries {
+				n += e.count
+			}
+			fp.Colors = append(fp.Colors, DominantColor{averageColor(box.entries), float32(n / opaque)})
+		}
+		sort.SliceStable(fp.Colors, func(i, j int) bool { return fp.Colors[i].Weight > fp.Colors[j].Weight })
+	}
+	return fp
+}
+
+// perceptualHash computes the DCT-based hash of the size x size luminance grid: each bit
+// of the hash tells whether one of the 8x8 lowest frequency coefficients is above their median.
+func perceptualHash(grid []float64, size int) uint64 {
+	// The DCT-II of the rows and then of the columns, limited to the 8 lowest frequencies.
+	cos := make([]float64, 8*size)
+	for u := 0; u < 8; u++ {
+		for x := 0; x < size; x++ {
+			cos[u*size+x] = math.Cos(math.Pi * float64(u) * (2*float64(x) + 1) / float64(2*size))
+		}
+	}
+	rows := make([]float64, size*8)
+	for y := 0; y < size; y++ {
+		for u := 0; u < 8; u++ {
+			var sum float64
+			for x := 0; x < size; x++ {
+				sum += grid[y*size+x] * cos[u*size+x]
+			}
+			rows[y*8+u] = sum
+		}
+	}
+	var coeffs [64]float64
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			var sum float64
+			for y := 0; y < size; y++ {
+				sum += rows[y*8+u] * cos[v*size+y]
+			}
+			coeffs[v*8+u] = sum
+		}
+	}
+
+	sorted := coeffs
+	sort.Float64s(sorted[:])
+	median := (sorted[31] + sorted[32]) / 2
+	var hash uint64
+	for i, c := range coeffs {
+		if c > median {
+			hash |= 1 << uint(63-i)
+		}
+	}
+	return hash
+}
+
+// Distance returns the number of different bits of the perceptual hashes of the fingerprints,
+// from 0 to 64. Images with a distance up to about 10 are usually variants of the same image.
+func (f *Fingerprint) Distance(other *Fingerprint) int {
+	return bits.OnesCount64(f.PHash ^ other.PHash)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (f *Fingerprint) MarshalBinary() ([]byte, error) {
+	le := binary.LittleEndian
+	data := make([]byte, 0, len(fingerprintMagic)+8+16*4+8+8+1+len(f.Colors)*8)
+	data = append(data, fingerprintMagic...)
+	data = le.AppendUint32(data, uint32(f.Width))
+	data = le.AppendUint32(data, uint32(f.Height))
+	for _, v := range f.Histogram {
+		data = le.AppendUint32(data, math.Float32bits(v))
+	}
+	data = le.AppendUint32(data, math.Float32bits(f.Brightness))
+	data = le.AppendUint32(data, math.Float32bits(f.Contrast))
+	data = le.AppendUint64(data, f.PHash)
+	if len(f.Colors) > 255 {
+		return nil, errors.New("imaging: too many fingerprint colors")
+	}
+	data = append(data, uint8(len(f.Colors)))
+	for _, c := range f.Colors {
+		data = append(data, c.Color.R, c.Color.G, c.Color.B, c.Color.A)
+		data = le.AppendUint32(data, math.Float32bits(c.Weight))
+	}
+	return data, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (f *Fingerprint) UnmarshalBinary(data []byte) error {
+	le := binary.LittleEndian
+	const fixedLen = len(fingerprintMagic) + 8 + 16*4 + 8 + 8 + 1
+	if len(data) < fixedLen || string(data[:len(fingerprintMagic)]) != fingerprintMagic {
+		return errInvalidFingerprint
+	}
+	n := int(data[fixedLen-1])
+	if len(data) != fixedLen+n*8 {
+		return errInvalidFingerprint
+	}
+	d := data[len(fingerprintMagic):]
+	fp := Fingerprint{
+		Width:  int(le.Uint32(d)),
+		Height: int(le.Uint32(d[4:])),
+	}
+	d = d[8:]
+	for i := range fp.Histogram {
+		fp.Histogram[i] = math.Float32frombits(le.Uint32(d[i*4:]))
+	}
+	d = d[16*4:]
+	fp.Brightness = math.Float32frombits(le.Uint32(d))
+	fp.Contrast = math.Float32frombits(le.Uint32(d[4:]))
+	fp.PHash = le.Uint64(d[8:])
+	d = d[17:]
+	for i := 0; i < n; i++ {
+		c := d[i*8:]
+		fp.Colors = append(fp.Colors, DominantColor{
+			Color:  color.NRGBA{c[0], c[1], c[2], c[3]},
+			Weight: math.Float32frombits(le.Uint32(c[4:])),
+		})
+	}
+	*f = fp
+	return nil
+}
@@ -0,0 +1,139 @@
+package imaging
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNewFingerprint(t *testing.T) {
+	// The left half is red and the right half is blue, the last column is transparent.
+	img := New(10, 4, color.NRGBA{255, 0, 0, 255})
+	img = Paste(img, New(4, 4, color.NRGBA{0, 0, 255, 255}), image.Pt(5, 0))
+	img = Paste(img, New(1, 4, color.NRGBA{0, 0, 255, 0}), image.Pt(9, 0))
+
+	fp := NewFingerprint(img)
+	if fp.Width != 10 || fp.Height != 4 {
+		t.Fatalf("got size %dx%d want 10x4", fp.Width, fp.Height)
+	}
+	// The red luminance is 76 and the blue one is 29.
+	var want [16]float32
+	want[76/16] = 0.5
+	want[29/16] = 0.5
+	if fp.Histogram != want {
+		t.Fatalf("got histogram %v want %v", fp.Histogram, want)
+	}
+	if math.Abs(float64(fp.Brightness)-(76.0+29.0)/2/255) > 0.002 {
+		t.Fatalf("got brightness %v", fp.Brightness)
+	}
+	if math.Abs(float64(fp.Contrast)-(76.0-29.0)/2/255) > 0.002 {
+		t.Fatalf("got contrast %v", fp.Contrast)
+	}
+	wantColors := []DominantColor{
+		{color.NRGBA{255, 0, 0, 255}, float32(5) / 9},
+		{color.NRGBA{0, 0, 255, 255}, float32(4) / 9},
+	}
+	if !reflect.DeepEqual(fp.Colors, wantColors) {
+		t.Fatalf("got colors %v want %v", fp.Colors, wantColors)
+	}
+}
+
+func TestNewFingerprintEmpty(t *testing.T) {
+	fp := NewFingerprint(&image.NRGBA{})
+	if !reflect.DeepEqual(fp, &Fingerprint{}) {
+		t.Fatalf("got %#v want an empty fingerprint", fp)
+	}
+}
+
+func TestFingerprintDistance(t *testing.T) {
+	fp := NewFingerprint(testdataBranchesPNG)
+	testCases := []struct {
+		name    string
+		img     image.Image
+		maxDist int
+		minDist int
+	}{
+		{"same", testdataBranchesPNG, 0, 0},
+		{"resized", Resize(testdataBranchesPNG, 150, 100, Lanczos), 4, 0},
+		{"brighter", AdjustBrightness(testdataBranchesPNG, 10), 4, 0},
+		{"small", Resize(testdataBranchesPNG, 24, 16, Box), 10, 0},
+		{"different", testdataFlowersSmallPNG, 64, 20},
+		{"flipped", FlipV(testdataBranchesPNG), 64, 20},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := fp.Distance(NewFingerprint(tc.img))
+			if d > tc.maxDist || d < tc.minDist {
+				t.Fatalf("got distance %d want from %d to %d", d, tc.minDist, tc.maxDist)
+			}
+		})
+	}
+}
+
+func TestFingerprintMarshal(t *testing.T) {
+	fp := NewFingerprint(testdataFlowersSmallPNG)
+	if len(fp.Colors) != fingerprintColors {
+		t.Fatalf("got %d colors want %d", len(fp.Colors), fingerprintColors)
+	}
+	for i := 1; i < len(fp.Colors); i++ {
+		if fp.Colors[i].Weight > fp.Colors[i-1].Weight {
+			t.Fatalf("the colors are not sorted by weight: %v", fp.Colors)
+		}
+	}
+
+	data, err := fp.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	if len(data) != 93+len(fp.Colors)*8 {
+		t.Fatalf("got %d bytes", len(data))
+	}
+	var got Fingerprint
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	if !reflect.DeepEqual(&got, fp) {
+		t.Fatalf("got %#v want %#v", got, fp)
+	}
+
+	data, err = json.Marshal(fp)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	got = Fingerprint{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(&got, fp) {
+		t.Fatalf("got %#v want %#v", got, fp)
+	}
+}
+
+func TestFingerprintUnmarshalFails(t *testing.T) {
+	data, err := NewFingerprint(testdataFlowersSmallPNG).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	for _, d := range [][]byte{
+		nil,
+		data[:20],
+		data[:len(data)-1],
+		append(append([]byte(nil), data...), 0),
+		append([]byte("IFP\x02"), data[4:]...),
+	} {
+		var fp Fingerprint
+		if err := fp.UnmarshalBinary(d); err != errInvalidFingerprint {
+			t.Fatalf("got error %v want %v", err, errInvalidFingerprint)
+		}
+	}
+}
+
+func BenchmarkNewFingerprint(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewFingerprint(testdataBranchesJPG)
+	}
+}
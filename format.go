@@ -0,0 +1,96 @@
+package imaging
+
+import (
+	"bufio"
+	"image"
+	"io"
+	"strings"
+	"sync"
+)
+
+// registeredFormat is an image format added by RegisterFormat.
+type registeredFormat struct {
+	format Format
+	magic  string
+	decode func(io.Reader) (image.Image, error)
+	encode func(io.Writer, image.Image) error
+}
+
+var (
+	formatsMu         sync.RWMutex
+	registeredFormats []registeredFormat
+)
+
+// RegisterFormat adds an image format to the package, so that Decode and Open recognize
+// its images and Encode, Save and FormatFromExtension support it. It returns the new
+// Format value. Name is returned by the Format.String method and ext is the filename
+// extension of the format (with or without the leading dot). Magic is the prefix that
+// identifies the encoded images, each "?" in it matches any byte. Registered formats take
+// precedence over the built-in ones, so a format can replace a built-in codec.
+// Decode or encode may be nil if the format is read-only or write-only.
+//
+// RegisterFormat is typically called in an init function.
+//
+// Example:
+//
+//	var TGA = imaging.RegisterFormat("TGA", "tga", "\x00\x00\x02", tga.Decode, tga.Encode)
+func RegisterFormat(name, ext, magic string, decode func(io.Reader) (image.Image, error), encode func(io.Writer, image.Image) error) Format {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	// All the formats have names, so the next free value is their count.
+	f := Format(len(formatNames))
+	formatNames[f] = name
+	if ext = strings.ToLower(strings.TrimPrefix(ext, ".")); ext != "" {
+		formatExts[ext] = f
+	}
+	registeredFormats = append(registeredFormats, registeredFormat{
+		format: f,
+		magic:  magic,
+		decode: decode,
+		encode: encode,
+	})
+	return f
+}
+
+// matchMagic reports whether the data matches the magic prefix with "?" wildcards.
+func matchMagic(magic string, data []byte) bool {
+	if len(data) != len(magic) {
+		return false
+	}
+	for i, b := range data {
+		if magic[i] != '?' && magic[i] != b {
+			return false
+		}
+	}
+	return true
+}
+
+// sniffRegisteredFormat returns the registered format of the image in br.
+// The formats registered later are checked first.
+func sniffRegisteredFormat(br *bufio.Reader) (registeredFormat, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	for i := len(registeredFormats) - 1; i >= 0; i-- {
+		rf := registeredFormats[i]
+		if rf.decode == nil || rf.magic == "" {
+			continue
+		}
+		if data, err := br.Peek(len(rf.magic)); err == nil && matchMagic(rf.magic, data) {
+			return rf, true
+		}
+	}
+	return registeredFormat{}, false
+}
+
+// registeredEncoder returns the encoder of the registered format.
+func registeredEncoder(f Format) func(io.Writer, image.Image) error {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	for _, rf := range registeredFormats {
+		if rf.format == f {
+			return rf.encode
+		}
+	}
+	return nil
+}
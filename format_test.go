@@ -0,0 +1,173 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// registerTestFormat registers a format for the duration of the test.
+// Its images are "GRY" and a version byte, the width and the height and then the gray pixels.
+func registerTestFormat(t *testing.T, ext, magic string) Format {
+	formatsMu.Lock()
+	names := make(map[Format]string, len(formatNames))
+	for k, v := range formatNames {
+		names[k] = v
+	}
+	exts := make(map[string]Format, len(formatExts))
+	for k, v := range formatExts {
+		exts[k] = v
+	}
+	registered := registeredFormats
+	formatsMu.Unlock()
+	t.Cleanup(func() {
+		formatsMu.Lock()
+		formatNames, formatExts, registeredFormats = names, exts, registered
+		formatsMu.Unlock()
+	})
+
+	decode := func(r io.Reader) (image.Image, error) {
+		var header [6]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, err
+		}
+		img := image.NewGray(image.Rect(0, 0, int(header[4]), int(header[5])))
+		if _, err := io.ReadFull(r, img.Pix); err != nil {
+			return nil, err
+		}
+		return img, nil
+	}
+	encode := func(w io.Writer, img image.Image) error {
+		b := img.Bounds()
+		if b.Dx() > 255 || b.Dy() > 255 {
+			return errors.New("image too large")
+		}
+		src := Grayscale(img)
+		data := []byte{'G', 'R', 'Y', '1', uint8(b.Dx()), uint8(b.Dy())}
+		for i := 0; i < len(src.Pix); i += 4 {
+			data = append(data, src.Pix[i])
+		}
+		_, err := w.Write(data)
+		return err
+	}
+	return RegisterFormat("GRY", ext, magic, decode, encode)
+}
+
+func TestRegisterFormat(t *testing.T) {
+	gry := registerTestFormat(t, ".GRY", "GRY?")
+	if gry <= WEBP {
+		t.Fatalf("got format %d which is a built-in one", gry)
+	}
+	if got := gry.String(); got != "GRY" {
+		t.Fatalf("got name %q want %q", got, "GRY")
+	}
+	for _, filename := range []string{"a.gry", "b.GRY"} {
+		if f, err := FormatFromFilename(filename); err != nil || f != gry {
+			t.Fatalf("FormatFromFilename(%q): got %v, %v want %v", filename, f, err, gry)
+		}
+	}
+
+	img := New(3, 2, color.Gray{0x80})
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, gry); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if want := "GRY1\x03\x02\x80\x80\x80\x80\x80\x80"; buf.String() != want {
+		t.Fatalf("got data %q want %q", buf.String(), want)
+	}
+	if err := Encode(&buf, New(300, 1, color.Black), gry); err == nil {
+		t.Fatal("expected the encoder error")
+	}
+
+	// The "?" of the magic matches any version.
+	got, format, err := DecodeWithFormat(strings.NewReader("GRY2\x02\x01\x10\x20"))
+	if err != nil {
+		t.Fatalf("DecodeWithFormat: %v", err)
+	}
+	if format != gry {
+		t.Fatalf("got format %v want %v", format, gry)
+	}
+	want := &image.Gray{Rect: image.Rect(0, 0, 2, 1), Stride: 2, Pix: []uint8{0x10, 0x20}}
+	if g, ok := got.(*image.Gray); !ok || g.Rect != want.Rect || !bytes.Equal(g.Pix, want.Pix) {
+		t.Fatalf("got result %#v want %#v", got, want)
+	}
+
+	// The built-in formats still work.
+	buf.Reset()
+	if err := Encode(&buf, img, PNG); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if _, err := Decode(&buf); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+}
+
+func TestRegisterFormatOpenSave(t *testing.T) {
+	registerTestFormat(t, "gry", "GRY1")
+	filename := filepath.Join(t.TempDir(), "test.gry")
+	img := New(4, 4, color.Gray{0x40})
+	if err := Save(img, filename); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := Open(filename)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if !compareNRGBA(Clone(got), img, 0) {
+		t.Fatal("the opened image differs")
+	}
+}
+
+func TestRegisterFormatOverride(t *testing.T) {
+	// A format with the PNG magic and extension replaces the built-in codec.
+	f := registerTestFormat(t, "png", "\x89PNG")
+	if got, err := FormatFromExtension("png"); err != nil || got != f {
+		t.Fatalf("got %v, %v want %v", got, err, f)
+	}
+	// Data with the PNG magic is decoded as the test format: the bytes after it are the size.
+	got, err := Decode(bytes.NewReader(append(append([]byte("\x89PNG"), 1, 1), 0x33)))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if g, ok := got.(*image.Gray); !ok || g.Pix[0] != 0x33 {
+		t.Fatalf("got result %#v", got)
+	}
+}
+
+func TestRegisterFormatNil(t *testing.T) {
+	f := registerTestFormat(t, "", "")
+	formatsMu.Lock()
+	registeredFormats[len(registeredFormats)-1].encode = nil
+	formatsMu.Unlock()
+	if err := Encode(io.Discard, New(1, 1, color.Black), f); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want %v", err, ErrUnsupportedFormat)
+	}
+	// Formats without a magic aren't sniffed.
+	if _, err := Decode(strings.NewReader("GRY1\x01\x01\x00")); err != image.ErrFormat {
+		t.Fatalf("got error %v want %v", err, image.ErrFormat)
+	}
+}
+
+func TestMatchMagic(t *testing.T) {
+	testCases := []struct {
+		magic string
+		data  string
+		want  bool
+	}{
+		{"abc", "abc", true},
+		{"a?c", "axc", true},
+		{"a?c", "axd", false},
+		{"abc", "ab", false},
+		{"???", "xyz", true},
+	}
+	for _, tc := range testCases {
+		if got := matchMagic(tc.magic, []byte(tc.data)); got != tc.want {
+			t.Fatalf("matchMagic(%q, %q): got %v want %v", tc.magic, tc.data, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// GamutMapping specifies how the colors outside of the sRGB gamut are brought into it.
+type GamutMapping int
+
+// Gamut mappings.
+const (
+	// GamutClip clips each channel to its range. It's the fastest, but the clipping
+	// changes the ratios of the channels, so the saturated colors shift their hue,
+	// e.g. an oversaturated orange turns yellow.
+	GamutClip GamutMapping = iota
+
+	// GamutClipChroma reduces the chroma of the out-of-gamut colors in the OkLab color
+	// space, keeping their lightness and hue, until they fit into the gamut. The colors
+	// inside the gamut are unchanged.
+	GamutClipChroma
+
+	// GamutCompress compresses the chroma of the colors near the gamut boundary smoothly
+	// into the gamut, keeping the lightness and hue. Unlike GamutClipChroma, the different
+	// out-of-gamut colors stay distinct, at the cost of desaturating the most saturated
+	// colors inside the gamut slightly.
+	GamutCompress
+)
+
+// gamutKnee is the fraction of the maximum chroma GamutCompress leaves unchanged.
+const gamutKnee = 0.8
+
+// MapGamut brings the color into the sRGB gamut with the given mapping. The red, green
+// and blue components are nonlinear sRGB values where the gamut is the range [0, 1], as
+// in NewLUT, so it can map the results of a color function that exceed the range.
+//
+// Example:
+//
+//	// Boost the saturation aggressively without hue shifts.
+//	lut := imaging.NewLUT(33, func(r, g, b float64) (float64, float64, float64) {
+//		y := 0.2126*r + 0.7152*g + 0.0722*b
+//		r, g, b = y+(r-y)*2, y+(g-y)*2, y+(b-y)*2
+//		return imaging.MapGamut(r, g, b, imaging.GamutClipChroma)
+//	})
+func MapGamut(r, g, b float64, mapping GamutMapping) (float64, float64, float64) {
+	switch mapping {
+	case GamutClipChroma:
+		if inUnitRange(r, g, b) {
+			return clampUnit(r), clampUnit(g), clampUnit(b)
+		}
+	case GamutCompress:
+	default:
+		return clampUnit(r), clampUnit(g), clampUnit(b)
+	}
+	return newOklabColor(r, g, b).mapGamut(mapping)
+}
+
+// mapGamut converts the color to nonlinear sRGB, bringing it into the gamut
+// with GamutClipChroma or GamutCompress.
+func (c oklabColor) mapGamut(mapping GamutMapping) (r, g, b float64) {
+	switch {
+	case c.l >= 1:
+		return 1, 1, 1
+	case c.l <= 0:
+		return 0, 0, 0
+	}
+	r, g, b = c.linear()
+	chroma := math.Hypot(c.a, c.b)
+	if chroma > 0 && (mapping == GamutCompress || !inUnitRange(r, g, b)) {
+		maxChroma := c.maxChroma(chroma)
+		k := 1.0
+		if mapping == GamutClipChroma {
+			k = math.Min(maxChroma/chroma, 1)
+		} else if knee := gamutKnee * maxChroma; chroma > knee {
+			// The compressed chroma approaches the maximum chroma asymptotically.
+			x := (chroma - knee) / (maxChroma - knee)
+			k = (knee + (maxChroma-knee)*x/(1+x)) / chroma
+		}
+		if k != 1 {
+			r, g, b = oklabColor{l: c.l, a: c.a * k, b: c.b * k}.linear()
+		}
+	}
+	return clampUnit(delinearizeSRGB(r)), clampUnit(delinearizeSRGB(g)), clampUnit(delinearizeSRGB(b))
+}
+
+// ApplyLUTGamut maps the colors of the image through the 3D color lookup table like
+// ApplyLUT and brings the output colors exceeding the range [0, 1] into the sRGB gamut
+// with the given mapping. ApplyLUT clips them.
+//
+// Example:
+//
+//	dstImage := imaging.ApplyLUTGamut(srcImage, lut, imaging.GamutCompress)
+func ApplyLUTGamut(img image.Image, lut *LUT, mapping GamutMapping) *image.NRGBA {
+	return applyLUT(img, lut, mapping)
+}
+
+// AdjustChroma changes the chroma of the image in the OkLab color space, keeping the
+// lightness and hue of the colors, and brings the oversaturated colors into the sRGB gamut
+// with the given mapping. The percentage must be >= -100, the percentage = -100 gives
+// the grayscale image with the perceived lightness of the colors.
+//
+// Example:
+//
+//	dstImage := imaging.AdjustChroma(srcImage, 150, imaging.GamutCompress)
+func AdjustChroma(img image.Image, percentage float64, mapping GamutMapping) *image.NRGBA {
+	if percentage == 0 {
+		return Clone(img)
+	}
+
+	k := 1 + math.Max(percentage, -100)/100
+	return AdjustFunc(img, oklabFunc(mapping, func(c *oklabColor) {
+		c.a *= k
+		c.b *= k
+	}))
+}
+
+// oklabFunc returns a pixel function changing the colors in the OkLab color space with
+// the fn function and bringing the results into the sRGB gamut with the given mapping.
+func oklabFunc(mapping GamutMapping, fn func(c *oklabColor)) func(c color.NRGBA) color.NRGBA {
+	return func(c color.NRGBA) color.NRGBA {
+		lab := linearToOklab(srgbToLinear[c.R], srgbToLinear[c.G], srgbToLinear[c.B])
+		fn(&lab)
+		var r, g, b float64
+		if mapping == GamutClipChroma || mapping == GamutCompress {
+			r, g, b = lab.mapGamut(mapping)
+		} else {
+			r, g, b = lab.srgb()
+		}
+		return color.NRGBA{clamp(r * 255), clamp(g * 255), clamp(b * 255), c.A}
+	}
+}
+
+// oklabBrightnessFunc returns the pixel function of AdjustBrightness in the OkLab color space.
+func oklabBrightnessFunc(percentage float64) func(c color.NRGBA) color.NRGBA {
+	shift := math.Min(math.Max(percentage, -100), 100) / 100
+	return oklabFunc(GamutClipChroma, func(c *oklabColor) {
+		c.l += shift
+	})
+}
+
+// oklabContrastFunc returns the pixel function of AdjustContrast in the OkLab color space.
+// The lightness is scaled like the channel values of AdjustContrast.
+func oklabContrastFunc(percentage float64) func(c color.NRGBA) color.NRGBA {
+	v := (100 + math.Min(math.Max(percentage, -100), 100)) / 100
+	return oklabFunc(GamutClipChroma, func(c *oklabColor) {
+		switch {
+		case v <= 1:
+			c.l = 0.5 + (c.l-0.5)*v
+		case v < 2:
+			c.l = 0.5 + (c.l-0.5)/(2-v)
+		case c.l < 0.5:
+			c.l = 0
+		default:
+			c.l = 1
+		}
+	})
+}
+
+// oklabSaturationFunc returns the pixel function of AdjustSaturation in the OkLab color space.
+func oklabSaturationFunc(percentage float64) func(c color.NRGBA) color.NRGBA {
+	k := 1 + math.Min(math.Max(percentage, -100), 100)/100
+	return oklabFunc(GamutClipChroma, func(c *oklabColor) {
+		c.a *= k
+		c.b *= k
+	})
+}
+
+// oklabHueFunc returns the pixel function of AdjustHue in the OkLab color space.
+func oklabHueFunc(shift float64) func(c color.NRGBA) color.NRGBA {
+	sin, cos := math.Sincos(shift * math.Pi / 180)
+	return oklabFunc(GamutClipChroma, func(c *oklabColor) {
+		c.a, c.b = c.a*cos-c.b*sin, c.a*sin+c.b*cos
+	})
+}
+
+// oklabColor is a color in the OkLab color space.
+type oklabColor struct {
+	l, a, b float64
+}
+
+// newOklabColor converts the nonlinear sRGB color to OkLab. The components may exceed
+// the range [0, 1].
+func newOklabColor(r, g, b float64) oklabColor {
+	return linearToOklab(linearizeSRGB(r), linearizeSRGB(g), linearizeSRGB(b))
+}
+
+// linearToOklab converts the linear sRGB color to OkLab.
+func linearToOklab(lr, lg, lb float64) oklabColor {
+	l := math.Cbrt(0.4122214708*lr + 0.5363325363*lg + 0.0514459929*lb)
+	m := math.Cbrt(0.2119034982*lr + 0.6806995451*lg + 0.1073969566*lb)
+	s := math.Cbrt(0.0883024619*lr + 0.2817188376*lg + 0.6299787005*lb)
+	return oklabColor{
+		l: 0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		a: 1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		b: 0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+// srgb converts the color to nonlinear sRGB.
+func (c oklabColor) srgb() (r, g, b float64) {
+	r, g, b = c.linear()
+	return delinearizeSRGB(r), delinearizeSRGB(g), delinearizeSRGB(b)
+}
+
+// linear converts the color to linear sRGB.
+func (c oklabColor) linear() (r, g, b float64) {
+	l := c.l + 0.3963377774*c.a + 0.2158037573*c.b
+	m := c.l - 0.1055613458*c.a - 0.0638541728*c.b
+	s := c.l - 0.0894841775*c.a - 1.2914855480*c.b
+	l, m, s = l*l*l, m*m*m, s*s*s
+	r = 4.0767416621*l - 3.3077115913*m + 0.2309699292*s
+	g = -1.2684380046*l + 2.6097574011*m - 0.3413193965*s
+	b = -0.0041960863*l - 0.7034186147*m + 1.7076147010*s
+	return r, g, b
+}
+
+// maxChroma returns the largest chroma of the colors inside the sRGB gamut with the
+// lightness and hue of c, whose chroma is given.
+func (c oklabColor) maxChroma(chroma float64) float64 {
+	// The chroma of the sRGB colors is below 0.33.
+	lo, hi := 0.0, 0.5
+	ca, cb := c.a/chroma, c.b/chroma
+	for i := 0; i < 16; i++ {
+		m := (lo + hi) / 2
+		// The transfer function keeps the range [0, 1], so the linear color is tested.
+		if inUnitRange(oklabColor{l: c.l, a: ca * m, b: cb * m}.linear()) {
+			lo = m
+		} else {
+			hi = m
+		}
+	}
+	return lo
+}
+
+// linearizeSRGB converts the nonlinear sRGB component to linear light,
+// mirroring the transfer function for the negative values.
+func linearizeSRGB(c float64) float64 {
+	v := math.Abs(c)
+	if v <= 0.04045 {
+		v /= 12.92
+	} else {
+		v = math.Pow((v+0.055)/1.055, 2.4)
+	}
+	return math.Copysign(v, c)
+}
+
+// delinearizeSRGB converts the linear light component to nonlinear sRGB,
+// mirroring the transfer function for the negative values.
+func delinearizeSRGB(c float64) float64 {
+	v := math.Abs(c)
+	if v <= 0.0031308 {
+		v *= 12.92
+	} else {
+		v = 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+	return math.Copysign(v, c)
+}
+
+// inUnitRange reports whether the components are in the range [0, 1]
+// up to the rounding errors of the color conversions.
+func inUnitRange(r, g, b float64) bool {
+	const eps = 1e-9
+	return r >= -eps && r <= 1+eps && g >= -eps && g <= 1+eps && b >= -eps && b <= 1+eps
+}
+
+// clampUnit clamps the value to the range [0, 1].
+func clampUnit(v float64) float64 {
+	return math.Min(math.Max(v, 0), 1)
+}
@@ -0,0 +1,170 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func hueDiff(c1, c2 oklabColor) float64 {
+	d := math.Abs(math.Atan2(c1.b, c1.a) - math.Atan2(c2.b, c2.a))
+	return math.Min(d, 2*math.Pi-d)
+}
+
+func TestMapGamut(t *testing.T) {
+	outside := [][3]float64{
+		{1.3, 0.5, -0.2},
+		{-0.1, 0.9, 0.2},
+		{0.2, -0.3, 1.4},
+		{1.1, 0.8, -0.3},
+		{0.6, -0.2, 0.8},
+	}
+	for _, mapping := range []GamutMapping{GamutClipChroma, GamutCompress} {
+		for _, c := range outside {
+			r, g, b := MapGamut(c[0], c[1], c[2], mapping)
+			if !inUnitRange(r, g, b) {
+				t.Fatalf("MapGamut(%v, %v): got %v, %v, %v outside of the gamut", c, mapping, r, g, b)
+			}
+			want := newOklabColor(c[0], c[1], c[2])
+			got := newOklabColor(r, g, b)
+			if math.Abs(got.l-want.l) > 1e-3 || hueDiff(got, want) > 1e-3 {
+				t.Fatalf("MapGamut(%v, %v): got %+v want the lightness and hue of %+v", c, mapping, got, want)
+			}
+			if math.Hypot(got.a, got.b) >= math.Hypot(want.a, want.b) {
+				t.Fatalf("MapGamut(%v, %v): the chroma isn't reduced", c, mapping)
+			}
+		}
+	}
+
+	// Clipping shifts the hue.
+	c := [3]float64{1.3, 0.5, -0.2}
+	r, g, b := MapGamut(c[0], c[1], c[2], GamutClip)
+	if r != 1 || g != 0.5 || b != 0 {
+		t.Fatalf("MapGamut(GamutClip): got %v, %v, %v want 1, 0.5, 0", r, g, b)
+	}
+	clipped := hueDiff(newOklabColor(r, g, b), newOklabColor(c[0], c[1], c[2]))
+	r, g, b = MapGamut(c[0], c[1], c[2], GamutClipChroma)
+	if hueDiff(newOklabColor(r, g, b), newOklabColor(c[0], c[1], c[2])) >= clipped {
+		t.Fatal("GamutClipChroma doesn't reduce the hue shift of GamutClip")
+	}
+
+	// Too bright and too dark colors.
+	if r, g, b := MapGamut(1.5, 1.4, 1.6, GamutClipChroma); r != 1 || g != 1 || b != 1 {
+		t.Fatalf("got %v, %v, %v want white", r, g, b)
+	}
+	if r, g, b := MapGamut(-0.5, -0.4, -0.6, GamutCompress); r != 0 || g != 0 || b != 0 {
+		t.Fatalf("got %v, %v, %v want black", r, g, b)
+	}
+}
+
+func TestMapGamutInside(t *testing.T) {
+	testCases := [][3]float64{
+		{0, 0, 0},
+		{1, 1, 1},
+		{0.5, 0.5, 0.5},
+		{0.6, 0.5, 0.4},
+		{1, 0, 0},
+		{0.2, 0.8, 0.3},
+	}
+	for _, c := range testCases {
+		for _, mapping := range []GamutMapping{GamutClip, GamutClipChroma} {
+			if r, g, b := MapGamut(c[0], c[1], c[2], mapping); r != c[0] || g != c[1] || b != c[2] {
+				t.Fatalf("MapGamut(%v, %v): got %v, %v, %v want the color unchanged", c, mapping, r, g, b)
+			}
+		}
+	}
+
+	// The unsaturated colors are unchanged by the compression, the saturated ones are
+	// desaturated.
+	r, g, b := MapGamut(0.6, 0.5, 0.4, GamutCompress)
+	if math.Abs(r-0.6) > 1e-6 || math.Abs(g-0.5) > 1e-6 || math.Abs(b-0.4) > 1e-6 {
+		t.Fatalf("got %v, %v, %v want 0.6, 0.5, 0.4", r, g, b)
+	}
+	r, g, b = MapGamut(1, 0, 0, GamutCompress)
+	got := newOklabColor(r, g, b)
+	want := newOklabColor(1, 0, 0)
+	if math.Hypot(got.a, got.b) >= math.Hypot(want.a, want.b) || hueDiff(got, want) > 1e-3 {
+		t.Fatalf("got %+v want %+v desaturated", got, want)
+	}
+
+	// Compressed colors stay distinct.
+	r1, g1, b1 := MapGamut(1.2, 0.3, 0.1, GamutCompress)
+	r2, g2, b2 := MapGamut(1.5, 0.2, -0.1, GamutCompress)
+	if r1 == r2 && g1 == g2 && b1 == b2 {
+		t.Fatal("the compressed colors are the same")
+	}
+}
+
+func TestAdjustChroma(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 2),
+		Stride: 8,
+		Pix: []uint8{
+			0xcc, 0x66, 0x33, 0xff, 0x80, 0x80, 0x80, 0x80,
+			0x20, 0x80, 0xe0, 0x40, 0x30, 0x90, 0x40, 0xff,
+		},
+	}
+
+	if !compareNRGBA(AdjustChroma(src, 0, GamutClipChroma), src, 0) {
+		t.Fatal("the percentage = 0 changes the image")
+	}
+
+	gray := AdjustChroma(src, -100, GamutClip)
+	for i := 0; i < len(gray.Pix); i += 4 {
+		p := gray.Pix[i : i+4]
+		if absint(int(p[0])-int(p[1])) > 1 || absint(int(p[1])-int(p[2])) > 1 {
+			t.Fatalf("got %v want gray", p)
+		}
+		if p[3] != src.Pix[i+3] {
+			t.Fatal("the alpha is changed")
+		}
+	}
+
+	for _, mapping := range []GamutMapping{GamutClipChroma, GamutCompress} {
+		dst := AdjustChroma(src, 200, mapping)
+		for i := 0; i < len(dst.Pix); i += 4 {
+			s, d := src.Pix[i:i+4], dst.Pix[i:i+4]
+			want := newOklabColor(float64(s[0])/255, float64(s[1])/255, float64(s[2])/255)
+			got := newOklabColor(float64(d[0])/255, float64(d[1])/255, float64(d[2])/255)
+			if math.Hypot(want.a, want.b) < 1e-3 {
+				continue
+			}
+			if math.Hypot(got.a, got.b) <= math.Hypot(want.a, want.b) {
+				t.Fatalf("%v: got %v from %v want a more saturated color", mapping, d, s)
+			}
+			if hueDiff(got, want) > 0.05 || math.Abs(got.l-want.l) > 0.02 {
+				t.Fatalf("%v: got %v from %v want the same hue and lightness", mapping, d, s)
+			}
+		}
+	}
+}
+
+func TestApplyLUTGamut(t *testing.T) {
+	saturate := NewLUT(17, func(r, g, b float64) (float64, float64, float64) {
+		y := 0.2126*r + 0.7152*g + 0.0722*b
+		return y + (r-y)*3, y + (g-y)*3, y + (b-y)*3
+	})
+	img := testdataFlowersSmallPNG
+	if !compareNRGBA(ApplyLUTGamut(img, saturate, GamutClip), ApplyLUT(img, saturate), 0) {
+		t.Fatal("GamutClip differs from ApplyLUT")
+	}
+
+	src := &image.NRGBA{Rect: image.Rect(0, 0, 1, 1), Stride: 4, Pix: []uint8{0xe0, 0x80, 0x40, 0xff}}
+	clipped := ApplyLUT(src, saturate).NRGBAAt(0, 0)
+	mapped := ApplyLUTGamut(src, saturate, GamutClipChroma).NRGBAAt(0, 0)
+	lab := func(c color.NRGBA) oklabColor {
+		return newOklabColor(float64(c.R)/255, float64(c.G)/255, float64(c.B)/255)
+	}
+	want := lab(src.NRGBAAt(0, 0))
+	if hueDiff(lab(mapped), want) >= hueDiff(lab(clipped), want) {
+		t.Fatalf("got %v, clipped %v: want a smaller hue shift", mapped, clipped)
+	}
+}
+
+func BenchmarkAdjustChroma(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		AdjustChroma(testdataBranchesJPG, 50, GamutCompress)
+	}
+}
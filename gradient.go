@@ -0,0 +1,122 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// GradientStop is a color of a gradient at the offset from 0.0 (the start) to 1.0 (the end).
+type GradientStop struct {
+	Offset float64
+	Color  color.Color
+}
+
+// NewLinearGradient creates a new image with the specified width and height filled with
+// the linear gradient of the stops. The angle is the direction of the gradient in degrees
+// counter-clockwise, like in Rotate: 0 runs from left to right and 90 from bottom to top.
+// The gradient spans the image, its start and end touch the opposite corners. The colors
+// are interpolated with premultiplied alpha, so the transparent stops don't darken the
+// colors, and the colors before the first stop and after the last one are the colors of
+// these stops. Without stops the image is transparent.
+//
+// Example:
+//
+//	// Darken the bottom of the photo for the caption.
+//	shade := imaging.NewLinearGradient(w, h, []imaging.GradientStop{
+//		{Offset: 0.6, Color: color.Transparent},
+//		{Offset: 1, Color: color.NRGBA{0, 0, 0, 160}},
+//	}, 270)
+//	dstImage := imaging.Overlay(photo, shade, image.Pt(0, 0), 1.0)
+func NewLinearGradient(width, height int, stops []GradientStop, angle float64) *image.NRGBA {
+	a := angle * math.Pi / 180
+	// The y axis of the image points down.
+	dx, dy := math.Cos(a), -math.Sin(a)
+	w, h := float64(width), float64(height)
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, p := range [4][2]float64{{0, 0}, {w, 0}, {0, h}, {w, h}} {
+		v := p[0]*dx + p[1]*dy
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	return newGradient(width, height, stops, func(x, y float64) float64 {
+		return (x*dx + y*dy - lo) / (hi - lo)
+	})
+}
+
+// NewRadialGradient creates a new image with the specified width and height filled with
+// the circular gradient of the stops around the center, from 0.0 at the center to 1.0 at
+// the radius in pixels. The radius <= 0 reaches the farthest corner of the image. The colors
+// are interpolated like in NewLinearGradient.
+//
+// Example:
+//
+//	// A soft spotlight.
+//	light := imaging.NewRadialGradient(400, 400, []imaging.GradientStop{
+//		{Offset: 0, Color: color.NRGBA{255, 255, 220, 200}},
+//		{Offset: 1, Color: color.Transparent},
+//	}, image.Pt(200, 200), 200)
+//	dstImage := imaging.Blend(photo, light, image.Pt(100, 50), imaging.BlendScreen, 1.0)
+func NewRadialGradient(width, height int, stops []GradientStop, center image.Point, radius float64) *image.NRGBA {
+	cx, cy := float64(center.X), float64(center.Y)
+	if radius <= 0 {
+		radius = math.Hypot(math.Max(cx, float64(width)-cx), math.Max(cy, float64(height)-cy))
+	}
+	return newGradient(width, height, stops, func(x, y float64) float64 {
+		return math.Hypot(x-cx, y-cy) / radius
+	})
+}
+
+// newGradient creates the image of the gradient of the stops at the offsets returned by fn
+// for the centers of the pixels.
+func newGradient(width, height int, stops []GradientStop, fn func(x, y float64) float64) *image.NRGBA {
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
+	dst := newNRGBA(image.Rect(0, 0, width, height))
+	if len(stops) == 0 {
+		return dst
+	}
+
+	// The stops sorted by the offset, with the premultiplied colors.
+	type stop struct {
+		offset float64
+		c      [4]float64
+	}
+	sorted := make([]stop, len(stops))
+	for i, s := range stops {
+		c := color.NRGBAModel.Convert(s.Color).(color.NRGBA)
+		a := float64(c.A) / 255
+		sorted[i] = stop{s.Offset, [4]float64{float64(c.R) * a, float64(c.G) * a, float64(c.B) * a, float64(c.A)}}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].offset < sorted[j].offset })
+
+	parallel(0, height, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < width; x++ {
+				t := fn(float64(x)+0.5, float64(y)+0.5)
+				i := sort.Search(len(sorted), func(i int) bool { return sorted[i].offset > t })
+				var c [4]float64
+				switch {
+				case i == 0:
+					c = sorted[0].c
+				case i == len(sorted):
+					c = sorted[i-1].c
+				default:
+					s0, s1 := sorted[i-1], sorted[i]
+					f := (t - s0.offset) / (s1.offset - s0.offset)
+					for k := range c {
+						c[k] = s0.c[k] + (s1.c[k]-s0.c[k])*f
+					}
+				}
+				d := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4 : y*dst.Stride+x*4+4]
+				if c[3] <= 0 {
+					continue
+				}
+				a := c[3] / 255
+				d[0], d[1], d[2], d[3] = clamp(c[0]/a), clamp(c[1]/a), clamp(c[2]/a), clamp(c[3])
+			}
+		}
+	})
+	return dst
+}
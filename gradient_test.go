@@ -0,0 +1,124 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestNewLinearGradient(t *testing.T) {
+	bw := []GradientStop{{0, color.Black}, {1, color.White}}
+	testCases := []struct {
+		name          string
+		width, height int
+		angle         float64
+		first, last   color.NRGBA
+	}{
+		{"left to right", 256, 1, 0, color.NRGBA{0, 0, 0, 255}, color.NRGBA{255, 255, 255, 255}},
+		{"right to left", 256, 1, 180, color.NRGBA{255, 255, 255, 255}, color.NRGBA{0, 0, 0, 255}},
+		{"bottom to top", 1, 256, 90, color.NRGBA{255, 255, 255, 255}, color.NRGBA{0, 0, 0, 255}},
+		{"top to bottom", 1, 256, -90, color.NRGBA{0, 0, 0, 255}, color.NRGBA{255, 255, 255, 255}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := NewLinearGradient(tc.width, tc.height, bw, tc.angle)
+			if got.Rect != image.Rect(0, 0, tc.width, tc.height) {
+				t.Fatalf("got bounds %v", got.Rect)
+			}
+			first, last := got.NRGBAAt(0, 0), got.NRGBAAt(tc.width-1, tc.height-1)
+			if !compareNRGBA(New(1, 1, first), New(1, 1, tc.first), 1) || !compareNRGBA(New(1, 1, last), New(1, 1, tc.last), 1) {
+				t.Fatalf("got first %v last %v want %v %v", first, last, tc.first, tc.last)
+			}
+			if mid := got.NRGBAAt(tc.width/2, tc.height/2); mid.R < 126 || mid.R > 129 {
+				t.Fatalf("got middle %v want gray", mid)
+			}
+		})
+	}
+
+	// Diagonal: the corners are the ends.
+	got := NewLinearGradient(100, 50, bw, 45)
+	if c := got.NRGBAAt(0, 49); c.R > 2 {
+		t.Fatalf("got bottom-left corner %v want black", c)
+	}
+	if c := got.NRGBAAt(99, 0); c.R < 253 {
+		t.Fatalf("got top-right corner %v want white", c)
+	}
+}
+
+func TestNewLinearGradientStops(t *testing.T) {
+	red, blue := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}
+	testCases := []struct {
+		name  string
+		stops []GradientStop
+		want  [4]color.NRGBA
+	}{
+		{
+			"unsorted stops",
+			[]GradientStop{{0.75, blue}, {0.25, red}},
+			[4]color.NRGBA{red, {191, 0, 64, 255}, {64, 0, 191, 255}, blue},
+		},
+		{
+			"transparent",
+			[]GradientStop{{0, red}, {1, color.Transparent}},
+			[4]color.NRGBA{{255, 0, 0, 223}, {255, 0, 0, 159}, {255, 0, 0, 96}, {255, 0, 0, 32}},
+		},
+		{
+			"single stop",
+			[]GradientStop{{0.5, blue}},
+			[4]color.NRGBA{blue, blue, blue, blue},
+		},
+		{
+			"no stops",
+			nil,
+			[4]color.NRGBA{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := NewLinearGradient(4, 1, tc.stops, 0)
+			want := &image.NRGBA{Rect: image.Rect(0, 0, 4, 1), Stride: 4 * 4, Pix: make([]uint8, 16)}
+			for x, c := range tc.want {
+				want.SetNRGBA(x, 0, c)
+			}
+			if !compareNRGBA(got, want, 1) {
+				t.Fatalf("got %v want %v", got.Pix, want.Pix)
+			}
+		})
+	}
+	if got := NewLinearGradient(0, 10, []GradientStop{{0, red}}, 0); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v want empty image", got.Rect)
+	}
+}
+
+func TestNewRadialGradient(t *testing.T) {
+	stops := []GradientStop{{0, color.White}, {1, color.Black}}
+	got := NewRadialGradient(101, 51, stops, image.Pt(50, 25), 0)
+	if c := got.NRGBAAt(50, 25); c.R < 250 {
+		t.Fatalf("got center %v want white", c)
+	}
+	for _, p := range []image.Point{{0, 0}, {100, 0}, {0, 50}, {100, 50}} {
+		if c := got.NRGBAAt(p.X, p.Y); c.R > 10 {
+			t.Fatalf("got corner %v %v want black", p, c)
+		}
+	}
+	// Symmetric around the center.
+	if c1, c2 := got.NRGBAAt(30, 10), got.NRGBAAt(69, 39); c1 != c2 {
+		t.Fatalf("got %v and %v want the same", c1, c2)
+	}
+
+	got = NewRadialGradient(100, 100, stops, image.Pt(0, 0), 50)
+	if c := got.NRGBAAt(60, 0); c.R != 0 {
+		t.Fatalf("got %v beyond the radius want black", c)
+	}
+	if c := got.NRGBAAt(25, 0); c.R < 125 || c.R > 130 {
+		t.Fatalf("got %v at half of the radius want gray", c)
+	}
+}
+
+func BenchmarkNewLinearGradient(b *testing.B) {
+	stops := []GradientStop{{0, color.Black}, {0.5, color.NRGBA{255, 0, 0, 128}}, {1, color.White}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewLinearGradient(640, 480, stops, 30)
+	}
+}
@@ -2,6 +2,7 @@ package imaging
 
 import (
 	"image"
+	"math"
 	"sync"
 )
 
@@ -50,3 +51,108 @@ func Histogram(img image.Image) [256]float64 {
 	}
 	return histogram
 }
+
+// ColorHistogram returns the normalized histograms of the red, green and blue channels of an image.
+// histogram[c][i] is the probability of the channel c of a pixel being i. The alpha channel is
+// ignored like in Histogram.
+//
+// The color histograms are cheaper to compare than the perceptual hashes and match the images
+// by their colors rather than their structure, see HistogramChiSquare, HistogramBhattacharyya
+// and HistogramEMD.
+func ColorHistogram(img image.Image) [3][256]float64 {
+	var mu sync.Mutex
+	var histogram [3][256]float64
+
+	src := newScanner(img)
+	if src.w == 0 || src.h == 0 {
+		return histogram
+	}
+
+	parallel(0, src.h, func(ys <-chan int) {
+		var tmpHistogram [3][256]float64
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			for i := 0; i < len(scanLine); i += 4 {
+				s := scanLine[i : i+3 : i+3]
+				tmpHistogram[0][s[0]]++
+				tmpHistogram[1][s[1]]++
+				tmpHistogram[2][s[2]]++
+			}
+		}
+		mu.Lock()
+		for c := range histogram {
+			for i := range histogram[c] {
+				histogram[c][i] += tmpHistogram[c][i]
+			}
+		}
+		mu.Unlock()
+	})
+
+	total := float64(src.w * src.h)
+	for c := range histogram {
+		for i := range histogram[c] {
+			histogram[c][i] /= total
+		}
+	}
+	return histogram
+}
+
+// HistogramChiSquare returns the chi-square distance of two normalized histograms of the same
+// length, from 0 for equal histograms to 2 for histograms without common bins.
+//
+// Example:
+//
+//	h1, h2 := imaging.Histogram(img1), imaging.Histogram(img2)
+//	d := imaging.HistogramChiSquare(h1[:], h2[:])
+func HistogramChiSquare(h1, h2 []float64) float64 {
+	var d float64
+	for i, a := range h1 {
+		b := h2[i]
+		if s := a + b; s > 0 {
+			d += (a - b) * (a - b) / s
+		}
+	}
+	return d
+}
+
+// HistogramBhattacharyya returns the Bhattacharyya distance of two histograms of the same
+// length, from 0 for equal histograms to 1 for histograms without common bins. The histograms
+// are normalized by the distance, so they may be counts as well.
+func HistogramBhattacharyya(h1, h2 []float64) float64 {
+	var bc, sum1, sum2 float64
+	for i, a := range h1 {
+		b := h2[i]
+		bc += math.Sqrt(a * b)
+		sum1 += a
+		sum2 += b
+	}
+	if sum1 == 0 || sum2 == 0 {
+		if sum1 == sum2 {
+			return 0
+		}
+		return 1
+	}
+	return math.Sqrt(math.Max(0, 1-bc/math.Sqrt(sum1*sum2)))
+}
+
+// HistogramEMD returns the earth mover's distance of two normalized histograms of the same
+// length, the minimal work of moving the mass of h1 to the bins of h2. It's divided by the
+// distance of the first and the last bin, so it ranges from 0 for equal histograms to 1.
+// Unlike the other distances, it takes the bin order into account, so slightly darker images
+// are nearer than much darker ones.
+//
+// For the histograms of ColorHistogram, the mean of the distances of the channels approximates
+// the distance of the colors, which is expensive to compute exactly.
+func HistogramEMD(h1, h2 []float64) float64 {
+	if len(h1) < 2 {
+		return 0
+	}
+	// In one dimension the distance is the area between the cumulative distributions.
+	var d, cdf float64
+	for i, a := range h1[:len(h1)-1] {
+		cdf += a - h2[i]
+		d += math.Abs(cdf)
+	}
+	return d / float64(len(h1)-1)
+}
@@ -2,6 +2,8 @@ package imaging
 
 import (
 	"image"
+	"image/color"
+	"math"
 	"testing"
 )
 
@@ -51,6 +53,92 @@ func TestHistogram(t *testing.T) {
 	}
 }
 
+func TestColorHistogram(t *testing.T) {
+	img := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 1),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0x00, 0x10, 0x20, 0xff, 0x00, 0x10, 0x30, 0x00,
+			0xff, 0x10, 0x20, 0x80, 0x80, 0x80, 0x80, 0xff,
+		},
+	}
+	want := [3][256]float64{
+		{0x00: 0.5, 0x80: 0.25, 0xff: 0.25},
+		{0x10: 0.75, 0x80: 0.25},
+		{0x20: 0.5, 0x30: 0.25, 0x80: 0.25},
+	}
+	if got := ColorHistogram(img); got != want {
+		t.Fatalf("got histogram %#v want %#v", got, want)
+	}
+	if got := ColorHistogram(&image.NRGBA{}); got != ([3][256]float64{}) {
+		t.Fatalf("got histogram %#v want zero", got)
+	}
+}
+
+func TestHistogramDistances(t *testing.T) {
+	testCases := []struct {
+		name              string
+		h1, h2            []float64
+		chi2, bhatta, emd float64
+	}{
+		{"equal", []float64{0.25, 0.5, 0.25}, []float64{0.25, 0.5, 0.25}, 0, 0, 0},
+		{"disjoint", []float64{1, 0, 0}, []float64{0, 0, 1}, 2, 1, 1},
+		{"neighbors", []float64{1, 0, 0}, []float64{0, 1, 0}, 2, 1, 0.5},
+		{"half", []float64{0.5, 0.5, 0}, []float64{0.5, 0, 0.5}, 1, math.Sqrt(0.5), 0.25},
+		{"zero", []float64{0, 0, 0}, []float64{0, 0, 0}, 0, 0, 0},
+		{"empty", nil, nil, 0, 0, 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := HistogramChiSquare(tc.h1, tc.h2); math.Abs(got-tc.chi2) > 1e-9 {
+				t.Fatalf("HistogramChiSquare: got %v want %v", got, tc.chi2)
+			}
+			if got := HistogramBhattacharyya(tc.h1, tc.h2); math.Abs(got-tc.bhatta) > 1e-9 {
+				t.Fatalf("HistogramBhattacharyya: got %v want %v", got, tc.bhatta)
+			}
+			if got := HistogramEMD(tc.h1, tc.h2); math.Abs(got-tc.emd) > 1e-9 {
+				t.Fatalf("HistogramEMD: got %v want %v", got, tc.emd)
+			}
+		})
+	}
+	if got := HistogramBhattacharyya([]float64{2, 6}, []float64{1, 3}); got > 1e-6 {
+		t.Fatalf("HistogramBhattacharyya: got %v for proportional counts want 0", got)
+	}
+	if got := HistogramBhattacharyya([]float64{1, 0}, []float64{0, 0}); got != 1 {
+		t.Fatalf("HistogramBhattacharyya: got %v for an empty histogram want 1", got)
+	}
+}
+
+func TestHistogramSimilarity(t *testing.T) {
+	// The resized image is nearer to the source than a differently colored image.
+	src := ColorHistogram(testdataBranchesPNG)
+	resized := ColorHistogram(Resize(testdataBranchesPNG, 150, 0, Lanczos))
+	other := ColorHistogram(AdjustBrightness(testdataBranchesPNG, -40))
+	red := ColorHistogram(New(10, 10, color.NRGBA{0xff, 0, 0, 0xff}))
+	for name, distance := range map[string]func(h1, h2 []float64) float64{
+		"chi-square":    HistogramChiSquare,
+		"bhattacharyya": HistogramBhattacharyya,
+		"emd":           HistogramEMD,
+	} {
+		var near, far, farthest float64
+		for c := 0; c < 3; c++ {
+			near += distance(src[c][:], resized[c][:])
+			far += distance(src[c][:], other[c][:])
+			farthest += distance(src[c][:], red[c][:])
+		}
+		if !(near < far && far < farthest) {
+			t.Fatalf("%s: got distances %v, %v, %v", name, near, far, farthest)
+		}
+	}
+}
+
+func BenchmarkColorHistogram(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ColorHistogram(testdataBranchesJPG)
+	}
+}
+
 func BenchmarkHistogram(b *testing.B) {
 	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
@@ -0,0 +1,258 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// HoughLine is a straight line found by HoughLines. The line is the set of points (x, y)
+// satisfying x*cos(Theta) + y*sin(Theta) = Rho, where Theta is in range [0, Pi) and
+// the coordinates are relative to the top-left corner of the image bounds.
+type HoughLine struct {
+	Rho   float64
+	Theta float64
+	Votes int // Number of foreground pixels lying on the line.
+}
+
+// HoughCircle is a circle found by HoughCircles. The center coordinates are relative
+// to the top-left corner of the image bounds.
+type HoughCircle struct {
+	X, Y   int
+	Radius int
+	Score  float64 // Fraction of the circumference covered by foreground pixels.
+}
+
+// foregroundPoints returns the coordinates of the pixels with a luminance of at least 128.
+func foregroundPoints(img image.Image) ([]image.Point, int, int) {
+	src := newScanner(img)
+	var pts []image.Point
+	scanLine := make([]uint8, src.w*4)
+	for y := 0; y < src.h; y++ {
+		src.scan(0, y, src.w, y+1, scanLine)
+		for x := 0; x < src.w; x++ {
+			s := scanLine[x*4 : x*4+4 : x*4+4]
+			lum := (0.299*float64(s[0]) + 0.587*float64(s[1]) + 0.114*float64(s[2])) * float64(s[3]) / 255
+			if lum >= 127.5 {
+				pts = append(pts, image.Pt(x, y))
+			}
+		}
+	}
+	return pts, src.w, src.h
+}
+
+// HoughLines detects straight lines in a binary image (e.g. the output of an edge detector)
+// using the Hough transform. Pixels with a luminance of at least 128 are the foreground.
+// Only lines with at least threshold foreground pixels are returned. The angle resolution
+// is 1 degree and the distance resolution is 1 pixel. The lines are sorted by the number
+// of votes in descending order.
+//
+// Example:
+//
+//	lines := imaging.HoughLines(edges, 100)
+func HoughLines(img image.Image, threshold int) []HoughLine {
+	const numTheta = 180
+
+	pts, w, h := foregroundPoints(img)
+	if len(pts) == 0 {
+		return nil
+	}
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	maxRho := int(math.Ceil(math.Hypot(float64(w), float64(h))))
+	numRho := 2*maxRho + 1
+	var sins, coss [numTheta]float64
+	for t := 0; t < numTheta; t++ {
+		sins[t], coss[t] = math.Sincos(float64(t) * math.Pi / numTheta)
+	}
+
+	acc := make([]int, numTheta*numRho)
+	parallel(0, numTheta, func(ts <-chan int) {
+		for t := range ts {
+			row := acc[t*numRho : (t+1)*numRho]
+			for _, p := range pts {
+				rho := float64(p.X)*coss[t] + float64(p.Y)*sins[t]
+				row[int(math.Floor(rho+0.5))+maxRho]++
+			}
+		}
+	})
+
+	var lines []HoughLine
+	for t := 0; t < numTheta; t++ {
+		for r := 0; r < numRho; r++ {
+			v := acc[t*numRho+r]
+			if v < threshold || !houghLinePeak(acc, numTheta, numRho, t, r) {
+				continue
+			}
+			lines = append(lines, HoughLine{
+				Rho:   float64(r - maxRho),
+				Theta: float64(t) * math.Pi / numTheta,
+				Votes: v,
+			})
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Votes > lines[j].Votes })
+	return lines
+}
+
+// houghLinePeak reports whether the accumulator cell is a local maximum in its neighbourhood
+// of 5 degrees and 3 pixels. The theta axis wraps around with the sign of rho flipped.
+func houghLinePeak(acc []int, numTheta, numRho, t, r int) bool {
+	const dtMax, drMax = 5, 3
+	v := acc[t*numRho+r]
+	for dt := -dtMax; dt <= dtMax; dt++ {
+		for dr := -drMax; dr <= drMax; dr++ {
+			if dt == 0 && dr == 0 {
+				continue
+			}
+			nt, nr := t+dt, r+dr
+			if nt < 0 || nt >= numTheta {
+				nt = (nt + numTheta) % numTheta
+				nr = numRho - 1 - nr
+			}
+			if nr < 0 || nr >= numRho {
+				continue
+			}
+			nv := acc[nt*numRho+nr]
+			// Break ties in favour of the first cell in scan order.
+			if nv > v || (nv == v && nt*numRho+nr < t*numRho+r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// HoughCircles detects circles with radii in range [minRadius, maxRadius] in a binary image
+// (e.g. the output of an edge detector) using the Hough transform. Pixels with a luminance
+// of at least 128 are the foreground. The threshold parameter is the minimum fraction of
+// the circumference that must be covered by foreground pixels, it must be from 0.0 to 1.0,
+// typically 0.5. The circles are sorted by their score in descending order.
+//
+// Example:
+//
+//	circles := imaging.HoughCircles(edges, 10, 40, 0.6)
+func HoughCircles(img image.Image, minRadius, maxRadius int, threshold float64) []HoughCircle {
+	if minRadius < 1 {
+		minRadius = 1
+	}
+	if maxRadius < minRadius {
+		return nil
+	}
+	pts, w, h := foregroundPoints(img)
+	if len(pts) == 0 {
+		return nil
+	}
+
+	var candidates []HoughCircle
+	found := make(chan []HoughCircle, maxRadius-minRadius+1)
+	parallel(minRadius, maxRadius+1, func(rs <-chan int) {
+		acc := make([]int, w*h)
+		for r := range rs {
+			for i := range acc {
+				acc[i] = 0
+			}
+			offsets := circleOffsets(r)
+			for _, p := range pts {
+				for _, o := range offsets {
+					x, y := p.X+o.X, p.Y+o.Y
+					if x >= 0 && y >= 0 && x < w && y < h {
+						acc[y*w+x]++
+					}
+				}
+			}
+
+			var circles []HoughCircle
+			minVotes := int(math.Ceil(threshold * float64(len(offsets))))
+			if minVotes < 1 {
+				minVotes = 1
+			}
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					v := acc[y*w+x]
+					if v < minVotes || !houghCirclePeak(acc, w, h, x, y) {
+						continue
+					}
+					circles = append(circles, HoughCircle{
+						X:      x,
+						Y:      y,
+						Radius: r,
+						Score:  math.Min(float64(v)/float64(len(offsets)), 1),
+					})
+				}
+			}
+			found <- circles
+		}
+	})
+	close(found)
+	for circles := range found {
+		candidates = append(candidates, circles...)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Radius != b.Radius {
+			return a.Radius > b.Radius
+		}
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		return a.X < b.X
+	})
+
+	// Suppress the weaker detections of the same circle.
+	var circles []HoughCircle
+	for _, c := range candidates {
+		duplicate := false
+		for _, o := range circles {
+			tol := float64(minint(c.Radius, o.Radius)) / 2
+			if math.Hypot(float64(c.X-o.X), float64(c.Y-o.Y)) <= tol && math.Abs(float64(c.Radius-o.Radius)) <= tol {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			circles = append(circles, c)
+		}
+	}
+	return circles
+}
+
+// circleOffsets returns the distinct integer offsets of the points of a circle with the given radius.
+func circleOffsets(r int) []image.Point {
+	n := int(math.Ceil(2*math.Pi*float64(r))) * 2
+	seen := make(map[image.Point]bool, n)
+	var offsets []image.Point
+	for i := 0; i < n; i++ {
+		sin, cos := math.Sincos(2 * math.Pi * float64(i) / float64(n))
+		p := image.Pt(int(math.Floor(float64(r)*cos+0.5)), int(math.Floor(float64(r)*sin+0.5)))
+		if !seen[p] {
+			seen[p] = true
+			offsets = append(offsets, p)
+		}
+	}
+	return offsets
+}
+
+// houghCirclePeak reports whether the accumulator cell is a local maximum in its 3x3 neighbourhood.
+func houghCirclePeak(acc []int, w, h, x, y int) bool {
+	v := acc[y*w+x]
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			nx, ny := x+dx, y+dy
+			if (dx == 0 && dy == 0) || nx < 0 || ny < 0 || nx >= w || ny >= h {
+				continue
+			}
+			nv := acc[ny*w+nx]
+			if nv > v || (nv == v && ny*w+nx < y*w+x) {
+				return false
+			}
+		}
+	}
+	return true
+}
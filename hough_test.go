@@ -0,0 +1,76 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestHoughLines(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		src.SetGray(x, 5, color.Gray{0xff})
+	}
+	for y := 0; y < 30; y++ {
+		src.SetGray(12, y, color.Gray{0xff})
+	}
+	for i := 0; i < 20; i++ {
+		src.SetGray(20+i, 10+i, color.Gray{0xff})
+	}
+
+	got := HoughLines(src, 15)
+	if len(got) != 3 {
+		t.Fatalf("got %d lines want 3: %+v", len(got), got)
+	}
+	want := []HoughLine{
+		{Rho: 5, Theta: math.Pi / 2, Votes: 40},
+		{Rho: 12, Theta: 0, Votes: 30},
+		{Rho: -7, Theta: 3 * math.Pi / 4, Votes: 20},
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Votes < w.Votes || math.Abs(g.Rho-w.Rho) > 1 || math.Abs(g.Theta-w.Theta) > 0.02 {
+			t.Fatalf("line %d: got %+v want %+v", i, g, w)
+		}
+	}
+
+	if got := HoughLines(image.NewGray(image.Rect(0, 0, 10, 10)), 1); len(got) != 0 {
+		t.Fatalf("got %d lines in empty image", len(got))
+	}
+}
+
+func TestHoughCircles(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 60, 50))
+	for _, o := range circleOffsets(12) {
+		src.SetGray(20+o.X, 25+o.Y, color.Gray{0xff})
+	}
+	for _, o := range circleOffsets(6) {
+		src.SetGray(45+o.X, 15+o.Y, color.Gray{0xff})
+	}
+
+	got := HoughCircles(src, 4, 15, 0.8)
+	want := []HoughCircle{
+		{X: 20, Y: 25, Radius: 12, Score: 1},
+		{X: 45, Y: 15, Radius: 6, Score: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got circles %+v want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got circles %+v want %+v", got, want)
+		}
+	}
+
+	if got := HoughCircles(src, 10, 5, 0.5); len(got) != 0 {
+		t.Fatalf("got %d circles for empty radius range", len(got))
+	}
+}
+
+func BenchmarkHoughLines(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		HoughLines(testdataBranchesPNG, 200)
+	}
+}
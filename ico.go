@@ -0,0 +1,265 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+)
+
+// This file implements an ICO encoder and decoder. The encoder stores each icon as PNG,
+// the decoder supports both PNG and BMP icons.
+
+var (
+	errInvalidICO  = errors.New("imaging: invalid ICO data")
+	errICOSize     = errors.New("imaging: ICO images must be from 1x1 to 256x256 pixels")
+	errICONoImages = errors.New("imaging: no ICO images to encode")
+)
+
+const icoHeaderLen, icoEntryLen = 6, 16
+
+// GenerateFavicon returns square icons of the given sizes (16, 32 and 48 by default)
+// to be encoded as a favicon. The image is scaled to fit each icon and centered on
+// a transparent background. Sizes out of the range from 1 to 256 are skipped.
+//
+// Example:
+//
+//	icons := imaging.GenerateFavicon(logoImage, 16, 32, 48, 64)
+//	err := imaging.EncodeICO(file, icons)
+func GenerateFavicon(img image.Image, sizes ...int) []image.Image {
+	if len(sizes) == 0 {
+		sizes = []int{16, 32, 48}
+	}
+	b := img.Bounds()
+	var icons []image.Image
+	for _, size := range sizes {
+		if size < 1 || size > 256 {
+			continue
+		}
+		w, h := size, size
+		if b.Dx() > b.Dy() {
+			h = int(math.Max(1, math.Round(float64(size*b.Dy())/float64(b.Dx()))))
+		} else if b.Dy() > b.Dx() {
+			w = int(math.Max(1, math.Round(float64(size*b.Dx())/float64(b.Dy()))))
+		}
+		icon := Resize(img, w, h, Lanczos)
+		if w != h {
+			icon = PasteCenter(New(size, size, color.Transparent), icon)
+		}
+		icons = append(icons, icon)
+	}
+	return icons
+}
+
+// EncodeICO writes the images to w as a single ICO file, e.g. icons of several sizes
+// made by GenerateFavicon. The images must be from 1x1 to 256x256 pixels.
+func EncodeICO(w io.Writer, icons []image.Image) error {
+	if len(icons) == 0 {
+		return errICONoImages
+	}
+
+	data := make([][]byte, len(icons))
+	for i, icon := range icons {
+		b := icon.Bounds()
+		if b.Dx() < 1 || b.Dy() < 1 || b.Dx() > 256 || b.Dy() > 256 {
+			return errICOSize
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, icon); err != nil {
+			return err
+		}
+		data[i] = buf.Bytes()
+	}
+
+	bw := bufio.NewWriter(w)
+	le := binary.LittleEndian
+	header := make([]byte, icoHeaderLen+icoEntryLen*len(icons))
+	le.PutUint16(header[2:], 1) // Icon resource type.
+	le.PutUint16(header[4:], uint16(len(icons)))
+	offset := len(header)
+	for i, icon := range icons {
+		e := header[icoHeaderLen+i*icoEntryLen:]
+		b := icon.Bounds()
+		// The size of 256 is stored as 0.
+		e[0] = uint8(b.Dx())
+		e[1] = uint8(b.Dy())
+		le.PutUint16(e[4:], 1)  // Color planes.
+		le.PutUint16(e[6:], 32) // Bits per pixel.
+		le.PutUint32(e[8:], uint32(len(data[i])))
+		le.PutUint32(e[12:], uint32(offset))
+		offset += len(data[i])
+	}
+	bw.Write(header)
+	for _, d := range data {
+		bw.Write(d)
+	}
+	return bw.Flush()
+}
+
+// encodeICO writes the image to w as an ICO file with icons of the given sizes. Without sizes
+// the image is stored as is, scaled down to fit 256x256 pixels if it's larger.
+func encodeICO(w io.Writer, img image.Image, sizes []int) error {
+	if len(sizes) > 0 {
+		return EncodeICO(w, GenerateFavicon(img, sizes...))
+	}
+	if b := img.Bounds(); b.Dx() > 256 || b.Dy() > 256 {
+		img = Fit(img, 256, 256, Lanczos)
+	}
+	return EncodeICO(w, []image.Image{img})
+}
+
+// decodeICO decodes the largest image of the ICO file data.
+func decodeICO(data []byte) (image.Image, error) {
+	icon, err := largestICOIcon(data)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(icon, []byte("\x89PNG\r\n\x1a\n")) {
+		return png.Decode(bytes.NewReader(icon))
+	}
+	return decodeICOBitmap(icon)
+}
+
+// decodeICOConfig returns the size of the largest image of the ICO file data. BMP icons
+// are reported as NRGBA images as they may be transparent.
+func decodeICOConfig(data []byte) (image.Config, error) {
+	icon, err := largestICOIcon(data)
+	if err != nil {
+		return image.Config{}, err
+	}
+	if bytes.HasPrefix(icon, []byte("\x89PNG\r\n\x1a\n")) {
+		return png.DecodeConfig(bytes.NewReader(icon))
+	}
+	le := binary.LittleEndian
+	if len(icon) < 40 {
+		return image.Config{}, errInvalidICO
+	}
+	width := int(int32(le.Uint32(icon[4:])))
+	height := int(int32(le.Uint32(icon[8:]))) / 2
+	if width <= 0 || height <= 0 || width > 256 || height > 256 {
+		return image.Config{}, errInvalidICO
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: width, Height: height}, nil
+}
+
+// largestICOIcon returns the data of the largest image of the ICO file data.
+func largestICOIcon(data []byte) ([]byte, error) {
+	le := binary.LittleEndian
+	if len(data) < icoHeaderLen || le.Uint16(data) != 0 || le.Uint16(data[2:]) != 1 {
+		return nil, errInvalidICO
+	}
+	count := int(le.Uint16(data[4:]))
+	if count == 0 || len(data) < icoHeaderLen+count*icoEntryLen {
+		return nil, errInvalidICO
+	}
+
+	best, bestArea, bestBPP := -1, 0, 0
+	for i := 0; i < count; i++ {
+		e := data[icoHeaderLen+i*icoEntryLen:]
+		w, h := int(e[0]), int(e[1])
+		if w == 0 {
+			w = 256
+		}
+		if h == 0 {
+			h = 256
+		}
+		bpp := int(le.Uint16(e[6:]))
+		if w*h > bestArea || (w*h == bestArea && bpp > bestBPP) {
+			best, bestArea, bestBPP = i, w*h, bpp
+		}
+	}
+	e := data[icoHeaderLen+best*icoEntryLen:]
+	size := int64(le.Uint32(e[8:]))
+	offset := int64(le.Uint32(e[12:]))
+	if offset+size > int64(len(data)) {
+		return nil, errInvalidICO
+	}
+	return data[offset : offset+size], nil
+}
+
+// decodeICOBitmap decodes a BMP icon. It's a BMP file without the file header, its height
+// is doubled and the color data is followed by a 1-bit transparency (AND) mask.
+func decodeICOBitmap(icon []byte) (image.Image, error) {
+	le := binary.LittleEndian
+	if len(icon) < 40 {
+		return nil, errInvalidICO
+	}
+	infoLen := int(le.Uint32(icon))
+	if infoLen < 40 || infoLen > len(icon) {
+		return nil, errInvalidICO
+	}
+	width := int(int32(le.Uint32(icon[4:])))
+	height := int(int32(le.Uint32(icon[8:]))) / 2
+	bpp := int(le.Uint16(icon[14:]))
+	colorsUsed := int(le.Uint32(icon[32:]))
+	if width <= 0 || height <= 0 || width > 256 || height > 256 {
+		return nil, errInvalidICO
+	}
+
+	info := append([]byte(nil), icon[:infoLen]...)
+	le.PutUint32(info[8:], uint32(height))
+	var masks []byte
+	if bpp == 32 && infoLen == 40 && le.Uint32(info[16:]) == bmpRGB {
+		// Unlike BMP files, the icons use the alpha channel of the 32-bit pixels.
+		le.PutUint32(info[16:], bmpAlphaBitFields)
+		masks = make([]byte, 16)
+		le.PutUint32(masks[0:], 0x00ff0000)
+		le.PutUint32(masks[4:], 0x0000ff00)
+		le.PutUint32(masks[8:], 0x000000ff)
+		le.PutUint32(masks[12:], 0xff000000)
+	}
+	palLen := 0
+	if bpp <= 8 {
+		if colorsUsed == 0 || colorsUsed > 1<<bpp {
+			colorsUsed = 1 << bpp
+		}
+		palLen = colorsUsed * 4
+	}
+	rest := icon[infoLen:]
+	pixOffset := 14 + len(info) + len(masks) + palLen
+
+	file := make([]byte, 14, 14+len(info)+len(masks)+len(rest))
+	copy(file, "BM")
+	le.PutUint32(file[2:], uint32(cap(file)))
+	le.PutUint32(file[10:], uint32(pixOffset))
+	file = append(file, info...)
+	file = append(file, masks...)
+	file = append(file, rest...)
+	img, err := decodeBMP(bytes.NewReader(file))
+	if err != nil {
+		return nil, err
+	}
+	if bpp == 32 {
+		return img, nil
+	}
+
+	// Apply the transparency mask, set bits are transparent pixels.
+	maskStart := palLen + (bpp*width+31)/32*4*height
+	maskRow := (width + 31) / 32 * 4
+	if len(rest) < maskStart+maskRow*height {
+		return img, nil
+	}
+	mask := rest[maskStart:]
+	var dst *image.NRGBA
+	for y := 0; y < height; y++ {
+		row := mask[(height-1-y)*maskRow:]
+		for x := 0; x < width; x++ {
+			if row[x/8]&(0x80>>uint(x%8)) == 0 {
+				continue
+			}
+			if dst == nil {
+				dst = Clone(img)
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{})
+		}
+	}
+	if dst == nil {
+		return img, nil
+	}
+	return dst, nil
+}
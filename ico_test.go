@@ -0,0 +1,187 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"testing"
+)
+
+// icoFile builds an ICO file with the given icons of the given sizes.
+func icoFile(sizes []int, icons ...[]byte) []byte {
+	le := binary.LittleEndian
+	data := make([]byte, icoHeaderLen+icoEntryLen*len(icons))
+	le.PutUint16(data[2:], 1)
+	le.PutUint16(data[4:], uint16(len(icons)))
+	for i, icon := range icons {
+		e := data[icoHeaderLen+i*icoEntryLen:]
+		e[0], e[1] = uint8(sizes[i]), uint8(sizes[i])
+		le.PutUint32(e[8:], uint32(len(icon)))
+		le.PutUint32(e[12:], uint32(len(data)))
+		data = append(data, icon...)
+	}
+	return data
+}
+
+// icoBitmap turns a BMP file into a BMP icon with the transparency mask rows.
+func icoBitmap(file []byte, maskRows ...[]byte) []byte {
+	icon := append([]byte(nil), file[14:]...)
+	h := binary.LittleEndian.Uint32(icon[8:])
+	binary.LittleEndian.PutUint32(icon[8:], 2*h)
+	for _, row := range maskRows {
+		icon = append(icon, row...)
+	}
+	return icon
+}
+
+func TestEncodeICO(t *testing.T) {
+	icons := GenerateFavicon(testdataBranchesPNG, 16, 32, 256)
+	var buf bytes.Buffer
+	if err := EncodeICO(&buf, icons); err != nil {
+		t.Fatalf("EncodeICO: %v", err)
+	}
+	data := buf.Bytes()
+	if got := binary.LittleEndian.Uint16(data[4:]); got != 3 {
+		t.Fatalf("got %d icons want 3", got)
+	}
+	for i, want := range []uint8{16, 32, 0} {
+		if got := data[icoHeaderLen+i*icoEntryLen]; got != want {
+			t.Fatalf("icon %d: got width byte %d want %d", i, got, want)
+		}
+	}
+
+	got, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !compareNRGBA(Clone(got), Clone(icons[2]), 0) {
+		t.Fatal("the largest icon differs")
+	}
+
+	if err := EncodeICO(&buf, nil); err != errICONoImages {
+		t.Fatalf("got error %v want %v", err, errICONoImages)
+	}
+	if err := EncodeICO(&buf, []image.Image{image.NewNRGBA(image.Rect(0, 0, 257, 10))}); err != errICOSize {
+		t.Fatalf("got error %v want %v", err, errICOSize)
+	}
+}
+
+func TestEncodeICOSizes(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testdataBranchesPNG, ICO, ICOSizes(48, 24)); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	got, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got.Bounds() != image.Rect(0, 0, 48, 48) {
+		t.Fatalf("got bounds %v want 48x48", got.Bounds())
+	}
+
+	// Without the sizes large images are scaled down.
+	buf.Reset()
+	if err := Encode(&buf, testdataBranchesPNG, ICO); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	got, err = Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got.Bounds() != image.Rect(0, 0, 256, 170) {
+		t.Fatalf("got bounds %v want 256x170", got.Bounds())
+	}
+}
+
+func TestGenerateFavicon(t *testing.T) {
+	icons := GenerateFavicon(testdataBranchesPNG)
+	if len(icons) != 3 {
+		t.Fatalf("got %d icons want 3", len(icons))
+	}
+	for i, size := range []int{16, 32, 48} {
+		if icons[i].Bounds() != image.Rect(0, 0, size, size) {
+			t.Fatalf("icon %d: got bounds %v want %dx%d", i, icons[i].Bounds(), size, size)
+		}
+	}
+	// The 600x400 image is centered vertically.
+	icon := icons[2].(*image.NRGBA)
+	if c := icon.NRGBAAt(24, 2); c.A != 0 {
+		t.Fatalf("got %v at the top want transparent", c)
+	}
+	if c := icon.NRGBAAt(24, 24); c.A != 0xff {
+		t.Fatalf("got %v at the center want opaque", c)
+	}
+
+	if icons := GenerateFavicon(testdataBranchesPNG, 0, 300, 8); len(icons) != 1 || icons[0].Bounds().Dx() != 8 {
+		t.Fatalf("got %d icons want one 8x8 icon", len(icons))
+	}
+}
+
+func TestDecodeICOBitmap(t *testing.T) {
+	palette := []byte{0x00, 0x00, 0xff, 0x00, 0xff, 0x00, 0x00, 0x00}
+	testCases := []struct {
+		name string
+		icon []byte
+		want *image.NRGBA
+	}{
+		{
+			"1-bit with mask",
+			icoBitmap(bmpFile(2, 2, 1, bmpRGB, palette, []byte{0x80, 0, 0, 0}, []byte{0x40, 0, 0, 0}),
+				[]byte{0x00, 0, 0, 0}, []byte{0x80, 0, 0, 0}),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 2),
+				Stride: 2 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff,
+					0x00, 0x00, 0xff, 0xff, 0xff, 0x00, 0x00, 0xff,
+				},
+			},
+		},
+		{
+			"32-bit with alpha",
+			icoBitmap(bmpFile(2, 1, 32, bmpRGB, nil, []byte{0x10, 0x20, 0x30, 0x80, 0x40, 0x50, 0x60, 0xff}),
+				[]byte{0, 0, 0, 0}),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix:    []uint8{0x30, 0x20, 0x10, 0x80, 0x60, 0x50, 0x40, 0xff},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The larger icon is decoded.
+			small := icoBitmap(bmpFile(1, 1, 24, bmpRGB, nil, []byte{0, 0, 0, 0}), []byte{0, 0, 0, 0})
+			got, err := Decode(bytes.NewReader(icoFile([]int{1, 2}, small, tc.icon)))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !compareNRGBA(Clone(got), tc.want, 0) {
+				t.Fatalf("got %#v want %#v", Clone(got), tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeICOFails(t *testing.T) {
+	valid := icoFile([]int{1}, icoBitmap(bmpFile(1, 1, 24, bmpRGB, nil, []byte{0, 0, 0, 0}), []byte{0, 0, 0, 0}))
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{"no icons", []byte{0, 0, 1, 0, 0, 0}},
+		{"bad directory", append([]byte{0, 0, 1, 0, 2, 0}, valid[6:]...)},
+		{"truncated icon", valid[:len(valid)-1]},
+		{"bad bitmap", icoFile([]int{1}, []byte("not a bitmap header"))},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := decodeICO(tc.data); err == nil {
+				t.Fatal("expected error got nil")
+			}
+		})
+	}
+	if _, err := Decode(bytes.NewReader(valid)); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package imagingtest
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/154pinkchairs/imaging"
+)
+
+// Noise generates an opaque image of the given size resembling a photo without a subject:
+// smooth blotches of color with finer detail and sensor-like grain. The same seed generates
+// the same image, so the benchmarks and fuzz tests have reproducible inputs of any size.
+// The non-positive sizes return an empty image.
+//
+// Example:
+//
+//	func BenchmarkResize(b *testing.B) {
+//		src := imagingtest.Noise(4000, 3000, 1)
+//		for i := 0; i < b.N; i++ {
+//			imaging.Resize(src, 800, 0, imaging.Lanczos)
+//		}
+//	}
+func Noise(width, height int, seed int64) *image.NRGBA {
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	// The octaves of the color blotches and of the detail, with their weights.
+	coarse := randomGrid(rnd, 4, 4)
+	fine := randomGrid(rnd, maxint(width/16, 2), maxint(height/16, 2))
+	dst := imaging.Resize(coarse, width, height, imaging.Linear)
+	detail := imaging.Resize(fine, width, height, imaging.Linear)
+	for i := 0; i < len(dst.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			v := 0.75*float64(dst.Pix[i+c]) + 0.25*float64(detail.Pix[i+c]) + rnd.NormFloat64()*4
+			dst.Pix[i+c] = clamp(v)
+		}
+		dst.Pix[i+3] = 0xff
+	}
+	return dst
+}
+
+// Gradient generates an opaque image of the given size filled with a linear gradient of
+// two random colors in a random direction, or with a radial one around a random center.
+// The same seed generates the same image. The non-positive sizes return an empty image.
+//
+// Example:
+//
+//	img := imagingtest.Gradient(640, 480, 7)
+//	var buf bytes.Buffer
+//	imaging.Encode(&buf, img, imaging.PNG)
+//	decoded, _ := imaging.Decode(&buf)
+//	imagingtest.AssertEqual(t, decoded, img, 0)
+func Gradient(width, height int, seed int64) *image.NRGBA {
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
+	return gradient(rand.New(rand.NewSource(seed)), width, height)
+}
+
+func gradient(rnd *rand.Rand, width, height int) *image.NRGBA {
+	c1, c2 := randomColor(rnd, 0xff), randomColor(rnd, 0xff)
+	w, h := float64(width), float64(height)
+
+	var t func(x, y float64) float64
+	if rnd.Intn(2) == 0 {
+		angle := rnd.Float64() * 2 * math.Pi
+		dx, dy := math.Cos(angle), math.Sin(angle)
+		// The projections of the corners span the gradient.
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, p := range [4][2]float64{{0, 0}, {w, 0}, {0, h}, {w, h}} {
+			v := p[0]*dx + p[1]*dy
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+		t = func(x, y float64) float64 { return (x*dx + y*dy - lo) / (hi - lo) }
+	} else {
+		cx, cy := rnd.Float64()*w, rnd.Float64()*h
+		r := math.Hypot(math.Max(cx, w-cx), math.Max(cy, h-cy))
+		t = func(x, y float64) float64 { return math.Hypot(x-cx, y-cy) / r }
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := math.Min(math.Max(t(float64(x)+0.5, float64(y)+0.5), 0), 1)
+			i := y*dst.Stride + x*4
+			dst.Pix[i+0] = clamp(float64(c1.R) + (float64(c2.R)-float64(c1.R))*v)
+			dst.Pix[i+1] = clamp(float64(c1.G) + (float64(c2.G)-float64(c1.G))*v)
+			dst.Pix[i+2] = clamp(float64(c1.B) + (float64(c2.B)-float64(c1.B))*v)
+			dst.Pix[i+3] = 0xff
+		}
+	}
+	return dst
+}
+
+// Shapes generates an opaque scene of the given size: random rectangles and ellipses,
+// some of them translucent, over a Gradient background. It has the sharp edges and flat
+// areas missing in Noise, e.g. for the edge detection and the compression. The same seed
+// generates the same image. The non-positive sizes return an empty image.
+//
+// Example:
+//
+//	for seed := int64(0); seed < 10; seed++ {
+//		img := imagingtest.Shapes(300, 200, seed)
+//		imagingtest.AssertEqual(t, imaging.FlipH(imaging.FlipH(img)), img, 0)
+//	}
+func Shapes(width, height int, seed int64) *image.NRGBA {
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	dst := gradient(rnd, width, height)
+	w, h := float64(width), float64(height)
+	n := 4 + rnd.Intn(8)
+	for k := 0; k < n; k++ {
+		a := uint8(0xff)
+		if rnd.Intn(3) == 0 {
+			a = uint8(0x40 + rnd.Intn(0x80))
+		}
+		c := randomColor(rnd, a)
+		// The shapes are from a tenth to a half of the image.
+		sw, sh := w*(0.1+0.4*rnd.Float64()), h*(0.1+0.4*rnd.Float64())
+		x0, y0 := rnd.Float64()*(w-sw), rnd.Float64()*(h-sh)
+		ellipse := rnd.Intn(2) == 0
+		shape := image.Rect(int(x0), int(y0), int(math.Ceil(x0+sw)), int(math.Ceil(y0+sh))).Intersect(dst.Rect)
+		for y := shape.Min.Y; y < shape.Max.Y; y++ {
+			for x := shape.Min.X; x < shape.Max.X; x++ {
+				if ellipse {
+					ex := (float64(x) + 0.5 - x0 - sw/2) / (sw / 2)
+					ey := (float64(y) + 0.5 - y0 - sh/2) / (sh / 2)
+					if ex*ex+ey*ey > 1 {
+						continue
+					}
+				}
+				i := y*dst.Stride + x*4
+				f := float64(c.A) / 255
+				dst.Pix[i+0] = clamp(float64(dst.Pix[i+0])*(1-f) + float64(c.R)*f)
+				dst.Pix[i+1] = clamp(float64(dst.Pix[i+1])*(1-f) + float64(c.G)*f)
+				dst.Pix[i+2] = clamp(float64(dst.Pix[i+2])*(1-f) + float64(c.B)*f)
+			}
+		}
+	}
+	return dst
+}
+
+// randomGrid returns an image of the given size with the random opaque colors.
+func randomGrid(rnd *rand.Rand, width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	rnd.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff
+	}
+	return img
+}
+
+func randomColor(rnd *rand.Rand, a uint8) color.NRGBA {
+	return color.NRGBA{uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), uint8(rnd.Intn(256)), a}
+}
+
+// clamp rounds and clamps float64 value to fit into uint8.
+func clamp(x float64) uint8 {
+	v := int64(x + 0.5)
+	if v > 255 {
+		return 255
+	}
+	if v > 0 {
+		return uint8(v)
+	}
+	return 0
+}
+
+func maxint(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
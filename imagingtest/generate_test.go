@@ -0,0 +1,69 @@
+package imagingtest
+
+import (
+	"image"
+	"math"
+	"testing"
+
+	"github.com/154pinkchairs/imaging"
+)
+
+func TestGenerators(t *testing.T) {
+	generators := []struct {
+		name string
+		fn   func(width, height int, seed int64) *image.NRGBA
+	}{
+		{"Noise", Noise},
+		{"Gradient", Gradient},
+		{"Shapes", Shapes},
+	}
+	for _, g := range generators {
+		t.Run(g.name, func(t *testing.T) {
+			for _, size := range []image.Point{{1, 1}, {7, 3}, {120, 90}} {
+				img := g.fn(size.X, size.Y, 42)
+				if img.Rect != image.Rect(0, 0, size.X, size.Y) {
+					t.Fatalf("got bounds %v want size %v", img.Rect, size)
+				}
+				for i := 3; i < len(img.Pix); i += 4 {
+					if img.Pix[i] != 0xff {
+						t.Fatalf("got alpha %d want opaque", img.Pix[i])
+					}
+				}
+				if d, _ := Compare(g.fn(size.X, size.Y, 42), img, 0, imaging.DistanceRGB); d.Count != 0 {
+					t.Fatalf("size %v: the same seed generated a different image", size)
+				}
+			}
+			img := g.fn(120, 90, 42)
+			if d, _ := Compare(g.fn(120, 90, 43), img, 0, imaging.DistanceRGB); d.Count == 0 {
+				t.Fatal("different seeds generated the same image")
+			}
+			if s := stddev(img); s < 5 {
+				t.Fatalf("got standard deviation %g of the channel values, want a varied image", s)
+			}
+			if img := g.fn(0, 10, 42); !img.Rect.Empty() {
+				t.Fatalf("got bounds %v want empty image", img.Rect)
+			}
+		})
+	}
+}
+
+func stddev(img *image.NRGBA) float64 {
+	var sum, sum2, n float64
+	for i, v := range img.Pix {
+		if i%4 == 3 {
+			continue
+		}
+		sum += float64(v)
+		sum2 += float64(v) * float64(v)
+		n++
+	}
+	mean := sum / n
+	return math.Sqrt(sum2/n - mean*mean)
+}
+
+func BenchmarkNoise(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Noise(640, 480, int64(i))
+	}
+}
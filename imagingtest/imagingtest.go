@@ -0,0 +1,146 @@
+// Package imagingtest provides the helpers of the tests of the image processing code built
+// on the imaging package: the comparison of the images with a tolerance of the channel values
+// or of the perceived color difference, and the golden files.
+//
+// The golden files are updated by running the tests with the -imagingtest.update flag:
+//
+//	go test ./... -args -imagingtest.update
+package imagingtest
+
+import (
+	"flag"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/154pinkchairs/imaging"
+)
+
+var update = flag.Bool("imagingtest.update", false, "update the golden files of imagingtest.AssertGolden")
+
+// Diff summarizes the differences of two images of the same size found by Compare.
+type Diff struct {
+	// Count is the number of the pixels differing by more than the tolerance.
+	Count int
+
+	// Max is the largest difference and At is the pixel with it, relative to the top-left
+	// corner of the images.
+	Max float64
+	At  image.Point
+
+	// Got and Want are the colors of the pixel At.
+	Got, Want color.NRGBA
+}
+
+// Compare compares the pixels of the images of the same size aligned with their top-left
+// corners and returns the summary of the pixels whose difference exceeds the tolerance.
+// The difference is the largest difference of the channel values if distance is
+// imaging.DistanceRGB, or the color difference of imaging.ColorDifference otherwise.
+// It returns false if the sizes of the images differ.
+func Compare(got, want image.Image, tolerance float64, distance imaging.ColorDistance) (Diff, bool) {
+	g, w := imaging.Clone(got), imaging.Clone(want)
+	if g.Rect.Size() != w.Rect.Size() {
+		return Diff{}, false
+	}
+	var d Diff
+	for y := 0; y < g.Rect.Dy(); y++ {
+		for x := 0; x < g.Rect.Dx(); x++ {
+			cg, cw := g.NRGBAAt(x, y), w.NRGBAAt(x, y)
+			var diff float64
+			if distance == imaging.DistanceRGB {
+				for _, v := range [4][2]uint8{{cg.R, cw.R}, {cg.G, cw.G}, {cg.B, cw.B}, {cg.A, cw.A}} {
+					diff = math.Max(diff, math.Abs(float64(v[0])-float64(v[1])))
+				}
+			} else {
+				diff = imaging.ColorDifference(cg, cw, distance)
+			}
+			if diff <= tolerance {
+				continue
+			}
+			d.Count++
+			if diff > d.Max {
+				d.Max, d.At, d.Got, d.Want = diff, image.Pt(x, y), cg, cw
+			}
+		}
+	}
+	return d, true
+}
+
+// AssertEqual reports an error of the test if the images differ in size or any channel value
+// of their pixels differs by more than the tolerance, e.g. 1 to allow for the different
+// floating-point rounding of the architectures. It returns whether the images are equal.
+//
+// Example:
+//
+//	got := imaging.Resize(src, 100, 0, imaging.Lanczos)
+//	imagingtest.AssertEqual(t, got, want, 0)
+func AssertEqual(t testing.TB, got, want image.Image, tolerance int) bool {
+	t.Helper()
+	return assert(t, got, want, float64(tolerance), imaging.DistanceRGB, "channel difference")
+}
+
+// AssertSimilar reports an error of the test if the images differ in size or the CIEDE2000
+// color difference of any of their pixels exceeds maxDeltaE, see imaging.ColorDifference.
+// It tolerates the small changes of the colors that aren't visible, e.g. of another resampling
+// or JPEG encoder, with a maxDeltaE of about 1 to 2. It returns whether the images are similar.
+//
+// Example:
+//
+//	imagingtest.AssertSimilar(t, decoded, original, 2)
+func AssertSimilar(t testing.TB, got, want image.Image, maxDeltaE float64) bool {
+	t.Helper()
+	return assert(t, got, want, maxDeltaE, imaging.DeltaE2000, "color difference")
+}
+
+func assert(t testing.TB, got, want image.Image, tolerance float64, distance imaging.ColorDistance, kind string) bool {
+	t.Helper()
+	d, ok := Compare(got, want, tolerance, distance)
+	if !ok {
+		t.Errorf("got image of size %v, want %v", got.Bounds().Size(), want.Bounds().Size())
+		return false
+	}
+	if d.Count > 0 {
+		t.Errorf("%d pixels differ by more than the %s %g, the most by %g at %v: got %v, want %v",
+			d.Count, kind, tolerance, d.Max, d.At, d.Got, d.Want)
+		return false
+	}
+	return true
+}
+
+// AssertGolden compares the image with the golden file like AssertEqual. The file is
+// written instead if the tests run with the -imagingtest.update flag, in the format of its
+// extension, see imaging.Save. The golden files are usually kept in the testdata directory
+// of the package, in a lossless format such as PNG.
+//
+// Example:
+//
+//	got := thumbnails.Process(src)
+//	imagingtest.AssertGolden(t, got, "testdata/thumbnail.png", 1)
+func AssertGolden(t testing.TB, got image.Image, filename string, tolerance int) bool {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			t.Errorf("failed to update the golden file: %v", err)
+			return false
+		}
+		if err := imaging.Save(got, filename); err != nil {
+			t.Errorf("failed to update the golden file: %v", err)
+			return false
+		}
+		t.Logf("updated the golden file %s", filename)
+		return true
+	}
+	want, err := imaging.Open(filename)
+	if err != nil {
+		t.Errorf("failed to open the golden file, run the test with -imagingtest.update to create it: %v", err)
+		return false
+	}
+	if !AssertEqual(t, got, want, tolerance) {
+		t.Errorf("the image differs from the golden file %s, run the test with -imagingtest.update to update it", filename)
+		return false
+	}
+	return true
+}
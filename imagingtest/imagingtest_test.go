@@ -0,0 +1,107 @@
+package imagingtest
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/154pinkchairs/imaging"
+)
+
+// recorder records the errors reported by the assertions.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, format)
+}
+
+func (r *recorder) Logf(format string, args ...interface{}) {}
+
+func TestCompare(t *testing.T) {
+	want := imaging.New(3, 2, color.NRGBA{100, 100, 100, 255})
+	got := imaging.Clone(want)
+	got.SetNRGBA(1, 0, color.NRGBA{103, 100, 100, 255})
+	got.SetNRGBA(2, 1, color.NRGBA{100, 100, 100, 250})
+	// The origins of the bounds don't matter.
+	shifted := &image.NRGBA{Rect: image.Rect(5, 5, 8, 7), Stride: got.Stride, Pix: got.Pix}
+
+	testCases := []struct {
+		tolerance float64
+		distance  imaging.ColorDistance
+		want      Diff
+	}{
+		{0, imaging.DistanceRGB, Diff{Count: 2, Max: 5, At: image.Pt(2, 1), Got: color.NRGBA{100, 100, 100, 250}, Want: color.NRGBA{100, 100, 100, 255}}},
+		{3, imaging.DistanceRGB, Diff{Count: 1, Max: 5, At: image.Pt(2, 1), Got: color.NRGBA{100, 100, 100, 250}, Want: color.NRGBA{100, 100, 100, 255}}},
+		{5, imaging.DistanceRGB, Diff{}},
+		{2, imaging.DeltaE2000, Diff{}},
+	}
+	for _, tc := range testCases {
+		d, ok := Compare(shifted, want, tc.tolerance, tc.distance)
+		if !ok || d != tc.want {
+			t.Fatalf("Compare(%v, %v): got %+v, %v want %+v", tc.tolerance, tc.distance, d, ok, tc.want)
+		}
+	}
+	if _, ok := Compare(got, imaging.New(2, 3, color.Black), 0, imaging.DistanceRGB); ok {
+		t.Fatal("Compare accepted the images of different sizes")
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	want := imaging.New(4, 4, color.NRGBA{100, 150, 200, 255})
+	close := imaging.AdjustBrightness(want, 1)
+	far := imaging.New(4, 4, color.NRGBA{200, 150, 100, 255})
+	testCases := []struct {
+		name   string
+		assert func(t testing.TB) bool
+		ok     bool
+	}{
+		{"equal", func(t testing.TB) bool { return AssertEqual(t, want, imaging.Clone(want), 0) }, true},
+		{"equal with tolerance", func(t testing.TB) bool { return AssertEqual(t, close, want, 3) }, true},
+		{"not equal", func(t testing.TB) bool { return AssertEqual(t, close, want, 0) }, false},
+		{"different sizes", func(t testing.TB) bool { return AssertEqual(t, imaging.New(4, 3, color.Black), want, 255) }, false},
+		{"similar", func(t testing.TB) bool { return AssertSimilar(t, close, want, 2) }, true},
+		{"not similar", func(t testing.TB) bool { return AssertSimilar(t, far, want, 2) }, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &recorder{TB: t}
+			if ok := tc.assert(r); ok != tc.ok || (len(r.errors) == 0) != tc.ok {
+				t.Fatalf("got %v with errors %q want %v", ok, r.errors, tc.ok)
+			}
+		})
+	}
+}
+
+func TestAssertGolden(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "golden", "image.png")
+	img := imaging.New(4, 4, color.NRGBA{100, 150, 200, 255})
+
+	r := &recorder{TB: t}
+	if AssertGolden(r, img, filename, 0) || len(r.errors) != 1 || !strings.Contains(r.errors[0], "-imagingtest.update") {
+		t.Fatalf("got errors %q for the missing golden file", r.errors)
+	}
+
+	*update = true
+	r = &recorder{TB: t}
+	ok := AssertGolden(r, img, filename, 0)
+	*update = false
+	if !ok || len(r.errors) != 0 {
+		t.Fatalf("got errors %q updating the golden file", r.errors)
+	}
+
+	r = &recorder{TB: t}
+	if !AssertGolden(r, img, filename, 0) || len(r.errors) != 0 {
+		t.Fatalf("got errors %q for the matching image", r.errors)
+	}
+	r = &recorder{TB: t}
+	if AssertGolden(r, imaging.Invert(img), filename, 0) || len(r.errors) != 2 {
+		t.Fatalf("got errors %q for the different image", r.errors)
+	}
+}
@@ -0,0 +1,231 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+
+	"golang.org/x/image/tiff"
+)
+
+// ImageInfo describes an encoded image.
+type ImageInfo struct {
+	// Width and Height are the size of the image Decode returns with the same options.
+	Width, Height int
+	// Format is the format of the encoded image. Camera RAW files are reported as TIFF.
+	Format Format
+	// Orientation is the EXIF orientation tag (1 to 8) of JPEG and camera RAW images
+	// or 0 if it's missing.
+	Orientation int
+	// ColorModel is the color model of the image Decode returns.
+	ColorModel color.Model
+	// ISO is the EXIF ISO speed of JPEG images or 0 if it's missing. The high ISO speeds
+	// of the photos taken in low light mean a strong noise, see AutoEnhance.
+	ISO int
+}
+
+// Inspect reads the size, the format, the EXIF orientation and the color model of the image
+// in r from its header without decoding the pixels. It supports the formats Decode does and
+// takes the same options. If AutoOrientation is enabled, the width, the height and the color
+// model are those of the transformed image Decode returns.
+//
+// Images of the formats added by RegisterFormat are decoded, they have no header decoders.
+// JPEG XL images are reported as color.NRGBAModel.
+func Inspect(r io.Reader, opts ...DecodeOption) (ImageInfo, error) {
+	cfg := defaultDecodeConfig
+	for _, option := range opts {
+		option(&cfg)
+	}
+	info, err := inspect(bufio.NewReader(r), cfg)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+	if cfg.autoOrientation && info.Orientation > orientationNormal {
+		// The transformed images are *image.NRGBA.
+		info.ColorModel = color.NRGBAModel
+		if info.Orientation >= orientationTranspose {
+			info.Width, info.Height = info.Height, info.Width
+		}
+	}
+	return info, nil
+}
+
+// InspectFile reads the header of the image file, see Inspect.
+func InspectFile(filename string, opts ...DecodeOption) (ImageInfo, error) {
+	file, err := fs.Open(filename)
+	if err != nil {
+		return ImageInfo{}, err
+	}
+	defer file.Close()
+	return Inspect(file, opts...)
+}
+
+// inspect reads the image header in br. The formats are detected in the same order as by
+// DecodeWithFormat.
+func inspect(br *bufio.Reader, cfg decodeConfig) (ImageInfo, error) {
+	if rf, ok := sniffRegisteredFormat(br); ok {
+		img, err := rf.decode(br)
+		if err != nil {
+			return ImageInfo{}, err
+		}
+		b := img.Bounds()
+		return ImageInfo{Width: b.Dx(), Height: b.Dy(), Format: rf.format, ColorModel: img.ColorModel()}, nil
+	}
+	if magic, err := br.Peek(2); err == nil && string(magic) == "BM" {
+		h, err := readBMPHeader(br)
+		if err != nil {
+			return ImageInfo{}, err
+		}
+		return ImageInfo{Width: h.width, Height: h.height, Format: BMP, ColorModel: h.colorModel()}, nil
+	}
+	if magic, err := br.Peek(4); err == nil && string(magic) == "\x00\x00\x01\x00" {
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return ImageInfo{}, err
+		}
+		c, err := decodeICOConfig(data)
+		return newImageInfo(c, ICO, orientationUnspecified), err
+	}
+	if cfg.svg && isSVG(br) {
+		c, err := decodeSVGConfig(br, cfg)
+		return newImageInfo(c, SVG, orientationUnspecified), err
+	}
+	if isPNM(br) {
+		h, err := readPNMHeader(br)
+		if err != nil {
+			return ImageInfo{}, err
+		}
+		return ImageInfo{Width: h.width, Height: h.height, Format: pnmFormat('0' + h.kind), ColorModel: h.colorModel()}, nil
+	}
+	if format, _, ok := externalFormat(br); ok {
+		c, err := decodeExternalConfig(br, format)
+		return newImageInfo(c, format, orientationUnspecified), err
+	}
+	if magic, err := br.Peek(4); err == nil && (string(magic) == "II*\x00" || string(magic) == "MM\x00*") {
+		// RAW files are TIFF files, all the data is needed to tell them apart.
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return ImageInfo{}, err
+		}
+		if isRAW(data) {
+			c, orient, err := decodeRAWConfig(data, cfg.rawFullDecode)
+			return newImageInfo(c, TIFF, orient), err
+		}
+		c, err := tiff.DecodeConfig(bytes.NewReader(data))
+		return newImageInfo(c, TIFF, orientationUnspecified), err
+	}
+
+	// Keep the data DecodeConfig reads, the EXIF data of JPEG images precedes the frame header.
+	var buf bytes.Buffer
+	c, name, err := image.DecodeConfig(io.TeeReader(br, &buf))
+	if err != nil {
+		return ImageInfo{}, err
+	}
+	orient := orientation(orientationUnspecified)
+	iso := 0
+	if name == "jpeg" {
+		iso = readISO(buf.Bytes())
+		orient = readOrientation(&buf)
+	}
+	info := newImageInfo(c, imageFormat(name), orient)
+	info.ISO = iso
+	return info, nil
+}
+
+// newImageInfo returns the info of an image with the given config.
+func newImageInfo(c image.Config, format Format, orient orientation) ImageInfo {
+	return ImageInfo{
+		Width:       c.Width,
+		Height:      c.Height,
+		Format:      format,
+		Orientation: int(orient),
+		ColorModel:  c.ColorModel,
+	}
+}
+
+// readISO returns the ISO speed of the EXIF data of the JPEG image data, the first value of
+// the ISOSpeedRatings tag or the ISOSpeed tag of the Exif IFD. It returns 0 if the tags or
+// the EXIF data are missing or invalid.
+func readISO(data []byte) int {
+	const (
+		markerAPP1       = 0xe1
+		exifIFDTag       = 0x8769
+		isoSpeedRatings  = 0x8827
+		isoSpeed         = 0x8833
+		typeShort        = 3
+		typeLong         = 4
+		ifdEntrySize     = 12
+		tiffHeaderLength = 8
+	)
+
+	// Find the APP1 segment with the EXIF data, it precedes the frame.
+	if len(data) < 2 || data[0] != 0xff || data[1] != 0xd8 {
+		return 0
+	}
+	var tiffData []byte
+	for i := 2; i+4 <= len(data) && data[i] == 0xff; {
+		marker := data[i+1]
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		if size < 2 || i+2+size > len(data) {
+			return 0
+		}
+		segment := data[i+4 : i+2+size]
+		if marker == markerAPP1 && len(segment) >= 6 && string(segment[:6]) == "Exif\x00\x00" {
+			tiffData = segment[6:]
+			break
+		}
+		if marker >= 0xc0 && marker <= 0xcf && marker != 0xc4 && marker != 0xc8 && marker != 0xcc {
+			return 0 // The frame header.
+		}
+		i += 2 + size
+	}
+	if len(tiffData) < tiffHeaderLength {
+		return 0
+	}
+	var byteOrder binary.ByteOrder
+	switch string(tiffData[:2]) {
+	case "MM":
+		byteOrder = binary.BigEndian
+	case "II":
+		byteOrder = binary.LittleEndian
+	default:
+		return 0
+	}
+
+	// find returns the type and the value of the tag in the IFD at the offset.
+	find := func(offset uint32, tag uint16) (typ uint16, value []byte, ok bool) {
+		if uint64(offset)+2 > uint64(len(tiffData)) {
+			return 0, nil, false
+		}
+		n := int(byteOrder.Uint16(tiffData[offset:]))
+		entries := tiffData[offset+2:]
+		for k := 0; k < n && (k+1)*ifdEntrySize <= len(entries); k++ {
+			e := entries[k*ifdEntrySize : (k+1)*ifdEntrySize]
+			if byteOrder.Uint16(e) == tag {
+				return byteOrder.Uint16(e[2:]), e[8:12], true
+			}
+		}
+		return 0, nil, false
+	}
+
+	typ, value, ok := find(byteOrder.Uint32(tiffData[4:]), exifIFDTag)
+	if !ok || typ != typeLong {
+		return 0
+	}
+	exifIFD := byteOrder.Uint32(value)
+	for _, tag := range []uint16{isoSpeedRatings, isoSpeed} {
+		// The first value of the tag is stored in the entry.
+		typ, value, ok := find(exifIFD, tag)
+		switch {
+		case !ok:
+		case typ == typeShort && byteOrder.Uint16(value) > 0:
+			return int(byteOrder.Uint16(value))
+		case typ == typeLong && byteOrder.Uint32(value) > 0 && byteOrder.Uint32(value) <= 1<<24:
+			return int(byteOrder.Uint32(value))
+		}
+	}
+	return 0
+}
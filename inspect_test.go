@@ -0,0 +1,361 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sameColorModel reports whether the color models are equal, the palettes are compared by value.
+func sameColorModel(a, b color.Model) bool {
+	if pa, ok := a.(color.Palette); ok {
+		pb, ok := b.(color.Palette)
+		return ok && reflect.DeepEqual(pa, pb)
+	}
+	return a == b
+}
+
+// checkInspect checks that Inspect reports the size, the format and the color model
+// of the image decoded from the same data with the same options.
+func checkInspect(t *testing.T, data []byte, opts ...DecodeOption) ImageInfo {
+	t.Helper()
+	info, err := Inspect(bytes.NewReader(data), opts...)
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	img, format, err := DecodeWithFormat(bytes.NewReader(data), opts...)
+	if err != nil {
+		t.Fatalf("DecodeWithFormat: %v", err)
+	}
+	b := img.Bounds()
+	if info.Width != b.Dx() || info.Height != b.Dy() {
+		t.Fatalf("got size %dx%d want %dx%d", info.Width, info.Height, b.Dx(), b.Dy())
+	}
+	if info.Format != format {
+		t.Fatalf("got format %v want %v", info.Format, format)
+	}
+	if !sameColorModel(info.ColorModel, img.ColorModel()) {
+		t.Fatalf("got color model %#v want %#v", info.ColorModel, img.ColorModel())
+	}
+	return info
+}
+
+func TestInspect(t *testing.T) {
+	gray := Grayscale(testdataFlowersSmallPNG)
+	testCases := []struct {
+		name   string
+		img    image.Image
+		format Format
+		opts   []EncodeOption
+	}{
+		{"jpeg", testdataFlowersSmallPNG, JPEG, nil},
+		{"png", testdataFlowersSmallPNG, PNG, nil},
+		{"png gray", image.NewGray16(image.Rect(0, 0, 3, 5)), PNG, nil},
+		{"gif", testdataFlowersSmallPNG, GIF, nil},
+		{"tiff", testdataFlowersSmallPNG, TIFF, nil},
+		{"bmp", testdataFlowersSmallPNG, BMP, nil},
+		{"bmp paletted", image.NewPaletted(image.Rect(0, 0, 7, 3), pnmBitmapPalette), BMP, nil},
+		{"ico", testdataFlowersSmallPNG, ICO, nil},
+		{"pbm", gray, PBM, nil},
+		{"pgm", gray, PGM, nil},
+		{"ppm", testdataFlowersSmallPNG, PPM, []EncodeOption{PNMPlain(true)}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, tc.img, tc.format, tc.opts...); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			if info := checkInspect(t, buf.Bytes()); info.Orientation != 0 {
+				t.Fatalf("got orientation %d want 0", info.Orientation)
+			}
+		})
+	}
+}
+
+func TestInspectICOBitmap(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeICO(&buf, []image.Image{New(16, 16, color.White), New(48, 32, color.Black)}); err != nil {
+		t.Fatalf("EncodeICO: %v", err)
+	}
+	data := buf.Bytes()
+	// Replace the PNG of the largest icon with a 2x1 bitmap.
+	le := binary.LittleEndian
+	bitmap := make([]byte, 40+8+4)
+	le.PutUint32(bitmap, 40)
+	le.PutUint32(bitmap[4:], 2)
+	le.PutUint32(bitmap[8:], 2)
+	le.PutUint16(bitmap[12:], 1)
+	le.PutUint16(bitmap[14:], 32)
+	e := data[icoHeaderLen+icoEntryLen:]
+	le.PutUint32(e[8:], uint32(len(bitmap)))
+	le.PutUint32(e[12:], uint32(len(data)))
+	data = append(data, bitmap...)
+
+	info, err := Inspect(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	want := ImageInfo{Width: 2, Height: 1, Format: ICO, ColorModel: color.NRGBAModel}
+	if info != want {
+		t.Fatalf("got %#v want %#v", info, want)
+	}
+}
+
+func TestInspectSVG(t *testing.T) {
+	data := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="40" viewBox="0 0 20 10"><rect width="5" height="5"/></svg>`)
+	if info := checkInspect(t, data, SVGDecoding(true)); info.Width != 40 || info.Height != 20 {
+		t.Fatalf("got size %dx%d want 40x20", info.Width, info.Height)
+	}
+	if info := checkInspect(t, data, SVGDecoding(true), SVGDPI(192)); info.Width != 80 || info.Height != 40 {
+		t.Fatalf("got size %dx%d want 80x40", info.Width, info.Height)
+	}
+	if info := checkInspect(t, data, SVGDecoding(true), SVGSize(10, 0)); info.Width != 10 || info.Height != 5 {
+		t.Fatalf("got size %dx%d want 10x5", info.Width, info.Height)
+	}
+
+	// The SVG images are unknown like to Decode unless SVG decoding is enabled, the limits
+	// don't parse them either.
+	for _, opts := range [][]DecodeOption{nil, {MaxPixels(1)}} {
+		_, decodeErr := Decode(bytes.NewReader(data), opts...)
+		if _, err := Inspect(bytes.NewReader(data), opts...); err != image.ErrFormat || decodeErr != err {
+			t.Fatalf("got error %v want %v like Decode", err, decodeErr)
+		}
+	}
+}
+
+func TestInspectOrientation(t *testing.T) {
+	for i := 0; i <= 8; i++ {
+		filename := "testdata/orientation_" + string(rune('0'+i)) + ".jpg"
+		info, err := InspectFile(filename)
+		if err != nil {
+			t.Fatalf("InspectFile(%q): %v", filename, err)
+		}
+		if info.Orientation != i || info.Format != JPEG {
+			t.Fatalf("InspectFile(%q): got orientation %d, format %v", filename, info.Orientation, info.Format)
+		}
+
+		img, err := Open(filename, AutoOrientation(true))
+		if err != nil {
+			t.Fatalf("Open(%q): %v", filename, err)
+		}
+		info, err = InspectFile(filename, AutoOrientation(true))
+		if err != nil {
+			t.Fatalf("InspectFile(%q): %v", filename, err)
+		}
+		if b := img.Bounds(); info.Width != b.Dx() || info.Height != b.Dy() {
+			t.Fatalf("InspectFile(%q): got size %dx%d want %dx%d", filename, info.Width, info.Height, b.Dx(), b.Dy())
+		}
+		if !sameColorModel(info.ColorModel, img.ColorModel()) {
+			t.Fatalf("InspectFile(%q): got color model %#v want %#v", filename, info.ColorModel, img.ColorModel())
+		}
+	}
+}
+
+func TestInspectRAW(t *testing.T) {
+	data := rawTestDNG(t, 1, 16)
+	if info := checkInspect(t, data); info.Orientation != orientationRotate90 {
+		t.Fatalf("got orientation %d want %d", info.Orientation, orientationRotate90)
+	}
+	checkInspect(t, data, AutoOrientation(true))
+	checkInspect(t, data, RAWFullDecode(true))
+
+	data = rawTestDNG(t, 1, 16,
+		rawLongs(dngDefaultCropOrigin, 1, 1),
+		rawLongs(dngDefaultCropSize, 4, 3),
+	)
+	if info := checkInspect(t, data, RAWFullDecode(true)); info.Width != 4 || info.Height != 3 {
+		t.Fatalf("got size %dx%d want 4x3", info.Width, info.Height)
+	}
+}
+
+func TestInspectJP2(t *testing.T) {
+	be := binary.BigEndian
+	siz := make([]byte, 4+39)
+	copy(siz, j2kSignature)
+	be.PutUint16(siz[4:], 41)
+	be.PutUint32(siz[8:], 110) // Xsiz
+	be.PutUint32(siz[12:], 60) // Ysiz
+	be.PutUint32(siz[16:], 10) // XOsiz
+	be.PutUint32(siz[20:], 0)  // YOsiz
+	be.PutUint16(siz[40:], 3)  // Csiz
+	siz[42] = 7                // Ssiz, 8 bits.
+
+	box := func(typ string, content []byte) []byte {
+		b := make([]byte, 8, 8+len(content))
+		be.PutUint32(b, uint32(8+len(content)))
+		copy(b[4:], typ)
+		return append(b, content...)
+	}
+	ihdr := make([]byte, 14)
+	be.PutUint32(ihdr[0:], 30)
+	be.PutUint32(ihdr[4:], 20)
+	be.PutUint16(ihdr[8:], 1)
+	ihdr[10] = 15
+	jp2 := []byte(jp2Signature)
+	jp2 = append(jp2, box("ftyp", []byte("jp2 \x00\x00\x00\x00jp2 "))...)
+	jp2 = append(jp2, box("jp2h", box("ihdr", ihdr))...)
+	jp2 = append(jp2, box("jp2c", siz)...)
+
+	testCases := []struct {
+		name string
+		data []byte
+		want ImageInfo
+	}{
+		{"j2k", siz, ImageInfo{Width: 100, Height: 60, Format: JP2, ColorModel: color.RGBAModel}},
+		{"jp2", jp2, ImageInfo{Width: 20, Height: 30, Format: JP2, ColorModel: color.Gray16Model}},
+		{"jp2 without header", append([]byte(jp2Signature), box("jp2c", siz)...), ImageInfo{Width: 100, Height: 60, Format: JP2, ColorModel: color.RGBAModel}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Inspect(bytes.NewReader(tc.data))
+			if err != nil {
+				t.Fatalf("Inspect: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("got %#v want %#v", got, tc.want)
+			}
+		})
+	}
+	if _, err := Inspect(bytes.NewReader(jp2[:40])); err != errInvalidJP2 {
+		t.Fatalf("got error %v want %v", err, errInvalidJP2)
+	}
+}
+
+func TestInspectJXL(t *testing.T) {
+	testCases := []struct {
+		name          string
+		data          string
+		width, height int
+	}{
+		// Small, height (1+1)*8, ratio 1 (1:1).
+		{"small", jxlCodestream + "\x43\x00", 16, 16},
+		// Small, height 8, ratio 0, width (2+1)*8.
+		{"small width", jxlCodestream + "\x01\x04", 24, 8},
+		// Height 99+1 with 9 bits, ratio 5 (16:9).
+		{"ratio", jxlCodestream + "\x18\x53", 177, 100},
+		{"container", jxlSignature + "\x00\x00\x00\x0cftypjxl \x00\x00\x00\x10jxlp\x00\x00\x00\x00" + jxlCodestream + "\x43\x00", 16, 16},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Inspect(strings.NewReader(tc.data))
+			if err != nil {
+				t.Fatalf("Inspect: %v", err)
+			}
+			want := ImageInfo{Width: tc.width, Height: tc.height, Format: JXL, ColorModel: color.NRGBAModel}
+			if got != want {
+				t.Fatalf("got %#v want %#v", got, want)
+			}
+		})
+	}
+	if _, err := Inspect(strings.NewReader(jxlCodestream)); err != errInvalidJXL {
+		t.Fatalf("got error %v want %v", err, errInvalidJXL)
+	}
+}
+
+func TestInspectRegisteredFormat(t *testing.T) {
+	gry := registerTestFormat(t, "gry", "GRY1")
+	info, err := Inspect(strings.NewReader("GRY1\x02\x01\x10\x20"))
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	want := ImageInfo{Width: 2, Height: 1, Format: gry, ColorModel: color.GrayModel}
+	if info != want {
+		t.Fatalf("got %#v want %#v", info, want)
+	}
+}
+
+func TestInspectFails(t *testing.T) {
+	if _, err := Inspect(strings.NewReader("not an image")); err != image.ErrFormat {
+		t.Fatalf("got error %v want %v", err, image.ErrFormat)
+	}
+	if _, err := Inspect(strings.NewReader("P5 0 1 255\n")); err != errInvalidPNM {
+		t.Fatalf("got error %v want %v", err, errInvalidPNM)
+	}
+	if _, err := InspectFile("testdata/missing.png"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+// jpegWithISO returns a JPEG image with the EXIF ISO speed stored in the tag of the type.
+func jpegWithISO(t *testing.T, order binary.AppendByteOrder, tag, typ uint16, iso uint32) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Encode(&buf, New(4, 2, color.White), JPEG); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	tiff := []byte("MM\x00*")
+	if order == binary.LittleEndian {
+		tiff = []byte("II*\x00")
+	}
+	tiff = order.AppendUint32(tiff, 8)
+	// IFD0 with the pointer to the Exif IFD.
+	tiff = order.AppendUint16(tiff, 1)
+	tiff = order.AppendUint16(tiff, 0x8769)
+	tiff = order.AppendUint16(tiff, 4)
+	tiff = order.AppendUint32(tiff, 1)
+	tiff = order.AppendUint32(tiff, 26)
+	tiff = order.AppendUint32(tiff, 0)
+	// Exif IFD with the ISO speed.
+	tiff = order.AppendUint16(tiff, 1)
+	tiff = order.AppendUint16(tiff, tag)
+	tiff = order.AppendUint16(tiff, typ)
+	tiff = order.AppendUint32(tiff, 1)
+	if typ == 3 {
+		tiff = order.AppendUint16(tiff, uint16(iso))
+		tiff = order.AppendUint16(tiff, 0)
+	} else {
+		tiff = order.AppendUint32(tiff, iso)
+	}
+	tiff = order.AppendUint32(tiff, 0)
+
+	app1 := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(2+6+len(tiff)))
+	app1 = append(append(app1, "Exif\x00\x00"...), tiff...)
+	data := buf.Bytes()
+	return append(append(append([]byte{}, data[:2]...), app1...), data[2:]...)
+}
+
+func TestInspectISO(t *testing.T) {
+	testCases := []struct {
+		name  string
+		order binary.AppendByteOrder
+		tag   uint16
+		typ   uint16
+		iso   uint32
+		want  int
+	}{
+		{"ISOSpeedRatings big endian", binary.BigEndian, 0x8827, 3, 1600, 1600},
+		{"ISOSpeedRatings little endian", binary.LittleEndian, 0x8827, 3, 200, 200},
+		{"ISOSpeed", binary.LittleEndian, 0x8833, 4, 51200, 51200},
+		{"other tag", binary.BigEndian, 0x829a, 3, 1600, 0},
+		{"zero", binary.BigEndian, 0x8827, 3, 0, 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			info := checkInspect(t, jpegWithISO(t, tc.order, tc.tag, tc.typ, tc.iso))
+			if info.ISO != tc.want || info.Width != 4 {
+				t.Fatalf("got %+v want ISO %d", info, tc.want)
+			}
+		})
+	}
+
+	// The other images and the JPEG images without the EXIF data have no ISO speed.
+	for _, filename := range []string{"testdata/orientation_1.jpg", "testdata/flowers_small.png"} {
+		info, err := InspectFile(filename)
+		if err != nil || info.ISO != 0 {
+			t.Fatalf("InspectFile(%q): got %+v, %v", filename, info, err)
+		}
+	}
+	data := jpegWithISO(t, binary.BigEndian, 0x8827, 3, 800)
+	for _, n := range []int{4, 10, 30, 40} {
+		if iso := readISO(data[:n]); iso != 0 {
+			t.Fatalf("got ISO %d from %d bytes", iso, n)
+		}
+	}
+}
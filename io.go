@@ -1,23 +1,34 @@
 package imaging
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"hash"
 	"image"
+	"image/color"
+	"image/color/palette"
 	"image/draw"
 	"image/gif"
 	"image/jpeg"
 	"image/png"
 	"io"
+	"mime"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"golang.org/x/image/bmp"
 	"golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
 )
 
-type fileSystem interface {
+// FileSystem opens and creates the files of an Engine, e.g. to read the images
+// from an object store or an in-memory file system.
+type FileSystem interface {
 	Create(string) (io.WriteCloser, error)
 	Open(string) (io.ReadCloser, error)
 }
@@ -27,14 +38,31 @@ type localFS struct{}
 func (localFS) Create(name string) (io.WriteCloser, error) { return os.Create(name) }
 func (localFS) Open(name string) (io.ReadCloser, error)    { return os.Open(name) }
 
-var fs fileSystem = localFS{}
+// fs is the file system of the package functions and the engines without one.
+var fs FileSystem = localFS{}
 
 type decodeConfig struct {
 	autoOrientation bool
+	svg             bool
+	svgDPI          float64
+	svgWidth        int
+	svgHeight       int
+	rawFullDecode   bool
+	maxPixels       int
+	maxWidth        int
+	maxHeight       int
 }
 
 var defaultDecodeConfig = decodeConfig{
 	autoOrientation: false,
+	svg:             false,
+	svgDPI:          96,
+	svgWidth:        0,
+	svgHeight:       0,
+	rawFullDecode:   false,
+	maxPixels:       0,
+	maxWidth:        0,
+	maxHeight:       0,
 }
 
 // DecodeOption sets an optional parameter for the Decode and Open functions.
@@ -49,16 +77,236 @@ func AutoOrientation(enabled bool) DecodeOption {
 	}
 }
 
-// Decode reads an image from r.
+// SVGDecoding returns a DecodeOption that enables rasterizing SVG images. By default SVG
+// images are rejected like the other unknown formats: rendering a document takes time and
+// memory growing with its contents rather than with its size, so it should be enabled for
+// the trusted data only, or together with MaxPixels and a timeout of the caller.
+func SVGDecoding(enabled bool) DecodeOption {
+	return func(c *decodeConfig) {
+		c.svg = enabled
+	}
+}
+
+// SVGDPI returns a DecodeOption that sets the resolution SVG images are rasterized at.
+// SVG lengths are defined at 96 DPI, so the default of 96 rasterizes an image
+// with width="100" and height="50" to 100x50 pixels, and 192 to 200x100 pixels.
+func SVGDPI(dpi float64) DecodeOption {
+	return func(c *decodeConfig) {
+		c.svgDPI = dpi
+	}
+}
+
+// SVGSize returns a DecodeOption that sets the size SVG images are rasterized to.
+// If one of width and height is 0, it's computed to preserve the image aspect ratio.
+// It overrides SVGDPI.
+func SVGSize(width, height int) DecodeOption {
+	return func(c *decodeConfig) {
+		c.svgWidth = width
+		c.svgHeight = height
+	}
+}
+
+// RAWFullDecode returns a DecodeOption that sets how camera RAW files (DNG, CR2, NEF and other
+// TIFF-based formats) are decoded. By default the embedded JPEG preview is returned, which is
+// fast and good enough for thumbnails. If full decoding is enabled, the sensor data is
+// demosaiced and converted to sRGB instead. Full decoding is supported for DNG files only.
+func RAWFullDecode(enabled bool) DecodeOption {
+	return func(c *decodeConfig) {
+		c.rawFullDecode = enabled
+	}
+}
+
+// MaxPixels returns a DecodeOption that limits the number of pixels of the decoded images.
+// The size is read from the image header before decoding and larger images are rejected
+// with an *ImageTooLargeError, which guards against decompression bombs in untrusted data.
+// The default of 0 means no limit.
+func MaxPixels(n int) DecodeOption {
+	return func(c *decodeConfig) {
+		c.maxPixels = n
+	}
+}
+
+// MaxDimensions returns a DecodeOption that limits the width and the height of the decoded
+// images like MaxPixels limits their area. The limits apply to the size stored in the image
+// header, before the EXIF orientation is applied. A limit of 0 means no limit.
+func MaxDimensions(width, height int) DecodeOption {
+	return func(c *decodeConfig) {
+		c.maxWidth = width
+		c.maxHeight = height
+	}
+}
+
+// ImageTooLargeError is returned by Decode and Open when the image size exceeds the limits
+// set by MaxPixels or MaxDimensions.
+type ImageTooLargeError struct {
+	Width, Height int
+}
+
+func (e *ImageTooLargeError) Error() string {
+	return fmt.Sprintf("imaging: image of %dx%d pixels exceeds the decode limits", e.Width, e.Height)
+}
+
+// checkSize returns an *ImageTooLargeError if the size exceeds the decode limits.
+func (c *decodeConfig) checkSize(width, height int) error {
+	tooLarge := (c.maxWidth > 0 && width > c.maxWidth) ||
+		(c.maxHeight > 0 && height > c.maxHeight) ||
+		// Dividing avoids the overflow of width*height.
+		(c.maxPixels > 0 && width > 0 && height > c.maxPixels/width)
+	if tooLarge {
+		return &ImageTooLargeError{Width: width, Height: height}
+	}
+	return nil
+}
+
+// checkDecodeLimits reads the header of the image in br and checks its size against the
+// decode limits. It returns a reader of the whole image including the header.
+func checkDecodeLimits(br *bufio.Reader, cfg decodeConfig) (*bufio.Reader, error) {
+	if cfg.maxPixels <= 0 && cfg.maxWidth <= 0 && cfg.maxHeight <= 0 {
+		return br, nil
+	}
+	if _, ok := sniffRegisteredFormat(br); ok {
+		// The registered formats have no header decoders, they are checked after decoding.
+		return br, nil
+	}
+	var header bytes.Buffer
+	info, err := inspect(bufio.NewReader(io.TeeReader(br, &header)), cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.checkSize(info.Width, info.Height); err != nil {
+		return nil, err
+	}
+	return bufio.NewReader(io.MultiReader(&header, br)), nil
+}
+
+// Decode reads an image from r. BMP images with 1, 4, 8, 16, 24 and 32 bits per pixel
+// are supported, including the alpha channel of 32-bit images. The largest image
+// of an ICO file is returned. Still WebP images are supported, the animated ones are not.
+// SVG images are rasterized if enabled, see SVGDecoding. Camera RAW files are decoded as
+// their embedded preview, see RAWFullDecode. JPEG 2000 and JPEG XL images are decoded by
+// external programs in the programs built with the imaging_exec build tag, see
+// ErrCodecUnsupported. Images of the formats added by RegisterFormat are recognized by
+// their magic prefix. Use MaxPixels and MaxDimensions to decode untrusted data.
 func Decode(r io.Reader, opts ...DecodeOption) (image.Image, error) {
+	img, _, err := DecodeWithFormat(r, opts...)
+	return img, err
+}
+
+// DecodeWithFormat reads an image from r like Decode and returns its format as well,
+// e.g. to encode the processed image in the same format. Camera RAW files are reported
+// as TIFF. Images decoded by the decoders registered with the standard image package
+// that are not supported by this package are reported as format -1.
+//
+// Example:
+//
+//	img, format, err := imaging.DecodeWithFormat(r)
+//	if err != nil {
+//		return err
+//	}
+//	err = imaging.Encode(w, imaging.Fit(img, 800, 600, imaging.Lanczos), format)
+func DecodeWithFormat(r io.Reader, opts ...DecodeOption) (image.Image, Format, error) {
+	cfg := defaultDecodeConfig
+	for _, option := range opts {
+		option(&cfg)
+	}
+	return decodeWithFormat(r, nil, cfg)
+}
+
+// DecodeBytes reads an image from the byte slice like Decode. The decoders of the formats
+// that need all the data at once (TIFF, camera RAW, ICO, JPEG 2000 and JPEG XL) use the slice
+// without copying it and the standard image decoders read it without extra buffering.
+// The slice must not be modified until DecodeBytes returns.
+func DecodeBytes(b []byte, opts ...DecodeOption) (image.Image, error) {
 	cfg := defaultDecodeConfig
 	for _, option := range opts {
 		option(&cfg)
 	}
+	img, _, err := decodeWithFormat(bytes.NewReader(b), b, cfg)
+	return img, err
+}
+
+// readAll returns all the data of the image, which is the data of br unless it's known.
+func readAll(br *bufio.Reader, all []byte) ([]byte, error) {
+	if all != nil {
+		return all, nil
+	}
+	return io.ReadAll(br)
+}
+
+// decodeWithFormat decodes the image in r. All is the data of r if it's known or nil.
+func decodeWithFormat(r io.Reader, all []byte, cfg decodeConfig) (image.Image, Format, error) {
+	br, err := checkDecodeLimits(bufio.NewReader(r), cfg)
+	if err != nil {
+		return nil, -1, err
+	}
+	if rf, ok := sniffRegisteredFormat(br); ok {
+		img, err := rf.decode(br)
+		if err != nil {
+			return nil, rf.format, err
+		}
+		if err := cfg.checkSize(img.Bounds().Dx(), img.Bounds().Dy()); err != nil {
+			return nil, rf.format, err
+		}
+		return img, rf.format, nil
+	}
+	// The golang.org/x/image/bmp decoder supports 8, 24 and 32-bit images only.
+	if magic, err := br.Peek(2); err == nil && string(magic) == "BM" {
+		img, err := decodeBMP(br)
+		return img, BMP, err
+	}
+	if magic, err := br.Peek(4); err == nil && string(magic) == "\x00\x00\x01\x00" {
+		data, err := readAll(br, all)
+		if err != nil {
+			return nil, ICO, err
+		}
+		img, err := decodeICO(data)
+		return img, ICO, err
+	}
+	if cfg.svg && isSVG(br) {
+		img, err := decodeSVG(br, cfg)
+		return img, SVG, err
+	}
+	if isPNM(br) {
+		magic, _ := br.Peek(2)
+		format := pnmFormat(magic[1])
+		img, err := decodePNM(br)
+		return img, format, err
+	}
+	if format, ext, ok := externalFormat(br); ok {
+		data, err := readAll(br, all)
+		if err != nil {
+			return nil, format, err
+		}
+		img, err := decodeExternal(data, format, ext)
+		return img, format, err
+	}
+	r = br
+	if all != nil {
+		// Nothing was read from br yet and bytes.Reader needs no buffering.
+		r = bytes.NewReader(all)
+	}
+	if magic, err := br.Peek(4); err == nil && (string(magic) == "II*\x00" || string(magic) == "MM\x00*") {
+		// RAW files are TIFF files, all the data is needed to tell them apart.
+		data, err := readAll(br, all)
+		if err != nil {
+			return nil, TIFF, err
+		}
+		if isRAW(data) {
+			img, orient, err := decodeRAW(data, cfg.rawFullDecode)
+			if err != nil {
+				return nil, TIFF, err
+			}
+			if cfg.autoOrientation {
+				img = fixOrientation(img, orient)
+			}
+			return img, TIFF, nil
+		}
+		r = bytes.NewReader(data)
+	}
 
 	if !cfg.autoOrientation {
-		img, _, err := image.Decode(r)
-		return img, err
+		img, name, err := image.Decode(r)
+		return img, imageFormat(name), err
 	}
 
 	var orient orientation
@@ -74,14 +322,22 @@ func Decode(r io.Reader, opts ...DecodeOption) (image.Image, error) {
 		}
 	}()
 
-	img, _, err := image.Decode(r)
+	img, name, err := image.Decode(r)
 	pw.Close()
 	<-done
 	if err != nil {
-		return nil, err
+		return nil, imageFormat(name), err
 	}
 
-	return fixOrientation(img, orient), nil
+	return fixOrientation(img, orient), imageFormat(name), nil
+}
+
+// imageFormat returns the format of the image package format name, or -1 if it's unknown.
+func imageFormat(name string) Format {
+	if f, err := FormatFromExtension(name); err == nil {
+		return f
+	}
+	return -1
 }
 
 // Open loads an image from file.
@@ -102,6 +358,21 @@ func Open(filename string, opts ...DecodeOption) (image.Image, error) {
 	return Decode(file, opts...)
 }
 
+// OpenWithFormat loads an image from file and returns its format, see DecodeWithFormat.
+// The format is detected from the file contents, not from the filename extension.
+//
+// Example:
+//
+//	img, format, err := imaging.OpenWithFormat("upload")
+func OpenWithFormat(filename string, opts ...DecodeOption) (image.Image, Format, error) {
+	file, err := fs.Open(filename)
+	if err != nil {
+		return nil, -1, err
+	}
+	defer file.Close()
+	return DecodeWithFormat(file, opts...)
+}
+
 // Format is an image file format.
 type Format int
 
@@ -112,6 +383,16 @@ const (
 	GIF
 	TIFF
 	BMP
+	SVG
+	ICO
+	JP2
+	JXL
+	PBM
+	PGM
+	PPM
+	DDS
+	KTX2
+	WEBP
 )
 
 var formatExts = map[string]Format{
@@ -122,6 +403,18 @@ var formatExts = map[string]Format{
 	"tif":  TIFF,
 	"tiff": TIFF,
 	"bmp":  BMP,
+	"svg":  SVG,
+	"ico":  ICO,
+	"jp2":  JP2,
+	"j2k":  JP2,
+	"jxl":  JXL,
+	"pbm":  PBM,
+	"pgm":  PGM,
+	"ppm":  PPM,
+	"pnm":  PPM,
+	"dds":  DDS,
+	"ktx2": KTX2,
+	"webp": WEBP,
 }
 
 var formatNames = map[Format]string{
@@ -130,18 +423,81 @@ var formatNames = map[Format]string{
 	GIF:  "GIF",
 	TIFF: "TIFF",
 	BMP:  "BMP",
+	SVG:  "SVG",
+	ICO:  "ICO",
+	JP2:  "JP2",
+	JXL:  "JXL",
+	PBM:  "PBM",
+	PGM:  "PGM",
+	PPM:  "PPM",
+	DDS:  "DDS",
+	KTX2: "KTX2",
+	WEBP: "WEBP",
 }
 
 func (f Format) String() string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
 	return formatNames[f]
 }
 
+// formatMIMEs are the MIME types of the formats, the first one is the canonical type.
+var formatMIMEs = map[Format][]string{
+	JPEG: {"image/jpeg", "image/jpg", "image/pjpeg"},
+	PNG:  {"image/png", "image/apng"},
+	GIF:  {"image/gif"},
+	TIFF: {"image/tiff", "image/tiff-fx"},
+	BMP:  {"image/bmp", "image/x-bmp", "image/x-ms-bmp"},
+	SVG:  {"image/svg+xml"},
+	ICO:  {"image/vnd.microsoft.icon", "image/x-icon"},
+	JP2:  {"image/jp2", "image/jpx"},
+	JXL:  {"image/jxl"},
+	PBM:  {"image/x-portable-bitmap"},
+	PGM:  {"image/x-portable-graymap"},
+	PPM:  {"image/x-portable-pixmap", "image/x-portable-anymap"},
+	DDS:  {"image/vnd-ms.dds", "image/x-dds"},
+	KTX2: {"image/ktx2"},
+	WEBP: {"image/webp"},
+}
+
+// MIME returns the MIME type of the format, e.g. "image/jpeg" for JPEG,
+// suitable for the Content-Type header. It returns an empty string for the formats
+// added by RegisterFormat.
+func (f Format) MIME() string {
+	if types := formatMIMEs[f]; len(types) > 0 {
+		return types[0]
+	}
+	return ""
+}
+
+// FormatFromMIME parses image format from a MIME type such as the value of the Content-Type
+// header, e.g. "image/jpeg". The parameters of the type are ignored. The common aliases
+// like "image/jpg" and "image/x-icon" are supported.
+func FormatFromMIME(mimeType string) (Format, error) {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return -1, ErrUnsupportedFormat
+	}
+	for f, types := range formatMIMEs {
+		for _, t := range types {
+			if t == mediaType {
+				return f, nil
+			}
+		}
+	}
+	return -1, ErrUnsupportedFormat
+}
+
 // ErrUnsupportedFormat means the given image format is not supported.
 var ErrUnsupportedFormat = errors.New("imaging: unsupported image format")
 
 // FormatFromExtension parses image format from filename extension:
-// "jpg" (or "jpeg"), "png", "gif", "tif" (or "tiff") and "bmp" are supported.
+// "jpg" (or "jpeg"), "png", "gif", "tif" (or "tiff"), "bmp", "svg", "ico", "jp2" (or "j2k"),
+// "jxl", "pbm", "pgm", "ppm" (or "pnm"), "dds", "ktx2", "webp" and the extensions of the
+// formats added by RegisterFormat are supported.
 func FormatFromExtension(ext string) (Format, error) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
 	if f, ok := formatExts[strings.ToLower(strings.TrimPrefix(ext, "."))]; ok {
 		return f, nil
 	}
@@ -149,7 +505,9 @@ func FormatFromExtension(ext string) (Format, error) {
 }
 
 // FormatFromFilename parses image format from filename:
-// "jpg" (or "jpeg"), "png", "gif", "tif" (or "tiff") and "bmp" are supported.
+// "jpg" (or "jpeg"), "png", "gif", "tif" (or "tiff"), "bmp", "svg", "ico", "jp2" (or "j2k"),
+// "jxl", "pbm", "pgm", "ppm" (or "pnm"), "dds", "ktx2", "webp" and the extensions of the
+// formats added by RegisterFormat are supported.
 func FormatFromFilename(filename string) (Format, error) {
 	ext := filepath.Ext(filename)
 	return FormatFromExtension(ext)
@@ -157,18 +515,57 @@ func FormatFromFilename(filename string) (Format, error) {
 
 type encodeConfig struct {
 	jpegQuality         int
+	jpegProgressive     bool
+	jpegOptimize        bool
+	jpegSubsampling     ChromaSubsampling
 	gifNumColors        int
 	gifQuantizer        draw.Quantizer
 	gifDrawer           draw.Drawer
+	gifInterlace        bool
+	tiffCompression     tiff.CompressionType
+	tiffPredictor       bool
+	bmpBitDepth         int
 	pngCompressionLevel png.CompressionLevel
+	pngColor            PNGColor
+	pngBitDepth         int
+	svgNumColors        int
+	icoSizes            []int
+	jp2CompressionRatio float64
+	jxlQuality          int
+	pnmPlain            bool
+	textureCompression  TextureCompression
+	textureMipmaps      bool
+	animationPalette    AnimationPaletteMode
+	animationQuality    []int
+	animationDedup      int
+	hashes              []hash.Hash
 }
 
 var defaultEncodeConfig = encodeConfig{
 	jpegQuality:         95,
+	jpegProgressive:     false,
+	jpegOptimize:        false,
+	jpegSubsampling:     ChromaSubsampling420,
 	gifNumColors:        256,
 	gifQuantizer:        nil,
 	gifDrawer:           nil,
+	gifInterlace:        false,
+	tiffCompression:     tiff.Deflate,
+	tiffPredictor:       true,
+	bmpBitDepth:         0,
 	pngCompressionLevel: png.DefaultCompression,
+	pngColor:            PNGColorAuto,
+	pngBitDepth:         0,
+	svgNumColors:        16,
+	icoSizes:            nil,
+	jp2CompressionRatio: 0,
+	jxlQuality:          90,
+	pnmPlain:            false,
+	textureCompression:  TextureUncompressed,
+	textureMipmaps:      false,
+	animationPalette:    AnimationPalettePerFrame,
+	animationQuality:    nil,
+	animationDedup:      -1,
 }
 
 // EncodeOption sets an optional parameter for the Encode and Save functions.
@@ -182,6 +579,49 @@ func JPEGQuality(quality int) EncodeOption {
 	}
 }
 
+// JPEGProgressive returns an EncodeOption that sets the progressive mode of the JPEG-encoded image.
+// Progressive images are displayed in increasing detail while loading and are often smaller.
+// Progressive images always use optimized Huffman tables. By default it's disabled.
+func JPEGProgressive(enabled bool) EncodeOption {
+	return func(c *encodeConfig) {
+		c.jpegProgressive = enabled
+	}
+}
+
+// JPEGOptimizedHuffman returns an EncodeOption that enables computing optimal Huffman tables
+// for the JPEG-encoded image instead of using the standard ones. It makes the file smaller
+// at the cost of slower encoding. By default it's disabled.
+func JPEGOptimizedHuffman(enabled bool) EncodeOption {
+	return func(c *encodeConfig) {
+		c.jpegOptimize = enabled
+	}
+}
+
+// ChromaSubsampling is a chroma subsampling ratio of the JPEG-encoded image.
+type ChromaSubsampling int
+
+// Chroma subsampling ratios.
+const (
+	// ChromaSubsampling420 halves the chroma resolution horizontally and vertically.
+	// This is the default.
+	ChromaSubsampling420 ChromaSubsampling = iota
+
+	// ChromaSubsampling422 halves the chroma resolution horizontally.
+	ChromaSubsampling422
+
+	// ChromaSubsampling444 keeps the full chroma resolution. It avoids color bleeding
+	// around sharp edges, e.g. in screenshots with text.
+	ChromaSubsampling444
+)
+
+// JPEGSubsampling returns an EncodeOption that sets the chroma subsampling ratio
+// of the JPEG-encoded image. It has no effect on grayscale images. Default is ChromaSubsampling420.
+func JPEGSubsampling(ratio ChromaSubsampling) EncodeOption {
+	return func(c *encodeConfig) {
+		c.jpegSubsampling = ratio
+	}
+}
+
 // GIFNumColors returns an EncodeOption that sets the maximum number of colors
 // used in the GIF-encoded image. It ranges from 1 to 256.  Default is 256.
 func GIFNumColors(numColors int) EncodeOption {
@@ -191,7 +631,8 @@ func GIFNumColors(numColors int) EncodeOption {
 }
 
 // GIFQuantizer returns an EncodeOption that sets the quantizer that is used to produce
-// a palette of the GIF-encoded image.
+// a palette of the GIF-encoded image. QuantizeMedianCut and QuantizeOctree can be used
+// to build an optimized palette instead of the default Plan 9 palette.
 func GIFQuantizer(quantizer draw.Quantizer) EncodeOption {
 	return func(c *encodeConfig) {
 		c.gifQuantizer = quantizer
@@ -200,12 +641,49 @@ func GIFQuantizer(quantizer draw.Quantizer) EncodeOption {
 
 // GIFDrawer returns an EncodeOption that sets the drawer that is used to convert
 // the source image to the desired palette of the GIF-encoded image.
+// The DitherMethod values (e.g. DitherAtkinson) can be used as drawers.
 func GIFDrawer(drawer draw.Drawer) EncodeOption {
 	return func(c *encodeConfig) {
 		c.gifDrawer = drawer
 	}
 }
 
+// GIFInterlace returns an EncodeOption that sets the interlace mode of the GIF-encoded image.
+// Interlaced images are displayed progressively while loading. By default it's disabled.
+func GIFInterlace(enabled bool) EncodeOption {
+	return func(c *encodeConfig) {
+		c.gifInterlace = enabled
+	}
+}
+
+// TIFFCompression returns an EncodeOption that sets the compression type of the TIFF-encoded image.
+// tiff.Uncompressed, tiff.Deflate and tiff.LZW are supported. Default is tiff.Deflate.
+func TIFFCompression(compression tiff.CompressionType) EncodeOption {
+	return func(c *encodeConfig) {
+		c.tiffCompression = compression
+	}
+}
+
+// TIFFPredictor returns an EncodeOption that sets whether the horizontal differencing predictor
+// is applied to the TIFF-encoded image. It improves the compression of photographic images.
+// It has no effect on paletted images. By default it's enabled.
+func TIFFPredictor(enabled bool) EncodeOption {
+	return func(c *encodeConfig) {
+		c.tiffPredictor = enabled
+	}
+}
+
+// BMPBitDepth returns an EncodeOption that sets the number of bits per pixel of the BMP-encoded image.
+// Supported values are 8 (paletted, the palette is built using the median cut quantization),
+// 16 (5 bits per channel), 24 and 32 (with alpha). By default (0) the image is written with
+// 8 bits per pixel if it's grayscale or paletted and 24 or 32 bits otherwise, but decoders
+// ignore the alpha channel of such images. Set 32 to keep the alpha channel.
+func BMPBitDepth(depth int) EncodeOption {
+	return func(c *encodeConfig) {
+		c.bmpBitDepth = depth
+	}
+}
+
 // PNGCompressionLevel returns an EncodeOption that sets the compression level
 // of the PNG-encoded image. Default is png.DefaultCompression.
 func PNGCompressionLevel(level png.CompressionLevel) EncodeOption {
@@ -214,15 +692,157 @@ func PNGCompressionLevel(level png.CompressionLevel) EncodeOption {
 	}
 }
 
-// Encode writes the image img to w in the specified format (JPEG, PNG, GIF, TIFF or BMP).
+// PNGColor is a color type of the PNG-encoded image.
+type PNGColor int
+
+// PNG color types.
+const (
+	// PNGColorAuto chooses the color type from the image type. This is the default.
+	PNGColorAuto PNGColor = iota
+
+	// PNGColorGray encodes the luminance of the image as grayscale without alpha.
+	// The translucent pixels are composited over black.
+	PNGColorGray
+
+	// PNGColorRGBA encodes the image as truecolor with alpha
+	// (without alpha if the image is opaque).
+	PNGColorRGBA
+
+	// PNGColorPaletted encodes the image as indexed color (PNG-8). The palette is built
+	// using the median cut quantization and the image is dithered to it.
+	PNGColorPaletted
+)
+
+// PNGColorType returns an EncodeOption that sets the color type of the PNG-encoded image.
+// Default is PNGColorAuto.
+func PNGColorType(colorType PNGColor) EncodeOption {
+	return func(c *encodeConfig) {
+		c.pngColor = colorType
+	}
+}
+
+// PNGBitDepth returns an EncodeOption that sets the bit depth of the PNG-encoded image.
+// Grayscale and truecolor images support 8 and 16 bits per channel. Paletted images
+// support 1, 2, 4 and 8 bits per pixel, limiting the palette to 2, 4, 16 and 256 colors.
+// By default (0) the bit depth is chosen from the image type.
+func PNGBitDepth(depth int) EncodeOption {
+	return func(c *encodeConfig) {
+		c.pngBitDepth = depth
+	}
+}
+
+// SVGNumColors returns an EncodeOption that sets the maximum number of colors
+// the image is posterized to before it's traced into SVG paths. It ranges from 1 to 256. Default is 16.
+func SVGNumColors(numColors int) EncodeOption {
+	return func(c *encodeConfig) {
+		c.svgNumColors = numColors
+	}
+}
+
+// ICOSizes returns an EncodeOption that sets the sizes of the square icons stored in the ICO file,
+// see GenerateFavicon. By default the image is stored as is, scaled down to fit 256x256 pixels.
+func ICOSizes(sizes ...int) EncodeOption {
+	return func(c *encodeConfig) {
+		c.icoSizes = sizes
+	}
+}
+
+// JP2CompressionRatio returns an EncodeOption that sets the compression ratio of the JPEG 2000
+// image, e.g. 20 makes the file about 20 times smaller than the raw pixel data. Ratios of 1
+// and less mean lossless compression, which is the default.
+func JP2CompressionRatio(ratio float64) EncodeOption {
+	return func(c *encodeConfig) {
+		c.jp2CompressionRatio = ratio
+	}
+}
+
+// JXLQuality returns an EncodeOption that sets the output JPEG XL quality.
+// Quality ranges from 0 to 100 inclusive, higher is better, 100 is lossless. Default is 90.
+func JXLQuality(quality int) EncodeOption {
+	return func(c *encodeConfig) {
+		c.jxlQuality = quality
+	}
+}
+
+// PNMPlain returns an EncodeOption that enables the plain (ASCII) variant of the PBM, PGM
+// and PPM formats. Plain images are much larger but readable by humans and simple tools.
+// By default the binary (raw) variant is used.
+func PNMPlain(enabled bool) EncodeOption {
+	return func(c *encodeConfig) {
+		c.pnmPlain = enabled
+	}
+}
+
+// TextureFormat returns an EncodeOption that sets the pixel format of DDS and KTX2 textures.
+// Default is TextureUncompressed.
+func TextureFormat(compression TextureCompression) EncodeOption {
+	return func(c *encodeConfig) {
+		c.textureCompression = compression
+	}
+}
+
+// TextureMipmaps returns an EncodeOption that enables storing the mipmaps in DDS and KTX2 textures:
+// the image downscaled by the factors of 2 down to 1x1 pixels. By default it's disabled.
+func TextureMipmaps(enabled bool) EncodeOption {
+	return func(c *encodeConfig) {
+		c.textureMipmaps = enabled
+	}
+}
+
+// EncodeHash returns an EncodeOption that writes the encoded data to the hash as well while
+// it's encoded, so the content hash is known without reading the data again, e.g. to store
+// it by its hash. The option can be given several times to compute several hashes.
+// Encode writes all the data to the hash, the hash of the data of a failed encoding is
+// meaningless.
+//
+// Example:
+//
+//	h := sha256.New()
+//	data, err := imaging.EncodeBytes(img, imaging.JPEG, imaging.EncodeHash(h))
+//	if err != nil {
+//		return err
+//	}
+//	key := hex.EncodeToString(h.Sum(nil)) + ".jpg"
+func EncodeHash(h hash.Hash) EncodeOption {
+	return func(c *encodeConfig) {
+		c.hashes = append(c.hashes, h)
+	}
+}
+
+// writer returns the writer of the encoded data written to w and the hashes.
+func (c *encodeConfig) writer(w io.Writer) io.Writer {
+	if len(c.hashes) == 0 {
+		return w
+	}
+	ws := []io.Writer{w}
+	for _, h := range c.hashes {
+		ws = append(ws, h)
+	}
+	return io.MultiWriter(ws...)
+}
+
+// Encode writes the image img to w in the specified format (JPEG, PNG, GIF, TIFF, BMP, SVG, ICO,
+// JP2, JXL, PBM, PGM, PPM, DDS, KTX2, WEBP or a format added by RegisterFormat). WebP images
+// are lossless. SVG output is produced by tracing the image, see EncodeSVG. JPEG 2000 and
+// JPEG XL images are encoded by external programs in the programs built with
+// the imaging_exec build tag, see ErrCodecUnsupported.
 func Encode(w io.Writer, img image.Image, format Format, opts ...EncodeOption) error {
 	cfg := defaultEncodeConfig
 	for _, option := range opts {
 		option(&cfg)
 	}
+	w = cfg.writer(w)
 
 	switch format {
 	case JPEG:
+		if cfg.jpegProgressive || cfg.jpegOptimize || cfg.jpegSubsampling != ChromaSubsampling420 {
+			return encodeJPEG(w, img, jpegOptions{
+				quality:     cfg.jpegQuality,
+				progressive: cfg.jpegProgressive,
+				optimize:    cfg.jpegOptimize,
+				subsampling: cfg.jpegSubsampling,
+			})
+		}
 		if nrgba, ok := img.(*image.NRGBA); ok && nrgba.Opaque() {
 			rgba := &image.RGBA{
 				Pix:    nrgba.Pix,
@@ -235,28 +855,249 @@ func Encode(w io.Writer, img image.Image, format Format, opts ...EncodeOption) e
 
 	case PNG:
 		encoder := png.Encoder{CompressionLevel: cfg.pngCompressionLevel}
-		return encoder.Encode(w, img)
+		return encoder.Encode(w, convertPNG(img, cfg.pngColor, cfg.pngBitDepth))
 
 	case GIF:
-		return gif.Encode(w, img, &gif.Options{
+		options := &gif.Options{
 			NumColors: cfg.gifNumColors,
 			Quantizer: cfg.gifQuantizer,
 			Drawer:    cfg.gifDrawer,
-		})
+		}
+		if cfg.gifInterlace {
+			return encodeInterlacedGIF(w, img, options)
+		}
+		return gif.Encode(w, img, options)
 
 	case TIFF:
-		return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate, Predictor: true})
+		return encodeTIFF(w, []image.Image{img}, cfg.tiffCompression, cfg.tiffPredictor)
 
 	case BMP:
+		if cfg.bmpBitDepth != 0 {
+			return encodeBMP(w, img, cfg.bmpBitDepth)
+		}
 		return bmp.Encode(w, img)
+
+	case SVG:
+		return EncodeSVG(w, img, cfg.svgNumColors)
+
+	case ICO:
+		return encodeICO(w, img, cfg.icoSizes)
+
+	case JP2, JXL:
+		return encodeExternal(w, img, format, cfg)
+
+	case PBM, PGM, PPM:
+		return encodePNM(w, img, format, cfg.pnmPlain)
+
+	case DDS:
+		return encodeDDS(w, img, cfg.textureCompression, cfg.textureMipmaps)
+
+	case KTX2:
+		return encodeKTX2(w, img, cfg.textureCompression, cfg.textureMipmaps)
+
+	case WEBP:
+		return encodeWebP(w, img)
 	}
 
+	if encode := registeredEncoder(format); encode != nil {
+		return encode(w, img)
+	}
 	return ErrUnsupportedFormat
 }
 
+// maxPooledBuffer is the capacity of the largest buffer EncodeBytes reuses.
+const maxPooledBuffer = 64 << 20
+
+var encodeBuffers = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// EncodeBytes encodes the image like Encode and returns the encoded data, e.g. to pass it to
+// an object storage client. The image is encoded to a pooled buffer, so that repeated calls
+// allocate little more than the returned slice.
+func EncodeBytes(img image.Image, format Format, opts ...EncodeOption) ([]byte, error) {
+	buf := encodeBuffers.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			buf.Reset()
+			encodeBuffers.Put(buf)
+		}
+	}()
+	if err := Encode(buf, img, format, opts...); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), buf.Bytes()...), nil
+}
+
+// convertPNG converts the image to the image type the PNG encoder
+// writes with the given color type and bit depth.
+func convertPNG(img image.Image, colorType PNGColor, depth int) image.Image {
+	switch colorType {
+	case PNGColorPaletted:
+		numColors := 256
+		if depth == 1 || depth == 2 || depth == 4 {
+			numColors = 1 << depth
+		}
+		if pm, ok := img.(*image.Paletted); ok && len(pm.Palette) <= numColors {
+			return img
+		}
+		return Dither(img, Quantize(img, numColors, QuantizeMedianCut), DitherFloydSteinberg)
+
+	case PNGColorGray:
+		// Both depths composite the translucent pixels over black, like the color models
+		// of the gray images.
+		b := img.Bounds()
+		var dst draw.Image
+		switch depth {
+		case 16:
+			if _, ok := img.(*image.Gray16); ok {
+				return img
+			}
+			dst = image.NewGray16(b)
+		default:
+			if _, ok := img.(*image.Gray); ok {
+				return img
+			}
+			dst = image.NewGray(b)
+		}
+		draw.Draw(dst, b, img, b.Min, draw.Src)
+		return dst
+
+	case PNGColorRGBA:
+		switch depth {
+		case 16:
+			return toNRGBA64(img)
+		default:
+			return toNRGBA(img)
+		}
+	}
+
+	switch depth {
+	case 8:
+		switch img.(type) {
+		case *image.Gray16:
+			dst := image.NewGray(img.Bounds())
+			draw.Draw(dst, dst.Rect, img, dst.Rect.Min, draw.Src)
+			return dst
+		case *image.NRGBA64, *image.RGBA64:
+			return toNRGBA(img)
+		}
+	case 16:
+		switch img.(type) {
+		case *image.Gray:
+			dst := image.NewGray16(img.Bounds())
+			draw.Draw(dst, dst.Rect, img, dst.Rect.Min, draw.Src)
+			return dst
+		case *image.Gray16, *image.NRGBA64, *image.RGBA64:
+			return img
+		default:
+			return toNRGBA64(img)
+		}
+	}
+	return img
+}
+
+// toNRGBA64 converts the image to the 16 bits per channel non-premultiplied image type.
+func toNRGBA64(img image.Image) *image.NRGBA64 {
+	if img, ok := img.(*image.NRGBA64); ok {
+		return img
+	}
+	b := img.Bounds()
+	dst := image.NewNRGBA64(b)
+	draw.Draw(dst, b, img, b.Min, draw.Src)
+	return dst
+}
+
+// encodeInterlacedGIF writes the image to w as an interlaced GIF.
+// The standard library encoder has no interlace support, so the image rows are
+// reordered into the interlaced order before encoding and the interlace flag
+// of the image descriptor is set afterwards.
+func encodeInterlacedGIF(w io.Writer, img image.Image, options *gif.Options) error {
+	b := img.Bounds()
+	numColors := options.NumColors
+	if numColors < 1 || numColors > 256 {
+		numColors = 256
+	}
+
+	pm, ok := img.(*image.Paletted)
+	if !ok || len(pm.Palette) > numColors {
+		var p color.Palette = palette.Plan9[:numColors]
+		if options.Quantizer != nil {
+			p = options.Quantizer.Quantize(make(color.Palette, 0, numColors), img)
+		}
+		drawer := options.Drawer
+		if drawer == nil {
+			drawer = draw.FloydSteinberg
+		}
+		pm = image.NewPaletted(b, p)
+		drawer.Draw(pm, b, img, b.Min)
+	}
+
+	interlaced := image.NewPaletted(pm.Rect, pm.Palette)
+	rowSize := pm.Rect.Dx()
+	y := 0
+	for _, pass := range [][2]int{{0, 8}, {4, 8}, {2, 4}, {1, 2}} {
+		for srcY := pass[0]; srcY < pm.Rect.Dy(); srcY += pass[1] {
+			copy(interlaced.Pix[y*interlaced.Stride:y*interlaced.Stride+rowSize], pm.Pix[srcY*pm.Stride:srcY*pm.Stride+rowSize])
+			y++
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, interlaced, &gif.Options{NumColors: len(pm.Palette)}); err != nil {
+		return err
+	}
+	data := buf.Bytes()
+	if err := setGIFInterlaceFlag(data); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// setGIFInterlaceFlag sets the interlace flag of the first image descriptor in the GIF data.
+func setGIFInterlaceFlag(data []byte) error {
+	const (
+		headerSize       = 6 + 7
+		flagColorTable   = 0x80
+		flagInterlace    = 0x40
+		extensionIntro   = 0x21
+		imageSeparator   = 0x2c
+		descriptorSize   = 10
+		descriptorPacked = 9
+	)
+
+	errBad := errors.New("imaging: bad GIF data")
+	if len(data) < headerSize {
+		return errBad
+	}
+	i := headerSize
+	if flags := data[10]; flags&flagColorTable != 0 {
+		i += 3 << (flags&7 + 1)
+	}
+	for i < len(data) {
+		switch data[i] {
+		case imageSeparator:
+			if i+descriptorSize > len(data) {
+				return errBad
+			}
+			data[i+descriptorPacked] |= flagInterlace
+			return nil
+		case extensionIntro:
+			i += 2
+			for i < len(data) && data[i] != 0 {
+				i += int(data[i]) + 1
+			}
+			i++
+		default:
+			return errBad
+		}
+	}
+	return errBad
+}
+
 // Save saves the image to file with the specified filename.
-// The format is determined from the filename extension:
-// "jpg" (or "jpeg"), "png", "gif", "tif" (or "tiff") and "bmp" are supported.
+// The format is determined from the filename extension, see FormatFromFilename.
 //
 // Examples:
 //
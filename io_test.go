@@ -2,6 +2,8 @@ package imaging
 
 import (
 	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
 	"errors"
 	"image"
 	"image/color"
@@ -88,6 +90,7 @@ func TestOpenSave(t *testing.T) {
 			GIFDrawer(draw.FloydSteinberg),
 			GIFNumColors(256),
 			GIFQuantizer(quantizer{palette.Plan9}),
+			GIFInterlace(true),
 			PNGCompressionLevel(png.BestSpeed),
 		},
 	}
@@ -98,7 +101,7 @@ func TestOpenSave(t *testing.T) {
 	}
 	defer os.RemoveAll(dir)
 
-	for _, ext := range []string{"jpg", "jpeg", "png", "gif", "bmp", "tif", "tiff"} {
+	for _, ext := range []string{"jpg", "jpeg", "png", "gif", "bmp", "tif", "tiff", "ico", "ppm", "pnm"} {
 		filename := filepath.Join(dir, "test."+ext)
 
 		img := imgWithoutAlpha
@@ -172,6 +175,141 @@ func TestOpenSave(t *testing.T) {
 	}
 }
 
+func TestEncodePNGColorType(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 3, 1),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0xff, 0x00, 0x00, 0xff, 0x00, 0xff, 0x00, 0x80, 0x40, 0x40, 0x40, 0xff,
+		},
+	}
+	testCases := []struct {
+		name      string
+		opts      []EncodeOption
+		bitDepth  uint8
+		colorType uint8
+		want      *image.NRGBA
+	}{
+		{
+			"auto",
+			nil,
+			8, 6,
+			src,
+		},
+		{
+			"auto 16",
+			[]EncodeOption{PNGBitDepth(16)},
+			16, 6,
+			src,
+		},
+		{
+			"rgba 16",
+			[]EncodeOption{PNGColorType(PNGColorRGBA), PNGBitDepth(16)},
+			16, 6,
+			src,
+		},
+		{
+			"gray",
+			[]EncodeOption{PNGColorType(PNGColorGray)},
+			8, 0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x4c, 0x4c, 0x4c, 0xff, 0x4b, 0x4b, 0x4b, 0xff, 0x40, 0x40, 0x40, 0xff,
+				},
+			},
+		},
+		{
+			// The translucent green is composited over black at both depths.
+			"gray 16",
+			[]EncodeOption{PNGColorType(PNGColorGray), PNGBitDepth(16)},
+			16, 0,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 1),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x4c, 0x4c, 0x4c, 0xff, 0x4b, 0x4b, 0x4b, 0xff, 0x40, 0x40, 0x40, 0xff,
+				},
+			},
+		},
+		{
+			"paletted",
+			[]EncodeOption{PNGColorType(PNGColorPaletted)},
+			2, 3,
+			src,
+		},
+		{
+			"paletted 1",
+			[]EncodeOption{PNGColorType(PNGColorPaletted), PNGBitDepth(1)},
+			1, 3,
+			nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, src, PNG, tc.opts...); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			data := buf.Bytes()
+			if data[24] != tc.bitDepth || data[25] != tc.colorType {
+				t.Fatalf("got bit depth %d color type %d want %d %d", data[24], data[25], tc.bitDepth, tc.colorType)
+			}
+			img, err := Decode(&buf)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if got := Clone(img); tc.want != nil && !compareNRGBA(got, tc.want, 0) {
+				t.Fatalf("got %#v want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncodeGIFInterlace(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 5, 19))
+	for y := 0; y < 19; y++ {
+		for x := 0; x < 5; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{uint8(y * 13), uint8(x * 50), 0x80, 0xff})
+		}
+	}
+
+	for _, interlace := range []bool{false, true} {
+		var buf bytes.Buffer
+		err := Encode(&buf, src, GIF, GIFInterlace(interlace), GIFQuantizer(QuantizeMedianCut), GIFDrawer(DitherNone))
+		if err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+
+		data := buf.Bytes()
+		i := bytes.IndexByte(data[13:], 0x2c) + 13
+		if got := data[i+9]&0x40 != 0; got != interlace {
+			t.Fatalf("got interlace flag %v want %v", got, interlace)
+		}
+
+		img, err := Decode(&buf)
+		if err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		if got := Clone(img); !compareNRGBA(got, src, 0) {
+			t.Fatalf("interlace=%v: got %#v want %#v", interlace, got, src)
+		}
+	}
+}
+
+func TestSetGIFInterlaceFlagFails(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		[]byte("GIF89a\x01\x00\x01\x00\x00\x00\x00"),
+		[]byte("GIF89a\x01\x00\x01\x00\x00\x00\x00\x3b"),
+	} {
+		if err := setGIFInterlaceFlag(data); err == nil {
+			t.Fatalf("setGIFInterlaceFlag(%q): expected error got nil", data)
+		}
+	}
+}
+
 func TestFormats(t *testing.T) {
 	formatNames := map[Format]string{
 		JPEG:       "JPEG",
@@ -179,6 +317,16 @@ func TestFormats(t *testing.T) {
 		GIF:        "GIF",
 		BMP:        "BMP",
 		TIFF:       "TIFF",
+		SVG:        "SVG",
+		ICO:        "ICO",
+		JP2:        "JP2",
+		JXL:        "JXL",
+		PBM:        "PBM",
+		PGM:        "PGM",
+		PPM:        "PPM",
+		DDS:        "DDS",
+		KTX2:       "KTX2",
+		WEBP:       "WEBP",
 		Format(-1): "",
 	}
 	for format, name := range formatNames {
@@ -232,6 +380,241 @@ func TestFormatFromExtension(t *testing.T) {
 	}
 }
 
+func TestDecodeWithFormat(t *testing.T) {
+	img := New(8, 6, color.NRGBA{200, 100, 50, 255})
+	for _, format := range []Format{JPEG, PNG, GIF, TIFF, BMP, SVG, ICO, PBM, PGM, PPM, WEBP} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, img, format); err != nil {
+			t.Fatalf("Encode(%v): %v", format, err)
+		}
+		got, gotFormat, err := DecodeWithFormat(&buf, AutoOrientation(format == JPEG), SVGDecoding(true))
+		if err != nil {
+			t.Fatalf("DecodeWithFormat(%v): %v", format, err)
+		}
+		if gotFormat != format {
+			t.Fatalf("got format %v want %v", gotFormat, format)
+		}
+		if got.Bounds().Size() != img.Bounds().Size() {
+			t.Fatalf("%v: got size %v", format, got.Bounds().Size())
+		}
+	}
+
+	_, format, err := DecodeWithFormat(strings.NewReader("not an image"))
+	if err != image.ErrFormat || format != -1 {
+		t.Fatalf("got %v, %v want -1, %v", format, err, image.ErrFormat)
+	}
+}
+
+func TestOpenWithFormat(t *testing.T) {
+	// The format is detected from the contents.
+	filename := filepath.Join(t.TempDir(), "upload")
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatalf("os.Create: %v", err)
+	}
+	if err := Encode(file, testdataFlowersSmallPNG, PNG); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	file.Close()
+
+	img, format, err := OpenWithFormat(filename)
+	if err != nil {
+		t.Fatalf("OpenWithFormat: %v", err)
+	}
+	if format != PNG || !compareNRGBA(Clone(img), Clone(testdataFlowersSmallPNG), 0) {
+		t.Fatalf("got format %v and a different image", format)
+	}
+	if _, format, err := OpenWithFormat(filename + ".missing"); err == nil || format != -1 {
+		t.Fatalf("got %v, %v want -1 and an error", format, err)
+	}
+}
+
+func TestDecodeBytes(t *testing.T) {
+	img := New(8, 6, color.NRGBA{200, 100, 50, 255})
+	for _, format := range []Format{JPEG, PNG, GIF, TIFF, BMP, SVG, ICO, PBM, PGM, PPM} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, img, format); err != nil {
+			t.Fatalf("Encode(%v): %v", format, err)
+		}
+		data := buf.Bytes()
+		want, err := Decode(bytes.NewReader(data), SVGDecoding(true))
+		if err != nil {
+			t.Fatalf("Decode(%v): %v", format, err)
+		}
+		got, err := DecodeBytes(data, SVGDecoding(true))
+		if err != nil {
+			t.Fatalf("DecodeBytes(%v): %v", format, err)
+		}
+		if !compareNRGBA(Clone(got), Clone(want), 0) {
+			t.Fatalf("DecodeBytes(%v): the image differs", format)
+		}
+		// The limits are checked on the header before decoding the whole data.
+		if got, err := DecodeBytes(data, SVGDecoding(true), MaxPixels(48)); err != nil || !compareNRGBA(Clone(got), Clone(want), 0) {
+			t.Fatalf("DecodeBytes(%v) with limits: %v", format, err)
+		}
+		if _, err := DecodeBytes(data, SVGDecoding(true), MaxPixels(47)); err == nil {
+			t.Fatalf("DecodeBytes(%v): expected an error", format)
+		}
+	}
+
+	data, err := os.ReadFile("testdata/orientation_6.jpg")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	want, err := Decode(bytes.NewReader(data), AutoOrientation(true))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	got, err := DecodeBytes(data, AutoOrientation(true))
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	if !compareNRGBA(Clone(got), Clone(want), 0) {
+		t.Fatal("DecodeBytes: the oriented image differs")
+	}
+
+	if _, err := DecodeBytes(nil); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestEncodeBytes(t *testing.T) {
+	for _, format := range []Format{JPEG, PNG, GIF, TIFF, BMP, PPM} {
+		var want bytes.Buffer
+		if err := Encode(&want, testdataFlowersSmallPNG, format, JPEGQuality(80)); err != nil {
+			t.Fatalf("Encode(%v): %v", format, err)
+		}
+		// The second call reuses the buffer of the first one.
+		for i := 0; i < 2; i++ {
+			got, err := EncodeBytes(testdataFlowersSmallPNG, format, JPEGQuality(80))
+			if err != nil {
+				t.Fatalf("EncodeBytes(%v): %v", format, err)
+			}
+			if !bytes.Equal(got, want.Bytes()) {
+				t.Fatalf("EncodeBytes(%v): got %d bytes that differ from Encode", format, len(got))
+			}
+		}
+	}
+
+	// The result isn't overwritten by later calls.
+	a, err := EncodeBytes(New(1, 1, color.White), PPM)
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	want := string(a)
+	if _, err := EncodeBytes(New(1, 1, color.Black), PPM); err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	if string(a) != want {
+		t.Fatalf("got %q want %q", a, want)
+	}
+
+	if _, err := EncodeBytes(testdataFlowersSmallPNG, Format(-1)); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want %v", err, ErrUnsupportedFormat)
+	}
+}
+
+func TestDecodeLimits(t *testing.T) {
+	img := New(40, 30, color.NRGBA{200, 100, 50, 255})
+	testCases := []struct {
+		name     string
+		opts     []DecodeOption
+		tooLarge bool
+	}{
+		{"no limits", nil, false},
+		{"pixels", []DecodeOption{MaxPixels(40 * 30)}, false},
+		{"pixels exceeded", []DecodeOption{MaxPixels(40*30 - 1)}, true},
+		{"dimensions", []DecodeOption{MaxDimensions(40, 30)}, false},
+		{"width exceeded", []DecodeOption{MaxDimensions(39, 0)}, true},
+		{"height exceeded", []DecodeOption{MaxDimensions(0, 29)}, true},
+		{"both", []DecodeOption{MaxDimensions(100, 100), MaxPixels(100)}, true},
+	}
+	for _, format := range []Format{JPEG, PNG, GIF, TIFF, BMP, SVG, ICO, PPM} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, img, format); err != nil {
+			t.Fatalf("Encode(%v): %v", format, err)
+		}
+		for _, tc := range testCases {
+			opts := append([]DecodeOption{SVGDecoding(true)}, tc.opts...)
+			got, gotFormat, err := DecodeWithFormat(bytes.NewReader(buf.Bytes()), opts...)
+			if tc.tooLarge {
+				var tooLarge *ImageTooLargeError
+				if !errors.As(err, &tooLarge) || tooLarge.Width != 40 || tooLarge.Height != 30 {
+					t.Fatalf("%v, %s: got error %v want an *ImageTooLargeError", format, tc.name, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%v, %s: DecodeWithFormat: %v", format, tc.name, err)
+			}
+			// The header read to check the limits is decoded as well.
+			if gotFormat != format || got.Bounds().Size() != img.Bounds().Size() {
+				t.Fatalf("%v, %s: got format %v and size %v", format, tc.name, gotFormat, got.Bounds().Size())
+			}
+		}
+	}
+}
+
+func TestDecodeLimitsRegisteredFormat(t *testing.T) {
+	registerTestFormat(t, "gry", "GRY1")
+	data := "GRY1\x03\x02\x00\x00\x00\x00\x00\x00"
+	if _, err := Decode(strings.NewReader(data), MaxPixels(6)); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	_, err := Decode(strings.NewReader(data), MaxPixels(5))
+	if want := "imaging: image of 3x2 pixels exceeds the decode limits"; err == nil || err.Error() != want {
+		t.Fatalf("got error %v want %q", err, want)
+	}
+}
+
+func TestDecodeLimitsHeaderFails(t *testing.T) {
+	if _, err := Decode(strings.NewReader("P6 0 1 255\n"), MaxPixels(100)); err != errInvalidPNM {
+		t.Fatalf("got error %v want %v", err, errInvalidPNM)
+	}
+}
+
+func TestFormatMIME(t *testing.T) {
+	for format := JPEG; format <= WEBP; format++ {
+		mimeType := format.MIME()
+		if !strings.HasPrefix(mimeType, "image/") {
+			t.Fatalf("%v: got MIME type %q", format, mimeType)
+		}
+		got, err := FormatFromMIME(mimeType)
+		if err != nil || got != format {
+			t.Fatalf("FormatFromMIME(%q): got %v, %v want %v", mimeType, got, err, format)
+		}
+	}
+	if got := Format(-1).MIME(); got != "" {
+		t.Fatalf("got MIME type %q want an empty one", got)
+	}
+}
+
+func TestFormatFromMIME(t *testing.T) {
+	testCases := []struct {
+		mimeType string
+		want     Format
+		err      error
+	}{
+		{"image/jpeg", JPEG, nil},
+		{"image/jpg", JPEG, nil},
+		{"IMAGE/PNG", PNG, nil},
+		{"image/svg+xml; charset=utf-8", SVG, nil},
+		{"image/x-icon", ICO, nil},
+		{"image/x-portable-anymap", PPM, nil},
+		{"image/webp", WEBP, nil},
+		{"image/avif", -1, ErrUnsupportedFormat},
+		{"text/html", -1, ErrUnsupportedFormat},
+		{"", -1, ErrUnsupportedFormat},
+		{"image/png; =", -1, ErrUnsupportedFormat},
+	}
+	for _, tc := range testCases {
+		got, err := FormatFromMIME(tc.mimeType)
+		if err != tc.err || got != tc.want {
+			t.Fatalf("FormatFromMIME(%q): got %v, %v want %v, %v", tc.mimeType, got, err, tc.want, tc.err)
+		}
+	}
+}
+
 func TestReadOrientation(t *testing.T) {
 	testCases := []struct {
 		path   string
@@ -432,3 +815,56 @@ func TestAutoOrientation(t *testing.T) {
 		t.Fatal("expected error got nil")
 	}
 }
+
+func TestEncodeHash(t *testing.T) {
+	img := testdataFlowersSmallPNG
+	for _, format := range []Format{JPEG, PNG, GIF, TIFF, BMP} {
+		h1, h2 := sha256.New(), md5.New()
+		var buf bytes.Buffer
+		if err := Encode(&buf, img, format, EncodeHash(h1), JPEGQuality(80), EncodeHash(h2)); err != nil {
+			t.Fatalf("Encode(%v): %v", format, err)
+		}
+		want1, want2 := sha256.Sum256(buf.Bytes()), md5.Sum(buf.Bytes())
+		if !bytes.Equal(h1.Sum(nil), want1[:]) || !bytes.Equal(h2.Sum(nil), want2[:]) {
+			t.Fatalf("Encode(%v): the hash differs from the hash of the data", format)
+		}
+
+		h := sha256.New()
+		data, err := EncodeBytes(img, format, JPEGQuality(80), EncodeHash(h))
+		if err != nil {
+			t.Fatalf("EncodeBytes(%v): %v", format, err)
+		}
+		if want := sha256.Sum256(data); !bytes.Equal(h.Sum(nil), want[:]) || !bytes.Equal(data, buf.Bytes()) {
+			t.Fatalf("EncodeBytes(%v): the hash differs from the hash of the data", format)
+		}
+	}
+
+	h := sha256.New()
+	var buf bytes.Buffer
+	if err := EncodeMultiPageTIFF(&buf, []image.Image{img, img}, EncodeHash(h)); err != nil {
+		t.Fatalf("EncodeMultiPageTIFF: %v", err)
+	}
+	if want := sha256.Sum256(buf.Bytes()); !bytes.Equal(h.Sum(nil), want[:]) {
+		t.Fatal("EncodeMultiPageTIFF: the hash differs from the hash of the data")
+	}
+}
+
+func TestDecodeWithFormatLargePNM(t *testing.T) {
+	// The images are larger than the read buffer, so the buffer is refilled while decoding.
+	for _, format := range []Format{PBM, PGM, PPM} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, testdataFlowersSmallPNG, format); err != nil {
+			t.Fatalf("Encode(%v): %v", format, err)
+		}
+		if buf.Len() <= 4096 {
+			t.Fatalf("%v: got %d bytes want more than the read buffer", format, buf.Len())
+		}
+		_, got, err := DecodeWithFormat(&buf)
+		if err != nil {
+			t.Fatalf("DecodeWithFormat(%v): %v", format, err)
+		}
+		if got != format {
+			t.Errorf("got format %v want %v", got, format)
+		}
+	}
+}
@@ -0,0 +1,772 @@
+package imaging
+
+import (
+	"bufio"
+	"errors"
+	"image"
+	"io"
+	"math"
+)
+
+// This file implements a JPEG encoder used when the encode options ask for features
+// the standard library encoder lacks: progressive scans, optimized Huffman tables and
+// chroma subsampling ratios other than 4:2:0.
+
+// jpegOptions are the parameters of the internal JPEG encoder.
+type jpegOptions struct {
+	quality     int
+	progressive bool
+	optimize    bool
+	subsampling ChromaSubsampling
+}
+
+// jpegZigzag maps the zigzag order index of a coefficient to its natural order index.
+var jpegZigzag = [64]int{
+	0, 1, 8, 16, 9, 2, 3, 10,
+	17, 24, 32, 25, 18, 11, 4, 5,
+	12, 19, 26, 33, 40, 48, 41, 34,
+	27, 20, 13, 6, 7, 14, 21, 28,
+	35, 42, 49, 56, 57, 50, 43, 36,
+	29, 22, 15, 23, 30, 37, 44, 51,
+	58, 59, 52, 45, 38, 31, 39, 46,
+	53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+// jpegBaseQuant are the quantization tables from the JPEG specification (Annex K)
+// for luminance and chrominance, in natural order.
+var jpegBaseQuant = [2][64]int{
+	{
+		16, 11, 10, 16, 24, 40, 51, 61,
+		12, 12, 14, 19, 26, 58, 60, 55,
+		14, 13, 16, 24, 40, 57, 69, 56,
+		14, 17, 22, 29, 51, 87, 80, 62,
+		18, 22, 37, 56, 68, 109, 103, 77,
+		24, 35, 55, 64, 81, 104, 113, 92,
+		49, 64, 78, 87, 103, 121, 120, 101,
+		72, 92, 95, 98, 112, 100, 103, 99,
+	},
+	{
+		17, 18, 24, 47, 99, 99, 99, 99,
+		18, 21, 26, 66, 99, 99, 99, 99,
+		24, 26, 56, 99, 99, 99, 99, 99,
+		47, 66, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+		99, 99, 99, 99, 99, 99, 99, 99,
+	},
+}
+
+// jpegHuffmanSpec is a Huffman table: the number of codes of each length 1-16 and the symbols.
+type jpegHuffmanSpec struct {
+	bits [16]byte
+	vals []byte
+}
+
+// jpegStdHuffman are the Huffman tables from the JPEG specification (Annex K):
+// luminance DC, luminance AC, chrominance DC and chrominance AC.
+var jpegStdHuffman = [4]jpegHuffmanSpec{
+	{
+		[16]byte{0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	{
+		[16]byte{0, 2, 1, 3, 3, 2, 4, 3, 5, 5, 4, 4, 0, 0, 1, 125},
+		[]byte{
+			0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12,
+			0x21, 0x31, 0x41, 0x06, 0x13, 0x51, 0x61, 0x07,
+			0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08,
+			0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0,
+			0x24, 0x33, 0x62, 0x72, 0x82, 0x09, 0x0a, 0x16,
+			0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
+			0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39,
+			0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49,
+			0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59,
+			0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69,
+			0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79,
+			0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
+			0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98,
+			0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+			0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6,
+			0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5,
+			0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4,
+			0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2,
+			0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea,
+			0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+	{
+		[16]byte{0, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0},
+		[]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+	},
+	{
+		[16]byte{0, 2, 1, 2, 4, 4, 3, 4, 7, 5, 4, 4, 0, 1, 2, 119},
+		[]byte{
+			0x00, 0x01, 0x02, 0x03, 0x11, 0x04, 0x05, 0x21,
+			0x31, 0x06, 0x12, 0x41, 0x51, 0x07, 0x61, 0x71,
+			0x13, 0x22, 0x32, 0x81, 0x08, 0x14, 0x42, 0x91,
+			0xa1, 0xb1, 0xc1, 0x09, 0x23, 0x33, 0x52, 0xf0,
+			0x15, 0x62, 0x72, 0xd1, 0x0a, 0x16, 0x24, 0x34,
+			0xe1, 0x25, 0xf1, 0x17, 0x18, 0x19, 0x1a, 0x26,
+			0x27, 0x28, 0x29, 0x2a, 0x35, 0x36, 0x37, 0x38,
+			0x39, 0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48,
+			0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58,
+			0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68,
+			0x69, 0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78,
+			0x79, 0x7a, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+			0x88, 0x89, 0x8a, 0x92, 0x93, 0x94, 0x95, 0x96,
+			0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5,
+			0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4,
+			0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3,
+			0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2,
+			0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda,
+			0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9,
+			0xea, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+			0xf9, 0xfa,
+		},
+	},
+}
+
+// jpegComponent is a color component of the encoded image with its quantized DCT coefficients.
+type jpegComponent struct {
+	id     byte
+	h, v   int // Sampling factors.
+	tq     int // Quantization table index (0 - luminance, 1 - chrominance).
+	bw, bh int // Number of blocks per row and column in the MCU-padded grid.
+	cw, ch int // Number of blocks per row and column that cover the image.
+	coef   [][64]int32
+}
+
+// jpegScan is a scan of a progressive image using spectral selection.
+type jpegScan struct {
+	comps  []int
+	ss, se int
+}
+
+// jpegEncoder writes an image in the JPEG format.
+type jpegEncoder struct {
+	w      *bufio.Writer
+	err    error
+	opts   jpegOptions
+	width  int
+	height int
+	quant  [2][64]int // Natural order.
+	comps  []*jpegComponent
+	mcusX  int
+	mcusY  int
+	hmax   int
+	vmax   int
+
+	// Bit writer state.
+	bits  uint32
+	nbits uint
+}
+
+var (
+	errJPEGTooLarge = errors.New("imaging: image is too large to encode as JPEG")
+	errJPEGEmpty    = errors.New("imaging: empty image can't be encoded as JPEG")
+)
+
+// encodeJPEG writes the image to w in the JPEG format. Images with an alpha channel
+// are composited over black, *image.Gray and *image.Gray16 images are written as grayscale.
+func encodeJPEG(w io.Writer, img image.Image, opts jpegOptions) error {
+	b := img.Bounds()
+	if b.Dx() >= 1<<16 || b.Dy() >= 1<<16 {
+		return errJPEGTooLarge
+	}
+	if b.Dx() <= 0 || b.Dy() <= 0 {
+		return errJPEGEmpty
+	}
+
+	e := &jpegEncoder{
+		w:      bufio.NewWriter(w),
+		opts:   opts,
+		width:  b.Dx(),
+		height: b.Dy(),
+	}
+	if opts.progressive {
+		// Progressive scans use the EOB run symbols that are missing from the standard tables.
+		e.opts.optimize = true
+	}
+	e.initQuant()
+
+	gray := false
+	switch img.(type) {
+	case *image.Gray, *image.Gray16:
+		gray = true
+	}
+	e.prepare(img, gray)
+
+	e.writeMarkerHeader()
+	if e.opts.progressive {
+		for _, s := range e.progressiveScans() {
+			e.writeScan(s)
+		}
+	} else {
+		all := make([]int, len(e.comps))
+		for i := range all {
+			all[i] = i
+		}
+		e.writeScan(jpegScan{comps: all, ss: 0, se: 63})
+	}
+	e.writeMarker(0xd9, nil)
+
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+// initQuant scales the base quantization tables by the quality factor
+// the same way the standard library encoder does.
+func (e *jpegEncoder) initQuant() {
+	q := e.opts.quality
+	if q < 1 {
+		q = 1
+	} else if q > 100 {
+		q = 100
+	}
+	var scale int
+	if q < 50 {
+		scale = 5000 / q
+	} else {
+		scale = 200 - q*2
+	}
+	for t := 0; t < 2; t++ {
+		for i := 0; i < 64; i++ {
+			x := (jpegBaseQuant[t][i]*scale + 50) / 100
+			if x < 1 {
+				x = 1
+			} else if x > 255 {
+				x = 255
+			}
+			e.quant[t][i] = x
+		}
+	}
+}
+
+// prepare converts the image to the YCbCr color space (or grayscale), subsamples
+// the chroma and computes the quantized DCT coefficients of all the blocks.
+func (e *jpegEncoder) prepare(img image.Image, gray bool) {
+	if gray {
+		e.comps = []*jpegComponent{{id: 1, h: 1, v: 1, tq: 0}}
+	} else {
+		// The luminance sampling factors relative to the chroma ones.
+		h, v := 2, 2
+		switch e.opts.subsampling {
+		case ChromaSubsampling422:
+			v = 1
+		case ChromaSubsampling444:
+			h, v = 1, 1
+		}
+		e.comps = []*jpegComponent{
+			{id: 1, h: h, v: v, tq: 0},
+			{id: 2, h: 1, v: 1, tq: 1},
+			{id: 3, h: 1, v: 1, tq: 1},
+		}
+	}
+	for _, c := range e.comps {
+		if c.h > e.hmax {
+			e.hmax = c.h
+		}
+		if c.v > e.vmax {
+			e.vmax = c.v
+		}
+	}
+	e.mcusX = (e.width + 8*e.hmax - 1) / (8 * e.hmax)
+	e.mcusY = (e.height + 8*e.vmax - 1) / (8 * e.vmax)
+
+	// Full resolution planes padded to whole MCUs by replicating the edge pixels.
+	pw := e.mcusX * 8 * e.hmax
+	ph := e.mcusY * 8 * e.vmax
+	planes := make([][]float32, len(e.comps))
+	for i := range planes {
+		planes[i] = make([]float32, pw*ph)
+	}
+
+	src := newScanner(img)
+	parallel(0, ph, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			sy := y
+			if sy >= src.h {
+				sy = src.h - 1
+			}
+			src.scan(0, sy, src.w, sy+1, scanLine)
+			for x := 0; x < pw; x++ {
+				sx := x
+				if sx >= src.w {
+					sx = src.w - 1
+				}
+				s := scanLine[sx*4 : sx*4+4 : sx*4+4]
+				// Composite the colors over black like the standard library encoder does.
+				a := float32(s[3]) / 255
+				r := float32(s[0]) * a
+				g := float32(s[1]) * a
+				b := float32(s[2]) * a
+				i := y*pw + x
+				if gray {
+					planes[0][i] = 0.299*r + 0.587*g + 0.114*b
+					continue
+				}
+				planes[0][i] = 0.299*r + 0.587*g + 0.114*b
+				planes[1][i] = -0.168736*r - 0.331264*g + 0.5*b + 128
+				planes[2][i] = 0.5*r - 0.418688*g - 0.081312*b + 128
+			}
+		}
+	})
+
+	for ci, c := range e.comps {
+		sx := e.hmax / c.h
+		sy := e.vmax / c.v
+		c.bw = e.mcusX * c.h
+		c.bh = e.mcusY * c.v
+		c.cw = ((e.width*c.h+e.hmax-1)/e.hmax + 7) / 8
+		c.ch = ((e.height*c.v+e.vmax-1)/e.vmax + 7) / 8
+		c.coef = make([][64]int32, c.bw*c.bh)
+		plane := planes[ci]
+		quant := &e.quant[c.tq]
+
+		parallel(0, c.bh, func(bys <-chan int) {
+			var block [64]float32
+			for by := range bys {
+				for bx := 0; bx < c.bw; bx++ {
+					// Downsample by averaging the sx x sy boxes of the full resolution plane.
+					for y := 0; y < 8; y++ {
+						for x := 0; x < 8; x++ {
+							var sum float32
+							px := (bx*8 + x) * sx
+							py := (by*8 + y) * sy
+							for dy := 0; dy < sy; dy++ {
+								row := plane[(py+dy)*pw:]
+								for dx := 0; dx < sx; dx++ {
+									sum += row[px+dx]
+								}
+							}
+							block[y*8+x] = sum/float32(sx*sy) - 128
+						}
+					}
+					jpegFDCT(&block)
+					out := &c.coef[by*c.bw+bx]
+					for k := 0; k < 64; k++ {
+						n := jpegZigzag[k]
+						out[k] = int32(math.Round(float64(block[n]) / float64(quant[n])))
+					}
+				}
+			}
+		})
+	}
+}
+
+var jpegDCTTable = func() [8][8]float32 {
+	var t [8][8]float32
+	for u := 0; u < 8; u++ {
+		cu := 1.0
+		if u == 0 {
+			cu = 1 / math.Sqrt2
+		}
+		for x := 0; x < 8; x++ {
+			t[u][x] = float32(cu / 2 * math.Cos(float64(2*x+1)*float64(u)*math.Pi/16))
+		}
+	}
+	return t
+}()
+
+// jpegFDCT performs the forward 2D DCT of the block in place.
+func jpegFDCT(block *[64]float32) {
+	var tmp [64]float32
+	for y := 0; y < 8; y++ {
+		for u := 0; u < 8; u++ {
+			var s float32
+			for x := 0; x < 8; x++ {
+				s += jpegDCTTable[u][x] * block[y*8+x]
+			}
+			tmp[y*8+u] = s
+		}
+	}
+	for u := 0; u < 8; u++ {
+		for v := 0; v < 8; v++ {
+			var s float32
+			for y := 0; y < 8; y++ {
+				s += jpegDCTTable[v][y] * tmp[y*8+u]
+			}
+			block[v*8+u] = s
+		}
+	}
+}
+
+// progressiveScans returns the scan script of a progressive image: the DC coefficients
+// of all the components first, then the low and high frequency bands.
+func (e *jpegEncoder) progressiveScans() []jpegScan {
+	if len(e.comps) == 1 {
+		return []jpegScan{
+			{comps: []int{0}, ss: 0, se: 0},
+			{comps: []int{0}, ss: 1, se: 5},
+			{comps: []int{0}, ss: 6, se: 63},
+		}
+	}
+	return []jpegScan{
+		{comps: []int{0, 1, 2}, ss: 0, se: 0},
+		{comps: []int{0}, ss: 1, se: 5},
+		{comps: []int{2}, ss: 1, se: 63},
+		{comps: []int{1}, ss: 1, se: 63},
+		{comps: []int{0}, ss: 6, se: 63},
+	}
+}
+
+func (e *jpegEncoder) writeMarker(marker byte, data []byte) {
+	if e.err != nil {
+		return
+	}
+	e.w.WriteByte(0xff)
+	e.w.WriteByte(marker)
+	if data != nil {
+		n := len(data) + 2
+		e.w.WriteByte(byte(n >> 8))
+		e.w.WriteByte(byte(n))
+		_, e.err = e.w.Write(data)
+	}
+}
+
+// writeMarkerHeader writes the SOI, DQT and SOF markers.
+func (e *jpegEncoder) writeMarkerHeader() {
+	e.writeMarker(0xd8, nil)
+
+	numTables := 1
+	if len(e.comps) > 1 {
+		numTables = 2
+	}
+	dqt := make([]byte, 0, numTables*65)
+	for t := 0; t < numTables; t++ {
+		dqt = append(dqt, byte(t))
+		for k := 0; k < 64; k++ {
+			dqt = append(dqt, byte(e.quant[t][jpegZigzag[k]]))
+		}
+	}
+	e.writeMarker(0xdb, dqt)
+
+	sof := []byte{8, byte(e.height >> 8), byte(e.height), byte(e.width >> 8), byte(e.width), byte(len(e.comps))}
+	for _, c := range e.comps {
+		sof = append(sof, c.id, byte(c.h<<4|c.v), byte(c.tq))
+	}
+	marker := byte(0xc0)
+	if e.opts.progressive {
+		marker = 0xc2
+	}
+	e.writeMarker(marker, sof)
+}
+
+// jpegSymbolSink receives the Huffman symbols and the extra bits of an entropy-coded scan.
+// The table parameter is the table slot: 0, 1 for the DC tables and 2, 3 for the AC tables.
+type jpegSymbolSink interface {
+	emit(table int, symbol byte, bits uint32, nbits uint)
+}
+
+type jpegSymbolCounter struct {
+	freq [4][257]int64
+}
+
+func (c *jpegSymbolCounter) emit(table int, symbol byte, bits uint32, nbits uint) {
+	c.freq[table][symbol]++
+}
+
+type jpegHuffmanCode struct {
+	code uint32
+	size uint
+}
+
+type jpegSymbolWriter struct {
+	e     *jpegEncoder
+	codes [4][256]jpegHuffmanCode
+}
+
+func (s *jpegSymbolWriter) emit(table int, symbol byte, bits uint32, nbits uint) {
+	c := s.codes[table][symbol]
+	s.e.writeBits(c.code, c.size)
+	if nbits > 0 {
+		s.e.writeBits(bits, nbits)
+	}
+}
+
+// writeBits writes the n low bits of the bits value to the entropy-coded segment.
+func (e *jpegEncoder) writeBits(bits uint32, n uint) {
+	e.bits = e.bits<<n | bits&(1<<n-1)
+	e.nbits += n
+	for e.nbits >= 8 {
+		b := byte(e.bits >> (e.nbits - 8))
+		e.w.WriteByte(b)
+		if b == 0xff {
+			e.w.WriteByte(0)
+		}
+		e.nbits -= 8
+	}
+}
+
+// flushBits pads the last byte of the entropy-coded segment with 1 bits.
+func (e *jpegEncoder) flushBits() {
+	if e.nbits > 0 {
+		e.writeBits(0x7f, 8-e.nbits)
+	}
+	e.bits = 0
+	e.nbits = 0
+}
+
+// writeScan writes the Huffman tables, the SOS marker and the entropy-coded data of the scan.
+func (e *jpegEncoder) writeScan(s jpegScan) {
+	// Table slots used by each component of the scan: luminance uses the 0 tables,
+	// chrominance uses the 1 tables.
+	var specs [4]*jpegHuffmanSpec
+	if e.opts.optimize {
+		counter := &jpegSymbolCounter{}
+		e.encodeScan(s, counter)
+		for t := 0; t < 4; t++ {
+			if counter.freq[t] != [257]int64{} {
+				specs[t] = jpegOptimalHuffman(&counter.freq[t])
+			}
+		}
+	} else {
+		specs = [4]*jpegHuffmanSpec{&jpegStdHuffman[0], &jpegStdHuffman[2], &jpegStdHuffman[1], &jpegStdHuffman[3]}
+		if len(e.comps) == 1 {
+			specs[1], specs[3] = nil, nil
+		}
+	}
+
+	sw := &jpegSymbolWriter{e: e}
+	var dht []byte
+	for t, spec := range specs {
+		if spec == nil {
+			continue
+		}
+		class, id := t/2, t%2
+		dht = append(dht, byte(class<<4|id))
+		dht = append(dht, spec.bits[:]...)
+		dht = append(dht, spec.vals...)
+		sw.codes[t] = jpegHuffmanCodes(spec)
+	}
+	e.writeMarker(0xc4, dht)
+
+	sos := []byte{byte(len(s.comps))}
+	for _, ci := range s.comps {
+		c := e.comps[ci]
+		sos = append(sos, c.id, byte(c.tq<<4|c.tq))
+	}
+	sos = append(sos, byte(s.ss), byte(s.se), 0)
+	e.writeMarker(0xda, sos)
+
+	if e.err != nil {
+		return
+	}
+	e.encodeScan(s, sw)
+	e.flushBits()
+}
+
+// encodeScan produces the Huffman symbols of the scan.
+func (e *jpegEncoder) encodeScan(s jpegScan, sink jpegSymbolSink) {
+	var pred [3]int32
+	eobrun := 0
+
+	encodeBlock := func(ci int, block *[64]int32) {
+		tq := e.comps[ci].tq
+		if s.ss == 0 {
+			diff := block[0] - pred[ci]
+			pred[ci] = block[0]
+			bits, n := jpegEncodeValue(diff)
+			sink.emit(tq, byte(n), bits, n)
+		}
+		if s.se == 0 {
+			return
+		}
+
+		start := s.ss
+		if start == 0 {
+			start = 1
+		}
+		run := 0
+		for k := start; k <= s.se; k++ {
+			v := block[k]
+			if v == 0 {
+				run++
+				continue
+			}
+			if eobrun > 0 {
+				e.emitEOBRun(sink, 2+tq, eobrun)
+				eobrun = 0
+			}
+			for run > 15 {
+				sink.emit(2+tq, 0xf0, 0, 0)
+				run -= 16
+			}
+			bits, n := jpegEncodeValue(v)
+			sink.emit(2+tq, byte(run<<4)|byte(n), bits, n)
+			run = 0
+		}
+		if run > 0 {
+			if !e.opts.progressive {
+				sink.emit(2+tq, 0x00, 0, 0)
+				return
+			}
+			eobrun++
+			if eobrun == 0x7fff {
+				e.emitEOBRun(sink, 2+tq, eobrun)
+				eobrun = 0
+			}
+		}
+	}
+
+	if len(s.comps) == 1 {
+		// Non-interleaved scan: the blocks covering the component in raster order.
+		ci := s.comps[0]
+		c := e.comps[ci]
+		for by := 0; by < c.ch; by++ {
+			for bx := 0; bx < c.cw; bx++ {
+				encodeBlock(ci, &c.coef[by*c.bw+bx])
+			}
+		}
+	} else {
+		// Interleaved scan: the blocks of all the components MCU by MCU.
+		for my := 0; my < e.mcusY; my++ {
+			for mx := 0; mx < e.mcusX; mx++ {
+				for _, ci := range s.comps {
+					c := e.comps[ci]
+					for v := 0; v < c.v; v++ {
+						for h := 0; h < c.h; h++ {
+							encodeBlock(ci, &c.coef[(my*c.v+v)*c.bw+mx*c.h+h])
+						}
+					}
+				}
+			}
+		}
+	}
+
+	if eobrun > 0 {
+		e.emitEOBRun(sink, 2+e.comps[s.comps[0]].tq, eobrun)
+	}
+}
+
+// emitEOBRun emits the symbol for a run of blocks ending with zero coefficients.
+func (e *jpegEncoder) emitEOBRun(sink jpegSymbolSink, table int, run int) {
+	n := uint(0)
+	for run>>(n+1) > 0 {
+		n++
+	}
+	sink.emit(table, byte(n<<4), uint32(run)-1<<n, n)
+}
+
+// jpegEncodeValue returns the extra bits and the size category of a coefficient value.
+func jpegEncodeValue(v int32) (uint32, uint) {
+	a := v
+	if a < 0 {
+		a = -a
+		v--
+	}
+	n := uint(0)
+	for a > 0 {
+		n++
+		a >>= 1
+	}
+	return uint32(v) & (1<<n - 1), n
+}
+
+// jpegHuffmanCodes returns the canonical Huffman codes of the table.
+func jpegHuffmanCodes(spec *jpegHuffmanSpec) [256]jpegHuffmanCode {
+	var codes [256]jpegHuffmanCode
+	code := uint32(0)
+	k := 0
+	for size := uint(1); size <= 16; size++ {
+		for i := 0; i < int(spec.bits[size-1]); i++ {
+			codes[spec.vals[k]] = jpegHuffmanCode{code: code, size: size}
+			code++
+			k++
+		}
+		code <<= 1
+	}
+	return codes
+}
+
+// jpegOptimalHuffman builds a Huffman table with code lengths limited to 16 bits
+// for the given symbol frequencies, following the JPEG specification (Annex K.2).
+func jpegOptimalHuffman(freqIn *[257]int64) *jpegHuffmanSpec {
+	freq := *freqIn
+	freq[256] = 1 // Reserve one code point so no code consists of all 1 bits.
+
+	var codesize [257]int
+	var others [257]int
+	for i := range others {
+		others[i] = -1
+	}
+
+	for {
+		c1, c2 := -1, -1
+		var v1, v2 int64 = math.MaxInt64, math.MaxInt64
+		for i := 0; i <= 256; i++ {
+			if freq[i] > 0 && freq[i] <= v1 {
+				v1 = freq[i]
+				c1 = i
+			}
+		}
+		for i := 0; i <= 256; i++ {
+			if freq[i] > 0 && freq[i] <= v2 && i != c1 {
+				v2 = freq[i]
+				c2 = i
+			}
+		}
+		if c2 < 0 {
+			break
+		}
+
+		freq[c1] += freq[c2]
+		freq[c2] = 0
+
+		codesize[c1]++
+		for others[c1] >= 0 {
+			c1 = others[c1]
+			codesize[c1]++
+		}
+		others[c1] = c2
+
+		codesize[c2]++
+		for others[c2] >= 0 {
+			c2 = others[c2]
+			codesize[c2]++
+		}
+	}
+
+	var bits [33]int
+	for i := 0; i <= 256; i++ {
+		if codesize[i] > 0 {
+			bits[codesize[i]]++
+		}
+	}
+
+	for i := 32; i > 16; i-- {
+		for bits[i] > 0 {
+			j := i - 2
+			for bits[j] == 0 {
+				j--
+			}
+			bits[i] -= 2
+			bits[i-1]++
+			bits[j+1] += 2
+			bits[j]--
+		}
+	}
+	i := 16
+	for bits[i] == 0 {
+		i--
+	}
+	bits[i]-- // Remove the reserved code point.
+
+	spec := &jpegHuffmanSpec{}
+	for i := 1; i <= 16; i++ {
+		spec.bits[i-1] = byte(bits[i])
+	}
+	for size := 1; size <= 32; size++ {
+		for sym := 0; sym < 256; sym++ {
+			if codesize[sym] == size {
+				spec.vals = append(spec.vals, byte(sym))
+			}
+		}
+	}
+	return spec
+}
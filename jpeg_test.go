@@ -0,0 +1,203 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"testing"
+)
+
+// meanAbsDiff returns the mean absolute difference of the color channels of two images.
+func meanAbsDiff(img1, img2 *image.NRGBA) float64 {
+	var sum float64
+	for i := range img1.Pix {
+		if i%4 == 3 {
+			continue
+		}
+		sum += float64(absint(int(img1.Pix[i]) - int(img2.Pix[i])))
+	}
+	return sum / float64(len(img1.Pix)/4*3)
+}
+
+// hasMarker reports whether the JPEG data has the given marker before the first scan.
+func hasMarker(data []byte, marker byte) bool {
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xff {
+			return false
+		}
+		if data[i+1] == marker {
+			return true
+		}
+		if data[i+1] == 0xda {
+			return false
+		}
+		i += 2 + (int(data[i+2])<<8 | int(data[i+3]))
+	}
+	return false
+}
+
+func TestEncodeJPEG(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 37, 21))
+	for y := 0; y < 21; y++ {
+		for x := 0; x < 37; x++ {
+			gray.SetGray(x, y, color.Gray{uint8(x*6 + y*3)})
+		}
+	}
+	translucent := image.NewNRGBA(image.Rect(0, 0, 19, 11))
+	for i := range translucent.Pix {
+		translucent.Pix[i] = uint8(i * 7)
+	}
+
+	testCases := []struct {
+		name  string
+		img   image.Image
+		opts  []EncodeOption
+		sof   byte
+		delta float64
+	}{
+		{"optimized", testdataFlowersSmallPNG, []EncodeOption{JPEGOptimizedHuffman(true)}, 0xc0, 0.1},
+		{"progressive", testdataFlowersSmallPNG, []EncodeOption{JPEGProgressive(true)}, 0xc2, 0.1},
+		{"progressive low quality", testdataBranchesPNG, []EncodeOption{JPEGProgressive(true), JPEGQuality(30)}, 0xc2, 0.1},
+		{"gray optimized", gray, []EncodeOption{JPEGOptimizedHuffman(true)}, 0xc0, 0.1},
+		{"gray progressive", gray, []EncodeOption{JPEGProgressive(true)}, 0xc2, 0.1},
+		{"translucent progressive", translucent, []EncodeOption{JPEGProgressive(true)}, 0xc2, 0.1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var want bytes.Buffer
+			if err := Encode(&want, tc.img, JPEG, tc.opts[1:]...); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			var got bytes.Buffer
+			if err := Encode(&got, tc.img, JPEG, tc.opts...); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			if !hasMarker(got.Bytes(), tc.sof) {
+				t.Fatalf("SOF marker %#x not found", tc.sof)
+			}
+
+			// The encoders composite the colors over black.
+			ref := image.NewNRGBA(tc.img.Bounds())
+			draw.Draw(ref, ref.Rect, image.Black, image.Point{}, draw.Src)
+			draw.Draw(ref, ref.Rect, tc.img, ref.Rect.Min, draw.Over)
+
+			wantImg, err := jpeg.Decode(&want)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			gotImg, err := jpeg.Decode(&got)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if _, ok := gotImg.(*image.Gray); ok != (tc.img == image.Image(gray)) {
+				t.Fatalf("got image type %T", gotImg)
+			}
+			// The error must be close to the error of the standard library encoder.
+			gotDiff := meanAbsDiff(Clone(gotImg), ref)
+			wantDiff := meanAbsDiff(Clone(wantImg), ref)
+			if gotDiff > wantDiff+tc.delta {
+				t.Fatalf("got mean difference %.3f want at most %.3f", gotDiff, wantDiff+tc.delta)
+			}
+		})
+	}
+}
+
+func TestEncodeJPEGOptimizedSize(t *testing.T) {
+	for _, img := range []image.Image{testdataFlowersSmallPNG, testdataBranchesPNG} {
+		var std, opt bytes.Buffer
+		if err := Encode(&std, img, JPEG); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		if err := Encode(&opt, img, JPEG, JPEGOptimizedHuffman(true)); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		if opt.Len() >= std.Len() {
+			t.Fatalf("got optimized size %d want less than %d", opt.Len(), std.Len())
+		}
+	}
+}
+
+func TestEncodeJPEGFails(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 0, 5)), JPEG, JPEGProgressive(true)); err == nil {
+		t.Fatalf("expected error for an empty image")
+	}
+	if err := Encode(&buf, image.NewGray(image.Rect(0, 0, 1<<16, 1)), JPEG, JPEGProgressive(true)); err == nil {
+		t.Fatalf("expected error for a large image")
+	}
+}
+
+func TestJPEGOptimalHuffman(t *testing.T) {
+	// Fibonacci frequencies produce the deepest possible tree that must be limited to 16 bits.
+	var freq [257]int64
+	a, b := int64(1), int64(1)
+	for i := 0; i < 30; i++ {
+		freq[i] = a
+		a, b = b, a+b
+	}
+	spec := jpegOptimalHuffman(&freq)
+	if len(spec.vals) != 30 {
+		t.Fatalf("got %d symbols want 30", len(spec.vals))
+	}
+	// The Kraft sum must leave room for the reserved all 1 bits code.
+	var kraft, n int
+	for i, c := range spec.bits {
+		kraft += int(c) << (15 - i)
+		n += int(c)
+	}
+	if n != 30 || kraft >= 1<<16 {
+		t.Fatalf("invalid code lengths %v", spec.bits)
+	}
+}
+
+func TestEncodeJPEGSubsampling(t *testing.T) {
+	// Alternating red and blue columns lose their colors with horizontal chroma subsampling.
+	src := image.NewNRGBA(image.Rect(0, 0, 33, 17))
+	for y := 0; y < 17; y++ {
+		for x := 0; x < 33; x++ {
+			c := color.NRGBA{0xff, 0x00, 0x00, 0xff}
+			if x%2 == 1 {
+				c = color.NRGBA{0x00, 0x00, 0xff, 0xff}
+			}
+			src.SetNRGBA(x, y, c)
+		}
+	}
+
+	testCases := []struct {
+		name  string
+		opts  []EncodeOption
+		ratio image.YCbCrSubsampleRatio
+		sharp bool
+	}{
+		{"default", nil, image.YCbCrSubsampleRatio420, false},
+		{"420", []EncodeOption{JPEGSubsampling(ChromaSubsampling420), JPEGOptimizedHuffman(true)}, image.YCbCrSubsampleRatio420, false},
+		{"422", []EncodeOption{JPEGSubsampling(ChromaSubsampling422)}, image.YCbCrSubsampleRatio422, false},
+		{"444", []EncodeOption{JPEGSubsampling(ChromaSubsampling444)}, image.YCbCrSubsampleRatio444, true},
+		{"444 progressive", []EncodeOption{JPEGSubsampling(ChromaSubsampling444), JPEGProgressive(true)}, image.YCbCrSubsampleRatio444, true},
+		{"422 progressive", []EncodeOption{JPEGSubsampling(ChromaSubsampling422), JPEGProgressive(true)}, image.YCbCrSubsampleRatio422, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, src, JPEG, tc.opts...); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			img, err := jpeg.Decode(&buf)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			ycbcr, ok := img.(*image.YCbCr)
+			if !ok {
+				t.Fatalf("got image type %T want *image.YCbCr", img)
+			}
+			if ycbcr.SubsampleRatio != tc.ratio {
+				t.Fatalf("got subsample ratio %v want %v", ycbcr.SubsampleRatio, tc.ratio)
+			}
+			if d := meanAbsDiff(Clone(img), src); (d < 20) != tc.sharp {
+				t.Fatalf("got mean difference %.3f, sharp colors expected: %v", d, tc.sharp)
+			}
+		})
+	}
+}
@@ -0,0 +1,563 @@
+package imaging
+
+// This file implements a decoder of baseline JPEG images at 1/2, 1/4 and 1/8 of their size.
+// Only the low frequency DCT coefficients of the blocks are used, so the inverse DCT of
+// a block yields 4x4, 2x2 or 1x1 pixels, which is much cheaper than decoding the full image
+// and downscaling it. Progressive, arithmetic-coded, CMYK and RGB images are not supported.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"io"
+	"math"
+)
+
+var (
+	errInvalidJPEG = errors.New("imaging: invalid JPEG data")
+
+	// errJPEGScaleUnsupported is returned by decodeJPEGScaled for the images it can't decode.
+	errJPEGScaleUnsupported = errors.New("imaging: unsupported JPEG image for scaled decoding")
+)
+
+// ThumbnailJPEG reads a JPEG image from r and creates its thumbnail like Thumbnail. Large photos
+// are decoded at 1/2, 1/4 or 1/8 of their size, the smallest one still covering the thumbnail,
+// before the final resampling with the filter. This skips most of the inverse DCT and resizing
+// work of decoding them fully, which typically halves the time. The EXIF orientation is
+// applied. The images that can't be decoded scaled, e.g. progressive JPEGs, and the images of
+// other formats are decoded fully.
+//
+// Example:
+//
+//	thumb, err := imaging.ThumbnailJPEG(file, 200, 200, imaging.Lanczos)
+func ThumbnailJPEG(r io.Reader, width, height int, filter ResampleFilter) (*image.NRGBA, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte("\xff\xd8\xff")) {
+		img, err := DecodeBytes(data, AutoOrientation(true))
+		if err != nil {
+			return nil, err
+		}
+		defer Release(img)
+		return Thumbnail(img, width, height, filter), nil
+	}
+
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	orient := readOrientation(bytes.NewReader(data))
+	w, h := width, height
+	if orient >= orientationTranspose && orient <= orientationRotate90 {
+		w, h = h, w
+	}
+
+	var img image.Image
+	if scale := jpegScale(cfg.Width, cfg.Height, w, h); scale > 1 {
+		img, err = decodeJPEGScaled(data, scale)
+		if err != nil && err != errJPEGScaleUnsupported {
+			return nil, err
+		}
+	}
+	if img == nil {
+		img, err = DecodeBytes(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	img = fixOrientation(img, orient)
+	defer Release(img)
+	return Thumbnail(img, width, height, filter), nil
+}
+
+// jpegScale returns the largest of the scale factors 8, 4 and 2 that still yields an image
+// of at least the thumbnail size, or 1.
+func jpegScale(srcW, srcH, width, height int) int {
+	for scale := 8; scale > 1; scale /= 2 {
+		if (srcW+scale-1)/scale >= width && (srcH+scale-1)/scale >= height {
+			return scale
+		}
+	}
+	return 1
+}
+
+// jpegHuffman is a Huffman table of the scaled decoder.
+type jpegHuffman struct {
+	// minCode, maxCode and valPtr are the smallest and the largest codes of each length
+	// and the index of the symbol of the smallest code. maxCode is -1 if there are no codes.
+	minCode [17]int32
+	maxCode [17]int32
+	valPtr  [17]int32
+	vals    []byte
+
+	// lookup maps the next 8 bits to the length<<8 | symbol of the codes of up to 8 bits, or 0.
+	lookup [256]uint16
+}
+
+func newJPEGHuffman(counts []byte, vals []byte) *jpegHuffman {
+	t := &jpegHuffman{vals: vals}
+	var code, k int32
+	for l := 1; l <= 16; l++ {
+		n := int32(counts[l-1])
+		t.valPtr[l] = k
+		t.minCode[l] = code
+		t.maxCode[l] = -1
+		if n > 0 {
+			t.maxCode[l] = code + n - 1
+		}
+		if l <= 8 {
+			for i := int32(0); i < n && int(k+i) < len(vals) && code+i < 1<<l; i++ {
+				first := (code + i) << (8 - l)
+				for j := int32(0); j < 1<<(8-l); j++ {
+					t.lookup[first+j] = uint16(l)<<8 | uint16(vals[k+i])
+				}
+			}
+		}
+		code = (code + n) << 1
+		k += n
+	}
+	return t
+}
+
+// jpegBitReader reads the bits of the entropy-coded data, removing the stuffed zero bytes.
+type jpegBitReader struct {
+	data []byte
+	pos  int
+	bits uint32
+	n    uint
+}
+
+// fill reads bytes until there are at least 16 bits. At a marker it supplies zero bits.
+func (r *jpegBitReader) fill() {
+	for r.n <= 24 {
+		var b byte
+		if r.pos < len(r.data) {
+			b = r.data[r.pos]
+			if b != 0xff {
+				r.pos++
+			} else if r.pos+1 < len(r.data) && r.data[r.pos+1] == 0 {
+				r.pos += 2
+			} else {
+				// A marker, it's left for the caller.
+				b = 0
+			}
+		}
+		r.bits |= uint32(b) << (24 - r.n)
+		r.n += 8
+	}
+}
+
+// readBits returns the next n bits, n <= 16.
+func (r *jpegBitReader) readBits(n uint) int32 {
+	if n == 0 {
+		return 0
+	}
+	if r.n < n {
+		r.fill()
+	}
+	v := int32(r.bits >> (32 - n))
+	r.bits <<= n
+	r.n -= n
+	return v
+}
+
+// decode returns the next Huffman-coded symbol.
+func (r *jpegBitReader) decode(t *jpegHuffman) (byte, error) {
+	if r.n < 16 {
+		r.fill()
+	}
+	if e := t.lookup[r.bits>>24]; e != 0 {
+		r.bits <<= e >> 8
+		r.n -= uint(e >> 8)
+		return byte(e), nil
+	}
+	var code int32
+	for l := 1; l <= 16; l++ {
+		code = code<<1 | int32(r.bits>>31)
+		r.bits <<= 1
+		r.n--
+		if code <= t.maxCode[l] {
+			i := t.valPtr[l] + code - t.minCode[l]
+			if int(i) >= len(t.vals) {
+				return 0, errInvalidJPEG
+			}
+			return t.vals[i], nil
+		}
+	}
+	return 0, errInvalidJPEG
+}
+
+// receiveExtend reads an s-bit value and extends its sign.
+func (r *jpegBitReader) receiveExtend(s byte) int32 {
+	v := r.readBits(uint(s))
+	if s > 0 && v < 1<<(s-1) {
+		v += -1<<s + 1
+	}
+	return v
+}
+
+// restart skips the RST marker and resets the bit buffer.
+func (r *jpegBitReader) restart() error {
+	r.bits, r.n = 0, 0
+	for r.pos < len(r.data) && r.data[r.pos] != 0xff {
+		// Padding bits and bytes before the marker.
+		r.pos++
+	}
+	for r.pos < len(r.data) && r.data[r.pos] == 0xff {
+		r.pos++
+	}
+	if r.pos >= len(r.data) || r.data[r.pos] < 0xd0 || r.data[r.pos] > 0xd7 {
+		return errInvalidJPEG
+	}
+	r.pos++
+	return nil
+}
+
+// jpegScaledComponent is a color component of the image decoded by decodeJPEGScaled.
+type jpegScaledComponent struct {
+	id     byte
+	h, v   int
+	nx, ny int // The size of the decoded blocks.
+	tq     int
+	dc, ac *jpegHuffman
+	pred   int32
+	pix    []uint8
+	stride int
+}
+
+// jpegIDCTCos are the cosine tables of the scaled inverse DCT of 1, 2, 4 and 8 pixels,
+// jpegIDCTCos[n][x*n+u] = C(u)*A(u)*cos((2x+1)uπ/2n) where C(0) = 1/√2 and C(u) = 1 otherwise.
+// A(u) is the attenuation of the frequency u by averaging 8/n pixels, so a pixel is the mean
+// of the 8/n pixels of the full inverse DCT of the low frequency coefficients.
+var jpegIDCTCos = func() (tabs [9][]float32) {
+	for n := 1; n <= 8; n *= 2 {
+		tab := make([]float32, n*n)
+		s := float64(8 / n)
+		for x := 0; x < n; x++ {
+			for u := 0; u < n; u++ {
+				c := math.Cos(float64((2*x+1)*u) * math.Pi / float64(2*n))
+				if u == 0 {
+					c /= math.Sqrt2
+				} else {
+					c *= math.Sin(float64(u)*s*math.Pi/16) / (s * math.Sin(float64(u)*math.Pi/16))
+				}
+				tab[x*n+u] = float32(c)
+			}
+		}
+		tabs[n] = tab
+	}
+	return tabs
+}()
+
+// jpegScaledIDCT computes the nx by ny pixels of a block from the nx by ny lowest frequency
+// coefficients and stores them in dst.
+func jpegScaledIDCT(coef *[64]float32, nx, ny int, dst []uint8, stride int) {
+	if nx == 1 && ny == 1 {
+		dst[0] = clamp(float64(coef[0]/8 + 128))
+		return
+	}
+	cosX, cosY := jpegIDCTCos[nx], jpegIDCTCos[ny]
+	var tmp [64]float32
+	for v := 0; v < ny; v++ {
+		for x := 0; x < nx; x++ {
+			var s float32
+			for u := 0; u < nx; u++ {
+				s += cosX[x*nx+u] * coef[v*8+u]
+			}
+			tmp[v*nx+x] = s
+		}
+	}
+	for y := 0; y < ny; y++ {
+		for x := 0; x < nx; x++ {
+			var s float32
+			for v := 0; v < ny; v++ {
+				s += cosY[y*ny+v] * tmp[v*nx+x]
+			}
+			dst[y*stride+x] = clamp(float64(s/4 + 128))
+		}
+	}
+}
+
+// decodeJPEGScaled decodes the baseline JPEG image in data at 1/scale of its size, the scale
+// is 2, 4 or 8. It returns errJPEGScaleUnsupported for the images it can't decode.
+func decodeJPEGScaled(data []byte, scale int) (image.Image, error) {
+	var (
+		quant          [4][64]float32
+		dcTabs, acTabs [4]*jpegHuffman
+		comps          []*jpegScaledComponent
+		width, height  int
+		restartInt     int
+		adobeRGB       bool
+	)
+	be := binary.BigEndian
+	pos := 2
+	for {
+		if pos >= len(data) || data[pos] != 0xff {
+			return nil, errInvalidJPEG
+		}
+		for pos < len(data) && data[pos] == 0xff {
+			pos++
+		}
+		if pos+2 >= len(data) {
+			return nil, errInvalidJPEG
+		}
+		marker := data[pos]
+		n := int(be.Uint16(data[pos+1:]))
+		if n < 2 || pos+1+n > len(data) {
+			return nil, errInvalidJPEG
+		}
+		seg := data[pos+3 : pos+1+n]
+		pos += 1 + n
+
+		switch {
+		case marker == 0xdb: // DQT
+			for len(seg) > 0 {
+				pq, tq := seg[0]>>4, int(seg[0]&15)
+				size := 64
+				if pq != 0 {
+					size = 128
+				}
+				if tq > 3 || len(seg) < 1+size {
+					return nil, errInvalidJPEG
+				}
+				for k := 0; k < 64; k++ {
+					if pq != 0 {
+						quant[tq][k] = float32(be.Uint16(seg[1+2*k:]))
+					} else {
+						quant[tq][k] = float32(seg[1+k])
+					}
+				}
+				seg = seg[1+size:]
+			}
+
+		case marker == 0xc0 || marker == 0xc1: // SOF0, SOF1
+			if len(seg) < 6 || seg[0] != 8 {
+				return nil, errJPEGScaleUnsupported
+			}
+			height, width = int(be.Uint16(seg[1:])), int(be.Uint16(seg[3:]))
+			nf := int(seg[5])
+			if height == 0 || width == 0 || (nf != 1 && nf != 3) {
+				return nil, errJPEGScaleUnsupported
+			}
+			if len(seg) < 6+3*nf {
+				return nil, errInvalidJPEG
+			}
+			for i := 0; i < nf; i++ {
+				c := seg[6+3*i:]
+				comp := &jpegScaledComponent{id: c[0], h: int(c[1] >> 4), v: int(c[1] & 15), tq: int(c[2])}
+				if comp.h < 1 || comp.h > 4 || comp.v < 1 || comp.v > 4 || comp.tq > 3 {
+					return nil, errInvalidJPEG
+				}
+				comps = append(comps, comp)
+			}
+
+		case marker >= 0xc2 && marker <= 0xcf && marker != 0xc4 && marker != 0xc8 && marker != 0xcc:
+			// Progressive, lossless, hierarchical and arithmetic-coded images.
+			return nil, errJPEGScaleUnsupported
+
+		case marker == 0xc4: // DHT
+			for len(seg) > 0 {
+				if len(seg) < 17 {
+					return nil, errInvalidJPEG
+				}
+				tc, th := seg[0]>>4, int(seg[0]&15)
+				total := 0
+				for _, c := range seg[1:17] {
+					total += int(c)
+				}
+				if tc > 1 || th > 3 || total > 256 || len(seg) < 17+total {
+					return nil, errInvalidJPEG
+				}
+				t := newJPEGHuffman(seg[1:17], seg[17:17+total])
+				if tc == 0 {
+					dcTabs[th] = t
+				} else {
+					acTabs[th] = t
+				}
+				seg = seg[17+total:]
+			}
+
+		case marker == 0xdd: // DRI
+			if len(seg) < 2 {
+				return nil, errInvalidJPEG
+			}
+			restartInt = int(be.Uint16(seg))
+
+		case marker == 0xee: // APP14
+			if len(seg) >= 12 && string(seg[:5]) == "Adobe" && seg[11] == 0 {
+				adobeRGB = true
+			}
+
+		case marker == 0xda: // SOS
+			if comps == nil || len(seg) < 1 || len(seg) < 1+2*int(seg[0]) {
+				return nil, errInvalidJPEG
+			}
+			if int(seg[0]) != len(comps) {
+				// Non-interleaved multi-scan images.
+				return nil, errJPEGScaleUnsupported
+			}
+			if len(comps) == 3 && (adobeRGB || (comps[0].id == 'R' && comps[1].id == 'G' && comps[2].id == 'B')) {
+				return nil, errJPEGScaleUnsupported
+			}
+			// Each selector picks a distinct component, so all of them get the tables.
+			for _, c := range comps {
+				c.dc, c.ac = nil, nil
+			}
+			for i := range comps {
+				sel := seg[1+2*i:]
+				var comp *jpegScaledComponent
+				for _, c := range comps {
+					if c.id == sel[0] {
+						comp = c
+					}
+				}
+				if comp == nil || comp.dc != nil || dcTabs[sel[1]>>4&3] == nil || acTabs[sel[1]&3] == nil {
+					return nil, errInvalidJPEG
+				}
+				comp.dc, comp.ac = dcTabs[sel[1]>>4&3], acTabs[sel[1]&3]
+			}
+			return decodeJPEGScan(data[pos:], comps, &quant, width, height, restartInt, scale)
+
+		case marker == 0xd9: // EOI
+			return nil, errInvalidJPEG
+		}
+	}
+}
+
+// decodeJPEGScan decodes the entropy-coded data of the interleaved scan of all the components.
+func decodeJPEGScan(data []byte, comps []*jpegScaledComponent, quant *[4][64]float32, width, height, restartInt, scale int) (image.Image, error) {
+	if len(comps) == 1 {
+		// A non-interleaved scan has a block per MCU whatever the sampling factors.
+		comps[0].h, comps[0].v = 1, 1
+	}
+	hmax, vmax := 1, 1
+	for _, c := range comps {
+		hmax = max(hmax, c.h)
+		vmax = max(vmax, c.v)
+	}
+	mcusX := (width + 8*hmax - 1) / (8 * hmax)
+	mcusY := (height + 8*vmax - 1) / (8 * vmax)
+	// Every block takes at least 2 bits, the codes of the DC difference and the end of block,
+	// so the size of the truncated data is rejected before allocating the components.
+	blocks := 0
+	for _, c := range comps {
+		// The scaled blocks have 1, 2, 4 or 8 pixels, so the subsampling ratios are powers of two.
+		if rh, rv := hmax/c.h, vmax/c.v; hmax%c.h != 0 || vmax%c.v != 0 || rh&(rh-1) != 0 || rv&(rv-1) != 0 {
+			return nil, errJPEGScaleUnsupported
+		}
+		blocks += c.h * c.v
+	}
+	if int64(mcusX)*int64(mcusY)*int64(blocks) > 4*int64(len(data)) {
+		return nil, errInvalidJPEG
+	}
+	for _, c := range comps {
+		// The subsampled components are decoded at the resolution of the image if possible.
+		c.nx = min(8, 8*hmax/(c.h*scale))
+		c.ny = min(8, 8*vmax/(c.v*scale))
+		c.stride = mcusX * c.h * c.nx
+		c.pix = make([]uint8, c.stride*mcusY*c.v*c.ny)
+	}
+
+	r := &jpegBitReader{data: data}
+	var coef [64]float32
+	mcus := 0
+	for my := 0; my < mcusY; my++ {
+		for mx := 0; mx < mcusX; mx++ {
+			if restartInt > 0 && mcus > 0 && mcus%restartInt == 0 {
+				if err := r.restart(); err != nil {
+					return nil, err
+				}
+				for _, c := range comps {
+					c.pred = 0
+				}
+			}
+			mcus++
+			for _, c := range comps {
+				q := &quant[c.tq]
+				for by := 0; by < c.v; by++ {
+					for bx := 0; bx < c.h; bx++ {
+						t, err := r.decode(c.dc)
+						if err != nil {
+							return nil, err
+						}
+						if t > 11 {
+							return nil, errInvalidJPEG
+						}
+						c.pred += r.receiveExtend(t)
+						coef[0] = float32(c.pred) * q[0]
+						for k := 1; k < 64; k++ {
+							rs, err := r.decode(c.ac)
+							if err != nil {
+								return nil, err
+							}
+							run, size := int(rs>>4), rs&15
+							if size == 0 {
+								if run != 15 {
+									break
+								}
+								k += 15
+								continue
+							}
+							k += run
+							if k > 63 {
+								return nil, errInvalidJPEG
+							}
+							v := r.receiveExtend(size)
+							if i := jpegZigzag[k]; i/8 < c.ny && i%8 < c.nx {
+								coef[i] = float32(v) * q[k]
+							}
+						}
+						x := (mx*c.h + bx) * c.nx
+						y := (my*c.v + by) * c.ny
+						jpegScaledIDCT(&coef, c.nx, c.ny, c.pix[y*c.stride+x:], c.stride)
+						for v := 0; v < c.ny; v++ {
+							clear(coef[v*8 : v*8+c.nx])
+						}
+					}
+				}
+			}
+		}
+	}
+
+	rect := image.Rect(0, 0, (width+scale-1)/scale, (height+scale-1)/scale)
+	if len(comps) == 1 {
+		gray := image.NewGray(rect)
+		for y := 0; y < rect.Dy(); y++ {
+			copy(gray.Pix[y*gray.Stride:y*gray.Stride+rect.Dx()], comps[0].pix[y*comps[0].stride:])
+		}
+		return gray, nil
+	}
+
+	if comps[1].h != 1 || comps[1].v != 1 || comps[2].h != 1 || comps[2].v != 1 {
+		return nil, errJPEGScaleUnsupported
+	}
+	ratios := map[[2]int]image.YCbCrSubsampleRatio{
+		{1, 1}: image.YCbCrSubsampleRatio444,
+		{2, 1}: image.YCbCrSubsampleRatio422,
+		{2, 2}: image.YCbCrSubsampleRatio420,
+		{1, 2}: image.YCbCrSubsampleRatio440,
+		{4, 1}: image.YCbCrSubsampleRatio411,
+		{4, 2}: image.YCbCrSubsampleRatio410,
+	}
+	// The subsampling of the chroma relative to the decoded luma.
+	rx := comps[0].h * comps[0].nx / (comps[1].h * comps[1].nx)
+	ry := comps[0].v * comps[0].ny / (comps[1].v * comps[1].ny)
+	ratio, ok := ratios[[2]int{rx, ry}]
+	if !ok {
+		return nil, errJPEGScaleUnsupported
+	}
+	img := image.NewYCbCr(rect, ratio)
+	for y := 0; y < rect.Dy(); y++ {
+		copy(img.Y[y*img.YStride:y*img.YStride+rect.Dx()], comps[0].pix[y*comps[0].stride:])
+	}
+	cw, ch := img.CStride, len(img.Cb)/img.CStride
+	for y := 0; y < ch; y++ {
+		copy(img.Cb[y*img.CStride:y*img.CStride+cw], comps[1].pix[y*comps[1].stride:])
+		copy(img.Cr[y*img.CStride:y*img.CStride+cw], comps[2].pix[y*comps[2].stride:])
+	}
+	return img, nil
+}
@@ -0,0 +1,249 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"testing"
+)
+
+// smoothJPEGSource is an image without fine details, which are lost by the scaled decoding.
+func smoothJPEGSource() *image.NRGBA {
+	return Blur(testdataBranchesPNG, 3)
+}
+
+func TestDecodeJPEGScaled(t *testing.T) {
+	src := smoothJPEGSource()
+	gray := image.NewGray(src.Rect)
+	draw.Draw(gray, gray.Rect, src, image.Point{}, draw.Src)
+	testCases := []struct {
+		name string
+		img  image.Image
+		opts []EncodeOption
+	}{
+		{"420", src, nil},
+		{"422", src, []EncodeOption{JPEGSubsampling(ChromaSubsampling422)}},
+		{"444", src, []EncodeOption{JPEGSubsampling(ChromaSubsampling444)}},
+		{"optimized", src, []EncodeOption{JPEGOptimizedHuffman(true)}},
+		{"gray", gray, nil},
+		{"odd size", Crop(src, image.Rect(0, 0, 333, 211)), nil},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, tc.img, JPEG, tc.opts...); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			full, err := jpeg.Decode(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			for _, scale := range []int{2, 4, 8} {
+				got, err := decodeJPEGScaled(buf.Bytes(), scale)
+				if err != nil {
+					t.Fatalf("decodeJPEGScaled(%d): %v", scale, err)
+				}
+				b := full.Bounds()
+				w, h := (b.Dx()+scale-1)/scale, (b.Dy()+scale-1)/scale
+				if got.Bounds() != image.Rect(0, 0, w, h) {
+					t.Fatalf("decodeJPEGScaled(%d): got bounds %v want %vx%d", scale, got.Bounds(), w, h)
+				}
+				if _, ok := got.(*image.Gray); ok != (tc.name == "gray") {
+					t.Fatalf("decodeJPEGScaled(%d): got image %T", scale, got)
+				}
+				// The last blocks of the odd sizes are partial.
+				r := image.Rect(0, 0, b.Dx()/scale, b.Dy()/scale)
+				want := Resize(Crop(full, image.Rect(0, 0, r.Dx()*scale, r.Dy()*scale)), r.Dx(), r.Dy(), Box)
+				if d := meanAbsDiff(Crop(got, r), want); d > 1 {
+					t.Fatalf("decodeJPEGScaled(%d): got mean difference %v", scale, d)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeJPEGScaledRestart(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testdataFlowersSmallPNG, JPEG); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	data := buf.Bytes()
+	// Insert a DRI marker without restarts, the decoder fails at the first missing RST marker.
+	dri := append([]byte{0xff, 0xd8, 0xff, 0xdd, 0x00, 0x04, 0x00, 0x01}, data[2:]...)
+	if _, err := decodeJPEGScaled(dri, 4); err != errInvalidJPEG {
+		t.Fatalf("got error %v want %v", err, errInvalidJPEG)
+	}
+}
+
+func TestDecodeJPEGScaledUnsupported(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testdataFlowersSmallPNG, JPEG, JPEGProgressive(true)); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if _, err := decodeJPEGScaled(buf.Bytes(), 2); err != errJPEGScaleUnsupported {
+		t.Fatalf("got error %v want %v", err, errJPEGScaleUnsupported)
+	}
+	for _, data := range []string{"\xff\xd8", "\xff\xd8\xff\xd9", "\xff\xd8\x00\x00\x00"} {
+		if _, err := decodeJPEGScaled([]byte(data), 2); err != errInvalidJPEG {
+			t.Fatalf("decodeJPEGScaled(%q): got error %v want %v", data, err, errInvalidJPEG)
+		}
+	}
+}
+
+func TestDecodeJPEGScaledSelectors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testdataFlowersSmallPNG, JPEG); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	data := buf.Bytes()
+	sos := bytes.Index(data, []byte{0xff, 0xda})
+	if sos < 0 || data[sos+4] != 3 {
+		t.Fatal("no SOS marker of 3 components")
+	}
+	// The second selector picks the first component again, the second one has no tables.
+	data[sos+7] = data[sos+5]
+	if _, err := decodeJPEGScaled(data, 2); err != errInvalidJPEG {
+		t.Fatalf("got error %v want %v", err, errInvalidJPEG)
+	}
+}
+
+func FuzzDecodeJPEGScaled(f *testing.F) {
+	for _, opts := range [][]EncodeOption{
+		nil,
+		{JPEGSubsampling(ChromaSubsampling444)},
+		{JPEGOptimizedHuffman(true)},
+	} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, Resize(testdataFlowersSmallPNG, 40, 0, Box), JPEG, opts...); err != nil {
+			f.Fatalf("Encode: %v", err)
+		}
+		f.Add(buf.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// The images decoded by the standard decoder can be large, skip them.
+		if cfg, err := jpeg.DecodeConfig(bytes.NewReader(data)); err == nil && cfg.Width*cfg.Height > 1<<20 {
+			return
+		}
+		for _, scale := range []int{2, 4, 8} {
+			decodeJPEGScaled(data, scale)
+		}
+	})
+}
+
+func TestJPEGScale(t *testing.T) {
+	testCases := []struct {
+		srcW, srcH, w, h, want int
+	}{
+		{4000, 3000, 200, 200, 8},
+		{4000, 3000, 600, 400, 4},
+		{4000, 3000, 1001, 400, 2},
+		{4000, 3000, 2001, 400, 1},
+		{4001, 3001, 501, 376, 8},
+		{100, 100, 100, 100, 1},
+	}
+	for _, tc := range testCases {
+		if got := jpegScale(tc.srcW, tc.srcH, tc.w, tc.h); got != tc.want {
+			t.Fatalf("jpegScale(%d, %d, %d, %d): got %d want %d", tc.srcW, tc.srcH, tc.w, tc.h, got, tc.want)
+		}
+	}
+}
+
+func TestThumbnailJPEG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, smoothJPEGSource(), JPEG, JPEGQuality(95)); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	full, err := Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	for _, size := range [][2]int{{45, 30}, {40, 40}, {100, 60}, {150, 150}, {400, 300}} {
+		got, err := ThumbnailJPEG(bytes.NewReader(buf.Bytes()), size[0], size[1], Lanczos)
+		if err != nil {
+			t.Fatalf("ThumbnailJPEG: %v", err)
+		}
+		want := Thumbnail(full, size[0], size[1], Lanczos)
+		if got.Bounds() != want.Bounds() {
+			t.Fatalf("got bounds %v want %v", got.Bounds(), want.Bounds())
+		}
+		if d := meanAbsDiff(got, want); d > 3 {
+			t.Fatalf("%v: got mean difference %v", size, d)
+		}
+	}
+
+	// The progressive and PNG images are decoded fully.
+	for _, opts := range [][]EncodeOption{{JPEGProgressive(true)}, nil} {
+		format := JPEG
+		if opts == nil {
+			format = PNG
+		}
+		buf.Reset()
+		if err := Encode(&buf, testdataBranchesPNG, format, opts...); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		img, err := Decode(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		got, err := ThumbnailJPEG(bytes.NewReader(buf.Bytes()), 60, 40, Linear)
+		if err != nil {
+			t.Fatalf("ThumbnailJPEG: %v", err)
+		}
+		if !compareNRGBA(got, Thumbnail(img, 60, 40, Linear), 0) {
+			t.Fatalf("%v: the image isn't decoded fully", format)
+		}
+	}
+}
+
+func TestThumbnailJPEGOrientation(t *testing.T) {
+	for _, i := range []int{1, 5, 6} {
+		filename := "testdata/orientation_" + string(rune('0'+i)) + ".jpg"
+		file, err := fs.Open(filename)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		got, err := ThumbnailJPEG(file, 20, 10, Lanczos)
+		file.Close()
+		if err != nil {
+			t.Fatalf("ThumbnailJPEG(%q): %v", filename, err)
+		}
+		img, err := Open(filename, AutoOrientation(true))
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		want := Thumbnail(img, 20, 10, Lanczos)
+		if d := meanAbsDiff(got, want); d > 8 {
+			t.Fatalf("ThumbnailJPEG(%q): got mean difference %v", filename, d)
+		}
+	}
+}
+
+func TestThumbnailJPEGFails(t *testing.T) {
+	if _, err := ThumbnailJPEG(bytes.NewReader([]byte("not an image")), 10, 10, Lanczos); err != image.ErrFormat {
+		t.Fatalf("got error %v want %v", err, image.ErrFormat)
+	}
+	if _, err := ThumbnailJPEG(bytes.NewReader([]byte("\xff\xd8\xff\xd9")), 10, 10, Lanczos); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func BenchmarkThumbnailJPEG(b *testing.B) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, Resize(testdataBranchesPNG, 2400, 1600, Linear), JPEG); err != nil {
+		b.Fatalf("Encode: %v", err)
+	}
+	b.Run("Scaled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ThumbnailJPEG(bytes.NewReader(buf.Bytes()), 200, 200, Lanczos)
+		}
+	})
+	b.Run("Full", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			img, _ := Decode(bytes.NewReader(buf.Bytes()))
+			Thumbnail(img, 200, 200, Lanczos)
+		}
+	})
+}
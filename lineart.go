@@ -0,0 +1,135 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// ResizeLineArt resizes the black and white line art, e.g. the scanned diagrams, drawings
+// and text, to the specified width and height and returns the black and white image,
+// instead of the gray and blurred lines of Resize. If one of width or height is 0, the image
+// aspect ratio is preserved.
+//
+// Each pixel of the result is black or white depending on the fraction of its area covered
+// by the ink of the source, measured in linear light, so the ink of the gray and antialiased
+// scans counts by the light it absorbs. The strokes, darker than the pixels on both sides,
+// stay black from a fifth of the pixel covered and keep continuous. The other pixels, e.g.
+// of the hatching and of the dithered halftones of the 1-bit scans, are thresholded with
+// the Floyd-Steinberg error diffusion, so the areas keep their tone. The transparent pixels
+// are white.
+//
+// Example:
+//
+//	scan, _ := imaging.Open("schematic.png")
+//	dstImage := imaging.ResizeLineArt(scan, scan.Bounds().Dx()/4, 0)
+func ResizeLineArt(img image.Image, width, height int) *image.NRGBA {
+	src := newScanner(img)
+	dstW, dstH, ok := resizeSize(src.w, src.h, width, height)
+	if !ok {
+		return &image.NRGBA{}
+	}
+
+	// The ink coverage of the source pixels.
+	ink := make([]float64, src.w*src.h)
+	parallel(0, src.h, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			for x := 0; x < src.w; x++ {
+				s := scanLine[x*4 : x*4+4 : x*4+4]
+				a := float64(s[3]) / 255
+				lum := linearizeSRGB((0.299*float64(s[0]) + 0.587*float64(s[1]) + 0.114*float64(s[2])) / 255)
+				ink[y*src.w+x] = a * (1 - lum)
+			}
+		}
+	})
+
+	// The coverage of the destination pixels, averaging the rows and then the columns.
+	wx, wy := areaWeights(dstW, src.w), areaWeights(dstH, src.h)
+	rows := make([]float64, dstW*src.h)
+	parallel(0, src.h, func(ys <-chan int) {
+		for y := range ys {
+			for x, ws := range wx {
+				var sum float64
+				for _, w := range ws {
+					sum += ink[y*src.w+w.index] * w.weight
+				}
+				rows[y*dstW+x] = sum
+			}
+		}
+	})
+	cov := make([]float64, dstW*dstH)
+	parallel(0, dstH, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < dstW; x++ {
+				var sum float64
+				for _, w := range wy[y] {
+					sum += rows[w.index*dstW+x] * w.weight
+				}
+				cov[y*dstW+x] = sum
+			}
+		}
+	})
+
+	// The pixels outside of the image repeat the edges, so the edges aren't strokes.
+	at := func(x, y int) float64 {
+		return cov[clampint(y, 0, dstH-1)*dstW+clampint(x, 0, dstW-1)]
+	}
+	stroke := func(x, y int) bool {
+		c := at(x, y)
+		for _, d := range [2]image.Point{{1, 0}, {0, 1}} {
+			p, n := at(x-d.X, y-d.Y), at(x+d.X, y+d.Y)
+			if c >= math.Max(p, n) && c-math.Min(p, n) >= 0.2 {
+				return true
+			}
+		}
+		return false
+	}
+
+	dst := newNRGBA(image.Rect(0, 0, dstW, dstH))
+	errs := make([]float64, dstW*dstH)
+	for y := 0; y < dstH; y++ {
+		for x := 0; x < dstW; x++ {
+			i := y*dstW + x
+			v := uint8(0xff)
+			if stroke(x, y) {
+				v = 0
+			} else {
+				c := cov[i] + errs[i]
+				if c >= 0.5 {
+					v = 0
+					c--
+				}
+				for _, k := range diffusionKernels[DitherFloydSteinberg] {
+					if nx, ny := x+k.dx, y+k.dy; nx >= 0 && nx < dstW && ny < dstH {
+						errs[ny*dstW+nx] += c * k.w
+					}
+				}
+			}
+			d := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4 : y*dst.Stride+x*4+4]
+			d[0], d[1], d[2], d[3] = v, v, v, 0xff
+		}
+	}
+	return dst
+}
+
+type areaWeight struct {
+	index  int
+	weight float64
+}
+
+// areaWeights returns the source pixels covered by each destination pixel with the
+// fractions of the destination pixel they cover.
+func areaWeights(dstSize, srcSize int) [][]areaWeight {
+	scale := float64(srcSize) / float64(dstSize)
+	weights := make([][]areaWeight, dstSize)
+	for i := range weights {
+		lo, hi := float64(i)*scale, float64(i+1)*scale
+		for j := int(lo); j < srcSize && float64(j) < hi; j++ {
+			if w := math.Min(hi, float64(j+1)) - math.Max(lo, float64(j)); w > 0 {
+				weights[i] = append(weights[i], areaWeight{j, w / scale})
+			}
+		}
+	}
+	return weights
+}
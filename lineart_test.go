@@ -0,0 +1,91 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestResizeLineArt(t *testing.T) {
+	// The vertical lines of 1 pixel every 8 pixels.
+	src := New(64, 64, color.White)
+	for y := 0; y < 64; y++ {
+		for x := 3; x < 64; x += 8 {
+			src.SetNRGBA(x, y, color.NRGBA{0, 0, 0, 255})
+		}
+	}
+	got := ResizeLineArt(src, 16, 0)
+	if got.Rect != image.Rect(0, 0, 16, 16) {
+		t.Fatalf("got bounds %v want 16x16", got.Rect)
+	}
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			want := uint8(0xff)
+			if x%2 == 0 {
+				want = 0
+			}
+			if c := got.NRGBAAt(x, y); c != (color.NRGBA{want, want, want, 0xff}) {
+				t.Fatalf("got pixel (%d, %d) %v want %d", x, y, c, want)
+			}
+		}
+	}
+	// Resize blurs the lines to gray.
+	if c := Resize(src, 16, 0, Box).NRGBAAt(0, 0); c.R < 0x80 {
+		t.Fatalf("got pixel %v of Resize want light gray", c)
+	}
+}
+
+func TestResizeLineArtHalftone(t *testing.T) {
+	// The checkerboard of a 1-bit halftone keeps its tone.
+	src := New(64, 64, color.White)
+	for y := 0; y < 64; y++ {
+		for x := y % 2; x < 64; x += 2 {
+			src.SetNRGBA(x, y, color.NRGBA{0, 0, 0, 255})
+		}
+	}
+	got := ResizeLineArt(src, 16, 16)
+	black := 0
+	for i := 0; i < len(got.Pix); i += 4 {
+		switch got.Pix[i] {
+		case 0:
+			black++
+		case 0xff:
+		default:
+			t.Fatalf("got gray %d want black and white", got.Pix[i])
+		}
+	}
+	if black < 16*16*4/10 || black > 16*16*6/10 {
+		t.Fatalf("got %d black pixels of %d want about a half", black, 16*16)
+	}
+}
+
+func TestResizeLineArtUniform(t *testing.T) {
+	testCases := []struct {
+		name string
+		src  image.Image
+		want uint8
+	}{
+		{"white", New(30, 20, color.White), 0xff},
+		{"black", New(30, 20, color.Black), 0},
+		{"transparent", New(30, 20, color.Transparent), 0xff},
+		{"dark gray", New(30, 20, color.NRGBA{0x40, 0x40, 0x40, 0xff}), 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ResizeLineArt(tc.src, 7, 5)
+			if !compareNRGBA(got, New(7, 5, color.NRGBA{tc.want, tc.want, tc.want, 0xff}), 0) {
+				t.Fatalf("got %v want %d", got.Pix, tc.want)
+			}
+		})
+	}
+	if got := ResizeLineArt(New(30, 20, color.White), 0, 0); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v want empty image", got.Rect)
+	}
+}
+
+func BenchmarkResizeLineArt(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ResizeLineArt(testdataBranchesJPG, 64, 0)
+	}
+}
@@ -0,0 +1,259 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// LowPoly renders the image as a low-poly mosaic: it places the given number of points in
+// the image, concentrated along the edges, triangulates them with the Delaunay triangulation
+// and fills each triangle with the mean color of its pixels. The corners and evenly spaced
+// points of the image boundary are added, so the triangles cover the whole image. Fewer
+// points give larger triangles, typically 200 to 5000 points. The points are placed
+// pseudo-randomly with a fixed seed, so the result is reproducible.
+// The points <= 0 return a copy of the image.
+//
+// Example:
+//
+//	dstImage := imaging.LowPoly(srcImage, 1000)
+func LowPoly(img image.Image, points int) *image.NRGBA {
+	if points <= 0 {
+		return Clone(img)
+	}
+	src := Clone(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	if w == 0 || h == 0 {
+		return src
+	}
+
+	pts := lowPolyPoints(src, points)
+	tris := delaunay(pts)
+
+	// Assign each pixel center to the triangle containing it.
+	owner := make([]int32, w*h)
+	for i := range owner {
+		owner[i] = -1
+	}
+	parallel(0, len(tris), func(ts <-chan int) {
+		for t := range ts {
+			rasterizeTriangle(tris[t], pts, w, h, func(i int) {
+				owner[i] = int32(t)
+			})
+		}
+	})
+
+	// The mean colors of the triangles, the colors are weighted by the alpha.
+	sums := make([][5]float64, len(tris))
+	for i, t := range owner {
+		if t < 0 {
+			continue
+		}
+		s := src.Pix[(i/w)*src.Stride+(i%w)*4:]
+		a := float64(s[3])
+		sum := &sums[t]
+		sum[0] += float64(s[0]) * a
+		sum[1] += float64(s[1]) * a
+		sum[2] += float64(s[2]) * a
+		sum[3] += a
+		sum[4]++
+	}
+	colors := make([][4]uint8, len(tris))
+	for t, sum := range sums {
+		if sum[3] > 0 {
+			colors[t] = [4]uint8{clamp(sum[0] / sum[3]), clamp(sum[1] / sum[3]), clamp(sum[2] / sum[3]), clamp(sum[3] / sum[4])}
+		}
+	}
+
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				if t := owner[y*w+x]; t >= 0 {
+					c := colors[t]
+					copy(src.Pix[y*src.Stride+x*4:y*src.Stride+x*4+4], c[:])
+				}
+			}
+		}
+	})
+	return src
+}
+
+// lowPolyPoints returns the vertices of LowPoly: the corners and points of the boundary
+// of the image, and n pixel centers sampled with the probability growing with
+// the gradient magnitude.
+func lowPolyPoints(img *image.NRGBA, n int) [][2]float64 {
+	lum, w, h := luminancePlane(img)
+	gx, gy := gradients(gaussianSmooth(lum, w, h), w, h, sobelWeights)
+
+	// The flat areas get a few points as well.
+	const base = 4
+	cdf := make([]float64, w*h)
+	var total float64
+	for i := range cdf {
+		total += math.Hypot(gx[i], gy[i]) + base
+		cdf[i] = total
+	}
+
+	fw, fh := float64(w), float64(h)
+	pts := [][2]float64{{0, 0}, {fw, 0}, {0, fh}, {fw, fh}}
+	// The boundary points are about as dense as the points inside.
+	spacing := math.Sqrt(fw * fh / float64(n))
+	for i, k := 1, int(fw/spacing); i < k; i++ {
+		x := math.Round(fw * float64(i) / float64(k))
+		pts = append(pts, [2]float64{x, 0}, [2]float64{x, fh})
+	}
+	for i, k := 1, int(fh/spacing); i < k; i++ {
+		y := math.Round(fh * float64(i) / float64(k))
+		pts = append(pts, [2]float64{0, y}, [2]float64{fw, y})
+	}
+
+	// A fixed seed makes the results reproducible.
+	rnd := rand.New(rand.NewSource(1))
+	used := make(map[int]bool)
+	for tries := 0; len(used) < n && len(used) < w*h && tries < 4*n; tries++ {
+		i := sort.SearchFloat64s(cdf, rnd.Float64()*total)
+		if i >= len(cdf) || used[i] {
+			continue
+		}
+		used[i] = true
+		pts = append(pts, [2]float64{float64(i%w) + 0.5, float64(i/w) + 0.5})
+	}
+	return pts
+}
+
+// delaunayTriangle is a triangle of the Delaunay triangulation: the indices of its vertices
+// and its circumcircle.
+type delaunayTriangle struct {
+	v      [3]int
+	cx, cy float64
+	r2     float64
+}
+
+// delaunay returns the Delaunay triangulation of the distinct points computed with
+// the Bowyer-Watson algorithm, as the triples of the point indices.
+func delaunay(pts [][2]float64) [][3]int {
+	n := len(pts)
+	if n < 3 {
+		return nil
+	}
+
+	// The points are inserted from left to right, so the triangles whose circumcircles
+	// lie left of the current point are final.
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return pts[order[i]][0] < pts[order[j]][0]
+	})
+
+	// The super triangle enclosing all the points, its vertices are n, n+1 and n+2.
+	minX, minY, maxX, maxY := pts[0][0], pts[0][1], pts[0][0], pts[0][1]
+	for _, p := range pts {
+		minX, maxX = math.Min(minX, p[0]), math.Max(maxX, p[0])
+		minY, maxY = math.Min(minY, p[1]), math.Max(maxY, p[1])
+	}
+	d := math.Max(maxX-minX, maxY-minY) * 20
+	mx, my := (minX+maxX)/2, (minY+maxY)/2
+	all := append(pts[:n:n], [2]float64{mx - d, my - d}, [2]float64{mx + d, my - d}, [2]float64{mx, my + d})
+
+	makeTriangle := func(a, b, c int) delaunayTriangle {
+		ax, ay := all[a][0], all[a][1]
+		bx, by := all[b][0]-ax, all[b][1]-ay
+		cx, cy := all[c][0]-ax, all[c][1]-ay
+		den := 2 * (bx*cy - by*cx)
+		ux := (cy*(bx*bx+by*by) - by*(cx*cx+cy*cy)) / den
+		uy := (bx*(cx*cx+cy*cy) - cx*(bx*bx+by*by)) / den
+		return delaunayTriangle{v: [3]int{a, b, c}, cx: ax + ux, cy: ay + uy, r2: ux*ux + uy*uy}
+	}
+
+	open := []delaunayTriangle{makeTriangle(n, n+1, n+2)}
+	var closed []delaunayTriangle
+	var edges [][2]int
+	for _, i := range order {
+		px, py := all[i][0], all[i][1]
+		edges = edges[:0]
+		k := 0
+		for _, t := range open {
+			dx := px - t.cx
+			if dx > 0 && dx*dx > t.r2 {
+				closed = append(closed, t)
+				continue
+			}
+			dy := py - t.cy
+			if dx*dx+dy*dy < t.r2 {
+				edges = append(edges, [2]int{t.v[0], t.v[1]}, [2]int{t.v[1], t.v[2]}, [2]int{t.v[2], t.v[0]})
+				continue
+			}
+			open[k] = t
+			k++
+		}
+		open = open[:k]
+
+		// The edges of a single removed triangle bound the cavity of the point.
+		for a := range edges {
+			e := edges[a]
+			if e[0] < 0 {
+				continue
+			}
+			shared := false
+			for b := a + 1; b < len(edges); b++ {
+				if f := edges[b]; f[0] == e[1] && f[1] == e[0] || f[0] == e[0] && f[1] == e[1] {
+					edges[b] = [2]int{-1, -1}
+					shared = true
+				}
+			}
+			if !shared {
+				open = append(open, makeTriangle(e[0], e[1], i))
+			}
+		}
+	}
+
+	var tris [][3]int
+	for _, t := range append(closed, open...) {
+		if t.v[0] < n && t.v[1] < n && t.v[2] < n {
+			tris = append(tris, t.v)
+		}
+	}
+	return tris
+}
+
+// rasterizeTriangle calls fn with the index y*w+x of each pixel (x, y) whose center lies
+// in the triangle. The pixels on the edges shared by two triangles belong to one of them.
+func rasterizeTriangle(t [3]int, pts [][2]float64, w, h int, fn func(i int)) {
+	a, b, c := pts[t[0]], pts[t[1]], pts[t[2]]
+	edge := func(p, q [2]float64, x, y float64) float64 {
+		return (q[0]-p[0])*(y-p[1]) - (q[1]-p[1])*(x-p[0])
+	}
+	area := edge(a, b, c[0], c[1])
+	if area == 0 {
+		return
+	}
+	if area < 0 {
+		b, c = c, b
+	}
+	// The opposite directions of a shared edge make one of the triangles own it.
+	owns := func(p, q [2]float64) bool {
+		dy := q[1] - p[1]
+		return dy > 0 || dy == 0 && q[0] < p[0]
+	}
+	ownAB, ownBC, ownCA := owns(a, b), owns(b, c), owns(c, a)
+	inside := func(e float64, own bool) bool {
+		return e > 0 || e == 0 && own
+	}
+
+	x0 := maxint(int(math.Floor(math.Min(a[0], math.Min(b[0], c[0])))), 0)
+	x1 := minint(int(math.Ceil(math.Max(a[0], math.Max(b[0], c[0])))), w)
+	y0 := maxint(int(math.Floor(math.Min(a[1], math.Min(b[1], c[1])))), 0)
+	y1 := minint(int(math.Ceil(math.Max(a[1], math.Max(b[1], c[1])))), h)
+	for y := y0; y < y1; y++ {
+		py := float64(y) + 0.5
+		for x := x0; x < x1; x++ {
+			px := float64(x) + 0.5
+			if inside(edge(a, b, px, py), ownAB) && inside(edge(b, c, px, py), ownBC) && inside(edge(c, a, px, py), ownCA) {
+				fn(y*w + x)
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestLowPoly(t *testing.T) {
+	src := testdataFlowersSmallPNG
+	if got := LowPoly(src, 0); !compareNRGBA(got, toNRGBA(src), 0) {
+		t.Fatal("the points = 0 change the image")
+	}
+
+	got := LowPoly(src, 300)
+	if got.Rect != image.Rect(0, 0, 240, 160) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	if !compareNRGBA(got, LowPoly(src, 300), 0) {
+		t.Fatal("the result isn't reproducible")
+	}
+	// The triangles are filled with flat colors, so there are far fewer colors than pixels.
+	colors := make(map[color.NRGBA]bool)
+	for y := 0; y < 160; y++ {
+		for x := 0; x < 240; x++ {
+			colors[got.NRGBAAt(x, y)] = true
+		}
+	}
+	if len(colors) > 1000 {
+		t.Fatalf("got %d colors want about one per triangle", len(colors))
+	}
+
+	// The flat image keeps its color and alpha.
+	flat := New(30, 20, color.NRGBA{0x20, 0x80, 0xc0, 0x80})
+	if got := LowPoly(flat, 50); !compareNRGBA(got, flat, 0) {
+		t.Fatal("the flat image is changed")
+	}
+
+	if got := LowPoly(image.NewNRGBA(image.Rect(0, 0, 0, 5)), 10); got.Rect.Dx() != 0 {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	// More points than pixels.
+	if got := LowPoly(New(2, 2, color.White), 100); !compareNRGBA(got, New(2, 2, color.White), 0) {
+		t.Fatal("the tiny image is changed")
+	}
+}
+
+func TestLowPolyCoverage(t *testing.T) {
+	for _, size := range []image.Point{{240, 160}, {7, 31}, {1, 1}} {
+		img := Resize(testdataFlowersSmallPNG, size.X, size.Y, Box)
+		pts := lowPolyPoints(img, 200)
+		tris := delaunay(pts)
+
+		// Each pixel belongs to exactly one triangle.
+		count := make([]int, size.X*size.Y)
+		var area float64
+		for _, tri := range tris {
+			rasterizeTriangle(tri, pts, size.X, size.Y, func(i int) { count[i]++ })
+			a, b, c := pts[tri[0]], pts[tri[1]], pts[tri[2]]
+			area += math.Abs((b[0]-a[0])*(c[1]-a[1])-(b[1]-a[1])*(c[0]-a[0])) / 2
+		}
+		for i, n := range count {
+			if n != 1 {
+				t.Fatalf("%v: the pixel %d is in %d triangles", size, i, n)
+			}
+		}
+		if math.Abs(area-float64(size.X*size.Y)) > 1e-6 {
+			t.Fatalf("%v: got area %v want %d", size, area, size.X*size.Y)
+		}
+	}
+}
+
+func TestDelaunay(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	pts := make([][2]float64, 100)
+	for i := range pts {
+		pts[i] = [2]float64{rnd.Float64() * 100, rnd.Float64() * 50}
+	}
+	tris := delaunay(pts)
+	// A triangulation of n points with h of them on the convex hull has 2n-2-h triangles.
+	if len(tris) < len(pts) || len(tris) > 2*len(pts)-5 {
+		t.Fatalf("got %d triangles", len(tris))
+	}
+	// No point lies inside the circumcircle of a triangle.
+	for _, tri := range tris {
+		a, b, c := pts[tri[0]], pts[tri[1]], pts[tri[2]]
+		bx, by := b[0]-a[0], b[1]-a[1]
+		cx, cy := c[0]-a[0], c[1]-a[1]
+		den := 2 * (bx*cy - by*cx)
+		ux := (cy*(bx*bx+by*by) - by*(cx*cx+cy*cy)) / den
+		uy := (bx*(cx*cx+cy*cy) - cx*(bx*bx+by*by)) / den
+		r2 := ux*ux + uy*uy
+		for i, p := range pts {
+			if i == tri[0] || i == tri[1] || i == tri[2] {
+				continue
+			}
+			dx, dy := p[0]-a[0]-ux, p[1]-a[1]-uy
+			if dx*dx+dy*dy < r2*(1-1e-9) {
+				t.Fatalf("the point %v is inside the circumcircle of %v", p, tri)
+			}
+		}
+	}
+
+	if got := delaunay(pts[:2]); len(got) != 0 {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func BenchmarkLowPoly(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		LowPoly(testdataBranchesJPG, 2000)
+	}
+}
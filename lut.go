@@ -0,0 +1,259 @@
+package imaging
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidLUT means the color lookup table data is malformed.
+var ErrInvalidLUT = errors.New("imaging: invalid color lookup table")
+
+// LUT is a three-dimensional color lookup table. It maps each input color
+// to an output color, interpolating between the table entries.
+type LUT struct {
+	size      int
+	domainMin [3]float64
+	domainMax [3]float64
+	// data holds size*size*size RGB triples in the .cube order:
+	// the red index changes fastest, the blue index changes slowest.
+	data []float64
+}
+
+// NewLUT builds a 3D color lookup table with the given number of entries per axis
+// by sampling the fn function. The fn function receives the red, green and blue
+// components in the range [0, 1] and must return the output components in the same range.
+// The size must be at least 2.
+//
+// Example:
+//
+//	// Build a LUT that swaps the red and blue channels.
+//	lut := imaging.NewLUT(17, func(r, g, b float64) (float64, float64, float64) {
+//		return b, g, r
+//	})
+func NewLUT(size int, fn func(r, g, b float64) (float64, float64, float64)) *LUT {
+	if size < 2 {
+		size = 2
+	}
+	lut := &LUT{
+		size:      size,
+		domainMax: [3]float64{1, 1, 1},
+		data:      make([]float64, size*size*size*3),
+	}
+	step := 1 / float64(size-1)
+	i := 0
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				rr, gg, bb := fn(float64(r)*step, float64(g)*step, float64(b)*step)
+				lut.data[i+0] = rr
+				lut.data[i+1] = gg
+				lut.data[i+2] = bb
+				i += 3
+			}
+		}
+	}
+	return lut
+}
+
+// Size returns the number of entries per axis of the lookup table.
+func (lut *LUT) Size() int {
+	return lut.size
+}
+
+// ParseCubeLUT reads a 3D color lookup table in the Adobe .cube format from r.
+func ParseCubeLUT(r io.Reader) (*LUT, error) {
+	lut := &LUT{domainMax: [3]float64{1, 1, 1}}
+	n := 0
+
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(text)
+
+		switch fields[0] {
+		case "TITLE":
+			continue
+
+		case "LUT_1D_SIZE", "LUT_1D_INPUT_RANGE":
+			return nil, fmt.Errorf("%w: line %d: 1D tables are not supported", ErrInvalidLUT, line)
+
+		case "LUT_3D_SIZE":
+			if len(fields) != 2 || lut.data != nil {
+				return nil, fmt.Errorf("%w: line %d: bad LUT_3D_SIZE", ErrInvalidLUT, line)
+			}
+			size, err := strconv.Atoi(fields[1])
+			if err != nil || size < 2 || size > 256 {
+				return nil, fmt.Errorf("%w: line %d: bad LUT_3D_SIZE", ErrInvalidLUT, line)
+			}
+			lut.size = size
+			lut.data = make([]float64, 0, size*size*size*3)
+			continue
+
+		case "DOMAIN_MIN", "DOMAIN_MAX", "LUT_3D_INPUT_RANGE":
+			v, err := parseCubeFloats(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidLUT, line, err)
+			}
+			switch {
+			case fields[0] == "DOMAIN_MIN" && len(v) == 3:
+				copy(lut.domainMin[:], v)
+			case fields[0] == "DOMAIN_MAX" && len(v) == 3:
+				copy(lut.domainMax[:], v)
+			case fields[0] == "LUT_3D_INPUT_RANGE" && len(v) == 2:
+				lut.domainMin = [3]float64{v[0], v[0], v[0]}
+				lut.domainMax = [3]float64{v[1], v[1], v[1]}
+			default:
+				return nil, fmt.Errorf("%w: line %d: bad %s", ErrInvalidLUT, line, fields[0])
+			}
+			continue
+		}
+
+		if lut.data == nil {
+			return nil, fmt.Errorf("%w: line %d: data before LUT_3D_SIZE", ErrInvalidLUT, line)
+		}
+		v, err := parseCubeFloats(fields)
+		if err != nil || len(v) != 3 {
+			return nil, fmt.Errorf("%w: line %d: bad table entry", ErrInvalidLUT, line)
+		}
+		if n == lut.size*lut.size*lut.size {
+			return nil, fmt.Errorf("%w: line %d: too many table entries", ErrInvalidLUT, line)
+		}
+		lut.data = append(lut.data, v...)
+		n++
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	if lut.data == nil || n != lut.size*lut.size*lut.size {
+		return nil, fmt.Errorf("%w: got %d table entries", ErrInvalidLUT, n)
+	}
+	for i := 0; i < 3; i++ {
+		if lut.domainMax[i] <= lut.domainMin[i] {
+			return nil, fmt.Errorf("%w: bad domain", ErrInvalidLUT)
+		}
+	}
+	return lut, nil
+}
+
+func parseCubeFloats(fields []string) ([]float64, error) {
+	v := make([]float64, len(fields))
+	for i, f := range fields {
+		x, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = x
+	}
+	return v, nil
+}
+
+// OpenCubeLUT loads a 3D color lookup table from an Adobe .cube file.
+func OpenCubeLUT(filename string) (*LUT, error) {
+	file, err := fs.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseCubeLUT(file)
+}
+
+// ApplyLUT maps the colors of the image through the 3D color lookup table
+// using trilinear interpolation and returns the adjusted image.
+// The alpha channel is preserved.
+//
+// Example:
+//
+//	lut, err := imaging.OpenCubeLUT("film.cube")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	dstImage := imaging.ApplyLUT(srcImage, lut)
+func ApplyLUT(img image.Image, lut *LUT) *image.NRGBA {
+	return applyLUT(img, lut, GamutClip)
+}
+
+// applyLUT maps the colors of the image through the 3D color lookup table and brings
+// the output colors into the sRGB gamut with the given mapping.
+func applyLUT(img image.Image, lut *LUT, mapping GamutMapping) *image.NRGBA {
+	if lut == nil || lut.size < 2 {
+		return Clone(img)
+	}
+
+	// Precompute the lattice cell index and the interpolation weight
+	// for every possible value of each channel.
+	var idx [3][256]int
+	var frac [3][256]float64
+	last := float64(lut.size - 1)
+	for c := 0; c < 3; c++ {
+		scale := last / (lut.domainMax[c] - lut.domainMin[c])
+		for v := 0; v < 256; v++ {
+			p := (float64(v)/255 - lut.domainMin[c]) * scale
+			p = math.Min(math.Max(p, 0), last)
+			i := int(p)
+			if i == lut.size-1 {
+				i--
+			}
+			idx[c][v] = i
+			frac[c][v] = p - float64(i)
+		}
+	}
+
+	n := lut.size
+	data := lut.data
+	return AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		r0, g0, b0 := idx[0][c.R], idx[1][c.G], idx[2][c.B]
+		fr, fg, fb := frac[0][c.R], frac[1][c.G], frac[2][c.B]
+
+		var out [3]float64
+		for k := 0; k < 8; k++ {
+			dr, dg, db := k&1, (k>>1)&1, (k>>2)&1
+			w := 1.0
+			if dr == 1 {
+				w *= fr
+			} else {
+				w *= 1 - fr
+			}
+			if dg == 1 {
+				w *= fg
+			} else {
+				w *= 1 - fg
+			}
+			if db == 1 {
+				w *= fb
+			} else {
+				w *= 1 - fb
+			}
+			if w == 0 {
+				continue
+			}
+			i := (((b0+db)*n+(g0+dg))*n + (r0 + dr)) * 3
+			s := data[i : i+3 : i+3]
+			out[0] += s[0] * w
+			out[1] += s[1] * w
+			out[2] += s[2] * w
+		}
+		if mapping != GamutClip {
+			out[0], out[1], out[2] = MapGamut(out[0], out[1], out[2], mapping)
+		}
+
+		return color.NRGBA{
+			R: clamp(out[0] * 255),
+			G: clamp(out[1] * 255),
+			B: clamp(out[2] * 255),
+			A: c.A,
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestApplyLUT(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 1),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x00, 0x00, 0x00, 0x01, 0xff, 0x00, 0x00, 0x02, 0x00, 0xff, 0x00, 0x03,
+			0x11, 0x22, 0x33, 0xff, 0x80, 0x80, 0x80, 0xff, 0xff, 0xff, 0xff, 0xff,
+		},
+	}
+	testCases := []struct {
+		name string
+		lut  *LUT
+		want *image.NRGBA
+	}{
+		{
+			"ApplyLUT identity",
+			NewLUT(2, func(r, g, b float64) (float64, float64, float64) { return r, g, b }),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x01, 0xff, 0x00, 0x00, 0x02, 0x00, 0xff, 0x00, 0x03,
+					0x11, 0x22, 0x33, 0xff, 0x80, 0x80, 0x80, 0xff, 0xff, 0xff, 0xff, 0xff,
+				},
+			},
+		},
+		{
+			"ApplyLUT swap red blue",
+			NewLUT(5, func(r, g, b float64) (float64, float64, float64) { return b, g, r }),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0xff, 0x02, 0x00, 0xff, 0x00, 0x03,
+					0x33, 0x22, 0x11, 0xff, 0x80, 0x80, 0x80, 0xff, 0xff, 0xff, 0xff, 0xff,
+				},
+			},
+		},
+		{
+			"ApplyLUT invert",
+			NewLUT(2, func(r, g, b float64) (float64, float64, float64) { return 1 - r, 1 - g, 1 - b }),
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 3, 2),
+				Stride: 3 * 4,
+				Pix: []uint8{
+					0xff, 0xff, 0xff, 0x01, 0x00, 0xff, 0xff, 0x02, 0xff, 0x00, 0xff, 0x03,
+					0xee, 0xdd, 0xcc, 0xff, 0x7f, 0x7f, 0x7f, 0xff, 0x00, 0x00, 0x00, 0xff,
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ApplyLUT(src, tc.lut)
+			if !compareNRGBA(got, tc.want, 0) {
+				t.Fatalf("got result %#v want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseCubeLUT(t *testing.T) {
+	data := `# Created by hand
+TITLE "invert"
+LUT_3D_SIZE 2
+DOMAIN_MIN 0.0 0.0 0.0
+DOMAIN_MAX 1.0 1.0 1.0
+
+1 1 1
+0 1 1
+1 0 1
+0 0 1
+1 1 0
+0 1 0
+1 0 0
+0 0 0
+`
+	lut, err := ParseCubeLUT(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseCubeLUT: %v", err)
+	}
+	if lut.Size() != 2 {
+		t.Fatalf("got size %d want 2", lut.Size())
+	}
+	got := ApplyLUT(testdataFlowersSmallPNG, lut)
+	want := Invert(testdataFlowersSmallPNG)
+	if !compareNRGBA(got, want, 1) {
+		t.Fatalf("cube LUT result does not match Invert")
+	}
+}
+
+func TestParseCubeLUTErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"1D", "LUT_1D_SIZE 2\n0 0 0\n1 1 1\n"},
+		{"no size", "0 0 0\n"},
+		{"bad size", "LUT_3D_SIZE x\n"},
+		{"too few entries", "LUT_3D_SIZE 2\n0 0 0\n1 1 1\n"},
+		{"bad entry", "LUT_3D_SIZE 2\n0 0\n"},
+		{"bad domain", "LUT_3D_SIZE 2\nDOMAIN_MIN 1 1 1\nDOMAIN_MAX 0 0 0\n" + strings.Repeat("0 0 0\n", 8)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseCubeLUT(strings.NewReader(tc.data))
+			if !errors.Is(err, ErrInvalidLUT) {
+				t.Fatalf("got error %v want ErrInvalidLUT", err)
+			}
+		})
+	}
+}
+
+func BenchmarkApplyLUT(b *testing.B) {
+	lut := NewLUT(33, func(r, g, b float64) (float64, float64, float64) { return b, g, r })
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ApplyLUT(testdataBranchesJPG, lut)
+	}
+}
@@ -0,0 +1,58 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+)
+
+// MapColors applies the fn function to each pixel of the image in parallel and returns
+// the resulting image. It's the same as AdjustFunc, see also AdjustColors to apply
+// several color adjustments in a single pass.
+//
+// Example:
+//
+//	dstImage := imaging.MapColors(srcImage, func(c color.NRGBA) color.NRGBA {
+//		return color.NRGBA{c.G, c.B, c.R, c.A}
+//	})
+func MapColors(img image.Image, fn func(c color.NRGBA) color.NRGBA) *image.NRGBA {
+	return AdjustFunc(img, fn)
+}
+
+// MapRegions copies the image, splits the copy into square tiles of the given size and
+// calls the fn function for the tiles in parallel, limited like the other functions of the
+// package by SetMaxProcs, and returns the resulting image. The fn function receives a
+// sub-image of the copy, which it changes in place. The bounds of the sub-image are its
+// position in the resulting image, whose bounds start at (0, 0). The tiles at the right
+// and bottom edges may be smaller. The tile size <= 0 gives the whole image as one tile.
+//
+// The fn function must neither change nor read the pixels outside of its sub-image,
+// as the other tiles are changed concurrently.
+//
+// Example:
+//
+//	// Fill each 64x64 tile with its average color.
+//	dstImage := imaging.MapRegions(srcImage, 64, func(sub *image.NRGBA) {
+//		avg := imaging.Resize(sub, 1, 1, imaging.Box).NRGBAAt(0, 0)
+//		draw.Draw(sub, sub.Bounds(), image.NewUniform(avg), image.Point{}, draw.Src)
+//	})
+func MapRegions(img image.Image, tile int, fn func(sub *image.NRGBA)) *image.NRGBA {
+	dst := Clone(img)
+	w, h := dst.Rect.Dx(), dst.Rect.Dy()
+	if w <= 0 || h <= 0 {
+		return dst
+	}
+	tw, th := tile, tile
+	if tile <= 0 {
+		tw, th = w, h
+	}
+	cols := (w + tw - 1) / tw
+	rows := (h + th - 1) / th
+
+	parallel(0, cols*rows, func(is <-chan int) {
+		for i := range is {
+			x, y := i%cols*tw, i/cols*th
+			fn(dst.SubImage(image.Rect(x, y, x+tw, y+th)).(*image.NRGBA))
+		}
+	})
+	return dst
+}
@@ -0,0 +1,92 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+	"testing"
+)
+
+func TestMapColors(t *testing.T) {
+	fn := func(c color.NRGBA) color.NRGBA { return color.NRGBA{c.G, c.B, c.R, c.A} }
+	got := MapColors(testdataFlowersSmallPNG, fn)
+	want := AdjustFunc(testdataFlowersSmallPNG, fn)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatal("the result differs from AdjustFunc")
+	}
+}
+
+func TestMapRegions(t *testing.T) {
+	img := testdataFlowersSmallPNG // 240x160
+	testCases := []struct {
+		name  string
+		tile  int
+		rects int
+	}{
+		{"whole image", 0, 1},
+		{"exact tiles", 80, 6},
+		{"edge tiles", 100, 6},
+		{"larger than the image", 1000, 1},
+		{"single pixels", 1, 240 * 160},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var rects []image.Rectangle
+			got := MapRegions(img, tc.tile, func(sub *image.NRGBA) {
+				mu.Lock()
+				rects = append(rects, sub.Rect)
+				mu.Unlock()
+				InvertInPlace(sub)
+			})
+			if len(rects) != tc.rects {
+				t.Fatalf("got %d tiles want %d", len(rects), tc.rects)
+			}
+			area := 0
+			for _, r := range rects {
+				if !r.In(got.Bounds()) || r.Empty() {
+					t.Fatalf("tile %v outside of the image", r)
+				}
+				area += r.Dx() * r.Dy()
+			}
+			if area != 240*160 {
+				t.Fatalf("the tiles cover %d pixels want %d", area, 240*160)
+			}
+			if !compareNRGBA(got, Invert(img), 0) {
+				t.Fatal("the tiles aren't processed")
+			}
+		})
+	}
+}
+
+func TestMapRegionsPosition(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(-5, 10, 5, 17))
+	got := MapRegions(src, 4, func(sub *image.NRGBA) {
+		c := color.NRGBA{uint8(sub.Rect.Min.X), uint8(sub.Rect.Min.Y), 0, 0xff}
+		draw.Draw(sub, sub.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	})
+	if got.Bounds() != image.Rect(0, 0, 10, 7) {
+		t.Fatalf("got bounds %v want %v", got.Bounds(), image.Rect(0, 0, 10, 7))
+	}
+	for _, p := range []image.Point{{0, 0}, {3, 3}, {4, 0}, {9, 6}, {7, 4}} {
+		want := color.NRGBA{uint8(p.X / 4 * 4), uint8(p.Y / 4 * 4), 0, 0xff}
+		if c := got.NRGBAAt(p.X, p.Y); c != want {
+			t.Fatalf("pixel %v: got %v want %v", p, c, want)
+		}
+	}
+
+	empty := MapRegions(&image.NRGBA{}, 8, func(*image.NRGBA) {
+		t.Fatal("fn is called for an empty image")
+	})
+	if !empty.Bounds().Empty() {
+		t.Fatalf("got bounds %v want empty", empty.Bounds())
+	}
+}
+
+func BenchmarkMapRegions(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		MapRegions(testdataBranchesJPG, 64, InvertInPlace)
+	}
+}
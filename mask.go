@@ -0,0 +1,89 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// ApplyMask multiplies the alpha channel of the image by the grayscale mask and returns
+// the masked image: the white pixels of the mask keep the pixels of the image, the black ones
+// make them transparent and the gray ones translucent. The luminance of the mask pixels is
+// multiplied by their alpha, so the transparent pixels of a color mask also hide the image.
+// The mask is aligned with the top-left corner of the image, the pixels outside of it
+// become transparent. The colors of the image are kept.
+//
+// Example:
+//
+//	// Cut the image along a shape drawn in white on black.
+//	shape, _ := imaging.Open("star.png")
+//	dstImage := imaging.ApplyMask(srcImage, imaging.Resize(shape, srcImage.Bounds().Dx(), srcImage.Bounds().Dy(), imaging.Linear))
+func ApplyMask(img, mask image.Image) *image.NRGBA {
+	return applyAlphaMask(img, mask, func(a, m float64) uint8 {
+		return clamp(a * m / 255)
+	})
+}
+
+// applyAlphaMask sets the alpha of each pixel of the image to fn of its alpha and the alpha
+// luminance of the mask pixel from 0 to 255, the mask is aligned like in ApplyMask.
+func applyAlphaMask(img, mask image.Image, fn func(a, m float64) uint8) *image.NRGBA {
+	dst := Clone(img)
+	m := newScanner(mask)
+	w, h := dst.Rect.Dx(), dst.Rect.Dy()
+	parallel(0, h, func(ys <-chan int) {
+		scanLine := make([]uint8, w*4)
+		for y := range ys {
+			mw := 0
+			if y < m.h {
+				mw = minint(m.w, w)
+				m.scan(0, y, mw, y+1, scanLine)
+			}
+			for x := 0; x < w; x++ {
+				d := &dst.Pix[y*dst.Stride+x*4+3]
+				if x >= mw {
+					*d = 0
+					continue
+				}
+				s := scanLine[x*4 : x*4+4 : x*4+4]
+				lum := (0.299*float64(s[0]) + 0.587*float64(s[1]) + 0.114*float64(s[2])) * float64(s[3]) / 255
+				*d = fn(float64(*d), lum)
+			}
+		}
+	})
+	return dst
+}
+
+// RoundCorners rounds the corners of the image with the radius in pixels and returns
+// the image with the outside of the corners transparent, with antialiased edges.
+// The radius is limited to half of the smaller side of the image, so the radius of
+// at least half the size makes a square image a circle, e.g. for the avatars, and
+// the other images a stadium. The radius <= 0 returns a copy of the image.
+//
+// Example:
+//
+//	avatar := imaging.RoundCorners(imaging.Fill(photo, 128, 128, imaging.Center, imaging.Lanczos), 64)
+func RoundCorners(img image.Image, radius float64) *image.NRGBA {
+	dst := Clone(img)
+	if radius <= 0 {
+		return dst
+	}
+	w, h := dst.Rect.Dx(), dst.Rect.Dy()
+	r := image.Rect(0, 0, w, h)
+	// Only the squares of the corners are affected.
+	k := minint(int(math.Ceil(radius)), minint(w, h)/2+1)
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			if y >= k && y < h-k {
+				continue
+			}
+			for x := 0; x < w; x++ {
+				if x == k && w-k > k {
+					x = w - k
+				}
+				cov := roundedRectCoverage(r, radius, float64(x)+0.5, float64(y)+0.5)
+				d := &dst.Pix[y*dst.Stride+x*4+3]
+				*d = clamp(float64(*d) * cov)
+			}
+		}
+	})
+	return dst
+}
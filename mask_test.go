@@ -0,0 +1,94 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestApplyMask(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 1),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x10, 0x20, 0x30, 0xff, 0x40, 0x50, 0x60, 0xff, 0x70, 0x80, 0x90, 0x80,
+			0x10, 0x20, 0x30, 0xff, 0x40, 0x50, 0x60, 0xff, 0x70, 0x80, 0x90, 0xff,
+		},
+	}
+	// The gray mask is smaller than the image, the color pixels count by their
+	// luminance times their alpha.
+	mask := image.NewNRGBA(image.Rect(5, 5, 7, 7))
+	mask.SetNRGBA(5, 5, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	mask.SetNRGBA(6, 5, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	mask.SetNRGBA(5, 6, color.NRGBA{0x80, 0x80, 0x80, 0xff})
+	mask.SetNRGBA(6, 6, color.NRGBA{0xff, 0xff, 0xff, 0x40})
+	got := ApplyMask(src, mask)
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 3, 2),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x10, 0x20, 0x30, 0xff, 0x40, 0x50, 0x60, 0xff, 0x70, 0x80, 0x90, 0x00,
+			0x10, 0x20, 0x30, 0x80, 0x40, 0x50, 0x60, 0x40, 0x70, 0x80, 0x90, 0x00,
+		},
+	}
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("got result %#v want %#v", got, want)
+	}
+
+	// The gray images are masks as well.
+	gray := image.NewGray(image.Rect(0, 0, 3, 2))
+	for i := range gray.Pix {
+		gray.Pix[i] = 0xff
+	}
+	if got := ApplyMask(src, gray); !compareNRGBA(got, Clone(src), 0) {
+		t.Fatalf("the white mask changed the image: %#v", got)
+	}
+}
+
+func TestRoundCorners(t *testing.T) {
+	red := color.NRGBA{0xff, 0, 0, 0xff}
+	testCases := []struct {
+		name   string
+		w, h   int
+		radius float64
+		opaque []image.Point
+		clear  []image.Point
+	}{
+		{"radius 4", 20, 10, 4, []image.Point{{4, 0}, {0, 4}, {15, 9}, {19, 5}, {10, 5}}, []image.Point{{0, 0}, {19, 0}, {0, 9}, {19, 9}}},
+		{"circle", 20, 20, 100, []image.Point{{10, 1}, {1, 10}, {10, 18}, {10, 10}}, []image.Point{{2, 2}, {17, 2}, {2, 17}, {17, 17}}},
+		{"stadium", 30, 10, 100, []image.Point{{5, 1}, {25, 8}, {1, 5}, {15, 0}}, []image.Point{{0, 0}, {29, 9}}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := RoundCorners(New(tc.w, tc.h, red), tc.radius)
+			for _, p := range tc.opaque {
+				if c := got.NRGBAAt(p.X, p.Y); c != red {
+					t.Fatalf("got %v at %v", c, p)
+				}
+			}
+			for _, p := range tc.clear {
+				if c := got.NRGBAAt(p.X, p.Y); c.A != 0 {
+					t.Fatalf("got %v at %v", c, p)
+				}
+			}
+		})
+	}
+
+	// The edges are antialiased and the colors kept.
+	got := RoundCorners(New(20, 20, red), 10)
+	if c := got.NRGBAAt(3, 2); c.R != 0xff || c.A == 0 || c.A == 0xff {
+		t.Fatalf("got %v at the edge", c)
+	}
+
+	src := New(5, 5, red)
+	if got := RoundCorners(src, 0); !compareNRGBA(got, src, 0) {
+		t.Fatalf("the radius 0 changed the image")
+	}
+}
+
+func BenchmarkRoundCorners(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		RoundCorners(testdataBranchesJPG, 40)
+	}
+}
@@ -0,0 +1,81 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+)
+
+// BWFilter is the color filter of the black and white photography simulated by
+// BlackAndWhite. A filter lightens the colors similar to its own and darkens the others.
+type BWFilter int
+
+// Black and white filters.
+const (
+	// BWNeutral converts the colors by their luminance, like Grayscale.
+	BWNeutral BWFilter = iota
+
+	// BWRed darkens the blue sky dramatically and lightens the skin, e.g. for landscapes
+	// with clouds.
+	BWRed
+
+	// BWYellow darkens the blue sky slightly, the classic filter of the outdoor photos.
+	BWYellow
+
+	// BWGreen lightens the foliage and darkens the red, e.g. the lips and the skin blemishes.
+	BWGreen
+
+	// BWBlue lightens the blue and darkens the red and the yellow, e.g. for the haze and
+	// the fog.
+	BWBlue
+)
+
+// bwFilterWeights are the weights of the red, green and blue channels of the filters.
+var bwFilterWeights = [...][3]float64{
+	BWNeutral: {0.299, 0.587, 0.114},
+	BWRed:     {0.70, 0.30, 0.00},
+	BWYellow:  {0.45, 0.50, 0.05},
+	BWGreen:   {0.25, 0.65, 0.10},
+	BWBlue:    {0.15, 0.25, 0.60},
+}
+
+// BlackAndWhite converts the image to black and white like a photo taken through
+// the color filter, which controls the tones of the colors of the same luminance:
+// e.g. the red filter makes a blue sky almost black and the clouds stand out, while
+// Grayscale keeps them both light. An invalid filter returns a copy of the image.
+//
+// Example:
+//
+//	dstImage := imaging.BlackAndWhite(landscape, imaging.BWRed)
+func BlackAndWhite(img image.Image, filter BWFilter) *image.NRGBA {
+	return AdjustColors(img, BlackAndWhiteAdjustment(filter))
+}
+
+// BlackAndWhiteWeights converts the image to black and white with the custom weights of
+// the red, green and blue channels, like the monochrome channel mixer of the image editors.
+// The weights summing to 1 keep the grays of the image, the larger sums brighten it and
+// the negative weights darken the colors of the channel further.
+//
+// Example:
+//
+//	// An infrared look: the bright foliage and the dark sky.
+//	dstImage := imaging.BlackAndWhiteWeights(srcImage, -0.2, 1.4, -0.2)
+func BlackAndWhiteWeights(img image.Image, r, g, b float64) *image.NRGBA {
+	return AdjustColors(img, BlackAndWhiteWeightsAdjustment(r, g, b))
+}
+
+// BlackAndWhiteAdjustment returns the color adjustment of BlackAndWhite.
+func BlackAndWhiteAdjustment(filter BWFilter) ColorAdjustment {
+	if filter < 0 || int(filter) >= len(bwFilterWeights) {
+		return ColorAdjustment{}
+	}
+	w := bwFilterWeights[filter]
+	return BlackAndWhiteWeightsAdjustment(w[0], w[1], w[2])
+}
+
+// BlackAndWhiteWeightsAdjustment returns the color adjustment of BlackAndWhiteWeights.
+func BlackAndWhiteWeightsAdjustment(r, g, b float64) ColorAdjustment {
+	return ColorAdjustment{pixel: func(c color.NRGBA) color.NRGBA {
+		y := clamp(r*float64(c.R) + g*float64(c.G) + b*float64(c.B))
+		return color.NRGBA{y, y, y, c.A}
+	}}
+}
@@ -0,0 +1,81 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBlackAndWhite(t *testing.T) {
+	if !compareNRGBA(BlackAndWhite(testdataFlowersSmallPNG, BWNeutral), Grayscale(testdataFlowersSmallPNG), 0) {
+		t.Fatal("the neutral filter differs from Grayscale")
+	}
+	if !compareNRGBA(BlackAndWhite(testdataFlowersSmallPNG, BWFilter(-1)), Clone(testdataFlowersSmallPNG), 0) {
+		t.Fatal("the invalid filter changed the image")
+	}
+
+	sky, foliage, skin := color.NRGBA{90, 140, 220, 255}, color.NRGBA{60, 140, 40, 255}, color.NRGBA{220, 160, 130, 128}
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 3, 1),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			sky.R, sky.G, sky.B, sky.A,
+			foliage.R, foliage.G, foliage.B, foliage.A,
+			skin.R, skin.G, skin.B, skin.A,
+		},
+	}
+	tones := map[BWFilter][3]uint8{}
+	for _, f := range []BWFilter{BWNeutral, BWRed, BWYellow, BWGreen, BWBlue} {
+		got := BlackAndWhite(src, f)
+		for x := 0; x < 3; x++ {
+			c := got.NRGBAAt(x, 0)
+			if c.R != c.G || c.R != c.B || c.A != src.NRGBAAt(x, 0).A {
+				t.Fatalf("filter %d: got %v want gray with the alpha kept", f, c)
+			}
+			tone := tones[f]
+			tone[x] = c.R
+			tones[f] = tone
+		}
+	}
+	// The sky darkens through the red and yellow filters and lightens through the blue one.
+	if !(tones[BWRed][0] < tones[BWYellow][0] && tones[BWYellow][0] < tones[BWNeutral][0] && tones[BWNeutral][0] < tones[BWBlue][0]) {
+		t.Fatalf("got the sky tones %v", tones)
+	}
+	// The foliage is the lightest through the green filter.
+	for f, tone := range tones {
+		if f != BWGreen && tone[1] >= tones[BWGreen][1] {
+			t.Fatalf("got the foliage tones %v", tones)
+		}
+	}
+	// The skin is lighter through the red filter than the green one.
+	if tones[BWRed][2] <= tones[BWGreen][2] {
+		t.Fatalf("got the skin tones %v", tones)
+	}
+}
+
+func TestBlackAndWhiteWeights(t *testing.T) {
+	src := New(1, 1, color.NRGBA{100, 200, 50, 200})
+	testCases := []struct {
+		r, g, b float64
+		want    uint8
+	}{
+		{1, 0, 0, 100},
+		{0, 0.5, 0.5, 125},
+		{-0.2, 1.4, -0.2, 250},
+		{0, 2, 0, 255},
+		{-1, 0, 0, 0},
+	}
+	for _, tc := range testCases {
+		got := BlackAndWhiteWeights(src, tc.r, tc.g, tc.b)
+		if c := got.NRGBAAt(0, 0); c != (color.NRGBA{tc.want, tc.want, tc.want, 200}) {
+			t.Fatalf("weights %v %v %v: got %v want %d", tc.r, tc.g, tc.b, c, tc.want)
+		}
+	}
+}
+
+func BenchmarkBlackAndWhite(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		BlackAndWhite(testdataBranchesJPG, BWRed)
+	}
+}
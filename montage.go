@@ -0,0 +1,160 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// MontageOption sets an optional parameter for the Montage function.
+type MontageOption func(*montageConfig)
+
+type montageConfig struct {
+	spacing    int
+	background color.Color
+	filter     ResampleFilter
+	labels     []string
+	face       font.Face
+	labelColor color.Color
+}
+
+func newMontageConfig(opts []MontageOption) montageConfig {
+	cfg := montageConfig{
+		spacing:    4,
+		background: color.White,
+		filter:     Lanczos,
+		face:       basicfont.Face7x13,
+		labelColor: color.Black,
+	}
+	for _, option := range opts {
+		option(&cfg)
+	}
+	return cfg
+}
+
+// MontageSpacing returns a MontageOption that sets the spacing in pixels between the cells
+// and around them. The spacing < 0 is 0. Default is 4.
+func MontageSpacing(spacing int) MontageOption {
+	return func(c *montageConfig) {
+		c.spacing = maxint(spacing, 0)
+	}
+}
+
+// MontageBackground returns a MontageOption that sets the color of the sheet behind
+// the thumbnails. Default is white.
+func MontageBackground(background color.Color) MontageOption {
+	return func(c *montageConfig) {
+		c.background = background
+	}
+}
+
+// MontageFilter returns a MontageOption that sets the resampling filter scaling down
+// the images to the cells. Default is Lanczos.
+func MontageFilter(filter ResampleFilter) MontageOption {
+	return func(c *montageConfig) {
+		c.filter = filter
+	}
+}
+
+// MontageLabels returns a MontageOption that sets the labels drawn under the cells,
+// e.g. the file names, in the order of the images. The labels too wide for the cell
+// are shortened with "...". The missing and empty labels leave the space empty.
+func MontageLabels(labels []string) MontageOption {
+	return func(c *montageConfig) {
+		c.labels = labels
+	}
+}
+
+// MontageLabelFont returns a MontageOption that sets the font face and the color of
+// the labels. If face is nil, a built-in 7x13 pixel face is used. Default is black.
+func MontageLabelFont(face font.Face, labelColor color.Color) MontageOption {
+	return func(c *montageConfig) {
+		if face == nil {
+			face = basicfont.Face7x13
+		}
+		c.face = face
+		c.labelColor = labelColor
+	}
+}
+
+// Montage lays out the images in a grid of the specified number of columns and returns
+// the contact sheet. Each image is scaled down to fit the cell of cellW x cellH pixels,
+// see Fit, and centered in it, the smaller images keep their size. The rows fill from
+// the left and the top, the nil images leave their cells empty. The options set the
+// spacing, the background color and the labels under the cells.
+// If there are no images or one of cols, cellW or cellH is not positive,
+// an empty image is returned.
+//
+// Example:
+//
+//	dstImage := imaging.Montage(images, 5, 160, 120,
+//		imaging.MontageSpacing(8),
+//		imaging.MontageBackground(color.NRGBA{32, 32, 32, 255}),
+//		imaging.MontageLabels(names),
+//		imaging.MontageLabelFont(nil, color.White),
+//	)
+func Montage(images []image.Image, cols, cellW, cellH int, opts ...MontageOption) *image.NRGBA {
+	if len(images) == 0 || cols <= 0 || cellW <= 0 || cellH <= 0 {
+		return &image.NRGBA{}
+	}
+	cfg := newMontageConfig(opts)
+	cols = minint(cols, len(images))
+	rows := (len(images) + cols - 1) / cols
+
+	labelH := 0
+	var metrics font.Metrics
+	for _, label := range cfg.labels {
+		if label != "" {
+			metrics = cfg.face.Metrics()
+			labelH = metrics.Height.Ceil() + cfg.spacing
+			break
+		}
+	}
+
+	pitchX, pitchY := cellW+cfg.spacing, cellH+labelH+cfg.spacing
+	dst := New(cfg.spacing+cols*pitchX, cfg.spacing+rows*pitchY, cfg.background)
+	for i, img := range images {
+		x0 := cfg.spacing + i%cols*pitchX
+		y0 := cfg.spacing + i/cols*pitchY
+		if img != nil {
+			thumb := Fit(img, cellW, cellH, cfg.filter)
+			w, h := thumb.Rect.Dx(), thumb.Rect.Dy()
+			pos := image.Pt(x0+(cellW-w)/2, y0+(cellH-h)/2)
+			draw.Draw(dst, image.Rectangle{Min: pos, Max: pos.Add(thumb.Rect.Size())}, thumb, image.Point{}, draw.Over)
+			Release(thumb)
+		}
+		if labelH == 0 || i >= len(cfg.labels) || cfg.labels[i] == "" {
+			continue
+		}
+		d := &font.Drawer{Dst: dst, Src: image.NewUniform(cfg.labelColor), Face: cfg.face}
+		label := fitLabel(d, cfg.labels[i], fixed.I(cellW))
+		adv := d.MeasureString(label)
+		d.Dot = fixed.Point26_6{
+			X: fixed.I(x0) + (fixed.I(cellW)-adv)/2,
+			Y: fixed.I(y0+cellH+cfg.spacing) + metrics.Ascent,
+		}
+		// The glyphs don't spill into the neighboring cells.
+		d.Dst = dst.SubImage(image.Rect(x0, y0+cellH, x0+cellW, y0+cellH+labelH)).(*image.NRGBA)
+		d.DrawString(label)
+	}
+	return dst
+}
+
+// fitLabel returns the label shortened with "..." to fit the width.
+func fitLabel(d *font.Drawer, label string, width fixed.Int26_6) string {
+	if d.MeasureString(label) <= width {
+		return label
+	}
+	runes := []rune(label)
+	for n := len(runes) - 1; n > 0; n-- {
+		s := string(runes[:n]) + "..."
+		if d.MeasureString(s) <= width {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,127 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestMontage(t *testing.T) {
+	red := New(40, 20, color.NRGBA{255, 0, 0, 255})
+	blue := New(10, 10, color.NRGBA{0, 0, 255, 255})
+	bg := color.NRGBA{0, 255, 0, 255}
+	got := Montage([]image.Image{red, nil, blue}, 2, 20, 20, MontageSpacing(2), MontageBackground(bg))
+
+	// 2 columns and 2 rows of 20x20 cells with 2 pixels around them.
+	if want := image.Rect(0, 0, 46, 46); got.Bounds() != want {
+		t.Fatalf("got bounds %v want %v", got.Bounds(), want)
+	}
+	for _, tc := range []struct {
+		x, y int
+		want color.NRGBA
+	}{
+		{0, 0, bg},
+		{2, 6, bg},                          // Above the scaled down red image.
+		{2, 7, color.NRGBA{255, 0, 0, 255}}, // The 20x10 red image centered in the first cell.
+		{21, 16, color.NRGBA{255, 0, 0, 255}},
+		{21, 17, bg},
+		{33, 12, bg}, // The empty cell.
+		{6, 29, bg},
+		{7, 29, color.NRGBA{0, 0, 255, 255}}, // The blue image kept its size.
+		{16, 38, color.NRGBA{0, 0, 255, 255}},
+		{17, 38, bg},
+		{45, 45, bg},
+	} {
+		if c := got.NRGBAAt(tc.x, tc.y); c != tc.want {
+			t.Errorf("pixel (%d, %d): got %v want %v", tc.x, tc.y, c, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		name               string
+		images             []image.Image
+		cols, cellW, cellH int
+	}{
+		{"no images", nil, 2, 20, 20},
+		{"zero columns", []image.Image{red}, 0, 20, 20},
+		{"zero cell width", []image.Image{red}, 2, 0, 20},
+		{"zero cell height", []image.Image{red}, 2, 20, 0},
+	} {
+		if got := Montage(tc.images, tc.cols, tc.cellW, tc.cellH); got.Bounds() != (image.Rectangle{}) {
+			t.Errorf("%s: got bounds %v want empty", tc.name, got.Bounds())
+		}
+	}
+}
+
+func TestMontageLabels(t *testing.T) {
+	images := []image.Image{testdataFlowersSmallPNG, testdataBranchesJPG, testdataFlowersSmallPNG}
+	labels := []string{"flowers", "", "a very long label of the flowers"}
+	got := Montage(images, 3, 60, 40, MontageSpacing(0), MontageLabels(labels))
+
+	// The labels add a row of the height of the 7x13 face.
+	if want := image.Rect(0, 0, 180, 53); got.Bounds() != want {
+		t.Fatalf("got bounds %v want %v", got.Bounds(), want)
+	}
+	ink := func(x1, x2 int) int {
+		n := 0
+		for y := 40; y < 53; y++ {
+			for x := x1; x < x2; x++ {
+				if c := got.NRGBAAt(x, y); c != (color.NRGBA{255, 255, 255, 255}) {
+					n++
+				}
+			}
+		}
+		return n
+	}
+	if ink(0, 60) == 0 {
+		t.Error("the first label is not drawn")
+	}
+	if n := ink(60, 120); n != 0 {
+		t.Errorf("the empty label: got %d ink pixels want 0", n)
+	}
+	if ink(120, 180) == 0 {
+		t.Error("the long label is not drawn")
+	}
+	if n := ink(0, 3) + ink(57, 63) + ink(177, 180); n != 0 {
+		t.Errorf("the labels spill out of the cells: got %d ink pixels want 0", n)
+	}
+
+	d := Montage(images, 3, 60, 40, MontageSpacing(0))
+	if want := image.Rect(0, 0, 180, 40); d.Bounds() != want {
+		t.Fatalf("without labels: got bounds %v want %v", d.Bounds(), want)
+	}
+}
+
+func TestFitLabel(t *testing.T) {
+	// The glyphs of the 7x13 face are 7 pixels wide.
+	d := &font.Drawer{Face: basicfont.Face7x13}
+	for _, tc := range []struct {
+		label string
+		width int
+		want  string
+	}{
+		{"abcd", 28, "abcd"},
+		{"abcdefgh", 49, "abcd..."},
+		{"abcdefgh", 48, "abc..."},
+		{"abcdefgh", 27, ""},
+	} {
+		if got := fitLabel(d, tc.label, fixed.I(tc.width)); got != tc.want {
+			t.Errorf("fitLabel(%q, %d): got %q want %q", tc.label, tc.width, got, tc.want)
+		}
+	}
+}
+
+func BenchmarkMontage(b *testing.B) {
+	images := make([]image.Image, 12)
+	for i := range images {
+		images[i] = testdataBranchesJPG
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Montage(images, 4, 80, 60, MontageLabels([]string{"branches"}))
+	}
+}
@@ -0,0 +1,266 @@
+package imaging
+
+import (
+	"image"
+)
+
+// StructuringElement is the neighbourhood of the morphological operations: the offsets
+// of the neighbours of a pixel, including the pixel itself at (0, 0) if it's a part of the
+// neighbourhood. Any set of offsets can be used, the functions returning the usual shapes
+// center them at (0, 0).
+//
+// Example:
+//
+//	// A horizontal line of 5 pixels, e.g. to join the letters of the scanned text.
+//	se := imaging.RectElement(5, 1)
+//	// The pixel and its right neighbour.
+//	se = imaging.StructuringElement{{0, 0}, {1, 0}}
+type StructuringElement []image.Point
+
+// RectElement returns the structuring element of a rectangle of the given size.
+// The even sizes extend a pixel further to the left and up.
+func RectElement(width, height int) StructuringElement {
+	var se StructuringElement
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			se = append(se, image.Pt(x-width/2, y-height/2))
+		}
+	}
+	return se
+}
+
+// DiskElement returns the structuring element of a disk of the given radius,
+// 2*radius+1 pixels wide.
+func DiskElement(radius int) StructuringElement {
+	var se StructuringElement
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			// The disk of the radius + 0.5 avoids the single pixels at the ends of the axes.
+			if x*x+y*y <= radius*radius+radius {
+				se = append(se, image.Pt(x, y))
+			}
+		}
+	}
+	return se
+}
+
+// CrossElement returns the structuring element of a cross with the arms of the given
+// length, 2*radius+1 pixels wide.
+func CrossElement(radius int) StructuringElement {
+	var se StructuringElement
+	for i := -radius; i <= radius; i++ {
+		se = append(se, image.Pt(i, 0))
+		if i != 0 {
+			se = append(se, image.Pt(0, i))
+		}
+	}
+	return se
+}
+
+// Erode shrinks the bright areas of the grayscale image, e.g. the white foreground of
+// a mask: each pixel becomes the minimum of its neighbourhood given by the structuring
+// element. It removes the bright specks smaller than the element and thickens the dark
+// text of the scanned documents. The pixels outside of the image are ignored.
+// An empty element leaves the image unchanged.
+//
+// Example:
+//
+//	mask = imaging.Erode(mask, imaging.DiskElement(2))
+func Erode(img *image.Gray, se StructuringElement) *image.Gray {
+	return morphGray(img, se, false)
+}
+
+// Dilate grows the bright areas of the grayscale image: each pixel becomes the maximum of
+// its neighbourhood given by the reflected structuring element. It fills the dark holes
+// smaller than the element and thins the dark text of the scanned documents.
+// The pixels outside of the image are ignored. An empty element leaves the image unchanged.
+//
+// Example:
+//
+//	mask = imaging.Dilate(mask, imaging.DiskElement(2))
+func Dilate(img *image.Gray, se StructuringElement) *image.Gray {
+	return morphGray(img, se, true)
+}
+
+// MorphOpen erodes and then dilates the grayscale image with the structuring element.
+// It removes the bright details smaller than the element, such as noise in a mask,
+// and keeps the size of the larger bright areas.
+//
+// Example:
+//
+//	mask = imaging.MorphOpen(mask, imaging.DiskElement(1))
+func MorphOpen(img *image.Gray, se StructuringElement) *image.Gray {
+	return morphGray(morphGray(img, se, false), se, true)
+}
+
+// MorphClose dilates and then erodes the grayscale image with the structuring element.
+// It fills the dark holes and gaps smaller than the element, such as the gaps in a mask
+// or the dust on a scanned page, and keeps the size of the larger dark areas.
+//
+// Example:
+//
+//	mask = imaging.MorphClose(mask, imaging.DiskElement(1))
+func MorphClose(img *image.Gray, se StructuringElement) *image.Gray {
+	return morphGray(morphGray(img, se, true), se, false)
+}
+
+// ErodeAlpha erodes the alpha channel of the image like Erode, shrinking its opaque
+// areas, and keeps the colors.
+//
+// Example:
+//
+//	dstImage := imaging.ErodeAlpha(cutout, imaging.DiskElement(1))
+func ErodeAlpha(img image.Image, se StructuringElement) *image.NRGBA {
+	return morphAlpha(img, se, false, false)
+}
+
+// DilateAlpha dilates the alpha channel of the image like Dilate, growing its opaque
+// areas. The transparent pixels that become visible take the color of their most opaque
+// neighbour, the other pixels keep their colors.
+//
+// Example:
+//
+//	dstImage := imaging.DilateAlpha(cutout, imaging.DiskElement(1))
+func DilateAlpha(img image.Image, se StructuringElement) *image.NRGBA {
+	return morphAlpha(img, se, true, false)
+}
+
+// MorphOpenAlpha opens the alpha channel of the image like MorphOpen, removing
+// the small opaque specks, and keeps the colors.
+//
+// Example:
+//
+//	dstImage := imaging.MorphOpenAlpha(cutout, imaging.DiskElement(1))
+func MorphOpenAlpha(img image.Image, se StructuringElement) *image.NRGBA {
+	return morphAlpha(img, se, false, true)
+}
+
+// MorphCloseAlpha closes the alpha channel of the image like MorphClose, filling
+// the small transparent holes. The filled pixels take the color of their most opaque
+// neighbour, as in DilateAlpha.
+//
+// Example:
+//
+//	dstImage := imaging.MorphCloseAlpha(cutout, imaging.DiskElement(1))
+func MorphCloseAlpha(img image.Image, se StructuringElement) *image.NRGBA {
+	return morphAlpha(img, se, true, true)
+}
+
+// morphGray returns the image eroded or dilated with the structuring element.
+func morphGray(img *image.Gray, se StructuringElement, dilate bool) *image.Gray {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	dst := image.NewGray(image.Rect(0, 0, w, h))
+	morphPlane(dst.Pix, img.Pix, img.Stride, w, h, se, dilate)
+	return dst
+}
+
+// morphAlpha returns the image with the alpha channel eroded or dilated with
+// the structuring element, followed by the opposite operation if both is true.
+func morphAlpha(img image.Image, se StructuringElement, dilate, both bool) *image.NRGBA {
+	dst := Clone(img)
+	w, h := dst.Rect.Dx(), dst.Rect.Dy()
+	alpha := make([]uint8, w*h)
+	for i := range alpha {
+		alpha[i] = dst.Pix[i*4+3]
+	}
+	tmp := make([]uint8, w*h)
+	morphPlane(tmp, alpha, w, w, h, se, dilate)
+	if both {
+		tmp, alpha = alpha, tmp
+		morphPlane(tmp, alpha, w, w, h, se, !dilate)
+	}
+
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				i := y*dst.Stride + x*4
+				if dst.Pix[i+3] == 0 && tmp[y*w+x] > 0 {
+					// The color of the most opaque neighbour the dilation took the alpha from.
+					best, bestAlpha := -1, 0
+					for _, p := range se {
+						nx, ny := x-p.X, y-p.Y
+						if nx < 0 || ny < 0 || nx >= w || ny >= h {
+							continue
+						}
+						j := ny*dst.Stride + nx*4
+						if a := int(dst.Pix[j+3]); a > bestAlpha {
+							best, bestAlpha = j, a
+						}
+					}
+					if best >= 0 {
+						copy(dst.Pix[i:i+3], dst.Pix[best:best+3])
+					}
+				}
+			}
+		}
+	})
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				dst.Pix[y*dst.Stride+x*4+3] = tmp[y*w+x]
+			}
+		}
+	})
+	return dst
+}
+
+// morphPlane stores the minimum (or the maximum, if dilate is true) of the neighbourhood
+// of each value of the w x h plane src in the plane dst, whose stride is w. The erosion
+// takes the neighbours at the offsets of the structuring element, the dilation at
+// the reflected offsets. The neighbours outside of the plane are ignored.
+func morphPlane(dst, src []uint8, stride, w, h int, se StructuringElement, dilate bool) {
+	if len(se) == 0 {
+		for y := 0; y < h; y++ {
+			copy(dst[y*w:y*w+w], src[y*stride:y*stride+w])
+		}
+		return
+	}
+
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			row := dst[y*w : y*w+w]
+			init := uint8(0xff)
+			if dilate {
+				init = 0
+			}
+			for x := range row {
+				row[x] = init
+			}
+			covered := false
+			for _, p := range se {
+				dx, dy := p.X, p.Y
+				if dilate {
+					dx, dy = -dx, -dy
+				}
+				sy := y + dy
+				if sy < 0 || sy >= h {
+					continue
+				}
+				// The destination pixels whose neighbours at dx are inside the plane.
+				x0, x1 := maxint(0, -dx), minint(w, w-dx)
+				if x0 >= x1 {
+					continue
+				}
+				covered = true
+				s := src[sy*stride+x0+dx : sy*stride+x1+dx]
+				d := row[x0:x1]
+				if dilate {
+					for i, v := range s {
+						if v > d[i] {
+							d[i] = v
+						}
+					}
+				} else {
+					for i, v := range s {
+						if v < d[i] {
+							d[i] = v
+						}
+					}
+				}
+			}
+			if !covered {
+				copy(row, src[y*stride:y*stride+w])
+			}
+		}
+	})
+}
@@ -0,0 +1,332 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// grayMask returns the w x h black mask with the white pixels given as the rows of '#'.
+func grayMask(rows ...string) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x, c := range row {
+			if c == '#' {
+				img.SetGray(x, y, color.Gray{0xff})
+			}
+		}
+	}
+	return img
+}
+
+func TestStructuringElements(t *testing.T) {
+	testCases := []struct {
+		name string
+		se   StructuringElement
+		want int
+	}{
+		{"rect 3x3", RectElement(3, 3), 9},
+		{"rect 4x2", RectElement(4, 2), 8},
+		{"rect 0x3", RectElement(0, 3), 0},
+		{"disk 0", DiskElement(0), 1},
+		{"disk 1", DiskElement(1), 9},
+		{"disk 2", DiskElement(2), 21},
+		{"cross 0", CrossElement(0), 1},
+		{"cross 2", CrossElement(2), 9},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if len(tc.se) != tc.want {
+				t.Fatalf("got %d offsets want %d", len(tc.se), tc.want)
+			}
+			seen := make(map[image.Point]bool)
+			for _, p := range tc.se {
+				if seen[p] {
+					t.Fatalf("got the offset %v twice", p)
+				}
+				seen[p] = true
+			}
+			if tc.want > 0 && !seen[image.Point{}] {
+				t.Fatal("the element doesn't contain the origin")
+			}
+		})
+	}
+
+	// The even sizes extend to the left and up.
+	se := RectElement(2, 1)
+	if se[0] != image.Pt(-1, 0) || se[1] != image.Pt(0, 0) {
+		t.Fatalf("got %v", se)
+	}
+}
+
+func TestErodeDilate(t *testing.T) {
+	src := grayMask(
+		"........",
+		".####...",
+		".####...",
+		".####...",
+		".####...",
+		"........",
+	)
+	testCases := []struct {
+		name string
+		fn   func(*image.Gray, StructuringElement) *image.Gray
+		se   StructuringElement
+		want *image.Gray
+	}{
+		{
+			"erode rect",
+			Erode,
+			RectElement(3, 3),
+			grayMask(
+				"........",
+				"........",
+				"..##....",
+				"..##....",
+				"........",
+				"........",
+			),
+		},
+		{
+			"dilate cross",
+			Dilate,
+			CrossElement(1),
+			grayMask(
+				".####...",
+				"######..",
+				"######..",
+				"######..",
+				"######..",
+				".####...",
+			),
+		},
+		{
+			"dilate asymmetric",
+			Dilate,
+			StructuringElement{{0, 0}, {1, 0}},
+			grayMask(
+				"........",
+				".#####..",
+				".#####..",
+				".#####..",
+				".#####..",
+				"........",
+			),
+		},
+		{
+			"erode asymmetric",
+			Erode,
+			StructuringElement{{0, 0}, {1, 0}},
+			grayMask(
+				"........",
+				".###....",
+				".###....",
+				".###....",
+				".###....",
+				"........",
+			),
+		},
+		{
+			"empty element",
+			Erode,
+			nil,
+			src,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.fn(src, tc.se)
+			if got.Rect != tc.want.Rect || string(got.Pix) != string(tc.want.Pix) {
+				t.Fatalf("got\n%s\nwant\n%s", maskString(got), maskString(tc.want))
+			}
+		})
+	}
+}
+
+func TestErodeBorder(t *testing.T) {
+	// The pixels outside of the image don't erode the white image.
+	src := image.NewGray(image.Rect(2, 3, 6, 6))
+	for i := range src.Pix {
+		src.Pix[i] = 0xff
+	}
+	got := Erode(src, DiskElement(2))
+	if got.Rect != image.Rect(0, 0, 4, 3) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	for i, v := range got.Pix {
+		if v != 0xff {
+			t.Fatalf("got %d at %d want 255", v, i)
+		}
+	}
+
+	// The subimage is processed with its own pixels only.
+	sub := grayMask(
+		"#...",
+		"....",
+		"....",
+	).SubImage(image.Rect(1, 0, 4, 3)).(*image.Gray)
+	for i, v := range Dilate(sub, RectElement(3, 3)).Pix {
+		if v != 0 {
+			t.Fatalf("got %d at %d want 0", v, i)
+		}
+	}
+
+	// The element larger than the image.
+	if got := Erode(src, StructuringElement{{10, 10}}); string(got.Pix) != string(Erode(src, nil).Pix) {
+		t.Fatalf("got %v", got.Pix)
+	}
+	if got := Erode(&image.Gray{}, DiskElement(1)); got.Rect.Dx() != 0 || got.Rect.Dy() != 0 {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
+
+func TestMorphOpenClose(t *testing.T) {
+	src := grayMask(
+		"..........",
+		".#####..#.",
+		".##.##....",
+		".#####....",
+		".#####.#..",
+		"..........",
+	)
+	// The pixels around the hole don't fit the element either.
+	want := grayMask(
+		"..........",
+		".##.##....",
+		".##.##....",
+		".#####....",
+		".#####....",
+		"..........",
+	)
+	if got := MorphOpen(src, RectElement(2, 2)); string(got.Pix) != string(want.Pix) {
+		t.Fatalf("open: got\n%s\nwant\n%s", maskString(got), maskString(want))
+	}
+
+	// The gaps narrower than the element are filled, the background around is kept.
+	src = grayMask(
+		"...........",
+		"...........",
+		"..#######..",
+		"..###.###..",
+		"..##.####..",
+		"..#######..",
+		"...........",
+		"...........",
+	)
+	want = grayMask(
+		"...........",
+		"...........",
+		"..#######..",
+		"..#######..",
+		"..#######..",
+		"..#######..",
+		"...........",
+		"...........",
+	)
+	if got := MorphClose(src, RectElement(3, 3)); string(got.Pix) != string(want.Pix) {
+		t.Fatalf("close: got\n%s\nwant\n%s", maskString(got), maskString(want))
+	}
+
+	// The grayscale values, e.g. a dark line of text on a page, take the minimum and maximum.
+	page := image.NewGray(image.Rect(0, 0, 5, 1))
+	copy(page.Pix, []uint8{200, 200, 40, 200, 200})
+	if got := Erode(page, RectElement(3, 1)).Pix; string(got) != string([]uint8{200, 40, 40, 40, 200}) {
+		t.Fatalf("got %v", got)
+	}
+	if got := Dilate(page, RectElement(3, 1)).Pix; string(got) != string([]uint8{200, 200, 200, 200, 200}) {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestMorphAlpha(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 3, 0),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x10, 0x20, 0x30, 0x00, 0x40, 0x50, 0x60, 0xff, 0x70, 0x80, 0x90, 0x80, 0xa0, 0xb0, 0xc0, 0x00,
+		},
+	}
+	se := RectElement(3, 1)
+
+	got := ErodeAlpha(src, se)
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 4, 1),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x10, 0x20, 0x30, 0x00, 0x40, 0x50, 0x60, 0x00, 0x70, 0x80, 0x90, 0x00, 0xa0, 0xb0, 0xc0, 0x00,
+		},
+	}
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("erode: got %#v want %#v", got, want)
+	}
+
+	// The visible transparent pixels take the color of the most opaque neighbour,
+	// the translucent pixel keeps its color.
+	got = DilateAlpha(src, se)
+	want = &image.NRGBA{
+		Rect:   image.Rect(0, 0, 4, 1),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x40, 0x50, 0x60, 0xff, 0x40, 0x50, 0x60, 0xff, 0x70, 0x80, 0x90, 0xff, 0x70, 0x80, 0x90, 0x80,
+		},
+	}
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("dilate: got %#v want %#v", got, want)
+	}
+
+	// The opening removes the small opaque areas, the closing fills the transparent pixel.
+	if got := MorphOpenAlpha(src, se); !compareNRGBA(got, ErodeAlpha(src, se), 0) {
+		t.Fatalf("open: got %#v", got)
+	}
+	hole := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 3, 1),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x10, 0x20, 0x30, 0xff, 0x00, 0x00, 0x00, 0x00, 0x40, 0x50, 0x60, 0xff,
+		},
+	}
+	got = MorphCloseAlpha(hole, se)
+	want = &image.NRGBA{
+		Rect:   image.Rect(0, 0, 3, 1),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x10, 0x20, 0x30, 0xff, 0x40, 0x50, 0x60, 0xff, 0x40, 0x50, 0x60, 0xff,
+		},
+	}
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("close: got %#v want %#v", got, want)
+	}
+
+	// The source isn't changed.
+	if src.Pix[3] != 0 || src.Pix[7] != 0xff {
+		t.Fatalf("the source is changed: %v", src.Pix)
+	}
+}
+
+// maskString returns the mask as the rows of '#' and '.'.
+func maskString(img *image.Gray) string {
+	var s []byte
+	for y := 0; y < img.Rect.Dy(); y++ {
+		for x := 0; x < img.Rect.Dx(); x++ {
+			if img.Pix[y*img.Stride+x] >= 0x80 {
+				s = append(s, '#')
+			} else {
+				s = append(s, '.')
+			}
+		}
+		s = append(s, '\n')
+	}
+	return string(s)
+}
+
+func BenchmarkMorphOpen(b *testing.B) {
+	mask := image.NewGray(testdataBranchesJPG.Bounds())
+	for i := range mask.Pix {
+		mask.Pix[i] = uint8(i * 7)
+	}
+	se := DiskElement(3)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		MorphOpen(mask, se)
+	}
+}
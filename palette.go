@@ -0,0 +1,265 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// ColorDistance specifies the color difference formula used to match colors.
+type ColorDistance int
+
+// Color difference formulas.
+const (
+	// DistanceRGB is the Euclidean distance of the sRGB components. It's the fastest, but
+	// it overrates the differences of greens and underrates the differences of blues.
+	DistanceRGB ColorDistance = iota
+
+	// DeltaE76 is the Euclidean distance of the colors in the CIELAB color space.
+	DeltaE76
+
+	// DeltaE2000 is the CIEDE2000 color difference. It corrects the nonuniformity of CIELAB
+	// in the saturated blues and the neutral colors and matches the perceived differences best.
+	DeltaE2000
+)
+
+// MapToPalette maps every pixel of the image to the nearest color of the palette by the
+// given color difference and returns the resulting paletted image. Unlike Dither, no error
+// is diffused, so areas of similar colors become flat areas of the palette colors, e.g. to
+// posterize artwork with a brand palette. The palette must not be empty, only its first
+// 256 colors are used.
+//
+// With DeltaE76 and DeltaE2000 the alpha difference is added to the color difference,
+// scaled so the difference of opaque and transparent is 100, the range of the CIELAB lightness.
+//
+// Example:
+//
+//	brand := color.Palette{
+//		color.NRGBA{0x1d, 0x35, 0x57, 0xff},
+//		color.NRGBA{0xe6, 0x39, 0x46, 0xff},
+//		color.NRGBA{0xf1, 0xfa, 0xee, 0xff},
+//	}
+//	dstImage := imaging.MapToPalette(srcImage, brand, imaging.DeltaE2000)
+func MapToPalette(img image.Image, p color.Palette, distance ColorDistance) *image.Paletted {
+	if len(p) == 0 {
+		return &image.Paletted{}
+	}
+	if len(p) > 256 {
+		p = p[:256]
+	}
+
+	src := newScanner(img)
+	dst := image.NewPaletted(image.Rect(0, 0, src.w, src.h), p)
+	if src.w <= 0 || src.h <= 0 {
+		return dst
+	}
+	if distance != DeltaE76 && distance != DeltaE2000 {
+		ditherTo(dst, src, DitherNone)
+		return dst
+	}
+
+	pal := newLabPaletteMatcher(p, distance)
+	parallel(0, src.h, func(ys <-chan int) {
+		// The images have few distinct colors compared to their pixels.
+		cache := make(map[color.NRGBA]uint8)
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			row := dst.Pix[y*dst.Stride : y*dst.Stride+src.w]
+			for x := range row {
+				s := scanLine[x*4 : x*4+4 : x*4+4]
+				c := color.NRGBA{s[0], s[1], s[2], s[3]}
+				i, ok := cache[c]
+				if !ok {
+					i = pal.nearest(c)
+					cache[c] = i
+				}
+				row[x] = i
+			}
+		}
+	})
+	return dst
+}
+
+// ColorDifference returns the difference of the colors by the given color difference formula,
+// the one MapToPalette minimizes. DistanceRGB is the Euclidean distance of the 8-bit
+// non-premultiplied components including the alpha. With DeltaE76 and DeltaE2000 the alpha
+// difference is added like in MapToPalette, so the difference of opaque and transparent is 100.
+// The differences below 1 of DeltaE2000 are imperceptible, the ones above 2 to 3 are noticeable
+// side by side.
+//
+// Example:
+//
+//	if imaging.ColorDifference(brandRed, printed, imaging.DeltaE2000) > 2 {
+//		log.Print("the printed color doesn't match the brand")
+//	}
+func ColorDifference(c1, c2 color.Color, distance ColorDistance) float64 {
+	n1 := color.NRGBAModel.Convert(c1).(color.NRGBA)
+	n2 := color.NRGBAModel.Convert(c2).(color.NRGBA)
+	da := float64(n1.A) - float64(n2.A)
+	switch distance {
+	case DeltaE76, DeltaE2000:
+		lab1, lab2 := newLabColor(n1.R, n1.G, n1.B), newLabColor(n2.R, n2.G, n2.B)
+		d := deltaE76(lab1, lab2)
+		if distance == DeltaE2000 {
+			d = deltaE2000(lab1, lab2)
+		}
+		return d + math.Abs(da)*100/255
+	}
+	dr := float64(n1.R) - float64(n2.R)
+	dg := float64(n1.G) - float64(n2.G)
+	db := float64(n1.B) - float64(n2.B)
+	return math.Sqrt(dr*dr + dg*dg + db*db + da*da)
+}
+
+// labPaletteMatcher finds the nearest palette colors in the CIELAB color space.
+type labPaletteMatcher struct {
+	colors   []labColor
+	alpha    []float64
+	distance func(c1, c2 labColor) float64
+}
+
+func newLabPaletteMatcher(p color.Palette, distance ColorDistance) *labPaletteMatcher {
+	m := &labPaletteMatcher{
+		colors:   make([]labColor, len(p)),
+		alpha:    make([]float64, len(p)),
+		distance: deltaE76,
+	}
+	if distance == DeltaE2000 {
+		m.distance = deltaE2000
+	}
+	for i, c := range p {
+		nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+		m.colors[i] = newLabColor(nc.R, nc.G, nc.B)
+		m.alpha[i] = float64(nc.A)
+	}
+	return m
+}
+
+// nearest returns the index of the palette color nearest to c.
+func (m *labPaletteMatcher) nearest(c color.NRGBA) uint8 {
+	lab := newLabColor(c.R, c.G, c.B)
+	a := float64(c.A)
+	best := 0
+	bestDist := math.Inf(1)
+	for i, pc := range m.colors {
+		dist := m.distance(lab, pc) + math.Abs(a-m.alpha[i])*100/255
+		if dist < bestDist {
+			best = i
+			bestDist = dist
+		}
+	}
+	return uint8(best)
+}
+
+// labColor is a color in the CIELAB color space with the D65 white point.
+type labColor struct {
+	l, a, b float64
+}
+
+// srgbToLinear converts the 8-bit sRGB components to linear light from 0 to 1.
+var srgbToLinear = func() (tab [256]float64) {
+	for i := range tab {
+		c := float64(i) / 255
+		if c <= 0.04045 {
+			tab[i] = c / 12.92
+		} else {
+			tab[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+	return tab
+}()
+
+// newLabColor converts the sRGB color to CIELAB.
+func newLabColor(r, g, b uint8) labColor {
+	lr, lg, lb := srgbToLinear[r], srgbToLinear[g], srgbToLinear[b]
+	// Linear sRGB to XYZ relative to the D65 white point.
+	x := (0.4124564*lr + 0.3575761*lg + 0.1804375*lb) / 0.95047
+	y := 0.2126729*lr + 0.7151522*lg + 0.0721750*lb
+	z := (0.0193339*lr + 0.1191920*lg + 0.9503041*lb) / 1.08883
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return labColor{l: 116*fy - 16, a: 500 * (fx - fy), b: 200 * (fy - fz)}
+}
+
+// deltaE76 returns the CIE76 color difference.
+func deltaE76(c1, c2 labColor) float64 {
+	dl, da, db := c1.l-c2.l, c1.a-c2.a, c1.b-c2.b
+	return math.Sqrt(dl*dl + da*da + db*db)
+}
+
+// deltaE2000 returns the CIEDE2000 color difference with the parametric factors of 1.
+func deltaE2000(c1, c2 labColor) float64 {
+	const pow25to7 = 6103515625 // 25^7
+
+	cab := (math.Hypot(c1.a, c1.b) + math.Hypot(c2.a, c2.b)) / 2
+	cab7 := pow7(cab)
+	g := 0.5 * (1 - math.Sqrt(cab7/(cab7+pow25to7)))
+	a1, a2 := (1+g)*c1.a, (1+g)*c2.a
+	cp1, cp2 := math.Hypot(a1, c1.b), math.Hypot(a2, c2.b)
+	hue := func(a, b float64) float64 {
+		if a == 0 && b == 0 {
+			return 0
+		}
+		h := math.Atan2(b, a)
+		if h < 0 {
+			h += 2 * math.Pi
+		}
+		return h
+	}
+	hp1, hp2 := hue(a1, c1.b), hue(a2, c2.b)
+
+	dL := c2.l - c1.l
+	dC := cp2 - cp1
+	var dh float64
+	if cp1*cp2 != 0 {
+		dh = hp2 - hp1
+		if dh > math.Pi {
+			dh -= 2 * math.Pi
+		} else if dh < -math.Pi {
+			dh += 2 * math.Pi
+		}
+	}
+	dH := 2 * math.Sqrt(cp1*cp2) * math.Sin(dh/2)
+
+	lp := (c1.l + c2.l) / 2
+	cp := (cp1 + cp2) / 2
+	hp := hp1 + hp2
+	if cp1*cp2 != 0 {
+		if math.Abs(hp1-hp2) > math.Pi {
+			if hp < 2*math.Pi {
+				hp += 2 * math.Pi
+			} else {
+				hp -= 2 * math.Pi
+			}
+		}
+		hp /= 2
+	}
+
+	deg := math.Pi / 180
+	t := 1 - 0.17*math.Cos(hp-30*deg) + 0.24*math.Cos(2*hp) +
+		0.32*math.Cos(3*hp+6*deg) - 0.20*math.Cos(4*hp-63*deg)
+	e := (hp/deg - 275) / 25
+	dTheta := 30 * deg * math.Exp(-e*e)
+	cp7 := pow7(cp)
+	rc := 2 * math.Sqrt(cp7/(cp7+pow25to7))
+	l50 := (lp - 50) * (lp - 50)
+	sl := 1 + 0.015*l50/math.Sqrt(20+l50)
+	sc := 1 + 0.045*cp
+	sh := 1 + 0.015*cp*t
+	rt := -math.Sin(2*dTheta) * rc
+
+	kl, kc, kh := dL/sl, dC/sc, dH/sh
+	return math.Sqrt(kl*kl + kc*kc + kh*kh + rt*kc*kh)
+}
+
+// pow7 returns x**7.
+func pow7(x float64) float64 {
+	x2 := x * x
+	return x2 * x2 * x2 * x
+}
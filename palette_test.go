@@ -0,0 +1,136 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestDeltaE2000(t *testing.T) {
+	// Test data of Sharma, Wu and Dalal, "The CIEDE2000 Color-Difference Formula".
+	testCases := []struct {
+		c1, c2 labColor
+		want   float64
+	}{
+		{labColor{50, 2.6772, -79.7751}, labColor{50, 0, -82.7485}, 2.0425},
+		{labColor{50, 3.1571, -77.2803}, labColor{50, 0, -82.7485}, 2.8615},
+		{labColor{50, 2.8361, -74.0200}, labColor{50, 0, -82.7485}, 3.4412},
+		{labColor{50, 0, 0}, labColor{50, -1, 2}, 2.3669},
+		{labColor{50, 2.49, -0.001}, labColor{50, -2.49, 0.0009}, 7.1792},
+		{labColor{50, 2.5, 0}, labColor{73, 25, -18}, 27.1492},
+		{labColor{50, 2.5, 0}, labColor{61, -5, 29}, 22.8977},
+		{labColor{60.2574, -34.0099, 36.2677}, labColor{60.4626, -34.1751, 39.4387}, 1.2644},
+		{labColor{50, 0, 0}, labColor{50, 0, 0}, 0},
+	}
+	for _, tc := range testCases {
+		if got := deltaE2000(tc.c1, tc.c2); math.Abs(got-tc.want) > 1e-4 {
+			t.Fatalf("deltaE2000(%v, %v): got %.4f want %.4f", tc.c1, tc.c2, got, tc.want)
+		}
+		if got := deltaE2000(tc.c2, tc.c1); math.Abs(got-tc.want) > 1e-4 {
+			t.Fatalf("deltaE2000(%v, %v): got %.4f want %.4f", tc.c2, tc.c1, got, tc.want)
+		}
+	}
+}
+
+func TestNewLabColor(t *testing.T) {
+	testCases := []struct {
+		r, g, b uint8
+		want    labColor
+	}{
+		{0, 0, 0, labColor{0, 0, 0}},
+		{255, 255, 255, labColor{100, 0, 0}},
+		{255, 0, 0, labColor{53.2408, 80.0925, 67.2032}},
+		{0, 0, 255, labColor{32.2970, 79.1875, -107.8602}},
+		{128, 128, 128, labColor{53.5850, 0, 0}},
+	}
+	for _, tc := range testCases {
+		got := newLabColor(tc.r, tc.g, tc.b)
+		if math.Abs(got.l-tc.want.l) > 1e-2 || math.Abs(got.a-tc.want.a) > 1e-2 || math.Abs(got.b-tc.want.b) > 1e-2 {
+			t.Fatalf("newLabColor(%d, %d, %d): got %v want %v", tc.r, tc.g, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestColorDifference(t *testing.T) {
+	testCases := []struct {
+		c1, c2   color.Color
+		distance ColorDistance
+		want     float64
+	}{
+		{color.NRGBA{10, 20, 30, 40}, color.NRGBA{13, 24, 30, 40}, DistanceRGB, 5},
+		{color.NRGBA{10, 20, 30, 255}, color.NRGBA{10, 20, 30, 0}, DistanceRGB, 255},
+		{color.Black, color.White, DeltaE76, 100},
+		{color.Black, color.White, DeltaE2000, 100},
+		{color.NRGBA{255, 0, 0, 255}, color.NRGBA{255, 0, 0, 0}, DeltaE2000, 100},
+		{color.Gray{128}, color.RGBA{128, 128, 128, 255}, DeltaE2000, 0},
+		{color.NRGBA{0, 0, 255, 255}, color.NRGBA{255, 0, 0, 255}, DeltaE76, deltaE76(newLabColor(0, 0, 255), newLabColor(255, 0, 0))},
+	}
+	for _, tc := range testCases {
+		if got := ColorDifference(tc.c1, tc.c2, tc.distance); math.Abs(got-tc.want) > 1e-2 {
+			t.Fatalf("ColorDifference(%v, %v, %v): got %v want %v", tc.c1, tc.c2, tc.distance, got, tc.want)
+		}
+	}
+}
+
+func TestMapToPalette(t *testing.T) {
+	p := color.Palette{
+		color.NRGBA{0x00, 0x00, 0x00, 0xff},
+		color.NRGBA{0xff, 0xff, 0xff, 0xff},
+		color.NRGBA{0x00, 0x60, 0x00, 0xff},
+		color.NRGBA{0x30, 0x30, 0x80, 0xff},
+		color.NRGBA{0x00, 0x00, 0x00, 0x00},
+	}
+	img := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 4, 0),
+		Stride: 5 * 4,
+		Pix: []uint8{
+			0x10, 0x10, 0x10, 0xff,
+			0xf0, 0xf0, 0xf0, 0xff,
+			0x20, 0x50, 0x30, 0xff,
+			0x20, 0x20, 0x70, 0xff,
+			0xff, 0x00, 0x00, 0x00,
+		},
+	}
+	for _, distance := range []ColorDistance{DistanceRGB, DeltaE76, DeltaE2000} {
+		got := MapToPalette(img, p, distance)
+		want := []uint8{0, 1, 2, 3, 4}
+		if got.Rect != image.Rect(0, 0, 5, 1) {
+			t.Fatalf("%d: got bounds %v", distance, got.Rect)
+		}
+		for i := range want {
+			if got.Pix[i] != want[i] {
+				t.Fatalf("%d: got indexes %v want %v", distance, got.Pix, want)
+			}
+		}
+	}
+	if got := MapToPalette(img, nil, DeltaE2000); got.Rect != (image.Rectangle{}) {
+		t.Fatalf("got bounds %v for an empty palette", got.Rect)
+	}
+	if got := MapToPalette(&image.NRGBA{}, p, DeltaE2000); got.Rect != (image.Rectangle{}) {
+		t.Fatalf("got bounds %v for an empty image", got.Rect)
+	}
+}
+
+func TestMapToPalettePerceptual(t *testing.T) {
+	// A muted green is nearer to the gray in RGB, but it's perceived nearer to the green.
+	p := color.Palette{
+		color.NRGBA{0x00, 0x80, 0x00, 0xff},
+		color.NRGBA{0x80, 0x80, 0x80, 0xff},
+	}
+	img := New(1, 1, color.NRGBA{0x60, 0x90, 0x60, 0xff})
+	if got := MapToPalette(img, p, DistanceRGB).Pix[0]; got != 1 {
+		t.Fatalf("DistanceRGB: got index %d want 1", got)
+	}
+	if got := MapToPalette(img, p, DeltaE2000).Pix[0]; got != 0 {
+		t.Fatalf("DeltaE2000: got index %d want 0", got)
+	}
+}
+
+func BenchmarkMapToPalette(b *testing.B) {
+	p := Quantize(testdataBranchesJPG, 16, QuantizeMedianCut)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		MapToPalette(testdataBranchesJPG, p, DeltaE2000)
+	}
+}
@@ -0,0 +1,230 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+)
+
+// NewCheckerboard creates a new image with the specified width and height filled with
+// the checkerboard of the squares of the size in pixels, the top-left square of the
+// color c1. The size < 1 is 1.
+//
+// Example:
+//
+//	// The background showing the transparency of the image.
+//	bg := imaging.NewCheckerboard(w, h, 8, color.White, color.NRGBA{204, 204, 204, 255})
+//	dstImage := imaging.Overlay(bg, srcImage, image.Pt(0, 0), 1.0)
+func NewCheckerboard(width, height, size int, c1, c2 color.Color) *image.NRGBA {
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
+	size = maxint(size, 1)
+	dst := newNRGBA(image.Rect(0, 0, width, height))
+	colors := [2]color.NRGBA{
+		color.NRGBAModel.Convert(c1).(color.NRGBA),
+		color.NRGBAModel.Convert(c2).(color.NRGBA),
+	}
+	// The rows starting with c1 and c2.
+	var rows [2][]uint8
+	for i := range rows {
+		rows[i] = make([]uint8, width*4)
+		for x := 0; x < width; x++ {
+			c := colors[(x/size+i)%2]
+			copy(rows[i][x*4:], []uint8{c.R, c.G, c.B, c.A})
+		}
+	}
+	for y := 0; y < height; y++ {
+		copy(dst.Pix[y*dst.Stride:], rows[y/size%2])
+	}
+	return dst
+}
+
+// NewSolidPattern creates a new image with the specified width and height filled with
+// the tile repeated from the top-left corner, e.g. a pattern of stripes or a texture.
+// The empty tile makes the image transparent.
+//
+// Example:
+//
+//	tile, _ := imaging.Open("paper.png")
+//	bg := imaging.NewSolidPattern(1920, 1080, tile)
+func NewSolidPattern(width, height int, tile image.Image) *image.NRGBA {
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
+	dst := newNRGBA(image.Rect(0, 0, width, height))
+	t := Clone(tile)
+	tw, th := t.Rect.Dx(), t.Rect.Dy()
+	if tw == 0 || th == 0 {
+		return dst
+	}
+	parallel(0, height, func(ys <-chan int) {
+		for y := range ys {
+			row := dst.Pix[y*dst.Stride : y*dst.Stride+width*4]
+			src := t.Pix[(y%th)*t.Stride : (y%th)*t.Stride+tw*4]
+			for x := 0; x < width; x += tw {
+				copy(row[x*4:], src)
+			}
+		}
+	})
+	return dst
+}
+
+// NewPerlinNoise creates a new opaque grayscale image with the specified width and height
+// filled with the Perlin noise, e.g. for the textures of the clouds, marble or wood, or the
+// displacement and the masks. The scale is the size of the largest features in pixels, and
+// each of the octaves adds the features of half the size and half the contrast of the
+// previous one, the octaves < 1 are 1. The same seed creates the same noise.
+//
+// Example:
+//
+//	clouds := imaging.NewPerlinNoise(800, 600, 200, 5, 42)
+//	sky := imaging.Blend(imaging.New(800, 600, color.NRGBA{70, 130, 200, 255}), clouds, image.Pt(0, 0), imaging.BlendScreen, 0.8)
+func NewPerlinNoise(width, height int, scale float64, octaves int, seed int64) *image.NRGBA {
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
+	scale = math.Max(scale, 1)
+	octaves = maxint(octaves, 1)
+	var p perlin
+	for i, v := range rand.New(rand.NewSource(seed)).Perm(256) {
+		p[i], p[i+256] = uint8(v), uint8(v)
+	}
+
+	dst := newNRGBA(image.Rect(0, 0, width, height))
+	parallel(0, height, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < width; x++ {
+				var v, amp, sum float64 = 0, 1, 0
+				f := 1 / scale
+				for o := 0; o < octaves; o++ {
+					// The octaves are offset not to align their lattices at the origin.
+					v += amp * p.noise((float64(x)+0.5)*f+float64(o)*17.31, (float64(y)+0.5)*f+float64(o)*29.17)
+					sum += amp
+					amp /= 2
+					f *= 2
+				}
+				g := clamp((v/sum + 1) / 2 * 255)
+				d := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4 : y*dst.Stride+x*4+4]
+				d[0], d[1], d[2], d[3] = g, g, g, 0xff
+			}
+		}
+	})
+	return dst
+}
+
+// NewPlasma creates a new opaque image with the specified width and height filled with
+// the colorful plasma fractal of the midpoint displacement, with the hues cycling through
+// the colors. The same seed creates the same plasma.
+//
+// Example:
+//
+//	placeholder := imaging.NewPlasma(320, 240, time.Now().UnixNano())
+func NewPlasma(width, height int, seed int64) *image.NRGBA {
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
+	rnd := rand.New(rand.NewSource(seed))
+	// The diamond-square algorithm on the grid of 2^k+1 points covering the image.
+	n := 1
+	for n+1 < maxint(width, height) {
+		n *= 2
+	}
+	size := n + 1
+	grid := make([]float64, size*size)
+	at := func(x, y int) *float64 { return &grid[y*size+x] }
+	for _, c := range [4][2]int{{0, 0}, {n, 0}, {0, n}, {n, n}} {
+		*at(c[0], c[1]) = rnd.Float64()
+	}
+	amp := 1.0
+	for step := n; step > 1; step /= 2 {
+		half := step / 2
+		for y := half; y < size; y += step {
+			for x := half; x < size; x += step {
+				avg := (*at(x-half, y-half) + *at(x+half, y-half) + *at(x-half, y+half) + *at(x+half, y+half)) / 4
+				*at(x, y) = avg + (rnd.Float64()-0.5)*amp
+			}
+		}
+		for y := 0; y < size; y += half {
+			for x := (y/half + 1) % 2 * half; x < size; x += step {
+				var sum float64
+				var count int
+				for _, d := range [4][2]int{{-half, 0}, {half, 0}, {0, -half}, {0, half}} {
+					if nx, ny := x+d[0], y+d[1]; nx >= 0 && nx < size && ny >= 0 && ny < size {
+						sum += *at(nx, ny)
+						count++
+					}
+				}
+				*at(x, y) = sum/float64(count) + (rnd.Float64()-0.5)*amp
+			}
+		}
+		amp /= 2
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range grid {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	dst := newNRGBA(image.Rect(0, 0, width, height))
+	parallel(0, height, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < width; x++ {
+				h := 0.0
+				if hi > lo {
+					h = (*at(x, y) - lo) / (hi - lo)
+				}
+				r, g, b := hslToRGB(h, 0.8, 0.5)
+				d := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4 : y*dst.Stride+x*4+4]
+				d[0], d[1], d[2], d[3] = r, g, b, 0xff
+			}
+		}
+	})
+	return dst
+}
+
+// perlin is the permutation table of the Perlin noise, repeated twice.
+type perlin [512]uint8
+
+// noise returns the improved Perlin noise at the point, from -1 to 1.
+func (p *perlin) noise(x, y float64) float64 {
+	fx, fy := math.Floor(x), math.Floor(y)
+	xi, yi := int(fx)&255, int(fy)&255
+	x, y = x-fx, y-fy
+	u, v := perlinFade(x), perlinFade(y)
+	aa, ab := p[int(p[xi])+yi], p[int(p[xi])+yi+1]
+	ba, bb := p[int(p[xi+1])+yi], p[int(p[xi+1])+yi+1]
+	x1 := perlinLerp(u, perlinGrad(aa, x, y), perlinGrad(ba, x-1, y))
+	x2 := perlinLerp(u, perlinGrad(ab, x, y-1), perlinGrad(bb, x-1, y-1))
+	return perlinLerp(v, x1, x2)
+}
+
+func perlinFade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func perlinLerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+// perlinGrad returns the dot product of the offset and one of the 8 gradients chosen by
+// the hash, scaled so the noise spans from -1 to 1.
+func perlinGrad(hash uint8, x, y float64) float64 {
+	switch hash & 7 {
+	case 0:
+		return x + y
+	case 1:
+		return -x + y
+	case 2:
+		return x - y
+	case 3:
+		return -x - y
+	case 4:
+		return x * math.Sqrt2
+	case 5:
+		return -x * math.Sqrt2
+	case 6:
+		return y * math.Sqrt2
+	}
+	return -y * math.Sqrt2
+}
@@ -0,0 +1,123 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestNewCheckerboard(t *testing.T) {
+	got := NewCheckerboard(5, 3, 2, color.White, color.NRGBA{0x10, 0x20, 0x30, 0x80})
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 5, 3),
+		Stride: 5 * 4,
+		Pix: []uint8{
+			0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x10, 0x20, 0x30, 0x80, 0x10, 0x20, 0x30, 0x80, 0xff, 0xff, 0xff, 0xff,
+			0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x10, 0x20, 0x30, 0x80, 0x10, 0x20, 0x30, 0x80, 0xff, 0xff, 0xff, 0xff,
+			0x10, 0x20, 0x30, 0x80, 0x10, 0x20, 0x30, 0x80, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x10, 0x20, 0x30, 0x80,
+		},
+	}
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("got %v want %v", got.Pix, want.Pix)
+	}
+	if got := NewCheckerboard(2, 2, 0, color.White, color.Black); got.NRGBAAt(1, 0) != (color.NRGBA{0, 0, 0, 0xff}) {
+		t.Fatalf("got %v want squares of 1 pixel", got.Pix)
+	}
+	if got := NewCheckerboard(0, 3, 2, color.White, color.Black); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v want empty image", got.Rect)
+	}
+}
+
+func TestNewSolidPattern(t *testing.T) {
+	tile := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 1, 0),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+	}
+	got := NewSolidPattern(3, 2, tile)
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 3, 2),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x01, 0x02, 0x03, 0x04,
+			0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x01, 0x02, 0x03, 0x04,
+		},
+	}
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("got %v want %v", got.Pix, want.Pix)
+	}
+
+	got = NewSolidPattern(500, 400, testdataFlowersSmallPNG)
+	if !compareNRGBA(Crop(got, image.Rect(240, 160, 480, 320)), Clone(testdataFlowersSmallPNG), 0) {
+		t.Fatal("the tile isn't repeated")
+	}
+	if got := NewSolidPattern(3, 2, &image.NRGBA{}); !compareNRGBA(got, New(3, 2, color.Transparent), 0) {
+		t.Fatalf("got %v want transparent image", got.Pix)
+	}
+}
+
+func TestNewPerlinNoise(t *testing.T) {
+	img := NewPerlinNoise(128, 96, 32, 1, 7)
+	if img.Rect != image.Rect(0, 0, 128, 96) {
+		t.Fatalf("got bounds %v", img.Rect)
+	}
+	if !compareNRGBA(img, NewPerlinNoise(128, 96, 32, 1, 7), 0) {
+		t.Fatal("the same seed created a different noise")
+	}
+	if compareNRGBA(img, NewPerlinNoise(128, 96, 32, 1, 8), 0) {
+		t.Fatal("different seeds created the same noise")
+	}
+	// The single octave is smooth and spans the gray levels.
+	lo, hi, maxStep := 255, 0, 0
+	for y := 0; y < 96; y++ {
+		for x := 0; x < 128; x++ {
+			c := img.NRGBAAt(x, y)
+			if c.R != c.G || c.R != c.B || c.A != 0xff {
+				t.Fatalf("got %v want opaque gray", c)
+			}
+			lo, hi = minint(lo, int(c.R)), maxint(hi, int(c.R))
+			if x > 0 {
+				maxStep = maxint(maxStep, absint(int(c.R)-int(img.NRGBAAt(x-1, y).R)))
+			}
+		}
+	}
+	if hi-lo < 100 || maxStep > 16 {
+		t.Fatalf("got levels from %d to %d and the step %d want smooth noise", lo, hi, maxStep)
+	}
+	// The octaves add the detail.
+	if compareNRGBA(img, NewPerlinNoise(128, 96, 32, 4, 7), 2) {
+		t.Fatal("the octaves didn't change the noise")
+	}
+}
+
+func TestNewPlasma(t *testing.T) {
+	img := NewPlasma(100, 60, 3)
+	if img.Rect != image.Rect(0, 0, 100, 60) {
+		t.Fatalf("got bounds %v", img.Rect)
+	}
+	if !compareNRGBA(img, NewPlasma(100, 60, 3), 0) {
+		t.Fatal("the same seed created a different plasma")
+	}
+	if compareNRGBA(img, NewPlasma(100, 60, 4), 0) {
+		t.Fatal("different seeds created the same plasma")
+	}
+	colors := map[color.NRGBA]bool{}
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 100; x++ {
+			colors[img.NRGBAAt(x, y)] = true
+		}
+	}
+	if len(colors) < 500 {
+		t.Fatalf("got %d colors want a colorful image", len(colors))
+	}
+	if got := NewPlasma(1, 1, 3); got.Rect != image.Rect(0, 0, 1, 1) || got.Pix[3] != 0xff {
+		t.Fatalf("got %v want an opaque pixel", got)
+	}
+}
+
+func BenchmarkNewPerlinNoise(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewPerlinNoise(256, 256, 64, 4, 1)
+	}
+}
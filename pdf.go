@@ -0,0 +1,71 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"io"
+	"time"
+)
+
+// ErrPDFUnsupported means no PDF renderer is available to rasterize the page. The renderers
+// are external programs, so they are only used by the programs built with the imaging_exec
+// build tag: pdftoppm (poppler), mutool (MuPDF) or gs (Ghostscript) in PATH.
+var ErrPDFUnsupported = errors.New("imaging: PDF rendering requires the imaging_exec build tag and pdftoppm (poppler), mutool (MuPDF) or gs (Ghostscript) in PATH")
+
+var (
+	errInvalidPDF           = errors.New("imaging: invalid PDF data")
+	errInvalidPDFPage       = errors.New("imaging: invalid PDF page number")
+	errInvalidPDFResolution = errors.New("imaging: invalid PDF resolution")
+)
+
+// pdfTimeout is the time DecodePDF lets the renderer run.
+const pdfTimeout = time.Minute
+
+// DecodePDF reads a PDF document from r and rasterizes the page with the given number
+// (starting from 1) at the given resolution in DPI. It is DecodePDFContext with a timeout
+// of one minute.
+//
+// Example:
+//
+//	// Make a thumbnail of the first page.
+//	page, err := imaging.DecodePDF(file, 1, 50)
+//	if err == nil {
+//		thumb := imaging.Thumbnail(page, 200, 200, imaging.Lanczos)
+//	}
+func DecodePDF(r io.Reader, page int, dpi float64) (*image.NRGBA, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pdfTimeout)
+	defer cancel()
+	return DecodePDFContext(ctx, r, page, dpi)
+}
+
+// DecodePDFContext reads a PDF document from r and rasterizes the page with the given number
+// (starting from 1) at the given resolution in DPI. A PDF page of 8.5x11 inches rasterized
+// at 72 DPI is 612x792 pixels. The rendering is done by the first external renderer found
+// in PATH: pdftoppm (poppler), mutool (MuPDF) or gs (Ghostscript). The renderer is killed
+// when the context is done. The renderers are only run by the programs built with
+// the imaging_exec build tag, ErrPDFUnsupported is returned otherwise or if none of them
+// is installed.
+//
+// Example:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//	page, err := imaging.DecodePDFContext(ctx, file, 1, 150)
+func DecodePDFContext(ctx context.Context, r io.Reader, page int, dpi float64) (*image.NRGBA, error) {
+	if page < 1 {
+		return nil, errInvalidPDFPage
+	}
+	if dpi <= 0 {
+		return nil, errInvalidPDFResolution
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Contains(data[:minint(len(data), 1024)], []byte("%PDF-")) {
+		return nil, errInvalidPDF
+	}
+	return rasterizePDF(ctx, data, page, dpi)
+}
@@ -0,0 +1,82 @@
+//go:build imaging_exec
+
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pdfRenderers are the external programs used to rasterize PDF pages, in the order of preference.
+// The args function returns the command line arguments making the program write
+// the page of the file as PNG to the standard output.
+var pdfRenderers = []struct {
+	name string
+	args func(file, page, dpi string) []string
+}{
+	{"pdftoppm", func(file, page, dpi string) []string {
+		return []string{"-png", "-singlefile", "-f", page, "-l", page, "-r", dpi, file}
+	}},
+	{"mutool", func(file, page, dpi string) []string {
+		return []string{"draw", "-q", "-F", "png", "-r", dpi, "-o", "-", file, page}
+	}},
+	{"gs", func(file, page, dpi string) []string {
+		return []string{"-q", "-dSAFER", "-dBATCH", "-dNOPAUSE", "-sDEVICE=pngalpha",
+			"-r" + dpi, "-dFirstPage=" + page, "-dLastPage=" + page, "-sOutputFile=-", file}
+	}},
+}
+
+// rasterizePDF renders the page with the first renderer found in PATH.
+func rasterizePDF(ctx context.Context, data []byte, page int, dpi float64) (*image.NRGBA, error) {
+	for _, renderer := range pdfRenderers {
+		path, err := exec.LookPath(renderer.name)
+		if err != nil {
+			continue
+		}
+		return renderPDF(ctx, path, renderer.args, data, page, dpi)
+	}
+	return nil, ErrPDFUnsupported
+}
+
+// renderPDF runs the renderer on a temporary copy of the document and decodes its PNG output.
+func renderPDF(ctx context.Context, path string, args func(file, page, dpi string) []string, data []byte, page int, dpi float64) (*image.NRGBA, error) {
+	f, err := os.CreateTemp("", "imaging-*.pdf")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if errc := f.Close(); err == nil {
+		err = errc
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args(f.Name(), strconv.Itoa(page), strconv.FormatFloat(dpi, 'f', -1, 64))...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// The children of a killed renderer could keep the output open.
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("imaging: PDF rendering failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	img, err := png.Decode(&stdout)
+	if err != nil {
+		// The renderers write nothing for pages out of range.
+		return nil, fmt.Errorf("imaging: PDF rendering failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return Clone(img), nil
+}
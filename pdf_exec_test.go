@@ -0,0 +1,106 @@
+//go:build imaging_exec
+
+package imaging
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRenderer installs a script with the given name as the only program in PATH.
+// The script writes its arguments to the args file and the PNG file to the standard output.
+func fakeRenderer(t *testing.T, name, pngFile string, fail bool) (argsFile string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported")
+	}
+	cat, err := exec.LookPath("cat")
+	if err != nil {
+		t.Skip("cat is not found")
+	}
+	dir := t.TempDir()
+	argsFile = filepath.Join(dir, "args")
+	script := "#!/bin/sh\necho \"$@\" > " + argsFile + "\n"
+	if fail {
+		script += "echo 'Wrong page range given' >&2\nexit 1\n"
+	} else {
+		script += cat + " " + pngFile + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	t.Setenv("PATH", dir)
+	return argsFile
+}
+
+func TestDecodePDF(t *testing.T) {
+	pngFile, err := filepath.Abs("testdata/flowers_small.png")
+	if err != nil {
+		t.Fatalf("Abs: %v", err)
+	}
+	testCases := []struct {
+		renderer string
+		args     string
+	}{
+		{"pdftoppm", "-png -singlefile -f 3 -l 3 -r 150 "},
+		{"mutool", "draw -q -F png -r 150 -o - "},
+		{"gs", "-q -dSAFER -dBATCH -dNOPAUSE -sDEVICE=pngalpha -r150 -dFirstPage=3 -dLastPage=3 -sOutputFile=- "},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.renderer, func(t *testing.T) {
+			argsFile := fakeRenderer(t, tc.renderer, pngFile, false)
+			got, err := DecodePDF(strings.NewReader(testPDF), 3, 150)
+			if err != nil {
+				t.Fatalf("DecodePDF: %v", err)
+			}
+			if !compareNRGBA(got, Clone(testdataFlowersSmallPNG), 0) {
+				t.Fatal("the decoded page differs")
+			}
+			args, err := os.ReadFile(argsFile)
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+			if !strings.HasPrefix(string(args), tc.args) {
+				t.Fatalf("got arguments %q want prefix %q", args, tc.args)
+			}
+		})
+	}
+}
+
+func TestDecodePDFFails(t *testing.T) {
+	fakeRenderer(t, "pdftoppm", "", true)
+	if _, err := DecodePDF(strings.NewReader(testPDF), 9, 72); err == nil || !strings.Contains(err.Error(), "Wrong page range") {
+		t.Fatalf("got error %v want the renderer error", err)
+	}
+}
+
+func TestDecodePDFContext(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported")
+	}
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep is not found")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pdftoppm"), []byte("#!/bin/sh\nexec "+sleep+" 10\n"), 0755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	t.Setenv("PATH", dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := DecodePDFContext(ctx, strings.NewReader(testPDF), 1, 72); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v want %v", err, context.DeadlineExceeded)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("the renderer was not killed, took %v", d)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !imaging_exec
+
+package imaging
+
+import (
+	"context"
+	"image"
+)
+
+// rasterizePDF doesn't run the external renderers without the imaging_exec build tag.
+func rasterizePDF(ctx context.Context, data []byte, page int, dpi float64) (*image.NRGBA, error) {
+	return nil, ErrPDFUnsupported
+}
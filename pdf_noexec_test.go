@@ -0,0 +1,26 @@
+//go:build !imaging_exec
+
+package imaging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodePDFNoExec(t *testing.T) {
+	// The renderers in PATH are not run without the build tag.
+	pngFile, err := filepath.Abs("testdata/flowers_small.png")
+	if err != nil {
+		t.Fatalf("Abs: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pdftoppm"), []byte("#!/bin/sh\ncat "+pngFile+"\n"), 0755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	t.Setenv("PATH", dir)
+	if _, err := DecodePDF(strings.NewReader(testPDF), 1, 72); err != ErrPDFUnsupported {
+		t.Fatalf("got error %v want %v", err, ErrPDFUnsupported)
+	}
+}
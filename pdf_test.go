@@ -0,0 +1,25 @@
+package imaging
+
+import (
+	"strings"
+	"testing"
+)
+
+const testPDF = "%PDF-1.4\n1 0 obj << /Type /Catalog >> endobj\n%%EOF\n"
+
+func TestDecodePDFInvalid(t *testing.T) {
+	if _, err := DecodePDF(strings.NewReader(testPDF), 0, 72); err != errInvalidPDFPage {
+		t.Fatalf("got error %v want %v", err, errInvalidPDFPage)
+	}
+	if _, err := DecodePDF(strings.NewReader(testPDF), 1, 0); err != errInvalidPDFResolution {
+		t.Fatalf("got error %v want %v", err, errInvalidPDFResolution)
+	}
+	if _, err := DecodePDF(strings.NewReader("not a PDF"), 1, 72); err != errInvalidPDF {
+		t.Fatalf("got error %v want %v", err, errInvalidPDF)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if _, err := DecodePDF(strings.NewReader(testPDF), 1, 72); err != ErrPDFUnsupported {
+		t.Fatalf("got error %v want %v", err, ErrPDFUnsupported)
+	}
+}
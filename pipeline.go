@@ -0,0 +1,450 @@
+package imaging
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"io"
+)
+
+// Pipeline is a list of operations applied to images in order. The methods adding an
+// operation return a new pipeline and leave the receiver unchanged, so a pipeline can be
+// built once and applied to many images, also concurrently, and extended by several callers.
+//
+// Apply fuses the consecutive color adjustments into a single pass over the pixels, so
+// e.g. AdjustContrast followed by AdjustBrightness and AdjustSaturation scans the image
+// once. The result is the same as applying the operations one by one.
+//
+// Example:
+//
+//	p := imaging.NewPipeline().
+//		Resize(800, 0, imaging.Lanczos).
+//		Sharpen(0.5).
+//		AdjustContrast(10)
+//	for _, img := range images {
+//		dstImage := p.Apply(img)
+//		// ...
+//	}
+type Pipeline struct {
+	ops        []pipelineOp
+	hook       SpanHook
+	decodeOpts []DecodeOption
+}
+
+// pipelineOp is an operation of a pipeline: fn processes the whole image,
+// or if it's nil, adj adjusts the colors pixel by pixel. The name is the name of its span.
+type pipelineOp struct {
+	name string
+	fn   func(img image.Image) *image.NRGBA
+	adj  ColorAdjustment
+}
+
+// SpanHook starts a span of a stage of a pipeline traced with Trace. The name of the span is
+// the name of the stage: "imaging.Pipeline" for the whole pipeline, "imaging.Decode" and
+// "imaging.Encode" for the stages of Process, "imaging.AdjustColors" for the fused color
+// adjustments and the name of the method adding the operation for the other operations,
+// e.g. "imaging.Resize" or "imaging.Then". The hook returns the context of the span, which
+// is the parent of the spans of the stages, and the function ending the span with the error
+// of the stage, or nil.
+//
+// The hook matches the tracers of OpenTelemetry with a small adapter, and the timing of the
+// stages can be logged with a hook measuring the time to the end of the span.
+//
+// Example:
+//
+//	hook := func(ctx context.Context, name string) (context.Context, func(err error)) {
+//		ctx, span := otel.Tracer("thumbnails").Start(ctx, name)
+//		return ctx, func(err error) {
+//			if err != nil {
+//				span.RecordError(err)
+//				span.SetStatus(codes.Error, err.Error())
+//			}
+//			span.End()
+//		}
+//	}
+type SpanHook func(ctx context.Context, name string) (context.Context, func(err error))
+
+// NewPipeline returns an empty pipeline.
+func NewPipeline() *Pipeline {
+	return &Pipeline{}
+}
+
+// add returns a new pipeline with the operation appended.
+func (p *Pipeline) add(op pipelineOp) *Pipeline {
+	ops := make([]pipelineOp, len(p.ops), len(p.ops)+1)
+	copy(ops, p.ops)
+	return &Pipeline{ops: append(ops, op), hook: p.hook, decodeOpts: p.decodeOpts}
+}
+
+// Trace returns a new pipeline with the operations of the pipeline traced with the hook,
+// see SpanHook, and ApplyContext and Process report the spans of each operation.
+// The nil hook disables the tracing.
+//
+// Example:
+//
+//	p := imaging.NewPipeline().Fit(800, 800, imaging.Lanczos).Sharpen(0.5).Trace(hook)
+//	http.HandleFunc("/thumbnail", func(w http.ResponseWriter, r *http.Request) {
+//		w.Header().Set("Content-Type", "image/jpeg")
+//		if err := p.Process(r.Context(), r.Body, w, imaging.JPEG); err != nil {
+//			http.Error(w, err.Error(), http.StatusBadRequest)
+//		}
+//	})
+func (p *Pipeline) Trace(hook SpanHook) *Pipeline {
+	return &Pipeline{ops: p.ops, hook: hook, decodeOpts: p.decodeOpts}
+}
+
+// DecodeOptions returns a new pipeline decoding the images of Process with the options,
+// e.g. the limits of the untrusted uploads. The options replace the decode options of the
+// pipeline and the EXIF orientation is applied unless they disable it.
+//
+// Example:
+//
+//	p := imaging.NewPipeline().Fit(800, 800, imaging.Lanczos).DecodeOptions(imaging.MaxPixels(50e6))
+func (p *Pipeline) DecodeOptions(opts ...DecodeOption) *Pipeline {
+	return &Pipeline{ops: p.ops, hook: p.hook, decodeOpts: append([]DecodeOption(nil), opts...)}
+}
+
+// span starts the span of the stage if the pipeline is traced.
+func (p *Pipeline) span(ctx context.Context, name string) (context.Context, func(err error)) {
+	if p.hook == nil {
+		return ctx, func(error) {}
+	}
+	return p.hook(ctx, "imaging."+name)
+}
+
+// Then appends an operation processing the whole image, e.g. a function of the package
+// with its parameters bound. The function must return a new image not sharing the pixels
+// of its argument, as Apply releases the intermediate images, see Release.
+//
+// Example:
+//
+//	p = p.Then(func(img image.Image) *image.NRGBA {
+//		return imaging.Rotate(img, 30, color.Black)
+//	})
+func (p *Pipeline) Then(fn func(img image.Image) *image.NRGBA) *Pipeline {
+	return p.then("Then", fn)
+}
+
+// then appends an operation processing the whole image with the span name.
+func (p *Pipeline) then(name string, fn func(img image.Image) *image.NRGBA) *Pipeline {
+	return p.add(pipelineOp{name: name, fn: fn})
+}
+
+// Resize appends Resize.
+func (p *Pipeline) Resize(width, height int, filter ResampleFilter) *Pipeline {
+	return p.then("Resize", func(img image.Image) *image.NRGBA {
+		return Resize(img, width, height, filter)
+	})
+}
+
+// Fit appends Fit.
+func (p *Pipeline) Fit(width, height int, filter ResampleFilter) *Pipeline {
+	return p.then("Fit", func(img image.Image) *image.NRGBA {
+		return Fit(img, width, height, filter)
+	})
+}
+
+// Fill appends Fill.
+func (p *Pipeline) Fill(width, height int, anchor Anchor, filter ResampleFilter) *Pipeline {
+	return p.then("Fill", func(img image.Image) *image.NRGBA {
+		return Fill(img, width, height, anchor, filter)
+	})
+}
+
+// Thumbnail appends Thumbnail.
+func (p *Pipeline) Thumbnail(width, height int, filter ResampleFilter) *Pipeline {
+	return p.then("Thumbnail", func(img image.Image) *image.NRGBA {
+		return Thumbnail(img, width, height, filter)
+	})
+}
+
+// ResizeLineArt appends ResizeLineArt.
+func (p *Pipeline) ResizeLineArt(width, height int) *Pipeline {
+	return p.then("ResizeLineArt", func(img image.Image) *image.NRGBA {
+		return ResizeLineArt(img, width, height)
+	})
+}
+
+// ResizeSubpixel appends ResizeSubpixel.
+func (p *Pipeline) ResizeSubpixel(width, height int, layout SubpixelLayout, filter ResampleFilter) *Pipeline {
+	return p.then("ResizeSubpixel", func(img image.Image) *image.NRGBA {
+		return ResizeSubpixel(img, width, height, layout, filter)
+	})
+}
+
+// Crop appends Crop.
+func (p *Pipeline) Crop(rect image.Rectangle) *Pipeline {
+	return p.then("Crop", func(img image.Image) *image.NRGBA {
+		return Crop(img, rect)
+	})
+}
+
+// CropAnchor appends CropAnchor.
+func (p *Pipeline) CropAnchor(width, height int, anchor Anchor) *Pipeline {
+	return p.then("CropAnchor", func(img image.Image) *image.NRGBA {
+		return CropAnchor(img, width, height, anchor)
+	})
+}
+
+// Trim appends Trim.
+func (p *Pipeline) Trim(tolerance int) *Pipeline {
+	return p.then("Trim", func(img image.Image) *image.NRGBA {
+		return Trim(img, tolerance)
+	})
+}
+
+// Blur appends Blur.
+func (p *Pipeline) Blur(sigma float64) *Pipeline {
+	return p.then("Blur", func(img image.Image) *image.NRGBA {
+		return Blur(img, sigma)
+	})
+}
+
+// BlurWhere appends BlurWhere.
+func (p *Pipeline) BlurWhere(mask PixelMask, sigma float64) *Pipeline {
+	return p.then("BlurWhere", func(img image.Image) *image.NRGBA {
+		return BlurWhere(img, mask, sigma)
+	})
+}
+
+// Sharpen appends Sharpen.
+func (p *Pipeline) Sharpen(sigma float64) *Pipeline {
+	return p.then("Sharpen", func(img image.Image) *image.NRGBA {
+		return Sharpen(img, sigma)
+	})
+}
+
+// UnsharpMask appends UnsharpMask.
+func (p *Pipeline) UnsharpMask(radius, amount float64, threshold uint8) *Pipeline {
+	return p.then("UnsharpMask", func(img image.Image) *image.NRGBA {
+		return UnsharpMask(img, radius, amount, threshold)
+	})
+}
+
+// Vignette appends Vignette.
+func (p *Pipeline) Vignette(strength float64, c color.Color) *Pipeline {
+	return p.then("Vignette", func(img image.Image) *image.NRGBA {
+		return Vignette(img, strength, c)
+	})
+}
+
+// AddBorder appends AddBorder.
+func (p *Pipeline) AddBorder(width int, c color.Color, style BorderStyle) *Pipeline {
+	return p.then("AddBorder", func(img image.Image) *image.NRGBA {
+		return AddBorder(img, width, c, style)
+	})
+}
+
+// RoundCorners appends RoundCorners.
+func (p *Pipeline) RoundCorners(radius float64) *Pipeline {
+	return p.then("RoundCorners", func(img image.Image) *image.NRGBA {
+		return RoundCorners(img, radius)
+	})
+}
+
+// ApplyMask appends ApplyMask.
+func (p *Pipeline) ApplyMask(mask image.Image) *Pipeline {
+	return p.then("ApplyMask", func(img image.Image) *image.NRGBA {
+		return ApplyMask(img, mask)
+	})
+}
+
+// AdjustOpacity appends AdjustOpacity.
+func (p *Pipeline) AdjustOpacity(factor float64) *Pipeline {
+	return p.then("AdjustOpacity", func(img image.Image) *image.NRGBA {
+		return AdjustOpacity(img, factor)
+	})
+}
+
+// FlipH appends FlipH.
+func (p *Pipeline) FlipH() *Pipeline {
+	return p.then("FlipH", FlipH)
+}
+
+// FlipV appends FlipV.
+func (p *Pipeline) FlipV() *Pipeline {
+	return p.then("FlipV", FlipV)
+}
+
+// Rotate90 appends Rotate90.
+func (p *Pipeline) Rotate90() *Pipeline {
+	return p.then("Rotate90", Rotate90)
+}
+
+// Rotate180 appends Rotate180.
+func (p *Pipeline) Rotate180() *Pipeline {
+	return p.then("Rotate180", Rotate180)
+}
+
+// Rotate270 appends Rotate270.
+func (p *Pipeline) Rotate270() *Pipeline {
+	return p.then("Rotate270", Rotate270)
+}
+
+// Rotate appends Rotate.
+func (p *Pipeline) Rotate(angle float64, bgColor color.Color) *Pipeline {
+	return p.then("Rotate", func(img image.Image) *image.NRGBA {
+		return Rotate(img, angle, bgColor)
+	})
+}
+
+// AdjustColors appends the color adjustments, see AdjustColors.
+func (p *Pipeline) AdjustColors(adjustments ...ColorAdjustment) *Pipeline {
+	for _, adj := range adjustments {
+		if adj.lut != nil || adj.pixel != nil {
+			p = p.add(pipelineOp{name: "AdjustColors", adj: adj})
+		}
+	}
+	return p
+}
+
+// AdjustFunc appends AdjustFunc. The function is fused with the neighboring color adjustments.
+func (p *Pipeline) AdjustFunc(fn func(c color.NRGBA) color.NRGBA) *Pipeline {
+	return p.AdjustColors(FuncAdjustment(fn))
+}
+
+// Grayscale appends Grayscale.
+func (p *Pipeline) Grayscale() *Pipeline {
+	return p.AdjustColors(GrayscaleAdjustment())
+}
+
+// Invert appends Invert.
+func (p *Pipeline) Invert() *Pipeline {
+	return p.AdjustColors(InvertAdjustment())
+}
+
+// Sepia appends Sepia.
+func (p *Pipeline) Sepia(percentage float64) *Pipeline {
+	return p.AdjustColors(SepiaAdjustment(percentage))
+}
+
+// BlackAndWhite appends BlackAndWhite.
+func (p *Pipeline) BlackAndWhite(filter BWFilter) *Pipeline {
+	return p.AdjustColors(BlackAndWhiteAdjustment(filter))
+}
+
+// AdjustSaturation appends AdjustSaturation.
+func (p *Pipeline) AdjustSaturation(percentage float64, opts ...AdjustOption) *Pipeline {
+	return p.AdjustColors(SaturationAdjustment(percentage, opts...))
+}
+
+// AdjustHue appends AdjustHue.
+func (p *Pipeline) AdjustHue(shift float64, opts ...AdjustOption) *Pipeline {
+	return p.AdjustColors(HueAdjustment(shift, opts...))
+}
+
+// AdjustContrast appends AdjustContrast.
+func (p *Pipeline) AdjustContrast(percentage float64, opts ...AdjustOption) *Pipeline {
+	return p.AdjustColors(ContrastAdjustment(percentage, opts...))
+}
+
+// AdjustBrightness appends AdjustBrightness.
+func (p *Pipeline) AdjustBrightness(percentage float64, opts ...AdjustOption) *Pipeline {
+	return p.AdjustColors(BrightnessAdjustment(percentage, opts...))
+}
+
+// AdjustGamma appends AdjustGamma.
+func (p *Pipeline) AdjustGamma(gamma float64) *Pipeline {
+	return p.AdjustColors(GammaAdjustment(gamma))
+}
+
+// AdjustSigmoid appends AdjustSigmoid.
+func (p *Pipeline) AdjustSigmoid(midpoint, factor float64) *Pipeline {
+	return p.AdjustColors(SigmoidAdjustment(midpoint, factor))
+}
+
+// Posterize appends Posterize.
+func (p *Pipeline) Posterize(levels int) *Pipeline {
+	return p.AdjustColors(PosterizeAdjustment(levels))
+}
+
+// Solarize appends Solarize.
+func (p *Pipeline) Solarize(threshold uint8) *Pipeline {
+	return p.AdjustColors(SolarizeAdjustment(threshold))
+}
+
+// ToneMap appends ToneMap. The colors are mapped with 8-bit precision like the other
+// color adjustments, ToneMap keeps the precision of the 16-bit images.
+func (p *Pipeline) ToneMap(operator ToneMapOperator, exposure float64) *Pipeline {
+	return p.AdjustColors(ToneMapAdjustment(operator, exposure))
+}
+
+// Apply applies the operations of the pipeline to the image and returns the resulting
+// image. An empty pipeline returns a copy of the image.
+func (p *Pipeline) Apply(img image.Image) *image.NRGBA {
+	return p.ApplyContext(context.Background(), img)
+}
+
+// ApplyContext applies the pipeline to the image like Apply, the spans of the traced
+// pipeline are the children of the span of the context, see Trace.
+func (p *Pipeline) ApplyContext(ctx context.Context, img image.Image) *image.NRGBA {
+	ctx, end := p.span(ctx, "Pipeline")
+	defer end(nil)
+	return p.apply(ctx, img)
+}
+
+// Process decodes the image from r like Decode with the EXIF orientation applied and the
+// decode options of the pipeline, see DecodeOptions, applies the pipeline to it and encodes
+// the result to w in the format with the options like Encode. The spans of the traced
+// pipeline include the decoding and the encoding, see Trace.
+//
+// Example:
+//
+//	err := p.Process(ctx, upload, w, imaging.JPEG, imaging.JPEGQuality(85))
+func (p *Pipeline) Process(ctx context.Context, r io.Reader, w io.Writer, format Format, opts ...EncodeOption) (err error) {
+	ctx, end := p.span(ctx, "Pipeline")
+	defer func() { end(err) }()
+
+	_, endDecode := p.span(ctx, "Decode")
+	img, err := Decode(r, append([]DecodeOption{AutoOrientation(true)}, p.decodeOpts...)...)
+	endDecode(err)
+	if err != nil {
+		return err
+	}
+
+	dst := p.apply(ctx, img)
+	defer Release(dst)
+
+	_, endEncode := p.span(ctx, "Encode")
+	err = Encode(w, dst, format, opts...)
+	endEncode(err)
+	return err
+}
+
+// apply applies the operations to the image.
+func (p *Pipeline) apply(ctx context.Context, img image.Image) *image.NRGBA {
+	var dst *image.NRGBA
+	for i := 0; i < len(p.ops); {
+		_, end := p.span(ctx, p.ops[i].name)
+		var next *image.NRGBA
+		if p.ops[i].fn != nil {
+			next = p.ops[i].fn(img)
+			i++
+		} else {
+			j := i + 1
+			adjustments := []ColorAdjustment{p.ops[i].adj}
+			for ; j < len(p.ops) && p.ops[j].fn == nil; j++ {
+				adjustments = append(adjustments, p.ops[j].adj)
+			}
+			if dst != nil {
+				// The intermediate image is adjusted without a copy.
+				AdjustColorsInPlace(dst, adjustments...)
+				next = dst
+			} else {
+				next = AdjustColors(img, adjustments...)
+			}
+			i = j
+		}
+		end(nil)
+		// The intermediate images aren't returned, so they are reused.
+		if dst != nil && dst != next {
+			Release(dst)
+		}
+		dst = next
+		img = next
+	}
+	if dst == nil {
+		return Clone(img)
+	}
+	return dst
+}
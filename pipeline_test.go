@@ -0,0 +1,283 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	img := testdataFlowersSmallPNG
+	testCases := []struct {
+		name string
+		p    *Pipeline
+		want func(img image.Image) *image.NRGBA
+	}{
+		{
+			"empty",
+			NewPipeline(),
+			Clone,
+		},
+		{
+			"resize sharpen contrast",
+			NewPipeline().Resize(120, 0, Lanczos).Sharpen(0.5).AdjustContrast(10),
+			func(img image.Image) *image.NRGBA {
+				return AdjustContrast(Sharpen(Resize(img, 120, 0, Lanczos), 0.5), 10)
+			},
+		},
+		{
+			"unsharp mask",
+			NewPipeline().UnsharpMask(1.5, 120, 4).AdjustGamma(0.9),
+			func(img image.Image) *image.NRGBA {
+				return AdjustGamma(UnsharpMask(img, 1.5, 120, 4), 0.9)
+			},
+		},
+		{
+			"card",
+			NewPipeline().Vignette(0.5, color.Black).AddBorder(6, color.White, BorderRounded).AddBorder(9, color.NRGBA{0, 0, 0, 96}, BorderShadow),
+			func(img image.Image) *image.NRGBA {
+				return AddBorder(AddBorder(Vignette(img, 0.5, color.Black), 6, color.White, BorderRounded), 9, color.NRGBA{0, 0, 0, 96}, BorderShadow)
+			},
+		},
+		{
+			"avatar",
+			NewPipeline().Fill(64, 64, Center, Linear).RoundCorners(32).ApplyMask(New(64, 48, color.Gray{200})).AdjustOpacity(0.8),
+			func(img image.Image) *image.NRGBA {
+				return AdjustOpacity(ApplyMask(RoundCorners(Fill(img, 64, 64, Center, Linear), 32), New(64, 48, color.Gray{200})), 0.8)
+			},
+		},
+		{
+			"black and white",
+			NewPipeline().BlackAndWhite(BWRed).AdjustContrast(20),
+			func(img image.Image) *image.NRGBA {
+				return AdjustContrast(BlackAndWhite(img, BWRed), 20)
+			},
+		},
+		{
+			"fused lookup tables",
+			NewPipeline().AdjustContrast(20).AdjustBrightness(-10).AdjustGamma(1.5).AdjustSigmoid(0.5, 3).Posterize(8),
+			func(img image.Image) *image.NRGBA {
+				return Posterize(AdjustSigmoid(AdjustGamma(AdjustBrightness(AdjustContrast(img, 20), -10), 1.5), 0.5, 3), 8)
+			},
+		},
+		{
+			"fused lookup tables and pixel functions",
+			NewPipeline().AdjustBrightness(10).AdjustSaturation(30).Invert().Solarize(100).AdjustHue(45).Sepia(50).Grayscale(),
+			func(img image.Image) *image.NRGBA {
+				return Grayscale(Sepia(AdjustHue(Solarize(Invert(AdjustSaturation(AdjustBrightness(img, 10), 30)), 100), 45), 50))
+			},
+		},
+		{
+			"no-op adjustments",
+			NewPipeline().AdjustContrast(0).AdjustBrightness(0).AdjustGamma(1).AdjustSigmoid(0.5, 0).
+				AdjustSaturation(0).AdjustHue(360).Sepia(0).Posterize(256),
+			Clone,
+		},
+		{
+			"geometry",
+			NewPipeline().Crop(image.Rect(10, 10, 200, 150)).FlipH().FlipV().Rotate90().Rotate180().Rotate270().
+				Rotate(30, color.Black).CropAnchor(100, 100, Center).Fit(80, 80, Box).Fill(50, 40, Top, Linear).
+				Thumbnail(20, 20, NearestNeighbor).Blur(1),
+			func(img image.Image) *image.NRGBA {
+				dst := Crop(img, image.Rect(10, 10, 200, 150))
+				dst = Rotate270(Rotate180(Rotate90(FlipV(FlipH(dst)))))
+				dst = CropAnchor(Rotate(dst, 30, color.Black), 100, 100, Center)
+				dst = Thumbnail(Fill(Fit(dst, 80, 80, Box), 50, 40, Top, Linear), 20, 20, NearestNeighbor)
+				return Blur(dst, 1)
+			},
+		},
+		{
+			"selective blur",
+			NewPipeline().BlurWhere(LuminanceRange(128, 255, 32), 2).Sharpen(1),
+			func(img image.Image) *image.NRGBA {
+				return Sharpen(BlurWhere(img, LuminanceRange(128, 255, 32), 2), 1)
+			},
+		},
+		{
+			"custom operations",
+			NewPipeline().
+				AdjustFunc(func(c color.NRGBA) color.NRGBA { return color.NRGBA{c.G, c.B, c.R, c.A} }).
+				Then(func(img image.Image) *image.NRGBA { return Transpose(img) }).
+				AdjustFunc(func(c color.NRGBA) color.NRGBA { return color.NRGBA{c.R, c.G, c.B, c.A / 2} }),
+			func(img image.Image) *image.NRGBA {
+				dst := AdjustFunc(img, func(c color.NRGBA) color.NRGBA { return color.NRGBA{c.G, c.B, c.R, c.A} })
+				dst = Transpose(dst)
+				return AdjustFunc(dst, func(c color.NRGBA) color.NRGBA { return color.NRGBA{c.R, c.G, c.B, c.A / 2} })
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.p.Apply(img)
+			want := tc.want(img)
+			if !compareNRGBA(got, want, 0) {
+				t.Fatal("the pipeline differs from the sequential operations")
+			}
+		})
+	}
+}
+
+func TestPipelineImmutable(t *testing.T) {
+	base := NewPipeline().AdjustContrast(10)
+	grayscale := base.Grayscale()
+	inverted := base.Invert()
+	if len(base.ops) != 1 || len(grayscale.ops) != 2 || len(inverted.ops) != 2 {
+		t.Fatal("the pipeline is changed by adding an operation")
+	}
+
+	img := testdataFlowersSmallPNG
+	if !compareNRGBA(grayscale.Apply(img), Grayscale(AdjustContrast(img, 10)), 0) {
+		t.Fatal("the derived pipelines share the operations")
+	}
+	if !compareNRGBA(inverted.Apply(img), Invert(AdjustContrast(img, 10)), 0) {
+		t.Fatal("the derived pipelines share the operations")
+	}
+}
+
+func TestPipelineConcurrent(t *testing.T) {
+	EnablePooling(true)
+	defer EnablePooling(false)
+
+	p := NewPipeline().Resize(100, 0, Lanczos).AdjustBrightness(10).Blur(0.5).AdjustSaturation(20)
+	want := AdjustSaturation(Blur(AdjustBrightness(Resize(testdataFlowersSmallPNG, 100, 0, Lanczos), 10), 0.5), 20)
+	var wg sync.WaitGroup
+	errs := make(chan string, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 4; j++ {
+				if !compareNRGBA(p.Apply(testdataFlowersSmallPNG), want, 0) {
+					errs <- "the result differs"
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// spanRecorder records the spans of a traced pipeline as "parent>name: error".
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+type spanKey struct{}
+
+func (r *spanRecorder) hook(ctx context.Context, name string) (context.Context, func(err error)) {
+	parent, _ := ctx.Value(spanKey{}).(string)
+	return context.WithValue(ctx, spanKey{}, name), func(err error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.spans = append(r.spans, fmt.Sprintf("%s>%s: %v", parent, name, err))
+	}
+}
+
+func TestPipelineTrace(t *testing.T) {
+	var r spanRecorder
+	p := NewPipeline().Resize(100, 0, Lanczos).AdjustBrightness(10).AdjustGamma(1.2).Then(Grayscale).Trace(r.hook).FlipH()
+	img := testdataFlowersSmallPNG
+	want := FlipH(Grayscale(AdjustGamma(AdjustBrightness(Resize(img, 100, 0, Lanczos), 10), 1.2)))
+	ctx := context.WithValue(context.Background(), spanKey{}, "request")
+	if got := p.ApplyContext(ctx, img); !compareNRGBA(got, want, 0) {
+		t.Fatal("the traced pipeline differs")
+	}
+	wantSpans := []string{
+		"imaging.Pipeline>imaging.Resize: <nil>",
+		"imaging.Pipeline>imaging.AdjustColors: <nil>",
+		"imaging.Pipeline>imaging.Then: <nil>",
+		"imaging.Pipeline>imaging.FlipH: <nil>",
+		"request>imaging.Pipeline: <nil>",
+	}
+	if fmt.Sprint(r.spans) != fmt.Sprint(wantSpans) {
+		t.Fatalf("got spans %q want %q", r.spans, wantSpans)
+	}
+
+	// The untraced pipelines report nothing.
+	r.spans = nil
+	if !compareNRGBA(p.Trace(nil).Apply(img), want, 0) || len(r.spans) != 0 {
+		t.Fatalf("got spans %q", r.spans)
+	}
+}
+
+func TestPipelineProcess(t *testing.T) {
+	var r spanRecorder
+	p := NewPipeline().Fit(50, 50, Box).Trace(r.hook)
+	var src, dst bytes.Buffer
+	if err := Encode(&src, testdataFlowersSmallPNG, PNG); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if err := p.Process(context.Background(), &src, &dst, PNG); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	img, err := Decode(&dst)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !compareNRGBA(Clone(img), Fit(testdataFlowersSmallPNG, 50, 50, Box), 0) {
+		t.Fatal("the processed image differs")
+	}
+	wantSpans := []string{
+		"imaging.Pipeline>imaging.Decode: <nil>",
+		"imaging.Pipeline>imaging.Fit: <nil>",
+		"imaging.Pipeline>imaging.Encode: <nil>",
+		">imaging.Pipeline: <nil>",
+	}
+	if fmt.Sprint(r.spans) != fmt.Sprint(wantSpans) {
+		t.Fatalf("got spans %q want %q", r.spans, wantSpans)
+	}
+
+	// The errors end the spans of their stages and of the pipeline.
+	r.spans = nil
+	err = p.Process(context.Background(), strings.NewReader("not an image"), &dst, PNG)
+	if err == nil || len(r.spans) != 2 || !strings.HasSuffix(r.spans[0], err.Error()) || !strings.HasSuffix(r.spans[1], err.Error()) {
+		t.Fatalf("got error %v and spans %q", err, r.spans)
+	}
+}
+
+func TestPipelineDecodeOptions(t *testing.T) {
+	var src bytes.Buffer
+	if err := Encode(&src, testdataFlowersSmallPNG, PNG); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	bounds := testdataFlowersSmallPNG.Bounds()
+
+	// The options are kept by the operations added later.
+	p := NewPipeline().DecodeOptions(MaxPixels(100)).Fit(50, 50, Box).Trace(nil)
+	var dst bytes.Buffer
+	err := p.Process(context.Background(), bytes.NewReader(src.Bytes()), &dst, PNG)
+	var tooLarge *ImageTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Width != bounds.Dx() || tooLarge.Height != bounds.Dy() {
+		t.Fatalf("got error %v want an *ImageTooLargeError", err)
+	}
+
+	if err := p.DecodeOptions().Process(context.Background(), bytes.NewReader(src.Bytes()), &dst, PNG); err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	p := NewPipeline().AdjustContrast(10).AdjustBrightness(5).AdjustGamma(1.2).AdjustSaturation(20)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.Apply(testdataBranchesJPG)
+	}
+}
+
+func BenchmarkPipelineSequential(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		AdjustSaturation(AdjustGamma(AdjustBrightness(AdjustContrast(testdataBranchesJPG, 10), 5), 1.2), 20)
+	}
+}
@@ -0,0 +1,301 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// Placement is a candidate region for a watermark or a logo found by FindPlacements.
+type Placement struct {
+	Anchor Anchor
+	Rect   image.Rectangle
+
+	// Detail is the mean gradient magnitude of the region from 0 to 1. Flat areas like sky
+	// are close to 0, text and fine textures have a high detail.
+	Detail float64
+
+	// Brightness is the mean luminance of the region from 0 to 1. It can be used to choose
+	// a light or a dark mark for it to remain legible.
+	Brightness float64
+
+	// Risk is how easily a visible mark in the region could be removed, see WatermarkRemovalRisk.
+	// The calm corners preferred by FindPlacements are the easiest to crop and inpaint, so
+	// the placements of the protective watermarks can be chosen by weighing it against Detail.
+	Risk RemovalRisk
+}
+
+// RemovalRisk estimates how easily a visible watermark could be removed from an image,
+// each score is from 0 (hard) to 1 (trivial).
+type RemovalRisk struct {
+	// Crop is how little of the image is lost by cropping the mark away: close to 1 for
+	// a thin mark along a border, 0 when the crop loses a quarter of the image or more.
+	Crop float64
+
+	// Inpaint is how easily the mark could be painted over from its surroundings: high for
+	// the small marks over flat areas, low for the marks covering a large part of the image
+	// or a detailed area whose texture is hard to reconstruct.
+	Inpaint float64
+
+	// Score is the overall risk, the probability that either way succeeds if the scores
+	// are taken as probabilities.
+	Score float64
+}
+
+// placementAnchors are the candidate positions, the corners come first so that they win ties.
+var placementAnchors = [8]Anchor{BottomRight, BottomLeft, TopRight, TopLeft, Bottom, Right, Top, Left}
+
+// FindPlacements finds where a mark of the given size can be placed along the corners and
+// the edges of the image, at margin pixels from its borders. The candidate regions are sorted
+// by their detail in ascending order, so that the first one is the calmest region where the mark
+// is legible and covers no text or important detail. It returns nil if the mark doesn't fit.
+//
+// Example:
+//
+//	placements := imaging.FindPlacements(srcImage, logo.Bounds().Dx(), logo.Bounds().Dy(), 16)
+//	if len(placements) > 0 {
+//		dstImage = imaging.Overlay(srcImage, logo, placements[0].Rect.Min, 0.5)
+//	}
+func FindPlacements(img image.Image, width, height, margin int) []Placement {
+	if margin < 0 {
+		return nil
+	}
+	b := img.Bounds()
+	inner := image.Rectangle{Min: image.Pt(margin, margin), Max: image.Pt(b.Dx()-margin, b.Dy()-margin)}
+	pm := newPlacementMap(img)
+	return pm.placements(width, height, inner)
+}
+
+// WatermarkRemovalRisk scores how easily a visible watermark placed in the rectangle of
+// the image could be removed by cropping or inpainting. The rectangle is in the image
+// coordinates, like the Rect of a Placement. The marks near the borders are cropped
+// away at a small loss, and the small marks over flat areas are inpainted away, so
+// the marks crossing the detailed center of the image are the hardest to remove.
+// The rectangle outside the image has the risk of 1.
+//
+// Example:
+//
+//	// Prefer the placement that is the hardest to remove.
+//	placements := imaging.FindPlacements(srcImage, mark.Bounds().Dx(), mark.Bounds().Dy(), 16)
+//	sort.SliceStable(placements, func(i, j int) bool { return placements[i].Risk.Score < placements[j].Risk.Score })
+//
+//	// Check a position chosen by the user.
+//	if risk := imaging.WatermarkRemovalRisk(srcImage, rect); risk.Score > 0.8 {
+//		log.Printf("the watermark is easy to remove: %+v", risk)
+//	}
+func WatermarkRemovalRisk(img image.Image, rect image.Rectangle) RemovalRisk {
+	b := img.Bounds()
+	r := rect.Intersect(b).Sub(b.Min)
+	if r.Empty() {
+		return RemovalRisk{Crop: 1, Inpaint: 1, Score: 1}
+	}
+	return newPlacementMap(img).removalRisk(r)
+}
+
+// SafeArea restricts where SafePosition and OverlaySafe place an overlay.
+type SafeArea struct {
+	// Top, Right, Bottom and Left are the minimum distances between the overlay
+	// and the borders of the background.
+	Top, Right, Bottom, Left int
+
+	// Exclude is an optional mask of the background areas the overlay must not cover,
+	// e.g. the detected faces. Its pixels with a luminance of at least 128 are excluded,
+	// like the foreground of FindContours. The mask is aligned with the top-left corner
+	// of the background.
+	Exclude image.Image
+}
+
+// SafePosition returns the position of an overlay of the given size over the background
+// that keeps the margins of the safe area and covers no excluded pixels. The corners and
+// the edges are preferred in the order of FindPlacements. If they are all excluded, the free
+// position closest to a corner is returned. The position is in the background coordinates,
+// ready for Overlay. It returns false if there is no such position.
+//
+// Example:
+//
+//	area := imaging.SafeArea{Top: 20, Right: 20, Bottom: 20, Left: 20, Exclude: faceMask}
+//	pos, ok := imaging.SafePosition(srcImage, badge.Bounds().Dx(), badge.Bounds().Dy(), area)
+func SafePosition(background image.Image, width, height int, area SafeArea) (image.Point, bool) {
+	b := background.Bounds()
+	inner := image.Rectangle{
+		Min: image.Pt(area.Left, area.Top),
+		Max: image.Pt(b.Dx()-area.Right, b.Dy()-area.Bottom),
+	}
+	if area.Top < 0 || area.Right < 0 || area.Bottom < 0 || area.Left < 0 ||
+		width <= 0 || height <= 0 || width > inner.Dx() || height > inner.Dy() {
+		return image.Point{}, false
+	}
+	pm := newPlacementMap(background)
+	if area.Exclude == nil {
+		return pm.placements(width, height, inner)[0].Rect.Min, true
+	}
+
+	excluded := make([]float64, b.Dx()*b.Dy())
+	mask := newScanner(area.Exclude)
+	mw, mh := minint(mask.w, b.Dx()), minint(mask.h, b.Dy())
+	parallel(0, mh, func(ys <-chan int) {
+		scanLine := make([]uint8, mw*4)
+		for y := range ys {
+			mask.scan(0, y, mw, y+1, scanLine)
+			for x := 0; x < mw; x++ {
+				s := scanLine[x*4 : x*4+4 : x*4+4]
+				lum := (0.299*float64(s[0]) + 0.587*float64(s[1]) + 0.114*float64(s[2])) * float64(s[3]) / 255
+				if lum >= 127.5 {
+					excluded[y*b.Dx()+x] = 1
+				}
+			}
+		}
+	})
+	covered := newSummedArea(excluded, b.Dx(), b.Dy())
+
+	for _, p := range pm.placements(width, height, inner) {
+		if covered.sum(p.Rect.Sub(b.Min)) < 0.5 {
+			return p.Rect.Min, true
+		}
+	}
+
+	// Find the free position with the smallest distances to the horizontal
+	// and the vertical borders, the calmer one wins ties.
+	best, found := image.Point{}, false
+	bestDist, bestDetail := 0, 0.0
+	for y := inner.Min.Y; y+height <= inner.Max.Y; y++ {
+		for x := inner.Min.X; x+width <= inner.Max.X; x++ {
+			r := image.Rect(x, y, x+width, y+height)
+			dist := minint(x-inner.Min.X, inner.Max.X-r.Max.X) + minint(y-inner.Min.Y, inner.Max.Y-r.Max.Y)
+			if found && dist > bestDist {
+				continue
+			}
+			if covered.sum(r) >= 0.5 {
+				continue
+			}
+			detail := pm.grad.sum(r)
+			if !found || dist < bestDist || detail < bestDetail {
+				best, found = r.Min, true
+				bestDist, bestDetail = dist, detail
+			}
+		}
+	}
+	return best.Add(b.Min), found
+}
+
+// OverlaySafe draws the img image over the background image at the position returned by
+// SafePosition and returns the combined image. Opacity parameter is the opacity of the img
+// image layer, used to compose the images, it must be from 0.0 to 1.0. If there is no safe
+// position, it returns a copy of the background and false.
+//
+// Example:
+//
+//	dstImage, ok := imaging.OverlaySafe(srcImage, badge, imaging.SafeArea{Bottom: 40, Exclude: faceMask}, 1.0)
+func OverlaySafe(background, img image.Image, area SafeArea, opacity float64) (*image.NRGBA, bool) {
+	pos, ok := SafePosition(background, img.Bounds().Dx(), img.Bounds().Dy(), area)
+	if !ok {
+		return Clone(background), false
+	}
+	return Overlay(background, img, pos, opacity), true
+}
+
+// placementMap holds the summed-area tables of an image used to evaluate mark placements.
+type placementMap struct {
+	min       image.Point
+	w, h      int
+	lum, grad *summedArea
+}
+
+// newPlacementMap computes the luminance and the Sobel gradient magnitude of the image.
+func newPlacementMap(img image.Image) *placementMap {
+	lum, w, h := luminancePlane(img)
+	grad := make([]float64, w*h)
+	parallel(1, h-1, func(ys <-chan int) {
+		for y := range ys {
+			for x := 1; x < w-1; x++ {
+				i := y*w + x
+				gx := (lum[i-w+1] + 2*lum[i+1] + lum[i+w+1]) - (lum[i-w-1] + 2*lum[i-1] + lum[i+w-1])
+				gy := (lum[i+w-1] + 2*lum[i+w] + lum[i+w+1]) - (lum[i-w-1] + 2*lum[i-w] + lum[i-w+1])
+				grad[i] = math.Min(math.Hypot(gx, gy)/8, 255)
+			}
+		}
+	})
+	return &placementMap{
+		min:  img.Bounds().Min,
+		w:    w,
+		h:    h,
+		lum:  newSummedArea(lum, w, h),
+		grad: newSummedArea(grad, w, h),
+	}
+}
+
+// placement returns the placement of the region r relative to the top-left corner of the image.
+func (pm *placementMap) placement(anchor Anchor, r image.Rectangle) Placement {
+	n := float64(r.Dx() * r.Dy() * 255)
+	return Placement{
+		Anchor: anchor,
+		Rect:   r.Add(pm.min),
+		// Rounding errors can make the sums slightly negative.
+		Detail:     math.Max(pm.grad.sum(r)/n, 0),
+		Brightness: math.Max(pm.lum.sum(r)/n, 0),
+		Risk:       pm.removalRisk(r),
+	}
+}
+
+// removalRisk returns the removal risk of a mark in the non-empty region r relative
+// to the top-left corner of the image.
+func (pm *placementMap) removalRisk(r image.Rectangle) RemovalRisk {
+	w, h := float64(pm.w), float64(pm.h)
+
+	// The smallest part of the image lost by cutting one of its sides past the mark.
+	loss := math.Min(
+		math.Min(float64(r.Max.X)/w, (w-float64(r.Min.X))/w),
+		math.Min(float64(r.Max.Y)/h, (h-float64(r.Min.Y))/h),
+	)
+	crop := math.Max(1-4*loss, 0)
+
+	// The detail of the mark and its surroundings, up to half of its size around it.
+	around := r.Inset(-maxint(r.Dx(), r.Dy()) / 2).Intersect(image.Rect(0, 0, pm.w, pm.h))
+	detail := math.Max(pm.grad.sum(around)/float64(around.Dx()*around.Dy()*255), 0)
+	coverage := float64(r.Dx()*r.Dy()) / (w * h)
+	inpaint := math.Max(1-5*detail, 0) * math.Max(1-4*coverage, 0)
+
+	return RemovalRisk{Crop: crop, Inpaint: inpaint, Score: 1 - (1-crop)*(1-inpaint)}
+}
+
+// placements returns the placements of a mark of the given size at the corners and the edges
+// of the inner rectangle, sorted by their detail.
+func (pm *placementMap) placements(width, height int, inner image.Rectangle) []Placement {
+	if width <= 0 || height <= 0 || width > inner.Dx() || height > inner.Dy() {
+		return nil
+	}
+	placements := make([]Placement, 0, len(placementAnchors))
+	for _, anchor := range placementAnchors {
+		r := image.Rectangle{Min: anchorPt(inner, width, height, anchor)}
+		r.Max = r.Min.Add(image.Pt(width, height))
+		placements = append(placements, pm.placement(anchor, r))
+	}
+	sort.SliceStable(placements, func(i, j int) bool { return placements[i].Detail < placements[j].Detail })
+	return placements
+}
+
+// summedArea is a summed-area table: the sum of the values of any rectangle takes constant time.
+type summedArea struct {
+	stride int
+	sums   []float64
+}
+
+// newSummedArea computes the summed-area table of the w x h values in row-major order.
+func newSummedArea(values []float64, w, h int) *summedArea {
+	s := &summedArea{stride: w + 1, sums: make([]float64, (w+1)*(h+1))}
+	for y := 0; y < h; y++ {
+		var row float64
+		for x := 0; x < w; x++ {
+			row += values[y*w+x]
+			i := (y+1)*s.stride + x + 1
+			s.sums[i] = s.sums[i-s.stride] + row
+		}
+	}
+	return s
+}
+
+// sum returns the sum of the values of the rectangle, which must be within the table.
+func (s *summedArea) sum(r image.Rectangle) float64 {
+	return s.sums[r.Max.Y*s.stride+r.Max.X] - s.sums[r.Min.Y*s.stride+r.Max.X] -
+		s.sums[r.Max.Y*s.stride+r.Min.X] + s.sums[r.Min.Y*s.stride+r.Min.X]
+}
@@ -0,0 +1,189 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestFindPlacements(t *testing.T) {
+	// Text-like stripes everywhere except a flat light area in the bottom left corner.
+	img := image.NewNRGBA(image.Rect(10, 20, 110, 80))
+	for y := 20; y < 80; y++ {
+		for x := 10; x < 110; x++ {
+			c := color.NRGBA{0, 0, 0, 255}
+			if (x/2+y/3)%2 == 0 {
+				c = color.NRGBA{255, 255, 255, 255}
+			}
+			if x < 50 && y >= 50 {
+				c = color.NRGBA{200, 200, 200, 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	placements := FindPlacements(img, 30, 20, 5)
+	if len(placements) != 8 {
+		t.Fatalf("got %d placements want 8", len(placements))
+	}
+	best := placements[0]
+	if best.Anchor != BottomLeft {
+		t.Fatalf("got anchor %v want %v", best.Anchor, BottomLeft)
+	}
+	if want := image.Rect(15, 55, 45, 75); best.Rect != want {
+		t.Fatalf("got rect %v want %v", best.Rect, want)
+	}
+	if best.Detail > 1e-9 {
+		t.Fatalf("got detail %v want 0", best.Detail)
+	}
+	if math.Abs(best.Brightness-200.0/255) > 1e-9 {
+		t.Fatalf("got brightness %v want %v", best.Brightness, 200.0/255)
+	}
+	for i, p := range placements[1:] {
+		if p.Detail < placements[i].Detail {
+			t.Fatalf("the placements are not sorted: %v", placements)
+		}
+		if p.Detail < 0.1 {
+			t.Fatalf("got detail %v for the %v anchor", p.Detail, p.Anchor)
+		}
+		if !p.Rect.In(img.Bounds()) || p.Rect.Dx() != 30 || p.Rect.Dy() != 20 {
+			t.Fatalf("invalid rect %v for the %v anchor", p.Rect, p.Anchor)
+		}
+	}
+}
+
+func TestFindPlacementsFlat(t *testing.T) {
+	// The corners win ties.
+	placements := FindPlacements(New(100, 50, color.Black), 20, 10, 0)
+	if len(placements) != 8 || placements[0].Anchor != BottomRight || placements[0].Rect != image.Rect(80, 40, 100, 50) {
+		t.Fatalf("got placements %v", placements)
+	}
+	for _, p := range placements[:4] {
+		switch p.Anchor {
+		case TopLeft, TopRight, BottomLeft, BottomRight:
+		default:
+			t.Fatalf("got anchor %v before the corners", p.Anchor)
+		}
+	}
+}
+
+func TestFindPlacementsNoFit(t *testing.T) {
+	img := New(100, 50, color.Black)
+	for _, size := range []image.Point{{0, 10}, {10, 0}, {91, 10}, {10, 41}} {
+		if p := FindPlacements(img, size.X, size.Y, 5); p != nil {
+			t.Fatalf("got placements %v for a %v mark", p, size)
+		}
+	}
+	if p := FindPlacements(img, 10, 10, -1); p != nil {
+		t.Fatalf("got placements %v for a negative margin", p)
+	}
+	if p := FindPlacements(img, 10, 10, 60); p != nil {
+		t.Fatalf("got placements %v for a margin larger than the image", p)
+	}
+}
+
+func TestSafePosition(t *testing.T) {
+	background := image.NewNRGBA(image.Rect(-10, 5, 90, 65))
+	// The mask excludes the bottom right corner.
+	corner := image.NewGray(image.Rect(0, 0, 100, 60))
+	for y := 40; y < 60; y++ {
+		for x := 70; x < 100; x++ {
+			corner.Pix[y*corner.Stride+x] = 255
+		}
+	}
+	// The mask excludes everything but a hole in the middle.
+	hole := New(100, 60, color.White)
+	hole = Paste(hole, New(20, 20, color.Black), image.Pt(30, 10))
+
+	testCases := []struct {
+		name string
+		area SafeArea
+		want image.Point
+		ok   bool
+	}{
+		{"margins", SafeArea{Top: 1, Right: 7, Bottom: 3, Left: 2}, image.Pt(73, 52), true},
+		{"excluded corner", SafeArea{Exclude: corner}, image.Pt(-10, 55), true},
+		{"hole", SafeArea{Exclude: hole}, image.Pt(20, 15), true},
+		{"hole and margins", SafeArea{Top: 15, Left: 35, Exclude: hole}, image.Pt(25, 20), true},
+		{"small mask", SafeArea{Exclude: New(90, 60, color.White)}, image.Pt(80, 55), true},
+		{"no position", SafeArea{Top: 15, Bottom: 36, Exclude: hole}, image.Point{}, false},
+		{"negative margin", SafeArea{Top: -1}, image.Point{}, false},
+		{"large margins", SafeArea{Left: 50, Right: 45}, image.Point{}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := SafePosition(background, 10, 10, tc.area)
+			if got != tc.want || ok != tc.ok {
+				t.Fatalf("got %v, %v want %v, %v", got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestOverlaySafe(t *testing.T) {
+	background := New(30, 20, color.NRGBA{0, 0, 0, 255})
+	badge := New(4, 3, color.NRGBA{255, 0, 0, 255})
+	got, ok := OverlaySafe(background, badge, SafeArea{Right: 2, Bottom: 1}, 1)
+	if !ok {
+		t.Fatal("no safe position found")
+	}
+	want := Paste(background, badge, image.Pt(24, 16))
+	if !compareNRGBA(got, want, 0) {
+		t.Fatal("the badge isn't in the bottom right corner")
+	}
+
+	got, ok = OverlaySafe(background, badge, SafeArea{Exclude: New(30, 20, color.White)}, 1)
+	if ok || !compareNRGBA(got, background, 0) {
+		t.Fatalf("got %v and a modified image, want false and the background", ok)
+	}
+}
+
+func BenchmarkFindPlacements(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		FindPlacements(testdataBranchesJPG, 100, 40, 10)
+	}
+}
+
+func TestWatermarkRemovalRisk(t *testing.T) {
+	// Text-like stripes along the right border, flat elsewhere.
+	img := image.NewNRGBA(image.Rect(10, 20, 210, 220))
+	for y := 20; y < 220; y++ {
+		for x := 10; x < 210; x++ {
+			c := color.NRGBA{200, 200, 200, 255}
+			if x >= 170 && (x/2+y/3)%2 == 0 {
+				c = color.NRGBA{0, 0, 0, 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	testCases := []struct {
+		name                string
+		rect                image.Rectangle
+		crop, inpaint, risk float64
+	}{
+		{"flat corner", image.Rect(10, 20, 30, 40), 1 - 4*0.1, 1 - 4*0.01, 1 - 4*0.1*4*0.01},
+		{"flat edge inset", image.Rect(30, 110, 50, 130), 1 - 4*0.2, 1 - 4*0.01, 1 - 4*0.2*4*0.01},
+		{"flat center", image.Rect(100, 110, 120, 130), 0, 1 - 4*0.01, 1 - 4*0.01},
+		{"large flat", image.Rect(40, 50, 90, 190), 0, 1 - 4*0.175, 1 - 4*0.175},
+		{"detailed edge", image.Rect(185, 110, 205, 130), 0.5, 0, 0.5},
+		{"outside", image.Rect(300, 300, 320, 320), 1, 1, 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := WatermarkRemovalRisk(img, tc.rect)
+			if math.Abs(got.Crop-tc.crop) > 1e-9 || math.Abs(got.Inpaint-tc.inpaint) > 1e-9 || math.Abs(got.Score-tc.risk) > 1e-9 {
+				t.Fatalf("got %+v want crop %v inpaint %v score %v", got, tc.crop, tc.inpaint, tc.risk)
+			}
+		})
+	}
+
+	// The placements carry the risk of their regions.
+	for _, p := range FindPlacements(img, 20, 20, 0) {
+		if got := WatermarkRemovalRisk(img, p.Rect); got != p.Risk {
+			t.Fatalf("got risk %+v for the %v anchor want %+v", p.Risk, p.Anchor, got)
+		}
+	}
+}
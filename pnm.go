@@ -0,0 +1,429 @@
+package imaging
+
+import (
+	"bufio"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+)
+
+// This file implements the netpbm formats: PBM (bitmaps), PGM (grayscale) and PPM (RGB),
+// both in the binary (raw) and the plain (ASCII) variants.
+
+var errInvalidPNM = errors.New("imaging: invalid PNM data")
+
+// pnmBitmapPalette is the palette of PBM images, a set bit is black.
+var pnmBitmapPalette = color.Palette{color.Gray{0xff}, color.Gray{0}}
+
+// isPNM reports whether the data in r starts with a netpbm magic number (P1 to P6).
+func isPNM(r *bufio.Reader) bool {
+	magic, err := r.Peek(3)
+	if err != nil || magic[0] != 'P' || magic[1] < '1' || magic[1] > '6' {
+		return false
+	}
+	return pnmSpace(magic[2]) || magic[2] == '#'
+}
+
+// pnmFormat returns the format of the image with the given netpbm magic number.
+func pnmFormat(magic byte) Format {
+	return [...]Format{PBM, PGM, PPM}[(magic-'1')%3]
+}
+
+func pnmSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// pnmReader reads the header fields and the plain raster values.
+type pnmReader struct {
+	r *bufio.Reader
+}
+
+// skip skips whitespace and comments.
+func (p pnmReader) skip() error {
+	for {
+		c, err := p.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if c == '#' {
+			if _, err := p.r.ReadString('\n'); err != nil {
+				return err
+			}
+			continue
+		}
+		if !pnmSpace(c) {
+			return p.r.UnreadByte()
+		}
+	}
+}
+
+// int reads a decimal number preceded by whitespace and comments.
+func (p pnmReader) int() (int, error) {
+	if err := p.skip(); err != nil {
+		return 0, errInvalidPNM
+	}
+	n, digits := 0, 0
+	for {
+		c, err := p.r.ReadByte()
+		if err != nil || c < '0' || c > '9' {
+			if err == nil {
+				p.r.UnreadByte()
+			}
+			break
+		}
+		if n > 1<<30 {
+			return 0, errInvalidPNM
+		}
+		n = n*10 + int(c-'0')
+		digits++
+	}
+	if digits == 0 {
+		return 0, errInvalidPNM
+	}
+	return n, nil
+}
+
+// bit reads a plain PBM value, the values don't need to be separated.
+func (p pnmReader) bit() (uint8, error) {
+	if err := p.skip(); err != nil {
+		return 0, errInvalidPNM
+	}
+	c, _ := p.r.ReadByte()
+	if c != '0' && c != '1' {
+		return 0, errInvalidPNM
+	}
+	return c - '0', nil
+}
+
+// pnmHeader is the header of a PNM image.
+type pnmHeader struct {
+	kind          byte // The digit of the magic number.
+	width, height int
+	maxval        int
+}
+
+// colorModel returns the color model of the decoded image.
+func (h pnmHeader) colorModel() color.Model {
+	switch {
+	case h.kind == 1 || h.kind == 4:
+		return pnmBitmapPalette
+	case (h.kind == 2 || h.kind == 5) && h.maxval > 255:
+		return color.Gray16Model
+	case h.kind == 2 || h.kind == 5:
+		return color.GrayModel
+	case h.maxval > 255:
+		return color.RGBA64Model
+	}
+	return color.RGBAModel
+}
+
+// readPNMHeader reads the header of a PNM image from br up to the raster.
+func readPNMHeader(br *bufio.Reader) (pnmHeader, error) {
+	var magic [2]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil || magic[0] != 'P' || magic[1] < '1' || magic[1] > '6' {
+		return pnmHeader{}, errInvalidPNM
+	}
+	h := pnmHeader{kind: magic[1] - '0', maxval: 1}
+	p := pnmReader{br}
+
+	var err error
+	if h.width, err = p.int(); err != nil {
+		return pnmHeader{}, err
+	}
+	if h.height, err = p.int(); err != nil {
+		return pnmHeader{}, err
+	}
+	if h.width == 0 || h.height == 0 || h.width*h.height > 1<<28 {
+		return pnmHeader{}, errInvalidPNM
+	}
+	if h.kind != 1 && h.kind != 4 {
+		if h.maxval, err = p.int(); err != nil {
+			return pnmHeader{}, err
+		}
+		if h.maxval == 0 || h.maxval > 65535 {
+			return pnmHeader{}, errInvalidPNM
+		}
+	}
+	if h.kind > 3 {
+		// A single whitespace character separates the header from the raster.
+		if c, err := br.ReadByte(); err != nil || !pnmSpace(c) {
+			return pnmHeader{}, errInvalidPNM
+		}
+	}
+	return h, nil
+}
+
+// decodePNM reads a PBM, PGM or PPM image from r. Bitmaps are returned as *image.Paletted,
+// grayscale images as *image.Gray or *image.Gray16 and RGB images as *image.RGBA or
+// *image.RGBA64, depending on the maximum sample value.
+func decodePNM(r io.Reader) (image.Image, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	h, err := readPNMHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	kind, width, height, maxval := h.kind, h.width, h.height, h.maxval
+	plain := kind <= 3
+	p := pnmReader{br}
+	rect := image.Rect(0, 0, width, height)
+
+	if kind == 1 || kind == 4 {
+		img := image.NewPaletted(rect, pnmBitmapPalette)
+		row := make([]byte, (width+7)/8)
+		for y := 0; y < height; y++ {
+			dst := img.Pix[y*img.Stride : y*img.Stride+width]
+			if plain {
+				for x := range dst {
+					if dst[x], err = p.bit(); err != nil {
+						return nil, err
+					}
+				}
+				continue
+			}
+			if _, err := io.ReadFull(br, row); err != nil {
+				return nil, errInvalidPNM
+			}
+			for x := range dst {
+				dst[x] = row[x/8] >> uint(7-x%8) & 1
+			}
+		}
+		return img, nil
+	}
+
+	samples := 1
+	if kind == 3 || kind == 6 {
+		samples = 3
+	}
+	wide := maxval > 255
+	bytesPerSample := 1
+	if wide {
+		bytesPerSample = 2
+	}
+	// Read the samples of a row and scale them to the full range of 8 or 16 bits.
+	values := make([]int, width*samples)
+	raw := make([]byte, width*samples*bytesPerSample)
+	readRow := func() error {
+		if plain {
+			for i := range values {
+				v, err := p.int()
+				if err != nil {
+					return err
+				}
+				values[i] = v
+			}
+		} else {
+			if _, err := io.ReadFull(br, raw); err != nil {
+				return errInvalidPNM
+			}
+			for i := range values {
+				if wide {
+					values[i] = int(raw[i*2])<<8 | int(raw[i*2+1])
+				} else {
+					values[i] = int(raw[i])
+				}
+			}
+		}
+		full := 255
+		if wide {
+			full = 65535
+		}
+		for i, v := range values {
+			if v > maxval {
+				return errInvalidPNM
+			}
+			if maxval != full {
+				values[i] = (v*full + maxval/2) / maxval
+			}
+		}
+		return nil
+	}
+
+	var img image.Image
+	var pix []uint8
+	var stride int
+	switch {
+	case samples == 1 && !wide:
+		m := image.NewGray(rect)
+		img, pix, stride = m, m.Pix, m.Stride
+	case samples == 1:
+		m := image.NewGray16(rect)
+		img, pix, stride = m, m.Pix, m.Stride
+	case !wide:
+		m := image.NewRGBA(rect)
+		img, pix, stride = m, m.Pix, m.Stride
+	default:
+		m := image.NewRGBA64(rect)
+		img, pix, stride = m, m.Pix, m.Stride
+	}
+	for y := 0; y < height; y++ {
+		if err := readRow(); err != nil {
+			return nil, err
+		}
+		dst := pix[y*stride:]
+		for x := 0; x < width; x++ {
+			switch {
+			case samples == 1 && !wide:
+				dst[x] = uint8(values[x])
+			case samples == 1:
+				dst[x*2] = uint8(values[x] >> 8)
+				dst[x*2+1] = uint8(values[x])
+			case !wide:
+				d := dst[x*4 : x*4+4 : x*4+4]
+				d[0], d[1], d[2], d[3] = uint8(values[x*3]), uint8(values[x*3+1]), uint8(values[x*3+2]), 0xff
+			default:
+				d := dst[x*8 : x*8+8 : x*8+8]
+				for c := 0; c < 3; c++ {
+					d[c*2] = uint8(values[x*3+c] >> 8)
+					d[c*2+1] = uint8(values[x*3+c])
+				}
+				d[6], d[7] = 0xff, 0xff
+			}
+		}
+	}
+	return img, nil
+}
+
+// pnmWriter writes the raster values of a PNM image.
+type pnmWriter struct {
+	w     *bufio.Writer
+	plain bool
+	line  int // The length of the current line of a plain image.
+}
+
+// value writes a sample value with the given number of bytes (1 or 2) in the binary format.
+func (p *pnmWriter) value(v, bytes int) {
+	if !p.plain {
+		if bytes == 2 {
+			p.w.WriteByte(uint8(v >> 8))
+		}
+		p.w.WriteByte(uint8(v))
+		return
+	}
+	s := strconv.Itoa(v)
+	// Plain images should have lines of at most 70 characters.
+	if p.line > 0 && p.line+1+len(s) > 70 {
+		p.w.WriteByte('\n')
+		p.line = 0
+	} else if p.line > 0 {
+		p.w.WriteByte(' ')
+		p.line++
+	}
+	p.w.WriteString(s)
+	p.line += len(s)
+}
+
+// endRow ends a row of a plain image.
+func (p *pnmWriter) endRow() {
+	if p.plain && p.line > 0 {
+		p.w.WriteByte('\n')
+		p.line = 0
+	}
+}
+
+// encodePNM writes the image to w as PBM, PGM or PPM. Images with an alpha channel are
+// composited over black. PBM images are black where the luminance is below the middle gray.
+// PGM and PPM images are written with 16 bits per sample if the image has 16-bit samples.
+func encodePNM(w io.Writer, img image.Image, format Format, plain bool) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	pw := &pnmWriter{w: bufio.NewWriter(w), plain: plain}
+
+	wide := false
+	switch img.(type) {
+	case *image.Gray16, *image.NRGBA64, *image.RGBA64:
+		wide = format != PBM
+	}
+	kind, maxval := 0, 255
+	switch format {
+	case PBM:
+		kind, maxval = 1, 1
+	case PGM:
+		kind = 2
+	default:
+		kind = 3
+	}
+	if !plain {
+		kind += 3
+	}
+	if wide {
+		maxval = 65535
+	}
+	pw.w.WriteString("P" + strconv.Itoa(kind) + "\n" + strconv.Itoa(width) + " " + strconv.Itoa(height) + "\n")
+	if format != PBM {
+		pw.w.WriteString(strconv.Itoa(maxval) + "\n")
+	}
+
+	var src16 *image.NRGBA64
+	var src *scanner
+	if wide {
+		src16 = toNRGBA64(img)
+	} else {
+		src = newScanner(img)
+	}
+	scanLine := make([]uint8, width*4)
+	row := make([]int, width*4)
+	bits := make([]byte, (width+7)/8)
+	for y := 0; y < height; y++ {
+		// The premultiplied RGBA samples of the row.
+		if wide {
+			line := src16.Pix[y*src16.Stride:]
+			for i := range row {
+				row[i] = int(line[i*2])<<8 | int(line[i*2+1])
+			}
+			for x := 0; x < width; x++ {
+				a := row[x*4+3]
+				for c := 0; c < 3; c++ {
+					row[x*4+c] = int((int64(row[x*4+c])*int64(a) + 0x7fff) / 0xffff)
+				}
+			}
+		} else {
+			src.scan(0, y, width, y+1, scanLine)
+			for x := 0; x < width; x++ {
+				a := int(scanLine[x*4+3])
+				for c := 0; c < 3; c++ {
+					row[x*4+c] = (int(scanLine[x*4+c])*a + 0x7f) / 0xff
+				}
+				row[x*4+3] = a
+			}
+		}
+
+		bytes := 1
+		if wide {
+			bytes = 2
+		}
+		for i := range bits {
+			bits[i] = 0
+		}
+		for x := 0; x < width; x++ {
+			s := row[x*4 : x*4+3 : x*4+3]
+			gray := int(0.299*float64(s[0]) + 0.587*float64(s[1]) + 0.114*float64(s[2]) + 0.5)
+			switch format {
+			case PBM:
+				if gray < 128 {
+					bits[x/8] |= 0x80 >> uint(x%8)
+					if plain {
+						pw.value(1, 1)
+					}
+				} else if plain {
+					pw.value(0, 1)
+				}
+			case PGM:
+				pw.value(gray, bytes)
+			default:
+				pw.value(s[0], bytes)
+				pw.value(s[1], bytes)
+				pw.value(s[2], bytes)
+			}
+		}
+		if format == PBM && !plain {
+			pw.w.Write(bits)
+		}
+		pw.endRow()
+	}
+	return pw.w.Flush()
+}
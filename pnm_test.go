@@ -0,0 +1,229 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestDecodePNM(t *testing.T) {
+	testCases := []struct {
+		name string
+		data string
+		want image.Image
+	}{
+		{
+			"plain PBM",
+			"P1\n# comment\n3 2\n0 1 0\n110\n",
+			&image.Paletted{
+				Rect:    image.Rect(0, 0, 3, 2),
+				Stride:  3,
+				Pix:     []uint8{0, 1, 0, 1, 1, 0},
+				Palette: pnmBitmapPalette,
+			},
+		},
+		{
+			"binary PBM",
+			"P4 10 2\n\x80\x40\x00\xc0",
+			&image.Paletted{
+				Rect:    image.Rect(0, 0, 10, 2),
+				Stride:  10,
+				Pix:     []uint8{1, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1},
+				Palette: pnmBitmapPalette,
+			},
+		},
+		{
+			"plain PGM",
+			"P2 2 2 15\n0 15\n# comment\n5 10",
+			&image.Gray{
+				Rect:   image.Rect(0, 0, 2, 2),
+				Stride: 2,
+				Pix:    []uint8{0, 0xff, 0x55, 0xaa},
+			},
+		},
+		{
+			"binary PGM",
+			"P5\n2 1\n255\n\x10\x20",
+			&image.Gray{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2,
+				Pix:    []uint8{0x10, 0x20},
+			},
+		},
+		{
+			"16-bit PGM",
+			"P5 2 1 65535 \x12\x34\xff\xff",
+			&image.Gray16{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 4,
+				Pix:    []uint8{0x12, 0x34, 0xff, 0xff},
+			},
+		},
+		{
+			"plain PPM",
+			"P3 1 2 255 1 2 3\n4 5 6\n",
+			&image.RGBA{
+				Rect:   image.Rect(0, 0, 1, 2),
+				Stride: 4,
+				Pix:    []uint8{1, 2, 3, 0xff, 4, 5, 6, 0xff},
+			},
+		},
+		{
+			"binary PPM",
+			"P6\n2 1\n255\n\x01\x02\x03\x04\x05\x06",
+			&image.RGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 8,
+				Pix:    []uint8{1, 2, 3, 0xff, 4, 5, 6, 0xff},
+			},
+		},
+		{
+			"12-bit PPM",
+			"P6 1 1 4095\n\x0f\xff\x08\x00\x00\x00",
+			&image.RGBA64{
+				Rect:   image.Rect(0, 0, 1, 1),
+				Stride: 8,
+				Pix:    []uint8{0xff, 0xff, 0x80, 0x08, 0, 0, 0xff, 0xff},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Decode(strings.NewReader(tc.data))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if got.Bounds() != tc.want.Bounds() {
+				t.Fatalf("got bounds %v want %v", got.Bounds(), tc.want.Bounds())
+			}
+			switch want := tc.want.(type) {
+			case *image.Paletted:
+				g, ok := got.(*image.Paletted)
+				if !ok || !bytes.Equal(g.Pix, want.Pix) || len(g.Palette) != 2 {
+					t.Fatalf("got result %#v want %#v", got, want)
+				}
+			case *image.Gray:
+				if g, ok := got.(*image.Gray); !ok || !bytes.Equal(g.Pix, want.Pix) {
+					t.Fatalf("got result %#v want %#v", got, want)
+				}
+			case *image.Gray16:
+				if g, ok := got.(*image.Gray16); !ok || !bytes.Equal(g.Pix, want.Pix) {
+					t.Fatalf("got result %#v want %#v", got, want)
+				}
+			case *image.RGBA:
+				if g, ok := got.(*image.RGBA); !ok || !bytes.Equal(g.Pix, want.Pix) {
+					t.Fatalf("got result %#v want %#v", got, want)
+				}
+			case *image.RGBA64:
+				if g, ok := got.(*image.RGBA64); !ok || !bytes.Equal(g.Pix, want.Pix) {
+					t.Fatalf("got result %#v want %#v", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodePNMFails(t *testing.T) {
+	for _, data := range []string{
+		"P1 2 1 0 2",
+		"P2 1 1 255 256",
+		"P3 1 1 255 1 2",
+		"P4 9 1 \x00",
+		"P5 0 1 255 ",
+		"P5 2 1 65536 \x00\x00",
+		"P5 2 1 255\x00\x00",
+		"P6 1 1 255 \x01\x02",
+		"P5 x",
+		"P7 1 1 255 \x00",
+	} {
+		if _, err := decodePNM(strings.NewReader(data)); err != errInvalidPNM {
+			t.Fatalf("decodePNM(%q): got error %v want %v", data, err, errInvalidPNM)
+		}
+	}
+}
+
+func TestEncodePNM(t *testing.T) {
+	img := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 2),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0xff, 0xff, 0xff, 0xff, 0x10, 0x20, 0x30, 0xff,
+			0xff, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0x80,
+		},
+	}
+	img16 := &image.Gray16{
+		Rect:   image.Rect(0, 0, 2, 1),
+		Stride: 4,
+		Pix:    []uint8{0x12, 0x34, 0xff, 0xff},
+	}
+	testCases := []struct {
+		name   string
+		img    image.Image
+		format Format
+		plain  bool
+		want   string
+	}{
+		{"PBM", img, PBM, false, "P4\n2 2\n\x40\x80"},
+		{"plain PBM", img, PBM, true, "P1\n2 2\n0 1\n1 0\n"},
+		{"PGM", img, PGM, false, "P5\n2 2\n255\n\xff\x1d\x4c\x80"},
+		{"plain PGM", img, PGM, true, "P2\n2 2\n255\n255 29\n76 128\n"},
+		{"16-bit PGM", img16, PGM, false, "P5\n2 1\n65535\n\x12\x34\xff\xff"},
+		{"PPM", img, PPM, false, "P6\n2 2\n255\n\xff\xff\xff\x10\x20\x30\xff\x00\x00\x80\x80\x80"},
+		{"plain PPM", img, PPM, true, "P3\n2 2\n255\n255 255 255 16 32 48\n255 0 0 128 128 128\n"},
+		{"16-bit PPM", img16, PPM, true, "P3\n2 1\n65535\n4660 4660 4660 65535 65535 65535\n"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, tc.img, tc.format, PNMPlain(tc.plain)); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Fatalf("got %q want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncodePNMLineLength(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, New(100, 1, color.White), PPM, PNMPlain(true)); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if len(line) > 70 {
+			t.Fatalf("got a line of %d characters", len(line))
+		}
+	}
+	img, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !compareNRGBA(Clone(img), New(100, 1, color.White), 0) {
+		t.Fatal("the decoded image differs")
+	}
+}
+
+func TestPNMRoundTrip(t *testing.T) {
+	for _, format := range []Format{PGM, PPM} {
+		for _, plain := range []bool{false, true} {
+			img := testdataFlowersSmallPNG
+			if format == PGM {
+				img = Grayscale(img)
+			}
+			var buf bytes.Buffer
+			if err := Encode(&buf, img, format, PNMPlain(plain)); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			got, err := Decode(&buf)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !compareNRGBA(Clone(got), Clone(img), 0) {
+				t.Fatalf("%v (plain=%v): the decoded image differs", format, plain)
+			}
+		}
+	}
+}
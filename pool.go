@@ -0,0 +1,74 @@
+package imaging
+
+import (
+	"image"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+// minPooledPix is the size of the smallest pixel buffer that's pooled.
+// Smaller images are cheap to allocate.
+const minPooledPix = 64 << 10
+
+var (
+	poolingEnabled atomic.Bool
+
+	// pixPools are the pools of the pixel buffers, the buffers of pixPools[i]
+	// have the capacity of at least 1<<i bytes.
+	pixPools [bits.UintSize]sync.Pool
+)
+
+// EnablePooling sets whether the pixel buffers of the images are pooled. If pooling is
+// enabled, the functions of the package take the pixel buffers of their results from a pool
+// and the buffers of the images passed to Release are returned to it. The intermediate
+// images, e.g. of the two passes of Resize, are released as well. This reduces the
+// allocations and the garbage collection of servers processing large images.
+// By default it's disabled.
+//
+// Example:
+//
+//	imaging.EnablePooling(true)
+//	...
+//	thumb := imaging.Thumbnail(img, 200, 200, imaging.Lanczos)
+//	err := imaging.Encode(w, thumb, imaging.JPEG)
+//	imaging.Release(thumb)
+func EnablePooling(enabled bool) {
+	poolingEnabled.Store(enabled)
+}
+
+// Release returns the pixel buffer of the *image.NRGBA image to the pool if pooling is
+// enabled, see EnablePooling. The image becomes empty and neither it nor any other image
+// sharing its pixels, such as its sub-images, may be used afterwards. Other image types
+// are ignored.
+func Release(img image.Image) {
+	if !poolingEnabled.Load() {
+		return
+	}
+	nrgba, ok := img.(*image.NRGBA)
+	if !ok || nrgba == nil || cap(nrgba.Pix) < minPooledPix {
+		return
+	}
+	pix := nrgba.Pix[:cap(nrgba.Pix)]
+	*nrgba = image.NRGBA{}
+	pixPools[bits.Len(uint(len(pix)))-1].Put(&pix)
+}
+
+// newNRGBA returns a new image like image.NewNRGBA, taking the pixel buffer
+// from the pool if pooling is enabled.
+func newNRGBA(r image.Rectangle) *image.NRGBA {
+	n := 4 * r.Dx() * r.Dy()
+	if !poolingEnabled.Load() || n < minPooledPix {
+		return image.NewNRGBA(r)
+	}
+	// The smallest class whose buffers are large enough.
+	class := bits.Len(uint(n - 1))
+	var pix []uint8
+	if p, ok := pixPools[class].Get().(*[]uint8); ok {
+		pix = (*p)[:n]
+		clear(pix)
+	} else {
+		pix = make([]uint8, n, 1<<class)
+	}
+	return &image.NRGBA{Pix: pix, Stride: 4 * r.Dx(), Rect: r}
+}
@@ -0,0 +1,97 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// enablePooling enables pooling for the duration of the test.
+func enablePooling(t *testing.T) {
+	EnablePooling(true)
+	t.Cleanup(func() { EnablePooling(false) })
+}
+
+func TestPooling(t *testing.T) {
+	enablePooling(t)
+	r := image.Rect(0, 0, 300, 200)
+	reused := false
+	var prev *uint8
+	for i := 0; i < 10; i++ {
+		img := newNRGBA(r)
+		if img.Rect != r || img.Stride != 1200 || len(img.Pix) != 240000 {
+			t.Fatalf("got image %v with stride %d and %d bytes", img.Rect, img.Stride, len(img.Pix))
+		}
+		if &img.Pix[0] == prev {
+			reused = true
+		}
+		for j, v := range img.Pix {
+			if v != 0 {
+				t.Fatalf("got byte %d = %d want 0", j, v)
+			}
+		}
+		for j := range img.Pix {
+			img.Pix[j] = 0xff
+		}
+		prev = &img.Pix[0]
+		Release(img)
+		if img.Pix != nil || !img.Rect.Empty() {
+			t.Fatal("the released image isn't empty")
+		}
+	}
+	if !reused {
+		t.Fatal("the buffers aren't reused")
+	}
+}
+
+func TestReleaseIgnored(t *testing.T) {
+	big := New(300, 200, color.White)
+	Release(big)
+	if big.Pix == nil {
+		t.Fatal("the image is released with pooling disabled")
+	}
+
+	enablePooling(t)
+	small := New(10, 10, color.White)
+	Release(small)
+	if small.Pix == nil {
+		t.Fatal("the small image is released")
+	}
+	gray := image.NewGray(image.Rect(0, 0, 300, 200))
+	Release(gray)
+	if gray.Pix == nil {
+		t.Fatal("the gray image is released")
+	}
+	Release(nil)
+}
+
+func TestPoolingResults(t *testing.T) {
+	want := []*image.NRGBA{
+		Resize(testdataBranchesPNG, 300, 150, Lanczos),
+		Fill(testdataBranchesPNG, 200, 200, Center, Linear),
+		Sharpen(testdataBranchesPNG, 1.5),
+	}
+	enablePooling(t)
+	for i := 0; i < 3; i++ {
+		got := []*image.NRGBA{
+			Resize(testdataBranchesPNG, 300, 150, Lanczos),
+			Fill(testdataBranchesPNG, 200, 200, Center, Linear),
+			Sharpen(testdataBranchesPNG, 1.5),
+		}
+		for j := range got {
+			if !compareNRGBA(got[j], want[j], 0) {
+				t.Fatalf("result %d differs with pooling", j)
+			}
+			Release(got[j])
+		}
+	}
+}
+
+func BenchmarkPooling(b *testing.B) {
+	EnablePooling(true)
+	defer EnablePooling(false)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Release(Resize(testdataBranchesPNG, 300, 200, Linear))
+	}
+}
@@ -0,0 +1,373 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"math"
+	"sync"
+)
+
+// RenderingIntent specifies how ConvertProfile maps the colors outside of the gamut of
+// the destination profile, as defined by the ICC.
+type RenderingIntent int
+
+// Rendering intents.
+const (
+	// IntentPerceptual compresses the gamut to keep the relations of the colors, e.g. for photos.
+	IntentPerceptual RenderingIntent = iota
+
+	// IntentRelativeColorimetric keeps the colors inside of the destination gamut, clips
+	// the others and maps the white of the source to the white of the destination.
+	IntentRelativeColorimetric
+
+	// IntentSaturation keeps the saturation of the colors, e.g. for charts.
+	IntentSaturation
+
+	// IntentAbsoluteColorimetric is IntentRelativeColorimetric keeping the white of
+	// the source, e.g. to proof the paper color of a print.
+	IntentAbsoluteColorimetric
+)
+
+// ErrUnsupportedProfile is returned by ConvertProfile for the ICC profiles that aren't
+// RGB or gray matrix/TRC profiles, e.g. the CMYK and the LUT-based profiles, without
+// a color management module supporting them, see RegisterColorManagementModule.
+var ErrUnsupportedProfile = errors.New("imaging: unsupported ICC profile")
+
+var errInvalidICC = errors.New("imaging: invalid ICC profile")
+
+// ColorManagementModule converts the colors of the image from the source ICC profile to
+// the destination one with the rendering intent, e.g. a binding of Little CMS. The empty
+// profiles, e.g. nil, are sRGB. It returns ErrUnsupportedProfile to leave the conversion to
+// the built-in matrix/TRC conversion of ConvertProfile.
+type ColorManagementModule func(img image.Image, srcICC, dstICC []byte, intent RenderingIntent) (*image.NRGBA, error)
+
+var (
+	cmmMu sync.RWMutex
+	cmm   ColorManagementModule
+)
+
+// RegisterColorManagementModule sets the color management module of ConvertProfile,
+// which supports the ICC profiles beyond the matrix/TRC ones and the perceptual and
+// saturation intents of the LUT-based profiles. The nil module restores the built-in
+// conversion. It is typically called in an init function.
+//
+// Example:
+//
+//	imaging.RegisterColorManagementModule(func(img image.Image, srcICC, dstICC []byte, intent imaging.RenderingIntent) (*image.NRGBA, error) {
+//		return lcms.Transform(img, srcICC, dstICC, int(intent))
+//	})
+func RegisterColorManagementModule(module ColorManagementModule) {
+	cmmMu.Lock()
+	defer cmmMu.Unlock()
+	cmm = module
+}
+
+// ConvertProfile converts the colors of the image from the source ICC profile to
+// the destination one, e.g. to normalize the photos of the wide gamut cameras to sRGB
+// before the processing, and returns the converted image. The empty profiles, e.g. nil,
+// are sRGB, like the images without embedded profiles. The alpha channel is kept.
+//
+// Without a color management module, see RegisterColorManagementModule, the RGB and gray
+// matrix/TRC profiles are supported, e.g. Adobe RGB, Display P3 and ProPhoto RGB, and
+// other profiles return ErrUnsupportedProfile. The matrix/TRC profiles have no gamut
+// mapping, so the perceptual and saturation intents convert the colors like
+// the relative colorimetric intent, clipping the colors outside of the destination gamut.
+//
+// Example:
+//
+//	img, _ := imaging.Open("photo.jpg")
+//	icc, _ := os.ReadFile("AdobeRGB1998.icc")
+//	srgb, err := imaging.ConvertProfile(img, icc, nil, imaging.IntentRelativeColorimetric)
+func ConvertProfile(img image.Image, srcICC, dstICC []byte, intent RenderingIntent) (*image.NRGBA, error) {
+	cmmMu.RLock()
+	module := cmm
+	cmmMu.RUnlock()
+	if module != nil {
+		dst, err := module(img, srcICC, dstICC, intent)
+		if err != ErrUnsupportedProfile {
+			return dst, err
+		}
+	}
+
+	if bytes.Equal(srcICC, dstICC) {
+		return Clone(img), nil
+	}
+	src, err := parseICCProfile(srcICC)
+	if err != nil {
+		return nil, err
+	}
+	dstProfile, err := parseICCProfile(dstICC)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := invert3x3(dstProfile.matrix); !ok {
+		return nil, errInvalidICC
+	}
+	return src.convert(img, dstProfile, intent), nil
+}
+
+// iccProfile is a matrix/TRC profile: the curves convert the channel values from 0 to 1
+// to linear light and the matrix converts the linear light to the PCS XYZ.
+type iccProfile struct {
+	gray   bool
+	matrix [3][3]float64
+	curves [3]func(float64) float64
+	white  [3]float64
+}
+
+// d50 is the white of the profile connection space.
+var d50 = [3]float64{0.9642, 1, 0.8249}
+
+// srgbProfile is the sRGB profile with the primaries adapted to D50.
+var srgbProfile = &iccProfile{
+	matrix: [3][3]float64{
+		{0.4360747, 0.3850649, 0.1430804},
+		{0.2225045, 0.7168786, 0.0606169},
+		{0.0139322, 0.0971045, 0.7141733},
+	},
+	curves: [3]func(float64) float64{linearizeSRGB, linearizeSRGB, linearizeSRGB},
+	white:  d50,
+}
+
+// parseICCProfile parses the RGB or gray matrix/TRC ICC profile, the empty profile is sRGB.
+func parseICCProfile(data []byte) (*iccProfile, error) {
+	if len(data) == 0 {
+		return srgbProfile, nil
+	}
+	be := binary.BigEndian
+	if len(data) < 132 || string(data[36:40]) != "acsp" {
+		return nil, errInvalidICC
+	}
+	if string(data[20:24]) != "XYZ " {
+		return nil, ErrUnsupportedProfile
+	}
+	tags := make(map[string][]byte)
+	n := int(be.Uint32(data[128:]))
+	if n > (len(data)-132)/12 {
+		return nil, errInvalidICC
+	}
+	for i := 0; i < n; i++ {
+		t := data[132+i*12:]
+		offset, size := int64(be.Uint32(t[4:])), int64(be.Uint32(t[8:]))
+		if offset+size > int64(len(data)) {
+			return nil, errInvalidICC
+		}
+		tags[string(t[:4])] = data[offset : offset+size]
+	}
+
+	p := &iccProfile{white: d50}
+	if w, ok := tags["wtpt"]; ok {
+		xyz, err := parseICCXYZ(w)
+		if err != nil {
+			return nil, err
+		}
+		p.white = xyz
+	}
+	switch string(data[16:20]) {
+	case "GRAY":
+		t, ok := tags["kTRC"]
+		if !ok {
+			return nil, ErrUnsupportedProfile
+		}
+		curve, err := parseICCCurve(t)
+		if err != nil {
+			return nil, err
+		}
+		// The gray is the luminance of the PCS white.
+		p.gray = true
+		p.matrix = [3][3]float64{{d50[0], 0, 0}, {0, 1, 0}, {0, 0, d50[2]}}
+		p.curves = [3]func(float64) float64{curve, curve, curve}
+	case "RGB ":
+		for c, name := range [3]string{"r", "g", "b"} {
+			x, okX := tags[name+"XYZ"]
+			t, okT := tags[name+"TRC"]
+			if !okX || !okT {
+				return nil, ErrUnsupportedProfile
+			}
+			xyz, err := parseICCXYZ(x)
+			if err != nil {
+				return nil, err
+			}
+			for r := range xyz {
+				p.matrix[r][c] = xyz[r]
+			}
+			if p.curves[c], err = parseICCCurve(t); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, ErrUnsupportedProfile
+	}
+	return p, nil
+}
+
+// parseICCXYZ parses the first XYZ number of the XYZType tag.
+func parseICCXYZ(t []byte) ([3]float64, error) {
+	var xyz [3]float64
+	if len(t) < 20 || string(t[:4]) != "XYZ " {
+		return xyz, errInvalidICC
+	}
+	for i := range xyz {
+		xyz[i] = s15Fixed16(t[8+i*4:])
+	}
+	return xyz, nil
+}
+
+// parseICCCurve parses the curveType or the parametricCurveType tag.
+func parseICCCurve(t []byte) (func(float64) float64, error) {
+	be := binary.BigEndian
+	if len(t) < 12 {
+		return nil, errInvalidICC
+	}
+	switch string(t[:4]) {
+	case "curv":
+		n := int(be.Uint32(t[8:]))
+		if len(t) < 12+n*2 {
+			return nil, errInvalidICC
+		}
+		switch n {
+		case 0:
+			return func(x float64) float64 { return x }, nil
+		case 1:
+			g := float64(be.Uint16(t[12:])) / 256
+			return func(x float64) float64 { return math.Pow(x, g) }, nil
+		}
+		table := make([]float64, n)
+		for i := range table {
+			table[i] = float64(be.Uint16(t[12+i*2:])) / 65535
+		}
+		return func(x float64) float64 {
+			f := math.Min(math.Max(x, 0), 1) * float64(n-1)
+			i := minint(int(f), n-2)
+			return table[i] + (table[i+1]-table[i])*(f-float64(i))
+		}, nil
+	case "para":
+		// The numbers of the parameters of the function types.
+		counts := [...]int{1, 3, 4, 5, 7}
+		typ := int(be.Uint16(t[8:]))
+		if typ >= len(counts) || len(t) < 12+counts[typ]*4 {
+			return nil, ErrUnsupportedProfile
+		}
+		var p [7]float64
+		for i := 0; i < counts[typ]; i++ {
+			p[i] = s15Fixed16(t[12+i*4:])
+		}
+		g, a, b, c, d, e, f := p[0], p[1], p[2], p[3], p[4], p[5], p[6]
+		pow := func(x float64) float64 { return math.Pow(math.Max(x, 0), g) }
+		switch typ {
+		case 0:
+			return pow, nil
+		case 1:
+			return func(x float64) float64 {
+				if x >= -b/a {
+					return pow(a*x + b)
+				}
+				return 0
+			}, nil
+		case 2:
+			return func(x float64) float64 {
+				if x >= -b/a {
+					return pow(a*x+b) + c
+				}
+				return c
+			}, nil
+		case 3:
+			return func(x float64) float64 {
+				if x >= d {
+					return pow(a*x + b)
+				}
+				return c * x
+			}, nil
+		}
+		return func(x float64) float64 {
+			if x >= d {
+				return pow(a*x+b) + e
+			}
+			return c*x + f
+		}, nil
+	}
+	return nil, ErrUnsupportedProfile
+}
+
+func s15Fixed16(b []byte) float64 {
+	return float64(int32(binary.BigEndian.Uint32(b))) / 65536
+}
+
+// convert converts the colors of the image from the profile to the destination profile.
+func (p *iccProfile) convert(img image.Image, dst *iccProfile, intent RenderingIntent) *image.NRGBA {
+	// The matrix from the linear light of the source to the PCS, adapted to the white of
+	// the source for the absolute intent, and to the linear light of the destination.
+	m := p.matrix
+	if intent == IntentAbsoluteColorimetric {
+		for r := range m {
+			for c := range m[r] {
+				m[r][c] *= p.white[r] / dst.white[r]
+			}
+		}
+	}
+	inv, _ := invert3x3(dst.matrix)
+	m = mulMatrix3(inv, m)
+
+	// The curves of the source as lookup tables of the channel values, and the inverse
+	// curves of the destination as lookup tables of the linear light.
+	const levels = 4096
+	var in [3][256]float64
+	var out [3][levels + 1]uint8
+	for c := 0; c < 3; c++ {
+		for i := range in[c] {
+			in[c][i] = p.curves[c](float64(i) / 255)
+		}
+		fn := dst.curves[c]
+		for i := range out[c] {
+			y := float64(i) / levels
+			// The curves are monotonic, so they are inverted by the bisection.
+			lo, hi := 0.0, 1.0
+			for k := 0; k < 20; k++ {
+				if mid := (lo + hi) / 2; fn(mid) < y {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			out[c][i] = clamp((lo + hi) / 2 * 255)
+		}
+	}
+
+	dstImg := Clone(img)
+	w, h := dstImg.Rect.Dx(), dstImg.Rect.Dy()
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				d := dstImg.Pix[y*dstImg.Stride+x*4 : y*dstImg.Stride+x*4+4 : y*dstImg.Stride+x*4+4]
+				v := [3]float64{in[0][d[0]], in[1][d[1]], in[2][d[2]]}
+				if p.gray {
+					v[1], v[2] = v[0], v[0]
+				}
+				var lin [3]float64
+				for r := range lin {
+					lin[r] = m[r][0]*v[0] + m[r][1]*v[1] + m[r][2]*v[2]
+				}
+				if dst.gray {
+					// The gray is the luminance.
+					lin[0], lin[2] = lin[1], lin[1]
+				}
+				for c := range lin {
+					d[c] = out[c][int(math.Min(math.Max(lin[c], 0), 1)*levels+0.5)]
+				}
+			}
+		}
+	})
+	return dstImg
+}
+
+func mulMatrix3(a, b [3][3]float64) [3][3]float64 {
+	var m [3][3]float64
+	for r := range m {
+		for c := range m[r] {
+			m[r][c] = a[r][0]*b[0][c] + a[r][1]*b[1][c] + a[r][2]*b[2][c]
+		}
+	}
+	return m
+}
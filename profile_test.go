@@ -0,0 +1,228 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"sort"
+	"testing"
+)
+
+// testICCProfile returns an ICC profile of the color space with the tags.
+func testICCProfile(colorSpace string, tags map[string][]byte) []byte {
+	be := binary.BigEndian
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	data := make([]byte, 132+len(names)*12)
+	copy(data[12:], "mntr")
+	copy(data[16:], colorSpace)
+	copy(data[20:], "XYZ ")
+	copy(data[36:], "acsp")
+	be.PutUint32(data[128:], uint32(len(names)))
+	for i, name := range names {
+		t := data[132+i*12:]
+		copy(t, name)
+		be.PutUint32(t[4:], uint32(len(data)))
+		be.PutUint32(t[8:], uint32(len(tags[name])))
+		data = append(data, tags[name]...)
+	}
+	binary.BigEndian.PutUint32(data, uint32(len(data)))
+	return data
+}
+
+func testICCXYZ(x, y, z float64) []byte {
+	t := append([]byte("XYZ "), make([]byte, 16)...)
+	for i, v := range [3]float64{x, y, z} {
+		binary.BigEndian.PutUint32(t[8+i*4:], uint32(int32(v*65536+0.5)))
+	}
+	return t
+}
+
+func testICCGamma(g float64) []byte {
+	t := append([]byte("curv"), 0, 0, 0, 0, 0, 0, 0, 1, 0, 0)
+	binary.BigEndian.PutUint16(t[12:], uint16(g*256+0.5))
+	return t
+}
+
+// testICCSRGBCurve returns the sRGB curve as the parametric curve of the type 3.
+func testICCSRGBCurve() []byte {
+	t := append([]byte("para"), 0, 0, 0, 0, 0, 3, 0, 0)
+	for _, v := range []float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045} {
+		t = binary.BigEndian.AppendUint32(t, uint32(int32(v*65536+0.5)))
+	}
+	return t
+}
+
+func testICCRGB(trc []byte, r, g, b [3]float64, white []byte) []byte {
+	tags := map[string][]byte{
+		"rXYZ": testICCXYZ(r[0], r[1], r[2]),
+		"gXYZ": testICCXYZ(g[0], g[1], g[2]),
+		"bXYZ": testICCXYZ(b[0], b[1], b[2]),
+		"rTRC": trc,
+		"gTRC": trc,
+		"bTRC": trc,
+	}
+	if white != nil {
+		tags["wtpt"] = white
+	}
+	return testICCProfile("RGB ", tags)
+}
+
+var (
+	testSRGBPrimaries  = [3][3]float64{{0.4360747, 0.2225045, 0.0139322}, {0.3850649, 0.7168786, 0.0971045}, {0.1430804, 0.0606169, 0.7141733}}
+	testAdobePrimaries = [3][3]float64{{0.6097559, 0.3111145, 0.0194702}, {0.2052401, 0.6256561, 0.0608902}, {0.1492240, 0.0632294, 0.7448387}}
+)
+
+func TestConvertProfile(t *testing.T) {
+	p := testSRGBPrimaries
+	srgb := testICCRGB(testICCSRGBCurve(), p[0], p[1], p[2], nil)
+	linear := testICCRGB(append([]byte("curv"), make([]byte, 8)...), p[0], p[1], p[2], nil)
+	a := testAdobePrimaries
+	adobe := testICCRGB(testICCGamma(563.0/256), a[0], a[1], a[2], nil)
+	gray := testICCProfile("GRAY", map[string][]byte{"kTRC": testICCGamma(1)})
+
+	testCases := []struct {
+		name           string
+		src            image.Image
+		srcICC, dstICC []byte
+		want           image.Image
+		delta          int
+	}{
+		{"sRGB to sRGB", testdataFlowersSmallPNG, nil, nil, testdataFlowersSmallPNG, 0},
+		{"sRGB profile to sRGB", testdataFlowersSmallPNG, srgb, nil, testdataFlowersSmallPNG, 1},
+		{"sRGB to linear", New(1, 1, color.NRGBA{128, 128, 128, 0x80}), nil, linear, New(1, 1, color.NRGBA{55, 55, 55, 0x80}), 1},
+		{"linear to sRGB", New(1, 1, color.NRGBA{55, 0, 255, 255}), linear, nil, New(1, 1, color.NRGBA{128, 0, 255, 255}), 1},
+		{"sRGB to gray", New(1, 1, color.NRGBA{255, 0, 0, 255}), nil, gray, New(1, 1, color.NRGBA{57, 57, 57, 255}), 1},
+		{"gray to sRGB", New(1, 1, color.NRGBA{55, 55, 55, 255}), gray, nil, New(1, 1, color.NRGBA{128, 128, 128, 255}), 1},
+		{"Adobe RGB red to sRGB", New(1, 1, color.NRGBA{255, 0, 0, 255}), adobe, nil, New(1, 1, color.NRGBA{255, 0, 0, 255}), 0},
+		{"Adobe RGB gray to sRGB", New(1, 1, color.NRGBA{128, 128, 128, 255}), adobe, nil, New(1, 1, color.NRGBA{128, 128, 128, 255}), 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ConvertProfile(tc.src, tc.srcICC, tc.dstICC, IntentPerceptual)
+			if err != nil {
+				t.Fatalf("got error %v", err)
+			}
+			if !compareNRGBA(got, Clone(tc.want), tc.delta) {
+				t.Fatalf("got %v want %v", got.Pix[:4], Clone(tc.want).Pix[:4])
+			}
+		})
+	}
+
+	// The colors inside of the gamut of Adobe RGB survive the round trip.
+	tmp, err := ConvertProfile(testdataFlowersSmallPNG, nil, adobe, IntentRelativeColorimetric)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	got, err := ConvertProfile(tmp, adobe, nil, IntentRelativeColorimetric)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if !compareNRGBA(got, Clone(testdataFlowersSmallPNG), 2) {
+		t.Fatal("the round trip through Adobe RGB changed the colors")
+	}
+	// The colors are less saturated in the wider gamut of Adobe RGB.
+	if compareNRGBA(tmp, Clone(testdataFlowersSmallPNG), 2) {
+		t.Fatal("the conversion to Adobe RGB didn't change the colors")
+	}
+}
+
+func TestConvertProfileAbsolute(t *testing.T) {
+	p := testSRGBPrimaries
+	// A print on the yellowish paper.
+	paper := testICCRGB(testICCSRGBCurve(), p[0], p[1], p[2], testICCXYZ(0.93, 0.95, 0.70))
+	white := New(1, 1, color.White)
+
+	got, err := ConvertProfile(white, paper, nil, IntentRelativeColorimetric)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if c := got.NRGBAAt(0, 0); c != (color.NRGBA{255, 255, 255, 255}) {
+		t.Fatalf("got relative white %v want white", c)
+	}
+	got, err = ConvertProfile(white, paper, nil, IntentAbsoluteColorimetric)
+	if err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if c := got.NRGBAAt(0, 0); c.B >= c.R || c.B > 240 {
+		t.Fatalf("got absolute white %v want the color of the paper", c)
+	}
+}
+
+func TestConvertProfileErrors(t *testing.T) {
+	p := testSRGBPrimaries
+	lut := testICCProfile("RGB ", map[string][]byte{"A2B0": []byte("mft2")})
+	cmyk := testICCProfile("CMYK", map[string][]byte{"A2B0": []byte("mft2")})
+	badCurve := testICCRGB([]byte("curv\x00\x00\x00\x00\x00\x00\x00\x05\x00\x00"), p[0], p[1], p[2], nil)
+	truncated := testICCRGB(testICCSRGBCurve(), p[0], p[1], p[2], nil)
+	truncated = truncated[:len(truncated)-10]
+
+	testCases := []struct {
+		name string
+		icc  []byte
+		want error
+	}{
+		{"short", []byte("acsp"), errInvalidICC},
+		{"not ICC", make([]byte, 200), errInvalidICC},
+		{"LUT-based", lut, ErrUnsupportedProfile},
+		{"CMYK", cmyk, ErrUnsupportedProfile},
+		{"bad curve", badCurve, errInvalidICC},
+		{"truncated", truncated, errInvalidICC},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ConvertProfile(testdataFlowersSmallPNG, tc.icc, nil, IntentPerceptual); err != tc.want {
+				t.Fatalf("got error %v want %v", err, tc.want)
+			}
+			if _, err := ConvertProfile(testdataFlowersSmallPNG, nil, tc.icc, IntentPerceptual); err != tc.want {
+				t.Fatalf("got error %v want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestRegisterColorManagementModule(t *testing.T) {
+	defer RegisterColorManagementModule(nil)
+	lut := testICCProfile("RGB ", map[string][]byte{"A2B0": []byte("mft2")})
+	errModule := errors.New("module error")
+	RegisterColorManagementModule(func(img image.Image, srcICC, dstICC []byte, intent RenderingIntent) (*image.NRGBA, error) {
+		switch {
+		case intent == IntentSaturation:
+			return nil, errModule
+		case srcICC != nil:
+			return New(1, 1, color.Black), nil
+		}
+		return nil, ErrUnsupportedProfile
+	})
+
+	got, err := ConvertProfile(testdataFlowersSmallPNG, lut, nil, IntentPerceptual)
+	if err != nil || got.Rect.Dx() != 1 {
+		t.Fatalf("got %v, %v want the image of the module", got.Rect, err)
+	}
+	if _, err := ConvertProfile(testdataFlowersSmallPNG, lut, nil, IntentSaturation); err != errModule {
+		t.Fatalf("got error %v want the error of the module", err)
+	}
+	// The module leaves the conversion to the built-in one.
+	got, err = ConvertProfile(testdataFlowersSmallPNG, nil, nil, IntentPerceptual)
+	if err != nil || !compareNRGBA(got, Clone(testdataFlowersSmallPNG), 0) {
+		t.Fatalf("got error %v want the built-in conversion", err)
+	}
+
+	RegisterColorManagementModule(nil)
+	if _, err := ConvertProfile(testdataFlowersSmallPNG, lut, nil, IntentPerceptual); err != ErrUnsupportedProfile {
+		t.Fatalf("got error %v want ErrUnsupportedProfile", err)
+	}
+}
+
+func BenchmarkConvertProfile(b *testing.B) {
+	a := testAdobePrimaries
+	adobe := testICCRGB(testICCGamma(563.0/256), a[0], a[1], a[2], nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ConvertProfile(testdataBranchesJPG, adobe, nil, IntentRelativeColorimetric)
+	}
+}
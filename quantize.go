@@ -0,0 +1,355 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"sort"
+)
+
+// QuantizeMethod specifies the color quantization algorithm used to build an optimized palette.
+// It implements the draw.Quantizer interface, so it can be passed to the GIFQuantizer encode option.
+type QuantizeMethod int
+
+// Color quantization methods.
+const (
+	// QuantizeMedianCut recursively splits the color space at the median of the widest color range.
+	// It gives good results for photographic images.
+	QuantizeMedianCut QuantizeMethod = iota
+
+	// QuantizeOctree builds an octree of the image colors and merges the least significant branches.
+	// It is faster than median cut and preserves small areas of distinct color well.
+	QuantizeOctree
+)
+
+// Quantize returns an optimized palette of at most numColors colors (up to 256)
+// that represents the colors of the image. If the image has fully transparent pixels,
+// one palette entry is reserved for the transparent color.
+//
+// Example:
+//
+//	p := imaging.Quantize(srcImage, 64, imaging.QuantizeMedianCut)
+//	dstImage := imaging.Dither(srcImage, p, imaging.DitherFloydSteinberg)
+func Quantize(img image.Image, numColors int, method QuantizeMethod) color.Palette {
+	if numColors > 256 {
+		numColors = 256
+	}
+	if numColors <= 0 {
+		return nil
+	}
+	return method.Quantize(make(color.Palette, 0, numColors), img)
+}
+
+// Quantize implements the draw.Quantizer interface. It appends up to cap(p) - len(p)
+// colors representing the image colors to the palette p and returns the updated palette.
+func (m QuantizeMethod) Quantize(p color.Palette, img image.Image) color.Palette {
+	if cap(p) <= len(p) {
+		return p
+	}
+	hist, transparent := colorHistogram(img)
+	return m.quantizeHistogram(p, hist, transparent)
+}
+
+// quantizeHistogram appends up to cap(p) - len(p) colors representing the histogram colors
+// to the palette p. If transparent is true, the first appended color is the transparent one.
+func (m QuantizeMethod) quantizeHistogram(p color.Palette, hist []colorCount, transparent bool) color.Palette {
+	n := cap(p) - len(p)
+	if n <= 0 {
+		return p
+	}
+	if transparent {
+		p = append(p, color.NRGBA{})
+		n--
+	}
+	if n <= 0 || len(hist) == 0 {
+		return p
+	}
+
+	if len(hist) <= n {
+		for _, e := range hist {
+			p = append(p, e.color())
+		}
+		return p
+	}
+
+	switch m {
+	case QuantizeOctree:
+		return append(p, quantizeOctree(hist, n)...)
+	default:
+		return append(p, quantizeMedianCut(hist, n)...)
+	}
+}
+
+// colorCount is a histogram entry: an opaque (or translucent) color and the number of pixels having it.
+type colorCount struct {
+	rgb   [3]uint8
+	count float64
+	alpha float64 // Sum of the alpha values of the pixels.
+}
+
+func (e colorCount) color() color.NRGBA {
+	return color.NRGBA{e.rgb[0], e.rgb[1], e.rgb[2], clamp(e.alpha / e.count)}
+}
+
+// colorHistogram returns the RGB colors of the non-transparent pixels of the image
+// with their pixel counts, and reports whether there are fully transparent pixels.
+func colorHistogram(img image.Image) ([]colorCount, bool) {
+	src := newScanner(img)
+	counts := make(map[uint32]int)
+	alphas := make(map[uint32]int)
+	transparent := false
+
+	scanLine := make([]uint8, src.w*4)
+	for y := 0; y < src.h; y++ {
+		src.scan(0, y, src.w, y+1, scanLine)
+		for x := 0; x < src.w; x++ {
+			s := scanLine[x*4 : x*4+4 : x*4+4]
+			if s[3] == 0 {
+				transparent = true
+				continue
+			}
+			key := uint32(s[0])<<16 | uint32(s[1])<<8 | uint32(s[2])
+			counts[key]++
+			alphas[key] += int(s[3])
+		}
+	}
+
+	hist := make([]colorCount, 0, len(counts))
+	for key, n := range counts {
+		hist = append(hist, colorCount{
+			rgb:   [3]uint8{uint8(key >> 16), uint8(key >> 8), uint8(key)},
+			count: float64(n),
+			alpha: float64(alphas[key]),
+		})
+	}
+	// Make the result independent of the map iteration order.
+	sort.Slice(hist, func(i, j int) bool {
+		a, b := hist[i].rgb, hist[j].rgb
+		return uint32(a[0])<<16|uint32(a[1])<<8|uint32(a[2]) < uint32(b[0])<<16|uint32(b[1])<<8|uint32(b[2])
+	})
+	return hist, transparent
+}
+
+// mergeHistograms returns the sum of the color histograms sorted by color.
+func mergeHistograms(a, b []colorCount) []colorCount {
+	key := func(e colorCount) uint32 {
+		return uint32(e.rgb[0])<<16 | uint32(e.rgb[1])<<8 | uint32(e.rgb[2])
+	}
+	merged := make([]colorCount, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		switch ka, kb := key(a[0]), key(b[0]); {
+		case ka < kb:
+			merged = append(merged, a[0])
+			a = a[1:]
+		case ka > kb:
+			merged = append(merged, b[0])
+			b = b[1:]
+		default:
+			e := a[0]
+			e.count += b[0].count
+			e.alpha += b[0].alpha
+			merged = append(merged, e)
+			a, b = a[1:], b[1:]
+		}
+	}
+	merged = append(merged, a...)
+	return append(merged, b...)
+}
+
+// averageColor returns the pixel-count weighted average of the histogram entries.
+func averageColor(entries []colorCount) color.NRGBA {
+	var r, g, b, a, n float64
+	for _, e := range entries {
+		r += float64(e.rgb[0]) * e.count
+		g += float64(e.rgb[1]) * e.count
+		b += float64(e.rgb[2]) * e.count
+		a += e.alpha
+		n += e.count
+	}
+	return color.NRGBA{clamp(r / n), clamp(g / n), clamp(b / n), clamp(a / n)}
+}
+
+type colorBox struct {
+	entries []colorCount
+	axis    int     // Channel with the widest range.
+	score   float64 // Priority of splitting this box.
+}
+
+func newColorBox(entries []colorCount) colorBox {
+	min := [3]uint8{255, 255, 255}
+	max := [3]uint8{}
+	var n float64
+	for _, e := range entries {
+		for c := 0; c < 3; c++ {
+			if e.rgb[c] < min[c] {
+				min[c] = e.rgb[c]
+			}
+			if e.rgb[c] > max[c] {
+				max[c] = e.rgb[c]
+			}
+		}
+		n += e.count
+	}
+	box := colorBox{entries: entries}
+	width := 0
+	for c := 0; c < 3; c++ {
+		if d := int(max[c]) - int(min[c]); d > width {
+			width = d
+			box.axis = c
+		}
+	}
+	if len(entries) > 1 {
+		box.score = float64(width) * n
+	}
+	return box
+}
+
+func quantizeMedianCut(hist []colorCount, n int) []color.Color {
+	boxes := medianCut(hist, n)
+	pal := make([]color.Color, len(boxes))
+	for i, b := range boxes {
+		pal[i] = averageColor(b.entries)
+	}
+	return pal
+}
+
+// medianCut splits the histogram into at most n boxes of similar colors.
+func medianCut(hist []colorCount, n int) []colorBox {
+	boxes := []colorBox{newColorBox(hist)}
+	for len(boxes) < n {
+		best := -1
+		for i, b := range boxes {
+			if b.score > 0 && (best < 0 || b.score > boxes[best].score) {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		axis := box.axis
+		sort.Slice(box.entries, func(i, j int) bool {
+			return box.entries[i].rgb[axis] < box.entries[j].rgb[axis]
+		})
+		var total float64
+		for _, e := range box.entries {
+			total += e.count
+		}
+		var sum float64
+		split := 1
+		for i, e := range box.entries[:len(box.entries)-1] {
+			sum += e.count
+			split = i + 1
+			if sum >= total/2 {
+				break
+			}
+		}
+
+		boxes[best] = newColorBox(box.entries[:split])
+		boxes = append(boxes, newColorBox(box.entries[split:]))
+	}
+	return boxes
+}
+
+type octreeNode struct {
+	children [8]*octreeNode
+	leaf     bool
+	count    float64
+	sum      [4]float64
+}
+
+func quantizeOctree(hist []colorCount, n int) []color.Color {
+	const maxDepth = 8
+
+	// levels holds the inner nodes of the tree by depth.
+	root := &octreeNode{}
+	var levels [maxDepth][]*octreeNode
+	levels[0] = []*octreeNode{root}
+	leaves := 0
+
+	for _, e := range hist {
+		node := root
+		for depth := 0; depth < maxDepth; depth++ {
+			if node.leaf {
+				break
+			}
+			shift := 7 - depth
+			idx := (e.rgb[0]>>shift&1)<<2 | (e.rgb[1]>>shift&1)<<1 | (e.rgb[2] >> shift & 1)
+			child := node.children[idx]
+			if child == nil {
+				child = &octreeNode{leaf: depth == maxDepth-1}
+				node.children[idx] = child
+				if child.leaf {
+					leaves++
+				} else {
+					levels[depth+1] = append(levels[depth+1], child)
+				}
+			}
+			node = child
+		}
+		node.count += e.count
+		node.sum[0] += float64(e.rgb[0]) * e.count
+		node.sum[1] += float64(e.rgb[1]) * e.count
+		node.sum[2] += float64(e.rgb[2]) * e.count
+		node.sum[3] += e.alpha
+	}
+
+	// Merge the children of the deepest inner nodes until the number of leaves fits the palette.
+	for depth := maxDepth - 1; depth >= 0 && leaves > n; depth-- {
+		// Merge the least populated nodes first. All the deeper nodes are leaves at this point.
+		nodes := levels[depth]
+		totals := make(map[*octreeNode]float64, len(nodes))
+		for _, node := range nodes {
+			t := node.count
+			for _, child := range node.children {
+				if child != nil {
+					t += child.count
+				}
+			}
+			totals[node] = t
+		}
+		sort.SliceStable(nodes, func(i, j int) bool { return totals[nodes[i]] < totals[nodes[j]] })
+		for _, node := range nodes {
+			if leaves <= n {
+				break
+			}
+			merged := 0
+			for i, child := range node.children {
+				if child == nil {
+					continue
+				}
+				node.count += child.count
+				for c := 0; c < 4; c++ {
+					node.sum[c] += child.sum[c]
+				}
+				node.children[i] = nil
+				merged++
+			}
+			node.leaf = true
+			leaves -= merged - 1
+		}
+	}
+
+	var pal []color.Color
+	var collect func(node *octreeNode)
+	collect = func(node *octreeNode) {
+		if node.leaf {
+			if node.count > 0 {
+				pal = append(pal, color.NRGBA{
+					clamp(node.sum[0] / node.count),
+					clamp(node.sum[1] / node.count),
+					clamp(node.sum[2] / node.count),
+					clamp(node.sum[3] / node.count),
+				})
+			}
+			return
+		}
+		for _, child := range node.children {
+			if child != nil {
+				collect(child)
+			}
+		}
+	}
+	collect(root)
+	return pal
+}
@@ -0,0 +1,105 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestQuantize(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 4, 1),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0xff, 0x00, 0x00, 0xff, 0x00, 0xff, 0x00, 0xff, 0x00, 0xff, 0x00, 0xff, 0x00, 0x00, 0x00, 0x00,
+		},
+	}
+	for _, method := range []QuantizeMethod{QuantizeMedianCut, QuantizeOctree} {
+		got := Quantize(src, 8, method)
+		want := color.Palette{
+			color.NRGBA{0x00, 0x00, 0x00, 0x00},
+			color.NRGBA{0x00, 0xff, 0x00, 0xff},
+			color.NRGBA{0xff, 0x00, 0x00, 0xff},
+		}
+		if len(got) != len(want) {
+			t.Fatalf("method %d: got palette %v want %v", method, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("method %d: got palette %v want %v", method, got, want)
+			}
+		}
+	}
+
+	if got := Quantize(src, 0, QuantizeMedianCut); got != nil {
+		t.Fatalf("got palette %v want nil", got)
+	}
+}
+
+func TestQuantizeReduce(t *testing.T) {
+	// A gradient with 256 gray levels and two bright red pixels.
+	src := image.NewNRGBA(image.Rect(0, 0, 256, 2))
+	for x := 0; x < 256; x++ {
+		src.SetNRGBA(x, 0, color.NRGBA{uint8(x), uint8(x), uint8(x), 0xff})
+		src.SetNRGBA(x, 1, color.NRGBA{uint8(x), uint8(x), uint8(x), 0xff})
+	}
+	src.SetNRGBA(0, 0, color.NRGBA{0xff, 0x00, 0x00, 0xff})
+	src.SetNRGBA(0, 1, color.NRGBA{0xff, 0x00, 0x00, 0xff})
+
+	for _, method := range []QuantizeMethod{QuantizeMedianCut, QuantizeOctree} {
+		for _, n := range []int{1, 2, 5, 16, 300} {
+			got := Quantize(src, n, method)
+			max := n
+			if max > 256 {
+				max = 256
+			}
+			if len(got) == 0 || len(got) > max {
+				t.Fatalf("method %d, n=%d: got %d colors", method, n, len(got))
+			}
+		}
+
+		p := Quantize(src, 16, method)
+		dst := Dither(src, p, DitherNone)
+		var maxErr int
+		for x := 1; x < 256; x++ {
+			c := p[dst.ColorIndexAt(x, 0)].(color.NRGBA)
+			if d := absint(int(c.G) - x); d > maxErr {
+				maxErr = d
+			}
+		}
+		if maxErr > 24 {
+			t.Fatalf("method %d: got max gray error %d", method, maxErr)
+		}
+	}
+}
+
+func TestQuantizeGIF(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 4, 1),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x12, 0x34, 0x56, 0xff, 0xa0, 0xb0, 0xc0, 0xff, 0xa0, 0xb0, 0xc0, 0xff, 0x01, 0x02, 0x03, 0xff,
+		},
+	}
+	for _, method := range []QuantizeMethod{QuantizeMedianCut, QuantizeOctree} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, src, GIF, GIFQuantizer(method)); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		img, err := Decode(&buf)
+		if err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		if got := Clone(img); !compareNRGBA(got, src, 0) {
+			t.Fatalf("method %d: got %#v want %#v", method, got, src)
+		}
+	}
+}
+
+func BenchmarkQuantize(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Quantize(testdataBranchesJPG, 256, QuantizeMedianCut)
+	}
+}
@@ -0,0 +1,935 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+	"sort"
+)
+
+// This file implements decoding of camera RAW files based on TIFF (DNG, CR2, NEF, ARW, PEF and
+// others). The embedded JPEG preview can be extracted from all of them. The sensor data can be
+// decoded from DNG files only, uncompressed or compressed with lossless JPEG.
+
+var (
+	errInvalidRAW     = errors.New("imaging: invalid RAW data")
+	errRAWUnsupported = errors.New("imaging: unsupported RAW sensor data, only the embedded preview can be decoded")
+	errRAWNoPreview   = errors.New("imaging: no embedded preview found in RAW data")
+)
+
+// TIFF and DNG tags used by the RAW decoder.
+const (
+	tiffNewSubfileType        = 254
+	tiffOrientation           = 274
+	tiffTileWidth             = 322
+	tiffTileLength            = 323
+	tiffTileOffsets           = 324
+	tiffTileByteCounts        = 325
+	tiffSubIFDs               = 330
+	tiffJPEGInterchange       = 513
+	tiffJPEGInterchangeLength = 514
+	tiffCFARepeatPatternDim   = 33421
+	tiffCFAPattern            = 33422
+	dngVersion                = 50706
+	dngLinearizationTable     = 50712
+	dngBlackLevelRepeatDim    = 50713
+	dngBlackLevel             = 50714
+	dngWhiteLevel             = 50717
+	dngDefaultCropOrigin      = 50719
+	dngDefaultCropSize        = 50720
+	dngColorMatrix1           = 50721
+	dngAsShotNeutral          = 50728
+	dngActiveArea             = 50829
+
+	photometricCFA       = 32803
+	photometricLinearRaw = 34892
+)
+
+// tiffField is a TIFF directory entry with the bytes of its value.
+type tiffField struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// tiffDir is a TIFF image file directory.
+type tiffDir map[uint16]tiffField
+
+// tiffReader reads TIFF directories and values from the file data.
+type tiffReader struct {
+	data []byte
+	bo   binary.ByteOrder
+}
+
+// newTIFFReader checks the TIFF header and returns the reader and the first directory offset.
+func newTIFFReader(data []byte) (*tiffReader, uint32, bool) {
+	if len(data) < 8 {
+		return nil, 0, false
+	}
+	var bo binary.ByteOrder
+	switch string(data[:4]) {
+	case "II*\x00":
+		bo = binary.LittleEndian
+	case "MM\x00*":
+		bo = binary.BigEndian
+	default:
+		return nil, 0, false
+	}
+	return &tiffReader{data: data, bo: bo}, bo.Uint32(data[4:]), true
+}
+
+// tiffTypeSizes are the sizes of the TIFF field types in bytes.
+var tiffTypeSizes = [...]int{0, 1, 1, 2, 4, 8, 1, 1, 2, 4, 8, 4, 8, 4}
+
+// dir reads the directory at the offset. It returns the directory and the next directory offset.
+func (t *tiffReader) dir(offset uint32) (tiffDir, uint32, bool) {
+	if int64(offset)+2 > int64(len(t.data)) {
+		return nil, 0, false
+	}
+	n := int(t.bo.Uint16(t.data[offset:]))
+	end := int64(offset) + 2 + int64(n)*12
+	if end+4 > int64(len(t.data)) {
+		return nil, 0, false
+	}
+	d := make(tiffDir, n)
+	for i := 0; i < n; i++ {
+		e := t.data[int(offset)+2+i*12:]
+		typ := t.bo.Uint16(e[2:])
+		count := t.bo.Uint32(e[4:])
+		if int(typ) >= len(tiffTypeSizes) || typ == 0 {
+			continue
+		}
+		size := int64(tiffTypeSizes[typ]) * int64(count)
+		var value []byte
+		if size <= 4 {
+			value = e[8 : 8+size]
+		} else {
+			off := int64(t.bo.Uint32(e[8:]))
+			if off+size > int64(len(t.data)) {
+				continue
+			}
+			value = t.data[off : off+size]
+		}
+		d[t.bo.Uint16(e)] = tiffField{typ: typ, count: count, value: value}
+	}
+	return d, t.bo.Uint32(t.data[end:]), true
+}
+
+// uints returns the values of an integer field.
+func (t *tiffReader) uints(f tiffField) []uint32 {
+	v := make([]uint32, 0, f.count)
+	for i := 0; i < int(f.count); i++ {
+		switch f.typ {
+		case 1, 7:
+			v = append(v, uint32(f.value[i]))
+		case 3:
+			v = append(v, uint32(t.bo.Uint16(f.value[i*2:])))
+		case 4, 13:
+			v = append(v, t.bo.Uint32(f.value[i*4:]))
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// floats returns the values of a numeric field.
+func (t *tiffReader) floats(f tiffField) []float64 {
+	v := make([]float64, 0, f.count)
+	for i := 0; i < int(f.count); i++ {
+		switch f.typ {
+		case 1, 7:
+			v = append(v, float64(f.value[i]))
+		case 3:
+			v = append(v, float64(t.bo.Uint16(f.value[i*2:])))
+		case 4, 13:
+			v = append(v, float64(t.bo.Uint32(f.value[i*4:])))
+		case 8:
+			v = append(v, float64(int16(t.bo.Uint16(f.value[i*2:]))))
+		case 9:
+			v = append(v, float64(int32(t.bo.Uint32(f.value[i*4:]))))
+		case 5, 10:
+			num, den := t.bo.Uint32(f.value[i*8:]), t.bo.Uint32(f.value[i*8+4:])
+			if den == 0 {
+				v = append(v, 0)
+			} else if f.typ == 5 {
+				v = append(v, float64(num)/float64(den))
+			} else {
+				v = append(v, float64(int32(num))/float64(int32(den)))
+			}
+		case 11:
+			v = append(v, float64(math.Float32frombits(t.bo.Uint32(f.value[i*4:]))))
+		case 12:
+			v = append(v, math.Float64frombits(t.bo.Uint64(f.value[i*8:])))
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// uint returns the first value of an integer field or def if it's missing.
+func (t *tiffReader) uint(d tiffDir, tag uint16, def uint32) uint32 {
+	if f, ok := d[tag]; ok {
+		if v := t.uints(f); len(v) > 0 {
+			return v[0]
+		}
+	}
+	return def
+}
+
+// dirs returns all the directories of the file: the main chain and the sub-directories.
+func (t *tiffReader) dirs(first uint32) []tiffDir {
+	var dirs []tiffDir
+	seen := make(map[uint32]bool)
+	queue := []uint32{first}
+	for len(queue) > 0 && len(dirs) < 64 {
+		offset := queue[0]
+		queue = queue[1:]
+		if offset == 0 || seen[offset] {
+			continue
+		}
+		seen[offset] = true
+		d, next, ok := t.dir(offset)
+		if !ok {
+			continue
+		}
+		dirs = append(dirs, d)
+		if f, ok := d[tiffSubIFDs]; ok {
+			queue = append(queue, t.uints(f)...)
+		}
+		queue = append(queue, next)
+	}
+	return dirs
+}
+
+// isRAW reports whether the data is a TIFF-based camera RAW file rather than a plain TIFF image.
+func isRAW(data []byte) bool {
+	t, first, ok := newTIFFReader(data)
+	if !ok {
+		return false
+	}
+	// The Canon CR2 signature.
+	if len(data) >= 10 && string(data[8:10]) == "CR" {
+		return true
+	}
+	for i, d := range t.dirs(first) {
+		if i == 0 {
+			if _, ok := d[dngVersion]; ok {
+				return true
+			}
+		}
+		switch t.uint(d, tiffPhotometric, 0) {
+		case photometricCFA, photometricLinearRaw:
+			return true
+		}
+	}
+	return false
+}
+
+// decodeRAW decodes the embedded preview or, if full is set, the sensor data of a RAW file.
+// It also returns the value of the orientation tag.
+func decodeRAW(data []byte, full bool) (image.Image, orientation, error) {
+	t, first, ok := newTIFFReader(data)
+	if !ok {
+		return nil, orientationUnspecified, errInvalidRAW
+	}
+	dirs := t.dirs(first)
+	if len(dirs) == 0 {
+		return nil, orientationUnspecified, errInvalidRAW
+	}
+	orient := orientation(t.uint(dirs[0], tiffOrientation, orientationUnspecified))
+	var img image.Image
+	var err error
+	if full {
+		img, err = decodeDNG(t, dirs)
+	} else {
+		img, err = decodeRAWPreview(t, dirs)
+	}
+	return img, orient, err
+}
+
+// decodeRAWConfig returns the size and the color model of the image decodeRAW returns
+// without decoding it, and the value of the orientation tag.
+func decodeRAWConfig(data []byte, full bool) (image.Config, orientation, error) {
+	t, first, ok := newTIFFReader(data)
+	if !ok {
+		return image.Config{}, orientationUnspecified, errInvalidRAW
+	}
+	dirs := t.dirs(first)
+	if len(dirs) == 0 {
+		return image.Config{}, orientationUnspecified, errInvalidRAW
+	}
+	orient := orientation(t.uint(dirs[0], tiffOrientation, orientationUnspecified))
+	if full {
+		cfg, err := decodeDNGConfig(t, dirs)
+		return cfg, orient, err
+	}
+	previews := rawPreviews(t, dirs)
+	if len(previews) == 0 {
+		return image.Config{}, orient, errRAWNoPreview
+	}
+	return previews[0].cfg, orient, nil
+}
+
+// rawPreview is an embedded JPEG image of a RAW file.
+type rawPreview struct {
+	data []byte
+	cfg  image.Config
+}
+
+// rawPreviews returns the embedded JPEG images, the largest first.
+func rawPreviews(t *tiffReader, dirs []tiffDir) []rawPreview {
+	var previews []rawPreview
+	add := func(offset, length uint32) {
+		if length == 0 || int64(offset)+int64(length) > int64(len(t.data)) {
+			return
+		}
+		b := t.data[offset : offset+length]
+		if cfg, err := jpeg.DecodeConfig(bytes.NewReader(b)); err == nil {
+			previews = append(previews, rawPreview{b, cfg})
+		}
+	}
+	for _, d := range dirs {
+		add(t.uint(d, tiffJPEGInterchange, 0), t.uint(d, tiffJPEGInterchangeLength, 0))
+		switch t.uint(d, tiffCompression, 1) {
+		case 6, 7:
+			if f, ok := d[tiffStripOffsets]; ok && f.count == 1 {
+				add(t.uint(d, tiffStripOffsets, 0), t.uint(d, tiffStripByteCounts, 0))
+			}
+		}
+	}
+	sort.SliceStable(previews, func(i, j int) bool {
+		return previews[i].cfg.Width*previews[i].cfg.Height > previews[j].cfg.Width*previews[j].cfg.Height
+	})
+	return previews
+}
+
+// decodeRAWPreview returns the largest embedded JPEG image.
+func decodeRAWPreview(t *tiffReader, dirs []tiffDir) (image.Image, error) {
+	for _, p := range rawPreviews(t, dirs) {
+		if img, err := jpeg.Decode(bytes.NewReader(p.data)); err == nil {
+			return img, nil
+		}
+	}
+	return nil, errRAWNoPreview
+}
+
+// rawImage is the sensor data of a RAW image.
+type rawImage struct {
+	w, h    int
+	samples int // 1 for CFA data, 3 for linear RGB data.
+	pix     []uint16
+}
+
+// decodeDNG decodes and develops the sensor data of a DNG file.
+func decodeDNG(t *tiffReader, dirs []tiffDir) (image.Image, error) {
+	raw := dngRawDir(t, dirs)
+	if raw == nil {
+		return nil, errRAWUnsupported
+	}
+	img, err := readRAWData(t, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	// The CFA pattern with 0 for red, 1 for green and 2 for blue.
+	cfaW, cfaH := 2, 2
+	cfa := []uint8{0, 1, 1, 2}
+	if img.samples == 1 {
+		if dims := t.uints(raw[tiffCFARepeatPatternDim]); len(dims) == 2 && dims[0] > 0 && dims[1] > 0 {
+			cfaH, cfaW = int(dims[0]), int(dims[1])
+		}
+		if p := t.uints(raw[tiffCFAPattern]); len(p) == cfaW*cfaH {
+			cfa = make([]uint8, len(p))
+			for i, c := range p {
+				if c > 2 {
+					return nil, errRAWUnsupported
+				}
+				cfa[i] = uint8(c)
+			}
+		} else if cfaW*cfaH != 4 {
+			return nil, errRAWUnsupported
+		}
+	}
+
+	// Crop the active area, the CFA pattern is relative to it.
+	if area := t.uints(raw[dngActiveArea]); len(area) == 4 {
+		img = img.crop(int(area[1]), int(area[0]), int(area[3]), int(area[2]))
+	}
+
+	rgb := developRAW(t, raw, img, cfa, cfaW, cfaH)
+	if b := dngDefaultCrop(t, raw, rgb.Bounds()); b != rgb.Bounds() {
+		return rgb.SubImage(b), nil
+	}
+	return rgb, nil
+}
+
+// dngRawDir returns the directory of the full resolution sensor data of a DNG file or nil.
+func dngRawDir(t *tiffReader, dirs []tiffDir) tiffDir {
+	if _, ok := dirs[0][dngVersion]; !ok {
+		return nil
+	}
+	for _, d := range dirs {
+		p := t.uint(d, tiffPhotometric, 0)
+		if t.uint(d, tiffNewSubfileType, 0) == 0 && (p == photometricCFA || p == photometricLinearRaw) {
+			return d
+		}
+	}
+	return nil
+}
+
+// dngDefaultCrop returns the default crop of the developed image with the given bounds.
+func dngDefaultCrop(t *tiffReader, raw tiffDir, b image.Rectangle) image.Rectangle {
+	if origin := t.floats(raw[dngDefaultCropOrigin]); len(origin) == 2 {
+		if size := t.floats(raw[dngDefaultCropSize]); len(size) == 2 {
+			x, y := int(origin[0]), int(origin[1])
+			if r := image.Rect(x, y, x+int(size[0]), y+int(size[1])).Intersect(b); !r.Empty() {
+				return r
+			}
+		}
+	}
+	return b
+}
+
+// decodeDNGConfig returns the size of the image decodeDNG returns without decoding it.
+func decodeDNGConfig(t *tiffReader, dirs []tiffDir) (image.Config, error) {
+	raw := dngRawDir(t, dirs)
+	if raw == nil {
+		return image.Config{}, errRAWUnsupported
+	}
+	w := int(t.uint(raw, tiffImageWidth, 0))
+	h := int(t.uint(raw, tiffImageLength, 0))
+	if w <= 0 || h <= 0 {
+		return image.Config{}, errInvalidRAW
+	}
+	// The active area is cropped the same way as by rawImage.crop.
+	if area := t.uints(raw[dngActiveArea]); len(area) == 4 {
+		x0, y0 := maxint(int(area[1]), 0), maxint(int(area[0]), 0)
+		x1, y1 := minint(int(area[3]), w), minint(int(area[2]), h)
+		if x0 < x1 && y0 < y1 {
+			w, h = x1-x0, y1-y0
+		}
+	}
+	b := dngDefaultCrop(t, raw, image.Rect(0, 0, w, h))
+	return image.Config{ColorModel: color.NRGBA64Model, Width: b.Dx(), Height: b.Dy()}, nil
+}
+
+func (img *rawImage) crop(x0, y0, x1, y1 int) *rawImage {
+	x0, y0 = maxint(x0, 0), maxint(y0, 0)
+	x1, y1 = minint(x1, img.w), minint(y1, img.h)
+	if x0 >= x1 || y0 >= y1 {
+		return img
+	}
+	dst := &rawImage{w: x1 - x0, h: y1 - y0, samples: img.samples}
+	dst.pix = make([]uint16, dst.w*dst.h*dst.samples)
+	for y := y0; y < y1; y++ {
+		copy(dst.pix[(y-y0)*dst.w*dst.samples:], img.pix[(y*img.w+x0)*img.samples:(y*img.w+x1)*img.samples])
+	}
+	return dst
+}
+
+// readRAWData reads the strips or tiles of the sensor data.
+func readRAWData(t *tiffReader, d tiffDir) (*rawImage, error) {
+	w := int(t.uint(d, tiffImageWidth, 0))
+	h := int(t.uint(d, tiffImageLength, 0))
+	samples := int(t.uint(d, tiffSamplesPerPixel, 1))
+	bits := int(t.uint(d, tiffBitsPerSample, 1))
+	compression := t.uint(d, tiffCompression, 1)
+	if !rawSizeOK(w, h) || (samples != 1 && samples != 3) || bits < 1 || bits > 16 {
+		return nil, errRAWUnsupported
+	}
+	if compression != 1 && compression != 7 {
+		return nil, errRAWUnsupported
+	}
+
+	// Strips are handled as tiles of the image width.
+	tileW, tileH := w, int(t.uint(d, tiffRowsPerStrip, uint32(h)))
+	offsets, counts := t.uints(d[tiffStripOffsets]), t.uints(d[tiffStripByteCounts])
+	if _, ok := d[tiffTileOffsets]; ok {
+		tileW, tileH = int(t.uint(d, tiffTileWidth, 0)), int(t.uint(d, tiffTileLength, 0))
+		offsets, counts = t.uints(d[tiffTileOffsets]), t.uints(d[tiffTileByteCounts])
+	}
+	if tileH > 0 {
+		tileH = minint(tileH, h)
+	}
+	if !rawSizeOK(tileW, tileH) {
+		return nil, errInvalidRAW
+	}
+	across := (w + tileW - 1) / tileW
+	down := (h + tileH - 1) / tileH
+	if len(offsets) < across*down || len(counts) < across*down {
+		return nil, errInvalidRAW
+	}
+
+	img := &rawImage{w: w, h: h, samples: samples, pix: make([]uint16, w*h*samples)}
+	rowLen := tileW * samples
+	for ty := 0; ty < down; ty++ {
+		for tx := 0; tx < across; tx++ {
+			i := ty*across + tx
+			if int64(offsets[i])+int64(counts[i]) > int64(len(t.data)) {
+				return nil, errInvalidRAW
+			}
+			data := t.data[offsets[i] : offsets[i]+counts[i]]
+
+			var tile []uint16
+			if compression == 7 {
+				var err error
+				if tile, err = decodeLosslessJPEG(data); err != nil {
+					return nil, err
+				}
+			} else {
+				tile = unpackRAWSamples(data, rowLen, tileH, bits, t.bo)
+			}
+			// Copy the tile rows clipped to the image.
+			for y := 0; y < tileH; y++ {
+				iy := ty*tileH + y
+				n := minint(tileW, w-tx*tileW) * samples
+				if iy >= h || (y+1)*rowLen > len(tile) {
+					break
+				}
+				copy(img.pix[(iy*w+tx*tileW)*samples:], tile[y*rowLen:y*rowLen+n])
+			}
+		}
+	}
+	return img, nil
+}
+
+// rawSizeOK reports whether the image or the tile of the given size is small enough
+// to be allocated. The sides are checked first so the product doesn't overflow.
+func rawSizeOK(w, h int) bool {
+	return w > 0 && h > 0 && w <= 1<<16 && h <= 1<<16 && int64(w)*int64(h) <= 1<<28
+}
+
+// unpackRAWSamples reads uncompressed samples. Samples of 8 and 16 bits are stored as bytes and
+// words, other sizes are packed MSB first with each row starting on a byte boundary.
+func unpackRAWSamples(data []byte, rowLen, rows, bits int, bo binary.ByteOrder) []uint16 {
+	out := make([]uint16, 0, rowLen*rows)
+	switch bits {
+	case 8:
+		for i := 0; i < len(data) && len(out) < cap(out); i++ {
+			out = append(out, uint16(data[i]))
+		}
+	case 16:
+		for i := 0; i+1 < len(data) && len(out) < cap(out); i += 2 {
+			out = append(out, bo.Uint16(data[i:]))
+		}
+	default:
+		rowBytes := (rowLen*bits + 7) / 8
+		for y := 0; y < rows && (y+1)*rowBytes <= len(data); y++ {
+			row := data[y*rowBytes:]
+			var acc uint32
+			var n, pos int
+			for x := 0; x < rowLen; x++ {
+				for n < bits {
+					acc = acc<<8 | uint32(row[pos])
+					pos++
+					n += 8
+				}
+				n -= bits
+				out = append(out, uint16(acc>>uint(n)&(1<<uint(bits)-1)))
+			}
+		}
+	}
+	return out
+}
+
+// xyzToSRGB converts CIE XYZ to linear sRGB (D65).
+var xyzToSRGB = [3][3]float64{
+	{0.412453, 0.357580, 0.180423},
+	{0.212671, 0.715160, 0.072169},
+	{0.019334, 0.119193, 0.950227},
+}
+
+// developRAW converts the sensor data to an sRGB image: it linearizes the values,
+// applies the white balance, demosaics the CFA data and converts the camera colors to sRGB.
+func developRAW(t *tiffReader, d tiffDir, img *rawImage, cfa []uint8, cfaW, cfaH int) *image.NRGBA64 {
+	lin := t.uints(d[dngLinearizationTable])
+	black := t.floats(d[dngBlackLevel])
+	blackW, blackH := 1, 1
+	if dims := t.uints(d[dngBlackLevelRepeatDim]); len(dims) == 2 {
+		blackH, blackW = maxint(int(dims[0]), 1), maxint(int(dims[1]), 1)
+	}
+	if len(black) < blackW*blackH*img.samples {
+		blackW, blackH = 1, 1
+		if len(black) == 0 {
+			black = []float64{0}
+		}
+	}
+	white := float64(uint32(1)<<t.uint(d, tiffBitsPerSample, 16) - 1)
+	if v := t.floats(d[dngWhiteLevel]); len(v) > 0 {
+		white = v[0]
+	}
+
+	// The white balance multipliers.
+	mul := [3]float64{1, 1, 1}
+	if neutral := t.floats(d[dngAsShotNeutral]); len(neutral) == 3 && neutral[0] > 0 && neutral[1] > 0 && neutral[2] > 0 {
+		min := math.Inf(1)
+		for c := range mul {
+			mul[c] = 1 / neutral[c]
+			min = math.Min(min, mul[c])
+		}
+		for c := range mul {
+			mul[c] /= min
+		}
+	}
+
+	// Normalize the samples to 0..1 with the white balance applied.
+	w, h, spp := img.w, img.h, img.samples
+	values := make([]float32, len(img.pix))
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				for s := 0; s < spp; s++ {
+					i := (y*w+x)*spp + s
+					v := float64(img.pix[i])
+					if len(lin) > 0 {
+						v = float64(lin[minint(int(img.pix[i]), len(lin)-1)])
+					}
+					bi := 0
+					if len(black) > 1 {
+						bi = ((y%blackH)*blackW+x%blackW)*spp + s
+						if spp > 1 && len(black) == spp {
+							bi = s
+						}
+					}
+					v = (v - black[minint(bi, len(black)-1)]) / (white - black[minint(bi, len(black)-1)])
+					c := s
+					if spp == 1 {
+						c = int(cfa[(y%cfaH)*cfaW+x%cfaW])
+					}
+					values[i] = float32(math.Max(0, math.Min(1, v*mul[c])))
+				}
+			}
+		}
+	})
+
+	// The camera to sRGB matrix following dcraw: the rows of the sRGB to camera matrix
+	// are normalized to keep white neutral after the white balance.
+	camToRGB := [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	if cm := t.floats(d[dngColorMatrix1]); len(cm) == 9 {
+		var rgbToCam [3][3]float64
+		for i := 0; i < 3; i++ {
+			var sum float64
+			for j := 0; j < 3; j++ {
+				for k := 0; k < 3; k++ {
+					rgbToCam[i][j] += cm[i*3+k] * xyzToSRGB[k][j]
+				}
+				sum += rgbToCam[i][j]
+			}
+			if sum != 0 {
+				for j := 0; j < 3; j++ {
+					rgbToCam[i][j] /= sum
+				}
+			}
+		}
+		if inv, ok := invert3x3(rgbToCam); ok {
+			camToRGB = inv
+		}
+	}
+
+	dst := image.NewNRGBA64(image.Rect(0, 0, w, h))
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				var cam [3]float64
+				if spp == 3 {
+					for c := 0; c < 3; c++ {
+						cam[c] = float64(values[(y*w+x)*3+c])
+					}
+				} else {
+					// Bilinear demosaicing: average the neighbours of each color.
+					var sums [3]float64
+					var counts [3]int
+					for dy := -1; dy <= 1; dy++ {
+						yy := y + dy
+						if yy < 0 || yy >= h {
+							continue
+						}
+						for dx := -1; dx <= 1; dx++ {
+							xx := x + dx
+							if xx < 0 || xx >= w {
+								continue
+							}
+							c := cfa[(yy%cfaH)*cfaW+xx%cfaW]
+							sums[c] += float64(values[yy*w+xx])
+							counts[c]++
+						}
+					}
+					own := cfa[(y%cfaH)*cfaW+x%cfaW]
+					for c := 0; c < 3; c++ {
+						if uint8(c) == own {
+							cam[c] = float64(values[y*w+x])
+						} else if counts[c] > 0 {
+							cam[c] = sums[c] / float64(counts[c])
+						}
+					}
+				}
+
+				i := y*dst.Stride + x*8
+				for c := 0; c < 3; c++ {
+					v := camToRGB[c][0]*cam[0] + camToRGB[c][1]*cam[1] + camToRGB[c][2]*cam[2]
+					v = math.Max(0, math.Min(1, v))
+					// The sRGB transfer function.
+					if v <= 0.0031308 {
+						v *= 12.92
+					} else {
+						v = 1.055*math.Pow(v, 1/2.4) - 0.055
+					}
+					u := uint16(v*0xffff + 0.5)
+					dst.Pix[i+c*2] = uint8(u >> 8)
+					dst.Pix[i+c*2+1] = uint8(u)
+				}
+				dst.Pix[i+6] = 0xff
+				dst.Pix[i+7] = 0xff
+			}
+		}
+	})
+	return dst
+}
+
+// invert3x3 returns the inverse of the matrix.
+func invert3x3(m [3][3]float64) ([3][3]float64, bool) {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	if math.Abs(det) < 1e-12 {
+		return m, false
+	}
+	var inv [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			// The cofactor of the transposed element.
+			a, b := (j+1)%3, (j+2)%3
+			c, d := (i+1)%3, (i+2)%3
+			inv[i][j] = (m[a][c]*m[b][d] - m[a][d]*m[b][c]) / det
+		}
+	}
+	return inv, true
+}
+
+// decodeLosslessJPEG decodes a lossless (SOF3) JPEG image and returns its samples
+// with the components interleaved.
+func decodeLosslessJPEG(data []byte) ([]uint16, error) {
+	if len(data) < 4 || data[0] != 0xff || data[1] != 0xd8 {
+		return nil, errInvalidRAW
+	}
+	type huffman struct {
+		maxCode [18]int32
+		valPtr  [17]int32
+		minCode [17]int32
+		vals    []uint8
+	}
+	var tables [4]*huffman
+	var precision, width, height, restart int
+	var compIDs []uint8
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xff {
+			return nil, errInvalidRAW
+		}
+		marker := data[pos+1]
+		if marker == 0xff {
+			pos++
+			continue
+		}
+		length := int(data[pos+2])<<8 | int(data[pos+3])
+		if length < 2 || pos+2+length > len(data) {
+			return nil, errInvalidRAW
+		}
+		seg := data[pos+4 : pos+2+length]
+		pos += 2 + length
+
+		switch marker {
+		case 0xc4: // DHT
+			for len(seg) >= 17 {
+				id := seg[0] & 0x0f
+				if id > 3 {
+					return nil, errInvalidRAW
+				}
+				var counts [17]int
+				total := 0
+				for l := 1; l <= 16; l++ {
+					counts[l] = int(seg[l])
+					total += counts[l]
+				}
+				if len(seg) < 17+total {
+					return nil, errInvalidRAW
+				}
+				h := &huffman{vals: seg[17 : 17+total]}
+				code, k := int32(0), int32(0)
+				for l := 1; l <= 16; l++ {
+					h.valPtr[l] = k
+					h.minCode[l] = code
+					code += int32(counts[l])
+					k += int32(counts[l])
+					h.maxCode[l] = code - 1
+					if counts[l] == 0 {
+						h.maxCode[l] = -1
+					}
+					code <<= 1
+				}
+				h.maxCode[17] = math.MaxInt32
+				tables[id] = h
+				seg = seg[17+total:]
+			}
+		case 0xc3: // SOF3
+			if len(seg) < 6 {
+				return nil, errInvalidRAW
+			}
+			precision = int(seg[0])
+			height = int(seg[1])<<8 | int(seg[2])
+			width = int(seg[3])<<8 | int(seg[4])
+			n := int(seg[5])
+			if len(seg) < 6+n*3 || n == 0 || n > 4 {
+				return nil, errInvalidRAW
+			}
+			for i := 0; i < n; i++ {
+				if seg[6+i*3+1] != 0x11 {
+					return nil, errRAWUnsupported // Subsampled components.
+				}
+				compIDs = append(compIDs, seg[6+i*3])
+			}
+		case 0xc0, 0xc1, 0xc2, 0xc5, 0xc6, 0xc7, 0xc9, 0xca, 0xcb, 0xcd, 0xce, 0xcf:
+			return nil, errRAWUnsupported
+		case 0xdd: // DRI
+			if len(seg) < 2 {
+				return nil, errInvalidRAW
+			}
+			restart = int(seg[0])<<8 | int(seg[1])
+		case 0xda: // SOS
+			nc := len(compIDs)
+			if nc == 0 || width <= 0 || height <= 0 || width*height*nc > 1<<28 || len(seg) < 1+nc*2+3 || int(seg[0]) != nc {
+				return nil, errInvalidRAW
+			}
+			htabs := make([]*huffman, nc)
+			for i := 0; i < nc; i++ {
+				htabs[i] = tables[seg[2+i*2]>>4&3]
+				if htabs[i] == nil {
+					return nil, errInvalidRAW
+				}
+			}
+			predictor := int(seg[1+nc*2])
+			pt := uint(seg[3+nc*2] & 0x0f)
+			if predictor < 1 || predictor > 7 {
+				return nil, errInvalidRAW
+			}
+
+			// The bit reader of the entropy-coded data.
+			var acc uint64
+			var bits uint
+			readBit := func() int32 {
+				if bits == 0 {
+					b := byte(0)
+					if pos < len(data) {
+						b = data[pos]
+						if b == 0xff {
+							if pos+1 < len(data) && data[pos+1] == 0 {
+								pos += 2
+							} else {
+								b = 0 // A marker: feed zeros.
+							}
+						} else {
+							pos++
+						}
+					}
+					acc = uint64(b)
+					bits = 8
+				}
+				bits--
+				return int32(acc >> bits & 1)
+			}
+			decode := func(h *huffman) (int32, bool) {
+				code := int32(0)
+				for l := 1; l <= 16; l++ {
+					code = code<<1 | readBit()
+					if code <= h.maxCode[l] {
+						return int32(h.vals[h.valPtr[l]+code-h.minCode[l]]), true
+					}
+				}
+				return 0, false
+			}
+
+			out := make([]uint16, width*height*nc)
+			stride := width * nc
+			initial := int32(1) << uint(precision-int(pt)-1)
+			restartY, restartX := 0, 0
+			mcu := 0
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					if restart > 0 && mcu > 0 && mcu%restart == 0 {
+						// Skip to the RST marker after the byte-aligned data.
+						bits = 0
+						for pos+1 < len(data) && !(data[pos] == 0xff && data[pos+1] >= 0xd0 && data[pos+1] <= 0xd7) {
+							pos++
+						}
+						pos += 2
+						restartY, restartX = y, x
+					}
+					mcu++
+					for c := 0; c < nc; c++ {
+						s, ok := decode(htabs[c])
+						if !ok || s > 16 {
+							return nil, errInvalidRAW
+						}
+						var diff int32
+						switch s {
+						case 0:
+						case 16:
+							diff = 32768
+						default:
+							for i := int32(0); i < s; i++ {
+								diff = diff<<1 | readBit()
+							}
+							if diff < 1<<uint(s-1) {
+								diff -= 1<<uint(s) - 1
+							}
+						}
+
+						i := y*stride + x*nc + c
+						var pred int32
+						switch {
+						case y == restartY && x == restartX:
+							pred = initial
+						case y == restartY:
+							pred = int32(out[i-nc])
+						case x == 0:
+							pred = int32(out[i-stride])
+						default:
+							ra, rb, rc := int32(out[i-nc]), int32(out[i-stride]), int32(out[i-stride-nc])
+							switch predictor {
+							case 1:
+								pred = ra
+							case 2:
+								pred = rb
+							case 3:
+								pred = rc
+							case 4:
+								pred = ra + rb - rc
+							case 5:
+								pred = ra + (rb-rc)>>1
+							case 6:
+								pred = rb + (ra-rc)>>1
+							case 7:
+								pred = (ra + rb) >> 1
+							}
+						}
+						out[i] = uint16(pred + diff)
+					}
+				}
+			}
+			if pt > 0 {
+				for i := range out {
+					out[i] <<= pt
+				}
+			}
+			return out, nil
+		}
+	}
+	return nil, errInvalidRAW
+}
@@ -0,0 +1,483 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// rawTestTag is a TIFF directory entry of a test file.
+type rawTestTag struct {
+	tag, typ uint16
+	count    uint32
+	data     []byte
+}
+
+func rawShorts(tag uint16, values ...uint16) rawTestTag {
+	data := make([]byte, len(values)*2)
+	for i, v := range values {
+		binary.LittleEndian.PutUint16(data[i*2:], v)
+	}
+	return rawTestTag{tag, tiffShort, uint32(len(values)), data}
+}
+
+func rawLongs(tag uint16, values ...uint32) rawTestTag {
+	data := make([]byte, len(values)*4)
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[i*4:], v)
+	}
+	return rawTestTag{tag, tiffLong, uint32(len(values)), data}
+}
+
+func rawBytes(tag uint16, values ...uint8) rawTestTag {
+	return rawTestTag{tag, 1, uint32(len(values)), values}
+}
+
+func rawSRationals(tag uint16, values ...int32) rawTestTag {
+	data := make([]byte, len(values)*8)
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[i*8:], uint32(v))
+		binary.LittleEndian.PutUint32(data[i*8+4:], 10000)
+	}
+	return rawTestTag{tag, 10, uint32(len(values)), data}
+}
+
+// rawTestFile builds a little-endian TIFF file.
+type rawTestFile struct {
+	buf []byte
+}
+
+func newRAWTestFile(magic string) *rawTestFile {
+	f := &rawTestFile{buf: make([]byte, 16)}
+	copy(f.buf, "II*\x00")
+	binary.LittleEndian.PutUint32(f.buf[4:], 16)
+	copy(f.buf[8:], magic)
+	return f
+}
+
+// blob appends the data and returns its offset.
+func (f *rawTestFile) blob(data []byte) uint32 {
+	offset := uint32(len(f.buf))
+	f.buf = append(f.buf, data...)
+	if len(f.buf)%2 == 1 {
+		f.buf = append(f.buf, 0)
+	}
+	return offset
+}
+
+// dir appends a directory and returns its offset.
+func (f *rawTestFile) dir(tags []rawTestTag, next uint32) uint32 {
+	le := binary.LittleEndian
+	d := make([]byte, 2+len(tags)*12+4)
+	le.PutUint16(d, uint16(len(tags)))
+	for i, t := range tags {
+		e := d[2+i*12:]
+		le.PutUint16(e, t.tag)
+		le.PutUint16(e[2:], t.typ)
+		le.PutUint32(e[4:], t.count)
+		if len(t.data) <= 4 {
+			copy(e[8:], t.data)
+		} else {
+			le.PutUint32(e[8:], f.blob(t.data))
+		}
+	}
+	le.PutUint32(d[len(d)-4:], next)
+	return f.blob(d)
+}
+
+// first sets the offset of the first directory.
+func (f *rawTestFile) first(offset uint32) []byte {
+	binary.LittleEndian.PutUint32(f.buf[4:], offset)
+	return f.buf
+}
+
+// encodeLosslessJPEG encodes one-component samples as a lossless JPEG with predictor 1.
+func encodeLosslessJPEG(samples []uint16, w, h, precision int) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0xff, 0xd8})
+	// All 17 difference categories have 5-bit codes equal to the category.
+	buf.Write([]byte{0xff, 0xc4, 0x00, 2 + 17 + 17, 0x00})
+	buf.Write([]byte{0, 0, 0, 0, 17, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0})
+	for i := 0; i <= 16; i++ {
+		buf.WriteByte(uint8(i))
+	}
+	buf.Write([]byte{0xff, 0xc3, 0, 11, uint8(precision), uint8(h >> 8), uint8(h), uint8(w >> 8), uint8(w), 1, 1, 0x11, 0})
+	buf.Write([]byte{0xff, 0xda, 0, 8, 1, 1, 0x00, 1, 0, 0})
+
+	var acc uint32
+	var n uint
+	put := func(v uint32, bits uint) {
+		for i := int(bits) - 1; i >= 0; i-- {
+			acc = acc<<1 | (v>>uint(i))&1
+			n++
+			if n == 8 {
+				buf.WriteByte(uint8(acc))
+				if acc == 0xff {
+					buf.WriteByte(0)
+				}
+				acc, n = 0, 0
+			}
+		}
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var pred int32
+			switch {
+			case x == 0 && y == 0:
+				pred = 1 << uint(precision-1)
+			case y == 0:
+				pred = int32(samples[x-1])
+			case x == 0:
+				pred = int32(samples[(y-1)*w])
+			default:
+				pred = int32(samples[y*w+x-1])
+			}
+			d := int32(samples[y*w+x]) - pred
+			s := uint(0)
+			for a := absint(int(d)); a > 0; a >>= 1 {
+				s++
+			}
+			put(uint32(s), 5)
+			if d < 0 {
+				d += 1<<s - 1
+			}
+			put(uint32(d), s)
+		}
+	}
+	if n > 0 {
+		put(0xff, 8-n)
+	}
+	buf.Write([]byte{0xff, 0xd9})
+	return buf.Bytes()
+}
+
+// rawTestCFA returns RGGB sensor data of a red image with a blue column at x=0.
+func rawTestCFA(w, h int) []uint16 {
+	pix := make([]uint16, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			switch {
+			case x%2 == 0 && y%2 == 0 && x > 1:
+				pix[y*w+x] = 4000 // Red.
+			case x%2 == 1 && y%2 == 1 && x < 2:
+				pix[y*w+x] = 4000 // Blue.
+			default:
+				pix[y*w+x] = 100 // Green and the black level.
+			}
+		}
+	}
+	return pix
+}
+
+// rawTestDNG returns a DNG file with a 16x12 JPEG preview and 8x6 CFA data stored
+// with the given compression and bits per sample.
+func rawTestDNG(t *testing.T, compression, bits uint16, extra ...rawTestTag) []byte {
+	const w, h = 8, 6
+	f := newRAWTestFile("")
+
+	var preview bytes.Buffer
+	if err := jpeg.Encode(&preview, New(16, 12, color.NRGBA{0, 0, 255, 255}), nil); err != nil {
+		t.Fatalf("failed to encode the preview: %v", err)
+	}
+	previewOffset := f.blob(preview.Bytes())
+
+	pix := rawTestCFA(w, h)
+	var data []byte
+	switch {
+	case compression == 7:
+		data = encodeLosslessJPEG(pix, w, h, 12)
+	case bits == 16:
+		data = make([]byte, len(pix)*2)
+		for i, v := range pix {
+			binary.LittleEndian.PutUint16(data[i*2:], v)
+		}
+	case bits == 12:
+		for i := 0; i < len(pix); i += 2 {
+			data = append(data, uint8(pix[i]>>4), uint8(pix[i]<<4)|uint8(pix[i+1]>>8), uint8(pix[i+1]))
+		}
+	}
+	dataOffset := f.blob(data)
+
+	rawTags := []rawTestTag{
+		rawLongs(tiffNewSubfileType, 0),
+		rawLongs(tiffImageWidth, w),
+		rawLongs(tiffImageLength, h),
+		rawShorts(tiffBitsPerSample, bits),
+		rawShorts(tiffCompression, compression),
+		rawShorts(tiffPhotometric, photometricCFA),
+		rawLongs(tiffStripOffsets, dataOffset),
+		rawShorts(tiffSamplesPerPixel, 1),
+		rawLongs(tiffRowsPerStrip, h),
+		rawLongs(tiffStripByteCounts, uint32(len(data))),
+		rawShorts(tiffCFARepeatPatternDim, 2, 2),
+		rawBytes(tiffCFAPattern, 0, 1, 1, 2),
+		rawShorts(dngBlackLevel, 100),
+		rawShorts(dngWhiteLevel, 4000),
+	}
+	rawTags = append(rawTags, extra...)
+	rawIFD := f.dir(rawTags, 0)
+
+	ifd0 := f.dir([]rawTestTag{
+		rawLongs(tiffNewSubfileType, 1),
+		rawLongs(tiffImageWidth, 16),
+		rawLongs(tiffImageLength, 12),
+		rawShorts(tiffCompression, 7),
+		rawShorts(tiffPhotometric, 6),
+		rawLongs(tiffStripOffsets, previewOffset),
+		rawShorts(tiffOrientation, orientationRotate90),
+		rawLongs(tiffStripByteCounts, uint32(preview.Len())),
+		rawLongs(tiffSubIFDs, rawIFD),
+		rawBytes(dngVersion, 1, 4, 0, 0),
+	}, 0)
+	return f.first(ifd0)
+}
+
+func TestDecodeRAWPreview(t *testing.T) {
+	data := rawTestDNG(t, 1, 16)
+
+	img, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got, want := img.Bounds(), image.Rect(0, 0, 16, 12); got != want {
+		t.Fatalf("got bounds %v want %v", got, want)
+	}
+	r, g, b, _ := img.At(8, 6).RGBA()
+	if r>>8 > 8 || g>>8 > 8 || b>>8 < 247 {
+		t.Fatalf("got preview color %v want blue", img.At(8, 6))
+	}
+
+	img, err = Decode(bytes.NewReader(data), AutoOrientation(true))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got, want := img.Bounds(), image.Rect(0, 0, 12, 16); got != want {
+		t.Fatalf("got oriented bounds %v want %v", got, want)
+	}
+}
+
+func TestDecodeRAWFull(t *testing.T) {
+	// The red image with a blue column at x=0 as demosaiced bilinearly.
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 8, 6),
+		Stride: 8 * 4,
+		Pix:    make([]uint8, 8*6*4),
+	}
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 8; x++ {
+			i := y*want.Stride + x*4
+			switch x {
+			case 0:
+				want.Pix[i+2] = 0xff
+			case 1:
+				want.Pix[i], want.Pix[i+2] = 0xbc, 0xff
+			case 2:
+				want.Pix[i] = 0xff
+				want.Pix[i+2] = 0xbc
+			default:
+				want.Pix[i] = 0xff
+			}
+			want.Pix[i+3] = 0xff
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		compression uint16
+		bits        uint16
+	}{
+		{"uncompressed 16-bit", 1, 16},
+		{"uncompressed 12-bit", 1, 12},
+		{"lossless JPEG", 7, 12},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := Decode(bytes.NewReader(rawTestDNG(t, tc.compression, tc.bits)), RAWFullDecode(true))
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if _, ok := img.(*image.NRGBA64); !ok {
+				t.Fatalf("got image type %T want *image.NRGBA64", img)
+			}
+			got := Clone(img)
+			if !compareNRGBA(got, want, 1) {
+				t.Fatalf("got result %#v want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeRAWCrop(t *testing.T) {
+	data := rawTestDNG(t, 1, 16,
+		rawLongs(dngDefaultCropOrigin, 1, 1),
+		rawLongs(dngDefaultCropSize, 4, 3),
+		rawLongs(dngActiveArea, 0, 2, 6, 8),
+	)
+	img, err := Decode(bytes.NewReader(data), RAWFullDecode(true))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got, want := img.Bounds(), image.Rect(1, 1, 5, 4); got != want {
+		t.Fatalf("got bounds %v want %v", got, want)
+	}
+	// The active area leaves the red pixels only.
+	want := color.NRGBA64{0xffff, 0, 0, 0xffff}
+	if got := color.NRGBA64Model.Convert(img.At(1, 1)); got != want {
+		t.Fatalf("got color %v want %v", got, want)
+	}
+}
+
+func TestDecodeRAWTooLarge(t *testing.T) {
+	testCases := []struct {
+		name  string
+		extra []rawTestTag
+		want  error
+	}{
+		{"wide image", []rawTestTag{rawLongs(tiffImageWidth, 1<<17)}, errRAWUnsupported},
+		{"large image", []rawTestTag{rawLongs(tiffImageWidth, 1<<16), rawLongs(tiffImageLength, 1<<16)}, errRAWUnsupported},
+		{"wide tiles", []rawTestTag{
+			rawLongs(tiffTileWidth, 1<<30),
+			rawLongs(tiffTileLength, 6),
+			rawLongs(tiffTileOffsets, 0),
+			rawLongs(tiffTileByteCounts, 0),
+		}, errInvalidRAW},
+	}
+	for _, tc := range testCases {
+		data := rawTestDNG(t, 1, 16, tc.extra...)
+		if _, err := Decode(bytes.NewReader(data), RAWFullDecode(true)); err != tc.want {
+			t.Errorf("%s: got error %v want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestRAWSizeOK(t *testing.T) {
+	testCases := []struct {
+		w, h int
+		want bool
+	}{
+		{1, 1, true},
+		{1 << 16, 1 << 12, true},
+		{0, 1, false},
+		{1, -1, false},
+		{1<<16 + 1, 1, false},
+		{1 << 16, 1 << 13, false},
+		{1 << 30, 1 << 30, false},
+	}
+	for _, tc := range testCases {
+		if got := rawSizeOK(tc.w, tc.h); got != tc.want {
+			t.Errorf("rawSizeOK(%d, %d): got %v want %v", tc.w, tc.h, got, tc.want)
+		}
+	}
+}
+
+func TestDecodeRAWWhiteBalance(t *testing.T) {
+	// With a neutral of (1, 0.5, 1) the green is doubled and the matrix is normalized
+	// so that it stays neutral.
+	data := rawTestDNG(t, 1, 16,
+		rawSRationals(dngColorMatrix1, 10000, 0, 0, 0, 10000, 0, 0, 0, 10000),
+		rawSRationals(dngAsShotNeutral, 10000, 5000, 10000),
+	)
+	img, err := Decode(bytes.NewReader(data), RAWFullDecode(true))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got := img.Bounds(); got != image.Rect(0, 0, 8, 6) {
+		t.Fatalf("got bounds %v", got)
+	}
+	r, _, _, _ := img.At(5, 3).RGBA()
+	if r < 0x8000 {
+		t.Fatalf("got color %v want mostly red", img.At(5, 3))
+	}
+}
+
+func TestDecodeRAWCR2(t *testing.T) {
+	f := newRAWTestFile("CR\x02\x00")
+	var preview bytes.Buffer
+	if err := jpeg.Encode(&preview, New(10, 20, color.White), nil); err != nil {
+		t.Fatalf("failed to encode the preview: %v", err)
+	}
+	var small bytes.Buffer
+	if err := jpeg.Encode(&small, New(4, 2, color.White), nil); err != nil {
+		t.Fatalf("failed to encode the preview: %v", err)
+	}
+	previewOffset := f.blob(preview.Bytes())
+	smallOffset := f.blob(small.Bytes())
+	ifd1 := f.dir([]rawTestTag{
+		rawLongs(tiffJPEGInterchange, smallOffset),
+		rawLongs(tiffJPEGInterchangeLength, uint32(small.Len())),
+	}, 0)
+	ifd0 := f.dir([]rawTestTag{
+		rawShorts(tiffCompression, 6),
+		rawLongs(tiffStripOffsets, previewOffset),
+		rawLongs(tiffStripByteCounts, uint32(preview.Len())),
+	}, ifd1)
+	data := f.first(ifd0)
+
+	img, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got, want := img.Bounds(), image.Rect(0, 0, 10, 20); got != want {
+		t.Fatalf("got bounds %v want %v", got, want)
+	}
+
+	if _, err := Decode(bytes.NewReader(data), RAWFullDecode(true)); err != errRAWUnsupported {
+		t.Fatalf("got error %v want %v", err, errRAWUnsupported)
+	}
+}
+
+func TestDecodeRAWNoPreview(t *testing.T) {
+	f := newRAWTestFile("CR\x02\x00")
+	data := f.first(f.dir([]rawTestTag{rawLongs(tiffImageWidth, 1)}, 0))
+	if _, err := Decode(bytes.NewReader(data)); err != errRAWNoPreview {
+		t.Fatalf("got error %v want %v", err, errRAWNoPreview)
+	}
+}
+
+func TestIsRAW(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, New(2, 2, color.White), TIFF); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if isRAW(buf.Bytes()) {
+		t.Fatalf("a TIFF image is reported as RAW")
+	}
+	if _, err := Decode(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatalf("Decode TIFF: %v", err)
+	}
+	if !isRAW(rawTestDNG(t, 1, 16)) {
+		t.Fatalf("a DNG file is not reported as RAW")
+	}
+	if isRAW([]byte("II*\x00")) {
+		t.Fatalf("truncated data is reported as RAW")
+	}
+}
+
+func TestInvert3x3(t *testing.T) {
+	m := [3][3]float64{{2, 0, 1}, {1, 1, 0}, {0, 3, 1}}
+	inv, ok := invert3x3(m)
+	if !ok {
+		t.Fatalf("the matrix is not inverted")
+	}
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			var v float64
+			for k := 0; k < 3; k++ {
+				v += m[i][k] * inv[k][j]
+			}
+			want := 0.0
+			if i == j {
+				want = 1
+			}
+			if v < want-1e-9 || v > want+1e-9 {
+				t.Fatalf("got product %v at %d,%d want %v", v, i, j, want)
+			}
+		}
+	}
+	if _, ok := invert3x3([3][3]float64{{1, 2, 3}, {2, 4, 6}, {0, 0, 1}}); ok {
+		t.Fatalf("a singular matrix is inverted")
+	}
+}
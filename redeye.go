@@ -0,0 +1,115 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// RemoveRedEye removes the red-eye effect of the flash photos in the given regions of
+// the image and returns the corrected image. The regions are in the coordinates of
+// the image bounds, like the rectangle of Crop, and should enclose the pupils tightly,
+// as the other red pixels inside them, e.g. of the skin or lips, are corrected as well.
+// The red of the pupils is replaced with the mean of the green and blue channels,
+// which keeps their brightness and highlights. Without the regions, the red eyes are
+// found by DetectRedEyes.
+//
+// Example:
+//
+//	// The eyes found automatically.
+//	dstImage := imaging.RemoveRedEye(srcImage)
+//	// The eyes selected by the user.
+//	dstImage = imaging.RemoveRedEye(srcImage, image.Rect(410, 220, 440, 250), image.Rect(520, 225, 550, 255))
+func RemoveRedEye(img image.Image, regions ...image.Rectangle) *image.NRGBA {
+	if len(regions) == 0 {
+		regions = DetectRedEyes(img)
+	}
+
+	dst := Clone(img)
+	min := img.Bounds().Min
+	for _, region := range regions {
+		r := region.Sub(min).Intersect(dst.Rect)
+		parallel(r.Min.Y, r.Max.Y, func(ys <-chan int) {
+			for y := range ys {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					d := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+3 : y*dst.Stride+x*4+3]
+					// The weight grows smoothly with the redness, so the edges of the pupils
+					// blend with the iris.
+					wt := math.Min(math.Max((redness(d[0], d[1], d[2])-0.3)/0.2, 0), 1)
+					if wt > 0 {
+						gray := (float64(d[1]) + float64(d[2])) / 2
+						d[0] = clamp(float64(d[0])*(1-wt) + gray*wt)
+					}
+				}
+			}
+		})
+	}
+	return dst
+}
+
+// DetectRedEyes finds the red eyes of the flash photos in the image and returns
+// the regions enclosing them for RemoveRedEye, in the coordinates of the image bounds.
+// The red eyes are the round areas of the saturated red color, smaller than a tenth
+// of the image, and not surrounded by other red areas.
+//
+// Example:
+//
+//	regions := imaging.DetectRedEyes(srcImage)
+//	// Review the regions, then:
+//	dstImage := imaging.RemoveRedEye(srcImage, regions...)
+func DetectRedEyes(img image.Image) []image.Rectangle {
+	src := newScanner(img)
+	mask := image.NewGray(image.Rect(0, 0, src.w, src.h))
+	parallel(0, src.h, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			for x := 0; x < src.w; x++ {
+				s := scanLine[x*4 : x*4+4 : x*4+4]
+				if s[0] >= 80 && s[3] >= 0x80 && redness(s[0], s[1], s[2]) >= 0.4 {
+					mask.Pix[y*mask.Stride+x] = 0xff
+				}
+			}
+		}
+	})
+
+	_, components := LabelComponents(mask)
+	maxSize := maxint(maxint(src.w, src.h)/10, 8)
+	var regions []image.Rectangle
+	for _, c := range components {
+		bw, bh := c.Bounds.Dx(), c.Bounds.Dy()
+		size := maxint(bw, bh)
+		// The pupils are small and round: they fill about Pi/4 of their bounding box.
+		if c.Area < 4 || size > maxSize || float64(size) > 1.75*float64(minint(bw, bh)) ||
+			float64(c.Area) < 0.45*float64(bw*bh) {
+			continue
+		}
+
+		// The pixels around the pupil are mostly not red, unlike the ones of the red objects.
+		ring := c.Bounds.Inset(-maxint(size/2, 2)).Intersect(mask.Rect)
+		red := 0
+		for y := ring.Min.Y; y < ring.Max.Y; y++ {
+			for x := ring.Min.X; x < ring.Max.X; x++ {
+				if mask.Pix[y*mask.Stride+x] != 0 {
+					red++
+				}
+			}
+		}
+		if float64(red-c.Area) > 0.2*float64(ring.Dx()*ring.Dy()-bw*bh) {
+			continue
+		}
+
+		// The region includes the darker red edge of the pupil.
+		r := c.Bounds.Inset(-(1 + size/4)).Intersect(mask.Rect)
+		regions = append(regions, r.Add(img.Bounds().Min))
+	}
+	return regions
+}
+
+// redness returns how much the red channel of the color exceeds the other channels,
+// relative to the red channel: from 0 for the colors that aren't red to 1 for pure red.
+func redness(r, g, b uint8) float64 {
+	if r == 0 {
+		return 0
+	}
+	return math.Max(float64(r)-math.Max(float64(g), float64(b)), 0) / float64(r)
+}
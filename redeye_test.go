@@ -0,0 +1,108 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// redEyeFace returns a skin-colored image with two red pupils centered at (30, 30)
+// and (70, 30) relative to the bounds, red lips and a red square.
+func redEyeFace() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(10, 10, 130, 100))
+	for y := 0; y < 90; y++ {
+		for x := 0; x < 120; x++ {
+			c := color.NRGBA{220, 170, 140, 0xff}
+			for _, cx := range []int{30, 70} {
+				dx, dy := x-cx, y-30
+				switch {
+				case dx*dx+dy*dy <= 9:
+					c = color.NRGBA{200, 30, 30, 0xff}
+				case dx*dx+dy*dy <= 36:
+					c = color.NRGBA{70, 60, 50, 0xff}
+				}
+			}
+			if x >= 30 && x < 70 && y >= 60 && y < 66 {
+				c = color.NRGBA{190, 40, 50, 0xff}
+			}
+			if x >= 95 && x < 115 && y >= 60 && y < 80 {
+				c = color.NRGBA{210, 20, 20, 0xff}
+			}
+			img.SetNRGBA(10+x, 10+y, c)
+		}
+	}
+	return img
+}
+
+func TestDetectRedEyes(t *testing.T) {
+	got := DetectRedEyes(redEyeFace())
+	want := []image.Rectangle{
+		image.Rect(35, 35, 46, 46),
+		image.Rect(75, 35, 86, 46),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got regions %v want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got regions %v want %v", got, want)
+		}
+	}
+
+	if got := DetectRedEyes(&image.NRGBA{}); len(got) != 0 {
+		t.Fatalf("got regions %v for an empty image", got)
+	}
+}
+
+func TestRemoveRedEye(t *testing.T) {
+	src := redEyeFace()
+	testCases := []struct {
+		name      string
+		regions   []image.Rectangle
+		leftFixed bool
+		rightRed  bool
+	}{
+		{"detected", nil, true, false},
+		{"manual", []image.Rectangle{image.Rect(36, 36, 45, 45)}, true, true},
+		{"outside", []image.Rectangle{image.Rect(200, 200, 210, 210)}, false, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dst := RemoveRedEye(src, tc.regions...)
+			if dst.Rect != image.Rect(0, 0, 120, 90) {
+				t.Fatalf("got bounds %v", dst.Rect)
+			}
+			left, right := dst.NRGBAAt(30, 30), dst.NRGBAAt(70, 30)
+			if fixed := left == (color.NRGBA{30, 30, 30, 0xff}); fixed != tc.leftFixed {
+				t.Fatalf("got left pupil %v", left)
+			}
+			if red := right == (color.NRGBA{200, 30, 30, 0xff}); red != tc.rightRed {
+				t.Fatalf("got right pupil %v", right)
+			}
+			// The skin, iris, lips and the square are kept.
+			for _, p := range []image.Point{{27, 27}, {30, 35}, {50, 62}, {100, 70}} {
+				if got, want := dst.NRGBAAt(p.X, p.Y), src.NRGBAAt(10+p.X, 10+p.Y); got != want {
+					t.Fatalf("got %v at %v want %v", got, p, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRemoveRedEyeAlpha(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	src.SetNRGBA(0, 0, color.NRGBA{200, 40, 60, 0x80})
+	src.SetNRGBA(1, 0, color.NRGBA{200, 150, 160, 0x80})
+	dst := RemoveRedEye(src, src.Rect)
+	want := []uint8{50, 40, 60, 0x80, 200, 150, 160, 0x80}
+	if !compareNRGBA(dst, &image.NRGBA{Rect: src.Rect, Stride: 8, Pix: want}, 0) {
+		t.Fatalf("got %v want %v", dst.Pix, want)
+	}
+}
+
+func BenchmarkRemoveRedEye(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		RemoveRedEye(testdataBranchesJPG)
+	}
+}
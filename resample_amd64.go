@@ -0,0 +1,10 @@
+//go:build !purego
+
+package imaging
+
+// resampleRow is resampleRowGeneric implemented with SSE2 instructions, which all amd64
+// processors support. The arithmetic operations are the same, so are the results.
+// The slices aren't bounds checked, precomputeWeights guarantees the source pixels exist.
+//
+//go:noescape
+func resampleRow(sums, line, alpha, weights []float64, starts, counts []int)
@@ -0,0 +1,60 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// func resampleRow(sums, line, alpha, weights []float64, starts, counts []int)
+TEXT ·resampleRow(SB), NOSPLIT, $0-144
+	MOVQ sums_base+0(FP), DI
+	MOVQ line_base+24(FP), R8
+	MOVQ alpha_base+48(FP), R9
+	MOVQ weights_base+72(FP), DX
+	MOVQ starts_base+96(FP), R10
+	MOVQ starts_len+104(FP), R11
+	MOVQ counts_base+120(FP), R12
+	TESTQ R11, R11
+	JZ done
+
+pixel:
+	// SI and BX point to the first source pixel in line and alpha, CX is the pixel count.
+	MOVQ (R10), AX
+	MOVQ AX, SI
+	SHLQ $5, SI
+	ADDQ R8, SI
+	LEAQ (R9)(AX*8), BX
+	MOVQ (R12), CX
+	XORPD X0, X0 // The sums of the red and the green channels.
+	XORPD X1, X1 // The sums of the blue and the alpha channels.
+	TESTQ CX, CX
+	JZ store
+
+loop:
+	// The weighted alpha aw = alpha[k] * weights[k] in both halves of X2.
+	MOVSD (BX), X2
+	MULSD (DX), X2
+	UNPCKLPD X2, X2
+
+	// The channels of the line are red, green, blue and 1, so the alpha sum gets aw.
+	MOVUPD (SI), X3
+	MULPD X2, X3
+	ADDPD X3, X0
+	MOVUPD 16(SI), X4
+	MULPD X2, X4
+	ADDPD X4, X1
+
+	ADDQ $32, SI
+	ADDQ $8, BX
+	ADDQ $8, DX
+	DECQ CX
+	JNZ loop
+
+store:
+	MOVUPD X0, (DI)
+	MOVUPD X1, 16(DI)
+	ADDQ $32, DI
+	ADDQ $8, R10
+	ADDQ $8, R12
+	DECQ R11
+	JNZ pixel
+
+done:
+	RET
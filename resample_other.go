@@ -0,0 +1,7 @@
+//go:build !amd64 || purego
+
+package imaging
+
+func resampleRow(sums, line, alpha, weights []float64, starts, counts []int) {
+	resampleRowGeneric(sums, line, alpha, weights, starts, counts)
+}
@@ -5,12 +5,16 @@ import (
 	"math"
 )
 
-type indexWeight struct {
-	index  int
-	weight float64
+// resampleWeights are the weights of the source pixels of the destination pixels. The source
+// pixels of a destination pixel are contiguous, count[i] pixels from starts[i] on, and their
+// weights are consecutive in weights.
+type resampleWeights struct {
+	starts  []int
+	counts  []int
+	weights []float64
 }
 
-func precomputeWeights(dstSize, srcSize int, filter ResampleFilter) [][]indexWeight {
+func precomputeWeights(dstSize, srcSize int, filter ResampleFilter) *resampleWeights {
 	du := float64(srcSize) / float64(dstSize)
 	scale := du
 	if scale < 1.0 {
@@ -18,8 +22,11 @@ func precomputeWeights(dstSize, srcSize int, filter ResampleFilter) [][]indexWei
 	}
 	ru := math.Ceil(scale * filter.Support)
 
-	out := make([][]indexWeight, dstSize)
-	tmp := make([]indexWeight, 0, dstSize*int(ru+2)*2)
+	out := &resampleWeights{
+		starts:  make([]int, dstSize),
+		counts:  make([]int, dstSize),
+		weights: make([]float64, 0, dstSize*int(ru+2)*2),
+	}
 
 	for v := 0; v < dstSize; v++ {
 		fu := (float64(v)+0.5)*du - 0.5
@@ -33,27 +40,92 @@ func precomputeWeights(dstSize, srcSize int, filter ResampleFilter) [][]indexWei
 			end = srcSize - 1
 		}
 
+		// The zero weights are kept to make the pixels contiguous, they don't change the sums.
+		tmp := out.weights[len(out.weights):]
 		var sum float64
 		for u := begin; u <= end; u++ {
 			w := filter.Kernel((float64(u) - fu) / scale)
-			if w != 0 {
-				sum += w
-				tmp = append(tmp, indexWeight{index: u, weight: w})
-			}
+			sum += w
+			tmp = append(tmp, w)
 		}
 		if sum != 0 {
 			for i := range tmp {
-				tmp[i].weight /= sum
+				tmp[i] /= sum
 			}
 		}
 
-		out[v] = tmp
-		tmp = tmp[len(tmp):]
+		out.starts[v] = begin
+		out.counts[v] = len(tmp)
+		out.weights = out.weights[:len(out.weights)+len(tmp)]
 	}
 
 	return out
 }
 
+// resampleLine resamples the NRGBA pixels of a row or a column of the source image.
+type resampleLine struct {
+	w     *resampleWeights
+	line  []float64 // The color channels and 1 of the source pixels.
+	alpha []float64 // The alpha channel of the source pixels.
+	sums  []float64 // The sums of the destination pixels.
+}
+
+func newResampleLine(w *resampleWeights, srcSize int) *resampleLine {
+	return &resampleLine{
+		w:     w,
+		line:  make([]float64, srcSize*4),
+		alpha: make([]float64, srcSize),
+		sums:  make([]float64, len(w.starts)*4),
+	}
+}
+
+// resample resamples the source pixels and stores the destination pixels in dst, the pixels
+// are stride bytes apart. The transparent destination pixels are left unchanged.
+func (l *resampleLine) resample(dst []uint8, stride int, pix []uint8) {
+	for i := range l.alpha {
+		s := pix[i*4 : i*4+4 : i*4+4]
+		d := l.line[i*4 : i*4+4 : i*4+4]
+		d[0] = float64(s[0])
+		d[1] = float64(s[1])
+		d[2] = float64(s[2])
+		d[3] = 1
+		l.alpha[i] = float64(s[3])
+	}
+	resampleRow(l.sums, l.line, l.alpha, l.w.weights, l.w.starts, l.w.counts)
+	for i := range l.w.starts {
+		s := l.sums[i*4 : i*4+4 : i*4+4]
+		if a := s[3]; a != 0 {
+			aInv := 1 / a
+			j := i * stride
+			d := dst[j : j+4 : j+4]
+			d[0] = clamp(s[0] * aInv)
+			d[1] = clamp(s[1] * aInv)
+			d[2] = clamp(s[2] * aInv)
+			d[3] = clamp(a)
+		}
+	}
+}
+
+// resampleRowGeneric stores the sums of the color channels premultiplied by the alpha and
+// the sum of the alpha of the weighted source pixels of each destination pixel in sums.
+func resampleRowGeneric(sums, line, alpha, weights []float64, starts, counts []int) {
+	for i, start := range starts {
+		n := counts[i]
+		ls, as := line[start*4:(start+n)*4], alpha[start:start+n]
+		var r, g, b, a float64
+		for k, w := range weights[:n] {
+			aw := as[k] * w
+			s := ls[k*4 : k*4+4 : k*4+4]
+			r += s[0] * aw
+			g += s[1] * aw
+			b += s[2] * aw
+			a += aw
+		}
+		weights = weights[n:]
+		sums[i*4], sums[i*4+1], sums[i*4+2], sums[i*4+3] = r, g, b, a
+	}
+}
+
 // Resize resizes the image to the specified width and height using the specified resampling
 // filter and returns the transformed image. If one of width or height is 0, the image aspect
 // ratio is preserved.
@@ -63,18 +135,56 @@ func precomputeWeights(dstSize, srcSize int, filter ResampleFilter) [][]indexWei
 //	dstImage := imaging.Resize(srcImage, 800, 600, imaging.Lanczos)
 //
 func Resize(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
-	dstW, dstH := width, height
-	if dstW < 0 || dstH < 0 {
+	return defaultEngine.resize(img, width, height, filter)
+}
+
+func (e *Engine) resize(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
+	srcW, srcH := img.Bounds().Dx(), img.Bounds().Dy()
+	dstW, dstH, ok := resizeSize(srcW, srcH, width, height)
+	if !ok {
 		return &image.NRGBA{}
 	}
-	if dstW == 0 && dstH == 0 {
-		return &image.NRGBA{}
+
+	if srcW == dstW && srcH == dstH {
+		return e.clone(img)
+	}
+
+	if filter.Support <= 0 {
+		// Nearest-neighbor special case.
+		return e.resizeNearest(img, dstW, dstH)
 	}
 
-	srcW := img.Bounds().Dx()
-	srcH := img.Bounds().Dy()
+	if srcW != dstW && srcH != dstH {
+		// The horizontal pass goes first unless the vertical one makes a much smaller
+		// intermediate image, e.g. when a tall panorama is widened and shortened.
+		if float64(srcW)*float64(dstH)*2 < float64(dstW)*float64(srcH) {
+			tmp := e.resizeVertical(img, dstH, filter)
+			defer Release(tmp)
+			return e.resizeHorizontal(tmp, dstW, filter)
+		}
+		tmp := e.resizeHorizontal(img, dstW, filter)
+		defer Release(tmp)
+		return e.resizeVertical(tmp, dstH, filter)
+	}
+	if srcW != dstW {
+		return e.resizeHorizontal(img, dstW, filter)
+	}
+	return e.resizeVertical(img, dstH, filter)
+
+}
+
+// resizeSize returns the size of the resized image, preserving the aspect ratio if one of
+// width or height is 0. It reports false if the resized image is empty.
+func resizeSize(srcW, srcH, width, height int) (dstW, dstH int, ok bool) {
+	dstW, dstH = width, height
+	if dstW < 0 || dstH < 0 {
+		return 0, 0, false
+	}
+	if dstW == 0 && dstH == 0 {
+		return 0, 0, false
+	}
 	if srcW <= 0 || srcH <= 0 {
-		return &image.NRGBA{}
+		return 0, 0, false
 	}
 
 	// If new width or height is 0 then preserve aspect ratio, minimum 1px.
@@ -86,104 +196,117 @@ func Resize(img image.Image, width, height int, filter ResampleFilter) *image.NR
 		tmpH := float64(dstW) * float64(srcH) / float64(srcW)
 		dstH = int(math.Max(1.0, math.Floor(tmpH+0.5)))
 	}
+	return dstW, dstH, true
+}
 
-	if srcW == dstW && srcH == dstH {
-		return Clone(img)
-	}
-
-	if filter.Support <= 0 {
-		// Nearest-neighbor special case.
-		return resizeNearest(img, dstW, dstH)
-	}
+// ResizeFast resizes the image like Resize with the Box filter. If the image width and height
+// are integer multiples of the new width and height, e.g. when halving the image, every pixel
+// is the average of a block of the source pixels computed in a single pass, which is about
+// twice as fast. The results differ from Resize by at most 1 in each channel, as Resize rounds
+// the results of its horizontal and vertical passes separately. Other sizes are resized by
+// Resize.
+//
+// Example:
+//
+//	thumb := imaging.ResizeFast(srcImage, srcImage.Bounds().Dx()/4, 0)
+func ResizeFast(img image.Image, width, height int) *image.NRGBA {
+	return defaultEngine.resizeFast(img, width, height)
+}
 
-	if srcW != dstW && srcH != dstH {
-		return resizeVertical(resizeHorizontal(img, dstW, filter), dstH, filter)
-	}
-	if srcW != dstW {
-		return resizeHorizontal(img, dstW, filter)
+func (e *Engine) resizeFast(img image.Image, width, height int) *image.NRGBA {
+	srcW, srcH := img.Bounds().Dx(), img.Bounds().Dy()
+	dstW, dstH, ok := resizeSize(srcW, srcH, width, height)
+	if !ok || srcW%dstW != 0 || srcH%dstH != 0 || (srcW == dstW && srcH == dstH) {
+		return e.resize(img, width, height, Box)
 	}
-	return resizeVertical(img, dstH, filter)
+	return e.resizeBox(img, srcW/dstW, srcH/dstH)
+}
 
+// resizeBox downscales the image by the integer factors, averaging the blocks of kx by ky
+// source pixels weighted by their alpha.
+func (e *Engine) resizeBox(img image.Image, kx, ky int) *image.NRGBA {
+	src := newScanner(img)
+	dstW, dstH := src.w/kx, src.h/ky
+	dst := newNRGBA(image.Rect(0, 0, dstW, dstH))
+	n := uint64(kx * ky)
+	e.parallel(0, dstH, func(ys <-chan int) {
+		block := make([]uint8, src.w*ky*4)
+		sums := make([]uint64, dstW*4)
+		for y := range ys {
+			src.scan(0, y*ky, src.w, (y+1)*ky, block)
+			clear(sums)
+			i := 0
+			for r := 0; r < ky; r++ {
+				for x := 0; x < dstW; x++ {
+					sum := sums[x*4 : x*4+4 : x*4+4]
+					for k := 0; k < kx; k++ {
+						s := block[i : i+4 : i+4]
+						a := uint64(s[3])
+						sum[0] += uint64(s[0]) * a
+						sum[1] += uint64(s[1]) * a
+						sum[2] += uint64(s[2]) * a
+						sum[3] += a
+						i += 4
+					}
+				}
+			}
+			row := dst.Pix[y*dst.Stride : y*dst.Stride+dstW*4]
+			for x := 0; x < dstW; x++ {
+				sum := sums[x*4 : x*4+4 : x*4+4]
+				a := sum[3]
+				if a == 0 {
+					continue
+				}
+				d := row[x*4 : x*4+4 : x*4+4]
+				d[0] = uint8((sum[0] + a/2) / a)
+				d[1] = uint8((sum[1] + a/2) / a)
+				d[2] = uint8((sum[2] + a/2) / a)
+				d[3] = uint8((a + n/2) / n)
+			}
+		}
+	})
+	return dst
 }
 
-func resizeHorizontal(img image.Image, width int, filter ResampleFilter) *image.NRGBA {
+func (e *Engine) resizeHorizontal(img image.Image, width int, filter ResampleFilter) *image.NRGBA {
 	src := newScanner(img)
-	dst := image.NewNRGBA(image.Rect(0, 0, width, src.h))
+	dst := newNRGBA(image.Rect(0, 0, width, src.h))
 	weights := precomputeWeights(width, src.w, filter)
-	parallel(0, src.h, func(ys <-chan int) {
+	e.parallel(0, src.h, func(ys <-chan int) {
 		scanLine := make([]uint8, src.w*4)
+		l := newResampleLine(weights, src.w)
 		for y := range ys {
 			src.scan(0, y, src.w, y+1, scanLine)
-			j0 := y * dst.Stride
-			for x := range weights {
-				var r, g, b, a float64
-				for _, w := range weights[x] {
-					i := w.index * 4
-					s := scanLine[i : i+4 : i+4]
-					aw := float64(s[3]) * w.weight
-					r += float64(s[0]) * aw
-					g += float64(s[1]) * aw
-					b += float64(s[2]) * aw
-					a += aw
-				}
-				if a != 0 {
-					aInv := 1 / a
-					j := j0 + x*4
-					d := dst.Pix[j : j+4 : j+4]
-					d[0] = clamp(r * aInv)
-					d[1] = clamp(g * aInv)
-					d[2] = clamp(b * aInv)
-					d[3] = clamp(a)
-				}
-			}
+			l.resample(dst.Pix[y*dst.Stride:], 4, scanLine)
 		}
 	})
 	return dst
 }
 
-func resizeVertical(img image.Image, height int, filter ResampleFilter) *image.NRGBA {
+func (e *Engine) resizeVertical(img image.Image, height int, filter ResampleFilter) *image.NRGBA {
 	src := newScanner(img)
-	dst := image.NewNRGBA(image.Rect(0, 0, src.w, height))
+	dst := newNRGBA(image.Rect(0, 0, src.w, height))
 	weights := precomputeWeights(height, src.h, filter)
-	parallel(0, src.w, func(xs <-chan int) {
+	e.parallel(0, src.w, func(xs <-chan int) {
 		scanLine := make([]uint8, src.h*4)
+		l := newResampleLine(weights, src.h)
 		for x := range xs {
 			src.scan(x, 0, x+1, src.h, scanLine)
-			for y := range weights {
-				var r, g, b, a float64
-				for _, w := range weights[y] {
-					i := w.index * 4
-					s := scanLine[i : i+4 : i+4]
-					aw := float64(s[3]) * w.weight
-					r += float64(s[0]) * aw
-					g += float64(s[1]) * aw
-					b += float64(s[2]) * aw
-					a += aw
-				}
-				if a != 0 {
-					aInv := 1 / a
-					j := y*dst.Stride + x*4
-					d := dst.Pix[j : j+4 : j+4]
-					d[0] = clamp(r * aInv)
-					d[1] = clamp(g * aInv)
-					d[2] = clamp(b * aInv)
-					d[3] = clamp(a)
-				}
-			}
+			l.resample(dst.Pix[x*4:], dst.Stride, scanLine)
 		}
 	})
 	return dst
 }
 
 // resizeNearest is a fast nearest-neighbor resize, no filtering.
-func resizeNearest(img image.Image, width, height int) *image.NRGBA {
-	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+func (e *Engine) resizeNearest(img image.Image, width, height int) *image.NRGBA {
+	dst := newNRGBA(image.Rect(0, 0, width, height))
 	dx := float64(img.Bounds().Dx()) / float64(width)
 	dy := float64(img.Bounds().Dy()) / float64(height)
 
 	if dx > 1 && dy > 1 {
 		src := newScanner(img)
-		parallel(0, height, func(ys <-chan int) {
+		e.parallel(0, height, func(ys <-chan int) {
 			for y := range ys {
 				srcY := int((float64(y) + 0.5) * dy)
 				dstOff := y * dst.Stride
@@ -196,7 +319,7 @@ func resizeNearest(img image.Image, width, height int) *image.NRGBA {
 		})
 	} else {
 		src := toNRGBA(img)
-		parallel(0, height, func(ys <-chan int) {
+		e.parallel(0, height, func(ys <-chan int) {
 			for y := range ys {
 				srcY := int((float64(y) + 0.5) * dy)
 				srcOff0 := srcY * src.Stride
@@ -222,6 +345,10 @@ func resizeNearest(img image.Image, width, height int) *image.NRGBA {
 //	dstImage := imaging.Fit(srcImage, 800, 600, imaging.Lanczos)
 //
 func Fit(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
+	return defaultEngine.fit(img, width, height, filter)
+}
+
+func (e *Engine) fit(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA {
 	maxW, maxH := width, height
 
 	if maxW <= 0 || maxH <= 0 {
@@ -237,7 +364,7 @@ func Fit(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA
 	}
 
 	if srcW <= maxW && srcH <= maxH {
-		return Clone(img)
+		return e.clone(img)
 	}
 
 	srcAspectRatio := float64(srcW) / float64(srcH)
@@ -246,13 +373,13 @@ func Fit(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA
 	var newW, newH int
 	if srcAspectRatio > maxAspectRatio {
 		newW = maxW
-		newH = int(float64(newW) / srcAspectRatio)
+		newH = int(math.Max(1, float64(newW)/srcAspectRatio))
 	} else {
 		newH = maxH
-		newW = int(float64(newH) * srcAspectRatio)
+		newW = int(math.Max(1, float64(newH)*srcAspectRatio))
 	}
 
-	return Resize(img, newW, newH, filter)
+	return e.resize(img, newW, newH, filter)
 }
 
 // Fill creates an image with the specified dimensions and fills it with the scaled source image.
@@ -263,6 +390,10 @@ func Fit(img image.Image, width, height int, filter ResampleFilter) *image.NRGBA
 //	dstImage := imaging.Fill(srcImage, 800, 600, imaging.Center, imaging.Lanczos)
 //
 func Fill(img image.Image, width, height int, anchor Anchor, filter ResampleFilter) *image.NRGBA {
+	return defaultEngine.fill(img, width, height, anchor, filter)
+}
+
+func (e *Engine) fill(img image.Image, width, height int, anchor Anchor, filter ResampleFilter) *image.NRGBA {
 	dstW, dstH := width, height
 
 	if dstW <= 0 || dstH <= 0 {
@@ -278,20 +409,32 @@ func Fill(img image.Image, width, height int, anchor Anchor, filter ResampleFilt
 	}
 
 	if srcW == dstW && srcH == dstH {
-		return Clone(img)
+		return e.clone(img)
 	}
 
-	if srcW >= 100 && srcH >= 100 {
-		return cropAndResize(img, dstW, dstH, anchor, filter)
+	if (srcW >= 100 && srcH >= 100) || fillCoverRatio(srcW, srcH, dstW, dstH) > maxFillCoverRatio {
+		return e.cropAndResize(img, dstW, dstH, anchor, filter)
 	}
-	return resizeAndCrop(img, dstW, dstH, anchor, filter)
+	return e.resizeAndCrop(img, dstW, dstH, anchor, filter)
+}
+
+// maxFillCoverRatio limits the size of the image resizeAndCrop resizes the source image to,
+// relative to the destination image. The small images with extreme aspect ratios, e.g.
+// a 20000x50 panorama filling a square, are cropped first instead.
+const maxFillCoverRatio = 16
+
+// fillCoverRatio returns the ratio of the size of the image covering the destination size
+// with the source aspect ratio to the destination size.
+func fillCoverRatio(srcW, srcH, dstW, dstH int) float64 {
+	r := float64(srcW) * float64(dstH) / (float64(srcH) * float64(dstW))
+	return math.Max(r, 1/r)
 }
 
 // cropAndResize crops the image to the smallest possible size that has the required aspect ratio using
 // the given anchor point, then scales it to the specified dimensions and returns the transformed image.
 //
 // This is generally faster than resizing first, but may result in inaccuracies when used on small source images.
-func cropAndResize(img image.Image, width, height int, anchor Anchor, filter ResampleFilter) *image.NRGBA {
+func (e *Engine) cropAndResize(img image.Image, width, height int, anchor Anchor, filter ResampleFilter) *image.NRGBA {
 	dstW, dstH := width, height
 
 	srcBounds := img.Bounds()
@@ -303,19 +446,20 @@ func cropAndResize(img image.Image, width, height int, anchor Anchor, filter Res
 	var tmp *image.NRGBA
 	if srcAspectRatio < dstAspectRatio {
 		cropH := float64(srcW) * float64(dstH) / float64(dstW)
-		tmp = CropAnchor(img, srcW, int(math.Max(1, cropH)+0.5), anchor)
+		tmp = e.cropAnchor(img, srcW, int(math.Max(1, cropH)+0.5), anchor)
 	} else {
 		cropW := float64(srcH) * float64(dstW) / float64(dstH)
-		tmp = CropAnchor(img, int(math.Max(1, cropW)+0.5), srcH, anchor)
+		tmp = e.cropAnchor(img, int(math.Max(1, cropW)+0.5), srcH, anchor)
 	}
+	defer Release(tmp)
 
-	return Resize(tmp, dstW, dstH, filter)
+	return e.resize(tmp, dstW, dstH, filter)
 }
 
 // resizeAndCrop resizes the image to the smallest possible size that will cover the specified dimensions,
 // crops the resized image to the specified dimensions using the given anchor point and returns
 // the transformed image.
-func resizeAndCrop(img image.Image, width, height int, anchor Anchor, filter ResampleFilter) *image.NRGBA {
+func (e *Engine) resizeAndCrop(img image.Image, width, height int, anchor Anchor, filter ResampleFilter) *image.NRGBA {
 	dstW, dstH := width, height
 
 	srcBounds := img.Bounds()
@@ -326,12 +470,13 @@ func resizeAndCrop(img image.Image, width, height int, anchor Anchor, filter Res
 
 	var tmp *image.NRGBA
 	if srcAspectRatio < dstAspectRatio {
-		tmp = Resize(img, dstW, 0, filter)
+		tmp = e.resize(img, dstW, 0, filter)
 	} else {
-		tmp = Resize(img, 0, dstH, filter)
+		tmp = e.resize(img, 0, dstH, filter)
 	}
+	defer Release(tmp)
 
-	return CropAnchor(tmp, dstW, dstH, anchor)
+	return e.cropAnchor(tmp, dstW, dstH, anchor)
 }
 
 // Thumbnail scales the image up or down using the specified resample filter, crops it
@@ -3,6 +3,8 @@ package imaging
 import (
 	"fmt"
 	"image"
+	"image/color"
+	"math/rand"
 	"path/filepath"
 	"testing"
 )
@@ -260,6 +262,70 @@ func TestResizeGolden(t *testing.T) {
 	}
 }
 
+// panorama returns an image of the given size with a diagonal color gradient.
+func panorama(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{uint8(x * 255 / w), uint8(y * 255 / h), uint8((x + y) % 256), 0xff})
+		}
+	}
+	return img
+}
+
+func TestResizeExtremeAspect(t *testing.T) {
+	// A tall image widened and shortened is resized vertically first, keeping
+	// the intermediate image small.
+	src := panorama(20, 4000)
+	got := Resize(src, 200, 100, Linear)
+	want := Resize(Resize(src, 20, 100, Linear), 200, 100, Linear)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatal("the tall image isn't resized vertically first")
+	}
+
+	// The other images are resized horizontally first.
+	src = panorama(4000, 20)
+	got = Resize(src, 100, 200, Linear)
+	want = Resize(Resize(src, 100, 20, Linear), 100, 200, Linear)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatal("the wide image isn't resized horizontally first")
+	}
+}
+
+func TestFitExtremeAspect(t *testing.T) {
+	for _, tc := range []struct {
+		srcW, srcH, w, h int
+		want             image.Rectangle
+	}{
+		{20000, 10, 100, 100, image.Rect(0, 0, 100, 1)},
+		{10, 20000, 100, 100, image.Rect(0, 0, 1, 100)},
+		{5000, 50, 800, 600, image.Rect(0, 0, 800, 8)},
+	} {
+		if got := Fit(panorama(tc.srcW, tc.srcH), tc.w, tc.h, Lanczos); got.Rect != tc.want {
+			t.Fatalf("Fit %dx%d %dx%d: got bounds %v want %v", tc.srcW, tc.srcH, tc.w, tc.h, got.Rect, tc.want)
+		}
+	}
+}
+
+func TestFillExtremeAspect(t *testing.T) {
+	// The small panorama is cropped before it's resized, instead of being resized
+	// to 12000x300 first.
+	src := panorama(2000, 50)
+	got := Fill(src, 300, 300, Center, Lanczos)
+	want := defaultEngine.cropAndResize(src, 300, 300, Center, Lanczos)
+	if got.Rect != image.Rect(0, 0, 300, 300) || !compareNRGBA(got, want, 0) {
+		t.Fatal("the panorama isn't cropped first")
+	}
+
+	// The small images with moderate aspect ratios are resized first.
+	src = panorama(40, 20)
+	got = Fill(src, 30, 30, Center, Lanczos)
+	want = defaultEngine.resizeAndCrop(src, 30, 30, Center, Lanczos)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatal("the small image isn't resized first")
+	}
+}
+
 func TestFit(t *testing.T) {
 	testCases := []struct {
 		name string
@@ -635,7 +701,7 @@ func TestResizeAndCrop(t *testing.T) {
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			got := resizeAndCrop(tc.src, tc.w, tc.h, tc.a, tc.f)
+			got := defaultEngine.resizeAndCrop(tc.src, tc.w, tc.h, tc.a, tc.f)
 			if !compareNRGBA(got, tc.want, 0) {
 				t.Fatalf("got result %#v want %#v", got, tc.want)
 			}
@@ -785,7 +851,7 @@ func TestCropAndResize(t *testing.T) {
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			got := cropAndResize(tc.src, tc.w, tc.h, tc.a, tc.f)
+			got := defaultEngine.cropAndResize(tc.src, tc.w, tc.h, tc.a, tc.f)
 			if !compareNRGBA(got, tc.want, 0) {
 				t.Fatalf("got result %#v want %#v", got, tc.want)
 			}
@@ -874,6 +940,114 @@ func TestThumbnail(t *testing.T) {
 	}
 }
 
+func TestResampleRow(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	const srcSize = 50
+	line := make([]float64, srcSize*4)
+	alpha := make([]float64, srcSize)
+	for i := range alpha {
+		line[i*4], line[i*4+1], line[i*4+2], line[i*4+3] = float64(rnd.Intn(256)), float64(rnd.Intn(256)), float64(rnd.Intn(256)), 1
+		alpha[i] = float64(rnd.Intn(256))
+	}
+	for _, filter := range []ResampleFilter{Box, Linear, CatmullRom, Lanczos} {
+		for _, dstSize := range []int{1, 7, 50, 123} {
+			w := precomputeWeights(dstSize, srcSize, filter)
+			got := make([]float64, dstSize*4)
+			want := make([]float64, dstSize*4)
+			resampleRow(got, line, alpha, w.weights, w.starts, w.counts)
+			resampleRowGeneric(want, line, alpha, w.weights, w.starts, w.counts)
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("%d -> %d: got sum %d = %v want %v", srcSize, dstSize, i, got[i], want[i])
+				}
+			}
+		}
+	}
+
+	// Destination pixels without source pixels have zero sums.
+	got := []float64{1, 2, 3, 4, 5, 6, 7, 8}
+	resampleRow(got, line, alpha, []float64{0.5}, []int{3, 4}, []int{0, 1})
+	want := []float64{0, 0, 0, 0, line[16] * alpha[4] * 0.5, line[17] * alpha[4] * 0.5, line[18] * alpha[4] * 0.5, alpha[4] * 0.5}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got sums %v want %v", got, want)
+		}
+	}
+}
+
+func TestResizeFast(t *testing.T) {
+	// A semi-transparent image to check the alpha weighting.
+	alpha := Clone(testdataBranchesPNG)
+	for i := 3; i < len(alpha.Pix); i += 4 {
+		alpha.Pix[i] = uint8(i / 4 % 7 * 40)
+	}
+	testCases := []struct {
+		name          string
+		src           image.Image
+		width, height int
+		fast          bool
+	}{
+		{"half", testdataBranchesPNG, 300, 200, true},
+		{"quarter", testdataBranchesJPG, 150, 100, true},
+		{"aspect ratio", testdataBranchesPNG, 200, 0, true},
+		{"horizontal", testdataBranchesPNG, 100, 400, true},
+		{"vertical", testdataBranchesPNG, 600, 5, true},
+		{"alpha", alpha, 120, 80, true},
+		{"non-integer", testdataBranchesPNG, 250, 150, false},
+		{"upscale", testdataFlowersSmallPNG, 480, 320, false},
+		{"same size", testdataFlowersSmallPNG, 240, 160, false},
+		{"empty", testdataFlowersSmallPNG, 0, 0, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ResizeFast(tc.src, tc.width, tc.height)
+			want := Resize(tc.src, tc.width, tc.height, Box)
+			delta := 0
+			if tc.fast {
+				delta = 1
+			}
+			if !compareNRGBA(got, want, delta) {
+				t.Fatalf("the result differs from Resize by more than %d", delta)
+			}
+		})
+	}
+}
+
+func TestResizeBox(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 4, 2),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x10, 0x20, 0x30, 0xff, 0x30, 0x40, 0x50, 0xff, 0xff, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0x00,
+			0x20, 0x30, 0x40, 0xff, 0x40, 0x50, 0x60, 0xff, 0x00, 0xff, 0x00, 0x00, 0x00, 0x00, 0xff, 0x80,
+		},
+	}
+	want := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 1),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x28, 0x38, 0x48, 0xff, 0x00, 0x00, 0xff, 0x20},
+	}
+	got := defaultEngine.resizeBox(src, 2, 2)
+	if !compareNRGBA(got, want, 0) {
+		t.Fatalf("got result %#v want %#v", got, want)
+	}
+}
+
+func BenchmarkResizeFast(b *testing.B) {
+	for _, format := range []string{"JPEG", "PNG"} {
+		img := testdataBranchesPNG
+		if format == "JPEG" {
+			img = testdataBranchesJPG
+		}
+		b.Run(format, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ResizeFast(img, 150, 100)
+			}
+		})
+	}
+}
+
 func BenchmarkResize(b *testing.B) {
 	for _, dir := range []string{"Down", "Up"} {
 		for _, filter := range []string{"NearestNeighbor", "Linear", "CatmullRom", "Lanczos"} {
@@ -0,0 +1,108 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// PixelMask returns the weight of the pixel of the given color in a selective adjustment,
+// from 0 (not selected) to 1 (fully selected), see BlurWhere. The fractional weights
+// feather the edges of the selection.
+type PixelMask func(c color.NRGBA) float64
+
+// LuminanceRange returns the PixelMask selecting the pixels of the luminance from low to high,
+// both from 0 to 255. The selection fades out over the feather below low and above high.
+//
+// Example:
+//
+//	// The highlights, fading out below 180.
+//	mask := imaging.LuminanceRange(200, 255, 20)
+func LuminanceRange(low, high, feather float64) PixelMask {
+	return func(c color.NRGBA) float64 {
+		y := 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+		return featherRange(low-y, feather) * featherRange(y-high, feather)
+	}
+}
+
+// ColorRange returns the PixelMask selecting the pixels of the colors differing from the
+// color by at most the tolerance, measured with the distance, see ColorDifference.
+// The selection fades out over the feather beyond the tolerance.
+//
+// Example:
+//
+//	// The blue sky, but not the blue shirt.
+//	mask := imaging.ColorRange(color.NRGBA{120, 170, 230, 255}, imaging.DeltaE2000, 12, 8)
+func ColorRange(c color.Color, distance ColorDistance, tolerance, feather float64) PixelMask {
+	return func(p color.NRGBA) float64 {
+		return featherRange(ColorDifference(p, c, distance)-tolerance, feather)
+	}
+}
+
+// featherRange returns the weight of the value d beyond the edge of a range: 1 inside it
+// (d <= 0), fading linearly to 0 at the feather.
+func featherRange(d, feather float64) float64 {
+	switch {
+	case d <= 0:
+		return 1
+	case d >= feather:
+		return 0
+	}
+	return 1 - d/feather
+}
+
+// BlurWhere blurs the pixels of the image selected by the mask using a Gaussian function,
+// see Blur, and keeps the others, e.g. to smooth the noise and the banding of the sky without
+// touching the detail of the foreground. Only the selected pixels are blurred together, so
+// the colors of the unselected ones don't bleed into the blurred areas. The partially
+// selected pixels are blended with their blurred colors by the weight. The alpha channel
+// is kept. Sigma must be positive, a nil mask selects nothing.
+//
+// Example:
+//
+//	dstImage := imaging.BlurWhere(photo, imaging.ColorRange(skyColor, imaging.DeltaE76, 15, 10), 4)
+func BlurWhere(img image.Image, mask PixelMask, sigma float64) *image.NRGBA {
+	if sigma <= 0 || mask == nil {
+		return Clone(img)
+	}
+
+	// The weights of the selection in the alpha of the source, so the blur averages the
+	// selected pixels only.
+	dst := Clone(img)
+	weights := make([]float64, dst.Rect.Dx()*dst.Rect.Dy())
+	selected := newNRGBA(dst.Rect)
+	defer Release(selected)
+	parallel(0, dst.Rect.Dy(), func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < dst.Rect.Dx(); x++ {
+				i := y*dst.Stride + x*4
+				s := dst.Pix[i : i+4 : i+4]
+				w := mask(color.NRGBA{s[0], s[1], s[2], s[3]})
+				w = math.Min(math.Max(w, 0), 1)
+				weights[y*dst.Rect.Dx()+x] = w
+				d := selected.Pix[i : i+4 : i+4]
+				d[0], d[1], d[2], d[3] = s[0], s[1], s[2], clamp(float64(s[3])*w)
+			}
+		}
+	})
+
+	blurred := Blur(selected, sigma)
+	defer Release(blurred)
+	parallel(0, dst.Rect.Dy(), func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < dst.Rect.Dx(); x++ {
+				w := weights[y*dst.Rect.Dx()+x]
+				i := y*dst.Stride + x*4
+				b := blurred.Pix[i : i+4 : i+4]
+				if w == 0 || b[3] == 0 {
+					continue
+				}
+				d := dst.Pix[i : i+3 : i+3]
+				for k := range d {
+					d[k] = clamp(float64(d[k]) + (float64(b[k])-float64(d[k]))*w)
+				}
+			}
+		}
+	})
+	return dst
+}
@@ -0,0 +1,114 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestLuminanceRange(t *testing.T) {
+	mask := LuminanceRange(100, 200, 20)
+	for _, tc := range []struct {
+		c    color.NRGBA
+		want float64
+	}{
+		{color.NRGBA{0, 0, 0, 255}, 0},
+		{color.NRGBA{80, 80, 80, 255}, 0},
+		{color.NRGBA{90, 90, 90, 255}, 0.5},
+		{color.NRGBA{100, 100, 100, 255}, 1},
+		{color.NRGBA{150, 150, 150, 0}, 1},
+		{color.NRGBA{200, 200, 200, 255}, 1},
+		{color.NRGBA{215, 215, 215, 255}, 0.25},
+		{color.NRGBA{255, 255, 255, 255}, 0},
+	} {
+		if got := mask(tc.c); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%v: got %v want %v", tc.c, got, tc.want)
+		}
+	}
+	if got := LuminanceRange(100, 200, 0)(color.NRGBA{99, 99, 99, 255}); got != 0 {
+		t.Errorf("no feather: got %v want 0", got)
+	}
+}
+
+func TestColorRange(t *testing.T) {
+	sky := color.NRGBA{120, 170, 230, 255}
+	mask := ColorRange(sky, DistanceRGB, 10, 10)
+	for _, tc := range []struct {
+		c    color.NRGBA
+		want float64
+	}{
+		{sky, 1},
+		{color.NRGBA{126, 178, 230, 255}, 1},
+		{color.NRGBA{120, 185, 230, 255}, 0.5},
+		{color.NRGBA{120, 170, 250, 255}, 0},
+		{color.NRGBA{200, 60, 50, 255}, 0},
+	} {
+		if got := mask(tc.c); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("%v: got %v want %v", tc.c, got, tc.want)
+		}
+	}
+}
+
+func TestBlurWhere(t *testing.T) {
+	// A noisy light sky over a dark foreground of sharp stripes.
+	src := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.NRGBA{200, 220, 250, 255}
+			if (x+y)%2 == 0 {
+				c = color.NRGBA{180, 200, 240, 255}
+			}
+			if y >= 20 {
+				c = color.NRGBA{0, 0, 0, 255}
+				if x%2 == 0 {
+					c = color.NRGBA{60, 40, 20, 255}
+				}
+			}
+			src.SetNRGBA(x, y, c)
+		}
+	}
+	got := BlurWhere(src, LuminanceRange(150, 255, 0), 2)
+
+	for y := 20; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			if got.NRGBAAt(x, y) != src.NRGBAAt(x, y) {
+				t.Fatalf("foreground pixel (%d, %d): got %v want %v", x, y, got.NRGBAAt(x, y), src.NRGBAAt(x, y))
+			}
+		}
+	}
+	// The sky is smoothed to the mean color, without the dark foreground bleeding in
+	// at the horizon.
+	want := color.NRGBA{190, 210, 245, 255}
+	for _, p := range []image.Point{{10, 5}, {11, 5}, {20, 19}, {21, 19}, {0, 0}} {
+		c := got.NRGBAAt(p.X, p.Y)
+		if absint(int(c.R)-int(want.R)) > 2 || absint(int(c.G)-int(want.G)) > 2 || absint(int(c.B)-int(want.B)) > 2 || c.A != 255 {
+			t.Errorf("sky pixel %v: got %v want about %v", p, c, want)
+		}
+	}
+
+	if !compareNRGBA(BlurWhere(src, LuminanceRange(0, 255, 0), 2), Blur(src, 2), 1) {
+		t.Error("selecting all the pixels differs from Blur")
+	}
+	for _, tc := range []struct {
+		name  string
+		mask  PixelMask
+		sigma float64
+	}{
+		{"nil mask", nil, 2},
+		{"zero sigma", LuminanceRange(0, 255, 0), 0},
+		{"no pixels selected", func(color.NRGBA) float64 { return 0 }, 2},
+	} {
+		if !compareNRGBA(BlurWhere(src, tc.mask, tc.sigma), src, 0) {
+			t.Errorf("%s: the image changed", tc.name)
+		}
+	}
+}
+
+func BenchmarkBlurWhere(b *testing.B) {
+	mask := LuminanceRange(128, 255, 32)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		BlurWhere(testdataBranchesJPG, mask, 3)
+	}
+}
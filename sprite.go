@@ -0,0 +1,265 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"sort"
+)
+
+// ErrSpriteTooLarge means a sprite doesn't fit the maximum size of the sprite sheets,
+// see SpriteMaxSize.
+var ErrSpriteTooLarge = errors.New("imaging: sprite larger than the sprite sheet")
+
+// SpriteAtlas is the result of PackSprites: the sprite sheets and the positions of the
+// sprites in them. It can be stored as JSON for the game engine or to generate the CSS
+// of the sprites, the images of the sheets are left out.
+type SpriteAtlas struct {
+	Sheets  []SpriteSheet `json:"sheets"`
+	Sprites []Sprite      `json:"sprites"`
+}
+
+// SpriteSheet is an image of packed sprites.
+type SpriteSheet struct {
+	Image  *image.NRGBA `json:"-"`
+	Width  int          `json:"width"`
+	Height int          `json:"height"`
+}
+
+// Sprite is the position of a packed image in the sprite sheets, in the order of the
+// images passed to PackSprites.
+type Sprite struct {
+	Name string `json:"name,omitempty"`
+
+	// Sheet is the index of the sheet holding the sprite, -1 for the empty sprites.
+	Sheet int `json:"sheet"`
+
+	// X, Y, Width and Height are the rectangle of the sprite in the sheet.
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// OffsetX and OffsetY are the position of the rectangle in the original image and
+	// SourceWidth and SourceHeight are its size. They differ from the rectangle for the
+	// sprites trimmed with SpriteTrim.
+	OffsetX      int `json:"offsetX"`
+	OffsetY      int `json:"offsetY"`
+	SourceWidth  int `json:"sourceWidth"`
+	SourceHeight int `json:"sourceHeight"`
+}
+
+// Rect returns the rectangle of the sprite in the sheet.
+func (s Sprite) Rect() image.Rectangle {
+	return image.Rect(s.X, s.Y, s.X+s.Width, s.Y+s.Height)
+}
+
+// SpriteOption sets an optional parameter for the PackSprites function.
+type SpriteOption func(*spriteConfig)
+
+type spriteConfig struct {
+	maxW, maxH int
+	padding    int
+	names      []string
+	trim       bool
+	powerOfTwo bool
+}
+
+func newSpriteConfig(opts []SpriteOption) spriteConfig {
+	cfg := spriteConfig{
+		maxW: 2048,
+		maxH: 2048,
+	}
+	for _, option := range opts {
+		option(&cfg)
+	}
+	return cfg
+}
+
+// SpriteMaxSize returns a SpriteOption that sets the maximum width and height of the
+// sprite sheets, the sprites not fitting one sheet start a new one. Default is 2048x2048,
+// the texture size supported by all the GPUs.
+func SpriteMaxSize(width, height int) SpriteOption {
+	return func(c *spriteConfig) {
+		c.maxW = width
+		c.maxH = height
+	}
+}
+
+// SpritePadding returns a SpriteOption that sets the empty space in pixels between
+// the sprites, so the texture filtering doesn't bleed the neighboring sprites into
+// each other. Default is 0.
+func SpritePadding(padding int) SpriteOption {
+	return func(c *spriteConfig) {
+		c.padding = maxint(padding, 0)
+	}
+}
+
+// SpriteNames returns a SpriteOption that sets the names of the sprites in the atlas,
+// e.g. the file names, in the order of the images.
+func SpriteNames(names []string) SpriteOption {
+	return func(c *spriteConfig) {
+		c.names = names
+	}
+}
+
+// SpriteTrim returns a SpriteOption that crops the transparent borders of the images
+// before the packing, see the offsets of Sprite. Default is false.
+func SpriteTrim(trim bool) SpriteOption {
+	return func(c *spriteConfig) {
+		c.trim = trim
+	}
+}
+
+// SpritePowerOfTwo returns a SpriteOption that rounds the sizes of the sheets up to
+// the powers of two, for the older GPUs requiring them. Default is false.
+func SpritePowerOfTwo(powerOfTwo bool) SpriteOption {
+	return func(c *spriteConfig) {
+		c.powerOfTwo = powerOfTwo
+	}
+}
+
+// PackSprites packs the images into the sprite sheets and returns the sheets
+// with the atlas of the sprite positions, for the texture atlases of games and the CSS
+// sprites of web pages. The sprites are placed on the shelves of the sheets, tallest first,
+// each one on the first shelf it fits. The sheets are cropped to the sprites they hold.
+// A sprite larger than the maximum sheet size returns ErrSpriteTooLarge.
+//
+// Example:
+//
+//	atlas, err := imaging.PackSprites(frames, imaging.SpritePadding(2), imaging.SpriteNames(names))
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	for i, sheet := range atlas.Sheets {
+//		imaging.Save(sheet.Image, fmt.Sprintf("sheet%d.png", i))
+//	}
+//	data, err := json.Marshal(atlas)
+func PackSprites(images []image.Image, opts ...SpriteOption) (*SpriteAtlas, error) {
+	cfg := newSpriteConfig(opts)
+	atlas := &SpriteAtlas{Sprites: make([]Sprite, len(images))}
+
+	var order []int
+	for i, img := range images {
+		s := &atlas.Sprites[i]
+		if i < len(cfg.names) {
+			s.Name = cfg.names[i]
+		}
+		s.Sheet = -1
+		b := img.Bounds()
+		s.SourceWidth, s.SourceHeight = b.Dx(), b.Dy()
+		r := image.Rect(0, 0, b.Dx(), b.Dy())
+		if cfg.trim {
+			r = opaqueBounds(img)
+		}
+		if r.Empty() {
+			continue
+		}
+		if r.Dx() > cfg.maxW || r.Dy() > cfg.maxH {
+			return nil, ErrSpriteTooLarge
+		}
+		s.OffsetX, s.OffsetY = r.Min.X, r.Min.Y
+		s.Width, s.Height = r.Dx(), r.Dy()
+		order = append(order, i)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := atlas.Sprites[order[i]], atlas.Sprites[order[j]]
+		if a.Height != b.Height {
+			return a.Height > b.Height
+		}
+		return a.Width > b.Width
+	})
+
+	// The shelves of the sheets: the rows of the sprites of at most the height of the first
+	// one, filled from the left.
+	type shelf struct{ y, h, x int }
+	var sheets [][]shelf
+	place := func(s *Sprite) {
+		for i := range sheets {
+			shelves := sheets[i]
+			for j := range shelves {
+				sh := &shelves[j]
+				if s.Height <= sh.h && sh.x+s.Width <= cfg.maxW {
+					s.Sheet, s.X, s.Y = i, sh.x, sh.y
+					sh.x += s.Width + cfg.padding
+					return
+				}
+			}
+			y := 0
+			if n := len(shelves); n > 0 {
+				y = shelves[n-1].y + shelves[n-1].h + cfg.padding
+			}
+			if y+s.Height <= cfg.maxH {
+				sheets[i] = append(shelves, shelf{y: y, h: s.Height, x: s.Width + cfg.padding})
+				s.Sheet, s.X, s.Y = i, 0, y
+				return
+			}
+		}
+		sheets = append(sheets, []shelf{{y: 0, h: s.Height, x: s.Width + cfg.padding}})
+		s.Sheet, s.X, s.Y = len(sheets)-1, 0, 0
+	}
+	for _, i := range order {
+		place(&atlas.Sprites[i])
+	}
+
+	atlas.Sheets = make([]SpriteSheet, len(sheets))
+	for _, s := range atlas.Sprites {
+		if s.Sheet >= 0 {
+			sheet := &atlas.Sheets[s.Sheet]
+			sheet.Width = maxint(sheet.Width, s.X+s.Width)
+			sheet.Height = maxint(sheet.Height, s.Y+s.Height)
+		}
+	}
+	for i := range atlas.Sheets {
+		sheet := &atlas.Sheets[i]
+		if cfg.powerOfTwo {
+			sheet.Width, sheet.Height = nextPowerOfTwo(sheet.Width), nextPowerOfTwo(sheet.Height)
+		}
+		sheet.Image = newNRGBA(image.Rect(0, 0, sheet.Width, sheet.Height))
+	}
+	for i, s := range atlas.Sprites {
+		if s.Sheet < 0 {
+			continue
+		}
+		src := newScanner(images[i])
+		dst := atlas.Sheets[s.Sheet].Image
+		parallel(0, s.Height, func(ys <-chan int) {
+			for y := range ys {
+				j := (s.Y+y)*dst.Stride + s.X*4
+				src.scan(s.OffsetX, s.OffsetY+y, s.OffsetX+s.Width, s.OffsetY+y+1, dst.Pix[j:j+s.Width*4])
+			}
+		})
+	}
+	return atlas, nil
+}
+
+// opaqueBounds returns the smallest rectangle containing the pixels of the image that are
+// not fully transparent, relative to the image bounds.
+func opaqueBounds(img image.Image) image.Rectangle {
+	src := newScanner(img)
+	r := image.Rectangle{Min: image.Pt(src.w, src.h)}
+	scanLine := make([]uint8, src.w*4)
+	for y := 0; y < src.h; y++ {
+		src.scan(0, y, src.w, y+1, scanLine)
+		for x := 0; x < src.w; x++ {
+			if scanLine[x*4+3] != 0 {
+				r.Min.X = minint(r.Min.X, x)
+				r.Max.X = maxint(r.Max.X, x+1)
+				r.Min.Y = minint(r.Min.Y, y)
+				r.Max.Y = y + 1
+			}
+		}
+	}
+	if r.Empty() {
+		return image.Rectangle{}
+	}
+	return r
+}
+
+// nextPowerOfTwo returns the smallest power of two not less than n.
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p *= 2
+	}
+	return p
+}
@@ -0,0 +1,119 @@
+package imaging
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPackSprites(t *testing.T) {
+	images := []image.Image{
+		New(30, 20, color.NRGBA{255, 0, 0, 255}),
+		New(40, 40, color.NRGBA{0, 255, 0, 255}),
+		&image.NRGBA{},
+		New(20, 20, color.NRGBA{0, 0, 255, 255}),
+		New(50, 10, color.NRGBA{255, 255, 0, 255}),
+	}
+	atlas, err := PackSprites(images, SpriteMaxSize(64, 64), SpritePadding(2), SpriteNames([]string{"red", "green"}))
+	if err != nil {
+		t.Fatalf("PackSprites: %v", err)
+	}
+	if len(atlas.Sprites) != len(images) {
+		t.Fatalf("got %d sprites want %d", len(atlas.Sprites), len(images))
+	}
+	if s := atlas.Sprites[2]; s.Sheet != -1 || !s.Rect().Empty() {
+		t.Errorf("empty image: got %+v want sheet -1", s)
+	}
+	if atlas.Sprites[0].Name != "red" || atlas.Sprites[1].Name != "green" || atlas.Sprites[3].Name != "" {
+		t.Errorf("got names %q, %q and %q", atlas.Sprites[0].Name, atlas.Sprites[1].Name, atlas.Sprites[3].Name)
+	}
+
+	for i, s := range atlas.Sprites {
+		if s.Sheet < 0 {
+			continue
+		}
+		if s.Sheet >= len(atlas.Sheets) {
+			t.Fatalf("sprite %d: got sheet %d of %d", i, s.Sheet, len(atlas.Sheets))
+		}
+		sheet := atlas.Sheets[s.Sheet]
+		if sheet.Width > 64 || sheet.Height > 64 || sheet.Image.Bounds() != image.Rect(0, 0, sheet.Width, sheet.Height) {
+			t.Fatalf("sprite %d: got sheet %dx%d with bounds %v", i, sheet.Width, sheet.Height, sheet.Image.Bounds())
+		}
+		if s.Width != images[i].Bounds().Dx() || s.Height != images[i].Bounds().Dy() || s.SourceWidth != s.Width || s.OffsetX != 0 {
+			t.Errorf("sprite %d: got %+v", i, s)
+		}
+		if !s.Rect().In(sheet.Image.Bounds()) {
+			t.Fatalf("sprite %d: got %v outside the sheet", i, s.Rect())
+		}
+		if !compareNRGBA(Crop(sheet.Image, s.Rect()), Clone(images[i]), 0) {
+			t.Errorf("sprite %d: the sheet pixels differ from the image", i)
+		}
+		// The sprites don't overlap with their padding.
+		for j, o := range atlas.Sprites[:i] {
+			if o.Sheet == s.Sheet && o.Rect().Inset(-2).Overlaps(s.Rect()) {
+				t.Errorf("sprites %d and %d overlap: %v and %v", i, j, s.Rect(), o.Rect())
+			}
+		}
+	}
+	// The 40x40 sprite takes most of the first 64x64 sheet.
+	if len(atlas.Sheets) != 2 {
+		t.Errorf("got %d sheets want 2", len(atlas.Sheets))
+	}
+
+	if _, err := PackSprites(images, SpriteMaxSize(45, 45)); !errors.Is(err, ErrSpriteTooLarge) {
+		t.Errorf("got error %v want ErrSpriteTooLarge", err)
+	}
+}
+
+func TestPackSpritesTrim(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(10, 10, 30, 25))
+	img.SetNRGBA(14, 13, color.NRGBA{255, 0, 0, 255})
+	img.SetNRGBA(20, 18, color.NRGBA{0, 0, 255, 128})
+	atlas, err := PackSprites([]image.Image{img, image.NewNRGBA(image.Rect(0, 0, 5, 5))}, SpriteTrim(true), SpritePowerOfTwo(true))
+	if err != nil {
+		t.Fatalf("PackSprites: %v", err)
+	}
+	s := atlas.Sprites[0]
+	want := Sprite{Sheet: 0, Width: 7, Height: 6, OffsetX: 4, OffsetY: 3, SourceWidth: 20, SourceHeight: 15}
+	if s != want {
+		t.Fatalf("got %+v want %+v", s, want)
+	}
+	if s := atlas.Sprites[1]; s.Sheet != -1 {
+		t.Errorf("transparent image: got sheet %d want -1", s.Sheet)
+	}
+	if len(atlas.Sheets) != 1 || atlas.Sheets[0].Width != 8 || atlas.Sheets[0].Height != 8 {
+		t.Fatalf("got sheets %+v want one 8x8 sheet", atlas.Sheets)
+	}
+	sheet := atlas.Sheets[0].Image
+	if c := sheet.NRGBAAt(0, 0); c != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("got top-left %v want red", c)
+	}
+	if c := sheet.NRGBAAt(6, 5); c != (color.NRGBA{0, 0, 255, 128}) {
+		t.Errorf("got bottom-right %v want blue", c)
+	}
+
+	data, err := json.Marshal(atlas)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	var got SpriteAtlas
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	if len(got.Sheets) != 1 || got.Sheets[0].Width != 8 || got.Sprites[0] != atlas.Sprites[0] {
+		t.Errorf("got %s", data)
+	}
+}
+
+func BenchmarkPackSprites(b *testing.B) {
+	images := make([]image.Image, 100)
+	for i := range images {
+		images[i] = New(16+i%7*8, 16+i%5*8, color.White)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		PackSprites(images, SpriteMaxSize(256, 256), SpritePadding(1))
+	}
+}
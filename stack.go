@@ -0,0 +1,100 @@
+package imaging
+
+import (
+	"image"
+)
+
+// StackMode specifies how Stack combines the frames.
+type StackMode int
+
+// Frame stacking modes.
+const (
+	// StackAverage averages the frames. It smooths moving water and clouds
+	// and reduces noise like a long exposure with a neutral density filter.
+	StackAverage StackMode = iota
+
+	// StackLighten takes the maximum of each color channel over the frames (lighten blending).
+	// It accumulates moving lights into light trails and star trails.
+	StackLighten
+
+	// StackDarken takes the minimum of each color channel over the frames (darken blending).
+	StackDarken
+)
+
+// Stack combines a sequence of frames, e.g. timelapse shots, into a single image
+// simulating a long exposure. The result has the size of the first frame, the other frames
+// are placed at its top-left corner and their parts outside of it are ignored.
+// Handheld frames should be registered with Align first.
+//
+// Example:
+//
+//	trails := imaging.Stack(frames, imaging.StackLighten)
+func Stack(frames []image.Image, mode StackMode) *image.NRGBA {
+	if len(frames) == 0 {
+		return &image.NRGBA{}
+	}
+	scanners := make([]*scanner, len(frames))
+	for i, f := range frames {
+		scanners[i] = newScanner(f)
+	}
+	w, h := scanners[0].w, scanners[0].h
+	dst := newNRGBA(image.Rect(0, 0, w, h))
+
+	parallel(0, h, func(ys <-chan int) {
+		scanLine := make([]uint8, w*4)
+		sums := make([]float64, w*4)
+		counts := make([]int, w)
+		for y := range ys {
+			row := dst.Pix[y*dst.Stride : y*dst.Stride+w*4]
+			for i := range sums {
+				sums[i] = 0
+			}
+			for i := range counts {
+				counts[i] = 0
+			}
+			for _, src := range scanners {
+				if y >= src.h {
+					continue
+				}
+				n := minint(w, src.w)
+				src.scan(0, y, n, y+1, scanLine)
+				for x := 0; x < n; x++ {
+					s := scanLine[x*4 : x*4+4 : x*4+4]
+					d := row[x*4 : x*4+4 : x*4+4]
+					first := counts[x] == 0
+					counts[x]++
+					switch mode {
+					case StackLighten, StackDarken:
+						for c := 0; c < 4; c++ {
+							if first || (mode == StackLighten) == (s[c] > d[c]) {
+								d[c] = s[c]
+							}
+						}
+					default:
+						// Weight the colors by alpha.
+						a := float64(s[3])
+						sums[x*4+0] += float64(s[0]) * a
+						sums[x*4+1] += float64(s[1]) * a
+						sums[x*4+2] += float64(s[2]) * a
+						sums[x*4+3] += a
+					}
+				}
+			}
+			if mode == StackLighten || mode == StackDarken {
+				continue
+			}
+			for x := 0; x < w; x++ {
+				a := sums[x*4+3]
+				if a == 0 {
+					continue
+				}
+				d := row[x*4 : x*4+4 : x*4+4]
+				d[0] = clamp(sums[x*4+0] / a)
+				d[1] = clamp(sums[x*4+1] / a)
+				d[2] = clamp(sums[x*4+2] / a)
+				d[3] = clamp(a / float64(counts[x]))
+			}
+		}
+	})
+	return dst
+}
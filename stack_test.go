@@ -0,0 +1,111 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+func TestStack(t *testing.T) {
+	frame1 := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 1),
+		Stride: 2 * 4,
+		Pix:    []uint8{0x10, 0x80, 0x00, 0xff, 0x00, 0x00, 0x00, 0x00},
+	}
+	frame2 := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 0),
+		Stride: 3 * 4,
+		Pix:    []uint8{0x30, 0x20, 0xff, 0xff, 0xff, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0xff},
+	}
+	testCases := []struct {
+		name   string
+		frames []image.Image
+		mode   StackMode
+		want   *image.NRGBA
+	}{
+		{
+			"average",
+			[]image.Image{frame1, frame2},
+			StackAverage,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix:    []uint8{0x20, 0x50, 0x80, 0xff, 0xff, 0x00, 0x00, 0x80},
+			},
+		},
+		{
+			"lighten",
+			[]image.Image{frame1, frame2},
+			StackLighten,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix:    []uint8{0x30, 0x80, 0xff, 0xff, 0xff, 0x00, 0x00, 0xff},
+			},
+		},
+		{
+			"darken",
+			[]image.Image{frame1, frame2},
+			StackDarken,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix:    []uint8{0x10, 0x20, 0x00, 0xff, 0x00, 0x00, 0x00, 0x00},
+			},
+		},
+		{
+			"smaller frame",
+			[]image.Image{frame1, Crop(frame2, image.Rect(0, -1, 1, 0))},
+			StackAverage,
+			&image.NRGBA{
+				Rect:   image.Rect(0, 0, 2, 1),
+				Stride: 2 * 4,
+				Pix:    []uint8{0x88, 0x40, 0x00, 0xff, 0x00, 0x00, 0x00, 0x00},
+			},
+		},
+		{
+			"single frame",
+			[]image.Image{frame1},
+			StackLighten,
+			frame1,
+		},
+		{
+			"no frames",
+			nil,
+			StackAverage,
+			&image.NRGBA{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Stack(tc.frames, tc.mode)
+			if !compareNRGBA(got, tc.want, 0) {
+				t.Fatalf("got result %#v want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStackNoise(t *testing.T) {
+	// Averaging shifted copies of a noisy image smooths it.
+	var frames []image.Image
+	for i := 0; i < 8; i++ {
+		frames = append(frames, Crop(testdataBranchesPNG, image.Rect(i, 0, 300+i, 200)))
+	}
+	got := Stack(frames, StackAverage)
+	want := Clone(frames[0])
+	if d := meanDiffInside(got, want, got.Bounds()); d < 1 {
+		t.Fatalf("mean difference %.2f, the frames are not averaged", d)
+	}
+	lighter := Stack(frames, StackLighten)
+	if Histogram(lighter)[0] > Histogram(frames[0])[0] {
+		t.Fatal("lighten stacking made the image darker")
+	}
+}
+
+func BenchmarkStack(b *testing.B) {
+	frames := []image.Image{testdataBranchesJPG, testdataBranchesPNG, testdataBranchesJPG}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Stack(frames, StackAverage)
+	}
+}
@@ -0,0 +1,249 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+)
+
+// ErrDataTooLarge means the data to embed exceeds the capacity of the image, see StegoCapacity.
+var ErrDataTooLarge = errors.New("imaging: data exceeds the image capacity")
+
+// ErrNoData means the image contains no data embedded by EmbedData with the same options,
+// or the data is damaged, e.g. by a lossy compression.
+var ErrNoData = errors.New("imaging: no embedded data found")
+
+// stegoHeaderSize is the size of the header preceding the embedded data:
+// the data length and its CRC-32 checksum.
+const stegoHeaderSize = 8
+
+// StegoOption sets an optional parameter for the EmbedData, ExtractData and StegoCapacity functions.
+type StegoOption func(*stegoConfig)
+
+type stegoConfig struct {
+	bits    int
+	encrypt func(data []byte) ([]byte, error)
+	decrypt func(data []byte) ([]byte, error)
+}
+
+func newStegoConfig(opts []StegoOption) stegoConfig {
+	cfg := stegoConfig{bits: 1}
+	for _, option := range opts {
+		option(&cfg)
+	}
+	return cfg
+}
+
+// StegoBits returns a StegoOption that sets the number of the least significant bits of each
+// channel that carry the data, from 1 to 4. More bits multiply the capacity, but the changes
+// of the colors become visible in the smooth areas from about 3 bits. The extraction must
+// use the same number of bits as the embedding. Default is 1.
+func StegoBits(n int) StegoOption {
+	return func(c *stegoConfig) {
+		if n >= 1 && n <= 4 {
+			c.bits = n
+		}
+	}
+}
+
+// StegoCipher returns a StegoOption that sets the encryption hook: EmbedData embeds the data
+// returned by encrypt, ExtractData returns the data returned by decrypt, e.g. the functions
+// sealing and opening the data with an AEAD cipher of the crypto/cipher package. Their errors
+// are returned as is. The nil functions leave the data unchanged.
+//
+// Example:
+//
+//	opt := imaging.StegoCipher(
+//		func(data []byte) ([]byte, error) {
+//			nonce := make([]byte, aead.NonceSize())
+//			if _, err := rand.Read(nonce); err != nil {
+//				return nil, err
+//			}
+//			return aead.Seal(nonce, nonce, data, nil), nil
+//		},
+//		func(data []byte) ([]byte, error) {
+//			if len(data) < aead.NonceSize() {
+//				return nil, imaging.ErrNoData
+//			}
+//			return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+//		},
+//	)
+func StegoCipher(encrypt, decrypt func(data []byte) ([]byte, error)) StegoOption {
+	return func(c *stegoConfig) {
+		c.encrypt = encrypt
+		c.decrypt = decrypt
+	}
+}
+
+// StegoCapacity returns the maximum size in bytes of the data EmbedData can embed in
+// the image with the options. The encryption hook of StegoCipher may add its own overhead.
+//
+// Example:
+//
+//	if len(tag) <= imaging.StegoCapacity(img) {
+//		tagged, _ := imaging.EmbedData(img, tag)
+//		// ...
+//	}
+func StegoCapacity(img image.Image, opts ...StegoOption) int {
+	cfg := newStegoConfig(opts)
+	return maxint(stegoSize(toNRGBA(img), cfg.bits)-stegoHeaderSize, 0)
+}
+
+// stegoSize returns the number of the bytes the image can store with the given bits
+// per channel, including the header.
+func stegoSize(img *image.NRGBA, bits int) int {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	opaque := 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if img.Pix[y*img.Stride+x*4+3] == 0xff {
+				opaque++
+			}
+		}
+	}
+	return opaque * 3 * bits / 8
+}
+
+// EmbedData hides the data in the image and returns the image with the data embedded.
+// The data is stored in the least significant bits of the red, green and blue channels
+// of the opaque pixels in the row-major order, so the change of the colors is invisible,
+// and is preceded by its length and checksum. The image must be saved in a lossless format,
+// such as PNG, to keep the data, and it's lost by any adjustment of the colors, resizing
+// or cropping. The data is only hidden, to keep it private use StegoCipher.
+//
+// Example:
+//
+//	tagged, err := imaging.EmbedData(srcImage, []byte("asset-id:1234"))
+//	if err != nil {
+//		log.Fatalf("failed to embed the tag: %v", err)
+//	}
+//	err = imaging.Save(tagged, "tagged.png")
+func EmbedData(img image.Image, data []byte, opts ...StegoOption) (*image.NRGBA, error) {
+	cfg := newStegoConfig(opts)
+	if cfg.encrypt != nil {
+		var err error
+		if data, err = cfg.encrypt(data); err != nil {
+			return nil, err
+		}
+	}
+
+	dst := Clone(img)
+	if stegoHeaderSize+len(data) > stegoSize(dst, cfg.bits) {
+		return nil, ErrDataTooLarge
+	}
+
+	payload := make([]byte, stegoHeaderSize+len(data))
+	binary.BigEndian.PutUint32(payload, uint32(len(data)))
+	binary.BigEndian.PutUint32(payload[4:], crc32.ChecksumIEEE(data))
+	copy(payload[stegoHeaderSize:], data)
+
+	cur := stegoCursor{img: dst, bits: cfg.bits}
+	mask := uint8(1)<<cfg.bits - 1
+	// The bits of the payload from the most significant one, the last channel
+	// is padded with zeros.
+	var acc, n uint
+	for _, b := range payload {
+		acc = acc<<8 | uint(b)
+		n += 8
+		for n >= uint(cfg.bits) {
+			n -= uint(cfg.bits)
+			cur.write(uint8(acc>>n) & mask)
+		}
+	}
+	if n > 0 {
+		cur.write(uint8(acc<<(uint(cfg.bits)-n)) & mask)
+	}
+	return dst, nil
+}
+
+// ExtractData returns the data embedded in the image by EmbedData with the same options.
+// It returns ErrNoData if the image contains no data or its checksum doesn't match.
+//
+// Example:
+//
+//	img, _ := imaging.Open("tagged.png")
+//	tag, err := imaging.ExtractData(img)
+//	if err == imaging.ErrNoData {
+//		// The image isn't tagged.
+//	}
+func ExtractData(img image.Image, opts ...StegoOption) ([]byte, error) {
+	cfg := newStegoConfig(opts)
+	src := toNRGBA(img)
+	size := stegoSize(src, cfg.bits)
+	if size < stegoHeaderSize {
+		return nil, ErrNoData
+	}
+
+	cur := stegoCursor{img: src, bits: cfg.bits}
+	var acc, n uint
+	readByte := func() byte {
+		for n < 8 {
+			acc = acc<<uint(cfg.bits) | uint(cur.read())
+			n += uint(cfg.bits)
+		}
+		n -= 8
+		return byte(acc >> n)
+	}
+	read := func(b []byte) {
+		for i := range b {
+			b[i] = readByte()
+		}
+	}
+
+	header := make([]byte, stegoHeaderSize)
+	read(header)
+	length := binary.BigEndian.Uint32(header)
+	if uint64(length) > uint64(size-stegoHeaderSize) {
+		return nil, ErrNoData
+	}
+	data := make([]byte, length)
+	read(data)
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, ErrNoData
+	}
+
+	if cfg.decrypt != nil {
+		return cfg.decrypt(data)
+	}
+	return data, nil
+}
+
+// stegoCursor iterates over the red, green and blue channels of the opaque pixels
+// of the image in the row-major order.
+type stegoCursor struct {
+	img  *image.NRGBA
+	bits int
+	x, y int
+	c    int
+}
+
+// next returns the index in the pixel slice of the next channel, or -1 at the end of the image.
+func (cur *stegoCursor) next() int {
+	w, h := cur.img.Rect.Dx(), cur.img.Rect.Dy()
+	for cur.y < h {
+		i := cur.y*cur.img.Stride + cur.x*4
+		if cur.c < 3 && cur.img.Pix[i+3] == 0xff {
+			cur.c++
+			return i + cur.c - 1
+		}
+		cur.c = 0
+		if cur.x++; cur.x == w {
+			cur.x = 0
+			cur.y++
+		}
+	}
+	return -1
+}
+
+// write stores the value in the low bits of the next channel.
+func (cur *stegoCursor) write(v uint8) {
+	i := cur.next()
+	p := &cur.img.Pix[i]
+	*p = *p&^(uint8(1)<<cur.bits-1) | v
+}
+
+// read returns the value of the low bits of the next channel.
+func (cur *stegoCursor) read() uint8 {
+	return cur.img.Pix[cur.next()] & (uint8(1)<<cur.bits - 1)
+}
@@ -0,0 +1,154 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestEmbedData(t *testing.T) {
+	src := testdataFlowersSmallPNG
+	data := []byte("asset-id:1234, the quick brown fox jumps over the lazy dog")
+	for bits := 1; bits <= 4; bits++ {
+		opt := StegoBits(bits)
+		got, err := EmbedData(src, data, opt)
+		if err != nil {
+			t.Fatalf("bits %d: %v", bits, err)
+		}
+		// Only the low bits change.
+		orig := toNRGBA(src)
+		for i := range got.Pix {
+			if d := absint(int(got.Pix[i]) - int(orig.Pix[i])); d >= 1<<bits || i%4 == 3 && d != 0 {
+				t.Fatalf("bits %d: the byte %d changed by %d", bits, i, d)
+			}
+		}
+
+		// The data survives the lossless encoding.
+		b, err := EncodeBytes(got, PNG)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := Decode(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		extracted, err := ExtractData(decoded, opt)
+		if err != nil {
+			t.Fatalf("bits %d: %v", bits, err)
+		}
+		if !bytes.Equal(extracted, data) {
+			t.Fatalf("bits %d: got %q want %q", bits, extracted, data)
+		}
+	}
+
+	// The image without the data, or the data read with the other options.
+	if _, err := ExtractData(src); err != ErrNoData {
+		t.Fatalf("got error %v want ErrNoData", err)
+	}
+	tagged, _ := EmbedData(src, data, StegoBits(2))
+	if _, err := ExtractData(tagged); err != ErrNoData {
+		t.Fatalf("got error %v want ErrNoData", err)
+	}
+	// The damaged data.
+	tagged.Pix[100] ^= 1
+	if _, err := ExtractData(tagged, StegoBits(2)); err != ErrNoData {
+		t.Fatalf("got error %v want ErrNoData", err)
+	}
+
+	// The empty data.
+	tagged, _ = EmbedData(src, nil)
+	if got, err := ExtractData(tagged); err != nil || len(got) != 0 {
+		t.Fatalf("got %q, %v want no data", got, err)
+	}
+}
+
+func TestStegoCapacity(t *testing.T) {
+	// The transparent and translucent pixels don't carry the data.
+	src := New(10, 4, color.NRGBA{0x10, 0x20, 0x30, 0xff})
+	for x := 0; x < 10; x++ {
+		src.SetNRGBA(x, 0, color.NRGBA{0x10, 0x20, 0x30, 0x00})
+		src.SetNRGBA(x, 1, color.NRGBA{0x10, 0x20, 0x30, 0xfe})
+	}
+	// 20 opaque pixels carry 60 bits, 7 bytes, less than the header.
+	if got := StegoCapacity(src); got != 0 {
+		t.Fatalf("got capacity %d want 0", got)
+	}
+	if _, err := EmbedData(src, nil); err != ErrDataTooLarge {
+		t.Fatalf("got error %v want ErrDataTooLarge", err)
+	}
+	if _, err := ExtractData(src); err != ErrNoData {
+		t.Fatalf("got error %v want ErrNoData", err)
+	}
+
+	// 20 opaque pixels carry 240 bits with 4 bits per channel, 30 bytes with the header.
+	opt := StegoBits(4)
+	if got := StegoCapacity(src, opt); got != 22 {
+		t.Fatalf("got capacity %d want 22", got)
+	}
+	data := bytes.Repeat([]byte{0xa5}, 22)
+	got, err := EmbedData(src, data, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2*src.Stride; i++ {
+		if got.Pix[i] != src.Pix[i] {
+			t.Fatalf("the non-opaque pixel byte %d is changed", i)
+		}
+	}
+	if extracted, err := ExtractData(got, opt); err != nil || !bytes.Equal(extracted, data) {
+		t.Fatalf("got %v, %v", extracted, err)
+	}
+	if _, err := EmbedData(src, append(data, 0), opt); err != ErrDataTooLarge {
+		t.Fatalf("got error %v want ErrDataTooLarge", err)
+	}
+
+	// The invalid options are ignored.
+	if got := StegoCapacity(src, StegoBits(5)); got != 0 {
+		t.Fatalf("got capacity %d want 0", got)
+	}
+	if got := StegoCapacity(image.NewNRGBA(image.Rect(0, 0, 0, 0))); got != 0 {
+		t.Fatalf("got capacity %d want 0", got)
+	}
+}
+
+func TestStegoCipher(t *testing.T) {
+	xor := func(data []byte) ([]byte, error) {
+		out := make([]byte, len(data))
+		for i, b := range data {
+			out[i] = b ^ 0x5a
+		}
+		return out, nil
+	}
+	opt := StegoCipher(xor, xor)
+	data := []byte("secret")
+	tagged, err := EmbedData(testdataFlowersSmallPNG, data, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ExtractData(tagged, opt); err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("got %q, %v want %q", got, err, data)
+	}
+	// Without the hook the encrypted data is returned.
+	if got, _ := ExtractData(tagged); bytes.Equal(got, data) {
+		t.Fatal("the data isn't encrypted")
+	}
+
+	errCipher := errors.New("cipher error")
+	fail := func([]byte) ([]byte, error) { return nil, errCipher }
+	if _, err := EmbedData(testdataFlowersSmallPNG, data, StegoCipher(fail, nil)); err != errCipher {
+		t.Fatalf("got error %v want the cipher error", err)
+	}
+	if _, err := ExtractData(tagged, StegoCipher(nil, fail)); err != errCipher {
+		t.Fatalf("got error %v want the cipher error", err)
+	}
+}
+
+func BenchmarkEmbedData(b *testing.B) {
+	data := bytes.Repeat([]byte("imaging"), 1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		EmbedData(testdataBranchesJPG, data)
+	}
+}
@@ -0,0 +1,94 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+)
+
+// ErrNoOverlap means the images can't be stitched because no common area is found.
+var ErrNoOverlap = errors.New("imaging: images don't overlap")
+
+// Stitch combines two overlapping photos, e.g. neighbouring shots of a horizontal panorama,
+// into a single image. The second image is aligned to the first one by matching corners
+// (allowing a slight rotation and scale change) and the seam is hidden by feathering:
+// in the overlapping area each image is weighted by the distance to its edge.
+// The areas of the result not covered by any image are transparent.
+// ErrNoOverlap is returned if the images can't be aligned.
+//
+// Example:
+//
+//	panorama, err := imaging.Stitch(leftImage, rightImage)
+func Stitch(a, b image.Image) (*image.NRGBA, error) {
+	t, ok := estimateSimilarity(a, b)
+	if !ok {
+		return nil, ErrNoOverlap
+	}
+	// Reject implausible transforms that would make a huge canvas.
+	if scale := math.Hypot(t.a, t.b); scale < 0.5 || scale > 2 {
+		return nil, ErrNoOverlap
+	}
+
+	srcA := toNRGBA(a)
+	srcB := toNRGBA(b)
+	wa, ha := srcA.Bounds().Dx(), srcA.Bounds().Dy()
+	wb, hb := float64(srcB.Bounds().Dx()), float64(srcB.Bounds().Dy())
+
+	// The canvas covers the first image and the corners of the second one mapped onto it.
+	inv := t.invert()
+	minX, minY, maxX, maxY := 0.0, 0.0, float64(wa), float64(ha)
+	for _, c := range [4][2]float64{{0, 0}, {wb, 0}, {0, hb}, {wb, hb}} {
+		x, y := inv.apply(c[0]-0.5, c[1]-0.5)
+		x, y = x+0.5, y+0.5
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+	// Partially covered border pixels are dropped to tolerate alignment errors.
+	ox, oy := int(math.Round(minX)), int(math.Round(minY))
+	w, h := int(math.Round(maxX))-ox, int(math.Round(maxY))-oy
+
+	dst := newNRGBA(image.Rect(0, 0, w, h))
+	parallel(0, h, func(ys <-chan int) {
+		sample := newNRGBA(image.Rect(0, 0, 1, 1))
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				ax, ay := x+ox, y+oy
+				var ca color.NRGBA
+				var weightA float64
+				if ax >= 0 && ay >= 0 && ax < wa && ay < ha {
+					ca = srcA.NRGBAAt(ax, ay)
+					weightA = edgeDistance(float64(ax), float64(ay), float64(wa), float64(ha))
+				}
+
+				bx, by := t.apply(float64(ax), float64(ay))
+				interpolatePoint(sample, 0, 0, srcB, bx, by, color.NRGBA{})
+				cb := sample.NRGBAAt(0, 0)
+				weightB := edgeDistance(bx, by, wb, hb)
+
+				i := y*dst.Stride + x*4
+				d := dst.Pix[i : i+4 : i+4]
+				wA := weightA * float64(ca.A)
+				wB := weightB * float64(cb.A)
+				if wA+wB == 0 {
+					continue
+				}
+				d[0] = clamp((wA*float64(ca.R) + wB*float64(cb.R)) / (wA + wB))
+				d[1] = clamp((wA*float64(ca.G) + wB*float64(cb.G)) / (wA + wB))
+				d[2] = clamp((wA*float64(ca.B) + wB*float64(cb.B)) / (wA + wB))
+				d[3] = clamp((wA + wB) / (weightA + weightB))
+			}
+		}
+	})
+	return dst, nil
+}
+
+// edgeDistance returns the distance of the pixel (x, y) to the nearest edge of a w x h image
+// plus one, or 0 if the pixel is outside the image.
+func edgeDistance(x, y, w, h float64) float64 {
+	d := math.Min(math.Min(x, w-1-x), math.Min(y, h-1-y))
+	if d <= -0.5 {
+		return 0
+	}
+	return math.Max(d, 0) + 1
+}
@@ -0,0 +1,71 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestStitch(t *testing.T) {
+	left := Crop(testdataBranchesPNG, image.Rect(0, 0, 360, 400))
+	right := Crop(testdataBranchesPNG, image.Rect(240, 0, 600, 400))
+
+	got, err := Stitch(left, right)
+	if err != nil {
+		t.Fatalf("Stitch: %v", err)
+	}
+	if got.Bounds() != testdataBranchesPNG.Bounds() {
+		t.Fatalf("got bounds %v want %v", got.Bounds(), testdataBranchesPNG.Bounds())
+	}
+	if d := meanDiffInside(got, Clone(testdataBranchesPNG), got.Bounds()); d > 1 {
+		t.Fatalf("mean difference %.2f", d)
+	}
+}
+
+func TestStitchShifted(t *testing.T) {
+	left := Crop(testdataBranchesPNG, image.Rect(0, 20, 360, 380))
+	right := Crop(testdataBranchesPNG, image.Rect(230, 0, 600, 350))
+
+	got, err := Stitch(left, right)
+	if err != nil {
+		t.Fatalf("Stitch: %v", err)
+	}
+	if got.Bounds() != image.Rect(0, 0, 600, 380) {
+		t.Fatalf("got bounds %v want 600x380", got.Bounds())
+	}
+	want := Crop(testdataBranchesPNG, image.Rect(0, 0, 600, 380))
+	if d := meanDiffInside(got, want, got.Bounds()); d > 1 {
+		t.Fatalf("mean difference %.2f", d)
+	}
+	// The corners covered by neither image are transparent.
+	for _, p := range []image.Point{{5, 5}, {595, 365}} {
+		if c := got.NRGBAAt(p.X, p.Y); c.A != 0 {
+			t.Fatalf("got %v at %v want transparent", c, p)
+		}
+	}
+}
+
+func TestStitchNoOverlap(t *testing.T) {
+	flat := New(100, 100, color.Gray{0x80})
+	if _, err := Stitch(testdataBranchesPNG, flat); err != ErrNoOverlap {
+		t.Fatalf("got error %v want %v", err, ErrNoOverlap)
+	}
+}
+
+func TestEdgeDistance(t *testing.T) {
+	testCases := []struct {
+		x, y, want float64
+	}{
+		{0, 0, 1},
+		{2, 5, 3},
+		{9, 5, 1},
+		{-0.2, 5, 1},
+		{-0.6, 5, 0},
+		{5, 10, 0},
+	}
+	for _, tc := range testCases {
+		if got := edgeDistance(tc.x, tc.y, 10, 10); got != tc.want {
+			t.Errorf("edgeDistance(%v, %v): got %v want %v", tc.x, tc.y, got, tc.want)
+		}
+	}
+}
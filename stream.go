@@ -0,0 +1,120 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"image"
+	"io"
+)
+
+// EncodedImage is an image encoded on demand while it's read or written, so it can be
+// streamed to a client taking an io.Reader, such as an object storage upload, without
+// buffering all the encoded data. It implements io.WriterTo as well, so io.Copy encodes
+// the image directly to the destination writer.
+//
+// When the image is read, it's encoded in a goroutine. Closing the EncodedImage before
+// the end of the data stops the encoding, so it must be closed unless it's read to the end
+// or written with WriteTo. An EncodedImage is not safe for concurrent use.
+//
+// Example:
+//
+//	enc := imaging.NewEncodedImage(img, imaging.JPEG, imaging.JPEGQuality(85))
+//	defer enc.Close()
+//	_, err := bucket.Upload(ctx, "photo.jpg", enc)
+type EncodedImage struct {
+	img    image.Image
+	format Format
+	opts   []EncodeOption
+	pr     *io.PipeReader
+}
+
+// NewEncodedImage returns the image to be encoded in the specified format with the options,
+// see Encode. The image must not be modified while it's encoded.
+func NewEncodedImage(img image.Image, format Format, opts ...EncodeOption) *EncodedImage {
+	return &EncodedImage{img: img, format: format, opts: opts}
+}
+
+// Read reads the encoded data, starting the encoding on the first call.
+// The encoding error is returned by Read.
+func (e *EncodedImage) Read(p []byte) (int, error) {
+	if e.pr == nil {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(Encode(pw, e.img, e.format, e.opts...))
+		}()
+		e.pr = pr
+	}
+	return e.pr.Read(p)
+}
+
+// WriteTo writes the encoded data to w and returns the number of bytes written.
+// If nothing was read yet, the image is encoded directly to w, otherwise the rest
+// of the data is copied.
+func (e *EncodedImage) WriteTo(w io.Writer) (int64, error) {
+	if e.pr != nil {
+		return io.Copy(w, e.pr)
+	}
+	cw := &countWriter{w: w}
+	err := Encode(cw, e.img, e.format, e.opts...)
+	return cw.n, err
+}
+
+// Close stops the encoding started by Read.
+func (e *EncodedImage) Close() error {
+	if e.pr != nil {
+		return e.pr.Close()
+	}
+	return nil
+}
+
+// countWriter counts the bytes written to w.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// DecodeReaderAt reads an image of the given size from r like Decode, e.g. from a file or
+// a ranged object storage reader. The header is read to check the decode limits before
+// reading the rest of the image. The formats that need all the data at once (TIFF, camera
+// RAW, ICO, JPEG 2000 and JPEG XL) are read into a buffer of the exact size with no
+// extra copies, the other formats are streamed.
+func DecodeReaderAt(r io.ReaderAt, size int64, opts ...DecodeOption) (image.Image, error) {
+	cfg := defaultDecodeConfig
+	for _, option := range opts {
+		option(&cfg)
+	}
+	return decodeReaderAt(r, size, cfg)
+}
+
+func decodeReaderAt(r io.ReaderAt, size int64, cfg decodeConfig) (image.Image, error) {
+	if size < 0 {
+		size = 0
+	}
+	br := bufio.NewReader(io.NewSectionReader(r, 0, size))
+	switch sniffFormat(br) {
+	case TIFF, ICO, JP2, JXL:
+	default:
+		img, _, err := decodeWithFormat(br, nil, cfg)
+		return img, err
+	}
+
+	// The limits are checked before allocating the buffer.
+	if _, err := checkDecodeLimits(br, cfg); err != nil {
+		return nil, err
+	}
+	data := make([]byte, size)
+	if n, err := r.ReadAt(data, 0); n < len(data) {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	img, _, err := decodeWithFormat(bytes.NewReader(data), data, cfg)
+	return img, err
+}
@@ -0,0 +1,149 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"testing"
+)
+
+func TestEncodedImage(t *testing.T) {
+	img := testdataFlowersSmallPNG
+	for _, format := range []Format{JPEG, PNG, GIF, TIFF, BMP} {
+		want, err := EncodeBytes(img, format, JPEGQuality(80), GIFNumColors(64))
+		if err != nil {
+			t.Fatalf("EncodeBytes(%v): %v", format, err)
+		}
+		enc := NewEncodedImage(img, format, JPEGQuality(80), GIFNumColors(64))
+
+		var buf bytes.Buffer
+		n, err := io.Copy(&buf, enc)
+		if err != nil {
+			t.Fatalf("WriteTo(%v): %v", format, err)
+		}
+		if n != int64(len(want)) || !bytes.Equal(buf.Bytes(), want) {
+			t.Fatalf("WriteTo(%v): got %d bytes want %d", format, n, len(want))
+		}
+
+		enc = NewEncodedImage(img, format, JPEGQuality(80), GIFNumColors(64))
+		got, err := io.ReadAll(enc)
+		if err != nil {
+			t.Fatalf("Read(%v): %v", format, err)
+		}
+		if err := enc.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("Read(%v): the data differs", format)
+		}
+
+		// WriteTo copies the rest of the data after Read.
+		enc = NewEncodedImage(img, format, JPEGQuality(80), GIFNumColors(64))
+		head := make([]byte, 10)
+		if _, err := io.ReadFull(enc, head); err != nil {
+			t.Fatalf("Read(%v): %v", format, err)
+		}
+		buf.Reset()
+		if _, err := enc.WriteTo(&buf); err != nil {
+			t.Fatalf("WriteTo(%v): %v", format, err)
+		}
+		if !bytes.Equal(append(head, buf.Bytes()...), want) {
+			t.Fatalf("Read and WriteTo(%v): the data differs", format)
+		}
+	}
+}
+
+func TestEncodedImageErrors(t *testing.T) {
+	enc := NewEncodedImage(testdataFlowersSmallPNG, Format(-1))
+	if _, err := enc.WriteTo(io.Discard); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want %v", err, ErrUnsupportedFormat)
+	}
+	if _, err := io.ReadAll(NewEncodedImage(testdataFlowersSmallPNG, Format(-1))); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want %v", err, ErrUnsupportedFormat)
+	}
+
+	// Closing the reader early stops the encoding.
+	r := NewEncodedImage(testdataBranchesPNG, PNG)
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close before Read: %v", err)
+	}
+	if _, err := r.Read(make([]byte, 16)); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := r.Read(make([]byte, 16)); err != io.ErrClosedPipe {
+		t.Fatalf("got error %v want %v", err, io.ErrClosedPipe)
+	}
+
+	// The write errors are returned with the bytes written.
+	errWrite := errors.New("write failed")
+	n, err := NewEncodedImage(testdataFlowersSmallPNG, BMP).WriteTo(&limitWriter{n: 100, err: errWrite})
+	if err != errWrite || n != 100 {
+		t.Fatalf("got %d, %v want 100, %v", n, err, errWrite)
+	}
+}
+
+// limitWriter fails after n bytes.
+type limitWriter struct {
+	n   int
+	err error
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, w.err
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestDecodeReaderAt(t *testing.T) {
+	img := testdataFlowersSmallPNG
+	for _, format := range []Format{JPEG, PNG, TIFF, ICO, BMP, GIF} {
+		data, err := EncodeBytes(img, format)
+		if err != nil {
+			t.Fatalf("EncodeBytes(%v): %v", format, err)
+		}
+		want, err := DecodeBytes(data)
+		if err != nil {
+			t.Fatalf("DecodeBytes(%v): %v", format, err)
+		}
+		got, err := DecodeReaderAt(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("DecodeReaderAt(%v): %v", format, err)
+		}
+		if !compareNRGBA(Clone(got), Clone(want), 0) {
+			t.Fatalf("DecodeReaderAt(%v): the image differs", format)
+		}
+
+		// The buffered formats are read to the given size.
+		if format == TIFF || format == ICO {
+			if _, err := DecodeReaderAt(bytes.NewReader(data), int64(len(data))+10); err != io.ErrUnexpectedEOF {
+				t.Fatalf("DecodeReaderAt(%v): got error %v want %v", format, err, io.ErrUnexpectedEOF)
+			}
+		}
+		// The size cuts the data.
+		if _, err := DecodeReaderAt(bytes.NewReader(data), int64(len(data))/2); err == nil {
+			t.Fatalf("DecodeReaderAt(%v): no error for the truncated data", format)
+		}
+
+		var tooLarge *ImageTooLargeError
+		_, err = DecodeReaderAt(bytes.NewReader(data), int64(len(data)), MaxPixels(1000))
+		if !errors.As(err, &tooLarge) {
+			t.Fatalf("DecodeReaderAt(%v): got error %v want *ImageTooLargeError", format, err)
+		}
+		_, err = NewEngine(EngineConfig{MaxPixels: 1000}).DecodeReaderAt(bytes.NewReader(data), int64(len(data)))
+		if !errors.As(err, &tooLarge) {
+			t.Fatalf("Engine.DecodeReaderAt(%v): got error %v want *ImageTooLargeError", format, err)
+		}
+	}
+
+	if _, err := DecodeReaderAt(bytes.NewReader(nil), -1); err != image.ErrFormat {
+		t.Fatalf("got error %v want %v", err, image.ErrFormat)
+	}
+}
@@ -0,0 +1,134 @@
+package imaging
+
+import (
+	"image"
+)
+
+// SubpixelLayout is the order of the color subpixels of the display the text of a screenshot
+// was rendered for, see ResizeSubpixel.
+type SubpixelLayout int
+
+// Subpixel layouts.
+const (
+	// SubpixelRGB is the layout of most of the LCD displays, red on the left.
+	SubpixelRGB SubpixelLayout = iota
+
+	// SubpixelBGR is the reversed layout, blue on the left.
+	SubpixelBGR
+)
+
+// ResizeSubpixel resizes the screenshot with the text rendered with the subpixel
+// antialiasing, e.g. ClearType, to the specified width and height using the specified
+// resampling filter and returns the transformed image. If one of width or height is 0,
+// the image aspect ratio is preserved.
+//
+// Resize treats the colored fringes of the subpixel antialiased text as the colors of the
+// pixels, so the small text turns blurred and tinted. ResizeSubpixel instead resamples the
+// brightness horizontally from the subpixels at their positions, a third of a pixel apart,
+// so the strokes keep their shape, and the colors smoothed over two destination pixels,
+// where the complementary fringes of the strokes cancel out. The colors of the interface
+// wider than that are kept, the way the chroma subsampling of JPEG keeps them. The images
+// of the same width, the nearest-neighbor filter and the invalid layouts are resized
+// with Resize.
+//
+// Example:
+//
+//	dstImage := imaging.ResizeSubpixel(screenshot, screenshot.Bounds().Dx()/2, 0, imaging.SubpixelRGB, imaging.Lanczos)
+func ResizeSubpixel(img image.Image, width, height int, layout SubpixelLayout, filter ResampleFilter) *image.NRGBA {
+	src := newScanner(img)
+	dstW, dstH, ok := resizeSize(src.w, src.h, width, height)
+	if !ok {
+		return &image.NRGBA{}
+	}
+	if dstW == src.w || filter.Support <= 0 || (layout != SubpixelRGB && layout != SubpixelBGR) {
+		return Resize(img, width, height, filter)
+	}
+
+	subWeights := precomputeWeights(dstW, src.w*3, filter)
+	// The colors are resampled with a tent twice as wide as the destination pixels.
+	pixWeights := precomputeWeights(dstW, src.w, ResampleFilter{
+		Support: 2,
+		Kernel: func(x float64) float64 {
+			return Linear.Kernel(x / 2)
+		},
+	})
+	tmp := newNRGBA(image.Rect(0, 0, dstW, src.h))
+	parallel(0, src.h, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		// The subpixels and their alpha, and the deviations of the channels from the mean of
+		// each pixel, all premultiplied by the alpha.
+		sub, subAlpha := make([]float64, src.w*3+2), make([]float64, src.w*3+2)
+		dev, blurred := make([]float64, src.w*4), make([]float64, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			for x := 0; x < src.w; x++ {
+				s := scanLine[x*4 : x*4+4 : x*4+4]
+				a := float64(s[3])
+				mean := (float64(s[0]) + float64(s[1]) + float64(s[2])) / 3
+				for k := 0; k < 3; k++ {
+					c := k
+					if layout == SubpixelBGR {
+						c = 2 - k
+					}
+					sub[x*3+k+1] = float64(s[c]) * a
+					subAlpha[x*3+k+1] = a
+					dev[x*4+k] = (float64(s[k]) - mean) * a
+				}
+				dev[x*4+3] = a
+			}
+			// The subpixels are averaged with their neighbors, so the uniform colors don't
+			// alias with the resampling.
+			sub[0], subAlpha[0] = sub[3], subAlpha[3]
+			sub[src.w*3+1], subAlpha[src.w*3+1] = sub[src.w*3-2], subAlpha[src.w*3-2]
+			for i := 0; i < src.w*3; i++ {
+				sub[i] = (sub[i] + sub[i+1] + sub[i+2]) / 3
+				subAlpha[i] = (subAlpha[i] + subAlpha[i+1] + subAlpha[i+2]) / 3
+			}
+			for x := 0; x < src.w; x++ {
+				x1, x2 := maxint(x-1, 0), minint(x+1, src.w-1)
+				for k := 0; k < 4; k++ {
+					var sum float64
+					for i := x1; i <= x2; i++ {
+						sum += dev[i*4+k]
+					}
+					blurred[x*4+k] = sum / float64(x2-x1+1)
+				}
+			}
+
+			ws, wp := subWeights.weights, pixWeights.weights
+			for i := 0; i < dstW; i++ {
+				var lum, lumAlpha float64
+				start, n := subWeights.starts[i], subWeights.counts[i]
+				for k, w := range ws[:n] {
+					lum += sub[start+k] * w
+					lumAlpha += subAlpha[start+k] * w
+				}
+				ws = ws[n:]
+
+				var chroma [4]float64
+				start, n = pixWeights.starts[i], pixWeights.counts[i]
+				for k, w := range wp[:n] {
+					for c := range chroma {
+						chroma[c] += blurred[(start+k)*4+c] * w
+					}
+				}
+				wp = wp[n:]
+
+				if lumAlpha <= 0 || chroma[3] <= 0 {
+					continue
+				}
+				lum /= lumAlpha
+				d := tmp.Pix[y*tmp.Stride+i*4 : y*tmp.Stride+i*4+4 : y*tmp.Stride+i*4+4]
+				d[0] = clamp(lum + chroma[0]/chroma[3])
+				d[1] = clamp(lum + chroma[1]/chroma[3])
+				d[2] = clamp(lum + chroma[2]/chroma[3])
+				d[3] = clamp(chroma[3])
+			}
+		}
+	})
+	if dstH == src.h {
+		return tmp
+	}
+	defer Release(tmp)
+	return defaultEngine.resizeVertical(tmp, dstH, filter)
+}
@@ -0,0 +1,84 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// subpixelStroke returns a white image with a black vertical stroke of a pixel rendered
+// with the subpixel antialiasing a third of a pixel to the right of the pixel 12.
+func subpixelStroke(layout SubpixelLayout) *image.NRGBA {
+	img := New(48, 4, color.White)
+	left, right := color.NRGBA{255, 255, 0, 255}, color.NRGBA{0, 0, 255, 255}
+	if layout == SubpixelBGR {
+		left, right = color.NRGBA{0, 255, 255, 255}, color.NRGBA{255, 0, 0, 255}
+	}
+	for y := 0; y < 4; y++ {
+		img.SetNRGBA(11, y, left)
+		img.SetNRGBA(12, y, right)
+	}
+	return img
+}
+
+func TestResizeSubpixel(t *testing.T) {
+	for _, layout := range []SubpixelLayout{SubpixelRGB, SubpixelBGR} {
+		src := subpixelStroke(layout)
+		got := ResizeSubpixel(src, 16, 4, layout, Box)
+		if got.Rect != image.Rect(0, 0, 16, 4) {
+			t.Fatalf("got bounds %v", got.Rect)
+		}
+		// The stroke covers a ninth of the pixel 3 and two ninths of the pixel 4.
+		for x, want := range map[int]float64{3: 255 * 8 / 9.0, 4: 255 * 7 / 9.0} {
+			c := got.NRGBAAt(x, 0)
+			mean := (float64(c.R) + float64(c.G) + float64(c.B)) / 3
+			if mean < want-3 || mean > want+3 {
+				t.Fatalf("layout %d: got pixel %d %v want brightness %g", layout, x, c, want)
+			}
+			if absint(int(c.R)-int(c.B)) > 12 || absint(int(c.R)-int(c.G)) > 12 {
+				t.Fatalf("layout %d: got pixel %d %v want gray", layout, x, c)
+			}
+		}
+		// Resize keeps the fringes.
+		if c := Resize(src, 16, 4, Box).NRGBAAt(3, 0); absint(int(c.R)-int(c.B)) < 60 {
+			t.Fatalf("got pixel %v of Resize want tinted", c)
+		}
+	}
+}
+
+func TestResizeSubpixelColors(t *testing.T) {
+	src := New(48, 20, color.NRGBA{200, 50, 100, 255})
+	got := ResizeSubpixel(src, 16, 0, SubpixelRGB, Lanczos)
+	if !compareNRGBA(got, New(16, 7, color.NRGBA{200, 50, 100, 255}), 1) {
+		t.Fatalf("got %v want the uniform color", got.NRGBAAt(0, 0))
+	}
+
+	for _, tc := range []struct {
+		name   string
+		layout SubpixelLayout
+		filter ResampleFilter
+		width  int
+	}{
+		{"invalid layout", SubpixelLayout(5), Lanczos, 40},
+		{"nearest neighbor", SubpixelRGB, NearestNeighbor, 40},
+		{"same width", SubpixelRGB, Lanczos, 240},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ResizeSubpixel(testdataFlowersSmallPNG, tc.width, 30, tc.layout, tc.filter)
+			want := Resize(testdataFlowersSmallPNG, tc.width, 30, tc.filter)
+			if !compareNRGBA(got, want, 0) {
+				t.Fatal("the result differs from Resize")
+			}
+		})
+	}
+	if got := ResizeSubpixel(src, 0, 0, SubpixelRGB, Box); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v want empty image", got.Rect)
+	}
+}
+
+func BenchmarkResizeSubpixel(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ResizeSubpixel(testdataBranchesJPG, 256, 0, SubpixelRGB, Lanczos)
+	}
+}
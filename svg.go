@@ -0,0 +1,1380 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/colornames"
+)
+
+// EncodeSVG traces the image into vector shapes and writes them to w as an SVG document.
+// The image colors are reduced to at most numColors colors (posterized), then the outlines
+// of the areas of each color are traced into SVG paths. This works best for logo-like images
+// with a few flat colors. Fully transparent areas are left empty.
+//
+// Example:
+//
+//	err := imaging.EncodeSVG(file, logoImage, 8)
+func EncodeSVG(w io.Writer, img image.Image, numColors int) error {
+	src := newScanner(img)
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		src.w, src.h, src.w, src.h)
+
+	p := Quantize(img, numColors, QuantizeMedianCut)
+	if len(p) > 0 && src.w > 0 && src.h > 0 {
+		indexed := Dither(img, p, DitherNone)
+
+		// Draw the colors covering the largest areas first.
+		counts := make([]int, len(p))
+		for _, idx := range indexed.Pix {
+			counts[idx]++
+		}
+		order := make([]int, len(p))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(i, j int) bool { return counts[order[i]] > counts[order[j]] })
+
+		for _, idx := range order {
+			c := p[idx].(color.NRGBA)
+			if c.A == 0 || counts[idx] == 0 {
+				continue
+			}
+			loops := traceBoundaries(src.w, src.h, func(x, y int) bool {
+				return indexed.Pix[y*indexed.Stride+x] == uint8(idx)
+			})
+			writeSVGPath(bw, loops, c)
+		}
+	}
+
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+func writeSVGPath(bw *bufio.Writer, loops [][]image.Point, c color.NRGBA) {
+	fmt.Fprintf(bw, `<path fill="#%02x%02x%02x"`, c.R, c.G, c.B)
+	if c.A != 0xff {
+		bw.WriteString(` fill-opacity="`)
+		bw.WriteString(strconv.FormatFloat(float64(c.A)/255, 'f', 3, 64))
+		bw.WriteByte('"')
+	}
+	bw.WriteString(` fill-rule="evenodd" d="`)
+	for _, loop := range loops {
+		for i, pt := range loop {
+			if i == 0 {
+				bw.WriteByte('M')
+			} else {
+				bw.WriteByte('L')
+			}
+			bw.WriteString(strconv.Itoa(pt.X))
+			bw.WriteByte(' ')
+			bw.WriteString(strconv.Itoa(pt.Y))
+		}
+		bw.WriteByte('Z')
+	}
+	bw.WriteString(`"/>` + "\n")
+}
+
+// The rest of this file implements an SVG rasterizer used by Decode with SVGDecoding.
+// It supports the basic shapes, paths, groups, transforms, solid fills and strokes and
+// the use element. Gradients are approximated with the average color of their stops;
+// text, clipping, masks, filters and CSS style sheets are ignored. Stroke joins are
+// always round.
+
+var (
+	errInvalidSVG    = errors.New("imaging: invalid SVG data")
+	errSVGTooLarge   = errors.New("imaging: SVG image is too large to rasterize")
+	errSVGTooComplex = errors.New("imaging: SVG image has too many elements to rasterize")
+	errSVGUseCycle   = errors.New("imaging: SVG use element references itself")
+)
+
+// svgMaxNodes is the maximum number of the elements rendered from a document, counting each
+// element once per use element referencing it. The nested use elements multiply the elements,
+// so a small document could take hours to render without the limit.
+const svgMaxNodes = 100000
+
+// isSVG reports whether the data starts like an SVG document.
+func isSVG(br *bufio.Reader) bool {
+	b, _ := br.Peek(1024)
+	b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+	b = bytes.TrimLeft(b, " \t\r\n")
+	return len(b) > 0 && b[0] == '<' && bytes.Contains(b, []byte("<svg"))
+}
+
+// svgNode is an element of the SVG document tree. The declarations of the style attribute
+// are merged into the attributes.
+type svgNode struct {
+	name     string
+	attrs    map[string]string
+	children []*svgNode
+}
+
+// parseSVG reads the SVG document tree.
+func parseSVG(r io.Reader) (*svgNode, error) {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.Entity = xml.HTMLEntity
+	d.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) { return input, nil }
+
+	var root *svgNode
+	var stack []*svgNode
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errInvalidSVG
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &svgNode{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr))}
+			for _, a := range t.Attr {
+				n.attrs[a.Name.Local] = strings.TrimSpace(a.Value)
+			}
+			for _, decl := range strings.Split(n.attrs["style"], ";") {
+				if k, v, ok := strings.Cut(decl, ":"); ok {
+					n.attrs[strings.TrimSpace(k)] = strings.TrimSpace(v)
+				}
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if root == nil {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	if root == nil || root.name != "svg" {
+		return nil, errInvalidSVG
+	}
+	return root, nil
+}
+
+// svgLayout is the size of an SVG image.
+type svgLayout struct {
+	iw, ih        float64 // The intrinsic size in CSS pixels.
+	vb            [4]float64
+	hasViewBox    bool
+	width, height int // The size of the rasterized image.
+}
+
+// newSVGLayout returns the size of the rasterized image. It's taken from the width and
+// height attributes (or the view box) at the configured DPI unless the size is set explicitly.
+func newSVGLayout(root *svgNode, cfg decodeConfig) (*svgLayout, error) {
+	l := &svgLayout{}
+	if nums := svgNumbers(root.attrs["viewBox"]); len(nums) == 4 && nums[2] > 0 && nums[3] > 0 {
+		copy(l.vb[:], nums)
+		l.hasViewBox = true
+	}
+
+	iw, wok := svgAbsLength(root.attrs["width"])
+	ih, hok := svgAbsLength(root.attrs["height"])
+	switch {
+	case !wok && !hok && l.hasViewBox:
+		iw, ih = l.vb[2], l.vb[3]
+	case !wok && !hok:
+		iw, ih = 300, 150
+	case !wok && l.hasViewBox:
+		iw = ih * l.vb[2] / l.vb[3]
+	case !hok && l.hasViewBox:
+		ih = iw * l.vb[3] / l.vb[2]
+	case !wok:
+		iw = 300
+	case !hok:
+		ih = 150
+	}
+	if iw <= 0 || ih <= 0 {
+		return nil, errInvalidSVG
+	}
+	l.iw, l.ih = iw, ih
+
+	var w, h float64
+	switch {
+	case cfg.svgWidth > 0 && cfg.svgHeight > 0:
+		w, h = float64(cfg.svgWidth), float64(cfg.svgHeight)
+	case cfg.svgWidth > 0:
+		w = float64(cfg.svgWidth)
+		h = w * ih / iw
+	case cfg.svgHeight > 0:
+		h = float64(cfg.svgHeight)
+		w = h * iw / ih
+	default:
+		w, h = iw*cfg.svgDPI/96, ih*cfg.svgDPI/96
+	}
+	l.width, l.height = int(math.Max(1, math.Round(w))), int(math.Max(1, math.Round(h)))
+	if float64(l.width)*float64(l.height) > 1<<28 {
+		return nil, errSVGTooLarge
+	}
+	return l, nil
+}
+
+// decodeSVGConfig returns the size of the image decodeSVG returns without rasterizing it.
+func decodeSVGConfig(r io.Reader, cfg decodeConfig) (image.Config, error) {
+	root, err := parseSVG(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	l, err := newSVGLayout(root, cfg)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: l.width, Height: l.height}, nil
+}
+
+// decodeSVG rasterizes an SVG document at the size given by newSVGLayout.
+func decodeSVG(r io.Reader, cfg decodeConfig) (image.Image, error) {
+	root, err := parseSVG(r)
+	if err != nil {
+		return nil, err
+	}
+	l, err := newSVGLayout(root, cfg)
+	if err != nil {
+		return nil, err
+	}
+	iw, ih, vb, width, height := l.iw, l.ih, l.vb, l.width, l.height
+
+	// The transform from the user space to the image pixels.
+	var m svgMatrix
+	if l.hasViewBox {
+		m = svgViewBoxTransform(vb, float64(width), float64(height), root.attrs["preserveAspectRatio"])
+	} else {
+		m = svgMatrix{float64(width) / iw, 0, 0, float64(height) / ih, 0, 0}
+		vb = [4]float64{0, 0, iw, ih}
+	}
+
+	rd := &svgRenderer{
+		ids:    make(map[string]*svgNode),
+		dst:    image.NewRGBA(image.Rect(0, 0, width, height)),
+		vw:     vb[2],
+		vh:     vb[3],
+		active: make(map[*svgNode]bool),
+	}
+	rd.z.init(width, height)
+	rd.collectIDs(root)
+	st := svgStyle{
+		m:           m,
+		fill:        svgPaint{c: color.NRGBA{0, 0, 0, 0xff}, ok: true},
+		fillOpacity: 1, strokeOpacity: 1, opacity: 1,
+		strokeWidth: 1,
+		color:       color.NRGBA{0, 0, 0, 0xff},
+	}
+	for _, c := range root.children {
+		rd.render(c, st, 0)
+	}
+	if rd.err != nil {
+		return nil, rd.err
+	}
+	return toNRGBA(rd.dst), nil
+}
+
+// svgViewBoxTransform maps the view box onto the width x height viewport
+// according to the preserveAspectRatio attribute value.
+func svgViewBoxTransform(vb [4]float64, width, height float64, par string) svgMatrix {
+	sx, sy := width/vb[2], height/vb[3]
+	fields := strings.Fields(par)
+	align, slice := "xMidYMid", false
+	if len(fields) > 0 {
+		align = fields[0]
+	}
+	if len(fields) > 1 {
+		slice = fields[1] == "slice"
+	}
+	if align == "none" {
+		return svgMatrix{sx, 0, 0, sy, -vb[0] * sx, -vb[1] * sy}
+	}
+	s := math.Min(sx, sy)
+	if slice {
+		s = math.Max(sx, sy)
+	}
+	tx, ty := -vb[0]*s, -vb[1]*s
+	if strings.Contains(align, "xMid") {
+		tx += (width - vb[2]*s) / 2
+	} else if strings.Contains(align, "xMax") {
+		tx += width - vb[2]*s
+	}
+	if strings.Contains(align, "YMid") {
+		ty += (height - vb[3]*s) / 2
+	} else if strings.Contains(align, "YMax") {
+		ty += height - vb[3]*s
+	}
+	return svgMatrix{s, 0, 0, s, tx, ty}
+}
+
+// svgMatrix is an affine transform mapping (x, y) to (a*x + c*y + e, b*x + d*y + f).
+type svgMatrix [6]float64
+
+var svgIdentity = svgMatrix{1, 0, 0, 1, 0, 0}
+
+// mul returns the transform applying n first and then m.
+func (m svgMatrix) mul(n svgMatrix) svgMatrix {
+	return svgMatrix{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+func (m svgMatrix) apply(x, y float64) svgPoint {
+	return svgPoint{m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]}
+}
+
+// parseSVGTransform parses the transform attribute value.
+func parseSVGTransform(s string) svgMatrix {
+	m := svgIdentity
+	for {
+		open := strings.IndexByte(s, '(')
+		end := strings.IndexByte(s, ')')
+		if open < 0 || end < open {
+			return m
+		}
+		name := strings.Trim(s[:open], " \t\r\n,")
+		args := svgNumbers(s[open+1 : end])
+		s = s[end+1:]
+		arg := func(i int, def float64) float64 {
+			if i < len(args) {
+				return args[i]
+			}
+			return def
+		}
+		var t svgMatrix
+		switch name {
+		case "matrix":
+			if len(args) != 6 {
+				continue
+			}
+			copy(t[:], args)
+		case "translate":
+			t = svgMatrix{1, 0, 0, 1, arg(0, 0), arg(1, 0)}
+		case "scale":
+			sx := arg(0, 1)
+			t = svgMatrix{sx, 0, 0, arg(1, sx), 0, 0}
+		case "rotate":
+			sin, cos := math.Sincos(arg(0, 0) * math.Pi / 180)
+			cx, cy := arg(1, 0), arg(2, 0)
+			t = svgMatrix{1, 0, 0, 1, cx, cy}.mul(svgMatrix{cos, sin, -sin, cos, 0, 0}).mul(svgMatrix{1, 0, 0, 1, -cx, -cy})
+		case "skewX":
+			t = svgMatrix{1, 0, math.Tan(arg(0, 0) * math.Pi / 180), 1, 0, 0}
+		case "skewY":
+			t = svgMatrix{1, math.Tan(arg(0, 0) * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			continue
+		}
+		m = m.mul(t)
+	}
+}
+
+// svgScanner reads numbers and flags from path data and number lists.
+type svgScanner struct {
+	s string
+	i int
+}
+
+func (sc *svgScanner) skipSeparators() {
+	for sc.i < len(sc.s) && strings.IndexByte(" \t\r\n,", sc.s[sc.i]) >= 0 {
+		sc.i++
+	}
+}
+
+func (sc *svgScanner) digits() int {
+	start := sc.i
+	for sc.i < len(sc.s) && sc.s[sc.i] >= '0' && sc.s[sc.i] <= '9' {
+		sc.i++
+	}
+	return sc.i - start
+}
+
+func (sc *svgScanner) number() (float64, bool) {
+	sc.skipSeparators()
+	start := sc.i
+	if sc.i < len(sc.s) && (sc.s[sc.i] == '+' || sc.s[sc.i] == '-') {
+		sc.i++
+	}
+	n := sc.digits()
+	if sc.i < len(sc.s) && sc.s[sc.i] == '.' {
+		sc.i++
+		n += sc.digits()
+	}
+	if n == 0 {
+		sc.i = start
+		return 0, false
+	}
+	if sc.i < len(sc.s) && (sc.s[sc.i] == 'e' || sc.s[sc.i] == 'E') {
+		mark := sc.i
+		sc.i++
+		if sc.i < len(sc.s) && (sc.s[sc.i] == '+' || sc.s[sc.i] == '-') {
+			sc.i++
+		}
+		if sc.digits() == 0 {
+			sc.i = mark
+		}
+	}
+	v, err := strconv.ParseFloat(sc.s[start:sc.i], 64)
+	if err != nil {
+		sc.i = start
+		return 0, false
+	}
+	return v, true
+}
+
+// flag reads an arc flag, which may be not separated from the next number.
+func (sc *svgScanner) flag() (bool, bool) {
+	sc.skipSeparators()
+	if sc.i < len(sc.s) && (sc.s[sc.i] == '0' || sc.s[sc.i] == '1') {
+		sc.i++
+		return sc.s[sc.i-1] == '1', true
+	}
+	return false, false
+}
+
+// svgNumbers parses a list of numbers separated by whitespace or commas.
+func svgNumbers(s string) []float64 {
+	sc := svgScanner{s: s}
+	var nums []float64
+	for {
+		v, ok := sc.number()
+		if !ok {
+			return nums
+		}
+		nums = append(nums, v)
+	}
+}
+
+// svgUnits are the sizes of the length units in CSS pixels.
+var svgUnits = map[string]float64{
+	"":   1,
+	"px": 1,
+	"pt": 96.0 / 72,
+	"pc": 16,
+	"mm": 96 / 25.4,
+	"cm": 96 / 2.54,
+	"in": 96,
+	"em": 16,
+	"ex": 8,
+}
+
+// svgLength parses a length. Percentages are relative to ref.
+func svgLength(s string, ref float64) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "%") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(s[:len(s)-1]), 64)
+		return v * ref / 100, err == nil
+	}
+	end := len(s)
+	for end > 0 && (s[end-1] >= 'a' && s[end-1] <= 'z' || s[end-1] >= 'A' && s[end-1] <= 'Z') {
+		end--
+	}
+	unit, ok := svgUnits[strings.ToLower(s[end:])]
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(s[:end]), 64)
+	if err != nil {
+		return 0, false
+	}
+	return v * unit, true
+}
+
+// svgAbsLength parses a length that isn't a percentage.
+func svgAbsLength(s string) (float64, bool) {
+	if strings.HasSuffix(strings.TrimSpace(s), "%") {
+		return 0, false
+	}
+	return svgLength(s, 0)
+}
+
+// svgPaint is a fill or stroke color. The zero value means no painting.
+type svgPaint struct {
+	c  color.NRGBA
+	ok bool
+}
+
+// svgStyle is the inherited state of the rendering.
+type svgStyle struct {
+	m             svgMatrix
+	fill, stroke  svgPaint
+	fillOpacity   float64
+	strokeOpacity float64
+	opacity       float64 // The product of the ancestor group opacities.
+	strokeWidth   float64
+	evenOdd       bool
+	lineCap       string
+	color         color.NRGBA // The currentColor value.
+	hidden        bool
+}
+
+// parseSVGColor parses a color value. It reports false if the value is invalid.
+func parseSVGColor(s string, current color.NRGBA) (svgPaint, bool) {
+	s = strings.TrimSpace(s)
+	ls := strings.ToLower(s)
+	switch {
+	case ls == "none", ls == "transparent":
+		return svgPaint{}, true
+	case ls == "currentcolor":
+		return svgPaint{c: current, ok: true}, true
+	case strings.HasPrefix(s, "#"):
+		hex := s[1:]
+		if len(hex) == 3 || len(hex) == 4 {
+			var b strings.Builder
+			for i := 0; i < len(hex); i++ {
+				b.WriteByte(hex[i])
+				b.WriteByte(hex[i])
+			}
+			hex = b.String()
+		}
+		if len(hex) == 6 {
+			hex += "ff"
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if len(hex) != 8 || err != nil {
+			return svgPaint{}, false
+		}
+		return svgPaint{c: color.NRGBA{uint8(v >> 24), uint8(v >> 16), uint8(v >> 8), uint8(v)}, ok: true}, true
+	case strings.HasPrefix(ls, "rgb(") || strings.HasPrefix(ls, "rgba("):
+		open, end := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+		if end < open {
+			return svgPaint{}, false
+		}
+		parts := strings.FieldsFunc(s[open+1:end], func(r rune) bool { return r == ',' || r == ' ' || r == '/' })
+		if len(parts) != 3 && len(parts) != 4 {
+			return svgPaint{}, false
+		}
+		var c [4]float64
+		c[3] = 1
+		for i, p := range parts {
+			ref := 255.0
+			if i == 3 {
+				ref = 1
+			}
+			v, ok := svgLength(p, ref)
+			if !ok {
+				return svgPaint{}, false
+			}
+			c[i] = v
+		}
+		return svgPaint{c: color.NRGBA{clamp(c[0]), clamp(c[1]), clamp(c[2]), clamp(c[3] * 255)}, ok: true}, true
+	}
+	if c, ok := colornames.Map[ls]; ok {
+		return svgPaint{c: color.NRGBA{c.R, c.G, c.B, c.A}, ok: true}, true
+	}
+	return svgPaint{}, false
+}
+
+// svgRenderer draws the SVG document tree.
+type svgRenderer struct {
+	ids    map[string]*svgNode
+	dst    *image.RGBA
+	z      svgRasterizer
+	vw, vh float64 // The view box size, percentages are relative to it.
+
+	// nodes is the number of the elements rendered so far and active are the elements being
+	// rendered, the ancestors of the current one including the referenced ones.
+	nodes  int
+	active map[*svgNode]bool
+	err    error
+}
+
+func (rd *svgRenderer) collectIDs(n *svgNode) {
+	if id := n.attrs["id"]; id != "" {
+		if _, ok := rd.ids[id]; !ok {
+			rd.ids[id] = n
+		}
+	}
+	for _, c := range n.children {
+		rd.collectIDs(c)
+	}
+}
+
+// paint resolves a fill or stroke value. It reports false if the value is invalid.
+func (rd *svgRenderer) paint(s string, current color.NRGBA) (svgPaint, bool) {
+	if !strings.HasPrefix(s, "url(") {
+		return parseSVGColor(s, current)
+	}
+	end := strings.IndexByte(s, ')')
+	if end < 0 {
+		return svgPaint{}, false
+	}
+	ref := strings.Trim(strings.TrimSpace(s[4:end]), `"'`)
+	if p, ok := rd.gradientColor(strings.TrimPrefix(ref, "#"), current); ok {
+		return p, true
+	}
+	// Use the fallback color.
+	if fallback := strings.TrimSpace(s[end+1:]); fallback != "" {
+		return parseSVGColor(fallback, current)
+	}
+	return svgPaint{}, true
+}
+
+// gradientColor returns the average color of the gradient stops.
+func (rd *svgRenderer) gradientColor(id string, current color.NRGBA) (svgPaint, bool) {
+	for depth := 0; depth < 8; depth++ {
+		n, ok := rd.ids[id]
+		if !ok || (n.name != "linearGradient" && n.name != "radialGradient") {
+			return svgPaint{}, false
+		}
+		var r, g, b, a float64
+		count := 0
+		for _, stop := range n.children {
+			if stop.name != "stop" {
+				continue
+			}
+			p, ok := parseSVGColor(stop.attrs["stop-color"], current)
+			if _, set := stop.attrs["stop-color"]; !set {
+				p, ok = svgPaint{c: color.NRGBA{0, 0, 0, 0xff}, ok: true}, true
+			}
+			if !ok || !p.ok {
+				p.c = color.NRGBA{}
+			}
+			alpha := float64(p.c.A) / 255
+			if v, ok := svgOpacity(stop.attrs["stop-opacity"]); ok {
+				alpha *= v
+			}
+			r += float64(p.c.R) * alpha
+			g += float64(p.c.G) * alpha
+			b += float64(p.c.B) * alpha
+			a += alpha
+			count++
+		}
+		if count > 0 {
+			if a == 0 {
+				return svgPaint{}, true
+			}
+			return svgPaint{c: color.NRGBA{clamp(r / a), clamp(g / a), clamp(b / a), clamp(a / float64(count) * 255)}, ok: true}, true
+		}
+		// The stops may be inherited from the referenced gradient.
+		id = strings.TrimPrefix(n.attrs["href"], "#")
+	}
+	return svgPaint{}, false
+}
+
+// svgOpacity parses an opacity value, a number or a percentage.
+func svgOpacity(s string) (float64, bool) {
+	if s == "" {
+		return 0, false
+	}
+	v, ok := svgLength(s, 1)
+	return math.Max(0, math.Min(1, v)), ok
+}
+
+// inherit returns the style of the node given the style of its parent.
+func (rd *svgRenderer) inherit(n *svgNode, st svgStyle) svgStyle {
+	a := n.attrs
+	if v, ok := a["color"]; ok {
+		if p, ok := parseSVGColor(v, st.color); ok && p.ok {
+			st.color = p.c
+		}
+	}
+	if v, ok := a["fill"]; ok {
+		if p, ok := rd.paint(v, st.color); ok {
+			st.fill = p
+		}
+	}
+	if v, ok := a["stroke"]; ok {
+		if p, ok := rd.paint(v, st.color); ok {
+			st.stroke = p
+		}
+	}
+	if v, ok := svgOpacity(a["fill-opacity"]); ok {
+		st.fillOpacity = v
+	}
+	if v, ok := svgOpacity(a["stroke-opacity"]); ok {
+		st.strokeOpacity = v
+	}
+	if v, ok := svgOpacity(a["opacity"]); ok {
+		st.opacity *= v
+	}
+	if v, ok := svgLength(a["stroke-width"], math.Sqrt((rd.vw*rd.vw+rd.vh*rd.vh)/2)); ok && v >= 0 {
+		st.strokeWidth = v
+	}
+	switch a["fill-rule"] {
+	case "evenodd":
+		st.evenOdd = true
+	case "nonzero":
+		st.evenOdd = false
+	}
+	if v := a["stroke-linecap"]; v != "" {
+		st.lineCap = v
+	}
+	switch a["visibility"] {
+	case "hidden", "collapse":
+		st.hidden = true
+	case "visible":
+		st.hidden = false
+	}
+	if v := a["transform"]; v != "" {
+		st.m = st.m.mul(parseSVGTransform(v))
+	}
+	return st
+}
+
+// render draws the node and its children.
+func (rd *svgRenderer) render(n *svgNode, st svgStyle, depth int) {
+	if rd.err != nil || depth > 64 || n.attrs["display"] == "none" {
+		return
+	}
+	if rd.nodes++; rd.nodes > svgMaxNodes {
+		rd.err = errSVGTooComplex
+		return
+	}
+	rd.active[n] = true
+	defer delete(rd.active, n)
+	switch n.name {
+	case "defs", "symbol", "clipPath", "mask", "pattern", "marker", "filter", "linearGradient",
+		"radialGradient", "style", "script", "title", "desc", "metadata", "text", "foreignObject":
+		return
+	}
+	st = rd.inherit(n, st)
+
+	switch n.name {
+	case "g", "a", "switch":
+		for _, c := range n.children {
+			rd.render(c, st, depth+1)
+		}
+		return
+	case "svg":
+		x, _ := svgLength(n.attrs["x"], rd.vw)
+		y, _ := svgLength(n.attrs["y"], rd.vh)
+		st.m = st.m.mul(svgMatrix{1, 0, 0, 1, x, y})
+		for _, c := range n.children {
+			rd.render(c, st, depth+1)
+		}
+		return
+	case "use":
+		ref, ok := rd.ids[strings.TrimPrefix(n.attrs["href"], "#")]
+		if !ok {
+			return
+		}
+		if rd.active[ref] {
+			rd.err = errSVGUseCycle
+			return
+		}
+		x, _ := svgLength(n.attrs["x"], rd.vw)
+		y, _ := svgLength(n.attrs["y"], rd.vh)
+		st.m = st.m.mul(svgMatrix{1, 0, 0, 1, x, y})
+		if ref.name == "symbol" {
+			rd.active[ref] = true
+			defer delete(rd.active, ref)
+			for _, c := range ref.children {
+				rd.render(c, st, depth+1)
+			}
+			return
+		}
+		rd.render(ref, st, depth+1)
+		return
+	}
+
+	if st.hidden {
+		return
+	}
+	p := &svgPath{m: st.m}
+	if !rd.shape(n, p) {
+		return
+	}
+
+	if st.fill.ok && st.fillOpacity > 0 {
+		c := st.fill.c
+		c.A = clamp(float64(c.A) * st.fillOpacity * st.opacity)
+		var polys [][]svgPoint
+		for _, sp := range p.subpaths {
+			if len(sp) >= 3 {
+				polys = append(polys, sp)
+			}
+		}
+		rd.z.fill(rd.dst, polys, st.evenOdd, c)
+	}
+
+	det := st.m[0]*st.m[3] - st.m[1]*st.m[2]
+	width := st.strokeWidth * math.Sqrt(math.Abs(det))
+	if st.stroke.ok && st.strokeOpacity > 0 && width > 0 {
+		c := st.stroke.c
+		c.A = clamp(float64(c.A) * st.strokeOpacity * st.opacity)
+		rd.z.fill(rd.dst, strokeSVGPath(p, width/2, st.lineCap), false, c)
+	}
+}
+
+// shape builds the outline of a basic shape or path element. It reports false if the element
+// isn't a shape.
+func (rd *svgRenderer) shape(n *svgNode, p *svgPath) bool {
+	diag := math.Sqrt((rd.vw*rd.vw + rd.vh*rd.vh) / 2)
+	attr := func(name string, ref float64) float64 {
+		v, _ := svgLength(n.attrs[name], ref)
+		return v
+	}
+	switch n.name {
+	case "path":
+		p.parse(n.attrs["d"])
+	case "rect":
+		x, y := attr("x", rd.vw), attr("y", rd.vh)
+		w, h := attr("width", rd.vw), attr("height", rd.vh)
+		if w <= 0 || h <= 0 {
+			return false
+		}
+		rx, rxok := svgLength(n.attrs["rx"], rd.vw)
+		ry, ryok := svgLength(n.attrs["ry"], rd.vh)
+		if !rxok {
+			rx = ry
+		}
+		if !ryok {
+			ry = rx
+		}
+		rx, ry = math.Max(0, math.Min(rx, w/2)), math.Max(0, math.Min(ry, h/2))
+		if rx == 0 || ry == 0 {
+			p.moveTo(x, y)
+			p.lineTo(x+w, y)
+			p.lineTo(x+w, y+h)
+			p.lineTo(x, y+h)
+			p.close()
+			break
+		}
+		p.moveTo(x+rx, y)
+		p.lineTo(x+w-rx, y)
+		p.arcTo(x+w-rx, y, rx, ry, 0, false, true, x+w, y+ry)
+		p.lineTo(x+w, y+h-ry)
+		p.arcTo(x+w, y+h-ry, rx, ry, 0, false, true, x+w-rx, y+h)
+		p.lineTo(x+rx, y+h)
+		p.arcTo(x+rx, y+h, rx, ry, 0, false, true, x, y+h-ry)
+		p.lineTo(x, y+ry)
+		p.arcTo(x, y+ry, rx, ry, 0, false, true, x+rx, y)
+		p.close()
+	case "circle", "ellipse":
+		cx, cy := attr("cx", rd.vw), attr("cy", rd.vh)
+		var rx, ry float64
+		if n.name == "circle" {
+			rx = attr("r", diag)
+			ry = rx
+		} else {
+			rx, ry = attr("rx", rd.vw), attr("ry", rd.vh)
+		}
+		if rx <= 0 || ry <= 0 {
+			return false
+		}
+		p.moveTo(cx+rx, cy)
+		p.arcTo(cx+rx, cy, rx, ry, 0, false, true, cx-rx, cy)
+		p.arcTo(cx-rx, cy, rx, ry, 0, false, true, cx+rx, cy)
+		p.close()
+	case "line":
+		p.moveTo(attr("x1", rd.vw), attr("y1", rd.vh))
+		p.lineTo(attr("x2", rd.vw), attr("y2", rd.vh))
+	case "polyline", "polygon":
+		nums := svgNumbers(n.attrs["points"])
+		for i := 0; i+1 < len(nums); i += 2 {
+			if i == 0 {
+				p.moveTo(nums[i], nums[i+1])
+			} else {
+				p.lineTo(nums[i], nums[i+1])
+			}
+		}
+		if n.name == "polygon" {
+			p.close()
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// svgPoint is a point in the image pixel coordinates.
+type svgPoint struct {
+	x, y float64
+}
+
+// svgPath is an outline made of polygonal subpaths in the image pixel coordinates.
+// The curves are flattened after transforming their control points.
+type svgPath struct {
+	m        svgMatrix
+	subpaths [][]svgPoint
+	closed   []bool
+}
+
+func (p *svgPath) moveTo(x, y float64) {
+	p.subpaths = append(p.subpaths, []svgPoint{p.m.apply(x, y)})
+	p.closed = append(p.closed, false)
+}
+
+// last returns the current point in the image coordinates. After closing a subpath,
+// a new one is started at the same point.
+func (p *svgPath) last() svgPoint {
+	i := len(p.subpaths) - 1
+	if i < 0 {
+		p.subpaths = append(p.subpaths, []svgPoint{p.m.apply(0, 0)})
+		p.closed = append(p.closed, false)
+		return p.subpaths[0][0]
+	}
+	if p.closed[i] {
+		start := p.subpaths[i][0]
+		p.subpaths = append(p.subpaths, []svgPoint{start})
+		p.closed = append(p.closed, false)
+		return start
+	}
+	return p.subpaths[i][len(p.subpaths[i])-1]
+}
+
+func (p *svgPath) add(pt svgPoint) {
+	p.last()
+	i := len(p.subpaths) - 1
+	p.subpaths[i] = append(p.subpaths[i], pt)
+}
+
+func (p *svgPath) lineTo(x, y float64) {
+	p.add(p.m.apply(x, y))
+}
+
+func (p *svgPath) cubicTo(x1, y1, x2, y2, x, y float64) {
+	p0 := p.last()
+	p1, p2, p3 := p.m.apply(x1, y1), p.m.apply(x2, y2), p.m.apply(x, y)
+	// Choose the number of segments to keep the flattening error below 0.1 pixels.
+	l := math.Hypot(p1.x-p0.x, p1.y-p0.y) + math.Hypot(p2.x-p1.x, p2.y-p1.y) + math.Hypot(p3.x-p2.x, p3.y-p2.y)
+	n := int(math.Max(1, math.Min(100, math.Ceil(math.Sqrt(2*l)))))
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		u := 1 - t
+		a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+		p.add(svgPoint{a*p0.x + b*p1.x + c*p2.x + d*p3.x, a*p0.y + b*p1.y + c*p2.y + d*p3.y})
+	}
+}
+
+func (p *svgPath) close() {
+	if i := len(p.subpaths) - 1; i >= 0 {
+		p.closed[i] = true
+	}
+}
+
+// arcTo adds an elliptical arc from (x1, y1) to (x2, y2) as cubic curves (SVG 1.1, F.6.5).
+func (p *svgPath) arcTo(x1, y1, rx, ry, angle float64, large, sweep bool, x2, y2 float64) {
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if x1 == x2 && y1 == y2 {
+		return
+	}
+	if rx == 0 || ry == 0 {
+		p.lineTo(x2, y2)
+		return
+	}
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	dx, dy := (x1-x2)/2, (y1-y2)/2
+	x1p, y1p := cos*dx+sin*dy, -sin*dx+cos*dy
+
+	// Scale up the radii if the arc can't reach the end point.
+	if l := x1p*x1p/(rx*rx) + y1p*y1p/(ry*ry); l > 1 {
+		rx *= math.Sqrt(l)
+		ry *= math.Sqrt(l)
+	}
+	num := rx*rx*ry*ry - rx*rx*y1p*y1p - ry*ry*x1p*x1p
+	den := rx*rx*y1p*y1p + ry*ry*x1p*x1p
+	k := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		k = -k
+	}
+	cxp, cyp := k*rx*y1p/ry, -k*ry*x1p/rx
+	cx := cos*cxp - sin*cyp + (x1+x2)/2
+	cy := sin*cxp + cos*cyp + (y1+y2)/2
+
+	theta1 := math.Atan2((y1p-cyp)/ry, (x1p-cxp)/rx)
+	dtheta := math.Atan2((-y1p-cyp)/ry, (-x1p-cxp)/rx) - theta1
+	if sweep && dtheta < 0 {
+		dtheta += 2 * math.Pi
+	} else if !sweep && dtheta > 0 {
+		dtheta -= 2 * math.Pi
+	}
+
+	// Approximate each part of at most 90 degrees with a cubic curve.
+	n := int(math.Ceil(math.Abs(dtheta) / (math.Pi / 2)))
+	step := dtheta / float64(n)
+	t := 4.0 / 3 * math.Tan(step/4)
+	point := func(a float64) (float64, float64, float64, float64) {
+		sa, ca := math.Sincos(a)
+		x := cx + rx*ca*cos - ry*sa*sin
+		y := cy + rx*ca*sin + ry*sa*cos
+		// The derivative.
+		dx := -rx*sa*cos - ry*ca*sin
+		dy := -rx*sa*sin + ry*ca*cos
+		return x, y, dx, dy
+	}
+	a := theta1
+	xa, ya, dxa, dya := point(a)
+	for i := 0; i < n; i++ {
+		b := a + step
+		xb, yb, dxb, dyb := point(b)
+		if i == n-1 {
+			xb, yb = x2, y2
+		}
+		p.cubicTo(xa+t*dxa, ya+t*dya, xb-t*dxb, yb-t*dyb, xb, yb)
+		a, xa, ya, dxa, dya = b, xb, yb, dxb, dyb
+	}
+}
+
+// parse adds the path data commands. Parsing stops at the first error.
+func (p *svgPath) parse(d string) {
+	sc := svgScanner{s: d}
+	var cmd byte
+	var x, y, startX, startY, ctrlX, ctrlY float64
+	var prev byte
+	for {
+		sc.skipSeparators()
+		if sc.i >= len(sc.s) {
+			return
+		}
+		if c := sc.s[sc.i]; c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' {
+			cmd = c
+			sc.i++
+		} else if cmd == 0 {
+			return
+		}
+
+		rel := cmd >= 'a'
+		var ox, oy float64
+		if rel {
+			ox, oy = x, y
+		}
+		nums := func(n int) ([]float64, bool) {
+			v := make([]float64, n)
+			for i := range v {
+				var ok bool
+				if v[i], ok = sc.number(); !ok {
+					return nil, false
+				}
+			}
+			return v, true
+		}
+
+		switch cmd | 0x20 {
+		case 'z':
+			p.close()
+			x, y = startX, startY
+			prev = 'z'
+			// A command letter must follow.
+			cmd = 0
+			continue
+		case 'm':
+			v, ok := nums(2)
+			if !ok {
+				return
+			}
+			x, y = ox+v[0], oy+v[1]
+			startX, startY = x, y
+			p.moveTo(x, y)
+			// The following coordinate pairs are line commands.
+			cmd = 'L' | cmd&0x20
+		case 'l':
+			v, ok := nums(2)
+			if !ok {
+				return
+			}
+			x, y = ox+v[0], oy+v[1]
+			p.lineTo(x, y)
+		case 'h':
+			v, ok := nums(1)
+			if !ok {
+				return
+			}
+			x = ox + v[0]
+			p.lineTo(x, y)
+		case 'v':
+			v, ok := nums(1)
+			if !ok {
+				return
+			}
+			y = oy + v[0]
+			p.lineTo(x, y)
+		case 'c', 's':
+			var x1, y1, x2, y2 float64
+			if cmd|0x20 == 'c' {
+				v, ok := nums(6)
+				if !ok {
+					return
+				}
+				x1, y1, x2, y2, x, y = ox+v[0], oy+v[1], ox+v[2], oy+v[3], ox+v[4], oy+v[5]
+			} else {
+				v, ok := nums(4)
+				if !ok {
+					return
+				}
+				// The first control point is the reflection of the previous one.
+				x1, y1 = x, y
+				if prev == 'c' || prev == 's' {
+					x1, y1 = 2*x-ctrlX, 2*y-ctrlY
+				}
+				x2, y2, x, y = ox+v[0], oy+v[1], ox+v[2], oy+v[3]
+			}
+			p.cubicTo(x1, y1, x2, y2, x, y)
+			ctrlX, ctrlY = x2, y2
+		case 'q', 't':
+			var qx, qy float64
+			x0, y0 := x, y
+			if cmd|0x20 == 'q' {
+				v, ok := nums(4)
+				if !ok {
+					return
+				}
+				qx, qy, x, y = ox+v[0], oy+v[1], ox+v[2], oy+v[3]
+			} else {
+				v, ok := nums(2)
+				if !ok {
+					return
+				}
+				qx, qy = x, y
+				if prev == 'q' || prev == 't' {
+					qx, qy = 2*x-ctrlX, 2*y-ctrlY
+				}
+				x, y = ox+v[0], oy+v[1]
+			}
+			// Elevate the quadratic curve to a cubic one.
+			p.cubicTo(x0+2*(qx-x0)/3, y0+2*(qy-y0)/3, x+2*(qx-x)/3, y+2*(qy-y)/3, x, y)
+			ctrlX, ctrlY = qx, qy
+		case 'a':
+			v, ok := nums(3)
+			if !ok {
+				return
+			}
+			large, ok1 := sc.flag()
+			sweep, ok2 := sc.flag()
+			end, ok3 := nums(2)
+			if !ok1 || !ok2 || !ok3 {
+				return
+			}
+			x0, y0 := x, y
+			x, y = ox+end[0], oy+end[1]
+			p.arcTo(x0, y0, v[0], v[1], v[2], large, sweep, x, y)
+		default:
+			return
+		}
+		prev = cmd | 0x20
+	}
+}
+
+// strokeSVGPath returns the polygons covering the stroke of the path with the given half width.
+// All the polygons have the same orientation so they're combined with the nonzero rule.
+func strokeSVGPath(p *svgPath, hw float64, lineCap string) [][]svgPoint {
+	var polys [][]svgPoint
+	for i, sp := range p.subpaths {
+		pts := make([]svgPoint, 0, len(sp)+1)
+		for _, pt := range sp {
+			if len(pts) == 0 || pt != pts[len(pts)-1] {
+				pts = append(pts, pt)
+			}
+		}
+		closed := p.closed[i] && len(pts) > 2
+		if closed && pts[0] != pts[len(pts)-1] {
+			pts = append(pts, pts[0])
+		}
+		if len(pts) == 1 {
+			// A zero length subpath is painted with round and square caps only.
+			switch lineCap {
+			case "round":
+				polys = append(polys, svgCircle(pts[0], hw))
+			case "square":
+				c := pts[0]
+				polys = append(polys, []svgPoint{{c.x - hw, c.y - hw}, {c.x - hw, c.y + hw}, {c.x + hw, c.y + hw}, {c.x + hw, c.y - hw}})
+			}
+			continue
+		}
+
+		for j := 0; j+1 < len(pts); j++ {
+			a, b := pts[j], pts[j+1]
+			dx, dy := b.x-a.x, b.y-a.y
+			l := math.Hypot(dx, dy)
+			ux, uy := dx/l, dy/l
+			if !closed && lineCap == "square" {
+				if j == 0 {
+					a = svgPoint{a.x - ux*hw, a.y - uy*hw}
+				}
+				if j == len(pts)-2 {
+					b = svgPoint{b.x + ux*hw, b.y + uy*hw}
+				}
+			}
+			nx, ny := -uy*hw, ux*hw
+			polys = append(polys, []svgPoint{{a.x + nx, a.y + ny}, {b.x + nx, b.y + ny}, {b.x - nx, b.y - ny}, {a.x - nx, a.y - ny}})
+		}
+
+		// Round joins, skipped where the direction barely changes.
+		join := func(prev, pt, next svgPoint) {
+			a1 := math.Atan2(pt.y-prev.y, pt.x-prev.x)
+			a2 := math.Atan2(next.y-pt.y, next.x-pt.x)
+			turn := math.Abs(math.Remainder(a2-a1, 2*math.Pi))
+			if hw*turn > 0.1 {
+				polys = append(polys, svgCircle(pt, hw))
+			}
+		}
+		for j := 1; j+1 < len(pts); j++ {
+			join(pts[j-1], pts[j], pts[j+1])
+		}
+		if closed {
+			join(pts[len(pts)-2], pts[0], pts[1])
+		} else if lineCap == "round" {
+			polys = append(polys, svgCircle(pts[0], hw), svgCircle(pts[len(pts)-1], hw))
+		}
+	}
+	return polys
+}
+
+// svgCircle returns a polygon approximating the circle, oriented like the stroke segments.
+func svgCircle(c svgPoint, r float64) []svgPoint {
+	n := int(math.Max(8, math.Ceil(7*math.Sqrt(r))))
+	pts := make([]svgPoint, n)
+	for i := range pts {
+		sin, cos := math.Sincos(-2 * math.Pi * float64(i) / float64(n))
+		pts[i] = svgPoint{c.x + r*cos, c.y + r*sin}
+	}
+	return pts
+}
+
+// svgEdge is a non-horizontal polygon edge going down from (x0, y0) to (x1, y1).
+type svgEdge struct {
+	x0, y0, x1, y1 float64
+	dir            int // The winding direction: 1 if the edge originally went down, -1 otherwise.
+}
+
+// svgRasterizer computes the anti-aliased coverage of polygons using 16 sample rows
+// per pixel and the exact horizontal coverage of the spans.
+type svgRasterizer struct {
+	w, h  int
+	cov   []float32
+	edges []svgEdge
+}
+
+func (z *svgRasterizer) init(w, h int) {
+	z.w, z.h = w, h
+	z.cov = make([]float32, w*h)
+}
+
+// fill paints the polygons with the color over dst using the nonzero or even-odd rule.
+func (z *svgRasterizer) fill(dst *image.RGBA, polys [][]svgPoint, evenOdd bool, c color.NRGBA) {
+	const samples = 16
+	if c.A == 0 {
+		return
+	}
+
+	z.edges = z.edges[:0]
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	for _, poly := range polys {
+		for i, a := range poly {
+			b := poly[(i+1)%len(poly)]
+			minX, maxX = math.Min(minX, a.x), math.Max(maxX, a.x)
+			if a.y == b.y || math.IsNaN(a.y) || math.IsNaN(b.y) {
+				continue
+			}
+			if a.y < b.y {
+				z.edges = append(z.edges, svgEdge{a.x, a.y, b.x, b.y, 1})
+			} else {
+				z.edges = append(z.edges, svgEdge{b.x, b.y, a.x, a.y, -1})
+			}
+			minY, maxY = math.Min(minY, math.Min(a.y, b.y)), math.Max(maxY, math.Max(a.y, b.y))
+		}
+	}
+	if len(z.edges) == 0 {
+		return
+	}
+	y0 := int(math.Max(0, math.Floor(minY)))
+	y1 := int(math.Min(float64(z.h), math.Ceil(maxY)))
+	x0 := int(math.Max(0, math.Floor(minX)))
+	x1 := int(math.Min(float64(z.w), math.Ceil(maxX)))
+	if y0 >= y1 || x0 >= x1 {
+		return
+	}
+	sort.Slice(z.edges, func(i, j int) bool { return z.edges[i].y0 < z.edges[j].y0 })
+
+	type crossing struct {
+		x   float64
+		dir int
+	}
+	var active []svgEdge
+	var xs []crossing
+	next := 0
+	for y := y0; y < y1; y++ {
+		for next < len(z.edges) && z.edges[next].y0 < float64(y+1) {
+			active = append(active, z.edges[next])
+			next++
+		}
+		k := 0
+		for _, e := range active {
+			if e.y1 > float64(y) {
+				active[k] = e
+				k++
+			}
+		}
+		active = active[:k]
+
+		row := z.cov[y*z.w : (y+1)*z.w]
+		for s := 0; s < samples; s++ {
+			sy := float64(y) + (float64(s)+0.5)/samples
+			xs = xs[:0]
+			for _, e := range active {
+				if e.y0 <= sy && sy < e.y1 {
+					xs = append(xs, crossing{e.x0 + (sy-e.y0)*(e.x1-e.x0)/(e.y1-e.y0), e.dir})
+				}
+			}
+			sort.Slice(xs, func(i, j int) bool { return xs[i].x < xs[j].x })
+			winding := 0
+			for i, cr := range xs {
+				winding += cr.dir
+				inside := winding != 0
+				if evenOdd {
+					inside = winding%2 != 0
+				}
+				if inside && i+1 < len(xs) {
+					svgAddSpan(row, cr.x, xs[i+1].x, 1.0/samples)
+				}
+			}
+		}
+	}
+
+	// Composite the color over the covered pixels and clear the coverage.
+	r, g, b, a := float64(c.R), float64(c.G), float64(c.B), float64(c.A)/255
+	for y := y0; y < y1; y++ {
+		row := z.cov[y*z.w : (y+1)*z.w]
+		for x := x0; x < x1; x++ {
+			v := float64(row[x])
+			if v <= 0 {
+				continue
+			}
+			row[x] = 0
+			ca := math.Min(v, 1) * a
+			i := y*dst.Stride + x*4
+			d := dst.Pix[i : i+4 : i+4]
+			d[0] = clamp(r*ca + float64(d[0])*(1-ca))
+			d[1] = clamp(g*ca + float64(d[1])*(1-ca))
+			d[2] = clamp(b*ca + float64(d[2])*(1-ca))
+			d[3] = clamp(255*ca + float64(d[3])*(1-ca))
+		}
+	}
+}
+
+// svgAddSpan adds the coverage of the horizontal span from xa to xb to the row.
+func svgAddSpan(row []float32, xa, xb float64, weight float32) {
+	xa = math.Max(xa, 0)
+	xb = math.Min(xb, float64(len(row)))
+	if xa >= xb {
+		return
+	}
+	ia, ib := int(xa), int(xb)
+	if ia == ib {
+		row[ia] += float32(xb-xa) * weight
+		return
+	}
+	row[ia] += float32(float64(ia+1)-xa) * weight
+	for i := ia + 1; i < ib; i++ {
+		row[i] += weight
+	}
+	if ib < len(row) {
+		row[ib] += float32(xb-float64(ib)) * weight
+	}
+}
@@ -0,0 +1,351 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncodeSVG(t *testing.T) {
+	src := New(3, 3, color.White)
+	src.SetNRGBA(1, 1, color.NRGBA{0x00, 0x00, 0x00, 0xff})
+
+	var buf bytes.Buffer
+	if err := EncodeSVG(&buf, src, 2); err != nil {
+		t.Fatalf("EncodeSVG: %v", err)
+	}
+	want := `<svg xmlns="http://www.w3.org/2000/svg" width="3" height="3" viewBox="0 0 3 3" shape-rendering="crispEdges">
+<path fill="#ffffff" fill-rule="evenodd" d="M0 0L3 0L3 3L0 3ZM1 1L1 2L2 2L2 1Z"/>
+<path fill="#000000" fill-rule="evenodd" d="M1 1L2 1L2 2L1 2Z"/>
+</svg>
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got %s want %s", got, want)
+	}
+}
+
+func TestEncodeSVGTransparent(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 3, 1),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0xff, 0x00, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0xff, 0x00, 0x00, 0x80,
+		},
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, src, SVG, SVGNumColors(4)); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	got := buf.String()
+	if n := strings.Count(got, "<path"); n != 1 {
+		t.Fatalf("got %d paths want 1: %s", n, got)
+	}
+	if !strings.Contains(got, `fill="#ff0000" fill-opacity="0.502"`) {
+		t.Fatalf("missing translucent red path: %s", got)
+	}
+}
+
+func BenchmarkEncodeSVG(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		EncodeSVG(&bytes.Buffer{}, testdataFlowersSmallPNG, 8)
+	}
+}
+
+func TestDecodeSVGRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeSVG(&buf, testdataFlowersSmallPNG, 8); err != nil {
+		t.Fatalf("EncodeSVG: %v", err)
+	}
+	got, err := Decode(&buf, SVGDecoding(true))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	want := Clone(Dither(testdataFlowersSmallPNG, Quantize(testdataFlowersSmallPNG, 8, QuantizeMedianCut), DitherNone))
+	if !compareNRGBA(Clone(got), want, 0) {
+		t.Fatal("the decoded image differs from the posterized source")
+	}
+}
+
+func TestDecodeSVGShapes(t *testing.T) {
+	const doc = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd">
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="40" height="20" viewBox="0 0 80 40">
+  <defs>
+    <linearGradient id="grad"><stop offset="0" stop-color="#ff0000"/><stop offset="1" stop-color="#0000ff"/></linearGradient>
+    <rect id="box" width="8" height="8" fill="lime"/>
+  </defs>
+  <title>Test</title>
+  <rect width="80" height="40" fill="white"/>
+  <circle cx="10" cy="10" r="8" style="fill: rgb(0, 0, 255)"/>
+  <path d="M20,2h16v16h-16zM24,6v8h8v-8z" fill="red" fill-rule="evenodd"/>
+  <g transform="translate(40 0)" fill="#000">
+    <polygon points="2,2 18,2 18,18 2,18"/>
+    <rect x="4" y="4" width="12" height="12" fill="none" stroke="yellow" stroke-width="4"/>
+  </g>
+  <use xlink:href="#box" x="62" y="6"/>
+  <rect x="0" y="24" width="80" height="16" fill="url(#grad)"/>
+  <rect x="0" y="24" width="20" height="16" fill="black" display="none"/>
+  <text x="0" y="30">ignored</text>
+</svg>`
+	img, err := Decode(strings.NewReader(doc), SVGDecoding(true))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	got := Clone(img)
+	if got.Bounds() != image.Rect(0, 0, 40, 20) {
+		t.Fatalf("got bounds %v want 40x20", got.Bounds())
+	}
+	testCases := []struct {
+		name string
+		x, y int
+		want color.NRGBA
+	}{
+		{"background", 0, 0, color.NRGBA{0xff, 0xff, 0xff, 0xff}},
+		{"circle", 5, 5, color.NRGBA{0x00, 0x00, 0xff, 0xff}},
+		{"even-odd ring", 11, 2, color.NRGBA{0xff, 0x00, 0x00, 0xff}},
+		{"even-odd hole", 14, 5, color.NRGBA{0xff, 0xff, 0xff, 0xff}},
+		{"stroke", 22, 5, color.NRGBA{0xff, 0xff, 0x00, 0xff}},
+		{"polygon inside the stroke", 25, 5, color.NRGBA{0x00, 0x00, 0x00, 0xff}},
+		{"use", 32, 4, color.NRGBA{0x00, 0xff, 0x00, 0xff}},
+		{"gradient", 2, 15, color.NRGBA{0x80, 0x00, 0x80, 0xff}},
+	}
+	for _, tc := range testCases {
+		if c := got.NRGBAAt(tc.x, tc.y); !compareNRGBA(
+			&image.NRGBA{Pix: []uint8{c.R, c.G, c.B, c.A}, Stride: 4, Rect: image.Rect(0, 0, 1, 1)},
+			&image.NRGBA{Pix: []uint8{tc.want.R, tc.want.G, tc.want.B, tc.want.A}, Stride: 4, Rect: image.Rect(0, 0, 1, 1)}, 1) {
+			t.Errorf("%s: got %v at (%d, %d) want %v", tc.name, c, tc.x, tc.y, tc.want)
+		}
+	}
+}
+
+func TestDecodeSVGSize(t *testing.T) {
+	testCases := []struct {
+		name string
+		doc  string
+		opts []DecodeOption
+		want image.Rectangle
+	}{
+		{"width and height", `<svg width="30" height="20"/>`, nil, image.Rect(0, 0, 30, 20)},
+		{"units", `<svg width="1in" height="36pt"/>`, nil, image.Rect(0, 0, 96, 48)},
+		{"view box", `<svg viewBox="0 0 64 32"/>`, nil, image.Rect(0, 0, 64, 32)},
+		{"width and view box", `<svg width="32" viewBox="0 0 64 32"/>`, nil, image.Rect(0, 0, 32, 16)},
+		{"percentages", `<svg width="100%" height="100%" viewBox="0 0 64 32"/>`, nil, image.Rect(0, 0, 64, 32)},
+		{"default", `<svg/>`, nil, image.Rect(0, 0, 300, 150)},
+		{"dpi", `<svg width="30" height="20"/>`, []DecodeOption{SVGDPI(192)}, image.Rect(0, 0, 60, 40)},
+		{"size", `<svg width="30" height="20"/>`, []DecodeOption{SVGSize(50, 10)}, image.Rect(0, 0, 50, 10)},
+		{"width only", `<svg width="30" height="20"/>`, []DecodeOption{SVGSize(60, 0)}, image.Rect(0, 0, 60, 40)},
+		{"height only", `<svg width="30" height="20"/>`, []DecodeOption{SVGSize(0, 10)}, image.Rect(0, 0, 15, 10)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := Decode(strings.NewReader(tc.doc), append([]DecodeOption{SVGDecoding(true)}, tc.opts...)...)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if img.Bounds() != tc.want {
+				t.Fatalf("got bounds %v want %v", img.Bounds(), tc.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSVGViewBox(t *testing.T) {
+	// The square view box is centered in the wide image.
+	doc := `<svg width="30" height="10" viewBox="0 0 10 10"><rect width="10" height="10" fill="red"/></svg>`
+	img, err := Decode(strings.NewReader(doc), SVGDecoding(true))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	got := Clone(img)
+	if c := got.NRGBAAt(5, 5); c.A != 0 {
+		t.Fatalf("got %v left of the view box want transparent", c)
+	}
+	if c := got.NRGBAAt(15, 5); c != (color.NRGBA{0xff, 0x00, 0x00, 0xff}) {
+		t.Fatalf("got %v inside the view box want red", c)
+	}
+
+	doc = `<svg width="30" height="10" viewBox="0 0 10 10" preserveAspectRatio="none"><rect width="10" height="10" fill="red"/></svg>`
+	img, err = Decode(strings.NewReader(doc), SVGDecoding(true))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if c := Clone(img).NRGBAAt(1, 1); c != (color.NRGBA{0xff, 0x00, 0x00, 0xff}) {
+		t.Fatalf("got %v want red in the stretched view box", c)
+	}
+}
+
+func TestDecodeSVGFails(t *testing.T) {
+	for _, doc := range []string{
+		`<svg width="10" height="10"><rect></svg`,
+		`<html><svg/></html>`,
+		`<svg width="-5" height="10"/>`,
+		`<svg width="100000" height="100000"/>`,
+	} {
+		if _, err := Decode(strings.NewReader(doc), SVGDecoding(true)); err == nil {
+			t.Errorf("Decode(%q): expected error got nil", doc)
+		}
+	}
+}
+
+func TestDecodeSVGDisabled(t *testing.T) {
+	doc := `<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"/>`
+	if _, err := Decode(strings.NewReader(doc)); err != image.ErrFormat {
+		t.Fatalf("got error %v want %v", err, image.ErrFormat)
+	}
+}
+
+func TestDecodeSVGUseLimits(t *testing.T) {
+	// Each level uses the previous one 4 times, 4^12 rectangles in total.
+	var sb strings.Builder
+	sb.WriteString(`<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10"><defs>`)
+	sb.WriteString(`<rect id="l0" width="1" height="1"/>`)
+	for i := 1; i <= 12; i++ {
+		fmt.Fprintf(&sb, `<g id="l%d">`, i)
+		for j := 0; j < 4; j++ {
+			fmt.Fprintf(&sb, `<use href="#l%d"/>`, i-1)
+		}
+		sb.WriteString(`</g>`)
+	}
+	sb.WriteString(`</defs><use href="#l12"/></svg>`)
+	if _, err := Decode(strings.NewReader(sb.String()), SVGDecoding(true)); err != errSVGTooComplex {
+		t.Fatalf("nested use: got error %v want %v", err, errSVGTooComplex)
+	}
+
+	for _, doc := range []string{
+		`<svg width="10" height="10"><g id="a"><use href="#a"/></g></svg>`,
+		`<svg width="10" height="10"><g id="a"><use href="#b"/></g><g id="b"><use href="#a"/></g></svg>`,
+		`<svg width="10" height="10"><symbol id="a"><use href="#a"/></symbol><use href="#a"/></svg>`,
+	} {
+		if _, err := Decode(strings.NewReader(doc), SVGDecoding(true)); err != errSVGUseCycle {
+			t.Errorf("Decode(%q): got error %v want %v", doc, err, errSVGUseCycle)
+		}
+	}
+
+	// The same element used twice side by side is not a cycle.
+	doc := `<svg width="10" height="10"><rect id="a" width="2" height="2"/><use href="#a" x="4"/><use href="#a" x="8"/></svg>`
+	img, err := Decode(strings.NewReader(doc), SVGDecoding(true))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if c := Clone(img).NRGBAAt(9, 1); c != (color.NRGBA{0, 0, 0, 255}) {
+		t.Fatalf("got color %v want black", c)
+	}
+}
+
+func TestParseSVGColor(t *testing.T) {
+	current := color.NRGBA{1, 2, 3, 0xff}
+	testCases := []struct {
+		s    string
+		want svgPaint
+		ok   bool
+	}{
+		{"#f00", svgPaint{color.NRGBA{0xff, 0, 0, 0xff}, true}, true},
+		{"#12345678", svgPaint{color.NRGBA{0x12, 0x34, 0x56, 0x78}, true}, true},
+		{"#ABCDEF", svgPaint{color.NRGBA{0xab, 0xcd, 0xef, 0xff}, true}, true},
+		{"rgb(10, 20, 30)", svgPaint{color.NRGBA{10, 20, 30, 0xff}, true}, true},
+		{"rgba(100%,0%,50%,0.5)", svgPaint{color.NRGBA{0xff, 0, 0x80, 0x80}, true}, true},
+		{"SteelBlue", svgPaint{color.NRGBA{0x46, 0x82, 0xb4, 0xff}, true}, true},
+		{"currentColor", svgPaint{current, true}, true},
+		{"none", svgPaint{}, true},
+		{"#12", svgPaint{}, false},
+		{"rgb(1,2)", svgPaint{}, false},
+		{"nocolor", svgPaint{}, false},
+	}
+	for _, tc := range testCases {
+		got, ok := parseSVGColor(tc.s, current)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("parseSVGColor(%q): got %v, %v want %v, %v", tc.s, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestParseSVGPath(t *testing.T) {
+	p := &svgPath{m: svgIdentity}
+	p.parse("M1,2L3-4.5.5 10e-1H0V-1zm1 1l1 0 0 1Zh2")
+	want := [][]svgPoint{
+		{{1, 2}, {3, -4.5}, {0.5, 1}, {0, 1}, {0, -1}},
+		{{2, 3}, {3, 3}, {3, 4}},
+		{{2, 3}, {4, 3}},
+	}
+	if len(p.subpaths) != len(want) {
+		t.Fatalf("got subpaths %v want %v", p.subpaths, want)
+	}
+	for i := range want {
+		if len(p.subpaths[i]) != len(want[i]) {
+			t.Fatalf("got subpaths %v want %v", p.subpaths, want)
+		}
+		for j := range want[i] {
+			if math.Abs(p.subpaths[i][j].x-want[i][j].x) > 1e-9 || math.Abs(p.subpaths[i][j].y-want[i][j].y) > 1e-9 {
+				t.Fatalf("got subpaths %v want %v", p.subpaths, want)
+			}
+		}
+	}
+	if !p.closed[0] || !p.closed[1] || p.closed[2] {
+		t.Fatalf("got closed flags %v", p.closed)
+	}
+
+	// An arc with flags not separated from the numbers ends exactly at its end point.
+	p = &svgPath{m: svgIdentity}
+	p.parse("M0 0a5 5 0 015 5q5 5 10 0t5 5c1 1 2 2 3 3s4 4 5 5 bad")
+	last := p.subpaths[0][len(p.subpaths[0])-1]
+	if math.Abs(last.x-28) > 1e-9 || math.Abs(last.y-18) > 1e-9 {
+		t.Fatalf("got end point %v want (28, 18)", last)
+	}
+}
+
+func TestParseSVGTransform(t *testing.T) {
+	testCases := []struct {
+		s    string
+		x, y float64
+	}{
+		{"translate(10)", 11, 1},
+		{"translate(10, 20) scale(2)", 12, 22},
+		{"scale(2 3)", 2, 3},
+		{"rotate(90)", -1, 1},
+		{"rotate(180 1 0)", 1, -1},
+		{"matrix(1 0 0 1 5 6)", 6, 7},
+		{"skewX(45)", 2, 1},
+		{"skewY(45)", 1, 2},
+		{"unknown(1) translate(1 1)", 2, 2},
+	}
+	for _, tc := range testCases {
+		got := parseSVGTransform(tc.s).apply(1, 1)
+		if math.Abs(got.x-tc.x) > 1e-9 || math.Abs(got.y-tc.y) > 1e-9 {
+			t.Errorf("%s: got %v want (%v, %v)", tc.s, got, tc.x, tc.y)
+		}
+	}
+}
+
+func TestOpenSVG(t *testing.T) {
+	dir, err := os.MkdirTemp("", "imaging")
+	if err != nil {
+		t.Fatalf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "logo.svg")
+	doc := "\xef\xbb\xbf\n<svg width=\"8\" height=\"4\"><line x1=\"0\" y1=\"2\" x2=\"8\" y2=\"2\" stroke=\"blue\" stroke-width=\"2\"/></svg>"
+	if err := os.WriteFile(filename, []byte(doc), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	img, err := Open(filename, SVGDecoding(true), SVGSize(16, 0))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got := Clone(img)
+	if got.Bounds() != image.Rect(0, 0, 16, 8) {
+		t.Fatalf("got bounds %v want 16x8", got.Bounds())
+	}
+	if c := got.NRGBAAt(8, 3); c != (color.NRGBA{0, 0, 0xff, 0xff}) {
+		t.Fatalf("got %v on the line want blue", c)
+	}
+	if c := got.NRGBAAt(8, 0); c.A != 0 {
+		t.Fatalf("got %v off the line want transparent", c)
+	}
+}
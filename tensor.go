@@ -0,0 +1,207 @@
+package imaging
+
+import (
+	"errors"
+	"image"
+)
+
+// ErrInvalidTensor means the tensor data doesn't match the image size and channels.
+var ErrInvalidTensor = errors.New("imaging: invalid tensor size")
+
+// TensorLayout specifies the order of the dimensions of a tensor.
+type TensorLayout int
+
+// Tensor layouts.
+const (
+	// HWC is the interleaved layout: rows, columns, channels,
+	// as used by TensorFlow and most image libraries.
+	HWC TensorLayout = iota
+
+	// CHW is the planar layout: channels, rows, columns, as used by PyTorch and ONNX models.
+	CHW
+)
+
+// TensorOption sets an optional parameter for the ToTensor and FromTensor functions.
+type TensorOption func(*tensorConfig)
+
+type tensorConfig struct {
+	channels  int
+	mean, std [4]float32
+	bgr       bool
+}
+
+func newTensorConfig(opts []TensorOption) tensorConfig {
+	cfg := tensorConfig{
+		channels: 3,
+		std:      [4]float32{1, 1, 1, 1},
+	}
+	for _, option := range opts {
+		option(&cfg)
+	}
+	return cfg
+}
+
+// TensorChannels returns a TensorOption that sets the number of channels of the tensor:
+// 1 for the grayscale luminance, 3 for the red, green and blue channels and 4 to add
+// the alpha channel. Default is 3.
+func TensorChannels(n int) TensorOption {
+	return func(c *tensorConfig) {
+		if n == 1 || n == 3 || n == 4 {
+			c.channels = n
+		}
+	}
+}
+
+// TensorNormalize returns a TensorOption that normalizes the channel values of the tensor:
+// the values in the range [0, 1] are mapped to (v - mean) / std. The mean and standard
+// deviation are given per channel of the tensor, a single value is used for all the
+// channels. By default the tensor values are in the range [0, 1].
+//
+// Example:
+//
+//	// The ImageNet normalization.
+//	opt := imaging.TensorNormalize([]float32{0.485, 0.456, 0.406}, []float32{0.229, 0.224, 0.225})
+//	// The range [-1, 1].
+//	opt = imaging.TensorNormalize([]float32{0.5}, []float32{0.5})
+func TensorNormalize(mean, std []float32) TensorOption {
+	return func(c *tensorConfig) {
+		for i := range c.mean {
+			switch {
+			case len(mean) == 1:
+				c.mean[i] = mean[0]
+			case i < len(mean):
+				c.mean[i] = mean[i]
+			}
+			switch {
+			case len(std) == 1 && std[0] != 0:
+				c.std[i] = std[0]
+			case i < len(std) && std[i] != 0:
+				c.std[i] = std[i]
+			}
+		}
+	}
+}
+
+// TensorBGR returns a TensorOption that sets the order of the color channels of the tensor
+// to blue, green, red, as expected by the models trained with OpenCV. By default it's
+// red, green, blue.
+func TensorBGR(enabled bool) TensorOption {
+	return func(c *tensorConfig) {
+		c.bgr = enabled
+	}
+}
+
+// ToTensor converts the image to a float32 tensor of the given layout with the dimensions
+// height, width and the channels set by TensorChannels, by default 3. The color channels
+// aren't premultiplied by alpha.
+//
+// Example:
+//
+//	img = imaging.Fill(img, 224, 224, imaging.Center, imaging.Lanczos)
+//	data := imaging.ToTensor(img, imaging.CHW,
+//		imaging.TensorNormalize([]float32{0.485, 0.456, 0.406}, []float32{0.229, 0.224, 0.225}),
+//	)
+func ToTensor(img image.Image, layout TensorLayout, opts ...TensorOption) []float32 {
+	cfg := newTensorConfig(opts)
+	src := newScanner(img)
+	n := cfg.channels
+	data := make([]float32, src.w*src.h*n)
+	if len(data) == 0 {
+		return data
+	}
+
+	// The tensor value of each channel value.
+	var lut [4][256]float32
+	for c := 0; c < n; c++ {
+		for v := range lut[c] {
+			lut[c][v] = (float32(v)/255 - cfg.mean[c]) / cfg.std[c]
+		}
+	}
+	order := cfg.channelOrder()
+	plane := src.w * src.h
+
+	parallel(0, src.h, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		var pix [4]uint8
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			for x := 0; x < src.w; x++ {
+				s := scanLine[x*4 : x*4+4 : x*4+4]
+				if n == 1 {
+					f := 0.299*float64(s[0]) + 0.587*float64(s[1]) + 0.114*float64(s[2])
+					pix[0] = uint8(f + 0.5)
+				} else {
+					for c := 0; c < n; c++ {
+						pix[c] = s[order[c]]
+					}
+				}
+				if layout == CHW {
+					i := y*src.w + x
+					for c := 0; c < n; c++ {
+						data[c*plane+i] = lut[c][pix[c]]
+					}
+				} else {
+					i := (y*src.w + x) * n
+					for c := 0; c < n; c++ {
+						data[i+c] = lut[c][pix[c]]
+					}
+				}
+			}
+		}
+	})
+	return data
+}
+
+// FromTensor converts the float32 tensor of the given layout with the dimensions height,
+// width and the channels set by TensorChannels back to an image, reverting the
+// normalization set by TensorNormalize. The values are rounded and clamped. It returns
+// ErrInvalidTensor if the length of the data doesn't match the dimensions.
+//
+// Example:
+//
+//	// The model outputs an RGB image in the range [-1, 1].
+//	img, err := imaging.FromTensor(output, 512, 512, imaging.CHW,
+//		imaging.TensorNormalize([]float32{0.5}, []float32{0.5}),
+//	)
+func FromTensor(data []float32, width, height int, layout TensorLayout, opts ...TensorOption) (*image.NRGBA, error) {
+	cfg := newTensorConfig(opts)
+	n := cfg.channels
+	if width < 0 || height < 0 || len(data) != width*height*n {
+		return nil, ErrInvalidTensor
+	}
+	dst := newNRGBA(image.Rect(0, 0, width, height))
+	order := cfg.channelOrder()
+	plane := width * height
+
+	parallel(0, height, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < width; x++ {
+				d := dst.Pix[y*dst.Stride+x*4 : y*dst.Stride+x*4+4 : y*dst.Stride+x*4+4]
+				d[3] = 0xff
+				for c := 0; c < n; c++ {
+					var v float32
+					if layout == CHW {
+						v = data[c*plane+y*width+x]
+					} else {
+						v = data[(y*width+x)*n+c]
+					}
+					u := clamp(float64((v*cfg.std[c] + cfg.mean[c]) * 255))
+					if n == 1 {
+						d[0], d[1], d[2] = u, u, u
+					} else {
+						d[order[c]] = u
+					}
+				}
+			}
+		}
+	})
+	return dst, nil
+}
+
+// channelOrder returns the index of the NRGBA channel of each tensor channel.
+func (cfg tensorConfig) channelOrder() [4]int {
+	if cfg.bgr {
+		return [4]int{2, 1, 0, 3}
+	}
+	return [4]int{0, 1, 2, 3}
+}
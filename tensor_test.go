@@ -0,0 +1,120 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestToTensor(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 1),
+		Stride: 8,
+		Pix:    []uint8{0xff, 0x00, 0x33, 0x80, 0x00, 0x66, 0xcc, 0xff},
+	}
+	r, g, b := float32(1), float32(0), float32(0.2)
+	r2, g2, b2 := float32(0), float32(0.4), float32(0.8)
+	a, a2 := float32(0x80)/255, float32(1)
+	gray := float32(clamp(0.299*0xff+0.114*0x33)) / 255
+	gray2 := float32(clamp(0.587*0x66+0.114*0xcc)) / 255
+	testCases := []struct {
+		name   string
+		layout TensorLayout
+		opts   []TensorOption
+		want   []float32
+	}{
+		{"HWC", HWC, nil, []float32{r, g, b, r2, g2, b2}},
+		{"CHW", CHW, nil, []float32{r, r2, g, g2, b, b2}},
+		{"HWC RGBA", HWC, []TensorOption{TensorChannels(4)}, []float32{r, g, b, a, r2, g2, b2, a2}},
+		{"CHW RGBA", CHW, []TensorOption{TensorChannels(4)}, []float32{r, r2, g, g2, b, b2, a, a2}},
+		{"gray", CHW, []TensorOption{TensorChannels(1)}, []float32{gray, gray2}},
+		{"invalid channels", HWC, []TensorOption{TensorChannels(2)}, []float32{r, g, b, r2, g2, b2}},
+		{"BGR", HWC, []TensorOption{TensorBGR(true)}, []float32{b, g, r, b2, g2, r2}},
+		{
+			"normalize",
+			HWC,
+			[]TensorOption{TensorNormalize([]float32{0.5}, []float32{0.5})},
+			[]float32{1, -1, -0.6, -1, -0.2, 0.6},
+		},
+		{
+			"normalize per channel",
+			CHW,
+			[]TensorOption{TensorBGR(true), TensorNormalize([]float32{0.1, 0.2, 0.3}, []float32{0.5, 2, 0})},
+			[]float32{(b - 0.1) / 0.5, (b2 - 0.1) / 0.5, (g - 0.2) / 2, (g2 - 0.2) / 2, r - 0.3, r2 - 0.3},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ToTensor(src, tc.layout, tc.opts...)
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %v want %v", got, tc.want)
+			}
+			for i := range got {
+				if math.Abs(float64(got[i]-tc.want[i])) > 1e-6 {
+					t.Fatalf("got %v want %v", got, tc.want)
+				}
+			}
+		})
+	}
+
+	if got := ToTensor(&image.NRGBA{}, HWC); len(got) != 0 {
+		t.Fatalf("got %v want empty", got)
+	}
+}
+
+func TestFromTensor(t *testing.T) {
+	img := testdataFlowersSmallPNG
+	imagenet := TensorNormalize([]float32{0.485, 0.456, 0.406}, []float32{0.229, 0.224, 0.225})
+	testCases := []struct {
+		name string
+		opts []TensorOption
+		want *image.NRGBA
+	}{
+		{"RGB", nil, Clone(img)},
+		{"RGBA", []TensorOption{TensorChannels(4)}, Clone(img)},
+		{"normalized BGR", []TensorOption{TensorBGR(true), imagenet}, Clone(img)},
+		{"gray", []TensorOption{TensorChannels(1)}, Grayscale(img)},
+	}
+	for _, tc := range testCases {
+		for _, layout := range []TensorLayout{HWC, CHW} {
+			data := ToTensor(img, layout, tc.opts...)
+			got, err := FromTensor(data, 240, 160, layout, tc.opts...)
+			if err != nil {
+				t.Fatalf("%s: FromTensor: %v", tc.name, err)
+			}
+			want := tc.want
+			if len(tc.opts) == 0 || tc.name == "normalized BGR" {
+				// The alpha channel is opaque.
+				want = AdjustFunc(want, func(c color.NRGBA) color.NRGBA {
+					c.A = 0xff
+					return c
+				})
+			}
+			if !compareNRGBA(got, want, 0) {
+				t.Fatalf("%s: the image isn't restored", tc.name)
+			}
+		}
+	}
+
+	for _, size := range [][2]int{{2, 3}, {5, 1}, {-1, -4}} {
+		if _, err := FromTensor(make([]float32, 12), size[0], size[1], HWC); err != ErrInvalidTensor {
+			t.Fatalf("FromTensor %v: got error %v want %v", size, err, ErrInvalidTensor)
+		}
+	}
+
+	got, err := FromTensor([]float32{-0.5, 0.5, 1.5}, 1, 1, HWC)
+	if err != nil {
+		t.Fatalf("FromTensor: %v", err)
+	}
+	if c := got.NRGBAAt(0, 0); c != (color.NRGBA{0, 0x80, 0xff, 0xff}) {
+		t.Fatalf("got %v want the values clamped", c)
+	}
+}
+
+func BenchmarkToTensor(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ToTensor(testdataBranchesJPG, CHW)
+	}
+}
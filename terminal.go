@@ -0,0 +1,146 @@
+package imaging
+
+import (
+	"bufio"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"strconv"
+)
+
+// TerminalMode specifies how an image is drawn with text characters by EncodeTerminal.
+type TerminalMode int
+
+// Terminal drawing modes.
+const (
+	// TerminalHalfBlock draws two vertically stacked pixels per character cell using
+	// the upper half block character with 24-bit foreground and background colors.
+	TerminalHalfBlock TerminalMode = iota
+
+	// TerminalBraille draws a 2x4 dot matrix per character cell using the Unicode
+	// braille patterns. Dots are chosen by dithering, each cell gets a single
+	// 24-bit foreground color.
+	TerminalBraille
+)
+
+// EncodeTerminal writes a preview of the image to w as text that is cols characters wide
+// using ANSI 24-bit color escape sequences, suitable for printing to a terminal.
+// The number of lines is chosen to preserve the aspect ratio of the image.
+// Fully transparent areas are left blank.
+//
+// Example:
+//
+//	err := imaging.EncodeTerminal(os.Stdout, srcImage, 80, imaging.TerminalHalfBlock)
+func EncodeTerminal(w io.Writer, img image.Image, cols int, mode TerminalMode) error {
+	srcW := img.Bounds().Dx()
+	srcH := img.Bounds().Dy()
+	if cols <= 0 || srcW <= 0 || srcH <= 0 {
+		return nil
+	}
+
+	bw := bufio.NewWriter(w)
+	switch mode {
+	case TerminalBraille:
+		writeBraille(bw, img, cols)
+	default:
+		writeHalfBlocks(bw, img, cols)
+	}
+	return bw.Flush()
+}
+
+// terminalSize returns the pixel size an image is resized to so that it is cols cells wide,
+// with each cell covering cellW x cellH pixels and cells being twice as tall as they are wide.
+func terminalSize(img image.Image, cols, cellW, cellH int) (int, int) {
+	w := cols * cellW
+	rows := float64(img.Bounds().Dy()) * float64(cols) / float64(img.Bounds().Dx()) / 2
+	h := int(math.Max(1, math.Floor(rows+0.5))) * cellH
+	return w, h
+}
+
+func writeANSIColor(bw *bufio.Writer, code string, r, g, b uint8) {
+	bw.WriteString("\x1b[")
+	bw.WriteString(code)
+	bw.WriteString(";2;")
+	bw.WriteString(strconv.Itoa(int(r)))
+	bw.WriteByte(';')
+	bw.WriteString(strconv.Itoa(int(g)))
+	bw.WriteByte(';')
+	bw.WriteString(strconv.Itoa(int(b)))
+	bw.WriteByte('m')
+}
+
+func writeHalfBlocks(bw *bufio.Writer, img image.Image, cols int) {
+	w, h := terminalSize(img, cols, 1, 2)
+	small := Resize(img, w, h, Box)
+
+	for y := 0; y < h; y += 2 {
+		for x := 0; x < w; x++ {
+			i := y*small.Stride + x*4
+			top := small.Pix[i : i+4 : i+4]
+			bottom := small.Pix[i+small.Stride : i+small.Stride+4 : i+small.Stride+4]
+			switch {
+			case top[3] == 0 && bottom[3] == 0:
+				bw.WriteString("\x1b[0m ")
+			case bottom[3] == 0:
+				bw.WriteString("\x1b[0m")
+				writeANSIColor(bw, "38", top[0], top[1], top[2])
+				bw.WriteString("▀")
+			case top[3] == 0:
+				bw.WriteString("\x1b[0m")
+				writeANSIColor(bw, "38", bottom[0], bottom[1], bottom[2])
+				bw.WriteString("▄")
+			default:
+				writeANSIColor(bw, "38", top[0], top[1], top[2])
+				writeANSIColor(bw, "48", bottom[0], bottom[1], bottom[2])
+				bw.WriteString("▀")
+			}
+		}
+		bw.WriteString("\x1b[0m\n")
+	}
+}
+
+// brailleDots maps a dot position within a 2x4 cell to its bit in the braille pattern.
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+func writeBraille(bw *bufio.Writer, img image.Image, cols int) {
+	w, h := terminalSize(img, cols, 2, 4)
+	small := Resize(img, w, h, Box)
+	dots := Dither(Grayscale(small), color.Palette{color.Black, color.White}, DitherFloydSteinberg)
+
+	for y := 0; y < h; y += 4 {
+		for x := 0; x < w; x += 2 {
+			pattern := rune(0x2800)
+			var r, g, b, n float64
+			for dy := 0; dy < 4; dy++ {
+				for dx := 0; dx < 2; dx++ {
+					if dots.Pix[(y+dy)*dots.Stride+x+dx] == 0 {
+						continue
+					}
+					i := (y+dy)*small.Stride + (x+dx)*4
+					s := small.Pix[i : i+4 : i+4]
+					if s[3] == 0 {
+						continue
+					}
+					pattern |= brailleDots[dy][dx]
+					r += float64(s[0])
+					g += float64(s[1])
+					b += float64(s[2])
+					n++
+				}
+			}
+			if n == 0 {
+				bw.WriteString("\x1b[0m ")
+				continue
+			}
+			writeANSIColor(bw, "38", clamp(r/n), clamp(g/n), clamp(b/n))
+			bw.WriteRune(pattern)
+		}
+		bw.WriteString("\x1b[0m\n")
+	}
+}
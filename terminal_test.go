@@ -0,0 +1,79 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestEncodeTerminal(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(0, 0, 2, 2),
+		Stride: 2 * 4,
+		Pix: []uint8{
+			0xff, 0x00, 0x00, 0xff, 0x00, 0x00, 0x00, 0x00,
+			0x00, 0x00, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00,
+		},
+	}
+	testCases := []struct {
+		name string
+		img  image.Image
+		cols int
+		mode TerminalMode
+		want string
+	}{
+		{
+			"EncodeTerminal half blocks",
+			src,
+			2,
+			TerminalHalfBlock,
+			"\x1b[38;2;255;0;0m\x1b[48;2;0;0;255m▀\x1b[0m \x1b[0m\n",
+		},
+		{
+			"EncodeTerminal braille",
+			New(2, 4, color.White),
+			1,
+			TerminalBraille,
+			"\x1b[38;2;255;255;255m⣿\x1b[0m\n",
+		},
+		{
+			"EncodeTerminal braille dark",
+			New(2, 4, color.Black),
+			1,
+			TerminalBraille,
+			"\x1b[0m \x1b[0m\n",
+		},
+		{
+			"EncodeTerminal zero cols",
+			src,
+			0,
+			TerminalHalfBlock,
+			"",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := EncodeTerminal(&buf, tc.img, tc.cols, tc.mode); err != nil {
+				t.Fatalf("EncodeTerminal: %v", err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Fatalf("got %q want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEncodeTerminalLines(t *testing.T) {
+	for _, mode := range []TerminalMode{TerminalHalfBlock, TerminalBraille} {
+		var buf bytes.Buffer
+		if err := EncodeTerminal(&buf, testdataBranchesPNG, 40, mode); err != nil {
+			t.Fatalf("EncodeTerminal: %v", err)
+		}
+		// The source image is 600x400, so 40 columns give 40*400/600/2 = 13 lines.
+		if n := bytes.Count(buf.Bytes(), []byte("\n")); n != 13 {
+			t.Fatalf("mode %d: got %d lines want 13", mode, n)
+		}
+	}
+}
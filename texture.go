@@ -0,0 +1,665 @@
+package imaging
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"math"
+)
+
+// This file implements DDS and KTX2 texture encoders with optional BC1, BC3 (DXT1, DXT5)
+// and ETC2 block compression.
+
+var (
+	errTextureSize  = errors.New("imaging: texture must not be empty")
+	errDDSETC2      = errors.New("imaging: DDS doesn't support ETC2 compression")
+	errTextureCodec = errors.New("imaging: unsupported texture compression")
+)
+
+// TextureCompression is the GPU pixel format of DDS and KTX2 textures.
+type TextureCompression int
+
+// Texture pixel formats.
+const (
+	// TextureUncompressed stores 8-bit RGBA pixels.
+	TextureUncompressed TextureCompression = iota
+
+	// TextureBC1 (DXT1) stores 4x4 pixel blocks in 8 bytes, with 1-bit alpha.
+	// It's supported by desktop GPUs.
+	TextureBC1
+
+	// TextureBC3 (DXT5) stores 4x4 pixel blocks in 16 bytes, with smooth alpha.
+	// It's supported by desktop GPUs.
+	TextureBC3
+
+	// TextureETC2 stores 4x4 pixel blocks in 8 bytes, or 16 bytes if the image has
+	// an alpha channel. It's supported by mobile GPUs. DDS doesn't support ETC2.
+	TextureETC2
+)
+
+// textureLevels returns the mipmap levels of the image: the image itself and, if mipmaps are enabled,
+// the images halved in size down to 1x1 pixels.
+func textureLevels(img image.Image, mipmaps bool) []*image.NRGBA {
+	levels := []*image.NRGBA{Clone(img)}
+	for mipmaps {
+		b := levels[len(levels)-1].Bounds()
+		if b.Dx() == 1 && b.Dy() == 1 {
+			break
+		}
+		levels = append(levels, Resize(levels[0], maxint(b.Dx()/2, 1), maxint(b.Dy()/2, 1), Box))
+	}
+	return levels
+}
+
+// textureBlockSize returns the size of a 4x4 block in bytes, or of a pixel if uncompressed.
+func textureBlockSize(c TextureCompression, opaque bool) int {
+	switch c {
+	case TextureBC1:
+		return 8
+	case TextureBC3:
+		return 16
+	case TextureETC2:
+		if opaque {
+			return 8
+		}
+		return 16
+	}
+	return 4
+}
+
+// encodeTextureLevel encodes the pixels of the image in the texture format.
+func encodeTextureLevel(img *image.NRGBA, c TextureCompression, opaque bool) []byte {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	if c == TextureUncompressed {
+		data := make([]byte, 0, w*h*4)
+		for y := 0; y < h; y++ {
+			data = append(data, img.Pix[y*img.Stride:y*img.Stride+w*4]...)
+		}
+		return data
+	}
+
+	bw, bh := (w+3)/4, (h+3)/4
+	size := textureBlockSize(c, opaque)
+	data := make([]byte, bw*bh*size)
+	parallel(0, bh, func(bys <-chan int) {
+		var block [16][4]uint8
+		for by := range bys {
+			for bx := 0; bx < bw; bx++ {
+				// Blocks at the right and bottom edges repeat the edge pixels.
+				for i := range block {
+					x := minint(bx*4+i%4, w-1)
+					y := minint(by*4+i/4, h-1)
+					copy(block[i][:], img.Pix[y*img.Stride+x*4:])
+				}
+				dst := data[(by*bw+bx)*size:]
+				switch c {
+				case TextureBC1:
+					encodeBC1Block(dst, &block, true)
+				case TextureBC3:
+					encodeBC3AlphaBlock(dst, &block)
+					encodeBC1Block(dst[8:], &block, false)
+				case TextureETC2:
+					if opaque {
+						encodeETC1Block(dst, &block)
+					} else {
+						encodeEACAlphaBlock(dst, &block)
+						encodeETC1Block(dst[8:], &block)
+					}
+				}
+			}
+		}
+	})
+	return data
+}
+
+// to565 quantizes the color to the RGB565 format.
+func to565(c [3]float64) uint16 {
+	r := uint16(math.Max(0, math.Min(31, math.Round(c[0]*31/255))))
+	g := uint16(math.Max(0, math.Min(63, math.Round(c[1]*63/255))))
+	b := uint16(math.Max(0, math.Min(31, math.Round(c[2]*31/255))))
+	return r<<11 | g<<5 | b
+}
+
+// from565 expands the RGB565 color to 8 bits per channel.
+func from565(c uint16) [3]float64 {
+	r, g, b := c>>11, c>>5&63, c&31
+	return [3]float64{float64(r<<3 | r>>2), float64(g<<2 | g>>4), float64(b<<3 | b>>2)}
+}
+
+// colorEndpoints returns the endpoints of the line fitting the block colors: the extreme
+// projections of the colors on their principal axis. Only the pixels with use set are fitted.
+func colorEndpoints(block *[16][4]uint8, use *[16]bool) (lo, hi [3]float64) {
+	var mean [3]float64
+	n := 0
+	for i, p := range block {
+		if use[i] {
+			for c := 0; c < 3; c++ {
+				mean[c] += float64(p[c])
+			}
+			n++
+		}
+	}
+	if n == 0 {
+		return
+	}
+	for c := range mean {
+		mean[c] /= float64(n)
+	}
+	var cov [3][3]float64
+	for i, p := range block {
+		if !use[i] {
+			continue
+		}
+		for a := 0; a < 3; a++ {
+			for b := 0; b < 3; b++ {
+				cov[a][b] += (float64(p[a]) - mean[a]) * (float64(p[b]) - mean[b])
+			}
+		}
+	}
+	// The principal axis by power iteration.
+	axis := [3]float64{1, 1, 1}
+	for iter := 0; iter < 8; iter++ {
+		var next [3]float64
+		for a := 0; a < 3; a++ {
+			next[a] = cov[a][0]*axis[0] + cov[a][1]*axis[1] + cov[a][2]*axis[2]
+		}
+		norm := math.Sqrt(next[0]*next[0] + next[1]*next[1] + next[2]*next[2])
+		if norm < 1e-9 {
+			break
+		}
+		for a := range axis {
+			axis[a] = next[a] / norm
+		}
+	}
+	minT, maxT := math.Inf(1), math.Inf(-1)
+	for i, p := range block {
+		if !use[i] {
+			continue
+		}
+		t := (float64(p[0])-mean[0])*axis[0] + (float64(p[1])-mean[1])*axis[1] + (float64(p[2])-mean[2])*axis[2]
+		minT = math.Min(minT, t)
+		maxT = math.Max(maxT, t)
+	}
+	for c := 0; c < 3; c++ {
+		lo[c] = mean[c] + minT*axis[c]
+		hi[c] = mean[c] + maxT*axis[c]
+	}
+	return lo, hi
+}
+
+func colorDistance(a [3]float64, p [4]uint8) float64 {
+	dr, dg, db := a[0]-float64(p[0]), a[1]-float64(p[1]), a[2]-float64(p[2])
+	return dr*dr + dg*dg + db*db
+}
+
+// encodeBC1Block encodes the color block of BC1 and BC3. If alpha is set, pixels with alpha
+// below 128 are encoded as transparent using the 3-color mode, otherwise alpha is ignored.
+func encodeBC1Block(dst []byte, block *[16][4]uint8, alpha bool) {
+	var use [16]bool
+	transparent := false
+	for i, p := range block {
+		use[i] = !alpha || p[3] >= 128
+		transparent = transparent || !use[i]
+	}
+	lo, hi := colorEndpoints(block, &use)
+	c0, c1 := to565(hi), to565(lo)
+
+	var palette [4][3]float64
+	p0, p1 := from565(c0), from565(c1)
+	colors := 4
+	if transparent {
+		// The 3-color mode needs c0 <= c1, index 3 is transparent.
+		if c0 > c1 {
+			c0, c1, p0, p1 = c1, c0, p1, p0
+		}
+		colors = 3
+		for c := 0; c < 3; c++ {
+			palette[2][c] = (p0[c] + p1[c]) / 2
+		}
+	} else {
+		if c0 < c1 {
+			c0, c1, p0, p1 = c1, c0, p1, p0
+		}
+		for c := 0; c < 3; c++ {
+			palette[2][c] = (2*p0[c] + p1[c]) / 3
+			palette[3][c] = (p0[c] + 2*p1[c]) / 3
+		}
+	}
+	palette[0], palette[1] = p0, p1
+
+	var indices uint32
+	if c0 != c1 || transparent {
+		for i, p := range block {
+			idx := 3
+			if use[i] {
+				best := math.Inf(1)
+				for j := 0; j < colors; j++ {
+					if d := colorDistance(palette[j], p); d < best {
+						best, idx = d, j
+					}
+				}
+			}
+			indices |= uint32(idx) << uint(2*i)
+		}
+	}
+	binary.LittleEndian.PutUint16(dst, c0)
+	binary.LittleEndian.PutUint16(dst[2:], c1)
+	binary.LittleEndian.PutUint32(dst[4:], indices)
+}
+
+// encodeBC3AlphaBlock encodes the alpha block of BC3 in the 8-value mode.
+func encodeBC3AlphaBlock(dst []byte, block *[16][4]uint8) {
+	a0, a1 := uint8(0), uint8(255)
+	for _, p := range block {
+		if p[3] > a0 {
+			a0 = p[3]
+		}
+		if p[3] < a1 {
+			a1 = p[3]
+		}
+	}
+	dst[0], dst[1] = a0, a1
+	var indices uint64
+	if a0 != a1 {
+		// The palette is a0, a1 and 6 values between them from a0 to a1.
+		for i, p := range block {
+			// The position from a0 (0) to a1 (7).
+			t := int(math.Round(float64(int(a0)-int(p[3])) * 7 / float64(int(a0)-int(a1))))
+			idx := t + 1
+			switch t {
+			case 0:
+				idx = 0
+			case 7:
+				idx = 1
+			}
+			indices |= uint64(idx) << uint(3*i)
+		}
+	}
+	for i := 0; i < 6; i++ {
+		dst[2+i] = uint8(indices >> uint(8*i))
+	}
+}
+
+// etc1Modifiers are the ETC1 intensity modifier tables.
+var etc1Modifiers = [8][2]int{{2, 8}, {5, 17}, {9, 29}, {13, 42}, {18, 60}, {24, 80}, {33, 106}, {47, 183}}
+
+// etcSubblock returns whether the pixel (index y*4+x) is in the second subblock.
+func etcSubblock(i int, flip bool) bool {
+	if flip {
+		return i/4 >= 2
+	}
+	return i%4 >= 2
+}
+
+// etcFitSubblock finds the best modifier table for the subblock with the base color.
+// It returns the error, the table and the pixel indices.
+func etcFitSubblock(block *[16][4]uint8, second, flip bool, base [3]int) (float64, int, [16]int) {
+	bestErr := math.Inf(1)
+	bestTable := 0
+	var bestIdx [16]int
+	for table, mods := range etc1Modifiers {
+		var idx [16]int
+		var sum float64
+		for i, p := range block {
+			if etcSubblock(i, flip) != second {
+				continue
+			}
+			best := math.Inf(1)
+			for j, m := range [4]int{mods[0], mods[1], -mods[0], -mods[1]} {
+				var e float64
+				for c := 0; c < 3; c++ {
+					d := float64(clampint(base[c]+m, 0, 255)) - float64(p[c])
+					e += d * d
+				}
+				if e < best {
+					best, idx[i] = e, j
+				}
+			}
+			sum += best
+		}
+		if sum < bestErr {
+			bestErr, bestTable, bestIdx = sum, table, idx
+		}
+	}
+	return bestErr, bestTable, bestIdx
+}
+
+func clampint(v, min, max int) int {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// encodeETC1Block encodes the color block in the ETC1 individual or differential mode,
+// which is also a valid ETC2 RGB block.
+func encodeETC1Block(dst []byte, block *[16][4]uint8) {
+	bestErr := math.Inf(1)
+	var best uint64
+	for _, flip := range []bool{false, true} {
+		// The average colors of the subblocks.
+		var avg [2][3]float64
+		for i, p := range block {
+			s := 0
+			if etcSubblock(i, flip) {
+				s = 1
+			}
+			for c := 0; c < 3; c++ {
+				avg[s][c] += float64(p[c]) / 8
+			}
+		}
+
+		for _, diff := range []bool{false, true} {
+			var codes [2][3]int
+			var bases [2][3]int
+			ok := true
+			for s := 0; s < 2; s++ {
+				for c := 0; c < 3; c++ {
+					if diff {
+						codes[s][c] = clampint(int(math.Round(avg[s][c]*31/255)), 0, 31)
+						bases[s][c] = codes[s][c]<<3 | codes[s][c]>>2
+					} else {
+						codes[s][c] = clampint(int(math.Round(avg[s][c]*15/255)), 0, 15)
+						bases[s][c] = codes[s][c]<<4 | codes[s][c]
+					}
+				}
+			}
+			if diff {
+				for c := 0; c < 3; c++ {
+					if d := codes[1][c] - codes[0][c]; d < -4 || d > 3 {
+						ok = false
+					}
+				}
+			}
+			if !ok {
+				continue
+			}
+
+			err0, table0, idx0 := etcFitSubblock(block, false, flip, bases[0])
+			err1, table1, idx1 := etcFitSubblock(block, true, flip, bases[1])
+			if err0+err1 >= bestErr {
+				continue
+			}
+			bestErr = err0 + err1
+
+			var v uint64
+			for c := 0; c < 3; c++ {
+				shift := uint(56 - 8*c)
+				if diff {
+					v |= uint64(codes[0][c])<<(shift+3) | uint64((codes[1][c]-codes[0][c])&7)<<shift
+				} else {
+					v |= uint64(codes[0][c])<<(shift+4) | uint64(codes[1][c])<<shift
+				}
+			}
+			v |= uint64(table0)<<37 | uint64(table1)<<34
+			if diff {
+				v |= 1 << 33
+			}
+			if flip {
+				v |= 1 << 32
+			}
+			for i := range block {
+				idx := idx0[i]
+				if etcSubblock(i, flip) {
+					idx = idx1[i]
+				}
+				// The pixels are numbered in columns, the index is stored in
+				// two bit planes with the most significant bits first.
+				bit := uint((i%4)*4 + i/4)
+				v |= uint64(idx>>1)<<(bit+16) | uint64(idx&1)<<bit
+			}
+			best = v
+		}
+	}
+	binary.BigEndian.PutUint64(dst, best)
+}
+
+// eacModifiers are the ETC2 alpha (EAC) modifier tables.
+var eacModifiers = [16][8]int{
+	{-3, -6, -9, -15, 2, 5, 8, 14},
+	{-3, -7, -10, -13, 2, 6, 9, 12},
+	{-2, -5, -8, -13, 1, 4, 7, 12},
+	{-2, -4, -6, -13, 1, 3, 5, 12},
+	{-3, -6, -8, -12, 2, 5, 7, 11},
+	{-3, -7, -9, -11, 2, 6, 8, 10},
+	{-4, -7, -8, -11, 3, 6, 7, 10},
+	{-3, -5, -8, -11, 2, 4, 7, 10},
+	{-2, -6, -8, -10, 1, 5, 7, 9},
+	{-2, -5, -8, -10, 1, 4, 7, 9},
+	{-2, -4, -8, -10, 1, 3, 7, 9},
+	{-2, -5, -7, -10, 1, 4, 6, 9},
+	{-3, -4, -7, -10, 2, 3, 6, 9},
+	{-1, -2, -3, -10, 0, 1, 2, 9},
+	{-4, -6, -8, -9, 3, 5, 7, 8},
+	{-3, -5, -7, -9, 2, 4, 6, 8},
+}
+
+// encodeEACAlphaBlock encodes the alpha block of ETC2 RGBA.
+func encodeEACAlphaBlock(dst []byte, block *[16][4]uint8) {
+	min, max := 255, 0
+	for _, p := range block {
+		min = minint(min, int(p[3]))
+		max = maxint(max, int(p[3]))
+	}
+
+	bestErr := math.MaxInt
+	var best uint64
+	for table, mods := range eacModifiers {
+		for mult := 1; mult < 16; mult++ {
+			// Center the modifier range on the alpha range.
+			base := clampint((min+max-(mods[3]+mods[7])*mult+1)/2, 0, 255)
+			sum := 0
+			var indices uint64
+			for i, p := range block {
+				bestD, bestJ := math.MaxInt, 0
+				for j, m := range mods {
+					d := clampint(base+m*mult, 0, 255) - int(p[3])
+					if d*d < bestD {
+						bestD, bestJ = d*d, j
+					}
+				}
+				sum += bestD
+				// The pixels are numbered in columns, the first one is stored in the highest bits.
+				indices |= uint64(bestJ) << uint(45-3*((i%4)*4+i/4))
+			}
+			if sum < bestErr {
+				bestErr = sum
+				best = uint64(base)<<56 | uint64(mult)<<52 | uint64(table)<<48 | indices
+			}
+			if bestErr == 0 {
+				binary.BigEndian.PutUint64(dst, best)
+				return
+			}
+		}
+	}
+	binary.BigEndian.PutUint64(dst, best)
+}
+
+// encodeDDS writes the image to w as a DDS texture.
+func encodeDDS(w io.Writer, img image.Image, c TextureCompression, mipmaps bool) error {
+	b := img.Bounds()
+	if b.Empty() {
+		return errTextureSize
+	}
+	if c == TextureETC2 {
+		return errDDSETC2
+	}
+	if c < TextureUncompressed || c > TextureETC2 {
+		return errTextureCodec
+	}
+	levels := textureLevels(img, mipmaps)
+
+	le := binary.LittleEndian
+	header := make([]byte, 4+124)
+	copy(header, "DDS ")
+	h := header[4:]
+	flags := uint32(0x1 | 0x2 | 0x4 | 0x1000) // Caps, height, width and pixel format.
+	caps := uint32(0x1000)                    // Texture.
+	if len(levels) > 1 {
+		flags |= 0x20000     // Mipmap count.
+		caps |= 0x400000 | 8 // Mipmap and complex.
+	}
+	le.PutUint32(h[0:], 124)
+	le.PutUint32(h[8:], uint32(b.Dy()))
+	le.PutUint32(h[12:], uint32(b.Dx()))
+	le.PutUint32(h[24:], uint32(len(levels)))
+	pf := h[72:]
+	le.PutUint32(pf[0:], 32)
+	switch c {
+	case TextureUncompressed:
+		flags |= 0x8 // Pitch.
+		le.PutUint32(h[16:], uint32(b.Dx()*4))
+		le.PutUint32(pf[4:], 0x40|0x1) // RGB with alpha.
+		le.PutUint32(pf[12:], 32)
+		le.PutUint32(pf[16:], 0x000000ff)
+		le.PutUint32(pf[20:], 0x0000ff00)
+		le.PutUint32(pf[24:], 0x00ff0000)
+		le.PutUint32(pf[28:], 0xff000000)
+	default:
+		flags |= 0x80000 // Linear size.
+		size := textureBlockSize(c, false)
+		le.PutUint32(h[16:], uint32((b.Dx()+3)/4*((b.Dy()+3)/4)*size))
+		le.PutUint32(pf[4:], 0x4) // FourCC.
+		if c == TextureBC1 {
+			copy(pf[8:], "DXT1")
+		} else {
+			copy(pf[8:], "DXT5")
+		}
+	}
+	le.PutUint32(h[4:], flags)
+	le.PutUint32(h[104:], caps)
+
+	bw := bufio.NewWriter(w)
+	bw.Write(header)
+	for _, level := range levels {
+		bw.Write(encodeTextureLevel(level, c, false))
+	}
+	return bw.Flush()
+}
+
+// ktx2Identifier starts KTX2 files.
+const ktx2Identifier = "\xabKTX 20\xbb\r\n\x1a\n"
+
+// ktx2Sample is a sample of the KTX2 data format descriptor.
+type ktx2Sample struct {
+	offset, length int
+	channel        uint8
+	linear         bool
+	lower, upper   uint32
+}
+
+// encodeKTX2 writes the image to w as a KTX2 texture. The color data is in the sRGB color space.
+func encodeKTX2(w io.Writer, img image.Image, c TextureCompression, mipmaps bool) error {
+	b := img.Bounds()
+	if b.Empty() {
+		return errTextureSize
+	}
+	if c < TextureUncompressed || c > TextureETC2 {
+		return errTextureCodec
+	}
+	levels := textureLevels(img, mipmaps)
+	opaque := levels[0].Opaque()
+
+	// The Vulkan format and its data format descriptor.
+	var vkFormat uint32
+	var colorModel uint8
+	var samples []ktx2Sample
+	const all = 0xffffffff
+	switch c {
+	case TextureUncompressed:
+		vkFormat, colorModel = 43, 1 // VK_FORMAT_R8G8B8A8_SRGB, RGBSDA.
+		samples = []ktx2Sample{
+			{0, 8, 0, false, 0, 255},
+			{8, 8, 1, false, 0, 255},
+			{16, 8, 2, false, 0, 255},
+			{24, 8, 15, true, 0, 255},
+		}
+	case TextureBC1:
+		vkFormat, colorModel = 134, 128 // VK_FORMAT_BC1_RGBA_SRGB_BLOCK, BC1A.
+		samples = []ktx2Sample{{0, 64, 1, false, 0, all}}
+	case TextureBC3:
+		vkFormat, colorModel = 138, 130 // VK_FORMAT_BC3_SRGB_BLOCK, BC3.
+		samples = []ktx2Sample{{0, 64, 15, true, 0, all}, {64, 64, 0, false, 0, all}}
+	case TextureETC2:
+		colorModel = 161 // ETC2.
+		if opaque {
+			vkFormat = 148 // VK_FORMAT_ETC2_R8G8B8_SRGB_BLOCK.
+			samples = []ktx2Sample{{0, 64, 2, false, 0, all}}
+		} else {
+			vkFormat = 152 // VK_FORMAT_ETC2_R8G8B8A8_SRGB_BLOCK.
+			samples = []ktx2Sample{{0, 64, 15, true, 0, all}, {64, 64, 2, false, 0, all}}
+		}
+	}
+	blockSize := textureBlockSize(c, opaque)
+
+	le := binary.LittleEndian
+	dfd := make([]byte, 4+24+16*len(samples))
+	le.PutUint32(dfd, uint32(len(dfd)))
+	d := dfd[4:]
+	le.PutUint32(d[4:], uint32(2|(24+16*len(samples))<<16)) // Version 2 and the block size.
+	d[8] = colorModel
+	d[9] = 1  // BT.709 primaries.
+	d[10] = 2 // sRGB transfer function.
+	if c != TextureUncompressed {
+		d[12], d[13] = 3, 3 // 4x4 texel blocks.
+	}
+	d[16] = uint8(blockSize)
+	for i, s := range samples {
+		e := d[24+16*i:]
+		channel := s.channel
+		if s.linear {
+			channel |= 0x10
+		}
+		le.PutUint32(e, uint32(s.offset)|uint32(s.length-1)<<16|uint32(channel)<<24)
+		le.PutUint32(e[8:], s.lower)
+		le.PutUint32(e[12:], s.upper)
+	}
+
+	// The header, the level index and the data format descriptor are followed by the levels
+	// from the smallest to the largest, each one aligned to the block size (4, 8 or 16 bytes).
+	data := make([][]byte, len(levels))
+	for i, level := range levels {
+		data[i] = encodeTextureLevel(level, c, opaque)
+	}
+	headerLen := 80 + 24*len(levels)
+	offset := headerLen + len(dfd)
+	offsets := make([]int, len(levels))
+	for i := len(levels) - 1; i >= 0; i-- {
+		offset = (offset + blockSize - 1) / blockSize * blockSize
+		offsets[i] = offset
+		offset += len(data[i])
+	}
+
+	header := make([]byte, headerLen)
+	copy(header, ktx2Identifier)
+	le.PutUint32(header[12:], vkFormat)
+	le.PutUint32(header[16:], 1) // Type size.
+	le.PutUint32(header[20:], uint32(b.Dx()))
+	le.PutUint32(header[24:], uint32(b.Dy()))
+	le.PutUint32(header[36:], 1) // Face count.
+	le.PutUint32(header[40:], uint32(len(levels)))
+	le.PutUint32(header[48:], uint32(headerLen))
+	le.PutUint32(header[52:], uint32(len(dfd)))
+	for i := range levels {
+		e := header[80+24*i:]
+		le.PutUint64(e, uint64(offsets[i]))
+		le.PutUint64(e[8:], uint64(len(data[i])))
+		le.PutUint64(e[16:], uint64(len(data[i])))
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.Write(header)
+	bw.Write(dfd)
+	pos := headerLen + len(dfd)
+	for i := len(levels) - 1; i >= 0; i-- {
+		bw.Write(make([]byte, offsets[i]-pos))
+		bw.Write(data[i])
+		pos = offsets[i] + len(data[i])
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,393 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// decodeBC1Block decodes a BC1 color block. In BC3 blocks the color is always in the 4-color mode.
+func decodeBC1Block(src []byte, bc3 bool) [16][4]uint8 {
+	c0, c1 := binary.LittleEndian.Uint16(src), binary.LittleEndian.Uint16(src[2:])
+	p0, p1 := from565(c0), from565(c1)
+	var palette [4][4]uint8
+	for c := 0; c < 3; c++ {
+		palette[0][c] = uint8(p0[c])
+		palette[1][c] = uint8(p1[c])
+		if c0 > c1 || bc3 {
+			palette[2][c] = uint8((2*p0[c] + p1[c]) / 3)
+			palette[3][c] = uint8((p0[c] + 2*p1[c]) / 3)
+		} else {
+			palette[2][c] = uint8((p0[c] + p1[c]) / 2)
+		}
+	}
+	palette[0][3], palette[1][3], palette[2][3] = 255, 255, 255
+	if c0 > c1 || bc3 {
+		palette[3][3] = 255
+	}
+	var block [16][4]uint8
+	indices := binary.LittleEndian.Uint32(src[4:])
+	for i := range block {
+		block[i] = palette[indices>>uint(2*i)&3]
+	}
+	return block
+}
+
+// decodeBC3AlphaBlock decodes a BC3 alpha block into the alpha of the pixels.
+func decodeBC3AlphaBlock(src []byte, block *[16][4]uint8) {
+	a0, a1 := int(src[0]), int(src[1])
+	var palette [8]int
+	palette[0], palette[1] = a0, a1
+	if a0 > a1 {
+		for i := 1; i < 7; i++ {
+			palette[i+1] = ((7-i)*a0 + i*a1) / 7
+		}
+	} else {
+		for i := 1; i < 5; i++ {
+			palette[i+1] = ((5-i)*a0 + i*a1) / 5
+		}
+		palette[6], palette[7] = 0, 255
+	}
+	var indices uint64
+	for i := 0; i < 6; i++ {
+		indices |= uint64(src[2+i]) << uint(8*i)
+	}
+	for i := range block {
+		block[i][3] = uint8(palette[indices>>uint(3*i)&7])
+	}
+}
+
+// decodeETC1Block decodes an ETC1 block (individual or differential mode).
+func decodeETC1Block(src []byte) [16][4]uint8 {
+	v := binary.BigEndian.Uint64(src)
+	diff, flip := v>>33&1 == 1, v>>32&1 == 1
+	var bases [2][3]int
+	for c := 0; c < 3; c++ {
+		shift := uint(56 - 8*c)
+		if diff {
+			c1 := int(v >> (shift + 3) & 31)
+			d := int(int32(v>>shift&7) << 29 >> 29) // Sign extension.
+			c2 := c1 + d
+			bases[0][c] = c1<<3 | c1>>2
+			bases[1][c] = c2<<3 | c2>>2
+		} else {
+			c1, c2 := int(v>>(shift+4)&15), int(v>>shift&15)
+			bases[0][c] = c1<<4 | c1
+			bases[1][c] = c2<<4 | c2
+		}
+	}
+	tables := [2]int{int(v >> 37 & 7), int(v >> 34 & 7)}
+	var block [16][4]uint8
+	for i := range block {
+		s := 0
+		if etcSubblock(i, flip) {
+			s = 1
+		}
+		bit := uint((i%4)*4 + i/4)
+		idx := int(v>>(bit+16)&1)<<1 | int(v>>bit&1)
+		mods := etc1Modifiers[tables[s]]
+		m := [4]int{mods[0], mods[1], -mods[0], -mods[1]}[idx]
+		for c := 0; c < 3; c++ {
+			block[i][c] = uint8(clampint(bases[s][c]+m, 0, 255))
+		}
+		block[i][3] = 255
+	}
+	return block
+}
+
+// decodeEACAlphaBlock decodes an ETC2 alpha block into the alpha of the pixels.
+func decodeEACAlphaBlock(src []byte, block *[16][4]uint8) {
+	v := binary.BigEndian.Uint64(src)
+	base, mult, table := int(v>>56), int(v>>52&15), int(v>>48&15)
+	for i := range block {
+		idx := v >> uint(45-3*((i%4)*4+i/4)) & 7
+		block[i][3] = uint8(clampint(base+eacModifiers[table][idx]*mult, 0, 255))
+	}
+}
+
+// decodeTextureLevel decodes the blocks of a texture level.
+func decodeTextureLevel(data []byte, w, h int, c TextureCompression, opaque bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	if c == TextureUncompressed {
+		copy(img.Pix, data)
+		return img
+	}
+	size := textureBlockSize(c, opaque)
+	bw := (w + 3) / 4
+	for by := 0; by < (h+3)/4; by++ {
+		for bx := 0; bx < bw; bx++ {
+			src := data[(by*bw+bx)*size:]
+			var block [16][4]uint8
+			switch {
+			case c == TextureBC1:
+				block = decodeBC1Block(src, false)
+			case c == TextureBC3:
+				block = decodeBC1Block(src[8:], true)
+				decodeBC3AlphaBlock(src, &block)
+			case opaque:
+				block = decodeETC1Block(src)
+			default:
+				block = decodeETC1Block(src[8:])
+				decodeEACAlphaBlock(src, &block)
+			}
+			for i, p := range block {
+				x, y := bx*4+i%4, by*4+i/4
+				if x < w && y < h {
+					copy(img.Pix[y*img.Stride+x*4:], p[:])
+				}
+			}
+		}
+	}
+	return img
+}
+
+// textureError returns the mean absolute difference of the channels of the images.
+func textureError(img1, img2 *image.NRGBA) (rgb, alpha float64) {
+	for i := 0; i < len(img1.Pix); i += 4 {
+		for c := 0; c < 3; c++ {
+			rgb += float64(absint(int(img1.Pix[i+c]) - int(img2.Pix[i+c])))
+		}
+		alpha += float64(absint(int(img1.Pix[i+3]) - int(img2.Pix[i+3])))
+	}
+	n := float64(len(img1.Pix) / 4)
+	return rgb / n / 3, alpha / n
+}
+
+// textureTestImage returns the flowers image with a transparent top left corner
+// and a semi-transparent gradient.
+func textureTestImage() *image.NRGBA {
+	img := Clone(testdataFlowersSmallPNG)
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 120; x++ {
+			img.Pix[y*img.Stride+x*4+3] = 0
+		}
+		for x := 120; x < 240; x++ {
+			img.Pix[y*img.Stride+x*4+3] = uint8(x)
+		}
+	}
+	return img
+}
+
+func TestEncodeTextureLevel(t *testing.T) {
+	opaque := Clone(testdataFlowersSmallPNG)
+	transparent := textureTestImage()
+	testCases := []struct {
+		name     string
+		img      *image.NRGBA
+		c        TextureCompression
+		maxRGB   float64
+		maxAlpha float64
+	}{
+		{"uncompressed", transparent, TextureUncompressed, 0, 0},
+		{"BC1", opaque, TextureBC1, 6, 0},
+		{"BC3", opaque, TextureBC3, 6, 0},
+		{"ETC2", opaque, TextureETC2, 6, 0},
+		{"BC3 alpha", transparent, TextureBC3, 0, 2},
+		{"ETC2 alpha", transparent, TextureETC2, 0, 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			isOpaque := tc.img.Opaque()
+			data := encodeTextureLevel(tc.img, tc.c, isOpaque)
+			w, h := tc.img.Rect.Dx(), tc.img.Rect.Dy()
+			want := textureBlockSize(tc.c, isOpaque) * ((w + 3) / 4) * ((h + 3) / 4)
+			if tc.c == TextureUncompressed {
+				want = w * h * 4
+			}
+			if len(data) != want {
+				t.Fatalf("got %d bytes want %d", len(data), want)
+			}
+			got := decodeTextureLevel(data, w, h, tc.c, isOpaque)
+			rgb, alpha := textureError(got, tc.img)
+			if isOpaque && rgb > tc.maxRGB {
+				t.Fatalf("got color error %v want at most %v", rgb, tc.maxRGB)
+			}
+			if alpha > tc.maxAlpha {
+				t.Fatalf("got alpha error %v want at most %v", alpha, tc.maxAlpha)
+			}
+		})
+	}
+}
+
+func TestEncodeBC1Transparent(t *testing.T) {
+	var block [16][4]uint8
+	for i := range block {
+		block[i] = [4]uint8{200, 100, 50, 255}
+		if i%3 == 0 {
+			block[i] = [4]uint8{0, 0, 0, 10}
+		}
+	}
+	var dst [8]byte
+	encodeBC1Block(dst[:], &block, true)
+	got := decodeBC1Block(dst[:], false)
+	for i, p := range got {
+		if i%3 == 0 {
+			if p[3] != 0 {
+				t.Fatalf("pixel %d: got %v want transparent", i, p)
+			}
+			continue
+		}
+		if p[3] != 255 || absint(int(p[0])-200) > 4 || absint(int(p[1])-100) > 2 || absint(int(p[2])-50) > 4 {
+			t.Fatalf("pixel %d: got %v want %v", i, p, block[i])
+		}
+	}
+}
+
+func TestEncodeETC1Block(t *testing.T) {
+	// Two flat halves need the differential or the individual mode with the right flip.
+	for _, flip := range []bool{false, true} {
+		var block [16][4]uint8
+		for i := range block {
+			block[i] = [4]uint8{0x22, 0x44, 0x66, 255}
+			if etcSubblock(i, flip) {
+				block[i] = [4]uint8{0xee, 0xcc, 0x11, 255}
+			}
+		}
+		var dst [8]byte
+		encodeETC1Block(dst[:], &block)
+		got := decodeETC1Block(dst[:])
+		for i, p := range got {
+			for c := 0; c < 3; c++ {
+				if absint(int(p[c])-int(block[i][c])) > 2 {
+					t.Fatalf("flip=%v: pixel %d: got %v want %v", flip, i, p, block[i])
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeDDS(t *testing.T) {
+	img := New(10, 6, color.NRGBA{10, 20, 30, 255})
+	testCases := []struct {
+		name     string
+		c        TextureCompression
+		mipmaps  bool
+		fourCC   string
+		flags    uint32
+		linear   uint32
+		levels   uint32
+		dataSize int
+	}{
+		{"uncompressed", TextureUncompressed, false, "\x00\x00\x00\x00", 0x100f, 40, 1, 10 * 6 * 4},
+		{"BC1", TextureBC1, false, "DXT1", 0x81007, 3 * 2 * 8, 1, 3 * 2 * 8},
+		{"BC3 mipmaps", TextureBC3, true, "DXT5", 0xa1007, 3 * 2 * 16, 4, (3*2 + 2*1 + 1 + 1) * 16},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, img, DDS, TextureFormat(tc.c), TextureMipmaps(tc.mipmaps)); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			data := buf.Bytes()
+			le := binary.LittleEndian
+			if string(data[:4]) != "DDS " || le.Uint32(data[4:]) != 124 {
+				t.Fatalf("invalid DDS header: %q", data[:8])
+			}
+			if got := le.Uint32(data[8:]); got != tc.flags {
+				t.Fatalf("got flags %#x want %#x", got, tc.flags)
+			}
+			if h, w := le.Uint32(data[12:]), le.Uint32(data[16:]); w != 10 || h != 6 {
+				t.Fatalf("got size %dx%d want 10x6", w, h)
+			}
+			if got := le.Uint32(data[20:]); got != tc.linear {
+				t.Fatalf("got pitch or linear size %d want %d", got, tc.linear)
+			}
+			if got := le.Uint32(data[28:]); got != tc.levels {
+				t.Fatalf("got %d levels want %d", got, tc.levels)
+			}
+			if got := string(data[84:88]); got != tc.fourCC {
+				t.Fatalf("got FourCC %q want %q", got, tc.fourCC)
+			}
+			if got := len(data) - 128; got != tc.dataSize {
+				t.Fatalf("got %d bytes of data want %d", got, tc.dataSize)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, DDS, TextureFormat(TextureETC2)); err != errDDSETC2 {
+		t.Fatalf("got error %v want %v", err, errDDSETC2)
+	}
+	if err := Encode(&buf, &image.NRGBA{}, DDS); err != errTextureSize {
+		t.Fatalf("got error %v want %v", err, errTextureSize)
+	}
+}
+
+func TestEncodeKTX2(t *testing.T) {
+	testCases := []struct {
+		name     string
+		img      *image.NRGBA
+		c        TextureCompression
+		vkFormat uint32
+		samples  int
+	}{
+		{"uncompressed", Clone(testdataFlowersSmallPNG), TextureUncompressed, 43, 4},
+		{"BC1", Clone(testdataFlowersSmallPNG), TextureBC1, 134, 1},
+		{"BC3", textureTestImage(), TextureBC3, 138, 2},
+		{"ETC2", Clone(testdataFlowersSmallPNG), TextureETC2, 148, 1},
+		{"ETC2 alpha", textureTestImage(), TextureETC2, 152, 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, tc.img, KTX2, TextureFormat(tc.c), TextureMipmaps(true)); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			data := buf.Bytes()
+			le := binary.LittleEndian
+			if string(data[:12]) != ktx2Identifier {
+				t.Fatalf("invalid identifier %q", data[:12])
+			}
+			if got := le.Uint32(data[12:]); got != tc.vkFormat {
+				t.Fatalf("got format %d want %d", got, tc.vkFormat)
+			}
+			if w, h := le.Uint32(data[20:]), le.Uint32(data[24:]); w != 240 || h != 160 {
+				t.Fatalf("got size %dx%d want 240x160", w, h)
+			}
+			// 240x160, 120x80, ..., 1x1.
+			levels := int(le.Uint32(data[40:]))
+			if levels != 8 {
+				t.Fatalf("got %d levels want 8", levels)
+			}
+			dfdOffset, dfdLen := le.Uint32(data[48:]), le.Uint32(data[52:])
+			if dfdOffset != uint32(80+24*levels) || dfdLen != uint32(4+24+16*tc.samples) || le.Uint32(data[dfdOffset:]) != dfdLen {
+				t.Fatalf("invalid data format descriptor at %d of %d bytes", dfdOffset, dfdLen)
+			}
+
+			opaque := tc.img.Opaque()
+			blockSize := uint64(textureBlockSize(tc.c, opaque))
+			end := uint64(len(data))
+			for i := 0; i < levels; i++ {
+				e := data[80+24*i:]
+				offset, length := le.Uint64(e), le.Uint64(e[8:])
+				if offset%blockSize != 0 {
+					t.Fatalf("level %d: offset %d is not aligned", i, offset)
+				}
+				// The largest level is stored last.
+				if offset+length != end && i == 0 {
+					t.Fatalf("level 0 is not at the end of the file")
+				}
+				if i == 0 {
+					got := decodeTextureLevel(data[offset:offset+length], 240, 160, tc.c, opaque)
+					if rgb, alpha := textureError(got, tc.img); (opaque && rgb > 6) || alpha > 2 {
+						t.Fatalf("got errors %v and %v", rgb, alpha)
+					}
+				}
+				end = offset
+			}
+		})
+	}
+}
+
+func BenchmarkEncodeTexture(b *testing.B) {
+	for _, c := range []TextureCompression{TextureBC1, TextureETC2} {
+		b.Run(map[TextureCompression]string{TextureBC1: "BC1", TextureETC2: "ETC2"}[c], func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				Encode(&bytes.Buffer{}, testdataBranchesPNG, KTX2, TextureFormat(c))
+			}
+		})
+	}
+}
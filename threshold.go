@@ -0,0 +1,124 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// Threshold binarizes the image: the pixels with the luminance greater than the level
+// become white, the others black. The luminance is the one of Grayscale and Histogram,
+// rounded to the levels 0 to 255. The alpha channel is ignored.
+//
+// Example:
+//
+//	mask := imaging.Threshold(srcImage, 128)
+func Threshold(img image.Image, level uint8) *image.Gray {
+	lum, w, h := luminancePlane(img)
+	dst := image.NewGray(image.Rect(0, 0, w, h))
+	// The rounded luminance is greater than the level.
+	t := float64(level) + 0.5
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			for x := 0; x < w; x++ {
+				if lum[y*w+x] >= t {
+					dst.Pix[y*dst.Stride+x] = 0xff
+				}
+			}
+		}
+	})
+	return dst
+}
+
+// Otsu binarizes the image like Threshold with the level found by OtsuLevel, which suits
+// the images of a dark foreground on a light background or vice versa, such as scanned text.
+//
+// Example:
+//
+//	mask := imaging.Otsu(srcImage)
+func Otsu(img image.Image) *image.Gray {
+	return Threshold(img, OtsuLevel(img))
+}
+
+// OtsuLevel returns the luminance level that best separates the pixels of the image into
+// the dark and light ones using Otsu's method, which minimizes the variance of the luminance
+// within each of the classes. The pixels up to the level are the dark ones.
+func OtsuLevel(img image.Image) uint8 {
+	return otsuThreshold(Histogram(img))
+}
+
+// AdaptiveThreshold binarizes the image with the level varying across it, which handles
+// the uneven lighting and shadows of the photographed documents: the pixels with
+// the luminance greater than the mean luminance of the blockSize x blockSize square
+// centered at them minus c become white, the others black. The blockSize should be
+// larger than the foreground details, e.g. the strokes of the text, the even sizes
+// are increased by one and the sizes less than 3 are set to 3. The positive c keeps
+// the noise of the flat background white, typically 5 to 15. The squares are clipped
+// at the image boundaries. The alpha channel is ignored.
+//
+// Example:
+//
+//	text := imaging.AdaptiveThreshold(photo, 25, 10)
+func AdaptiveThreshold(img image.Image, blockSize int, c float64) *image.Gray {
+	if blockSize < 3 {
+		blockSize = 3
+	}
+	radius := blockSize / 2
+
+	lum, w, h := luminancePlane(img)
+	dst := image.NewGray(image.Rect(0, 0, w, h))
+
+	// The integral image: sum[(y+1)*(w+1)+x+1] is the sum of the luminance above and left
+	// of (x, y) inclusive.
+	sum := make([]float64, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		var row float64
+		for x := 0; x < w; x++ {
+			row += lum[y*w+x]
+			sum[(y+1)*(w+1)+x+1] = sum[y*(w+1)+x+1] + row
+		}
+	}
+
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			y0, y1 := maxint(y-radius, 0), minint(y+radius+1, h)
+			for x := 0; x < w; x++ {
+				x0, x1 := maxint(x-radius, 0), minint(x+radius+1, w)
+				s := sum[y1*(w+1)+x1] - sum[y0*(w+1)+x1] - sum[y1*(w+1)+x0] + sum[y0*(w+1)+x0]
+				mean := s / float64((x1-x0)*(y1-y0))
+				// The rounding keeps the flat areas with c = 0 consistent.
+				if math.Round(lum[y*w+x]) > math.Round(mean-c) {
+					dst.Pix[y*dst.Stride+x] = 0xff
+				}
+			}
+		}
+	})
+	return dst
+}
+
+// otsuThreshold returns the luminance level that best separates the histogram
+// into two classes (levels up to the threshold and levels above it) using Otsu's method.
+func otsuThreshold(hist [256]float64) uint8 {
+	var total, sum float64
+	for i, p := range hist {
+		total += p
+		sum += float64(i) * p
+	}
+
+	var best uint8
+	var bestVar, w0, sum0 float64
+	for t := 0; t < 255; t++ {
+		w0 += hist[t]
+		sum0 += float64(t) * hist[t]
+		w1 := total - w0
+		if w0 == 0 || w1 == 0 {
+			continue
+		}
+		m0 := sum0 / w0
+		m1 := (sum - sum0) / w1
+		if v := w0 * w1 * (m0 - m1) * (m0 - m1); v > bestVar {
+			bestVar = v
+			best = uint8(t)
+		}
+	}
+	return best
+}
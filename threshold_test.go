@@ -0,0 +1,145 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestThreshold(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 3, 0),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x7f, 0x7f, 0x7f, 0xff, 0x80, 0x80, 0x80, 0xff, 0x81, 0x81, 0x81, 0x00, 0xff, 0x00, 0x00, 0xff,
+		},
+	}
+	testCases := []struct {
+		level uint8
+		want  []uint8
+	}{
+		{0x7f, []uint8{0x00, 0xff, 0xff, 0x00}},
+		{0x80, []uint8{0x00, 0x00, 0xff, 0x00}},
+		{0x00, []uint8{0xff, 0xff, 0xff, 0xff}},
+		{0xff, []uint8{0x00, 0x00, 0x00, 0x00}},
+	}
+	for _, tc := range testCases {
+		got := Threshold(src, tc.level)
+		if got.Rect != image.Rect(0, 0, 4, 1) || string(got.Pix) != string(tc.want) {
+			t.Fatalf("level %d: got %v want %v", tc.level, got.Pix, tc.want)
+		}
+	}
+
+	// The mask matches the thresholded Grayscale image.
+	gray := Grayscale(testdataFlowersSmallPNG)
+	got := Threshold(testdataFlowersSmallPNG, 100)
+	diff := 0
+	for i := range got.Pix {
+		if (gray.Pix[i*4] > 100) != (got.Pix[i] == 0xff) {
+			diff++
+		}
+	}
+	// The luminance rounded exactly at the level may differ.
+	if diff > len(got.Pix)/1000 {
+		t.Fatalf("got %d pixels differing from Grayscale", diff)
+	}
+
+	if got := Threshold(image.NewNRGBA(image.Rect(0, 0, 0, 0)), 10); len(got.Pix) != 0 {
+		t.Fatalf("got %v", got.Pix)
+	}
+}
+
+func TestOtsu(t *testing.T) {
+	// The dark text on the light page.
+	src := New(20, 10, color.NRGBA{0xd0, 0xd0, 0xc0, 0xff})
+	for x := 5; x < 15; x++ {
+		src.SetNRGBA(x, 4, color.NRGBA{0x30, 0x20, 0x20, 0xff})
+		src.SetNRGBA(x, 5, color.NRGBA{0x40, 0x40, 0x40, 0xff})
+	}
+	level := OtsuLevel(src)
+	if level < 0x40 || level >= 0xcf {
+		t.Fatalf("got level %d between the text and the page", level)
+	}
+	got := Otsu(src)
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 20; x++ {
+			want := uint8(0xff)
+			if x >= 5 && x < 15 && (y == 4 || y == 5) {
+				want = 0
+			}
+			if v := got.GrayAt(x, y).Y; v != want {
+				t.Fatalf("got %d at (%d, %d) want %d", v, x, y, want)
+			}
+		}
+	}
+}
+
+func TestOtsuThreshold(t *testing.T) {
+	var hist [256]float64
+	hist[40] = 0.3
+	hist[50] = 0.2
+	hist[200] = 0.5
+	got := otsuThreshold(hist)
+	if got < 50 || got >= 200 {
+		t.Fatalf("got threshold %d want in range [50, 200)", got)
+	}
+}
+
+func TestAdaptiveThreshold(t *testing.T) {
+	// The text on the page lit unevenly, so no global level separates them.
+	src := image.NewGray(image.Rect(0, 0, 60, 20))
+	isText := func(x, y int) bool {
+		return y >= 8 && y < 12 && x%6 < 2
+	}
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 60; x++ {
+			page := 60 + 3*x
+			v := page
+			if isText(x, y) {
+				v = page - 50
+			}
+			src.SetGray(x, y, color.Gray{uint8(v)})
+		}
+	}
+	got := AdaptiveThreshold(src, 9, 10)
+	if got.Rect != src.Rect {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 60; x++ {
+			want := uint8(0xff)
+			if isText(x, y) {
+				want = 0
+			}
+			if v := got.GrayAt(x, y).Y; v != want {
+				t.Fatalf("got %d at (%d, %d) want %d", v, x, y, want)
+			}
+		}
+	}
+	if Otsu(src).GrayAt(0, 0).Y != 0 {
+		t.Fatal("the global level keeps the dark page white")
+	}
+
+	// The flat image stays white with the positive c, the invalid sizes are normalized.
+	flat := New(7, 5, color.NRGBA{0x80, 0x80, 0x80, 0xff})
+	for _, size := range []int{-1, 0, 4, 31} {
+		for _, v := range AdaptiveThreshold(flat, size, 5).Pix {
+			if v != 0xff {
+				t.Fatalf("size %d: got %d want white", size, v)
+			}
+		}
+	}
+	if got := AdaptiveThreshold(flat, 3, 0); got.Pix[0] != 0 {
+		t.Fatalf("got %d with c = 0 want black", got.Pix[0])
+	}
+	if got := AdaptiveThreshold(&image.Gray{}, 3, 5); len(got.Pix) != 0 {
+		t.Fatalf("got %v", got.Pix)
+	}
+}
+
+func BenchmarkAdaptiveThreshold(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		AdaptiveThreshold(testdataBranchesJPG, 25, 10)
+	}
+}
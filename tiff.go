@@ -0,0 +1,405 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+
+	"golang.org/x/image/tiff"
+)
+
+// This file implements a TIFF encoder. Unlike the golang.org/x/image/tiff encoder it supports
+// LZW compression, the horizontal predictor with all compression types and multiple pages.
+
+var errUnsupportedTIFFCompression = errors.New("imaging: unsupported TIFF compression")
+
+// EncodeMultiPageTIFF writes the images to w as pages of a single TIFF file.
+// The TIFFCompression and TIFFPredictor encode options are supported.
+//
+// Example:
+//
+//	err := imaging.EncodeMultiPageTIFF(file, []image.Image{page1, page2}, imaging.TIFFCompression(tiff.LZW))
+func EncodeMultiPageTIFF(w io.Writer, pages []image.Image, opts ...EncodeOption) error {
+	cfg := defaultEncodeConfig
+	for _, option := range opts {
+		option(&cfg)
+	}
+	return encodeTIFF(cfg.writer(w), pages, cfg.tiffCompression, cfg.tiffPredictor)
+}
+
+// SaveMultiPageTIFF saves the images to file with the specified filename as pages of a single TIFF file.
+//
+// Example:
+//
+//	err := imaging.SaveMultiPageTIFF([]image.Image{page1, page2}, "out.tif")
+func SaveMultiPageTIFF(pages []image.Image, filename string, opts ...EncodeOption) (err error) {
+	file, err := fs.Create(filename)
+	if err != nil {
+		return err
+	}
+	err = EncodeMultiPageTIFF(file, pages, opts...)
+	errc := file.Close()
+	if err == nil {
+		err = errc
+	}
+	return err
+}
+
+// TIFF tags and field types used by the encoder.
+const (
+	tiffImageWidth      = 256
+	tiffImageLength     = 257
+	tiffBitsPerSample   = 258
+	tiffCompression     = 259
+	tiffPhotometric     = 262
+	tiffStripOffsets    = 273
+	tiffSamplesPerPixel = 277
+	tiffRowsPerStrip    = 278
+	tiffStripByteCounts = 279
+	tiffXResolution     = 282
+	tiffYResolution     = 283
+	tiffResolutionUnit  = 296
+	tiffPageNumber      = 297
+	tiffPredictor       = 317
+	tiffColorMap        = 320
+	tiffExtraSamples    = 338
+
+	tiffShort    = 3
+	tiffLong     = 4
+	tiffRational = 5
+)
+
+type tiffEntry struct {
+	tag    uint16
+	typ    uint16
+	values []uint32
+}
+
+// tiffPage is the pixel data of a page in the TIFF byte order.
+type tiffPage struct {
+	w, h         int
+	samples      int // Samples per pixel.
+	bits         int // Bits per sample, 8 or 16.
+	photometric  uint32
+	extraSamples uint32
+	colorMap     []uint32
+	data         []byte
+	noPredictor  bool // The predictor isn't useful for this kind of data.
+	predictor    bool // The predictor is applied to the data.
+}
+
+func encodeTIFF(w io.Writer, pages []image.Image, compression tiff.CompressionType, predictor bool) error {
+	if len(pages) == 0 {
+		return errors.New("imaging: no TIFF pages to encode")
+	}
+	var code uint32
+	switch compression {
+	case tiff.Uncompressed:
+		code = 1
+	case tiff.LZW:
+		code = 5
+	case tiff.Deflate:
+		code = 8
+	default:
+		return errUnsupportedTIFFCompression
+	}
+
+	// Compress all the pages first to know where the IFDs start.
+	encoded := make([]*tiffPage, len(pages))
+	for i, img := range pages {
+		p := newTIFFPage(img)
+		p.predictor = predictor && !p.noPredictor
+		if p.predictor {
+			p.predict()
+		}
+		switch compression {
+		case tiff.LZW:
+			p.data = compressLZW(p.data)
+		case tiff.Deflate:
+			var buf bytes.Buffer
+			zw := zlib.NewWriter(&buf)
+			zw.Write(p.data)
+			zw.Close()
+			p.data = buf.Bytes()
+		}
+		encoded[i] = p
+	}
+
+	// Each page is written as its pixel data followed by its IFD.
+	// IFDs must begin on a word boundary.
+	padding := func(n int) int { return n % 2 }
+	bw := bufio.NewWriter(w)
+	bw.WriteString("II*\x00")
+	offset := uint32(8)
+	binary.Write(bw, binary.LittleEndian, offset+uint32(len(encoded[0].data)+padding(len(encoded[0].data))))
+
+	for i, p := range encoded {
+		if _, err := bw.Write(p.data); err != nil {
+			return err
+		}
+		if padding(len(p.data)) == 1 {
+			bw.WriteByte(0)
+		}
+		dataOffset := offset
+		offset += uint32(len(p.data) + padding(len(p.data)))
+
+		bps := make([]uint32, p.samples)
+		for j := range bps {
+			bps[j] = uint32(p.bits)
+		}
+		entries := []tiffEntry{
+			{tiffImageWidth, tiffLong, []uint32{uint32(p.w)}},
+			{tiffImageLength, tiffLong, []uint32{uint32(p.h)}},
+			{tiffBitsPerSample, tiffShort, bps},
+			{tiffCompression, tiffShort, []uint32{code}},
+			{tiffPhotometric, tiffShort, []uint32{p.photometric}},
+			{tiffStripOffsets, tiffLong, []uint32{dataOffset}},
+			{tiffSamplesPerPixel, tiffShort, []uint32{uint32(p.samples)}},
+			{tiffRowsPerStrip, tiffLong, []uint32{uint32(p.h)}},
+			{tiffStripByteCounts, tiffLong, []uint32{uint32(len(p.data))}},
+			{tiffXResolution, tiffRational, []uint32{72, 1}},
+			{tiffYResolution, tiffRational, []uint32{72, 1}},
+			{tiffResolutionUnit, tiffShort, []uint32{2}}, // Inch.
+		}
+		if len(pages) > 1 {
+			entries = append(entries, tiffEntry{tiffPageNumber, tiffShort, []uint32{uint32(i), uint32(len(pages))}})
+		}
+		if p.predictor {
+			entries = append(entries, tiffEntry{tiffPredictor, tiffShort, []uint32{2}}) // Horizontal differencing.
+		}
+		if p.colorMap != nil {
+			entries = append(entries, tiffEntry{tiffColorMap, tiffShort, p.colorMap})
+		}
+		if p.extraSamples != 0 {
+			entries = append(entries, tiffEntry{tiffExtraSamples, tiffShort, []uint32{p.extraSamples}})
+		}
+
+		ifd := encodeTIFFIFD(offset, entries)
+		offset += uint32(len(ifd))
+		next := uint32(0)
+		if i+1 < len(encoded) {
+			n := len(encoded[i+1].data)
+			next = offset + uint32(n+padding(n))
+		}
+		// The next IFD offset follows the entries.
+		binary.LittleEndian.PutUint32(ifd[2+len(entries)*12:], next)
+		if _, err := bw.Write(ifd); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// encodeTIFFIFD returns the image file directory starting at the given offset followed by
+// the values that don't fit into the entries. The next IFD offset is left zero.
+// The entries must be sorted by tag.
+func encodeTIFFIFD(offset uint32, entries []tiffEntry) []byte {
+	le := binary.LittleEndian
+	var buf, extra bytes.Buffer
+	extraOffset := offset + 2 + uint32(len(entries))*12 + 4
+
+	binary.Write(&buf, le, uint16(len(entries)))
+	for _, e := range entries {
+		var value bytes.Buffer
+		for _, v := range e.values {
+			switch e.typ {
+			case tiffShort:
+				binary.Write(&value, le, uint16(v))
+			default:
+				binary.Write(&value, le, v)
+			}
+		}
+		count := len(e.values)
+		if e.typ == tiffRational {
+			count /= 2
+		}
+		binary.Write(&buf, le, e.tag)
+		binary.Write(&buf, le, e.typ)
+		binary.Write(&buf, le, uint32(count))
+		if value.Len() <= 4 {
+			var field [4]byte
+			copy(field[:], value.Bytes())
+			buf.Write(field[:])
+		} else {
+			binary.Write(&buf, le, extraOffset+uint32(extra.Len()))
+			extra.Write(value.Bytes())
+			if extra.Len()%2 == 1 {
+				extra.WriteByte(0)
+			}
+		}
+	}
+	binary.Write(&buf, le, uint32(0))
+	buf.Write(extra.Bytes())
+	return buf.Bytes()
+}
+
+// newTIFFPage converts the image to the pixel data of a TIFF page.
+// Grayscale and paletted images keep their types, 16-bit images are written with
+// 16 bits per sample and other images are written as 8-bit RGBA.
+func newTIFFPage(img image.Image) *tiffPage {
+	b := img.Bounds()
+	p := &tiffPage{w: b.Dx(), h: b.Dy()}
+
+	switch img := img.(type) {
+	case *image.Gray:
+		p.samples, p.bits, p.photometric = 1, 8, 1
+		p.data = make([]byte, 0, p.w*p.h)
+		for y := 0; y < p.h; y++ {
+			i := y * img.Stride
+			p.data = append(p.data, img.Pix[i:i+p.w]...)
+		}
+
+	case *image.Gray16:
+		p.samples, p.bits, p.photometric = 1, 16, 1
+		p.data = make([]byte, 0, p.w*p.h*2)
+		for y := 0; y < p.h; y++ {
+			row := img.Pix[y*img.Stride : y*img.Stride+p.w*2]
+			for x := 0; x < len(row); x += 2 {
+				p.data = append(p.data, row[x+1], row[x])
+			}
+		}
+
+	case *image.Paletted:
+		p.samples, p.bits, p.photometric = 1, 8, 3
+		// Differences of palette indices don't compress better.
+		p.noPredictor = true
+		p.data = make([]byte, 0, p.w*p.h)
+		for y := 0; y < p.h; y++ {
+			i := y * img.Stride
+			p.data = append(p.data, img.Pix[i:i+p.w]...)
+		}
+		p.colorMap = make([]uint32, 3*256)
+		for i, c := range img.Palette {
+			if i == 256 {
+				break
+			}
+			r, g, b, _ := c.RGBA()
+			p.colorMap[i] = r
+			p.colorMap[256+i] = g
+			p.colorMap[512+i] = b
+		}
+
+	case *image.NRGBA64, *image.RGBA64:
+		src := toNRGBA64(img)
+		p.samples, p.bits, p.photometric, p.extraSamples = 4, 16, 2, 2
+		p.data = make([]byte, 0, p.w*p.h*8)
+		for y := 0; y < p.h; y++ {
+			row := src.Pix[y*src.Stride : y*src.Stride+p.w*8]
+			for x := 0; x < len(row); x += 2 {
+				p.data = append(p.data, row[x+1], row[x])
+			}
+		}
+
+	default:
+		src := newScanner(img)
+		p.samples, p.bits, p.photometric, p.extraSamples = 4, 8, 2, 2 // Unassociated alpha.
+		p.data = make([]byte, p.w*p.h*4)
+		parallel(0, p.h, func(ys <-chan int) {
+			for y := range ys {
+				src.scan(0, y, p.w, y+1, p.data[y*p.w*4:(y+1)*p.w*4])
+			}
+		})
+	}
+	return p
+}
+
+// predict applies the horizontal differencing predictor to the page data.
+func (p *tiffPage) predict() {
+	n := p.w * p.samples
+	for y := 0; y < p.h; y++ {
+		if p.bits == 16 {
+			row := p.data[y*n*2 : (y+1)*n*2]
+			for i := n - 1; i >= p.samples; i-- {
+				v := binary.LittleEndian.Uint16(row[i*2:]) - binary.LittleEndian.Uint16(row[(i-p.samples)*2:])
+				binary.LittleEndian.PutUint16(row[i*2:], v)
+			}
+			continue
+		}
+		row := p.data[y*n : (y+1)*n]
+		for i := n - 1; i >= p.samples; i-- {
+			row[i] -= row[i-p.samples]
+		}
+	}
+}
+
+// compressLZW compresses the data using the TIFF variant of the LZW algorithm
+// (MSB first bit order with the code width increased one code early).
+func compressLZW(data []byte) []byte {
+	const (
+		clearCode = 256
+		eoiCode   = 257
+		maxWidth  = 12
+		tableSize = 1 << 14
+		tableMask = tableSize - 1
+	)
+
+	var out bytes.Buffer
+	var bits uint32
+	var nbits uint
+	width := uint(9)
+	emit := func(code uint32) {
+		bits = bits<<width | code
+		nbits += width
+		for nbits >= 8 {
+			out.WriteByte(byte(bits >> (nbits - 8)))
+			nbits -= 8
+		}
+	}
+
+	// The hash table entries hold the key (prefix code and the next byte) in the
+	// high 20 bits and the code in the low 12 bits. Zero marks an empty entry.
+	var table [tableSize]uint32
+	hi := uint32(eoiCode)
+	emit(clearCode)
+	if len(data) == 0 {
+		emit(eoiCode)
+	} else {
+		prefix := uint32(data[0])
+		for _, c := range data[1:] {
+			key := prefix<<8 | uint32(c)
+			h := (key>>12 ^ key) & tableMask
+			found := false
+			for t := table[h]; t != 0; t = table[h] {
+				if t>>12 == key {
+					prefix = t & 0xfff
+					found = true
+					break
+				}
+				h = (h + 1) & tableMask
+			}
+			if found {
+				continue
+			}
+
+			emit(prefix)
+			hi++
+			if hi+1 >= 1<<width {
+				if width == maxWidth {
+					emit(clearCode)
+					table = [tableSize]uint32{}
+					hi = eoiCode
+					width = 9
+					prefix = uint32(c)
+					continue
+				}
+				width++
+			}
+			table[h] = key<<12 | hi
+			prefix = uint32(c)
+		}
+		emit(prefix)
+		hi++
+		if hi+1 >= 1<<width && width < maxWidth {
+			width++
+		}
+		emit(eoiCode)
+	}
+	if nbits > 0 {
+		out.WriteByte(byte(bits << (8 - nbits)))
+	}
+	return out.Bytes()
+}
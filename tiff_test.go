@@ -0,0 +1,155 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"testing"
+
+	"golang.org/x/image/tiff"
+)
+
+func TestEncodeTIFF(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 31, 17))
+	gray16 := image.NewGray16(image.Rect(0, 0, 31, 17))
+	nrgba64 := image.NewNRGBA64(image.Rect(0, 0, 31, 17))
+	paletted := image.NewPaletted(image.Rect(0, 0, 31, 17), palette.WebSafe)
+	for y := 0; y < 17; y++ {
+		for x := 0; x < 31; x++ {
+			gray.SetGray(x, y, color.Gray{uint8(x * y)})
+			gray16.SetGray16(x, y, color.Gray16{uint16(x*2000 + y*7)})
+			nrgba64.SetNRGBA64(x, y, color.NRGBA64{uint16(x * 1000), uint16(y * 3000), 0x1234, uint16(0xffff - x*y)})
+			paletted.SetColorIndex(x, y, uint8((x+y)%len(palette.WebSafe)))
+		}
+	}
+	images := []struct {
+		name string
+		img  image.Image
+	}{
+		{"nrgba", testdataFlowersSmallPNG},
+		{"large", testdataBranchesPNG},
+		{"gray", gray},
+		{"gray16", gray16},
+		{"nrgba64", nrgba64},
+		{"paletted", paletted},
+	}
+	options := []struct {
+		name string
+		opts []EncodeOption
+	}{
+		{"default", nil},
+		{"uncompressed", []EncodeOption{TIFFCompression(tiff.Uncompressed), TIFFPredictor(false)}},
+		{"uncompressed predictor", []EncodeOption{TIFFCompression(tiff.Uncompressed)}},
+		{"deflate", []EncodeOption{TIFFCompression(tiff.Deflate), TIFFPredictor(false)}},
+		{"lzw", []EncodeOption{TIFFCompression(tiff.LZW), TIFFPredictor(false)}},
+		{"lzw predictor", []EncodeOption{TIFFCompression(tiff.LZW), TIFFPredictor(true)}},
+	}
+	for _, tc := range images {
+		for _, opt := range options {
+			t.Run(tc.name+" "+opt.name, func(t *testing.T) {
+				var buf bytes.Buffer
+				if err := Encode(&buf, tc.img, TIFF, opt.opts...); err != nil {
+					t.Fatalf("Encode: %v", err)
+				}
+				got, err := tiff.Decode(&buf)
+				if err != nil {
+					t.Fatalf("Decode: %v", err)
+				}
+				if !got.Bounds().Eq(tc.img.Bounds()) {
+					t.Fatalf("got bounds %v want %v", got.Bounds(), tc.img.Bounds())
+				}
+				b := got.Bounds()
+				for y := b.Min.Y; y < b.Max.Y; y++ {
+					for x := b.Min.X; x < b.Max.X; x++ {
+						r1, g1, b1, a1 := got.At(x, y).RGBA()
+						r2, g2, b2, a2 := tc.img.At(x, y).RGBA()
+						if r1 != r2 || g1 != g2 || b1 != b2 || a1 != a2 {
+							t.Fatalf("got color %v at (%d, %d) want %v", got.At(x, y), x, y, tc.img.At(x, y))
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestEncodeTIFFFails(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testdataFlowersSmallPNG, TIFF, TIFFCompression(tiff.CCITTGroup4)); err != errUnsupportedTIFFCompression {
+		t.Fatalf("got error %v want %v", err, errUnsupportedTIFFCompression)
+	}
+	if err := EncodeMultiPageTIFF(&buf, nil); err == nil {
+		t.Fatalf("expected error for no pages")
+	}
+}
+
+func TestEncodeMultiPageTIFF(t *testing.T) {
+	pages := []image.Image{
+		testdataFlowersSmallPNG,
+		image.NewGray(image.Rect(0, 0, 5, 3)),
+		testdataBranchesPNG,
+	}
+	var buf bytes.Buffer
+	if err := EncodeMultiPageTIFF(&buf, pages, TIFFCompression(tiff.LZW)); err != nil {
+		t.Fatalf("EncodeMultiPageTIFF: %v", err)
+	}
+	data := buf.Bytes()
+
+	// Follow the IFD chain and decode each page by pointing the header to its IFD.
+	offset := binary.LittleEndian.Uint32(data[4:])
+	for i, want := range pages {
+		if offset == 0 {
+			t.Fatalf("got %d pages want %d", i, len(pages))
+		}
+		page := append([]byte(nil), data...)
+		binary.LittleEndian.PutUint32(page[4:], offset)
+		got, err := tiff.Decode(bytes.NewReader(page))
+		if err != nil {
+			t.Fatalf("Decode page %d: %v", i, err)
+		}
+		if !compareNRGBA(Clone(got), Clone(want), 0) {
+			t.Fatalf("page %d differs", i)
+		}
+		n := binary.LittleEndian.Uint16(data[offset:])
+		offset = binary.LittleEndian.Uint32(data[offset+2+uint32(n)*12:])
+	}
+	if offset != 0 {
+		t.Fatalf("got more than %d pages", len(pages))
+	}
+}
+
+func TestSaveMultiPageTIFF(t *testing.T) {
+	fs = badFS{}
+	defer func() { fs = localFS{} }()
+	pages := []image.Image{testdataFlowersSmallPNG}
+	if err := SaveMultiPageTIFF(pages, "create.tif"); err != errCreate {
+		t.Fatalf("got error %v want %v", err, errCreate)
+	}
+	if err := SaveMultiPageTIFF(pages, "badFile.jpg"); err != errClose {
+		t.Fatalf("got error %v want %v", err, errClose)
+	}
+}
+
+func TestCompressLZWWidths(t *testing.T) {
+	// Random-like data fills the code table and forces the table resets.
+	data := make([]byte, 200000)
+	x := uint32(1)
+	for i := range data {
+		x = x*1664525 + 1013904223
+		data[i] = byte(x >> 24 & 0x0f)
+	}
+	img := &image.Gray{Pix: data, Stride: 1000, Rect: image.Rect(0, 0, 1000, 200)}
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, TIFF, TIFFCompression(tiff.LZW), TIFFPredictor(false)); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	got, err := tiff.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !bytes.Equal(got.(*image.Gray).Pix, data) {
+		t.Fatalf("decoded data differs")
+	}
+}
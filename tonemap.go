@@ -0,0 +1,116 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// ToneMapOperator is a tone curve compressing the linear light values into the display range.
+type ToneMapOperator int
+
+// Tone mapping operators.
+const (
+	// ToneMapReinhard is the Reinhard operator x / (1 + x). It compresses the highlights
+	// smoothly and never clips, but it darkens the midtones by about a stop and
+	// lowers the contrast.
+	ToneMapReinhard ToneMapOperator = iota
+
+	// ToneMapFilmic is the filmic curve of John Hable used in Uncharted 2, with a toe
+	// deepening the shadows and a shoulder rolling off the highlights like film.
+	ToneMapFilmic
+
+	// ToneMapACES is the fit of the ACES filmic curve by Krzysztof Narkowicz. It has more
+	// contrast and saturation than ToneMapFilmic and clips the brightest highlights to white.
+	ToneMapACES
+)
+
+// ToneMap applies the tone curve of the operator to the linear light values of the image
+// multiplied by 2^exposure and returns the adjusted image. The exposure is in stops, the
+// exposure = 0 leaves the values unchanged before the curve. The curve is applied to each
+// channel, so the saturated highlights desaturate towards white as on film.
+//
+// The 16-bit images, such as the developed camera RAW files, are mapped with their full
+// precision, so the lifted shadows keep their detail.
+//
+// Example:
+//
+//	img, _ := imaging.Open("photo.dng")
+//	dstImage := imaging.ToneMap(img, imaging.ToneMapACES, 1)
+func ToneMap(img image.Image, operator ToneMapOperator, exposure float64) *image.NRGBA {
+	switch img.(type) {
+	case *image.NRGBA64, *image.RGBA64:
+		return toneMap16(toNRGBA64(img), toneCurve(operator, exposure))
+	}
+	return AdjustColors(img, ToneMapAdjustment(operator, exposure))
+}
+
+// ToneMapAdjustment returns the color adjustment of ToneMap for the 8-bit colors.
+func ToneMapAdjustment(operator ToneMapOperator, exposure float64) ColorAdjustment {
+	curve := toneCurve(operator, exposure)
+	lut := make([]uint8, 256)
+	for i := range lut {
+		lut[i] = clamp(delinearizeSRGB(curve(srgbToLinear[i])) * 255)
+	}
+	return ColorAdjustment{lut: lut}
+}
+
+// ToneMapInPlace applies the tone curve to the image like ToneMap, changing its pixels.
+func ToneMapInPlace(img *image.NRGBA, operator ToneMapOperator, exposure float64) {
+	AdjustColorsInPlace(img, ToneMapAdjustment(operator, exposure))
+}
+
+// toneMap16 maps the 16-bit image to the 8-bit one with the tone curve.
+func toneMap16(src *image.NRGBA64, curve func(x float64) float64) *image.NRGBA {
+	lut := make([]uint8, 65536)
+	for i := range lut {
+		lut[i] = clamp(delinearizeSRGB(curve(linearizeSRGB(float64(i)/65535))) * 255)
+	}
+
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dst := newNRGBA(image.Rect(0, 0, w, h))
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			s := src.Pix[y*src.Stride : y*src.Stride+w*8]
+			d := dst.Pix[y*dst.Stride : y*dst.Stride+w*4]
+			for x := 0; x < w; x++ {
+				si := s[x*8 : x*8+8 : x*8+8]
+				di := d[x*4 : x*4+4 : x*4+4]
+				di[0] = lut[uint16(si[0])<<8|uint16(si[1])]
+				di[1] = lut[uint16(si[2])<<8|uint16(si[3])]
+				di[2] = lut[uint16(si[4])<<8|uint16(si[5])]
+				di[3] = si[6]
+			}
+		}
+	})
+	return dst
+}
+
+// toneCurve returns the tone curve of the operator for the linear light values,
+// including the exposure multiplier.
+func toneCurve(operator ToneMapOperator, exposure float64) func(x float64) float64 {
+	k := math.Exp2(exposure)
+	switch operator {
+	case ToneMapFilmic:
+		const white = 11.2
+		hable := func(x float64) float64 {
+			const a, b, c, d, e, f = 0.15, 0.50, 0.10, 0.20, 0.02, 0.30
+			return (x*(a*x+c*b)+d*e)/(x*(a*x+b)+d*f) - e/f
+		}
+		// The exposure bias of 2 is the one of the original curve.
+		scale := 1 / hable(white)
+		return func(x float64) float64 {
+			return hable(2*k*x) * scale
+		}
+	case ToneMapACES:
+		return func(x float64) float64 {
+			// The fit expects the values scaled by 0.6.
+			x *= 0.6 * k
+			return (x * (2.51*x + 0.03)) / (x*(2.43*x+0.59) + 0.14)
+		}
+	default:
+		return func(x float64) float64 {
+			x *= k
+			return x / (1 + x)
+		}
+	}
+}
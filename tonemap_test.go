@@ -0,0 +1,106 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestToneMapAdjustment(t *testing.T) {
+	for _, op := range []ToneMapOperator{ToneMapReinhard, ToneMapFilmic, ToneMapACES} {
+		lut := ToneMapAdjustment(op, 0).lut
+		if lut[0] != 0 {
+			t.Fatalf("%v: got black %d want 0", op, lut[0])
+		}
+		brighter := ToneMapAdjustment(op, 1).lut
+		for i := 1; i < 256; i++ {
+			if lut[i] < lut[i-1] {
+				t.Fatalf("%v: the curve isn't monotonic at %d", op, i)
+			}
+			if brighter[i] < lut[i] {
+				t.Fatalf("%v: the higher exposure is darker at %d", op, i)
+			}
+		}
+	}
+
+	// Reinhard maps white to 0.5 in linear light.
+	if got := ToneMapAdjustment(ToneMapReinhard, 0).lut[255]; got != 188 {
+		t.Fatalf("got Reinhard white %d want 188", got)
+	}
+	// Reinhard never reaches white, ACES clips the bright values.
+	if got := ToneMapAdjustment(ToneMapReinhard, 4).lut[255]; got == 255 {
+		t.Fatal("Reinhard clips the highlights")
+	}
+	if got := ToneMapAdjustment(ToneMapACES, 4).lut[255]; got != 255 {
+		t.Fatalf("got ACES white %d want 255", got)
+	}
+	// The filmic curve maps white to the upper midtones, its white point is 11.2.
+	if got := ToneMapAdjustment(ToneMapFilmic, 0).lut[255]; got >= 200 {
+		t.Fatalf("got filmic white %d want the midtones compressed", got)
+	}
+}
+
+func TestToneMap(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 2, 0),
+		Stride: 3 * 4,
+		Pix: []uint8{
+			0x20, 0x80, 0xff, 0xff, 0x00, 0x10, 0x40, 0x80, 0xff, 0xff, 0xff, 0x00,
+		},
+	}
+	for _, op := range []ToneMapOperator{ToneMapReinhard, ToneMapFilmic, ToneMapACES} {
+		got := ToneMap(src, op, 0.5)
+		if !compareNRGBA(got, AdjustColors(src, ToneMapAdjustment(op, 0.5)), 0) {
+			t.Fatalf("%v: ToneMap differs from ToneMapAdjustment", op)
+		}
+		for i := 3; i < len(got.Pix); i += 4 {
+			if got.Pix[i] != src.Pix[i] {
+				t.Fatalf("%v: the alpha is changed", op)
+			}
+		}
+
+		img := Clone(src)
+		ToneMapInPlace(img, op, 0.5)
+		if !compareNRGBA(img, got, 0) {
+			t.Fatalf("%v: ToneMapInPlace differs from ToneMap", op)
+		}
+		if !compareNRGBA(NewPipeline().ToneMap(op, 0.5).Apply(src), got, 0) {
+			t.Fatalf("%v: the pipeline differs from ToneMap", op)
+		}
+	}
+}
+
+func TestToneMap16(t *testing.T) {
+	// The 16-bit values that are the 8-bit ones give the same results.
+	src := image.NewNRGBA64(image.Rect(1, 1, 257, 2))
+	src8 := image.NewNRGBA(image.Rect(0, 0, 256, 1))
+	for x := 0; x < 256; x++ {
+		v := uint16(x) * 0x101
+		src.SetNRGBA64(x+1, 1, color.NRGBA64{v, 0xffff - v, v / 2, 0xffff})
+		src8.SetNRGBA(x, 0, color.NRGBA{uint8(x), uint8(255 - x), uint8(v / 2 >> 8), 0xff})
+	}
+	for _, op := range []ToneMapOperator{ToneMapReinhard, ToneMapFilmic, ToneMapACES} {
+		if !compareNRGBA(ToneMap(src, op, 1), ToneMap(src8, op, 1), 1) {
+			t.Fatalf("%v: the 16-bit image differs from the 8-bit one", op)
+		}
+	}
+
+	// The shadows lifted by the exposure keep the 16-bit detail.
+	dark := image.NewRGBA64(image.Rect(0, 0, 2, 1))
+	dark.SetRGBA64(0, 0, color.RGBA64{0x0200, 0x0200, 0x0200, 0xffff})
+	dark.SetRGBA64(1, 0, color.RGBA64{0x0270, 0x0270, 0x0270, 0xffff})
+	got := ToneMap(dark, ToneMapReinhard, 6)
+	if got.Pix[0] == got.Pix[4] {
+		t.Fatalf("got the same values %v for the different shadows", got.Pix)
+	}
+	if got.Rect != image.Rect(0, 0, 2, 1) || got.Pix[3] != 0xff {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func BenchmarkToneMap(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ToneMap(testdataBranchesJPG, ToneMapFilmic, 1)
+	}
+}
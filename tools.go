@@ -15,7 +15,7 @@ func New(width, height int, fillColor color.Color) *image.NRGBA {
 
 	c := color.NRGBAModel.Convert(fillColor).(color.NRGBA)
 	if (c == color.NRGBA{0, 0, 0, 0}) {
-		return image.NewNRGBA(image.Rect(0, 0, width, height))
+		return newNRGBA(image.Rect(0, 0, width, height))
 	}
 
 	return &image.NRGBA{
@@ -27,10 +27,14 @@ func New(width, height int, fillColor color.Color) *image.NRGBA {
 
 // Clone returns a copy of the given image.
 func Clone(img image.Image) *image.NRGBA {
+	return defaultEngine.clone(img)
+}
+
+func (e *Engine) clone(img image.Image) *image.NRGBA {
 	src := newScanner(img)
-	dst := image.NewNRGBA(image.Rect(0, 0, src.w, src.h))
+	dst := newNRGBA(image.Rect(0, 0, src.w, src.h))
 	size := src.w * 4
-	parallel(0, src.h, func(ys <-chan int) {
+	e.parallel(0, src.h, func(ys <-chan int) {
 		for y := range ys {
 			i := y * dst.Stride
 			src.scan(0, y, src.w, y+1, dst.Pix[i:i+size])
@@ -92,18 +96,22 @@ func anchorPt(b image.Rectangle, w, h int, anchor Anchor) image.Point {
 // Crop cuts out a rectangular region with the specified bounds
 // from the image and returns the cropped image.
 func Crop(img image.Image, rect image.Rectangle) *image.NRGBA {
+	return defaultEngine.crop(img, rect)
+}
+
+func (e *Engine) crop(img image.Image, rect image.Rectangle) *image.NRGBA {
 	r := rect.Intersect(img.Bounds()).Sub(img.Bounds().Min)
 	if r.Empty() {
 		return &image.NRGBA{}
 	}
 	if r.Eq(img.Bounds().Sub(img.Bounds().Min)) {
-		return Clone(img)
+		return e.clone(img)
 	}
 
 	src := newScanner(img)
-	dst := image.NewNRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	dst := newNRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
 	rowSize := r.Dx() * 4
-	parallel(r.Min.Y, r.Max.Y, func(ys <-chan int) {
+	e.parallel(r.Min.Y, r.Max.Y, func(ys <-chan int) {
 		for y := range ys {
 			i := (y - r.Min.Y) * dst.Stride
 			src.scan(r.Min.X, y, r.Max.X, y+1, dst.Pix[i:i+rowSize])
@@ -115,11 +123,15 @@ func Crop(img image.Image, rect image.Rectangle) *image.NRGBA {
 // CropAnchor cuts out a rectangular region with the specified size
 // from the image using the specified anchor point and returns the cropped image.
 func CropAnchor(img image.Image, width, height int, anchor Anchor) *image.NRGBA {
+	return defaultEngine.cropAnchor(img, width, height, anchor)
+}
+
+func (e *Engine) cropAnchor(img image.Image, width, height int, anchor Anchor) *image.NRGBA {
 	srcBounds := img.Bounds()
 	pt := anchorPt(srcBounds, width, height, anchor)
 	r := image.Rect(0, 0, width, height).Add(pt)
 	b := srcBounds.Intersect(r)
-	return Crop(img, b)
+	return e.crop(img, b)
 }
 
 // CropCenter cuts out a rectangular region with the specified size
@@ -128,6 +140,108 @@ func CropCenter(img image.Image, width, height int) *image.NRGBA {
 	return CropAnchor(img, width, height, Center)
 }
 
+// Trim crops the uniform borders of the image, such as the white margins of a scan or
+// the black letterbox bars of a video frame, and returns the cropped image. The border
+// color is the color of the image corners, the one shared by most of them, so a logo
+// in a corner doesn't prevent the trimming. The rows and columns at the edges whose
+// pixels all differ from the border color by at most tolerance in every channel
+// (from 0 to 255) are removed, see TrimColor.
+//
+// Example:
+//
+//	// Remove the JPEG-compressed white margins.
+//	dstImage := imaging.Trim(srcImage, 16)
+func Trim(img image.Image, tolerance int) *image.NRGBA {
+	if img.Bounds().Empty() {
+		return &image.NRGBA{}
+	}
+
+	src := newScanner(img)
+	var corners [4]color.NRGBA
+	pix := make([]uint8, 4)
+	for i, p := range []image.Point{{0, 0}, {src.w - 1, 0}, {0, src.h - 1}, {src.w - 1, src.h - 1}} {
+		src.scan(p.X, p.Y, p.X+1, p.Y+1, pix)
+		corners[i] = color.NRGBA{pix[0], pix[1], pix[2], pix[3]}
+	}
+	best, bestCount := corners[0], 0
+	for _, c := range corners {
+		count := 0
+		for _, d := range corners {
+			if colorsClose(c, d, tolerance) {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = c, count
+		}
+	}
+	return TrimColor(img, best, tolerance)
+}
+
+// TrimColor crops the borders of the given color from the image and returns the cropped
+// image: the rows and columns at the edges whose pixels all differ from the color by at most
+// tolerance in every channel (from 0 to 255) are removed. The colors are compared with their
+// channels premultiplied by the alpha, so all the transparent pixels match a transparent color.
+// The image consisting of the border color only is returned uncropped.
+//
+// Example:
+//
+//	dstImage := imaging.TrimColor(srcImage, color.Black, 8)
+func TrimColor(img image.Image, borderColor color.Color, tolerance int) *image.NRGBA {
+	c := color.NRGBAModel.Convert(borderColor).(color.NRGBA)
+	src := newScanner(img)
+	if src.w == 0 || src.h == 0 {
+		return &image.NRGBA{}
+	}
+
+	// The first and last pixel of each row differing from the border color,
+	// the first is w for the rows of the border color only.
+	first := make([]int, src.h)
+	last := make([]int, src.h)
+	parallel(0, src.h, func(ys <-chan int) {
+		scanLine := make([]uint8, src.w*4)
+		for y := range ys {
+			src.scan(0, y, src.w, y+1, scanLine)
+			first[y], last[y] = src.w, -1
+			for x := 0; x < src.w; x++ {
+				s := scanLine[x*4 : x*4+4 : x*4+4]
+				if !colorsClose(color.NRGBA{s[0], s[1], s[2], s[3]}, c, tolerance) {
+					if first[y] == src.w {
+						first[y] = x
+					}
+					last[y] = x
+				}
+			}
+		}
+	})
+
+	r := image.Rectangle{Min: image.Pt(src.w, src.h)}
+	for y := 0; y < src.h; y++ {
+		if first[y] < src.w {
+			r.Min.X = minint(r.Min.X, first[y])
+			r.Max.X = maxint(r.Max.X, last[y]+1)
+			r.Min.Y = minint(r.Min.Y, y)
+			r.Max.Y = y + 1
+		}
+	}
+	if r.Empty() {
+		return Clone(img)
+	}
+	return Crop(img, r.Add(img.Bounds().Min))
+}
+
+// colorsClose reports whether the colors premultiplied by their alpha differ by at most
+// the tolerance in every channel.
+func colorsClose(a, b color.NRGBA, tolerance int) bool {
+	if absint(int(a.A)-int(b.A)) > tolerance {
+		return false
+	}
+	pa, pb := int(a.A), int(b.A)
+	return absint((int(a.R)*pa-int(b.R)*pb)/255) <= tolerance &&
+		absint((int(a.G)*pa-int(b.G)*pb)/255) <= tolerance &&
+		absint((int(a.B)*pa-int(b.B)*pb)/255) <= tolerance
+}
+
 // Paste pastes the img image to the background image at the specified position and returns the combined image.
 func Paste(background, img image.Image, pos image.Point) *image.NRGBA {
 	dst := Clone(background)
@@ -176,6 +290,7 @@ func PasteCenter(background, img image.Image) *image.NRGBA {
 // Overlay draws the img image over the background image at given position
 // and returns the combined image. Opacity parameter is the opacity of the img
 // image layer, used to compose the images, it must be from 0.0 to 1.0.
+// See Composite for the other Porter–Duff compositing operators and Blend for the blend modes.
 //
 // Examples:
 //
@@ -466,6 +466,77 @@ func TestCropCenter(t *testing.T) {
 	}
 }
 
+func TestTrim(t *testing.T) {
+	// The photo with the noisy white margins and a dark logo in the top-right corner of the margin.
+	photo := New(10, 6, color.NRGBA{0x40, 0x80, 0x20, 0xff})
+	src := Paste(New(20, 14, color.NRGBA{0xfe, 0xff, 0xfd, 0xff}), photo, image.Pt(4, 3))
+	src.SetNRGBA(19, 0, color.NRGBA{0x10, 0x10, 0x10, 0xff})
+	src.SetNRGBA(0, 13, color.NRGBA{0xf8, 0xff, 0xfd, 0xff})
+	src.Rect = src.Rect.Add(image.Pt(-5, 7))
+
+	got := Trim(src, 0)
+	if got.Rect != image.Rect(0, 0, 20, 14) {
+		t.Fatalf("tolerance 0: got bounds %v want the image", got.Rect)
+	}
+	got = Trim(src, 8)
+	// The logo is kept as it's a part of the image.
+	if got.Rect != image.Rect(0, 0, 16, 9) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	if c := got.NRGBAAt(0, 8); c != (color.NRGBA{0x40, 0x80, 0x20, 0xff}) {
+		t.Fatalf("got %v at the bottom-left corner want the photo", c)
+	}
+
+	// The letterbox bars.
+	frame := Paste(New(16, 12, color.Black), photo, image.Pt(3, 3))
+	want := photo
+	if got := Trim(frame, 8); !compareNRGBA(got, want, 0) {
+		t.Fatalf("got bounds %v want the photo", got.Rect)
+	}
+	if got := NewPipeline().Trim(8).Apply(frame); !compareNRGBA(got, want, 0) {
+		t.Fatalf("pipeline: got bounds %v want the photo", got.Rect)
+	}
+
+	// The uniform image is kept.
+	if got := Trim(photo, 0); !compareNRGBA(got, photo, 0) {
+		t.Fatalf("got bounds %v want the image", got.Rect)
+	}
+	if got := Trim(&image.NRGBA{}, 0); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
+
+func TestTrimColor(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 6))
+	// The transparent pixels of different colors.
+	for i := 0; i < len(src.Pix); i += 4 {
+		src.Pix[i] = uint8(i)
+	}
+	src.SetNRGBA(2, 1, color.NRGBA{0xff, 0, 0, 0x80})
+	src.SetNRGBA(5, 3, color.NRGBA{0, 0xff, 0, 0xff})
+
+	got := TrimColor(src, color.Transparent, 0)
+	if got.Rect != image.Rect(0, 0, 4, 3) || got.NRGBAAt(0, 0) != (color.NRGBA{0xff, 0, 0, 0x80}) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	// The other color doesn't match the transparent border.
+	if got := TrimColor(src, color.White, 10); got.Rect != image.Rect(0, 0, 8, 6) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	// The translucent pixel matches within the tolerance of its premultiplied red channel.
+	got = TrimColor(src, color.Transparent, 0x80)
+	if got.Rect != image.Rect(0, 0, 1, 1) || got.NRGBAAt(0, 0) != (color.NRGBA{0, 0xff, 0, 0xff}) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+}
+
+func BenchmarkTrim(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Trim(testdataBranchesJPG, 16)
+	}
+}
+
 func TestCropAnchor(t *testing.T) {
 	testCases := []struct {
 		name   string
@@ -0,0 +1,137 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// Transcode reads an image from r and writes it to w in the specified format. If the image
+// already is in the format and no encode options but EncodeHash are given, its data is copied
+// unchanged without decoding it, so a normalization proxy doesn't lose quality or metadata of
+// images that are already acceptable. Otherwise the image is decoded with the EXIF orientation
+// applied, as re-encoding drops the orientation tag, and encoded with the options.
+// Camera RAW files are always decoded, although they are TIFF files.
+//
+// Example:
+//
+//	// Serve all the uploads as PNG.
+//	err := imaging.Transcode(upload, w, imaging.PNG)
+func Transcode(r io.Reader, w io.Writer, format Format, opts ...EncodeOption) error {
+	return TranscodeWithOptions(r, w, format, TranscodeOptions{Encode: opts})
+}
+
+// TranscodeOptions are the options of TranscodeWithOptions.
+type TranscodeOptions struct {
+	// Decode are the decode options, e.g. the limits of the untrusted uploads. The EXIF
+	// orientation is applied unless they disable it.
+	Decode []DecodeOption
+
+	// Encode are the encode options like the options of Transcode.
+	Encode []EncodeOption
+}
+
+// TranscodeWithOptions transcodes the image like Transcode with the decode and encode options.
+// The size limits of the decode options, see MaxPixels and MaxDimensions, are checked for the
+// images copied unchanged too.
+//
+// Example:
+//
+//	err := imaging.TranscodeWithOptions(upload, w, imaging.JPEG, imaging.TranscodeOptions{
+//		Decode: []imaging.DecodeOption{imaging.MaxPixels(50e6)},
+//		Encode: []imaging.EncodeOption{imaging.JPEGQuality(85)},
+//	})
+func TranscodeWithOptions(r io.Reader, w io.Writer, format Format, options TranscodeOptions) error {
+	opts := options.Encode
+	br := bufio.NewReader(r)
+	if onlyHashOptions(opts) && sniffFormat(br) == format {
+		decodeCfg := defaultDecodeConfig
+		for _, option := range options.Decode {
+			option(&decodeCfg)
+		}
+		var err error
+		br, err = checkDecodeLimits(br, decodeCfg)
+		if err != nil {
+			return err
+		}
+
+		cfg := defaultEncodeConfig
+		for _, option := range opts {
+			option(&cfg)
+		}
+		hw := cfg.writer(w)
+		if format != TIFF {
+			_, err := br.WriteTo(hw)
+			return err
+		}
+		// RAW files are TIFF files, all the data is needed to tell them apart.
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return err
+		}
+		if !isRAW(data) {
+			_, err := hw.Write(data)
+			return err
+		}
+		br = bufio.NewReader(bytes.NewReader(data))
+	}
+
+	img, err := Decode(br, append([]DecodeOption{AutoOrientation(true)}, options.Decode...)...)
+	if err != nil {
+		return err
+	}
+	defer Release(img)
+	return Encode(w, img, format, opts...)
+}
+
+// onlyHashOptions reports whether the options set nothing but the hashes of EncodeHash.
+func onlyHashOptions(opts []EncodeOption) bool {
+	for _, option := range opts {
+		var probe encodeConfig
+		option(&probe)
+		if len(probe.hashes) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// sniffFormat returns the format of the image in br by its signature or -1 if it's unknown.
+// Camera RAW files are reported as TIFF.
+func sniffFormat(br *bufio.Reader) Format {
+	if rf, ok := sniffRegisteredFormat(br); ok {
+		return rf.format
+	}
+	if format, _, ok := externalFormat(br); ok {
+		return format
+	}
+	if isSVG(br) {
+		return SVG
+	}
+	if isPNM(br) {
+		magic, _ := br.Peek(2)
+		return pnmFormat(magic[1])
+	}
+	if magic, _ := br.Peek(12); len(magic) == 12 && string(magic[:4]) == "RIFF" && string(magic[8:]) == "WEBP" {
+		return WEBP
+	}
+	magic, _ := br.Peek(8)
+	signatures := []struct {
+		magic  string
+		format Format
+	}{
+		{"\xff\xd8\xff", JPEG},
+		{"\x89PNG\r\n\x1a\n", PNG},
+		{"GIF8", GIF},
+		{"II*\x00", TIFF},
+		{"MM\x00*", TIFF},
+		{"BM", BMP},
+		{"\x00\x00\x01\x00", ICO},
+	}
+	for _, s := range signatures {
+		if bytes.HasPrefix(magic, []byte(s.magic)) {
+			return s.format
+		}
+	}
+	return -1
+}
@@ -0,0 +1,234 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTranscodePassThrough(t *testing.T) {
+	for _, format := range []Format{JPEG, PNG, GIF, TIFF, BMP, SVG, ICO, PBM, PGM, PPM} {
+		var src bytes.Buffer
+		if err := Encode(&src, testdataFlowersSmallPNG, format); err != nil {
+			t.Fatalf("Encode(%v): %v", format, err)
+		}
+		var dst bytes.Buffer
+		if err := Transcode(bytes.NewReader(src.Bytes()), &dst, format); err != nil {
+			t.Fatalf("Transcode(%v): %v", format, err)
+		}
+		if !bytes.Equal(dst.Bytes(), src.Bytes()) {
+			t.Fatalf("Transcode(%v): the data isn't copied unchanged", format)
+		}
+	}
+}
+
+func TestTranscodeHash(t *testing.T) {
+	src, err := EncodeBytes(testdataFlowersSmallPNG, PNG)
+	if err != nil {
+		t.Fatalf("EncodeBytes: %v", err)
+	}
+	testCases := []struct {
+		name   string
+		format Format
+		opts   []EncodeOption
+		copied bool
+	}{
+		{"pass-through", PNG, nil, true},
+		{"other format", JPEG, nil, false},
+		{"options", PNG, []EncodeOption{PNGCompressionLevel(png.NoCompression)}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := sha256.New()
+			var dst bytes.Buffer
+			opts := append([]EncodeOption{EncodeHash(h)}, tc.opts...)
+			if err := Transcode(bytes.NewReader(src), &dst, tc.format, opts...); err != nil {
+				t.Fatalf("Transcode: %v", err)
+			}
+			if want := sha256.Sum256(dst.Bytes()); !bytes.Equal(h.Sum(nil), want[:]) {
+				t.Fatal("the hash differs from the hash of the data")
+			}
+			if copied := bytes.Equal(dst.Bytes(), src); copied != tc.copied {
+				t.Fatalf("got copied %v want %v", copied, tc.copied)
+			}
+		})
+	}
+}
+
+func TestTranscode(t *testing.T) {
+	var src bytes.Buffer
+	if err := Encode(&src, testdataFlowersSmallPNG, PNG); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	testCases := []struct {
+		name   string
+		format Format
+		opts   []EncodeOption
+	}{
+		{"other format", JPEG, nil},
+		{"same format with options", PNG, []EncodeOption{PNGCompressionLevel(png.NoCompression)}},
+		{"options", GIF, []EncodeOption{GIFNumColors(16)}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var want bytes.Buffer
+			if err := Encode(&want, testdataFlowersSmallPNG, tc.format, tc.opts...); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			var got bytes.Buffer
+			if err := Transcode(bytes.NewReader(src.Bytes()), &got, tc.format, tc.opts...); err != nil {
+				t.Fatalf("Transcode: %v", err)
+			}
+			if !bytes.Equal(got.Bytes(), want.Bytes()) {
+				t.Fatal("the image is encoded differently")
+			}
+		})
+	}
+}
+
+func TestTranscodeOrientation(t *testing.T) {
+	data, err := os.ReadFile("testdata/orientation_6.jpg")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var dst bytes.Buffer
+	if err := Transcode(bytes.NewReader(data), &dst, PNG); err != nil {
+		t.Fatalf("Transcode: %v", err)
+	}
+	got, err := Decode(&dst)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	want, err := Decode(bytes.NewReader(data), AutoOrientation(true))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !compareNRGBA(Clone(got), Clone(want), 0) {
+		t.Fatal("the orientation isn't applied")
+	}
+
+	// The orientation tag is kept with the data.
+	dst.Reset()
+	if err := Transcode(bytes.NewReader(data), &dst, JPEG); err != nil {
+		t.Fatalf("Transcode: %v", err)
+	}
+	if !bytes.Equal(dst.Bytes(), data) {
+		t.Fatal("the JPEG data isn't copied unchanged")
+	}
+}
+
+func TestTranscodeRAW(t *testing.T) {
+	data := rawTestDNG(t, 1, 16)
+	var dst bytes.Buffer
+	if err := Transcode(bytes.NewReader(data), &dst, TIFF); err != nil {
+		t.Fatalf("Transcode: %v", err)
+	}
+	if bytes.Equal(dst.Bytes(), data) {
+		t.Fatal("the RAW data is copied")
+	}
+	img, format, err := DecodeWithFormat(&dst)
+	if err != nil || format != TIFF {
+		t.Fatalf("DecodeWithFormat: %v, %v", format, err)
+	}
+	want, err := Decode(bytes.NewReader(data), AutoOrientation(true))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if img.Bounds().Size() != want.Bounds().Size() {
+		t.Fatalf("got size %v want %v", img.Bounds().Size(), want.Bounds().Size())
+	}
+}
+
+func TestTranscodeFails(t *testing.T) {
+	if err := Transcode(strings.NewReader("not an image"), &bytes.Buffer{}, PNG); err != image.ErrFormat {
+		t.Fatalf("got error %v want %v", err, image.ErrFormat)
+	}
+	var src bytes.Buffer
+	if err := Encode(&src, testdataFlowersSmallPNG, PNG); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if err := Transcode(&src, &bytes.Buffer{}, Format(-1)); err != ErrUnsupportedFormat {
+		t.Fatalf("got error %v want %v", err, ErrUnsupportedFormat)
+	}
+}
+
+func TestTranscodeWithOptions(t *testing.T) {
+	var src bytes.Buffer
+	if err := Encode(&src, testdataFlowersSmallPNG, PNG); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	bounds := testdataFlowersSmallPNG.Bounds()
+
+	// The limits apply to the decoded images and to the images copied unchanged.
+	for _, format := range []Format{JPEG, PNG} {
+		var dst bytes.Buffer
+		err := TranscodeWithOptions(bytes.NewReader(src.Bytes()), &dst, format, TranscodeOptions{
+			Decode: []DecodeOption{MaxDimensions(bounds.Dx()-1, 0)},
+		})
+		var tooLarge *ImageTooLargeError
+		if !errors.As(err, &tooLarge) || tooLarge.Width != bounds.Dx() || tooLarge.Height != bounds.Dy() {
+			t.Fatalf("%v: got error %v want an *ImageTooLargeError", format, err)
+		}
+		if dst.Len() != 0 {
+			t.Fatalf("%v: got %d bytes written want 0", format, dst.Len())
+		}
+	}
+
+	var dst bytes.Buffer
+	err := TranscodeWithOptions(bytes.NewReader(src.Bytes()), &dst, PNG, TranscodeOptions{
+		Decode: []DecodeOption{MaxPixels(bounds.Dx() * bounds.Dy())},
+	})
+	if err != nil || !bytes.Equal(dst.Bytes(), src.Bytes()) {
+		t.Fatalf("got error %v and %d bytes want the data copied unchanged", err, dst.Len())
+	}
+	dst.Reset()
+	err = TranscodeWithOptions(bytes.NewReader(src.Bytes()), &dst, BMP, TranscodeOptions{
+		Decode: []DecodeOption{MaxPixels(bounds.Dx() * bounds.Dy())},
+		Encode: []EncodeOption{BMPBitDepth(24)},
+	})
+	if err != nil {
+		t.Fatalf("TranscodeWithOptions: %v", err)
+	}
+	img, err := Decode(&dst)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !compareNRGBA(Clone(img), Clone(testdataFlowersSmallPNG), 0) {
+		t.Fatal("the transcoded image differs")
+	}
+}
+
+func TestSniffFormat(t *testing.T) {
+	gry := registerTestFormat(t, "gry", "GRY1")
+	testCases := []struct {
+		data string
+		want Format
+	}{
+		{"\xff\xd8\xff\xe0", JPEG},
+		{"\x89PNG\r\n\x1a\n", PNG},
+		{"GIF89a", GIF},
+		{"II*\x00", TIFF},
+		{"MM\x00*", TIFF},
+		{"BM", BMP},
+		{"\x00\x00\x01\x00", ICO},
+		{"<svg xmlns=\"http://www.w3.org/2000/svg\"/>", SVG},
+		{"P4 1 1\n", PBM},
+		{"P2 1 1 255\n", PGM},
+		{jp2Signature, JP2},
+		{jxlCodestream, JXL},
+		{"GRY1", gry},
+		{"RIFF", -1},
+		{"", -1},
+	}
+	for _, tc := range testCases {
+		if got := sniffFormat(bufio.NewReader(strings.NewReader(tc.data))); got != tc.want {
+			t.Fatalf("sniffFormat(%q): got %v want %v", tc.data, got, tc.want)
+		}
+	}
+}
@@ -12,7 +12,7 @@ func FlipH(img image.Image) *image.NRGBA {
 	dstW := src.w
 	dstH := src.h
 	rowSize := dstW * 4
-	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	dst := newNRGBA(image.Rect(0, 0, dstW, dstH))
 	parallel(0, dstH, func(ys <-chan int) {
 		for dstY := range ys {
 			i := dstY * dst.Stride
@@ -30,7 +30,7 @@ func FlipV(img image.Image) *image.NRGBA {
 	dstW := src.w
 	dstH := src.h
 	rowSize := dstW * 4
-	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	dst := newNRGBA(image.Rect(0, 0, dstW, dstH))
 	parallel(0, dstH, func(ys <-chan int) {
 		for dstY := range ys {
 			i := dstY * dst.Stride
@@ -47,7 +47,7 @@ func Transpose(img image.Image) *image.NRGBA {
 	dstW := src.h
 	dstH := src.w
 	rowSize := dstW * 4
-	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	dst := newNRGBA(image.Rect(0, 0, dstW, dstH))
 	parallel(0, dstH, func(ys <-chan int) {
 		for dstY := range ys {
 			i := dstY * dst.Stride
@@ -64,7 +64,7 @@ func Transverse(img image.Image) *image.NRGBA {
 	dstW := src.h
 	dstH := src.w
 	rowSize := dstW * 4
-	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	dst := newNRGBA(image.Rect(0, 0, dstW, dstH))
 	parallel(0, dstH, func(ys <-chan int) {
 		for dstY := range ys {
 			i := dstY * dst.Stride
@@ -82,7 +82,7 @@ func Rotate90(img image.Image) *image.NRGBA {
 	dstW := src.h
 	dstH := src.w
 	rowSize := dstW * 4
-	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	dst := newNRGBA(image.Rect(0, 0, dstW, dstH))
 	parallel(0, dstH, func(ys <-chan int) {
 		for dstY := range ys {
 			i := dstY * dst.Stride
@@ -99,7 +99,7 @@ func Rotate180(img image.Image) *image.NRGBA {
 	dstW := src.w
 	dstH := src.h
 	rowSize := dstW * 4
-	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	dst := newNRGBA(image.Rect(0, 0, dstW, dstH))
 	parallel(0, dstH, func(ys <-chan int) {
 		for dstY := range ys {
 			i := dstY * dst.Stride
@@ -117,7 +117,7 @@ func Rotate270(img image.Image) *image.NRGBA {
 	dstW := src.h
 	dstH := src.w
 	rowSize := dstW * 4
-	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	dst := newNRGBA(image.Rect(0, 0, dstW, dstH))
 	parallel(0, dstH, func(ys <-chan int) {
 		for dstY := range ys {
 			i := dstY * dst.Stride
@@ -150,7 +150,7 @@ func Rotate(img image.Image, angle float64, bgColor color.Color) *image.NRGBA {
 	srcW := src.Bounds().Max.X
 	srcH := src.Bounds().Max.Y
 	dstW, dstH := rotatedSize(srcW, srcH, angle)
-	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+	dst := newNRGBA(image.Rect(0, 0, dstW, dstH))
 
 	if dstW <= 0 || dstH <= 0 {
 		return dst
@@ -208,6 +208,61 @@ func rotatedSize(w, h int, angle float64) (int, int) {
 	return int(neww), int(newh)
 }
 
+// WarpPerspective maps the quadrilateral with the given corners (top-left, top-right,
+// bottom-right, bottom-left, relative to the top-left corner of the image bounds) onto
+// a width x height rectangle and returns the transformed image. It corrects the perspective
+// of a photographed planar object, e.g. a document found by DetectDocument. If width or height
+// is 0, it's computed from the lengths of the quadrilateral edges. Areas of the quadrilateral
+// outside the image are transparent.
+//
+// Example:
+//
+//	corners := [4]image.Point{{120, 80}, {910, 130}, {880, 1190}, {90, 1150}}
+//	dstImage := imaging.WarpPerspective(srcImage, corners, 0, 0)
+func WarpPerspective(img image.Image, corners [4]image.Point, width, height int) *image.NRGBA {
+	var x, y [4]float64
+	for i, p := range corners {
+		x[i], y[i] = float64(p.X), float64(p.Y)
+	}
+	if width == 0 {
+		width = int(math.Round(math.Max(math.Hypot(x[1]-x[0], y[1]-y[0]), math.Hypot(x[2]-x[3], y[2]-y[3]))))
+	}
+	if height == 0 {
+		height = int(math.Round(math.Max(math.Hypot(x[3]-x[0], y[3]-y[0]), math.Hypot(x[2]-x[1], y[2]-y[1]))))
+	}
+	if width <= 0 || height <= 0 {
+		return &image.NRGBA{}
+	}
+
+	// The projective mapping of the unit square onto the quadrilateral (Heckbert, 1989).
+	dx1, dx2, dx3 := x[1]-x[2], x[3]-x[2], x[0]-x[1]+x[2]-x[3]
+	dy1, dy2, dy3 := y[1]-y[2], y[3]-y[2], y[0]-y[1]+y[2]-y[3]
+	var g, h float64
+	if den := dx1*dy2 - dx2*dy1; den != 0 {
+		g = (dx3*dy2 - dx2*dy3) / den
+		h = (dx1*dy3 - dx3*dy1) / den
+	}
+	a, b, c := x[1]-x[0]+g*x[1], x[3]-x[0]+h*x[3], x[0]
+	d, e, f := y[1]-y[0]+g*y[1], y[3]-y[0]+h*y[3], y[0]
+
+	src := toNRGBA(img)
+	dst := newNRGBA(image.Rect(0, 0, width, height))
+	parallel(0, height, func(ys <-chan int) {
+		for dstY := range ys {
+			v := (float64(dstY) + 0.5) / float64(height)
+			for dstX := 0; dstX < width; dstX++ {
+				u := (float64(dstX) + 0.5) / float64(width)
+				w := g*u + h*v + 1
+				// Corner coordinates to pixel center coordinates.
+				xf := (a*u+b*v+c)/w - 0.5
+				yf := (d*u+e*v+f)/w - 0.5
+				interpolatePoint(dst, dstX, dstY, src, xf, yf, color.NRGBA{})
+			}
+		}
+	})
+	return dst
+}
+
 func interpolatePoint(dst *image.NRGBA, dstX, dstY int, src *image.NRGBA, xf, yf float64, bgColor color.NRGBA) {
 	j := dstY*dst.Stride + dstX*4
 	d := dst.Pix[j : j+4 : j+4]
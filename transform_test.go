@@ -643,3 +643,90 @@ func BenchmarkRotate(b *testing.B) {
 		Rotate(testdataBranchesJPG, 30, color.Transparent)
 	}
 }
+
+func TestWarpPerspective(t *testing.T) {
+	src := &image.NRGBA{
+		Rect:   image.Rect(-1, -1, 3, 2),
+		Stride: 4 * 4,
+		Pix: []uint8{
+			0x00, 0x11, 0x22, 0xff, 0x33, 0x44, 0x55, 0xff, 0x66, 0x77, 0x88, 0xff, 0x99, 0xaa, 0xbb, 0xff,
+			0x01, 0x02, 0x03, 0xff, 0x04, 0x05, 0x06, 0xff, 0x07, 0x08, 0x09, 0xff, 0x0a, 0x0b, 0x0c, 0xff,
+			0xf0, 0xe0, 0xd0, 0xff, 0xc0, 0xb0, 0xa0, 0xff, 0x90, 0x80, 0x70, 0xff, 0x60, 0x50, 0x40, 0x80,
+		},
+	}
+	testCases := []struct {
+		name          string
+		corners       [4]image.Point
+		width, height int
+		want          *image.NRGBA
+	}{
+		{
+			"identity",
+			[4]image.Point{{0, 0}, {4, 0}, {4, 3}, {0, 3}},
+			0, 0,
+			Clone(src),
+		},
+		{
+			"rotate 180",
+			[4]image.Point{{4, 3}, {0, 3}, {0, 0}, {4, 0}},
+			4, 3,
+			Rotate180(src),
+		},
+		{
+			"transpose",
+			[4]image.Point{{0, 0}, {0, 3}, {4, 3}, {4, 0}},
+			0, 0,
+			Transpose(src),
+		},
+		{
+			"crop",
+			[4]image.Point{{1, 1}, {3, 1}, {3, 3}, {1, 3}},
+			0, 0,
+			Crop(src, image.Rect(0, 0, 2, 2)),
+		},
+		{
+			"outside",
+			[4]image.Point{{5, 5}, {7, 5}, {7, 6}, {5, 6}},
+			0, 0,
+			image.NewNRGBA(image.Rect(0, 0, 2, 1)),
+		},
+		{
+			"empty",
+			[4]image.Point{{1, 1}, {1, 1}, {1, 1}, {1, 1}},
+			0, 0,
+			&image.NRGBA{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := WarpPerspective(src, tc.corners, tc.width, tc.height)
+			if !compareNRGBA(got, tc.want, 0) {
+				t.Fatalf("got result %#v want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWarpPerspectiveTrapezoid(t *testing.T) {
+	// A white trapezoid on a black background must fill the whole output.
+	corners := [4]image.Point{{30, 10}, {70, 10}, {90, 90}, {10, 90}}
+	src := New(100, 100, color.Black)
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			if y >= 10 && y < 90 && absint(2*x+1-100) <= 40+(y-10) {
+				src.SetNRGBA(x, y, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+			}
+		}
+	}
+	got := WarpPerspective(src, corners, 0, 0)
+	if got.Rect != image.Rect(0, 0, 80, 82) {
+		t.Fatalf("got bounds %v want %v", got.Rect, image.Rect(0, 0, 80, 82))
+	}
+	for y := 1; y < 81; y++ {
+		for x := 1; x < 79; x++ {
+			if c := got.NRGBAAt(x, y); c.R < 0x80 {
+				t.Fatalf("got color %v at (%d, %d) want white", c, x, y)
+			}
+		}
+	}
+}
@@ -5,26 +5,29 @@ import (
 	"math"
 	"runtime"
 	"sync"
-	"sync/atomic"
 )
 
-var maxProcs int64
-
 // SetMaxProcs limits the number of concurrent processing goroutines to the given value.
-// A value <= 0 clears the limit.
+// A value <= 0 clears the limit. It sets the limit of the default engine, the engines
+// created by NewEngine have their own limits.
 func SetMaxProcs(value int) {
-	atomic.StoreInt64(&maxProcs, int64(value))
+	defaultEngine.SetMaxProcs(value)
 }
 
-// parallel processes the data in separate goroutines.
+// parallel processes the data in separate goroutines of the default engine.
 func parallel(start, stop int, fn func(<-chan int)) {
+	defaultEngine.parallel(start, stop, fn)
+}
+
+// parallel processes the data in separate goroutines limited by the engine.
+func (e *Engine) parallel(start, stop int, fn func(<-chan int)) {
 	count := stop - start
 	if count < 1 {
 		return
 	}
 
 	procs := runtime.GOMAXPROCS(0)
-	limit := int(atomic.LoadInt64(&maxProcs))
+	limit := int(e.maxProcs.Load())
 	if procs > limit && limit > 0 {
 		procs = limit
 	}
@@ -4,7 +4,6 @@ import (
 	"image"
 	"math"
 	"runtime"
-	"sync/atomic"
 	"testing"
 )
 
@@ -80,7 +79,7 @@ func testParallelMaxProcsN(n, procs int) bool {
 func TestSetMaxProcs(t *testing.T) {
 	for _, p := range []int{-1, 0, 10} {
 		SetMaxProcs(p)
-		if int(atomic.LoadInt64(&maxProcs)) != p {
+		if int(defaultEngine.maxProcs.Load()) != p {
 			t.Fatalf("test [set max procs %d] failed", p)
 		}
 	}
@@ -0,0 +1,261 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"math"
+	"math/rand"
+)
+
+// The watermark is embedded in the DCT coefficients of the luminance of the image resized
+// to watermarkSize x watermarkSize, so it doesn't depend on the image size. The coefficients
+// (u, v) with watermarkLow <= u+v < watermarkHigh are low enough frequencies to survive
+// the JPEG compression and resizing, and high enough to be invisible.
+const (
+	watermarkSize = 256
+	watermarkLow  = 16
+	watermarkHigh = 96
+
+	// The 32 bits of the payload and the 16 check bits.
+	watermarkBits = 48
+
+	// The maximum number of the embedding passes.
+	watermarkPasses = 3
+)
+
+// EmbedWatermark embeds the invisible watermark carrying the payload, e.g. an asset or user
+// identifier, in the image and returns the watermarked image. DetectWatermark reads
+// the payload back with the same key. Unlike EmbedData, the watermark survives the JPEG
+// compression and moderate resizing, as it's spread over the middle frequencies of
+// the luminance of the whole image. It doesn't survive cropping, rotation or flipping.
+//
+// The strength is the amplitude of the watermark. The higher strengths survive the stronger
+// changes of the image, the lower ones are less visible. The strength of 4 survives the JPEG
+// quality 50 and halving of the size of the images at least 512 pixels wide and high, and
+// changes the pixels by 2 to 4 levels on average. The strength <= 0 returns a copy of the image.
+// The watermark of the images smaller than 256 pixels is weaker and more visible.
+//
+// Example:
+//
+//	const key = 0x5eed
+//	dstImage := imaging.EmbedWatermark(srcImage, key, assetID, 4)
+func EmbedWatermark(img image.Image, key int64, payload uint32, strength float64) *image.NRGBA {
+	dst := Clone(img)
+	if strength <= 0 || dst.Rect.Empty() {
+		return dst
+	}
+
+	bits := watermarkPayloadBits(payload)
+	sets := watermarkSets(key)
+
+	// The projection of the coefficients of each bit on its pseudo-random signs is pushed
+	// to at least the strength with the sign of the bit. The projections are measured again
+	// in the watermarked image to make up for the losses of the resizing and rounding.
+	for pass := 0; pass < watermarkPasses; pass++ {
+		coeffs := watermarkCoefficients(dst)
+		delta := make([]float64, watermarkSize*watermarkSize)
+		changed := false
+		for k, set := range sets {
+			sign := 1.0
+			if !bits[k] {
+				sign = -1
+			}
+			need := strength - sign*watermarkProjection(coeffs, set)
+			if need <= 0 {
+				continue
+			}
+			changed = true
+			for _, c := range set {
+				delta[c.index] = need * sign * c.sign
+			}
+		}
+		if !changed {
+			break
+		}
+		addWatermarkDiff(dst, dctTransform(delta, watermarkSize, watermarkHigh, true))
+	}
+	return dst
+}
+
+// addWatermarkDiff adds the watermarkSize x watermarkSize luminance difference upsampled
+// bilinearly to the color channels of the image.
+func addWatermarkDiff(dst *image.NRGBA, diff []float64) {
+	w, h := dst.Rect.Dx(), dst.Rect.Dy()
+	sx := float64(watermarkSize) / float64(w)
+	sy := float64(watermarkSize) / float64(h)
+	parallel(0, h, func(ys <-chan int) {
+		for y := range ys {
+			fy := math.Max(math.Min((float64(y)+0.5)*sy-0.5, watermarkSize-1), 0)
+			y0 := int(fy)
+			y1 := minint(y0+1, watermarkSize-1)
+			ty := fy - float64(y0)
+			for x := 0; x < w; x++ {
+				fx := math.Max(math.Min((float64(x)+0.5)*sx-0.5, watermarkSize-1), 0)
+				x0 := int(fx)
+				x1 := minint(x0+1, watermarkSize-1)
+				tx := fx - float64(x0)
+				d := (diff[y0*watermarkSize+x0]*(1-tx)+diff[y0*watermarkSize+x1]*tx)*(1-ty) +
+					(diff[y1*watermarkSize+x0]*(1-tx)+diff[y1*watermarkSize+x1]*tx)*ty
+				i := y*dst.Stride + x*4
+				dst.Pix[i+0] = clamp(float64(dst.Pix[i+0]) + d)
+				dst.Pix[i+1] = clamp(float64(dst.Pix[i+1]) + d)
+				dst.Pix[i+2] = clamp(float64(dst.Pix[i+2]) + d)
+			}
+		}
+	})
+}
+
+// DetectWatermark detects the watermark embedded by EmbedWatermark with the key and returns
+// its payload. It reports false if the image has no watermark of the key, or the watermark
+// was damaged beyond recovery. The probability of a false detection is about 1 in 65536.
+//
+// Example:
+//
+//	if assetID, ok := imaging.DetectWatermark(img, key); ok {
+//		fmt.Println("the image of the asset", assetID)
+//	}
+func DetectWatermark(img image.Image, key int64) (payload uint32, ok bool) {
+	if img.Bounds().Empty() {
+		return 0, false
+	}
+
+	coeffs := watermarkCoefficients(img)
+	var bits [watermarkBits]bool
+	for k, set := range watermarkSets(key) {
+		bits[k] = watermarkProjection(coeffs, set) > 0
+	}
+
+	for k := 0; k < 32; k++ {
+		if bits[k] {
+			payload |= 1 << uint(31-k)
+		}
+	}
+	return payload, bits == watermarkPayloadBits(payload)
+}
+
+// watermarkPayloadBits returns the bits of the payload followed by its check bits.
+func watermarkPayloadBits(payload uint32) [watermarkBits]bool {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], payload)
+	v := uint64(payload)<<16 | uint64(crc32.ChecksumIEEE(b[:])&0xffff)
+	var bits [watermarkBits]bool
+	for k := range bits {
+		bits[k] = v>>uint(watermarkBits-1-k)&1 == 1
+	}
+	return bits
+}
+
+// watermarkProjection returns the mean of the coefficients of the set multiplied by their signs.
+func watermarkProjection(coeffs []float64, set []watermarkCoefficient) float64 {
+	var x float64
+	for _, c := range set {
+		x += coeffs[c.index] * c.sign
+	}
+	return x / float64(len(set))
+}
+
+// watermarkCoefficient is a DCT coefficient carrying a bit of the watermark:
+// its index in the coefficient matrix and its pseudo-random sign.
+type watermarkCoefficient struct {
+	index int
+	sign  float64
+}
+
+// watermarkSets returns the coefficients carrying each bit of the watermark,
+// assigned pseudo-randomly with the key.
+func watermarkSets(key int64) [][]watermarkCoefficient {
+	var indices []int
+	for v := 0; v < watermarkHigh; v++ {
+		for u := 0; u < watermarkHigh-v; u++ {
+			if u+v >= watermarkLow {
+				indices = append(indices, v*watermarkSize+u)
+			}
+		}
+	}
+	rnd := rand.New(rand.NewSource(key))
+	rnd.Shuffle(len(indices), func(i, j int) {
+		indices[i], indices[j] = indices[j], indices[i]
+	})
+	sets := make([][]watermarkCoefficient, watermarkBits)
+	for i, index := range indices {
+		sign := 1.0
+		if rnd.Intn(2) == 0 {
+			sign = -1
+		}
+		sets[i%watermarkBits] = append(sets[i%watermarkBits], watermarkCoefficient{index, sign})
+	}
+	return sets
+}
+
+// watermarkCoefficients returns the DCT coefficients of the luminance of the image resized
+// to watermarkSize x watermarkSize.
+func watermarkCoefficients(img image.Image) []float64 {
+	small := Resize(img, watermarkSize, watermarkSize, Linear)
+	lum, _, _ := luminancePlane(small)
+	Release(small)
+	return dctTransform(lum, watermarkSize, watermarkHigh, false)
+}
+
+// dctMatrix returns the n x n matrix of the orthonormal DCT-II, m[k*n+x] is the weight
+// of the value x in the coefficient k.
+func dctMatrix(n int) []float64 {
+	m := make([]float64, n*n)
+	for k := 0; k < n; k++ {
+		s := math.Sqrt(2 / float64(n))
+		if k == 0 {
+			s = math.Sqrt(1 / float64(n))
+		}
+		for x := 0; x < n; x++ {
+			m[k*n+x] = s * math.Cos(math.Pi*float64(k)*(2*float64(x)+1)/float64(2*n))
+		}
+	}
+	return m
+}
+
+// dctTransform returns the 2D orthonormal DCT-II of the n x n plane p in row-major order,
+// limited to the coefficients (u, v) with u, v < limit, or if inverse is true, the inverse DCT
+// of such coefficients. The other coefficients are zero.
+func dctTransform(p []float64, n, limit int, inverse bool) []float64 {
+	m := dctMatrix(n)
+	// The sizes of the input and output of the 1D transform, and the weight
+	// of the input j in the output i.
+	in, out := n, limit
+	weight := func(i, j int) float64 {
+		return m[i*n+j]
+	}
+	if inverse {
+		in, out = limit, n
+		weight = func(i, j int) float64 {
+			return m[j*n+i]
+		}
+	}
+
+	// The transform of the rows and then of the columns.
+	tmp := make([]float64, n*n)
+	parallel(0, in, func(ys <-chan int) {
+		for y := range ys {
+			row := p[y*n : y*n+in]
+			for i := 0; i < out; i++ {
+				var sum float64
+				for j, v := range row {
+					sum += weight(i, j) * v
+				}
+				tmp[y*n+i] = sum
+			}
+		}
+	})
+	dst := make([]float64, n*n)
+	parallel(0, out, func(is <-chan int) {
+		for i := range is {
+			d := dst[i*n : i*n+out]
+			for j := 0; j < in; j++ {
+				w := weight(i, j)
+				for x, v := range tmp[j*n : j*n+out] {
+					d[x] += w * v
+				}
+			}
+		}
+	})
+	return dst
+}
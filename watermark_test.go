@@ -0,0 +1,135 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"math"
+	"testing"
+)
+
+func TestEmbedWatermark(t *testing.T) {
+	const key = 42
+	const payload uint32 = 0xdeadbeef
+	src := testdataBranchesJPG
+	got := EmbedWatermark(src, key, payload, 4)
+	if got.Rect != image.Rect(0, 0, 600, 400) {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+
+	// The watermark is invisible: the change is small and the alpha is kept.
+	orig := toNRGBA(src)
+	var sum float64
+	for i := range got.Pix {
+		d := float64(got.Pix[i]) - float64(orig.Pix[i])
+		if i%4 == 3 && d != 0 {
+			t.Fatalf("the alpha of the pixel %d is changed", i/4)
+		}
+		sum += d * d
+	}
+	if rms := math.Sqrt(sum / float64(len(got.Pix)*3/4)); rms > 4 {
+		t.Fatalf("got the change of %.2f levels want at most 4", rms)
+	}
+
+	jpeg, err := EncodeBytes(got, JPEG, JPEGQuality(50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := Decode(bytes.NewReader(jpeg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name string
+		img  image.Image
+	}{
+		{"watermarked", got},
+		{"jpeg", compressed},
+		{"half size", Resize(got, 300, 200, Lanczos)},
+		{"larger", Resize(got, 900, 600, Linear)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, ok := DetectWatermark(tc.img, key)
+			if !ok || p != payload {
+				t.Fatalf("got %#x, %v want %#x", p, ok, payload)
+			}
+		})
+	}
+
+	// The other key, or the image without the watermark.
+	if p, ok := DetectWatermark(got, key+1); ok {
+		t.Fatalf("got %#x with the other key", p)
+	}
+	if p, ok := DetectWatermark(src, key); ok {
+		t.Fatalf("got %#x without the watermark", p)
+	}
+
+	if got := EmbedWatermark(src, key, payload, 0); !compareNRGBA(got, orig, 0) {
+		t.Fatal("the strength = 0 changes the image")
+	}
+	if got := EmbedWatermark(&image.NRGBA{}, key, payload, 4); !got.Rect.Empty() {
+		t.Fatalf("got bounds %v", got.Rect)
+	}
+	if _, ok := DetectWatermark(&image.NRGBA{}, key); ok {
+		t.Fatal("detected the watermark in the empty image")
+	}
+}
+
+func TestWatermarkPayloads(t *testing.T) {
+	src := testdataFlowersSmallPNG
+	for _, payload := range []uint32{0, 0x80000001, 0xffffffff} {
+		p, ok := DetectWatermark(EmbedWatermark(src, -7, payload, 4), -7)
+		if !ok || p != payload {
+			t.Fatalf("got %#x, %v want %#x", p, ok, payload)
+		}
+	}
+}
+
+func TestDCTTransform(t *testing.T) {
+	const n = 16
+	p := make([]float64, n*n)
+	for i := range p {
+		p[i] = float64(i%7) * float64(i%5)
+	}
+	coeffs := dctTransform(p, n, n, false)
+	// The DC coefficient of the orthonormal DCT is the sum divided by n.
+	var sum float64
+	for _, v := range p {
+		sum += v
+	}
+	if math.Abs(coeffs[0]-sum/n) > 1e-9 {
+		t.Fatalf("got DC %v want %v", coeffs[0], sum/n)
+	}
+	for i, v := range dctTransform(coeffs, n, n, true) {
+		if math.Abs(v-p[i]) > 1e-9 {
+			t.Fatalf("got %v at %d after the inverse transform want %v", v, i, p[i])
+		}
+	}
+
+	// The limited transform computes the low frequencies only.
+	low := dctTransform(p, n, 5, false)
+	for v := 0; v < n; v++ {
+		for u := 0; u < n; u++ {
+			want := 0.0
+			if u < 5 && v < 5 {
+				want = coeffs[v*n+u]
+			}
+			if math.Abs(low[v*n+u]-want) > 1e-9 {
+				t.Fatalf("got %v at (%d, %d) want %v", low[v*n+u], u, v, want)
+			}
+		}
+	}
+	full := dctTransform(low, n, n, true)
+	for i, v := range dctTransform(low, n, 5, true) {
+		if math.Abs(v-full[i]) > 1e-9 {
+			t.Fatalf("got %v at %d after the limited inverse transform want %v", v, i, full[i])
+		}
+	}
+}
+
+func BenchmarkEmbedWatermark(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		EmbedWatermark(testdataBranchesJPG, 1, 1, 4)
+	}
+}
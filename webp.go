@@ -0,0 +1,511 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"io"
+	"sort"
+	"time"
+)
+
+// This file implements a lossless WebP (VP8L) encoder for still images and animations.
+// The encoder uses the subtract green transform and the backward references to the previous
+// pixel and to the pixel above, with one set of prefix codes for the whole image.
+
+var errWebPTooLarge = errors.New("imaging: image is too large for WebP")
+
+// webpMaxSize is the maximum width and height of a VP8L image.
+const webpMaxSize = 1 << 14
+
+// encodeWebP writes the image to w as a lossless WebP image.
+func encodeWebP(w io.Writer, img image.Image) error {
+	src := Clone(img)
+	defer Release(src)
+	data, err := encodeVP8L(src)
+	if err != nil {
+		return err
+	}
+	var buf []byte
+	buf = appendRIFFChunk(buf, "VP8L", data)
+	return writeRIFF(w, buf)
+}
+
+// encodeAnimatedWebP writes the animation to w as an animated lossless WebP image. Every frame
+// but the first one only stores the bounding box of the pixels that changed since the previous
+// frame, which replaces the pixels of the canvas.
+func encodeAnimatedWebP(w io.Writer, anim *Animation, cfg encodeConfig) error {
+	frames, delays := animationFrames(anim)
+	if cfg.animationDedup >= 0 {
+		frames, delays = deduplicateFrames(frames, delays, cfg.animationDedup)
+	}
+	size := frames[0].Rect.Size()
+	if size.X > webpMaxSize || size.Y > webpMaxSize {
+		return errWebPTooLarge
+	}
+	opaque := true
+	for _, f := range frames {
+		opaque = opaque && f.Opaque()
+	}
+
+	var flags byte = 0x02 // Animation.
+	if !opaque {
+		flags |= 0x10 // Alpha.
+	}
+	vp8x := make([]byte, 10)
+	vp8x[0] = flags
+	putUint24(vp8x[4:], uint32(size.X-1))
+	putUint24(vp8x[7:], uint32(size.Y-1))
+	buf := appendRIFFChunk(nil, "VP8X", vp8x)
+
+	// The background color is transparent. The WebP loop count is the number of plays.
+	loops := 0
+	switch {
+	case anim.LoopCount < 0:
+		loops = 1
+	case anim.LoopCount > 0:
+		loops = minint(anim.LoopCount+1, 0xffff)
+	}
+	animChunk := make([]byte, 6)
+	binary.LittleEndian.PutUint16(animChunk[4:], uint16(loops))
+	buf = appendRIFFChunk(buf, "ANIM", animChunk)
+
+	for i, f := range frames {
+		r := f.Rect
+		if i > 0 {
+			r = changedBounds(f, frames[i-1])
+		}
+		// The frame offsets are stored halved.
+		r.Min.X &^= 1
+		r.Min.Y &^= 1
+		data, err := encodeVP8L(f.SubImage(r).(*image.NRGBA))
+		if err != nil {
+			return err
+		}
+		header := make([]byte, 16)
+		putUint24(header[0:], uint32(r.Min.X/2))
+		putUint24(header[3:], uint32(r.Min.Y/2))
+		putUint24(header[6:], uint32(r.Dx()-1))
+		putUint24(header[9:], uint32(r.Dy()-1))
+		putUint24(header[12:], uint32(minint(int(delays[i]/time.Millisecond), 1<<24-1)))
+		header[15] = 0x02 // No blending, no disposal.
+		buf = appendRIFFChunk(buf, "ANMF", appendRIFFChunk(header, "VP8L", data))
+	}
+	return writeRIFF(w, buf)
+}
+
+// changedBounds returns the bounding box of the pixels of the frame that differ from
+// the previous frame of the same size, or the top-left pixel if there are none.
+func changedBounds(frame, previous *image.NRGBA) image.Rectangle {
+	w, h := frame.Rect.Dx(), frame.Rect.Dy()
+	r := image.Rectangle{Min: image.Pt(w, h)}
+	for y := 0; y < h; y++ {
+		a := frame.Pix[y*frame.Stride : y*frame.Stride+w*4]
+		b := previous.Pix[y*previous.Stride : y*previous.Stride+w*4]
+		for x := 0; x < w; x++ {
+			if a[x*4] != b[x*4] || a[x*4+1] != b[x*4+1] || a[x*4+2] != b[x*4+2] || a[x*4+3] != b[x*4+3] {
+				r.Min.X = minint(r.Min.X, x)
+				r.Max.X = maxint(r.Max.X, x+1)
+				r.Min.Y = minint(r.Min.Y, y)
+				r.Max.Y = y + 1
+			}
+		}
+	}
+	if r.Empty() {
+		return image.Rect(0, 0, 1, 1)
+	}
+	return r
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
+
+// appendRIFFChunk appends the chunk with the given identifier and data, padded to an even size.
+func appendRIFFChunk(buf []byte, id string, data []byte) []byte {
+	buf = append(buf, id...)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(data)))
+	buf = append(buf, data...)
+	if len(data)%2 == 1 {
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+// writeRIFF writes the chunks to w as a WebP file.
+func writeRIFF(w io.Writer, chunks []byte) error {
+	header := make([]byte, 12)
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+len(chunks)))
+	copy(header[8:], "WEBP")
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(chunks)
+	return err
+}
+
+// VP8L alphabet sizes: the green channel shares the prefix code with the lengths
+// of the backward references.
+const (
+	vp8lLiterals  = 256
+	vp8lLengths   = 24
+	vp8lDistances = 40
+)
+
+// vp8lCodeLengthOrder is the order the lengths of the code length code are stored in.
+var vp8lCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// vp8lToken is a literal pixel or, if length > 0, a backward reference to the pixels
+// at the distance code dist.
+type vp8lToken struct {
+	argb   uint32
+	length int
+	dist   int
+}
+
+// encodeVP8L returns the VP8L bitstream of the image.
+func encodeVP8L(img *image.NRGBA) ([]byte, error) {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	if w > webpMaxSize || h > webpMaxSize {
+		return nil, errWebPTooLarge
+	}
+	if w == 0 || h == 0 {
+		return nil, errors.New("imaging: cannot encode an empty image as WebP")
+	}
+
+	// The pixels with the green subtracted from the red and the blue.
+	argb := make([]uint32, w*h)
+	for y := 0; y < h; y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+w*4]
+		for x := 0; x < w; x++ {
+			r, g, b, a := row[x*4], row[x*4+1], row[x*4+2], row[x*4+3]
+			argb[y*w+x] = uint32(a)<<24 | uint32(r-g)<<16 | uint32(g)<<8 | uint32(b-g)
+		}
+	}
+
+	bw := &vp8lBitWriter{}
+	bw.buf = append(bw.buf, 0x2f)
+	bw.write(uint32(w-1), 14)
+	bw.write(uint32(h-1), 14)
+	if img.Opaque() {
+		bw.write(0, 1)
+	} else {
+		bw.write(1, 1)
+	}
+	bw.write(0, 3) // Version.
+	bw.write(1, 1) // The subtract green transform.
+	bw.write(2, 2)
+	bw.write(0, 1) // No more transforms.
+	bw.write(0, 1) // No color cache.
+	bw.write(0, 1) // No meta prefix codes.
+
+	tokens := vp8lTokens(argb, w)
+	var green, red, blue, alpha, dist [vp8lLiterals]int
+	var greenLengths [vp8lLengths]int
+	for _, t := range tokens {
+		if t.length > 0 {
+			code, _, _ := vp8lPrefix(t.length)
+			greenLengths[code]++
+			code, _, _ = vp8lPrefix(t.dist)
+			dist[code]++
+			continue
+		}
+		alpha[t.argb>>24]++
+		red[t.argb>>16&0xff]++
+		green[t.argb>>8&0xff]++
+		blue[t.argb&0xff]++
+	}
+	codes := [5]vp8lCode{
+		bw.writeCode(append(green[:], greenLengths[:]...)),
+		bw.writeCode(red[:]),
+		bw.writeCode(blue[:]),
+		bw.writeCode(alpha[:]),
+		bw.writeCode(dist[:vp8lDistances]),
+	}
+	for _, t := range tokens {
+		if t.length > 0 {
+			code, bits, extra := vp8lPrefix(t.length)
+			codes[0].write(bw, vp8lLiterals+code)
+			bw.write(extra, bits)
+			code, bits, extra = vp8lPrefix(t.dist)
+			codes[4].write(bw, code)
+			bw.write(extra, bits)
+			continue
+		}
+		codes[0].write(bw, int(t.argb>>8&0xff))
+		codes[1].write(bw, int(t.argb>>16&0xff))
+		codes[2].write(bw, int(t.argb&0xff))
+		codes[3].write(bw, int(t.argb>>24))
+	}
+	return bw.flush(), nil
+}
+
+// vp8lTokens splits the pixels into the literals and the backward references to the previous
+// pixel (distance code 2) or to the pixel above (distance code 1), choosing the longer run.
+func vp8lTokens(argb []uint32, w int) []vp8lToken {
+	const minLength, maxLength = 3, 4096
+	var tokens []vp8lToken
+	for i := 0; i < len(argb); {
+		var left, up int
+		if i > 0 {
+			for left < maxLength && i+left < len(argb) && argb[i+left] == argb[i+left-1] {
+				left++
+			}
+		}
+		if i >= w {
+			for up < maxLength && i+up < len(argb) && argb[i+up] == argb[i+up-w] {
+				up++
+			}
+		}
+		switch {
+		case up >= minLength && up >= left:
+			tokens = append(tokens, vp8lToken{length: up, dist: 1})
+			i += up
+		case left >= minLength:
+			tokens = append(tokens, vp8lToken{length: left, dist: 2})
+			i += left
+		default:
+			tokens = append(tokens, vp8lToken{argb: argb[i]})
+			i++
+		}
+	}
+	return tokens
+}
+
+// vp8lPrefix returns the prefix code of the value from 1, like the lengths and the distance
+// codes of the backward references, and the number and the value of its extra bits.
+func vp8lPrefix(v int) (code int, bits uint, extra uint32) {
+	v--
+	if v < 4 {
+		return v, 0, 0
+	}
+	hi := 0
+	for 2<<hi <= v {
+		hi++
+	}
+	second := v >> (hi - 1) & 1
+	bits = uint(hi - 1)
+	return 2*hi + second, bits, uint32(v) & (1<<bits - 1)
+}
+
+// vp8lBitWriter writes the bits least significant first.
+type vp8lBitWriter struct {
+	buf  []byte
+	acc  uint64
+	nacc uint
+}
+
+func (bw *vp8lBitWriter) write(v uint32, n uint) {
+	bw.acc |= uint64(v) << bw.nacc
+	bw.nacc += n
+	for bw.nacc >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nacc -= 8
+	}
+}
+
+func (bw *vp8lBitWriter) flush() []byte {
+	if bw.nacc > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc, bw.nacc = 0, 0
+	}
+	return bw.buf
+}
+
+// vp8lCode is a prefix code: the bit-reversed canonical codes of the symbols and their lengths.
+// The code of a single symbol takes no bits.
+type vp8lCode struct {
+	codes   []uint32
+	lengths []uint8
+}
+
+func (c vp8lCode) write(bw *vp8lBitWriter, symbol int) {
+	bw.write(c.codes[symbol], uint(c.lengths[symbol]))
+}
+
+// writeCode builds the prefix code of the symbol counts and writes it.
+func (bw *vp8lBitWriter) writeCode(counts []int) vp8lCode {
+	var symbols []int
+	for s, n := range counts {
+		if n > 0 {
+			symbols = append(symbols, s)
+		}
+	}
+	if len(symbols) <= 2 && (len(symbols) == 0 || symbols[len(symbols)-1] < vp8lLiterals) {
+		// The simple code of one or two 8-bit symbols.
+		if len(symbols) == 0 {
+			symbols = []int{0}
+		}
+		c := vp8lCode{codes: make([]uint32, len(counts)), lengths: make([]uint8, len(counts))}
+		bw.write(1, 1)
+		bw.write(uint32(len(symbols)-1), 1)
+		if symbols[0] < 2 {
+			bw.write(0, 1)
+			bw.write(uint32(symbols[0]), 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(uint32(symbols[0]), 8)
+		}
+		if len(symbols) == 2 {
+			bw.write(uint32(symbols[1]), 8)
+			c.codes[symbols[1]], c.lengths[symbols[0]], c.lengths[symbols[1]] = 1, 1, 1
+		}
+		return c
+	}
+
+	lengths := huffmanLengths(counts, 15)
+	// The code lengths are coded with the literal lengths and the runs of zeros.
+	type clToken struct {
+		symbol int
+		extra  uint32
+	}
+	var clTokens []clToken
+	clCounts := make([]int, 19)
+	for i := 0; i < len(lengths); {
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == lengths[i] {
+			run++
+		}
+		if lengths[i] == 0 && run >= 3 {
+			run = minint(run, 138)
+			if run >= 11 {
+				clTokens = append(clTokens, clToken{18, uint32(run - 11)})
+			} else {
+				clTokens = append(clTokens, clToken{17, uint32(run - 3)})
+			}
+			clCounts[clTokens[len(clTokens)-1].symbol]++
+			i += run
+			continue
+		}
+		clTokens = append(clTokens, clToken{int(lengths[i]), 0})
+		clCounts[lengths[i]]++
+		i++
+	}
+	clLengths := huffmanLengths(clCounts, 7)
+	n := 4
+	for i, s := range vp8lCodeLengthOrder {
+		if clLengths[s] > 0 {
+			n = maxint(n, i+1)
+		}
+	}
+	bw.write(0, 1)
+	bw.write(uint32(n-4), 4)
+	for _, s := range vp8lCodeLengthOrder[:n] {
+		bw.write(uint32(clLengths[s]), 3)
+	}
+	bw.write(0, 1) // All the code lengths are stored.
+	clCode := newVP8LCode(clLengths)
+	for _, t := range clTokens {
+		clCode.write(bw, t.symbol)
+		switch t.symbol {
+		case 17:
+			bw.write(t.extra, 3)
+		case 18:
+			bw.write(t.extra, 7)
+		}
+	}
+	return newVP8LCode(lengths)
+}
+
+// newVP8LCode returns the canonical prefix code of the code lengths.
+func newVP8LCode(lengths []uint8) vp8lCode {
+	c := vp8lCode{codes: make([]uint32, len(lengths)), lengths: make([]uint8, len(lengths))}
+	var count, next [16]uint32
+	used := 0
+	for _, l := range lengths {
+		if l > 0 {
+			count[l]++
+			used++
+		}
+	}
+	if used == 1 {
+		// The decoders read no bits for the only symbol.
+		return c
+	}
+	code := uint32(0)
+	for l := 1; l < 16; l++ {
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+	for s, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		v := next[l]
+		next[l]++
+		// The codes are read from the most significant bit.
+		var rev uint32
+		for i := uint8(0); i < l; i++ {
+			rev = rev<<1 | v>>i&1
+		}
+		c.codes[s], c.lengths[s] = rev, l
+	}
+	return c
+}
+
+// huffmanLengths returns the lengths of the Huffman codes of the symbol counts limited to
+// maxBits. The counts are halved until the code fits. A single symbol gets the length 1.
+func huffmanLengths(counts []int, maxBits int) []uint8 {
+	lengths := make([]uint8, len(counts))
+	type node struct {
+		count       int
+		left, right int // The children in nodes, -1 for the leaves.
+		symbol      int
+	}
+	weights := append([]int(nil), counts...)
+	for {
+		var leaves []node
+		for s, n := range weights {
+			if n > 0 {
+				leaves = append(leaves, node{count: n, left: -1, right: -1, symbol: s})
+			}
+		}
+		switch len(leaves) {
+		case 0:
+			return lengths
+		case 1:
+			lengths[leaves[0].symbol] = 1
+			return lengths
+		}
+		sort.SliceStable(leaves, func(i, j int) bool { return leaves[i].count < leaves[j].count })
+
+		// The two-queue construction: the leaves sorted by count and the merged nodes,
+		// which are created in the order of increasing counts.
+		nodes := append([]node(nil), leaves...)
+		merged := len(nodes)
+		li, mi := 0, merged
+		pop := func() int {
+			if li < len(leaves) && (mi >= len(nodes) || nodes[li].count <= nodes[mi].count) {
+				li++
+				return li - 1
+			}
+			mi++
+			return mi - 1
+		}
+		for len(nodes)-merged < len(leaves)-1 {
+			a, b := pop(), pop()
+			nodes = append(nodes, node{count: nodes[a].count + nodes[b].count, left: a, right: b})
+		}
+
+		depths := make([]int, len(nodes))
+		maxDepth := 0
+		for i := len(nodes) - 1; i >= merged; i-- {
+			n := nodes[i]
+			depths[n.left], depths[n.right] = depths[i]+1, depths[i]+1
+			maxDepth = maxint(maxDepth, depths[i]+1)
+		}
+		if maxDepth <= maxBits {
+			for i := 0; i < merged; i++ {
+				lengths[nodes[i].symbol] = uint8(depths[i])
+			}
+			return lengths
+		}
+		for s, n := range weights {
+			if n > 0 {
+				weights[s] = (n + 1) / 2
+			}
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+	"time"
+
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebP(t *testing.T) {
+	// The gradient has many colors, the flat areas are coded as backward references.
+	gradient := New(300, 70, color.NRGBA{10, 20, 30, 255})
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 300; x++ {
+			gradient.SetNRGBA(x, y, color.NRGBA{uint8(x), uint8(y * 6), uint8(x ^ y), uint8(255 - y)})
+		}
+	}
+	// The noise with a skewed distribution makes the long prefix codes.
+	noise := New(97, 31, color.Black)
+	rnd := rand.New(rand.NewSource(1))
+	for i := range noise.Pix {
+		noise.Pix[i] = uint8(rnd.ExpFloat64() * 8)
+	}
+	testCases := []struct {
+		name string
+		img  image.Image
+	}{
+		{"noise", noise},
+		{"flowers", testdataFlowersSmallPNG},
+		{"branches", testdataBranchesJPG},
+		{"gradient", gradient},
+		{"one color", New(17, 5, color.NRGBA{1, 2, 3, 4})},
+		{"one pixel", New(1, 1, color.NRGBA{200, 100, 50, 255})},
+		{"sub-image", gradient.SubImage(image.Rect(5, 30, 105, 50))},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, tc.img, WEBP); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			got, format, err := DecodeWithFormat(&buf)
+			if err != nil {
+				t.Fatalf("DecodeWithFormat: %v", err)
+			}
+			if format != WEBP {
+				t.Fatalf("got format %v want %v", format, WEBP)
+			}
+			if !compareNRGBA(Clone(got), Clone(tc.img), 0) {
+				t.Fatal("the decoded image differs")
+			}
+		})
+	}
+
+	if err := Encode(&bytes.Buffer{}, New(webpMaxSize+1, 1, color.White), WEBP); err != errWebPTooLarge {
+		t.Fatalf("got error %v want %v", err, errWebPTooLarge)
+	}
+}
+
+func TestVP8LPrefix(t *testing.T) {
+	testCases := []struct {
+		v     int
+		code  int
+		bits  uint
+		extra uint32
+	}{
+		{1, 0, 0, 0},
+		{4, 3, 0, 0},
+		{5, 4, 1, 0},
+		{6, 4, 1, 1},
+		{7, 5, 1, 0},
+		{9, 6, 2, 0},
+		{4096, 23, 10, 1023},
+	}
+	for _, tc := range testCases {
+		code, bits, extra := vp8lPrefix(tc.v)
+		if code != tc.code || bits != tc.bits || extra != tc.extra {
+			t.Errorf("vp8lPrefix(%d): got %d, %d, %d want %d, %d, %d", tc.v, code, bits, extra, tc.code, tc.bits, tc.extra)
+		}
+	}
+}
+
+func TestHuffmanLengths(t *testing.T) {
+	// The Fibonacci counts make the deepest tree, which is limited by halving the counts.
+	counts := make([]int, 20)
+	a, b := 1, 1
+	for i := range counts {
+		counts[i] = a
+		a, b = b, a+b
+	}
+	for _, maxBits := range []int{7, 15} {
+		lengths := huffmanLengths(counts, maxBits)
+		// The code is complete: the Kraft sum is 1.
+		sum := 0
+		for _, l := range lengths {
+			if l == 0 || int(l) > maxBits {
+				t.Fatalf("max %d bits: got lengths %v", maxBits, lengths)
+			}
+			sum += 1 << (maxBits - int(l))
+		}
+		if sum != 1<<maxBits {
+			t.Fatalf("max %d bits: the code of lengths %v is not complete", maxBits, lengths)
+		}
+	}
+}
+
+// webpFrame is a frame of an animated WebP image.
+type webpFrame struct {
+	rect     image.Rectangle
+	duration time.Duration
+	flags    byte
+	img      image.Image
+}
+
+// readAnimatedWebP parses an animated WebP image and decodes its frames.
+func readAnimatedWebP(t *testing.T, data []byte) (canvas image.Point, loops int, frames []webpFrame) {
+	t.Helper()
+	le := binary.LittleEndian
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" || int(le.Uint32(data[4:]))+8 != len(data) {
+		t.Fatalf("invalid RIFF header")
+	}
+	uint24 := func(b []byte) int { return int(b[0]) | int(b[1])<<8 | int(b[2])<<16 }
+	for p := 12; p < len(data); {
+		id, size := string(data[p:p+4]), int(le.Uint32(data[p+4:]))
+		chunk := data[p+8 : p+8+size]
+		switch id {
+		case "VP8X":
+			if chunk[0]&0x02 == 0 {
+				t.Fatal("the animation flag is not set")
+			}
+			canvas = image.Pt(uint24(chunk[4:])+1, uint24(chunk[7:])+1)
+		case "ANIM":
+			loops = int(le.Uint16(chunk[4:]))
+		case "ANMF":
+			f := webpFrame{
+				rect:     image.Rect(0, 0, uint24(chunk[6:])+1, uint24(chunk[9:])+1).Add(image.Pt(uint24(chunk[0:])*2, uint24(chunk[3:])*2)),
+				duration: time.Duration(uint24(chunk[12:])) * time.Millisecond,
+				flags:    chunk[15],
+			}
+			// The frame data is a VP8L chunk, decoded as a still image.
+			var still bytes.Buffer
+			if err := writeRIFF(&still, chunk[16:]); err != nil {
+				t.Fatalf("writeRIFF: %v", err)
+			}
+			img, err := webp.Decode(&still)
+			if err != nil {
+				t.Fatalf("frame %d: %v", len(frames), err)
+			}
+			f.img = img
+			frames = append(frames, f)
+		default:
+			t.Fatalf("unexpected chunk %q", id)
+		}
+		p += 8 + size + size%2
+	}
+	return canvas, loops, frames
+}
+
+func TestEncodeAnimationWebP(t *testing.T) {
+	red := New(30, 20, color.NRGBA{255, 0, 0, 255})
+	dot := Clone(red)
+	dot.SetNRGBA(13, 7, color.NRGBA{0, 0, 255, 128})
+	anim := &Animation{
+		Frames:    []image.Image{red, dot, dot, red},
+		Delays:    []time.Duration{100 * time.Millisecond, 50 * time.Millisecond, 30 * time.Millisecond, time.Second},
+		LoopCount: 2,
+	}
+	var buf bytes.Buffer
+	if err := EncodeAnimation(&buf, anim, WEBP, AnimationDeduplicate(0)); err != nil {
+		t.Fatalf("EncodeAnimation: %v", err)
+	}
+	canvas, loops, frames := readAnimatedWebP(t, buf.Bytes())
+	if canvas != image.Pt(30, 20) || loops != 3 {
+		t.Fatalf("got canvas %v and %d loops want (30,20) and 3", canvas, loops)
+	}
+	if len(frames) != 3 {
+		t.Fatalf("got %d frames want 3", len(frames))
+	}
+
+	// The frames are drawn over the canvas without blending.
+	shown := newNRGBA(image.Rect(0, 0, 30, 20))
+	want := []struct {
+		rect     image.Rectangle
+		duration time.Duration
+		img      *image.NRGBA
+	}{
+		{image.Rect(0, 0, 30, 20), 100 * time.Millisecond, red},
+		// The offsets are even.
+		{image.Rect(12, 6, 14, 8), 80 * time.Millisecond, dot},
+		{image.Rect(12, 6, 14, 8), time.Second, red},
+	}
+	for i, f := range frames {
+		if f.rect != want[i].rect || f.duration != want[i].duration || f.flags != 0x02 {
+			t.Fatalf("frame %d: got %v, %v, flags %#x want %v, %v, flags 0x02", i, f.rect, f.duration, f.flags, want[i].rect, want[i].duration)
+		}
+		shown = Paste(shown, f.img, f.rect.Min)
+		if !compareNRGBA(shown, want[i].img, 0) {
+			t.Fatalf("frame %d: the shown image differs", i)
+		}
+	}
+}
+
+func TestConvertAnimationWebP(t *testing.T) {
+	var gifData bytes.Buffer
+	anim := &Animation{
+		Frames: []image.Image{
+			New(8, 8, color.NRGBA{255, 0, 0, 255}),
+			New(8, 8, color.NRGBA{0, 0, 255, 255}),
+		},
+		Delays: []time.Duration{200 * time.Millisecond, 200 * time.Millisecond},
+	}
+	if err := EncodeAnimation(&gifData, anim, GIF); err != nil {
+		t.Fatalf("EncodeAnimation: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := ConvertAnimation(&buf, &gifData, WEBP); err != nil {
+		t.Fatalf("ConvertAnimation: %v", err)
+	}
+	_, loops, frames := readAnimatedWebP(t, buf.Bytes())
+	if loops != 0 || len(frames) != 2 {
+		t.Fatalf("got %d loops and %d frames want 0 and 2", loops, len(frames))
+	}
+	if c := Clone(frames[1].img).NRGBAAt(0, 0); c != (color.NRGBA{0, 0, 255, 255}) {
+		t.Fatalf("got color %v want blue", c)
+	}
+}
+
+func BenchmarkEncodeWebP(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Encode(&bytes.Buffer{}, testdataBranchesJPG, WEBP)
+	}
+}